@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
 	"github.com/konveyor-ecosystem/kubectl-migrate/internal/file"
 	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
 	"github.com/konveyor/crane-lib/apply"
@@ -107,7 +108,7 @@ func (o *Options) run() error {
 		return err
 	}
 
-	files, err := file.ReadFiles(context.TODO(), exportDir)
+	files, err := file.ReadFiles(context.TODO(), exportDir, exporter.ReportFiles)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,258 @@
+package archive
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	exportarchive "github.com/konveyor-ecosystem/kubectl-migrate/internal/archive"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type PackOptions struct {
+	// Two GlobalFlags struct fields are needed
+	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
+	// 2. globalFlags for the args merged with values from the viper config file
+	cobraGlobalFlags *flags.GlobalFlags
+	globalFlags      *flags.GlobalFlags
+	// Two Flags struct fields are needed
+	// 1. cobraFlags for explicit CLI args parsed by cobra
+	// 2. Flags for the args merged with values from the viper config file
+	cobraFlags PackFlags
+	PackFlags
+}
+
+type PackFlags struct {
+	ExportDir string `mapstructure:"export-dir"`
+	File      string `mapstructure:"file"`
+	Checksum  bool   `mapstructure:"checksum"`
+}
+
+type UnpackOptions struct {
+	// Two GlobalFlags struct fields are needed
+	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
+	// 2. globalFlags for the args merged with values from the viper config file
+	cobraGlobalFlags *flags.GlobalFlags
+	globalFlags      *flags.GlobalFlags
+	// Two Flags struct fields are needed
+	// 1. cobraFlags for explicit CLI args parsed by cobra
+	// 2. Flags for the args merged with values from the viper config file
+	cobraFlags UnpackFlags
+	UnpackFlags
+}
+
+type UnpackFlags struct {
+	File string `mapstructure:"file"`
+	Dir  string `mapstructure:"dir"`
+}
+
+func NewArchiveCommand(f *flags.GlobalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "archive",
+		Short: "Pack an export directory into a tar.gz archive, or unpack one",
+		Long: `Pack an export directory into a tar.gz archive, or unpack one, e.g. to move an export to
+another machine. Neither command needs access to a cluster.`,
+	}
+	cmd.AddCommand(newPackCommand(f), newUnpackCommand(f))
+	return cmd
+}
+
+func (o *PackOptions) Complete(c *cobra.Command, args []string) error {
+	if o.File == "" {
+		o.File = strings.TrimSuffix(filepath.Clean(o.ExportDir), string(filepath.Separator)) + ".tar.gz"
+	}
+	return nil
+}
+
+func (o *PackOptions) Validate() error {
+	info, err := os.Stat(o.ExportDir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", o.ExportDir)
+	}
+	// the archive would be packed into itself
+	exportDir, err := filepath.Abs(o.ExportDir)
+	if err != nil {
+		return err
+	}
+	file, err := filepath.Abs(o.File)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(exportDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("--file %s must be outside of the export directory %s", o.File, o.ExportDir)
+	}
+	return nil
+}
+
+func (o *PackOptions) Run() error {
+	return o.run()
+}
+
+func newPackCommand(f *flags.GlobalFlags) *cobra.Command {
+	o := &PackOptions{
+		cobraGlobalFlags: f,
+		globalFlags:      f,
+	}
+	cmd := &cobra.Command{
+		Use:   "pack",
+		Short: "Pack an export directory into a tar.gz archive",
+		Long: `Pack an export directory into a tar.gz archive keeping its layout. With --checksum, the
+SHA-256 of the archive is written next to it with the ` + exportarchive.ChecksumExtension + ` extension, in the
+format of sha256sum, and checked by unpack.`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			c.SilenceUsage = true
+			if err := o.Run(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			_ = viper.BindPFlags(cmd.Flags())
+			_ = viper.Unmarshal(&o.PackFlags)
+			_ = viper.Unmarshal(&o.globalFlags)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.cobraFlags.ExportDir, "export-dir", "e", "export", "The export directory to pack")
+	cmd.Flags().StringVarP(&o.cobraFlags.File, "file", "f", "", "The path of the archive to write, defaults to the export directory with the .tar.gz extension")
+	cmd.Flags().BoolVar(&o.cobraFlags.Checksum, "checksum", false, "Write the SHA-256 of the archive to the archive path with the "+exportarchive.ChecksumExtension+" extension")
+
+	return cmd
+}
+
+func (o *PackOptions) run() error {
+	log := o.globalFlags.GetLogger()
+
+	if _, err := os.Stat(filepath.Join(o.ExportDir, index.File)); os.IsNotExist(err) {
+		log.Warnf("%s has no %s, the archive cannot be verified when unpacked", o.ExportDir, index.File)
+	}
+	if err := exportarchive.PackFile(o.ExportDir, o.File); err != nil {
+		return fmt.Errorf("cannot pack %s: %w", o.ExportDir, err)
+	}
+	log.Infof("Packed %s to %s", o.ExportDir, o.File)
+	if o.Checksum {
+		sum, err := exportarchive.WriteChecksum(o.File)
+		if err != nil {
+			return fmt.Errorf("cannot write the checksum of %s: %w", o.File, err)
+		}
+		log.Infof("Wrote the sha256 %s to %s%s", sum, o.File, exportarchive.ChecksumExtension)
+	}
+	return nil
+}
+
+func (o *UnpackOptions) Complete(c *cobra.Command, args []string) error {
+	return nil
+}
+
+func (o *UnpackOptions) Validate() error {
+	if o.File == "" {
+		return fmt.Errorf("--file is required")
+	}
+	// an unpacked export mixed with other files could not be verified
+	entries, err := os.ReadDir(o.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("--dir %s must be empty or not exist", o.Dir)
+	}
+	return nil
+}
+
+func (o *UnpackOptions) Run() error {
+	return o.run()
+}
+
+func newUnpackCommand(f *flags.GlobalFlags) *cobra.Command {
+	o := &UnpackOptions{
+		cobraGlobalFlags: f,
+		globalFlags:      f,
+	}
+	cmd := &cobra.Command{
+		Use:   "unpack",
+		Short: "Unpack an archive written by archive pack or export --archive",
+		Long: `Unpack an archive written by archive pack or export --archive into an empty directory.
+
+The archive is checked against its ` + exportarchive.ChecksumExtension + ` file first when there is one. The entries
+whose name is absolute or contains .., and the entries other than directories and regular files,
+like symlinks, are refused. The unpacked files are then checked against the checksums of the
+` + index.File + ` of the export, like verify does, and the command exits with a non-zero code when
+they do not match.`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			c.SilenceUsage = true
+			if err := o.Run(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			_ = viper.BindPFlags(cmd.Flags())
+			_ = viper.Unmarshal(&o.UnpackFlags)
+			_ = viper.Unmarshal(&o.globalFlags)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.cobraFlags.File, "file", "f", "", "The path of the archive to unpack")
+	cmd.Flags().StringVarP(&o.cobraFlags.Dir, "dir", "d", "export", "The directory to unpack the archive into, it must be empty or not exist")
+
+	return cmd
+}
+
+func (o *UnpackOptions) run() error {
+	log := o.globalFlags.GetLogger()
+
+	err := exportarchive.VerifyChecksum(o.File)
+	switch {
+	case os.IsNotExist(err):
+		log.Debugf("%s has no %s file, its checksum is not verified", o.File, exportarchive.ChecksumExtension)
+	case err != nil:
+		return err
+	default:
+		log.Infof("Verified the checksum of %s", o.File)
+	}
+	if err := exportarchive.UnpackFile(o.File, o.Dir); err != nil {
+		return fmt.Errorf("cannot unpack %s: %w", o.File, err)
+	}
+
+	entries, err := index.Read(o.Dir)
+	if os.IsNotExist(err) {
+		log.Warnf("%s has no %s, the unpacked files are not verified", o.File, index.File)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	problems, err := index.Verify(o.Dir, entries)
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		log.Errorf("%s", p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d files unpacked to %s do not match %s", len(problems), o.Dir, index.File)
+	}
+	log.Infof("Unpacked %s to %s and verified %d files", o.File, o.Dir, len(entries))
+	return nil
+}
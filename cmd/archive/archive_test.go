@@ -0,0 +1,125 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	exportarchive "github.com/konveyor-ecosystem/kubectl-migrate/internal/archive"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+)
+
+func writeTestExport(t *testing.T, exportDir string, indexed string) {
+	t.Helper()
+	path := filepath.Join(exportDir, "resources", "foo", "ConfigMap__v1_foo_bar.yaml")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	content := "kind: ConfigMap\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	entries := []index.Entry{{Path: "resources/foo/ConfigMap__v1_foo_bar.yaml", Size: int64(len(indexed)), SHA256: index.Sum([]byte(indexed))}}
+	if err := index.Write(exportDir, entries); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPackUnpack(t *testing.T) {
+	tests := []struct {
+		name    string
+		indexed string
+		tamper  bool
+		wantErr bool
+	}{
+		{
+			name:    "given a valid export, should unpack and verify it",
+			indexed: "kind: ConfigMap\n",
+		},
+		{
+			name:    "given a file not matching the index, should fail",
+			indexed: "kind: Secret\n",
+			wantErr: true,
+		},
+		{
+			name:    "given an archive not matching its checksum, should fail",
+			indexed: "kind: ConfigMap\n",
+			tamper:  true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			exportDir := filepath.Join(dir, "out")
+			writeTestExport(t, exportDir, tt.indexed)
+
+			pack := &PackOptions{globalFlags: &flags.GlobalFlags{}}
+			pack.ExportDir = exportDir
+			pack.Checksum = true
+			if err := pack.Complete(nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if pack.File != exportDir+".tar.gz" {
+				t.Errorf("default file = %s, want %s.tar.gz", pack.File, exportDir)
+			}
+			if err := pack.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if err := pack.run(); err != nil {
+				t.Fatalf("pack run() error = %v", err)
+			}
+			if _, err := os.Stat(pack.File + exportarchive.ChecksumExtension); err != nil {
+				t.Errorf("checksum file not written: %v", err)
+			}
+			if tt.tamper {
+				f, err := os.OpenFile(pack.File, os.O_APPEND|os.O_WRONLY, 0600)
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.Write([]byte{0})
+				f.Close()
+			}
+
+			unpack := &UnpackOptions{globalFlags: &flags.GlobalFlags{}}
+			unpack.File = pack.File
+			unpack.Dir = filepath.Join(dir, "restored")
+			if err := unpack.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			err := unpack.run()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unpack run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got, err := os.ReadFile(filepath.Join(unpack.Dir, "resources", "foo", "ConfigMap__v1_foo_bar.yaml"))
+			if err != nil || string(got) != "kind: ConfigMap\n" {
+				t.Errorf("unpacked file = %q, %v", got, err)
+			}
+			if err := unpack.Validate(); err == nil {
+				t.Errorf("Validate() of a non-empty directory succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestPackOptions_Validate(t *testing.T) {
+	exportDir := t.TempDir()
+	o := &PackOptions{}
+	o.ExportDir = exportDir
+	o.File = filepath.Join(exportDir, "out.tar.gz")
+	if err := o.Validate(); err == nil {
+		t.Errorf("Validate() of an archive inside the export directory succeeded, want error")
+	}
+	o.File = filepath.Join(t.TempDir(), "out.tar.gz")
+	if err := o.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	o.ExportDir = filepath.Join(exportDir, "missing")
+	if err := o.Validate(); err == nil {
+		t.Errorf("Validate() of a missing export directory succeeded, want error")
+	}
+}
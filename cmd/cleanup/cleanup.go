@@ -0,0 +1,405 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	k8sdiscovery "k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+)
+
+// CleanupIncompleteError is returned by cleanup when some of the applied objects could not be
+// deleted or were not gone within the timeout
+type CleanupIncompleteError struct {
+	Remaining int
+}
+
+func (e *CleanupIncompleteError) Error() string {
+	return fmt.Sprintf("cleanup completed with %d objects remaining", e.Remaining)
+}
+
+// teardownObject is an object applied by replay that cleanup deletes
+type teardownObject struct {
+	resource   schema.GroupVersionResource
+	namespaced bool
+	// tier is the tier of applyTiers the object was applied in, the tiers are deleted in reverse
+	tier int
+	obj  unstructured.Unstructured
+	// reason tells why the object remains after the cleanup
+	reason string
+}
+
+func (o teardownObject) String() string {
+	if !o.namespaced {
+		return fmt.Sprintf("%s %s", o.obj.GetKind(), o.obj.GetName())
+	}
+	return fmt.Sprintf("%s %s/%s", o.obj.GetKind(), o.obj.GetNamespace(), o.obj.GetName())
+}
+
+// teardown deletes from the target cluster the objects applied by replay. Only the objects still
+// labeled managedLabel and not owned by another object are deleted: the owned ones are left to
+// the garbage collector, and the labeled ones were applied by replay.
+type teardown struct {
+	client dynamic.Interface
+	mapper meta.RESTMapper
+	// resources are the namespaced resources of the target cluster, listed for the labeled objects
+	resources []*metav1.APIResourceList
+	// deleteNamespaces deletes the labeled namespaces themselves
+	deleteNamespaces bool
+	log              logrus.FieldLogger
+}
+
+// objects returns the objects to delete, in the reverse of the order replay applied them in. The
+// objects of apply-results.json are looked up first, then the labeled objects of the namespaces.
+// The cluster-scoped objects replay did not create, like a CRD shared with other applications, are
+// not deleted.
+func (t *teardown) objects(ctx context.Context, namespaces []string, results []exporter.ApplyResult) []teardownObject {
+	found := map[string]bool{}
+	objects := []teardownObject{}
+	add := func(o teardownObject) {
+		key := exporter.ReplayKey(o.obj)
+		if found[key] || o.obj.GetLabels()[exporter.ManagedLabel] != "true" || len(o.obj.GetOwnerReferences()) > 0 {
+			return
+		}
+		found[key] = true
+		o.tier = exporter.ApplyTier(o.obj)
+		objects = append(objects, o)
+	}
+
+	for _, result := range results {
+		switch {
+		case result.Result != exporter.ApplyCreated && result.Result != exporter.ApplyConfigured && result.Result != exporter.ApplyUnchanged,
+			result.APIVersion == "v1" && result.Kind == "Namespace",
+			result.Namespace != "" && !slices.Contains(namespaces, result.Namespace),
+			result.Namespace == "" && result.Result != exporter.ApplyCreated:
+			continue
+		}
+		gv, err := schema.ParseGroupVersion(result.APIVersion)
+		if err != nil {
+			t.log.Warnf("cannot delete %s %s/%s: %v", result.Kind, result.Namespace, result.Name, err)
+			continue
+		}
+		mapping, err := t.mapper.RESTMapping(schema.GroupKind{Group: gv.Group, Kind: result.Kind}, gv.Version)
+		if err != nil {
+			t.log.Warnf("cannot delete %s %s/%s: %v", result.Kind, result.Namespace, result.Name, err)
+			continue
+		}
+		o := teardownObject{resource: mapping.Resource, namespaced: mapping.Scope.Name() == meta.RESTScopeNameNamespace}
+		obj, err := t.resource(o.resource, o.namespaced, result.Namespace).Get(ctx, result.Name, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			continue
+		case err != nil:
+			t.log.Warnf("cannot get %s %s/%s to delete it: %v", result.Kind, result.Namespace, result.Name, err)
+			continue
+		}
+		obj.SetAPIVersion(result.APIVersion)
+		obj.SetKind(result.Kind)
+		o.obj = *obj
+		add(o)
+	}
+
+	p := exporter.NewPruner(t.client, t.resources, nil, false, t.log)
+	candidates, _ := p.Candidates(ctx, namespaces, map[string]bool{})
+	for _, c := range candidates {
+		add(teardownObject{resource: c.Resource, namespaced: true, obj: c.Object})
+	}
+
+	if t.deleteNamespaces {
+		for _, namespace := range namespaces {
+			obj, err := t.client.Resource(exporter.NamespacesGVR).Get(ctx, namespace, metav1.GetOptions{})
+			switch {
+			case apierrors.IsNotFound(err):
+				continue
+			case err != nil:
+				t.log.Warnf("cannot get namespace %s to delete it: %v", namespace, err)
+				continue
+			case obj.GetLabels()[exporter.ManagedLabel] != "true":
+				t.log.Warnf("namespace %s was not applied by replay, it is not deleted", namespace)
+				continue
+			}
+			obj.SetAPIVersion("v1")
+			obj.SetKind("Namespace")
+			add(teardownObject{resource: exporter.NamespacesGVR, obj: *obj})
+		}
+	}
+
+	sort.SliceStable(objects, func(i, j int) bool {
+		if objects[i].tier != objects[j].tier {
+			return objects[i].tier > objects[j].tier
+		}
+		return objects[i].String() < objects[j].String()
+	})
+	return objects
+}
+
+func (t *teardown) resource(gvr schema.GroupVersionResource, namespaced bool, namespace string) dynamic.ResourceInterface {
+	if namespaced {
+		return t.client.Resource(gvr).Namespace(namespace)
+	}
+	return t.client.Resource(gvr)
+}
+
+// delete deletes the objects tier by tier, waiting for the objects of a tier to be gone before
+// deleting the next one, and returns the objects remaining. The waits share the timeout, once it
+// expired the next tiers are deleted without waiting.
+func (t *teardown) delete(ctx context.Context, objects []teardownObject, timeout time.Duration) []teardownObject {
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	remaining := []teardownObject{}
+	for start := 0; start < len(objects); {
+		end := start
+		for end < len(objects) && objects[end].tier == objects[start].tier {
+			end++
+		}
+		deleted := []teardownObject{}
+		for _, o := range objects[start:end] {
+			// the object is only deleted if it was not replaced since it was listed
+			uid := o.obj.GetUID()
+			propagation := metav1.DeletePropagationBackground
+			options := metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &uid}, PropagationPolicy: &propagation}
+			err := t.resource(o.resource, o.namespaced, o.obj.GetNamespace()).Delete(ctx, o.obj.GetName(), options)
+			switch {
+			case apierrors.IsNotFound(err):
+				t.log.Debugf("%s is already deleted", o)
+			case err != nil:
+				t.log.Errorf("cannot delete %s: %v", o, err)
+				o.reason = err.Error()
+				remaining = append(remaining, o)
+			default:
+				t.log.Infof("deleted %s", o)
+				deleted = append(deleted, o)
+			}
+		}
+		remaining = append(remaining, t.waitDeleted(waitCtx, deleted, timeout)...)
+		start = end
+	}
+	return remaining
+}
+
+// waitDeleted waits for the deleted objects to be gone, e.g. once their finalizers ran, and returns
+// the ones still there when the context expires
+func (t *teardown) waitDeleted(ctx context.Context, deleted []teardownObject, timeout time.Duration) []teardownObject {
+	pending := deleted
+	err := wait.PollUntilContextCancel(ctx, 2*time.Second, true, func(ctx context.Context) (bool, error) {
+		still := []teardownObject{}
+		for _, o := range pending {
+			_, err := t.resource(o.resource, o.namespaced, o.obj.GetNamespace()).Get(ctx, o.obj.GetName(), metav1.GetOptions{})
+			if !apierrors.IsNotFound(err) {
+				still = append(still, o)
+			}
+		}
+		pending = still
+		if len(pending) > 0 {
+			t.log.Infof("waiting for %d objects to be deleted", len(pending))
+		}
+		return len(pending) == 0, nil
+	})
+	if err == nil {
+		return nil
+	}
+	for i := range pending {
+		pending[i].reason = fmt.Sprintf("not deleted within %s", timeout)
+	}
+	return pending
+}
+
+// printTeardown prints the objects cleanup deletes with --dry-run, or the ones remaining after it
+func printTeardown(out io.Writer, objects []teardownObject, remaining []teardownObject, dryRun bool) {
+	if dryRun {
+		if len(objects) == 0 {
+			fmt.Fprintf(out, "No object to delete\n")
+			return
+		}
+		fmt.Fprintf(out, "%d objects would be deleted:\n", len(objects))
+		for _, o := range objects {
+			fmt.Fprintf(out, "  %s\n", o)
+		}
+		return
+	}
+	fmt.Fprintf(out, "%d deleted, %d remaining\n", len(objects)-len(remaining), len(remaining))
+	for _, o := range remaining {
+		fmt.Fprintf(out, "  %s: %s\n", o, o.reason)
+	}
+}
+
+type CleanupOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+
+	// Two GlobalFlags struct fields are needed
+	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
+	// 2. globalFlags for the args merged with values from the viper config file
+	cobraGlobalFlags *flags.GlobalFlags
+	globalFlags      *flags.GlobalFlags
+
+	exportDir        string
+	namespaces       []string
+	timeout          time.Duration
+	deleteNamespaces bool
+	dryRun           bool
+
+	// results are read from apply-results.json by Complete, empty without a replay
+	results []exporter.ApplyResult
+
+	genericclioptions.IOStreams
+}
+
+func (o *CleanupOptions) Complete(c *cobra.Command, args []string) error {
+	results, err := exporter.ReadApplyResults(o.exportDir)
+	switch {
+	case os.IsNotExist(err):
+		o.results = []exporter.ApplyResult{}
+	case err != nil:
+		return err
+	default:
+		o.results = results
+	}
+	if len(o.namespaces) == 0 {
+		// the namespaces replay applied objects to
+		for _, result := range o.results {
+			switch {
+			case result.Namespace != "":
+				o.namespaces = append(o.namespaces, result.Namespace)
+			case result.APIVersion == "v1" && result.Kind == "Namespace":
+				o.namespaces = append(o.namespaces, result.Name)
+			}
+		}
+	}
+	o.namespaces = exporter.UniqueNamespaces(o.namespaces)
+	return nil
+}
+
+func (o *CleanupOptions) Validate() error {
+	if len(o.namespaces) == 0 {
+		return fmt.Errorf("no namespace to clean up, %s has no applied object, use --namespace", exporter.ApplyResultsFile)
+	}
+	if o.timeout < 0 {
+		return fmt.Errorf("--timeout must not be negative")
+	}
+	return nil
+}
+
+func (o *CleanupOptions) Run() error {
+	return o.run(context.Background(), o.globalFlags.GetLogger())
+}
+
+func NewCleanupCommand(streams genericclioptions.IOStreams, f *flags.GlobalFlags) *cobra.Command {
+	o := &CleanupOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+
+		IOStreams:        streams,
+		cobraGlobalFlags: f,
+		globalFlags:      f,
+	}
+	cmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Delete the objects applied by replay from the target cluster",
+		Long: `Delete the objects applied by replay from the target cluster, e.g. to roll back a migration.
+
+The objects recorded in ` + exporter.ApplyResultsFile + ` and the objects of the target namespaces labeled
+` + exporter.ManagedLabel + `=true are deleted in the reverse of the order replay applied them in, the
+webhook configurations first and the CRDs last. Each tier is waited for until the objects are
+gone, --timeout bounding the whole wait. Only the objects still labeled are deleted, the objects
+owned by another one are left to the garbage collector, and the cluster-scoped objects are only
+deleted when replay created them. The namespaces are kept unless --delete-namespace is given.
+
+The namespaces default to the ones of ` + exporter.ApplyResultsFile + `. --dry-run lists the objects that
+would be deleted without deleting them.
+
+Exit codes:
+  0    every object was deleted
+  1    fatal error, like an unreachable cluster
+  2    some objects remain, they are listed with the reason`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			c.SilenceUsage = true
+			if err := o.Run(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+			viper.Unmarshal(o.globalFlags)
+			viper.Unmarshal(o.configFlags)
+			viper.UnmarshalKey("export-dir", &o.exportDir)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.exportDir, "export-dir", "e", "export", "The export directory holding the "+exporter.ApplyResultsFile+" of the replay to clean up")
+	cmd.Flags().StringSliceVarP(&o.namespaces, "namespace", "n", nil, "The target namespace to clean up, defaults to the namespaces of "+exporter.ApplyResultsFile+". Can be repeated or comma-separated")
+	cmd.Flags().DurationVar(&o.timeout, "timeout", 5*time.Minute, "How long to wait for the deleted objects to be gone")
+	cmd.Flags().BoolVar(&o.deleteNamespaces, "delete-namespace", false, "Also delete the target namespaces applied by replay")
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, "List the objects that would be deleted without deleting them")
+	// the namespace flag is registered above so it can take several values
+	o.configFlags.Namespace = nil
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func (o *CleanupOptions) run(ctx context.Context, log logrus.FieldLogger) error {
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return fmt.Errorf("cannot create rest config: %w", err)
+	}
+	client, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("cannot create dynamic client: %w", err)
+	}
+	mapper, err := o.configFlags.ToRESTMapper()
+	if err != nil {
+		return fmt.Errorf("cannot create rest mapper: %w", err)
+	}
+	discoveryClient, err := o.configFlags.ToDiscoveryClient()
+	if err != nil {
+		return fmt.Errorf("cannot create discovery client: %w", err)
+	}
+	resources, err := k8sdiscovery.ServerPreferredNamespacedResources(discoveryClient)
+	switch {
+	case k8sdiscovery.IsGroupDiscoveryFailedError(err):
+		// the labeled objects of the groups that cannot be discovered are only deleted when recorded
+		log.Warnf("some API groups cannot be discovered, their objects not in %s are not deleted: %v", exporter.ApplyResultsFile, err)
+	case err != nil:
+		return fmt.Errorf("cannot discover the resources to delete: %w", err)
+	}
+	t := &teardown{client: client, mapper: mapper, resources: resources, deleteNamespaces: o.deleteNamespaces, log: log}
+	return o.cleanup(ctx, t)
+}
+
+func (o *CleanupOptions) cleanup(ctx context.Context, t *teardown) error {
+	objects := t.objects(ctx, o.namespaces, o.results)
+	if o.dryRun {
+		printTeardown(o.Out, objects, nil, true)
+		return nil
+	}
+	remaining := t.delete(ctx, objects, o.timeout)
+	printTeardown(o.Out, objects, remaining, false)
+	if len(remaining) > 0 {
+		return &CleanupIncompleteError{Remaining: len(remaining)}
+	}
+	return nil
+}
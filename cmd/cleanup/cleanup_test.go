@@ -0,0 +1,167 @@
+package cleanup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func testLogger() logrus.FieldLogger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func testReplayObject(apiVersion, kind, namespace, name string) unstructured.Unstructured {
+	obj := unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+func TestCleanupOptions_cleanup(t *testing.T) {
+	object := func(apiVersion, kind, namespace, name string, managed bool) *unstructured.Unstructured {
+		obj := testReplayObject(apiVersion, kind, namespace, name)
+		if managed {
+			obj.SetLabels(map[string]string{exporter.ManagedLabel: "true"})
+		}
+		return &obj
+	}
+	owned := object("apps/v1", "ReplicaSet", "bar", "web-abc", true)
+	owned.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"}})
+	newClient := func() *dynamicfake.FakeDynamicClient {
+		return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{
+				{Version: "v1", Resource: "configmaps"}:                 "ConfigMapList",
+				{Group: "apps", Version: "v1", Resource: "deployments"}: "DeploymentList",
+				{Group: "apps", Version: "v1", Resource: "replicasets"}: "ReplicaSetList",
+			},
+			object("v1", "Namespace", "", "bar", true),
+			object("apps/v1", "Deployment", "bar", "web", true),
+			object("apps/v1", "Deployment", "bar", "unmanaged", false),
+			object("apps/v1", "Deployment", "other", "web", true),
+			object("v1", "ConfigMap", "bar", "settings", true),
+			object("rbac.authorization.k8s.io/v1", "ClusterRole", "", "reader", true),
+			object("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "widgets.example.com", true),
+			owned,
+		)
+	}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}, meta.RESTScopeRoot)
+	resources := []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: []string{"list", "delete"}},
+		}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "deployments", Kind: "Deployment", Namespaced: true, Verbs: []string{"list", "delete"}},
+			{Name: "replicasets", Kind: "ReplicaSet", Namespaced: true, Verbs: []string{"list", "delete"}},
+		}},
+	}
+	results := []exporter.ApplyResult{
+		{Tier: "namespaces", APIVersion: "v1", Kind: "Namespace", Name: "bar", Result: exporter.ApplyCreated},
+		{Tier: "crds", APIVersion: "apiextensions.k8s.io/v1", Kind: "CustomResourceDefinition", Name: "widgets.example.com", Result: exporter.ApplyConfigured},
+		{Tier: "cluster", APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole", Name: "reader", Result: exporter.ApplyCreated},
+		{Tier: "workloads", Namespace: "bar", APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Result: exporter.ApplyUnchanged},
+		{Tier: "workloads", Namespace: "bar", APIVersion: "apps/v1", Kind: "Deployment", Name: "unmanaged", Result: exporter.ApplyConfigured},
+		{Tier: "workloads", Namespace: "bar", APIVersion: "apps/v1", Kind: "Deployment", Name: "failed", Result: exporter.ApplyFailed},
+	}
+
+	tests := []struct {
+		name             string
+		deleteNamespaces bool
+		dryRun           bool
+		want             string
+		wantDeleted      []string
+	}{
+		{
+			name:        "given --dry-run, should list the objects in reverse order without deleting them",
+			dryRun:      true,
+			want:        "3 objects would be deleted:\n  Deployment bar/web\n  ConfigMap bar/settings\n  ClusterRole reader\n",
+			wantDeleted: []string{},
+		},
+		{
+			name:        "given the applied objects, should delete the managed ones but the namespace",
+			want:        "3 deleted, 0 remaining\n",
+			wantDeleted: []string{"deployments/web", "configmaps/settings", "clusterroles/reader"},
+		},
+		{
+			name:             "given --delete-namespace, should delete the namespace last",
+			deleteNamespaces: true,
+			want:             "4 deleted, 0 remaining\n",
+			wantDeleted:      []string{"deployments/web", "configmaps/settings", "clusterroles/reader", "namespaces/bar"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newClient()
+			out := &bytes.Buffer{}
+			o := &CleanupOptions{namespaces: []string{"bar"}, results: results, timeout: time.Second, deleteNamespaces: tt.deleteNamespaces, dryRun: tt.dryRun}
+			o.Out = out
+			td := &teardown{client: client, mapper: mapper, resources: resources, deleteNamespaces: tt.deleteNamespaces, log: testLogger()}
+			if err := o.cleanup(context.Background(), td); err != nil {
+				t.Fatalf("cleanup() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("cleanup() output = %q, want %q", out.String(), tt.want)
+			}
+			deleted := []string{}
+			for _, action := range client.Actions() {
+				if action, ok := action.(k8stesting.DeleteAction); ok {
+					deleted = append(deleted, action.GetResource().Resource+"/"+action.GetName())
+				}
+			}
+			if !reflect.DeepEqual(deleted, tt.wantDeleted) {
+				t.Errorf("deleted %v, want %v", deleted, tt.wantDeleted)
+			}
+			if _, err := client.Resource(exporter.CRDResource).Get(context.Background(), "widgets.example.com", metav1.GetOptions{}); err != nil {
+				t.Errorf("the configured CRD was deleted: %v", err)
+			}
+			if _, err := client.Resource(schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "replicasets"}).Namespace("bar").Get(context.Background(), "web-abc", metav1.GetOptions{}); err != nil {
+				t.Errorf("the owned ReplicaSet was deleted: %v", err)
+			}
+		})
+	}
+}
+
+func Test_teardown_delete_remaining(t *testing.T) {
+	obj := testReplayObject("v1", "ConfigMap", "bar", "settings")
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), &obj)
+	client.PrependReactor("delete", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "settings", errors.New("denied"))
+	})
+	td := &teardown{client: client, log: testLogger()}
+	objects := []teardownObject{{resource: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, namespaced: true, tier: exporter.TierConfig, obj: obj}}
+	out := &bytes.Buffer{}
+	o := &CleanupOptions{timeout: time.Second}
+	o.Out = out
+	remaining := td.delete(context.Background(), objects, o.timeout)
+	if len(remaining) != 1 || !strings.Contains(remaining[0].reason, "denied") {
+		t.Fatalf("delete() remaining = %+v, want the forbidden object", remaining)
+	}
+	printTeardown(out, objects, remaining, false)
+	if want := "0 deleted, 1 remaining\n  ConfigMap bar/settings: "; !strings.HasPrefix(out.String(), want) {
+		t.Errorf("output = %q, want prefix %q", out.String(), want)
+	}
+}
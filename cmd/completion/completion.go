@@ -0,0 +1,93 @@
+package completion
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	shellBash       = "bash"
+	shellZsh        = "zsh"
+	shellFish       = "fish"
+	shellPowershell = "powershell"
+)
+
+type Options struct {
+	// Two GlobalFlags struct fields are needed
+	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
+	// 2. globalFlags for the args merged with values from the viper config file
+	cobraGlobalFlags *flags.GlobalFlags
+	globalFlags      *flags.GlobalFlags
+
+	shell string
+	// root is the command whose completion script is generated
+	root *cobra.Command
+	out  io.Writer
+}
+
+func (o *Options) Complete(c *cobra.Command, args []string) error {
+	o.shell = args[0]
+	o.root = c.Root()
+	o.out = c.OutOrStdout()
+	return nil
+}
+
+func (o *Options) Validate() error {
+	switch o.shell {
+	case shellBash, shellZsh, shellFish, shellPowershell:
+		return nil
+	}
+	return fmt.Errorf("unsupported shell %q, must be one of: %s, %s, %s, %s", o.shell, shellBash, shellZsh, shellFish, shellPowershell)
+}
+
+func (o *Options) Run() error {
+	switch o.shell {
+	case shellZsh:
+		return o.root.GenZshCompletion(o.out)
+	case shellFish:
+		return o.root.GenFishCompletion(o.out, true)
+	case shellPowershell:
+		return o.root.GenPowerShellCompletionWithDesc(o.out)
+	}
+	return o.root.GenBashCompletionV2(o.out, true)
+}
+
+func NewCompletionCommand(f *flags.GlobalFlags) *cobra.Command {
+	o := &Options{
+		cobraGlobalFlags: f,
+	}
+	cmd := &cobra.Command{
+		Use:   "completion bash|zsh|fish|powershell",
+		Short: "Print the shell completion script",
+		Long: `Print the completion script of the shell. The namespaces and the resources are completed from
+the cluster when it is reachable, the flags otherwise.
+
+  bash:        source <(kubectl-migrate completion bash)
+  zsh:         kubectl-migrate completion zsh > "${fpath[1]}/_kubectl-migrate"
+  fish:        kubectl-migrate completion fish | source
+  powershell:  kubectl-migrate completion powershell | Out-String | Invoke-Expression`,
+		Args:                  cobra.ExactArgs(1),
+		ValidArgs:             []string{shellBash, shellZsh, shellFish, shellPowershell},
+		DisableFlagsInUseLine: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			if err := o.Run(); err != nil {
+				return err
+			}
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.Unmarshal(&o.globalFlags)
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,38 @@
+package completion
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/spf13/cobra"
+)
+
+func TestNewCompletionCommand(t *testing.T) {
+	for _, shell := range []string{shellBash, shellZsh, shellFish, shellPowershell} {
+		t.Run("given "+shell+", should print its completion script", func(t *testing.T) {
+			root := &cobra.Command{Use: "kubectl-migrate"}
+			root.AddCommand(NewCompletionCommand(&flags.GlobalFlags{}))
+			out := &bytes.Buffer{}
+			root.SetOut(out)
+			root.SetArgs([]string{"completion", shell})
+			if err := root.Execute(); err != nil {
+				t.Fatalf("completion %s error = %v", shell, err)
+			}
+			if !bytes.Contains(out.Bytes(), []byte("kubectl-migrate")) {
+				t.Errorf("completion %s = %q, want a script for kubectl-migrate", shell, out.String())
+			}
+		})
+	}
+
+	t.Run("given an unknown shell, should fail", func(t *testing.T) {
+		root := &cobra.Command{Use: "kubectl-migrate"}
+		root.AddCommand(NewCompletionCommand(&flags.GlobalFlags{}))
+		root.SetOut(&bytes.Buffer{})
+		root.SetErr(&bytes.Buffer{})
+		root.SetArgs([]string{"completion", "tcsh"})
+		if err := root.Execute(); err == nil {
+			t.Errorf("completion tcsh should fail")
+		}
+	})
+}
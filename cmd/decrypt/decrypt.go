@@ -0,0 +1,132 @@
+package decrypt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/encryption"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type Options struct {
+	// Two GlobalFlags struct fields are needed
+	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
+	// 2. globalFlags for the args merged with values from the viper config file
+	cobraGlobalFlags *flags.GlobalFlags
+	globalFlags      *flags.GlobalFlags
+	// Two Flags struct fields are needed
+	// 1. cobraFlags for explicit CLI args parsed by cobra
+	// 2. Flags for the args merged with values from the viper config file
+	cobraFlags Flags
+	Flags
+}
+
+type Flags struct {
+	ExportDir    string `mapstructure:"export-dir"`
+	IdentityFile string `mapstructure:"identity-file"`
+	Keep         bool   `mapstructure:"keep"`
+}
+
+func (o *Options) Complete(c *cobra.Command, args []string) error {
+	return nil
+}
+
+func (o *Options) Validate() error {
+	if o.IdentityFile == "" {
+		return fmt.Errorf("--identity-file is required")
+	}
+	return nil
+}
+
+func (o *Options) Run() error {
+	return o.run()
+}
+
+func NewDecryptCommand(f *flags.GlobalFlags) *cobra.Command {
+	o := &Options{
+		cobraGlobalFlags: f,
+		globalFlags:      f,
+	}
+	cmd := &cobra.Command{
+		Use:   "decrypt",
+		Short: "Decrypt the Secrets encrypted by export --encrypt-secrets-to in an export directory",
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			if err := o.Run(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			_ = viper.BindPFlags(cmd.Flags())
+			_ = viper.Unmarshal(&o.Flags)
+			_ = viper.Unmarshal(&o.globalFlags)
+		},
+	}
+
+	addFlagsForOptions(&o.cobraFlags, cmd)
+
+	return cmd
+}
+
+func addFlagsForOptions(o *Flags, cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.ExportDir, "export-dir", "e", "export", "The path where the kubernetes resources are saved")
+	cmd.Flags().StringVarP(&o.IdentityFile, "identity-file", "i", "", "The path of the age identity file holding the private key")
+	cmd.Flags().BoolVar(&o.Keep, "keep", false, "Keep the encrypted files after decrypting them")
+}
+
+func (o *Options) run() error {
+	log := o.globalFlags.GetLogger()
+
+	identities, err := encryption.ReadIdentities(o.IdentityFile)
+	if err != nil {
+		return err
+	}
+
+	// Decrypted files are written next to the encrypted ones, without the .age extension
+	decrypted := 0
+	err = filepath.Walk(o.ExportDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, encryption.Extension) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		plain, err := encryption.Decrypt(data, identities)
+		if err != nil {
+			log.Errorf("cannot decrypt %s: %v", path, err)
+			return err
+		}
+		target := strings.TrimSuffix(path, encryption.Extension)
+		if err := os.WriteFile(target, plain, 0600); err != nil {
+			return err
+		}
+		if !o.Keep {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+		log.Debugf("decrypted %s to %s", path, target)
+		decrypted++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Infof("Decrypted %d files in %s", decrypted, o.ExportDir)
+	return nil
+}
@@ -0,0 +1,55 @@
+package decrypt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/encryption"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+)
+
+func TestDecryptRoundTrip(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	identityFile := filepath.Join(dir, "key.txt")
+	if err := os.WriteFile(identityFile, []byte(identity.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	exportDir := filepath.Join(dir, "export")
+	secretPath := filepath.Join(exportDir, "resources", "foo", "Secret__v1_foo_app.yaml")
+	if err := os.MkdirAll(filepath.Dir(secretPath), 0700); err != nil {
+		t.Fatal(err)
+	}
+	plain := []byte("kind: Secret\n")
+	encrypted, err := encryption.Encrypt(plain, []age.Recipient{identity.Recipient()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(secretPath+encryption.Extension, encrypted, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	o := &Options{globalFlags: &flags.GlobalFlags{}}
+	o.ExportDir = exportDir
+	o.IdentityFile = identityFile
+	if err := o.run(); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	got, err := os.ReadFile(secretPath)
+	if err != nil {
+		t.Fatalf("decrypted file not written: %v", err)
+	}
+	if string(got) != string(plain) {
+		t.Errorf("decrypted content = %q, want %q", got, plain)
+	}
+	if _, err := os.Stat(secretPath + encryption.Extension); !os.IsNotExist(err) {
+		t.Errorf("encrypted file should be removed after decryption")
+	}
+}
@@ -0,0 +1,333 @@
+package diff
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/completion"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/encryption"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/yaml"
+)
+
+// DriftError is returned by diff when the live namespaces no longer match the export
+type DriftError struct {
+	Objects int
+}
+
+func (e *DriftError) Error() string {
+	return fmt.Sprintf("%d objects differ from the export", e.Objects)
+}
+
+type DiffOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+
+	// Two GlobalFlags struct fields are needed
+	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
+	// 2. globalFlags for the args merged with values from the viper config file
+	cobraGlobalFlags *flags.GlobalFlags
+	globalFlags      *flags.GlobalFlags
+
+	exportDir  string
+	namespaces []string
+	detail     bool
+
+	genericclioptions.IOStreams
+}
+
+func (o *DiffOptions) Complete(c *cobra.Command, args []string) error {
+	return nil
+}
+
+func (o *DiffOptions) Validate() error {
+	return nil
+}
+
+func (o *DiffOptions) Run() error {
+	return o.run(context.Background(), o.globalFlags.GetLogger())
+}
+
+func NewDiffCommand(streams genericclioptions.IOStreams, f *flags.GlobalFlags) *cobra.Command {
+	o := &DiffOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+
+		IOStreams:        streams,
+		cobraGlobalFlags: f,
+		globalFlags:      f,
+	}
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Compare an export with the live namespaces",
+		Long: `Compare an export with the live namespaces.
+
+The namespaces are listed again with the flags recorded in ` + exporter.SummaryJSONFile + `, so that the same
+resources and objects are selected and prepared like the export did, rewritten with its
+--image-map and --storageclass-map and moved to its --target-namespace, and the objects added,
+removed or modified since the export are printed. --detail prints a unified diff of the YAML of each object.
+The encrypted Secrets are only compared by existence. The Events, the Namespaces and the cluster-scoped
+objects exported with --include-crds, --include-cluster-deps and --include-webhooks are not compared.
+
+Exit codes:
+  0    the live namespaces match the export
+  1    fatal error, like an invalid export or a resource that could not be listed
+  3    some objects differ from the export`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			c.SilenceUsage = true
+			if err := o.Run(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+			viper.Unmarshal(o.globalFlags)
+			viper.Unmarshal(o.configFlags)
+			viper.UnmarshalKey("export-dir", &o.exportDir)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.exportDir, "export-dir", "e", "export", "The export directory to compare, as written by export")
+	cmd.Flags().StringSliceVarP(&o.namespaces, "namespace", "n", nil, "The exported namespace to compare, defaults to every namespace of the export. Can be repeated or comma-separated")
+	cmd.Flags().BoolVar(&o.detail, "detail", false, "Print a unified diff of the YAML of each object added, removed or modified")
+	// the namespace flag is registered above so it can take several values
+	o.configFlags.Namespace = nil
+	o.configFlags.AddFlags(cmd.Flags())
+	cmd.RegisterFlagCompletionFunc("namespace", completion.Namespaces(o.configFlags))
+
+	return cmd
+}
+
+func (o *DiffOptions) run(ctx context.Context, log logrus.FieldLogger) error {
+	previous, err := exporter.ReadRunSummary(o.exportDir)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s has no export to compare, %s not found", o.exportDir, exporter.SummaryJSONFile)
+	}
+	if err != nil {
+		return err
+	}
+	namespaces, err := previous.SelectNamespaces(o.namespaces)
+	if err != nil {
+		return err
+	}
+	entries, err := index.Read(o.exportDir)
+	if err != nil {
+		return fmt.Errorf("cannot read the %s of %s: %w", index.File, o.exportDir, err)
+	}
+
+	l, err := exporter.NewLiveExport(previous, o.exportDir, o.configFlags, o.IOStreams, o.cobraGlobalFlags, o.globalFlags, log)
+	if err != nil {
+		return err
+	}
+
+	drifted := 0
+	for _, namespace := range namespaces {
+		exported, err := readExportedObjects(o.exportDir, namespace, entries, l.ClusterScopedRBAC())
+		if err != nil {
+			return err
+		}
+		objects, err := l.Objects(ctx, namespace, log)
+		if err != nil {
+			return err
+		}
+		live, err := liveObjects(objects)
+		if err != nil {
+			return err
+		}
+		diffs := compareObjects(exported, live)
+		if err := printDiffs(o.Out, namespace, diffs, o.detail); err != nil {
+			return err
+		}
+		drifted += len(diffs)
+	}
+	if drifted > 0 {
+		return &DriftError{Objects: drifted}
+	}
+	return nil
+}
+
+// diffObject is an object of the export or of the live namespace, data is its YAML. The encrypted
+// objects of the export have no data, they are only compared by existence.
+type diffObject struct {
+	APIVersion string
+	Kind       string
+	Name       string
+	path       string
+	data       []byte
+	encrypted  bool
+}
+
+func newDiffObject(obj unstructured.Unstructured, path string) (diffObject, error) {
+	data, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return diffObject{}, err
+	}
+	return diffObject{APIVersion: obj.GetAPIVersion(), Kind: obj.GetKind(), Name: obj.GetName(), path: path, data: data}, nil
+}
+
+// key identifies the object in its namespace, the versions of a resource exported with
+// --all-versions are compared apart
+func (d diffObject) key() string {
+	return d.APIVersion + "/" + d.Kind + "/" + d.Name
+}
+
+func (d diffObject) String() string {
+	return fmt.Sprintf("%s %s %s", d.APIVersion, d.Kind, d.Name)
+}
+
+// objectDiff is an object added, removed or modified since the export
+type objectDiff struct {
+	diffObject
+	Change   string
+	exported []byte
+	live     []byte
+}
+
+// isDiffed reports whether an exported object is compared: the objects of the namespace, and the
+// cluster-scoped RBAC exported with --cluster-scoped-rbac
+func isDiffed(apiVersion, kind, namespace string, clusterScopedRbac bool) bool {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	switch {
+	case err != nil:
+		return false
+	case kind == "Namespace" && gv.Group == "":
+		return false
+	case namespace == "":
+		return clusterScopedRbac && exporter.IsClusterScopedResource(gv.Group, kind)
+	}
+	return true
+}
+
+// readExportedObjects reads the objects exported from the namespace per the index of the export,
+// keyed by diffObject.key
+func readExportedObjects(exportDir, namespace string, entries []index.Entry, clusterScopedRbac bool) (map[string]diffObject, error) {
+	objects := map[string]diffObject{}
+	for _, entry := range entries {
+		if !exporter.InNamespaces(entry.Path, []string{namespace}) || strings.HasPrefix(entry.Path, "resources/"+namespace+"/"+exporter.EventsDir+"/") {
+			continue
+		}
+		// the files holding a single object are indexed with it
+		if entry.Name != "" && !isDiffed(entry.APIVersion, entry.Kind, entry.Namespace, clusterScopedRbac) {
+			continue
+		}
+		path := filepath.Join(exportDir, filepath.FromSlash(entry.Path))
+		if strings.HasSuffix(entry.Path, encryption.Extension) {
+			d := diffObject{APIVersion: entry.APIVersion, Kind: entry.Kind, Name: entry.Name, path: path, encrypted: true}
+			objects[d.key()] = d
+			continue
+		}
+		items, err := exporter.ReadManifestFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range items {
+			if !isDiffed(obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), clusterScopedRbac) {
+				continue
+			}
+			d, err := newDiffObject(obj, path)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			objects[d.key()] = d
+		}
+	}
+	return objects, nil
+}
+
+// liveObjects returns the objects listed from the live namespace, keyed by diffObject.key
+func liveObjects(objects []unstructured.Unstructured) (map[string]diffObject, error) {
+	live := map[string]diffObject{}
+	for _, obj := range objects {
+		d, err := newDiffObject(obj, "")
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		live[d.key()] = d
+	}
+	return live, nil
+}
+
+// compareObjects returns the objects added, removed and modified since the export, sorted by key
+func compareObjects(exported, live map[string]diffObject) []objectDiff {
+	keys := map[string]bool{}
+	for key := range exported {
+		keys[key] = true
+	}
+	for key := range live {
+		keys[key] = true
+	}
+	diffs := []objectDiff{}
+	for _, key := range slices.Sorted(maps.Keys(keys)) {
+		e, wasExported := exported[key]
+		l, isLive := live[key]
+		switch {
+		case !isLive:
+			diffs = append(diffs, objectDiff{diffObject: e, Change: exporter.DriftRemoved, exported: e.data})
+		case !wasExported:
+			diffs = append(diffs, objectDiff{diffObject: l, Change: exporter.DriftAdded, live: l.data})
+		case !e.encrypted && !bytes.Equal(e.data, l.data):
+			diffs = append(diffs, objectDiff{diffObject: e, Change: exporter.DriftModified, exported: e.data, live: l.data})
+		}
+	}
+	return diffs
+}
+
+// printDiffs prints the objects of the namespace that differ from the export, with a unified diff
+// of their YAML when detail is set
+func printDiffs(out io.Writer, namespace string, diffs []objectDiff, detail bool) error {
+	if len(diffs) == 0 {
+		fmt.Fprintf(out, "Namespace %s: no difference with the export\n", namespace)
+		return nil
+	}
+	fmt.Fprintf(out, "Namespace %s: %d objects differ from the export\n", namespace, len(diffs))
+	sort.SliceStable(diffs, func(i, j int) bool { return diffs[i].Change < diffs[j].Change })
+	for _, d := range diffs {
+		fmt.Fprintf(out, "  %-8s  %s\n", d.Change, d.diffObject)
+		if !detail || d.encrypted {
+			continue
+		}
+		from := d.path
+		if from == "" {
+			from = "/dev/null"
+		}
+		to := "live/" + namespace + "/" + d.Kind + "/" + d.Name
+		if d.Change == exporter.DriftRemoved {
+			to = "/dev/null"
+		}
+		text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(d.exported)),
+			B:        difflib.SplitLines(string(d.live)),
+			FromFile: from,
+			ToFile:   to,
+			Context:  3,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(out, text)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package diff
+
+import (
+	"bytes"
+	"maps"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter/exportertest"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func testDeployment(name string, replicas int64) unstructured.Unstructured {
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": "foo",
+		},
+		"spec": map[string]interface{}{
+			"replicas": replicas,
+		},
+	}}
+}
+
+func testLiveDeployments(t *testing.T, objects ...unstructured.Unstructured) map[string]diffObject {
+	t.Helper()
+	live, err := liveObjects(objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return live
+}
+
+func Test_diffExport(t *testing.T) {
+	tests := []struct {
+		name     string
+		layout   string
+		compress bool
+	}{
+		{name: "given the flat layout, should compare every object", layout: exporter.LayoutFlat},
+		{name: "given the kind layout compressed, should compare every object", layout: exporter.LayoutKind, compress: true},
+		{name: "given the single layout, should compare the objects of the stream", layout: exporter.LayoutSingle},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exportDir := t.TempDir()
+			entries := exportertest.WriteExport(t, exportDir, tt.layout, tt.compress, testDeployment("api", 1), testDeployment("web", 1), testDeployment("db", 1))
+			exported, err := readExportedObjects(exportDir, "foo", entries, false)
+			if err != nil {
+				t.Fatalf("readExportedObjects() error = %v", err)
+			}
+			if len(exported) != 3 {
+				t.Fatalf("readExportedObjects() = %v, want the 3 deployments without the namespace", slices.Sorted(maps.Keys(exported)))
+			}
+
+			diffs := compareObjects(exported, testLiveDeployments(t, testDeployment("api", 1), testDeployment("web", 3), testDeployment("cache", 1)))
+			got := []string{}
+			for _, d := range diffs {
+				got = append(got, d.Change+" "+d.Name)
+			}
+			want := []string{"added cache", "removed db", "modified web"}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("compareObjects() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func Test_readExportedObjects_skipped(t *testing.T) {
+	exportDir := t.TempDir()
+	entries := exportertest.WriteExport(t, exportDir, exporter.LayoutFlat, false, testDeployment("api", 1))
+	entries = append(entries,
+		index.Entry{Path: "resources/foo/" + exporter.EventsDir + "/Deployment_api.yaml", APIVersion: "v1", Kind: "Event", Namespace: "foo", Name: "api.1"},
+		index.Entry{Path: "resources/foo/secrets_db.yaml.age", APIVersion: "v1", Kind: "Secret", Namespace: "foo", Name: "db"},
+		index.Entry{Path: "resources/foo/_cluster/crds/widgets.example.com.yaml", APIVersion: "apiextensions.k8s.io/v1", Kind: "CustomResourceDefinition", Name: "widgets.example.com"},
+		index.Entry{Path: "resources/bar/deployments.apps_api.yaml", APIVersion: "apps/v1", Kind: "Deployment", Namespace: "bar", Name: "api"},
+	)
+	exported, err := readExportedObjects(exportDir, "foo", entries, false)
+	if err != nil {
+		t.Fatalf("readExportedObjects() error = %v", err)
+	}
+	if got := slices.Sorted(maps.Keys(exported)); !reflect.DeepEqual(got, []string{"apps/v1/Deployment/api", "v1/Secret/db"}) {
+		t.Errorf("readExportedObjects() = %v, want the deployment and the encrypted secret", got)
+	}
+
+	// the encrypted secret is only compared by existence
+	secret := unstructured.Unstructured{}
+	secret.SetAPIVersion("v1")
+	secret.SetKind("Secret")
+	secret.SetName("db")
+	secret.SetNamespace("foo")
+	live := testLiveDeployments(t, testDeployment("api", 1))
+	d, _ := newDiffObject(secret, "")
+	live[d.key()] = d
+	if diffs := compareObjects(exported, live); len(diffs) != 0 {
+		t.Errorf("compareObjects() = %+v, want no difference", diffs)
+	}
+}
+
+func Test_isDiffed(t *testing.T) {
+	tests := []struct {
+		name              string
+		apiVersion        string
+		kind              string
+		namespace         string
+		clusterScopedRbac bool
+		want              bool
+	}{
+		{name: "given a namespaced object, should compare it", apiVersion: "apps/v1", kind: "Deployment", namespace: "foo", want: true},
+		{name: "given the namespace, should skip it", apiVersion: "v1", kind: "Namespace", want: false},
+		{name: "given a cluster role without --cluster-scoped-rbac, should skip it", apiVersion: "rbac.authorization.k8s.io/v1", kind: "ClusterRole", want: false},
+		{name: "given a cluster role with --cluster-scoped-rbac, should compare it", apiVersion: "rbac.authorization.k8s.io/v1", kind: "ClusterRole", clusterScopedRbac: true, want: true},
+		{name: "given a CRD with --cluster-scoped-rbac, should skip it", apiVersion: "apiextensions.k8s.io/v1", kind: "CustomResourceDefinition", clusterScopedRbac: true, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDiffed(tt.apiVersion, tt.kind, tt.namespace, tt.clusterScopedRbac); got != tt.want {
+				t.Errorf("isDiffed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_printDiffs(t *testing.T) {
+	exportDir := t.TempDir()
+	entries := exportertest.WriteExport(t, exportDir, exporter.LayoutFlat, false, testDeployment("web", 1))
+	exported, err := readExportedObjects(exportDir, "foo", entries, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs := compareObjects(exported, testLiveDeployments(t, testDeployment("web", 3)))
+
+	out := &bytes.Buffer{}
+	if err := printDiffs(out, "foo", diffs, true); err != nil {
+		t.Fatalf("printDiffs() error = %v", err)
+	}
+	for _, want := range []string{
+		"Namespace foo: 1 objects differ from the export",
+		"modified  apps/v1 Deployment web",
+		"--- " + filepath.Join(exportDir, "resources", "foo", "deployments.apps_web.yaml"),
+		"+++ live/foo/Deployment/web",
+		"-  replicas: 1\n+  replicas: 3\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("printDiffs() = %q, want it to contain %q", out.String(), want)
+		}
+	}
+
+	out.Reset()
+	if err := printDiffs(out, "foo", nil, true); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "Namespace foo: no difference with the export\n" {
+		t.Errorf("printDiffs() without differences = %q", out.String())
+	}
+}
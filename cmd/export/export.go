@@ -1,185 +1,28 @@
 package export
 
 import (
-	"fmt"
-	"os"
-	"path/filepath"
-	"strings"
-
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
 	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
-	"github.com/vmware-tanzu/velero/pkg/discovery"
-	"github.com/vmware-tanzu/velero/pkg/features"
-	errorsutil "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
-	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/tools/clientcmd/api"
 )
 
-type ExportOptions struct {
-	configFlags *genericclioptions.ConfigFlags
-
-	// Two GlobalFlags struct fields are needed
-	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
-	// 2. globalFlags for the args merged with values from the viper config file
-	cobraGlobalFlags *flags.GlobalFlags
-	globalFlags      *flags.GlobalFlags
-
-	rawConfig              api.Config
-	exportDir              string
-	labelSelector          string
-	userSpecifiedNamespace string
-	clusterScopedRbac      bool
-	asExtras               string
-	extras                 map[string][]string
-	QPS                    float32
-	Burst                  int
-
-	genericclioptions.IOStreams
-}
-
-func (o *ExportOptions) Complete(c *cobra.Command, args []string) error {
-	var err error
-
-	o.rawConfig, err = o.configFlags.ToRawKubeConfigLoader().RawConfig()
-	if err != nil {
-		return err
-	}
-
-	o.userSpecifiedNamespace, _, err = o.configFlags.ToRawKubeConfigLoader().Namespace()
-	if err != nil {
-		return err
-	}
-
-	if o.asExtras != "" {
-		keysAndStrings := strings.Split(o.asExtras, ";")
-		o.extras = map[string][]string{}
-		for _, keysAndString := range keysAndStrings {
-			keyString := strings.Split(keysAndString, "=")
-			if len(keyString) != 2 {
-				return fmt.Errorf("extra options (%v) formatted incorrectly", o.asExtras)
-			}
-			o.extras[keyString[0]] = strings.Split(keyString[1], ",")
-		}
-	}
-
-	return nil
-}
-
-func (o *ExportOptions) Validate() error {
-	if o.asExtras != "" && *o.configFlags.Impersonate == "" && len(*o.configFlags.ImpersonateGroup) == 0 {
-		return fmt.Errorf("extras requires specifying a user or group to impersonate")
-	}
-	return nil
-}
-
-func (o *ExportOptions) Run() error {
-	var err error
-
-	log := o.globalFlags.GetLogger()
-
-	// create export directory if it doesnt exist
-	resourceDir := filepath.Join(o.exportDir, "resources", o.userSpecifiedNamespace)
-	err = os.MkdirAll(resourceDir, 0700)
-	switch {
-	case os.IsExist(err):
-	case err != nil:
-		log.Errorf("error creating the resources directory: %#v", err)
-		return err
-	}
-	// create _cluster directory if it doesnt exist
-	clusterResourceDir := filepath.Join(o.exportDir, "resources", o.userSpecifiedNamespace, "_cluster")
-	if o.clusterScopedRbac {
-		err = os.MkdirAll(clusterResourceDir, 0700)
-		switch {
-		case os.IsExist(err):
-		case err != nil:
-			log.Errorf("error creating the cluster resources directory: %#v", err)
-			return err
-		}
-	}
-	// create export directory if it doesnt exist
-	err = os.MkdirAll(filepath.Join(o.exportDir, "failures", o.userSpecifiedNamespace), 0700)
-	switch {
-	case os.IsExist(err):
-	case err != nil:
-		log.Errorf("error creating the failures directory: %#v", err)
-		return err
-	}
-
-	discoveryClient, err := o.configFlags.ToDiscoveryClient()
-	if err != nil {
-		log.Errorf("cannot create discovery client: %#v", err)
-		return err
-	}
-
-	// Always request fresh data from the server
-	discoveryClient.Invalidate()
-
-	restConfig, err := o.configFlags.ToRESTConfig()
-	if err != nil {
-		log.Errorf("cannot create rest config: %#v", err)
-		return err
-	}
-
-	// user/group impersonation is handled from genericclioptions.ConfigFlags
-	restConfig.Impersonate.Extra = o.extras
-	restConfig.Burst = o.Burst
-	restConfig.QPS = o.QPS
-
-	dynamicClient, err := dynamic.NewForConfig(restConfig)
-	if err != nil {
-		log.Errorf("cannot create dynamic client: %#v", err)
-		return err
-	}
-
-	features.NewFeatureFlagSet()
-	features.Enable(velerov1api.APIGroupVersionsFeatureFlag)
-
-	discoveryHelper, err := discovery.NewHelper(discoveryClient, log)
-	if err != nil {
-		log.Errorf("cannot create discovery helper: %#v", err)
-		return err
-	}
-
-	var errs []error
-
-	resources, resourceErrs := resourceToExtract(o.userSpecifiedNamespace, o.labelSelector, o.clusterScopedRbac, dynamicClient, discoveryHelper.Resources(), discoveryHelper.APIGroups(), log)
-	clusterScopeHandler := NewClusterScopeHandler()
-	if o.clusterScopedRbac {
-		resources = clusterScopeHandler.filterRbacResources(resources, log)
-	}
-
-	log.Debugf("attempting to write resources to files\n")
-	writeResourcesErrors := writeResources(resources, clusterResourceDir, resourceDir, log)
-	for _, e := range writeResourcesErrors {
-		log.Warnf("error writing manifests to file: %#v, ignoring\n", e)
-	}
-
-	writeErrorsErrors := writeErrors(resourceErrs, filepath.Join(o.exportDir, "failures", o.userSpecifiedNamespace), log)
-	for _, e := range writeErrorsErrors {
-		log.Warnf("error writing errors to file: %#v, ignoring\n", e)
-	}
-
-	errs = append(errs, writeResourcesErrors...)
-	errs = append(errs, writeErrorsErrors...)
-
-	return errorsutil.NewAggregate(errs)
-}
-
 func NewExportCommand(streams genericclioptions.IOStreams, f *flags.GlobalFlags) *cobra.Command {
-	o := &ExportOptions{
-		configFlags: genericclioptions.NewConfigFlags(true),
-
-		IOStreams:        streams,
-		cobraGlobalFlags: f,
-		globalFlags:      f,
-	}
+	o := exporter.NewExportOptions(streams, f)
 	cmd := &cobra.Command{
 		Use:   "export",
 		Short: "Export the namespace resources in an output directory",
+		Long: `Export the namespace resources in an output directory.
+
+Exit codes:
+  0    the export is clean
+  1    fatal error, like invalid flags or an unreachable cluster
+  2    some resources or objects could not be exported, see failures/<namespace>/` + exporter.FailuresFile + `
+       (0 with --ignore-failures)
+  4    --max-resources or --max-bytes was reached
+  5    --timeout was reached
+  130  the export was interrupted`,
 		RunE: func(c *cobra.Command, args []string) error {
 			if err := o.Complete(c, args); err != nil {
 				return err
@@ -187,6 +30,8 @@ func NewExportCommand(streams genericclioptions.IOStreams, f *flags.GlobalFlags)
 			if err := o.Validate(); err != nil {
 				return err
 			}
+			// the usage is only relevant to flag errors
+			c.SilenceUsage = true
 			if err := o.Run(); err != nil {
 				return err
 			}
@@ -195,19 +40,11 @@ func NewExportCommand(streams genericclioptions.IOStreams, f *flags.GlobalFlags)
 		},
 		PreRun: func(cmd *cobra.Command, args []string) {
 			viper.BindPFlags(cmd.Flags())
-			viper.Unmarshal(o.globalFlags)
-			viper.Unmarshal(o.configFlags)
-			viper.UnmarshalKey("export-dir", &o.exportDir)
+			o.UnmarshalConfig()
 		},
 	}
 
-	cmd.Flags().StringVarP(&o.exportDir, "export-dir", "e", "export", "The path where files are to be exported")
-	cmd.Flags().StringVarP(&o.labelSelector, "label-selector", "l", "", "Restrict export to resources matching a label selector")
-	cmd.Flags().BoolVarP(&o.clusterScopedRbac, "cluster-scoped-rbac", "c", false, "Include cluster-scoped RBAC resources")
-	cmd.Flags().StringVar(&o.asExtras, "as-extras", "", "The extra info for impersonation can only be used with User or Group but is not required. An example is --as-extras key=string1,string2;key2=string3")
-	cmd.Flags().Float32VarP(&o.QPS, "qps", "q", 100, "Query Per Second Rate.")
-	cmd.Flags().IntVarP(&o.Burst, "burst", "b", 1000, "API Burst Rate.")
-	o.configFlags.AddFlags(cmd.Flags())
+	o.RegisterFlags(cmd)
 
 	return cmd
 }
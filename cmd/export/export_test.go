@@ -0,0 +1,27 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func TestNewExportCommand_kubectlFlags(t *testing.T) {
+	cmd := NewExportCommand(genericclioptions.IOStreams{}, &flags.GlobalFlags{})
+	if f := cmd.Flags().ShorthandLookup("n"); f == nil || f.Name != "namespace" {
+		t.Errorf("-n is not the shorthand of --namespace: %v", f)
+	}
+	help := &bytes.Buffer{}
+	cmd.SetOut(help)
+	if err := cmd.Help(); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"-n, --namespace", "--request-timeout", "--insecure-skip-tls-verify", "--token", "--server", "--context", "--kubeconfig"} {
+		if !strings.Contains(help.String(), want) {
+			t.Errorf("the help of export does not list %s", want)
+		}
+	}
+}
@@ -0,0 +1,431 @@
+package images
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// Results of the images in image-copy-results.json
+const (
+	imageCopied     = "copied"
+	imageCopyFailed = "failed"
+)
+
+// ImageCopyFailureError is returned when some images could not be copied, the details are in
+// image-copy-results.json
+type ImageCopyFailureError struct {
+	Failures int
+}
+
+func (e *ImageCopyFailureError) Error() string {
+	return fmt.Sprintf("%d images not copied, see %s", e.Failures, exporter.ImageCopyResultsFile)
+}
+
+// imageCopyResult is the outcome of copying an image of images.json, recorded in
+// image-copy-results.json
+type imageCopyResult struct {
+	Image string `json:"image"`
+	// Source is the reference copied, pinned to the digest of the image when it has one
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	// Digest is the digest of the manifest or of the multi-arch index copied
+	Digest string `json:"digest,omitempty"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// imageCopy is the copy of an image of the inventory to the destination registry
+type imageCopy struct {
+	image       *exporter.Image
+	source      string
+	destination string
+}
+
+// newImageCopy returns the copy of the image under the destination registry. The repository path
+// and the tag are kept, and an image pinned to a digest is copied by digest so that the copy has
+// the same digest.
+func newImageCopy(img *exporter.Image, destRegistry string) imageCopy {
+	source := img.Registry + "/" + img.Repository
+	destination := strings.TrimSuffix(destRegistry, "/") + "/" + img.Repository
+	c := imageCopy{image: img}
+	switch {
+	case img.Digest != "":
+		c.source = source + "@" + img.Digest
+	default:
+		c.source = source + ":" + img.Tag
+	}
+	switch {
+	case img.Tag != "":
+		c.destination = destination + ":" + img.Tag
+	default:
+		c.destination = destination + "@" + img.Digest
+	}
+	return c
+}
+
+// copyFunc copies the image of the source reference to the destination one and returns the
+// digest of the copy
+type copyFunc func(ctx context.Context, source, destination string) (string, error)
+
+// registryCopy returns the copyFunc copying images between registries with the credentials of
+// the docker config, e.g. ~/.docker/config.json. The manifests are copied unchanged, with the
+// index and the manifests of every platform of the multi-arch images.
+func registryCopy(insecure bool) copyFunc {
+	return func(ctx context.Context, source, destination string) (string, error) {
+		options := []crane.Option{crane.WithContext(ctx), crane.WithAuthFromKeychain(authn.DefaultKeychain)}
+		if insecure {
+			options = append(options, crane.Insecure)
+		}
+		digest, err := crane.Digest(source, options...)
+		if err != nil {
+			return "", err
+		}
+		if err := crane.Copy(source, destination, options...); err != nil {
+			return "", err
+		}
+		copied, err := crane.Digest(destination, options...)
+		if err != nil {
+			return "", err
+		}
+		if copied != digest {
+			return copied, fmt.Errorf("the copy has digest %s, the source %s", copied, digest)
+		}
+		return copied, nil
+	}
+}
+
+// copyImages copies the images from at most workers goroutines and returns their results in the
+// order of the copies
+func copyImages(ctx context.Context, copies []imageCopy, workers int, copyImage copyFunc, log logrus.FieldLogger) []imageCopyResult {
+	results := make([]imageCopyResult, len(copies))
+	skipped := exporter.RunWorkers(ctx, workers, len(copies), func(i int) {
+		c := copies[i]
+		result := imageCopyResult{Image: c.image.Image, Source: c.source, Destination: c.destination, Result: imageCopied}
+		digest, err := copyImage(ctx, c.source, c.destination)
+		result.Digest = digest
+		if err != nil {
+			result.Result, result.Error = imageCopyFailed, err.Error()
+			log.Errorf("Cannot copy image %s to %s: %v", c.source, c.destination, err)
+		} else {
+			log.Infof("Copied image %s to %s", c.source, c.destination)
+		}
+		results[i] = result
+	})
+	for _, i := range skipped {
+		c := copies[i]
+		results[i] = imageCopyResult{Image: c.image.Image, Source: c.source, Destination: c.destination, Result: imageCopyFailed, Error: ctx.Err().Error()}
+	}
+	return results
+}
+
+// readImages reads the images.json inventory of the export
+func readImages(exportDir string) ([]*exporter.Image, error) {
+	imageBytes, err := os.ReadFile(filepath.Join(exportDir, exporter.ImagesFile))
+	if err != nil {
+		return nil, err
+	}
+	images := []*exporter.Image{}
+	if err := json.Unmarshal(imageBytes, &images); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", exporter.ImagesFile, err)
+	}
+	return images, nil
+}
+
+func writeImageCopyResults(exportDir string, results []imageCopyResult) error {
+	resultBytes, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(exportDir, exporter.ImageCopyResultsFile), append(resultBytes, '\n'), 0600)
+}
+
+func readImageCopyResults(exportDir string) ([]imageCopyResult, error) {
+	resultBytes, err := os.ReadFile(filepath.Join(exportDir, exporter.ImageCopyResultsFile))
+	if err != nil {
+		return nil, err
+	}
+	results := []imageCopyResult{}
+	if err := json.Unmarshal(resultBytes, &results); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", exporter.ImageCopyResultsFile, err)
+	}
+	return results, nil
+}
+
+// copiedImageMap returns the --image-map values repointing the copied images to their copies,
+// e.g. quay.io/app/web=registry.example.com/migrated/app/web. The copies keep the tags and the
+// digests, so mapping the repositories repoints every reference of an image.
+func copiedImageMap(results []imageCopyResult) []string {
+	mapped := map[string]bool{}
+	values := []string{}
+	for _, r := range results {
+		if r.Result != imageCopied {
+			continue
+		}
+		from, _ := exporter.SplitImage(r.Source)
+		to, _ := exporter.SplitImage(r.Destination)
+		if value := from + "=" + to; !mapped[value] {
+			mapped[value] = true
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func NewImagesCommand(streams genericclioptions.IOStreams, f *flags.GlobalFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "images",
+		Short: "Mirror the container images of an export to the target registry",
+		Long: `Mirror the container images of an export to the target registry.
+
+copy copies the images of the ` + exporter.ImagesFile + ` inventory of an export to a registry, and rewrite
+repoints the containers of the export to the copies.`,
+	}
+	cmd.AddCommand(newImagesCopyCommand(streams, f))
+	cmd.AddCommand(newImagesRewriteCommand(streams, f))
+	return cmd
+}
+
+type ImagesCopyOptions struct {
+	// Two GlobalFlags struct fields are needed
+	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
+	// 2. globalFlags for the args merged with values from the viper config file
+	cobraGlobalFlags *flags.GlobalFlags
+	globalFlags      *flags.GlobalFlags
+
+	exportDir    string
+	destRegistry string
+	dryRun       bool
+	insecure     bool
+	workers      int
+
+	genericclioptions.IOStreams
+}
+
+func (o *ImagesCopyOptions) Complete(c *cobra.Command, args []string) error {
+	o.destRegistry = strings.TrimSuffix(o.destRegistry, "/")
+	return nil
+}
+
+func (o *ImagesCopyOptions) Validate() error {
+	if o.destRegistry == "" {
+		return fmt.Errorf("--dest-registry is required")
+	}
+	if strings.ContainsAny(o.destRegistry, "@ ") || strings.Contains(o.destRegistry, "://") {
+		return fmt.Errorf("invalid --dest-registry %q, it must not have a scheme, a tag or a digest", o.destRegistry)
+	}
+	if _, err := name.NewRepository(o.destRegistry + "/image"); err != nil {
+		return fmt.Errorf("invalid --dest-registry %q: %w", o.destRegistry, err)
+	}
+	if o.workers < 1 {
+		return fmt.Errorf("--workers must be at least 1")
+	}
+	return nil
+}
+
+func (o *ImagesCopyOptions) Run() error {
+	return o.run(context.Background(), registryCopy(o.insecure), o.globalFlags.GetLogger())
+}
+
+func newImagesCopyCommand(streams genericclioptions.IOStreams, f *flags.GlobalFlags) *cobra.Command {
+	o := &ImagesCopyOptions{
+		IOStreams:        streams,
+		cobraGlobalFlags: f,
+		globalFlags:      f,
+	}
+	cmd := &cobra.Command{
+		Use:   "copy",
+		Short: "Copy the images of an export to the destination registry",
+		Long: `Copy the images of an export to the destination registry.
+
+Each image of the ` + exporter.ImagesFile + ` inventory is copied under --dest-registry with its repository
+path and tag, e.g. quay.io/app/web:v1 to registry.example.com/migrated/app/web:v1. The manifests
+are copied unchanged, so the copies have the digests of the source images, and the multi-arch
+images are copied with the manifests of every platform. The credentials of both registries are
+read from the docker config, e.g. ~/.docker/config.json as written by docker login.
+
+The result of each image is recorded in ` + exporter.ImageCopyResultsFile + ` at the root of the export
+directory, and images rewrite repoints the export to the copied images.
+
+Exit codes:
+  0    every image was copied
+  1    fatal error, like a missing inventory
+  2    some images could not be copied, see ` + exporter.ImageCopyResultsFile,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			c.SilenceUsage = true
+			if err := o.Run(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+			viper.Unmarshal(o.globalFlags)
+			viper.UnmarshalKey("export-dir", &o.exportDir)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.exportDir, "export-dir", "e", "export", "The export directory whose "+exporter.ImagesFile+" lists the images to copy")
+	cmd.Flags().StringVar(&o.destRegistry, "dest-registry", "", "The registry and optional repository path the images are copied under, e.g. registry.example.com/migrated")
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, "Print the copies without copying any image")
+	cmd.Flags().BoolVar(&o.insecure, "insecure", false, "Allow the registries to be reached over plain HTTP or with an untrusted certificate")
+	cmd.Flags().IntVar(&o.workers, "workers", 4, "The number of images copied in parallel")
+
+	return cmd
+}
+
+func (o *ImagesCopyOptions) run(ctx context.Context, copyImage copyFunc, log logrus.FieldLogger) error {
+	images, err := readImages(o.exportDir)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s has no image inventory, %s not found", o.exportDir, exporter.ImagesFile)
+	}
+	if err != nil {
+		return err
+	}
+	copies := make([]imageCopy, 0, len(images))
+	for _, img := range images {
+		copies = append(copies, newImageCopy(img, o.destRegistry))
+	}
+	if o.dryRun {
+		return printImageCopies(o.Out, copies)
+	}
+
+	results := copyImages(ctx, copies, o.workers, copyImage, log)
+	if err := writeImageCopyResults(o.exportDir, results); err != nil {
+		return fmt.Errorf("cannot write %s: %w", exporter.ImageCopyResultsFile, err)
+	}
+	failures := 0
+	for _, r := range results {
+		if r.Result == imageCopyFailed {
+			failures++
+		}
+	}
+	log.Infof("Copied %d images, %d failed, see %s", len(results)-failures, failures, filepath.Join(o.exportDir, exporter.ImageCopyResultsFile))
+	if failures > 0 {
+		return &ImageCopyFailureError{Failures: failures}
+	}
+	return nil
+}
+
+func printImageCopies(out io.Writer, copies []imageCopy) error {
+	for _, c := range copies {
+		if _, err := fmt.Fprintf(out, "%s -> %s\n", c.source, c.destination); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type ImagesRewriteOptions struct {
+	// Two GlobalFlags struct fields are needed
+	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
+	// 2. globalFlags for the args merged with values from the viper config file
+	cobraGlobalFlags *flags.GlobalFlags
+	globalFlags      *flags.GlobalFlags
+
+	rewrite *exporter.Rewrite
+	// imageMap are the --image-map values of the copied images
+	imageMap []string
+
+	genericclioptions.IOStreams
+}
+
+func (o *ImagesRewriteOptions) Complete(c *cobra.Command, args []string) error {
+	results, err := readImageCopyResults(o.rewrite.ExportDir)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s has no copied images, %s not found, run images copy first", o.rewrite.ExportDir, exporter.ImageCopyResultsFile)
+	}
+	if err != nil {
+		return err
+	}
+	// the mappings are set like the --image-map flag of rewrite, so that the summary records them
+	// the same way
+	o.imageMap = copiedImageMap(results)
+	for _, value := range o.imageMap {
+		if err := o.rewrite.SetFlag("image-map", value); err != nil {
+			return err
+		}
+	}
+	return o.rewrite.Complete()
+}
+
+func (o *ImagesRewriteOptions) Validate() error {
+	if len(o.imageMap) == 0 {
+		return fmt.Errorf("no image was copied, see %s", exporter.ImageCopyResultsFile)
+	}
+	return o.rewrite.Validate()
+}
+
+func (o *ImagesRewriteOptions) Run() error {
+	return o.rewrite.Run(context.Background(), o.globalFlags.GetLogger())
+}
+
+func newImagesRewriteCommand(streams genericclioptions.IOStreams, f *flags.GlobalFlags) *cobra.Command {
+	o := &ImagesRewriteOptions{
+		rewrite: exporter.NewRewrite(),
+
+		IOStreams:        streams,
+		cobraGlobalFlags: f,
+		globalFlags:      f,
+	}
+	cmd := &cobra.Command{
+		Use:   "rewrite",
+		Short: "Repoint the containers of an export to the images copied by images copy",
+		Long: `Repoint the containers of an export to the images copied by images copy.
+
+The images recorded as copied in ` + exporter.ImageCopyResultsFile + ` are rewritten to their copies, like
+the rewrite command does with an --image-map per copied repository, and the rewritten export is
+written to --output-dir. The export in --export-dir is left unchanged. The images that were not
+copied are left unchanged and reported in ` + exporter.ImageRewritesFile + `.
+
+Exit codes:
+  0    the export was rewritten
+  1    fatal error, like an invalid export`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			c.SilenceUsage = true
+			if err := o.Run(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+			viper.Unmarshal(o.globalFlags)
+			viper.UnmarshalKey("export-dir", &o.rewrite.ExportDir)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.rewrite.ExportDir, "export-dir", "e", "export", "The export directory whose images were copied. It is left unchanged")
+	cmd.Flags().StringVar(&o.rewrite.OutputDir, "output-dir", "", "The directory the rewritten export is written to")
+	cmd.Flags().BoolVar(&o.rewrite.Overwrite, "overwrite", false, "Replace a previous export found in the output directory. Only the content written by export is removed")
+
+	return cmd
+}
@@ -0,0 +1,290 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter/exportertest"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func testLogger() logrus.FieldLogger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func writeTestImages(t *testing.T, exportDir string, refs ...string) {
+	t.Helper()
+	images := []*exporter.Image{}
+	for _, ref := range refs {
+		images = append(images, exporter.ParseImage(ref))
+	}
+	imageBytes, err := json.Marshal(images)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(exportDir, exporter.ImagesFile), imageBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_newImageCopy(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	tests := []struct {
+		name            string
+		ref             string
+		wantSource      string
+		wantDestination string
+	}{
+		{
+			name:            "given a tag, should copy the tag",
+			ref:             "quay.io/acme/web:1.0",
+			wantSource:      "quay.io/acme/web:1.0",
+			wantDestination: "registry.example.com/migrated/acme/web:1.0",
+		},
+		{
+			name:            "given a docker hub image without tag, should copy the latest tag of the library repository",
+			ref:             "nginx",
+			wantSource:      "docker.io/library/nginx:latest",
+			wantDestination: "registry.example.com/migrated/library/nginx:latest",
+		},
+		{
+			name:            "given a digest, should copy by digest",
+			ref:             "quay.io/acme/web@" + digest,
+			wantSource:      "quay.io/acme/web@" + digest,
+			wantDestination: "registry.example.com/migrated/acme/web@" + digest,
+		},
+		{
+			name:            "given a tag and a digest, should copy the digest to the tag",
+			ref:             "localhost:5000/acme/web:1.0@" + digest,
+			wantSource:      "localhost:5000/acme/web@" + digest,
+			wantDestination: "registry.example.com/migrated/acme/web:1.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newImageCopy(exporter.ParseImage(tt.ref), "registry.example.com/migrated")
+			if got.source != tt.wantSource || got.destination != tt.wantDestination {
+				t.Errorf("newImageCopy() = %s -> %s, want %s -> %s", got.source, got.destination, tt.wantSource, tt.wantDestination)
+			}
+		})
+	}
+}
+
+func TestImagesCopyOptions_run(t *testing.T) {
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	// a multi-arch image by tag and a single image by digest
+	multiArch, err := random.Index(256, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	multiArchRef, err := name.ParseReference(host + "/acme/web:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(multiArchRef, multiArch); err != nil {
+		t.Fatal(err)
+	}
+	single, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	singleRef, err := name.ParseReference(host + "/acme/db:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(singleRef, single); err != nil {
+		t.Fatal(err)
+	}
+	multiArchDigest, _ := multiArch.Digest()
+	singleDigest, _ := single.Digest()
+
+	exportDir := t.TempDir()
+	writeTestImages(t, exportDir, host+"/acme/web:1.0", host+"/acme/db@"+singleDigest.String(), host+"/acme/missing:1.0")
+	o := &ImagesCopyOptions{exportDir: exportDir, destRegistry: host + "/migrated", workers: 2}
+	err = o.run(context.Background(), registryCopy(false), testLogger())
+	var failed *ImageCopyFailureError
+	if !errors.As(err, &failed) || failed.Failures != 1 {
+		t.Fatalf("run() error = %v, want the missing image failed", err)
+	}
+
+	results, err := readImageCopyResults(exportDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []imageCopyResult{
+		{Image: host + "/acme/web:1.0", Source: host + "/acme/web:1.0", Destination: host + "/migrated/acme/web:1.0", Digest: multiArchDigest.String(), Result: imageCopied},
+		{Image: host + "/acme/db@" + singleDigest.String(), Source: host + "/acme/db@" + singleDigest.String(), Destination: host + "/migrated/acme/db@" + singleDigest.String(), Digest: singleDigest.String(), Result: imageCopied},
+	}
+	if len(results) != 3 || !reflect.DeepEqual(results[:2], want) {
+		t.Errorf("results = %+v, want %+v", results, want)
+	}
+	if len(results) == 3 && (results[2].Result != imageCopyFailed || results[2].Error == "") {
+		t.Errorf("result of the missing image = %+v, want it failed", results[2])
+	}
+	manifest, err := crane.Manifest(host + "/migrated/acme/web:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(manifest), `"manifests"`) {
+		t.Errorf("copied manifest = %s, want the multi-arch index", manifest)
+	}
+}
+
+func TestImagesCopyOptions_run_dryRun(t *testing.T) {
+	exportDir := t.TempDir()
+	writeTestImages(t, exportDir, "quay.io/acme/web:1.0", "nginx")
+	out := &bytes.Buffer{}
+	o := &ImagesCopyOptions{exportDir: exportDir, destRegistry: "registry.example.com/migrated", dryRun: true, workers: 1}
+	o.Out = out
+	copyImage := func(ctx context.Context, source, destination string) (string, error) {
+		t.Errorf("dry run copied %s", source)
+		return "", nil
+	}
+	if err := o.run(context.Background(), copyImage, testLogger()); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	want := "quay.io/acme/web:1.0 -> registry.example.com/migrated/acme/web:1.0\ndocker.io/library/nginx:latest -> registry.example.com/migrated/library/nginx:latest\n"
+	if out.String() != want {
+		t.Errorf("dry run output = %q, want %q", out.String(), want)
+	}
+	if _, err := os.Stat(filepath.Join(exportDir, exporter.ImageCopyResultsFile)); !os.IsNotExist(err) {
+		t.Errorf("dry run wrote %s", exporter.ImageCopyResultsFile)
+	}
+}
+
+func TestImagesRewriteOptions(t *testing.T) {
+	dir := t.TempDir()
+	exportDir, outputDir := filepath.Join(dir, "export"), filepath.Join(dir, "mirrored")
+	deployment := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "foo"},
+		"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"name": "web", "image": "quay.io/acme/web:1.0"}},
+		}}},
+	}}
+	exportertest.WriteExport(t, exportDir, exporter.LayoutFlat, false, deployment)
+	exportertest.WriteSummary(t, exportDir, map[string]string{"namespace": "[" + exportertest.Namespace + "]"})
+	results := []imageCopyResult{
+		{Image: "quay.io/acme/web:1.0", Source: "quay.io/acme/web:1.0", Destination: "registry.example.com/migrated/acme/web:1.0", Result: imageCopied},
+		{Image: "quay.io/acme/db:2.0", Source: "quay.io/acme/db:2.0", Destination: "registry.example.com/migrated/acme/db:2.0", Result: imageCopyFailed, Error: "unauthorized"},
+	}
+	if err := writeImageCopyResults(exportDir, results); err != nil {
+		t.Fatal(err)
+	}
+
+	o := &ImagesRewriteOptions{rewrite: exporter.NewRewrite()}
+	o.rewrite.ExportDir, o.rewrite.OutputDir = exportDir, outputDir
+	if err := o.Complete(nil, nil); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if want := []string{"quay.io/acme/web=registry.example.com/migrated/acme/web"}; !reflect.DeepEqual(o.imageMap, want) {
+		t.Errorf("image map = %v, want %v", o.imageMap, want)
+	}
+	if err := o.rewrite.Run(context.Background(), testLogger()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	entries, err := index.Read(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Kind != "Deployment" {
+			continue
+		}
+		objects, err := exporter.ReadManifestFile(filepath.Join(outputDir, filepath.FromSlash(entry.Path)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		containers, _, _ := unstructured.NestedSlice(objects[0].Object, "spec", "template", "spec", "containers")
+		if got := containers[0].(map[string]interface{})["image"]; got != "registry.example.com/migrated/acme/web:1.0" {
+			t.Errorf("rewritten image = %v, want the copy", got)
+		}
+		return
+	}
+	t.Errorf("no Deployment in the rewritten export %+v", entries)
+}
+
+func TestImagesRewriteOptions_noCopy(t *testing.T) {
+	o := &ImagesRewriteOptions{rewrite: exporter.NewRewrite()}
+	o.rewrite.ExportDir, o.rewrite.OutputDir = t.TempDir(), t.TempDir()
+	if err := o.Complete(nil, nil); err == nil {
+		t.Errorf("Complete() without %s, want error", exporter.ImageCopyResultsFile)
+	}
+	if err := writeImageCopyResults(o.rewrite.ExportDir, []imageCopyResult{{Image: "nginx", Result: imageCopyFailed}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Complete(nil, nil); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err == nil {
+		t.Errorf("Validate() without a copied image, want error")
+	}
+}
+
+func TestImagesCopyOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		o       *ImagesCopyOptions
+		wantErr bool
+	}{
+		{
+			name: "given a registry and a repository path, should pass",
+			o:    &ImagesCopyOptions{destRegistry: "registry.example.com:5000/migrated", workers: 1},
+		},
+		{
+			name:    "given no registry, should fail",
+			o:       &ImagesCopyOptions{workers: 1},
+			wantErr: true,
+		},
+		{
+			name:    "given a registry with a scheme, should fail",
+			o:       &ImagesCopyOptions{destRegistry: "https://registry.example.com", workers: 1},
+			wantErr: true,
+		},
+		{
+			name:    "given an invalid repository path, should fail",
+			o:       &ImagesCopyOptions{destRegistry: "registry.example.com/Migrated", workers: 1},
+			wantErr: true,
+		},
+		{
+			name:    "given no worker, should fail",
+			o:       &ImagesCopyOptions{destRegistry: "registry.example.com"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.o.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package permissions
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/completion"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/preflight"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/discovery"
+	"github.com/vmware-tanzu/velero/pkg/features"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// exportRoleName is the name of the Role and ClusterRole generated by permissions
+const exportRoleName = "kubectl-migrate-export"
+
+// PermissionsDeniedError is returned by permissions when some of the permissions export needs are
+// denied
+type PermissionsDeniedError struct {
+	Denied int
+}
+
+func (e *PermissionsDeniedError) Error() string {
+	return fmt.Sprintf("%d permissions needed by export are denied", e.Denied)
+}
+
+type PermissionsOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+
+	// Two GlobalFlags struct fields are needed
+	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
+	// 2. globalFlags for the args merged with values from the viper config file
+	cobraGlobalFlags *flags.GlobalFlags
+	globalFlags      *flags.GlobalFlags
+
+	namespace         string
+	includeResources  []string
+	excludeResources  []string
+	resourceFilter    *exporter.ResourceFilter
+	clusterScopedRbac bool
+	includeCRDs       bool
+	clusterDeps       bool
+	webhooks          bool
+	events            bool
+
+	genericclioptions.IOStreams
+}
+
+func (o *PermissionsOptions) Complete(c *cobra.Command, args []string) error {
+	namespace, _, err := o.configFlags.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return err
+	}
+	o.namespace = namespace
+
+	if o.resourceFilter, err = exporter.NewResourceFilter(o.includeResources, o.excludeResources); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (o *PermissionsOptions) Validate() error {
+	return nil
+}
+
+func (o *PermissionsOptions) Run() error {
+	return o.run(context.Background(), o.globalFlags.GetLogger())
+}
+
+func NewPermissionsCommand(streams genericclioptions.IOStreams, f *flags.GlobalFlags) *cobra.Command {
+	o := &PermissionsOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+
+		IOStreams:        streams,
+		cobraGlobalFlags: f,
+		globalFlags:      f,
+	}
+	cmd := &cobra.Command{
+		Use:   "permissions",
+		Short: "Report the permissions export needs in a namespace of the source cluster",
+		Long: `Report the permissions export needs in a namespace of the source cluster.
+
+The resources an export of the namespace would list are discovered like export does, with the
+same resource and cluster-scoped flags, and the permission of the current user to get and list
+each of them is reviewed with SelfSubjectAccessReviews. The cluster-scoped resources read by the
+flags are reviewed too, like the Namespace itself or the CustomResourceDefinitions of
+--include-crds. Nothing is listed.
+
+The permissions are printed as a table, followed by the Role, and the ClusterRole when
+cluster-scoped resources are read, granting exactly what the export needs.
+
+Exit codes:
+  0    every permission is allowed
+  1    fatal error, like an unreachable cluster
+  3    some permissions are denied`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			c.SilenceUsage = true
+			if err := o.Run(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+			viper.Unmarshal(o.globalFlags)
+			viper.Unmarshal(o.configFlags)
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&o.includeResources, "include-resources", nil, "The resources the export would include, as resource or resource.group, see export")
+	cmd.Flags().StringSliceVar(&o.excludeResources, "exclude-resources", nil, "The resources the export would skip, as resource or resource.group, see export")
+	cmd.Flags().BoolVarP(&o.clusterScopedRbac, "cluster-scoped-rbac", "c", false, "Review the permissions of an export with --cluster-scoped-rbac")
+	cmd.Flags().BoolVar(&o.includeCRDs, "include-crds", false, "Review the permissions of an export with --include-crds")
+	cmd.Flags().BoolVar(&o.clusterDeps, "include-cluster-deps", false, "Review the permissions of an export with --include-cluster-deps")
+	cmd.Flags().BoolVar(&o.webhooks, "include-webhooks", false, "Review the permissions of an export with --include-webhooks")
+	cmd.Flags().BoolVar(&o.events, "include-events", false, "Review the permissions of an export with --include-events")
+	o.configFlags.AddFlags(cmd.Flags())
+	cmd.RegisterFlagCompletionFunc("namespace", completion.Namespaces(o.configFlags))
+	cmd.RegisterFlagCompletionFunc("include-resources", completion.Resources(o.configFlags))
+	cmd.RegisterFlagCompletionFunc("exclude-resources", completion.Resources(o.configFlags))
+
+	return cmd
+}
+
+func (o *PermissionsOptions) run(ctx context.Context, log logrus.FieldLogger) error {
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return fmt.Errorf("cannot create rest config: %w", err)
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("cannot create client: %w", err)
+	}
+	if _, err := exporter.CheckDiscovery(client.Discovery(), false, log); err != nil {
+		return fmt.Errorf("cannot discover the server resources: %w", err)
+	}
+	// the resources are discovered in every version like export does, and listed in the preferred one
+	features.NewFeatureFlagSet()
+	features.Enable(velerov1api.APIGroupVersionsFeatureFlag)
+	discoveryHelper, err := discovery.NewHelper(client.Discovery(), log)
+	if err != nil {
+		return fmt.Errorf("cannot create discovery helper: %w", err)
+	}
+	if err := o.resourceFilter.Validate(discoveryHelper.Resources()); err != nil {
+		return fmt.Errorf("invalid resource filter: %w", err)
+	}
+
+	permissions := o.requiredPermissions(discoveryHelper.Resources(), discoveryHelper.APIGroups(), log)
+	if err := preflight.ReviewPermissions(ctx, client, permissions); err != nil {
+		return fmt.Errorf("cannot review the permissions: %w", err)
+	}
+	return printPermissions(o.Out, o.namespace, permissions)
+}
+
+// requiredPermissions returns the permissions an export of the namespace with the flags needs: get
+// and list on the resources it lists, the cluster-scoped ones too with --cluster-scoped-rbac, and
+// the cluster-scoped reads of the other flags
+func (o *PermissionsOptions) requiredPermissions(lists []*metav1.APIResourceList, apiGroups []metav1.APIGroup, log logrus.FieldLogger) []preflight.Permission {
+	permissions := []preflight.Permission{}
+	add := func(gvr schema.GroupVersionResource, namespace string, name string, verbs ...string) {
+		for _, verb := range verbs {
+			permissions = append(permissions, preflight.Permission{Resource: gvr, Namespace: namespace, Name: name, Verb: verb})
+		}
+	}
+
+	// the permissions are the same in every version of a resource
+	for _, r := range exporter.ResourcesToList(o.clusterScopedRbac, false, o.resourceFilter, lists, apiGroups, log) {
+		gvr := schema.GroupVersionResource{Group: r.APIGroup, Version: r.APIVersion, Resource: r.APIResource.Name}
+		namespace := o.namespace
+		if !r.APIResource.Namespaced {
+			namespace = ""
+		}
+		// the objects that cannot be decoded from a list are read one by one
+		add(gvr, namespace, "", "get", "list")
+	}
+	if o.events {
+		for _, gvr := range exporter.EventGVRs {
+			if exporter.ServesResource(lists, gvr) {
+				add(gvr, o.namespace, "", "list")
+			}
+		}
+	}
+
+	// the Namespace manifest is written with its labels and annotations
+	add(exporter.NamespacesGVR, "", o.namespace, "get")
+	if o.includeCRDs || o.clusterDeps {
+		add(exporter.CRDGVR, "", "", "get")
+	}
+	if o.clusterDeps {
+		for _, gvr := range []schema.GroupVersionResource{exporter.PriorityClassesGVR, exporter.RuntimeClassesGVR, exporter.StorageClassesGVR, exporter.IngressClassesGVR} {
+			if exporter.ServesResource(lists, gvr) {
+				add(gvr, "", "", "get")
+			}
+		}
+	}
+	if o.webhooks {
+		for _, resource := range exporter.WebhookConfigurationResources {
+			add(schema.GroupVersionResource{Group: resource.Group, Version: resource.Version, Resource: resource.Name}, "", "", "list")
+		}
+	}
+	return permissions
+}
+
+// exportRoles returns the Role granting the namespaced permissions and, when some permissions are
+// cluster-scoped, the ClusterRole granting them, named after the namespace
+func exportRoles(namespace string, permissions []preflight.Permission) (*rbacv1.Role, *rbacv1.ClusterRole) {
+	namespaced := []preflight.Permission{}
+	clusterScoped := []preflight.Permission{}
+	for _, p := range permissions {
+		if p.Namespace == "" {
+			clusterScoped = append(clusterScoped, p)
+		} else {
+			namespaced = append(namespaced, p)
+		}
+	}
+	role := &rbacv1.Role{
+		TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+		ObjectMeta: metav1.ObjectMeta{Name: exportRoleName, Namespace: namespace},
+		Rules:      preflight.PolicyRules(namespaced),
+	}
+	if len(clusterScoped) == 0 {
+		return role, nil
+	}
+	clusterRole := &rbacv1.ClusterRole{
+		TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+		ObjectMeta: metav1.ObjectMeta{Name: exportRoleName + "-" + namespace},
+		Rules:      preflight.PolicyRules(clusterScoped),
+	}
+	return role, clusterRole
+}
+
+// printPermissions writes the reviewed permissions as a table followed by the roles granting them
+func printPermissions(out io.Writer, namespace string, permissions []preflight.Permission) error {
+	preflight.PrintPermissions(out, permissions)
+	role, clusterRole := exportRoles(namespace, permissions)
+	manifests := []interface{}{role}
+	if clusterRole != nil {
+		manifests = append(manifests, clusterRole)
+	}
+	for _, manifest := range manifests {
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(manifest)
+		if err != nil {
+			return err
+		}
+		// the roles are yet to be created
+		unstructured.RemoveNestedField(obj, "metadata", "creationTimestamp")
+		manifestBytes, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(out, "---\n%s", manifestBytes); err != nil {
+			return err
+		}
+	}
+	denied := 0
+	for _, p := range permissions {
+		if !p.Allowed {
+			denied++
+		}
+	}
+	if denied > 0 {
+		return &PermissionsDeniedError{Denied: denied}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package permissions
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/preflight"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func testLogger() logrus.FieldLogger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func Test_requiredPermissions(t *testing.T) {
+	list := metav1.Verbs{"list", "get"}
+	lists := []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "configmaps", Namespaced: true, Kind: "ConfigMap", Verbs: list},
+			{Name: "events", Namespaced: true, Kind: "Event", Verbs: list},
+			{Name: "nodes", Kind: "Node", Verbs: list},
+		}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{{Name: "deployments", Namespaced: true, Kind: "Deployment", Verbs: list}}},
+		{GroupVersion: "apps/v1beta1", APIResources: []metav1.APIResource{{Name: "deployments", Namespaced: true, Kind: "Deployment", Verbs: list}}},
+		{GroupVersion: "rbac.authorization.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "clusterroles", Kind: "ClusterRole", Verbs: list}}},
+		{GroupVersion: "scheduling.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "priorityclasses", Kind: "PriorityClass", Verbs: list}}},
+	}
+	groups := []metav1.APIGroup{{Name: "apps", PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "apps/v1", Version: "v1"}}}
+	o := &PermissionsOptions{namespace: "foo", resourceFilter: &exporter.ResourceFilter{}, clusterScopedRbac: true, includeCRDs: true, clusterDeps: true}
+
+	got := []string{}
+	for _, p := range o.requiredPermissions(lists, groups, testLogger()) {
+		got = append(got, p.Namespace+" "+p.Verb+" "+p.Resource.String()+" "+p.Name)
+	}
+	want := []string{
+		"foo get /v1, Resource=configmaps ",
+		"foo list /v1, Resource=configmaps ",
+		"foo get apps/v1, Resource=deployments ",
+		"foo list apps/v1, Resource=deployments ",
+		" get rbac.authorization.k8s.io/v1, Resource=clusterroles ",
+		" list rbac.authorization.k8s.io/v1, Resource=clusterroles ",
+		" get /v1, Resource=namespaces foo",
+		" get apiextensions.k8s.io/v1, Resource=customresourcedefinitions ",
+		" get scheduling.k8s.io/v1, Resource=priorityclasses ",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("requiredPermissions() = %q, want %q", got, want)
+	}
+}
+
+func Test_printPermissions(t *testing.T) {
+	configMaps := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	permissions := []preflight.Permission{
+		{Resource: configMaps, Namespace: "foo", Verb: "get", Allowed: true},
+		{Resource: configMaps, Namespace: "foo", Verb: "list", Allowed: true},
+	}
+	out := &bytes.Buffer{}
+	if err := printPermissions(out, "foo", permissions); err != nil {
+		t.Fatalf("printPermissions() = %v, want no error when every permission is allowed", err)
+	}
+	if !strings.Contains(out.String(), "kind: Role\nmetadata:\n  name: kubectl-migrate-export\n  namespace: foo\n") || strings.Contains(out.String(), "ClusterRole") {
+		t.Errorf("printPermissions() = %s, want a Role only", out.String())
+	}
+
+	permissions = append(permissions, preflight.Permission{Resource: exporter.NamespacesGVR, Name: "foo", Verb: "get"})
+	out.Reset()
+	var denied *PermissionsDeniedError
+	if err := printPermissions(out, "foo", permissions); !errors.As(err, &denied) || denied.Denied != 1 {
+		t.Errorf("printPermissions() = %v, want 1 permission denied", err)
+	}
+	for _, want := range []string{"DENIED", "kind: ClusterRole\nmetadata:\n  name: kubectl-migrate-export-foo\n", "  resourceNames:\n  - foo\n  resources:\n  - namespaces\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("printPermissions() = %s, want %q", out.String(), want)
+		}
+	}
+}
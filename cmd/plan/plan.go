@@ -0,0 +1,432 @@
+package plan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/preflight"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	k8sdiscovery "k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
+)
+
+var pvcResource = schema.GroupVersionResource{Version: "v1", Resource: "persistentvolumeclaims"}
+
+// PlanFailedError is returned by plan when some checks failed, the target cluster cannot take the
+// namespaces as they are
+type PlanFailedError struct {
+	Failures int
+}
+
+func (e *PlanFailedError) Error() string {
+	return fmt.Sprintf("%d plan checks failed, see the plan file", e.Failures)
+}
+
+// migrationPlan is the readiness report of plan, written as plan.json
+type migrationPlan struct {
+	SourceContext string           `json:"sourceContext,omitempty"`
+	TargetContext string           `json:"targetContext,omitempty"`
+	SourceVersion string           `json:"sourceVersion,omitempty"`
+	TargetVersion string           `json:"targetVersion,omitempty"`
+	Namespaces    []*namespacePlan `json:"namespaces"`
+	// DataBytes is the capacity of the PersistentVolumeClaims of every namespace
+	DataBytes int64              `json:"dataBytes"`
+	Checks    []preflight.Result `json:"checks"`
+}
+
+// namespacePlan is what would be migrated from a source namespace
+type namespacePlan struct {
+	Namespace string `json:"namespace"`
+	// Objects counts the objects an export would write by apiVersion and kind, e.g. apps/v1 Deployment
+	Objects map[string]int `json:"objects"`
+	// TargetExists is set when the namespace already exists on the target cluster, Collisions lists
+	// the objects found there with the kind and name of a source object as Kind/name
+	TargetExists bool                        `json:"targetExists"`
+	Collisions   []string                    `json:"collisions,omitempty"`
+	Volumes      []volumePlan                `json:"volumes,omitempty"`
+	DataBytes    int64                       `json:"dataBytes"`
+	Deprecated   []exporter.DeprecatedObject `json:"deprecatedAPIs,omitempty"`
+}
+
+// volumePlan is a PersistentVolumeClaim whose data is to migrate
+type volumePlan struct {
+	Name         string `json:"name"`
+	StorageClass string `json:"storageClass,omitempty"`
+	// Capacity is the status.capacity of the claim, empty while it is not bound
+	Capacity string `json:"capacity,omitempty"`
+	Bytes    int64  `json:"bytes"`
+}
+
+type PlanOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+
+	// Two GlobalFlags struct fields are needed
+	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
+	// 2. globalFlags for the args merged with values from the viper config file
+	cobraGlobalFlags *flags.GlobalFlags
+	globalFlags      *flags.GlobalFlags
+
+	namespaces    []string
+	sourceContext string
+	targetContext string
+	planFile      string
+
+	genericclioptions.IOStreams
+}
+
+func (o *PlanOptions) Complete(c *cobra.Command, args []string) error {
+	o.namespaces = exporter.UniqueNamespaces(o.namespaces)
+	return nil
+}
+
+func (o *PlanOptions) Validate() error {
+	if len(o.namespaces) == 0 {
+		return fmt.Errorf("at least one --namespace is required")
+	}
+	if o.targetContext == "" {
+		return fmt.Errorf("--target-context is required")
+	}
+	if o.sourceContext == o.targetContext {
+		return fmt.Errorf("--source-context and --target-context must be different contexts")
+	}
+	return nil
+}
+
+func (o *PlanOptions) Run() error {
+	return o.run(context.Background(), o.globalFlags.GetLogger())
+}
+
+func NewPlanCommand(streams genericclioptions.IOStreams, f *flags.GlobalFlags) *cobra.Command {
+	o := &PlanOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+
+		IOStreams:        streams,
+		cobraGlobalFlags: f,
+		globalFlags:      f,
+	}
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Report whether namespaces of the source cluster can be migrated to the target cluster",
+		Long: `Report whether namespaces of the source cluster can be migrated to the target cluster.
+
+The objects an export would write are listed from the source cluster, metadata only, and nothing
+is written but the plan file. On the target cluster, the namespaces that already exist and their
+objects with the kind and name of a source object are reported as collisions, the apiVersion and
+kind of the source objects are checked against the API served, and the API versions deprecated
+or removed in the target Kubernetes version are reported. The data to migrate is estimated from
+the status.capacity of the PersistentVolumeClaims. Neither cluster is modified.
+
+The checks are printed as a table and the plan is written to --plan-file.
+
+Exit codes:
+  0    the namespaces can be migrated, maybe with warnings
+  1    fatal error, like an unreachable cluster
+  3    some checks failed`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			c.SilenceUsage = true
+			if err := o.Run(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+			viper.Unmarshal(o.globalFlags)
+			viper.Unmarshal(o.configFlags)
+		},
+	}
+
+	cmd.Flags().StringSliceVarP(&o.namespaces, "namespace", "n", nil, "The source namespace to plan the migration of. Can be repeated or comma-separated")
+	cmd.Flags().StringVar(&o.sourceContext, "source-context", "", "The kubeconfig context of the source cluster, defaults to the current context")
+	cmd.Flags().StringVar(&o.targetContext, "target-context", "", "The kubeconfig context of the target cluster")
+	cmd.Flags().StringVar(&o.planFile, "plan-file", "plan.json", "The file the plan is written to")
+	// the namespace flag is registered above so it can take several values, and the contexts
+	// replace the context flag
+	o.configFlags.Namespace = nil
+	o.configFlags.Context = nil
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func (o *PlanOptions) run(ctx context.Context, log logrus.FieldLogger) error {
+	sourceConfig, err := exporter.ContextConfig(o.configFlags, o.sourceContext)
+	if err != nil {
+		return fmt.Errorf("cannot create the source cluster config: %w", err)
+	}
+	targetConfig, err := exporter.ContextConfig(o.configFlags, o.targetContext)
+	if err != nil {
+		return fmt.Errorf("cannot create the target cluster config: %w", err)
+	}
+
+	plan := &migrationPlan{SourceContext: o.sourceContext, TargetContext: o.targetContext, Checks: []preflight.Result{}}
+	p := &planner{log: log}
+	sourceDiscovery, err := k8sdiscovery.NewDiscoveryClientForConfig(sourceConfig)
+	if err != nil {
+		return fmt.Errorf("cannot create the source discovery client: %w", err)
+	}
+	if info, err := sourceDiscovery.ServerVersion(); err != nil {
+		log.Warnf("cannot get the source server version: %v", err)
+	} else {
+		plan.SourceVersion = info.GitVersion
+	}
+	p.sourceResources, err = sourceDiscovery.ServerPreferredNamespacedResources()
+	switch {
+	case k8sdiscovery.IsGroupDiscoveryFailedError(err):
+		plan.Checks = append(plan.Checks, preflight.Result{Check: "source API discovery", Status: preflight.StatusWarn, Detail: err.Error()})
+	case err != nil:
+		return fmt.Errorf("cannot discover the source cluster resources: %w", err)
+	}
+	if p.source, err = metadata.NewForConfig(sourceConfig); err != nil {
+		return fmt.Errorf("cannot create the source metadata client: %w", err)
+	}
+	if p.sourceDynamic, err = dynamic.NewForConfig(sourceConfig); err != nil {
+		return fmt.Errorf("cannot create the source dynamic client: %w", err)
+	}
+
+	targetDiscovery, err := k8sdiscovery.NewDiscoveryClientForConfig(targetConfig)
+	if err != nil {
+		return fmt.Errorf("cannot create the target discovery client: %w", err)
+	}
+	if info, err := targetDiscovery.ServerVersion(); err != nil {
+		log.Warnf("cannot get the target server version, every deprecated API version is reported: %v", err)
+	} else {
+		plan.TargetVersion = info.GitVersion
+		if p.targetMinor, err = exporter.ParseMinorVersion(info.GitVersion); err != nil {
+			log.Warnf("cannot parse the target server version, every deprecated API version is reported: %v", err)
+		}
+	}
+	_, targetResources, err := targetDiscovery.ServerGroupsAndResources()
+	switch {
+	case k8sdiscovery.IsGroupDiscoveryFailedError(err):
+		plan.Checks = append(plan.Checks, preflight.Result{Check: "target API discovery", Status: preflight.StatusWarn, Detail: err.Error()})
+	case err != nil:
+		return fmt.Errorf("cannot discover the target cluster resources: %w", err)
+	}
+	p.targetAPI = exporter.NewServedAPI(targetResources)
+	if p.target, err = metadata.NewForConfig(targetConfig); err != nil {
+		return fmt.Errorf("cannot create the target metadata client: %w", err)
+	}
+
+	p.plan(ctx, o.namespaces, plan)
+	preflight.Print(o.Out, plan.Checks)
+	fmt.Fprintf(o.Out, "\nData to migrate: %s in %d namespaces\n", exporter.FormatBytes(int(plan.DataBytes)), len(plan.Namespaces))
+	if err := writePlan(o.planFile, plan); err != nil {
+		return fmt.Errorf("cannot write %s: %w", o.planFile, err)
+	}
+	failures := 0
+	for _, r := range plan.Checks {
+		if r.Status == preflight.StatusFail {
+			failures++
+		}
+	}
+	if failures > 0 {
+		return &PlanFailedError{Failures: failures}
+	}
+	return nil
+}
+
+// planner lists the source namespaces and checks them against the target cluster, it only reads
+// from both clusters
+type planner struct {
+	source          metadata.Interface
+	sourceDynamic   dynamic.Interface
+	sourceResources []*metav1.APIResourceList
+	target          metadata.Interface
+	targetAPI       *exporter.ServedAPI
+	// targetMinor is the Kubernetes minor version of the target cluster, 0 when unknown
+	targetMinor int
+	log         logrus.FieldLogger
+}
+
+// plan adds the plan of each namespace and its checks to the plan
+func (p *planner) plan(ctx context.Context, namespaces []string, plan *migrationPlan) {
+	objects := []unstructured.Unstructured{}
+	for _, namespace := range namespaces {
+		np := &namespacePlan{Namespace: namespace, Objects: map[string]int{}}
+		listed, failures := p.listSource(ctx, namespace)
+		plan.Checks = append(plan.Checks, failures...)
+		for _, obj := range listed {
+			np.Objects[obj.GetAPIVersion()+" "+obj.GetKind()]++
+		}
+		objects = append(objects, listed...)
+
+		plan.Checks = append(plan.Checks, p.checkTargetNamespace(ctx, np, listed))
+		np.Deprecated = exporter.DeprecatedObjects(listed, p.targetMinor)
+		if len(np.Deprecated) > 0 {
+			plan.Checks = append(plan.Checks, deprecationResult(namespace, np.Deprecated))
+		}
+		plan.Checks = append(plan.Checks, p.estimateData(ctx, np))
+		plan.DataBytes += np.DataBytes
+		plan.Namespaces = append(plan.Namespaces, np)
+	}
+	plan.Checks = append(plan.Checks, p.targetAPI.Check(objects)...)
+}
+
+// listSource lists the metadata of the objects of the namespace an export would write: the
+// objects of the preferred versions, but the Events and the objects controlled by another one.
+// The resources that cannot be listed are returned as warnings.
+func (p *planner) listSource(ctx context.Context, namespace string) ([]unstructured.Unstructured, []preflight.Result) {
+	objects := []unstructured.Unstructured{}
+	failures := []preflight.Result{}
+	for _, list := range p.sourceResources {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, r := range list.APIResources {
+			if !r.Namespaced || r.Kind == "Event" || strings.Contains(r.Name, "/") || !slices.Contains(r.Verbs, "list") {
+				continue
+			}
+			items, err := p.source.Resource(gv.WithResource(r.Name)).Namespace(namespace).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				p.log.Warnf("cannot list %s in namespace %s: %v", r.Name, namespace, err)
+				failures = append(failures, preflight.Result{Check: "list " + exporter.GroupResourceName(gv.Group, r.Name) + " in " + namespace, Status: preflight.StatusWarn, Detail: err.Error()})
+				continue
+			}
+			for _, item := range items.Items {
+				if metav1.GetControllerOfNoCopy(&item) != nil {
+					continue
+				}
+				obj := unstructured.Unstructured{}
+				obj.SetAPIVersion(list.GroupVersion)
+				obj.SetKind(r.Kind)
+				obj.SetNamespace(item.Namespace)
+				obj.SetName(item.Name)
+				objects = append(objects, obj)
+			}
+		}
+	}
+	return objects, failures
+}
+
+// checkTargetNamespace looks for the namespace on the target cluster and, when it exists, for the
+// objects with the kind and name of the source ones
+func (p *planner) checkTargetNamespace(ctx context.Context, np *namespacePlan, objects []unstructured.Unstructured) preflight.Result {
+	result := preflight.Result{Check: "namespace " + np.Namespace}
+	_, err := p.target.Resource(exporter.NamespacesGVR).Get(ctx, np.Namespace, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		result.Status, result.Detail = preflight.StatusPass, "missing on the target, it is created"
+		return result
+	case err != nil:
+		result.Status, result.Detail = preflight.StatusWarn, "cannot be checked on the target: "+err.Error()
+		return result
+	}
+	np.TargetExists = true
+
+	names := map[schema.GroupVersionKind][]string{}
+	for _, obj := range objects {
+		names[obj.GroupVersionKind()] = append(names[obj.GroupVersionKind()], obj.GetName())
+	}
+	gvks := make([]schema.GroupVersionKind, 0, len(names))
+	for gvk := range names {
+		gvks = append(gvks, gvk)
+	}
+	exporter.SortGVKs(gvks)
+	for _, gvk := range gvks {
+		served, ok := p.targetAPI.Resource(gvk)
+		if !ok {
+			continue
+		}
+		existing, err := p.target.Resource(gvk.GroupVersion().WithResource(served.Name)).Namespace(np.Namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			p.log.Warnf("cannot list %s in namespace %s of the target: %v", served.Name, np.Namespace, err)
+			continue
+		}
+		found := map[string]bool{}
+		for _, item := range existing.Items {
+			found[item.Name] = true
+		}
+		for _, name := range names[gvk] {
+			if found[name] {
+				np.Collisions = append(np.Collisions, gvk.Kind+"/"+name)
+			}
+		}
+	}
+	if len(np.Collisions) > 0 {
+		result.Status, result.Detail = preflight.StatusWarn, fmt.Sprintf("exists on the target with %d objects of the same kind and name, e.g. %s", len(np.Collisions), np.Collisions[0])
+	} else {
+		result.Status, result.Detail = preflight.StatusPass, "exists on the target, no object collides"
+	}
+	return result
+}
+
+// deprecationResult summarizes the objects of a namespace stored at deprecated API versions, the
+// removed ones failing
+func deprecationResult(namespace string, deprecated []exporter.DeprecatedObject) preflight.Result {
+	result := preflight.Result{Check: "deprecated APIs in " + namespace, Status: preflight.StatusWarn}
+	removed := 0
+	for _, d := range deprecated {
+		if d.Removed {
+			removed++
+		}
+	}
+	if removed > 0 {
+		result.Status = preflight.StatusFail
+	}
+	result.Detail = fmt.Sprintf("%d objects at deprecated API versions, %d removed on the target, e.g. %s", len(deprecated), removed, deprecated[0])
+	return result
+}
+
+// estimateData sums the capacity of the PersistentVolumeClaims of the namespace
+func (p *planner) estimateData(ctx context.Context, np *namespacePlan) preflight.Result {
+	result := preflight.Result{Check: "data in " + np.Namespace}
+	claims, err := p.sourceDynamic.Resource(pvcResource).Namespace(np.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		result.Status, result.Detail = preflight.StatusWarn, "cannot list the PersistentVolumeClaims: "+err.Error()
+		return result
+	}
+	unbound := 0
+	for _, claim := range claims.Items {
+		v := volumePlan{Name: claim.GetName()}
+		v.StorageClass, _, _ = unstructured.NestedString(claim.Object, "spec", "storageClassName")
+		v.Capacity, _, _ = unstructured.NestedString(claim.Object, "status", "capacity", "storage")
+		if q, err := resource.ParseQuantity(v.Capacity); err == nil {
+			v.Bytes = q.Value()
+		} else {
+			unbound++
+		}
+		np.DataBytes += v.Bytes
+		np.Volumes = append(np.Volumes, v)
+	}
+	sort.Slice(np.Volumes, func(i, j int) bool { return np.Volumes[i].Name < np.Volumes[j].Name })
+	result.Status, result.Detail = preflight.StatusPass, fmt.Sprintf("%s in %d PersistentVolumeClaims", exporter.FormatBytes(int(np.DataBytes)), len(np.Volumes))
+	if unbound > 0 {
+		result.Status = preflight.StatusWarn
+		result.Detail += fmt.Sprintf(", %d without a capacity are not counted", unbound)
+	}
+	return result
+}
+
+// writePlan writes the plan as JSON
+func writePlan(path string, plan *migrationPlan) error {
+	planBytes, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(planBytes, '\n'), 0600)
+}
@@ -0,0 +1,177 @@
+package plan
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/preflight"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	metadatafake "k8s.io/client-go/metadata/fake"
+)
+
+func testLogger() logrus.FieldLogger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func testReplayObject(apiVersion, kind, namespace, name string) unstructured.Unstructured {
+	obj := unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+func testServedAPI() *exporter.ServedAPI {
+	return exporter.NewServedAPI([]*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "namespaces", Kind: "Namespace"},
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true},
+			{Name: "persistentvolumeclaims", Kind: "PersistentVolumeClaim", Namespaced: true},
+		}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "deployments", Kind: "Deployment", Namespaced: true},
+			{Name: "deployments/scale", Kind: "Scale", Namespaced: true},
+		}},
+		{GroupVersion: "networking.k8s.io/v1", APIResources: []metav1.APIResource{
+			{Name: "ingresses", Kind: "Ingress", Namespaced: true},
+		}},
+		{GroupVersion: "apiextensions.k8s.io/v1", APIResources: []metav1.APIResource{
+			{Name: "customresourcedefinitions", Kind: "CustomResourceDefinition"},
+		}},
+	})
+}
+
+func testPlanMeta(apiVersion, kind, namespace, name string, owners ...metav1.OwnerReference) runtime.Object {
+	return &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: apiVersion, Kind: kind},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, OwnerReferences: owners},
+	}
+}
+
+func testPlanClaim(name string, capacity string) *unstructured.Unstructured {
+	claim := testReplayObject("v1", "PersistentVolumeClaim", "foo", name)
+	claim.Object["spec"] = map[string]interface{}{"storageClassName": "gp2"}
+	if capacity != "" {
+		claim.Object["status"] = map[string]interface{}{"capacity": map[string]interface{}{"storage": capacity}}
+	}
+	return &claim
+}
+
+func Test_planner_plan(t *testing.T) {
+	scheme := metadatafake.NewTestScheme()
+	metav1.AddMetaToScheme(scheme)
+	controller := true
+	source := metadatafake.NewSimpleMetadataClient(scheme,
+		testPlanMeta("v1", "ConfigMap", "foo", "web-config"),
+		testPlanMeta("apps/v1", "Deployment", "foo", "web"),
+		testPlanMeta("apps/v1", "ReplicaSet", "foo", "web-abc12", metav1.OwnerReference{Kind: "Deployment", Name: "web", Controller: &controller}),
+		testPlanMeta("batch/v1beta1", "CronJob", "foo", "report"),
+		testPlanMeta("v1", "ConfigMap", "bar", "settings"),
+	)
+	target := metadatafake.NewSimpleMetadataClient(scheme,
+		testPlanMeta("v1", "Namespace", "", "foo"),
+		testPlanMeta("v1", "ConfigMap", "foo", "web-config"),
+		testPlanMeta("v1", "ConfigMap", "foo", "other"),
+	)
+	claims := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), testPlanClaim("data", "10Gi"), testPlanClaim("pending", ""))
+
+	p := &planner{
+		source:        source,
+		sourceDynamic: claims,
+		sourceResources: []*metav1.APIResourceList{
+			{GroupVersion: "v1", APIResources: []metav1.APIResource{
+				{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: []string{"list"}},
+				{Name: "events", Kind: "Event", Namespaced: true, Verbs: []string{"list"}},
+			}},
+			{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+				{Name: "deployments", Kind: "Deployment", Namespaced: true, Verbs: []string{"list"}},
+				{Name: "deployments/scale", Kind: "Scale", Namespaced: true, Verbs: []string{"get"}},
+				{Name: "replicasets", Kind: "ReplicaSet", Namespaced: true, Verbs: []string{"list"}},
+			}},
+			{GroupVersion: "batch/v1beta1", APIResources: []metav1.APIResource{
+				{Name: "cronjobs", Kind: "CronJob", Namespaced: true, Verbs: []string{"list"}},
+			}},
+		},
+		target:      target,
+		targetAPI:   testServedAPI(),
+		targetMinor: 29,
+		log:         testLogger(),
+	}
+	plan := &migrationPlan{Checks: []preflight.Result{}}
+	p.plan(context.Background(), []string{"foo", "bar"}, plan)
+
+	want := []preflight.Result{
+		{Check: "namespace foo", Status: preflight.StatusWarn, Detail: "exists on the target with 1 objects of the same kind and name, e.g. ConfigMap/web-config"},
+		{Check: "deprecated APIs in foo", Status: preflight.StatusFail, Detail: "1 objects at deprecated API versions, 1 removed on the target, e.g. " + plan.Namespaces[0].Deprecated[0].String()},
+		{Check: "data in foo", Status: preflight.StatusWarn, Detail: "10.0 GiB in 2 PersistentVolumeClaims, 1 without a capacity are not counted"},
+		{Check: "namespace bar", Status: preflight.StatusPass, Detail: "missing on the target, it is created"},
+		{Check: "data in bar", Status: preflight.StatusPass, Detail: "0 B in 0 PersistentVolumeClaims"},
+		{Check: "API apps/v1 Deployment", Status: preflight.StatusPass, Detail: "served, 1 objects"},
+		{Check: "API batch/v1beta1 CronJob", Status: preflight.StatusFail, Detail: "1 objects, not served by the target"},
+		{Check: "API v1 ConfigMap", Status: preflight.StatusPass, Detail: "served, 2 objects"},
+	}
+	if !reflect.DeepEqual(plan.Checks, want) {
+		t.Errorf("plan checks = %+v, want %+v", plan.Checks, want)
+	}
+
+	foo := plan.Namespaces[0]
+	wantObjects := map[string]int{"v1 ConfigMap": 1, "apps/v1 Deployment": 1, "batch/v1beta1 CronJob": 1}
+	if !reflect.DeepEqual(foo.Objects, wantObjects) {
+		t.Errorf("foo objects = %v, want %v", foo.Objects, wantObjects)
+	}
+	if !foo.TargetExists || !reflect.DeepEqual(foo.Collisions, []string{"ConfigMap/web-config"}) {
+		t.Errorf("foo target = %v %v, want it existing with ConfigMap/web-config colliding", foo.TargetExists, foo.Collisions)
+	}
+	wantVolumes := []volumePlan{{Name: "data", StorageClass: "gp2", Capacity: "10Gi", Bytes: 10 << 30}, {Name: "pending", StorageClass: "gp2"}}
+	if !reflect.DeepEqual(foo.Volumes, wantVolumes) {
+		t.Errorf("foo volumes = %+v, want %+v", foo.Volumes, wantVolumes)
+	}
+	if plan.DataBytes != 10<<30 {
+		t.Errorf("plan data = %d, want %d", plan.DataBytes, int64(10<<30))
+	}
+}
+
+func TestPlanOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		o       *PlanOptions
+		wantErr bool
+	}{
+		{
+			name: "given a namespace and two contexts, should pass",
+			o:    &PlanOptions{namespaces: []string{"foo"}, sourceContext: "a", targetContext: "b"},
+		},
+		{
+			name:    "given no namespace, should fail",
+			o:       &PlanOptions{sourceContext: "a", targetContext: "b"},
+			wantErr: true,
+		},
+		{
+			name:    "given no target context, should fail",
+			o:       &PlanOptions{namespaces: []string{"foo"}, sourceContext: "a"},
+			wantErr: true,
+		},
+		{
+			name:    "given the same context twice, should fail",
+			o:       &PlanOptions{namespaces: []string{"foo"}, sourceContext: "a", targetContext: "a"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.o.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
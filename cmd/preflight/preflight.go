@@ -0,0 +1,108 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/completion"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/preflight"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes"
+)
+
+type Options struct {
+	configFlags *genericclioptions.ConfigFlags
+
+	// Two GlobalFlags struct fields are needed
+	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
+	// 2. globalFlags for the args merged with values from the viper config file
+	cobraGlobalFlags *flags.GlobalFlags
+	globalFlags      *flags.GlobalFlags
+
+	exportDir  string
+	namespaces []string
+
+	genericclioptions.IOStreams
+}
+
+func (o *Options) Complete(c *cobra.Command, args []string) error {
+	if len(o.namespaces) == 0 {
+		namespace, _, err := o.configFlags.ToRawKubeConfigLoader().Namespace()
+		if err != nil {
+			return err
+		}
+		o.namespaces = []string{namespace}
+	}
+	return nil
+}
+
+func (o *Options) Validate() error {
+	return nil
+}
+
+func (o *Options) Run() error {
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	results := preflight.Run(context.Background(), client, o.namespaces, o.exportDir)
+	preflight.Print(o.Out, results)
+	if preflight.Failed(results) {
+		return fmt.Errorf("preflight checks failed")
+	}
+	return nil
+}
+
+func NewPreflightCommand(streams genericclioptions.IOStreams, f *flags.GlobalFlags) *cobra.Command {
+	o := &Options{
+		configFlags: genericclioptions.NewConfigFlags(true),
+
+		IOStreams:        streams,
+		cobraGlobalFlags: f,
+		globalFlags:      f,
+	}
+	cmd := &cobra.Command{
+		Use:   "preflight",
+		Short: "Check that an export can run before starting it",
+		Long: `Check that an export can run before starting it: the cluster is reachable, the namespaces
+exist, their resources can be listed and the export directory is writable with enough free space.
+The checks are printed as a table and the command exits with a non-zero code when one fails.
+Export runs the same checks unless --skip-preflight is set.`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			c.SilenceUsage = true
+			if err := o.Run(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+			viper.Unmarshal(o.globalFlags)
+			viper.Unmarshal(o.configFlags)
+			viper.UnmarshalKey("export-dir", &o.exportDir)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.exportDir, "export-dir", "e", "export", "The path where files are to be exported")
+	cmd.Flags().StringSliceVarP(&o.namespaces, "namespace", "n", nil, "The namespace to check, defaults to the namespace of the current context. Can be repeated or comma-separated")
+	// the namespace flag is registered above so it can take several values
+	o.configFlags.Namespace = nil
+	o.configFlags.AddFlags(cmd.Flags())
+	cmd.RegisterFlagCompletionFunc("namespace", completion.Namespaces(o.configFlags))
+
+	return cmd
+}
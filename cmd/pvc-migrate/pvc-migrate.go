@@ -0,0 +1,373 @@
+package pvc_migrate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	transfer_pvc "github.com/konveyor-ecosystem/kubectl-migrate/cmd/transfer-pvc"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/encryption"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Results of the PersistentVolumeClaims in pvc-migrate-results.json
+const (
+	pvcMigrated = "migrated"
+	pvcFailed   = "failed"
+)
+
+// PVCMigrateFailureError is returned when the data of some PersistentVolumeClaims could not be
+// migrated, the details are in pvc-migrate-results.json
+type PVCMigrateFailureError struct {
+	Failures int
+}
+
+func (e *PVCMigrateFailureError) Error() string {
+	return fmt.Sprintf("%d PersistentVolumeClaims not migrated, see %s", e.Failures, exporter.PVCMigrateResultsFile)
+}
+
+// pvcResult is the outcome of migrating the data of a PersistentVolumeClaim, recorded in
+// pvc-migrate-results.json
+type pvcResult struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// StorageClass is the storage class of the claim created on the target cluster
+	StorageClass string `json:"storageClass,omitempty"`
+	Result       string `json:"result"`
+	Attempts     int    `json:"attempts"`
+	// TransferredFiles is the number of files sent by the last attempt, Source and Destination
+	// count the files of both volumes once the data is sent
+	TransferredFiles int64                     `json:"transferredFiles"`
+	Source           *transfer_pvc.VolumeStats `json:"source,omitempty"`
+	Destination      *transfer_pvc.VolumeStats `json:"destination,omitempty"`
+	Error            string                    `json:"error,omitempty"`
+}
+
+type PVCMigrateOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+
+	// Two GlobalFlags struct fields are needed
+	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
+	// 2. globalFlags for the args merged with values from the viper config file
+	cobraGlobalFlags *flags.GlobalFlags
+	globalFlags      *flags.GlobalFlags
+
+	exportDir        string
+	namespaces       []string
+	sourceContext    string
+	targetContext    string
+	pvcs             []string
+	storageClassMap  []string
+	storageClasses   exporter.StorageClassMap
+	retries          int
+	endpoint         transfer_pvc.EndpointFlags
+	sourceImage      string
+	destinationImage string
+	checksum         bool
+
+	genericclioptions.IOStreams
+}
+
+func (o *PVCMigrateOptions) Complete(c *cobra.Command, args []string) error {
+	o.namespaces = exporter.UniqueNamespaces(o.namespaces)
+	var err error
+	o.storageClasses, err = exporter.ParseStorageClassMap(o.storageClassMap)
+	return err
+}
+
+func (o *PVCMigrateOptions) Validate() error {
+	if len(o.namespaces) == 0 {
+		return fmt.Errorf("at least one --namespace is required")
+	}
+	if len(o.pvcs) > 0 && len(o.namespaces) > 1 {
+		return fmt.Errorf("--pvc requires a single --namespace")
+	}
+	if o.targetContext == "" {
+		return fmt.Errorf("--target-context is required")
+	}
+	if o.sourceContext == o.targetContext {
+		return fmt.Errorf("--source-context and --target-context must be different contexts")
+	}
+	if o.retries < 0 {
+		return fmt.Errorf("--retries must not be negative")
+	}
+	return o.endpoint.Validate()
+}
+
+func (o *PVCMigrateOptions) Run() error {
+	return o.run(context.Background(), o.globalFlags.GetLogger())
+}
+
+func NewPVCMigrateCommand(streams genericclioptions.IOStreams, f *flags.GlobalFlags) *cobra.Command {
+	o := &PVCMigrateOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+
+		IOStreams:        streams,
+		cobraGlobalFlags: f,
+		globalFlags:      f,
+	}
+	cmd := &cobra.Command{
+		Use:   "pvc-migrate",
+		Short: "Copy the data of the exported PersistentVolumeClaims to the target cluster",
+		Long: `Copy the data of the exported PersistentVolumeClaims to the target cluster.
+
+For each PersistentVolumeClaim of the namespace found in the export, or given with --pvc, a claim
+with the same name is created on the target cluster, its storage class rewritten with
+--storageclass-map, and the data is sent with rsync like transfer-pvc does: an rsync daemon pod
+receives it in the target namespace through an endpoint, and an rsync client pod sends it from the
+source namespace. The progress of each claim is printed, and the files and bytes of both volumes
+are counted once the data is sent.
+
+A failed transfer is retried --retries times. rsync only sends the files missing or changed on the
+target, so a retry or a later run resumes the transfer. The pods, secrets and endpoint of each
+transfer are deleted when it completes or fails. The result of each claim is recorded in
+` + exporter.PVCMigrateResultsFile + ` at the root of the export directory.
+
+Exit codes:
+  0    the data of every claim was migrated
+  1    fatal error, like an unreachable cluster
+  2    some claims could not be migrated, see ` + exporter.PVCMigrateResultsFile,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			c.SilenceUsage = true
+			if err := o.Run(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+			viper.Unmarshal(o.globalFlags)
+			viper.Unmarshal(o.configFlags)
+			viper.UnmarshalKey("export-dir", &o.exportDir)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.exportDir, "export-dir", "e", "export", "The export directory listing the PersistentVolumeClaims to migrate")
+	cmd.Flags().StringSliceVarP(&o.namespaces, "namespace", "n", nil, "The source namespace to migrate the PersistentVolumeClaims of. Can be repeated or comma-separated")
+	cmd.Flags().StringVar(&o.sourceContext, "source-context", "", "The kubeconfig context of the source cluster, defaults to the current context")
+	cmd.Flags().StringVar(&o.targetContext, "target-context", "", "The kubeconfig context of the target cluster")
+	cmd.Flags().StringSliceVar(&o.pvcs, "pvc", nil, "The PersistentVolumeClaim to migrate instead of the exported ones. Can be repeated or comma-separated")
+	cmd.Flags().StringArrayVar(&o.storageClassMap, "storageclass-map", nil, "Rewrite the storage class of the claims created on the target cluster, e.g. gp2=standard-rwo. *=target maps the classes without a mapping of their own. Can be repeated")
+	cmd.Flags().IntVar(&o.retries, "retries", 2, "How many times to retry the transfer of a PersistentVolumeClaim that failed")
+	cmd.Flags().Var(&o.endpoint.Type, "endpoint", "The type of networking endpoint to use to accept traffic in the target cluster. Must be `nginx-ingress` or `route`.")
+	cmd.Flags().StringVar(&o.endpoint.Subdomain, "subdomain", "", "Subdomain to use for the ingress endpoint")
+	cmd.Flags().StringVar(&o.endpoint.IngressClass, "ingress-class", "", "IngressClass to use for the ingress endpoint")
+	cmd.Flags().StringVar(&o.sourceImage, "source-image", "", "The container image to use on the source cluster. Defaults to quay.io/konveyor/esync-transfer:latest")
+	cmd.Flags().StringVar(&o.destinationImage, "destination-image", "", "The container image to use on the target cluster. Defaults to quay.io/konveyor/rsync-transfer:latest")
+	cmd.Flags().BoolVar(&o.checksum, "checksum", false, "Compare the files by checksum rather than by size and modification time")
+	// the namespace flag is registered above so it can take several values, and the contexts
+	// replace the context flag
+	o.configFlags.Namespace = nil
+	o.configFlags.Context = nil
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func (o *PVCMigrateOptions) run(ctx context.Context, log logrus.FieldLogger) error {
+	sourceConfig, err := exporter.ContextConfig(o.configFlags, o.sourceContext)
+	if err != nil {
+		return fmt.Errorf("cannot create the source cluster config: %w", err)
+	}
+	targetConfig, err := exporter.ContextConfig(o.configFlags, o.targetContext)
+	if err != nil {
+		return fmt.Errorf("cannot create the target cluster config: %w", err)
+	}
+	source, err := kubernetes.NewForConfig(sourceConfig)
+	if err != nil {
+		return fmt.Errorf("cannot create the source cluster client: %w", err)
+	}
+
+	m := &pvcMigrator{
+		source: source,
+		template: transfer_pvc.Transfer{
+			SourceConfig:      sourceConfig,
+			DestinationConfig: targetConfig,
+			Endpoint:          o.endpoint,
+			SourceImage:       o.sourceImage,
+			DestinationImage:  o.destinationImage,
+			Verify:            o.checksum,
+			CompareVolumes:    true,
+		},
+		classes: o.storageClasses,
+		retries: o.retries,
+		run:     (*transfer_pvc.Transfer).Run,
+		log:     log,
+	}
+
+	results := []pvcResult{}
+	failures := 0
+	for _, namespace := range o.namespaces {
+		names := o.pvcs
+		if len(names) == 0 {
+			names, err = exportedClaims(o.exportDir, namespace)
+			if err != nil {
+				return fmt.Errorf("cannot read the PersistentVolumeClaims of %s from the export: %w", namespace, err)
+			}
+		}
+		if len(names) == 0 {
+			log.Infof("No PersistentVolumeClaim to migrate in namespace %s", namespace)
+			continue
+		}
+		for _, name := range names {
+			log.Infof("Migrating the data of PersistentVolumeClaim %s/%s", namespace, name)
+			result := m.migrate(ctx, namespace, name)
+			if result.Result == pvcFailed {
+				failures++
+				log.Errorf("Cannot migrate PersistentVolumeClaim %s/%s: %s", namespace, name, result.Error)
+			}
+			results = append(results, result)
+			// written after each claim, so an interrupted run still records the claims migrated
+			if err := writePVCResults(o.exportDir, results); err != nil {
+				return fmt.Errorf("cannot write %s: %w", exporter.PVCMigrateResultsFile, err)
+			}
+		}
+	}
+
+	log.Infof("Migrated %d PersistentVolumeClaims, %d failed, see %s", len(results)-failures, failures, filepath.Join(o.exportDir, exporter.PVCMigrateResultsFile))
+	if failures > 0 {
+		return &PVCMigrateFailureError{Failures: failures}
+	}
+	return nil
+}
+
+// exportedClaims returns the names of the PersistentVolumeClaims exported with the namespace
+func exportedClaims(exportDir, namespace string) ([]string, error) {
+	entries, err := index.Read(exportDir)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		// the files of a single object tell its kind in the index, and only Secrets are encrypted
+		if !exporter.IsReplayedFile(entry.Path, namespace) || strings.HasSuffix(entry.Path, encryption.Extension) ||
+			(entry.Kind != "" && entry.Kind != "PersistentVolumeClaim") {
+			continue
+		}
+		items, err := exporter.ReadManifestFile(filepath.Join(exportDir, filepath.FromSlash(entry.Path)))
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range items {
+			if obj.GetKind() == "PersistentVolumeClaim" && !slices.Contains(names, obj.GetName()) {
+				names = append(names, obj.GetName())
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// destinationClaim returns the claim to create on the target cluster for the source claim, with
+// the storage class of --storageclass-map. The claim is bound to a new volume.
+func destinationClaim(source *corev1.PersistentVolumeClaim, classes exporter.StorageClassMap) *corev1.PersistentVolumeClaim {
+	claim := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      source.Name,
+			Namespace: source.Namespace,
+			Labels:    source.Labels,
+		},
+		Spec: *source.Spec.DeepCopy(),
+	}
+	claim.Spec.VolumeName = ""
+	claim.Spec.Selector = nil
+	claim.Spec.DataSource = nil
+	claim.Spec.DataSourceRef = nil
+	class := ""
+	if claim.Spec.StorageClassName != nil {
+		class = *claim.Spec.StorageClassName
+	}
+	// an empty storageClassName asks for no class at all, to bind a PersistentVolume without one
+	if claim.Spec.StorageClassName == nil || class != "" {
+		if target, ok := classes.Target(class); ok {
+			claim.Spec.StorageClassName = &target
+		}
+	}
+	return claim
+}
+
+// pvcMigrator migrates the data of the PersistentVolumeClaims one at a time, so that the progress
+// of each transfer can be printed
+type pvcMigrator struct {
+	source kubernetes.Interface
+	// template holds the clusters and options of the transfers
+	template transfer_pvc.Transfer
+	classes  exporter.StorageClassMap
+	retries  int
+	run      func(*transfer_pvc.Transfer, context.Context) (*transfer_pvc.Progress, error)
+	log      logrus.FieldLogger
+}
+
+// migrate transfers the data of a claim of the source cluster, retrying the failed transfers
+func (m *pvcMigrator) migrate(ctx context.Context, namespace, name string) pvcResult {
+	result := pvcResult{Namespace: namespace, Name: name, Result: pvcFailed}
+	claim, err := m.source.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if claim.Status.Phase != corev1.ClaimBound {
+		result.Error = fmt.Sprintf("the claim is %s, not bound to a volume", claim.Status.Phase)
+		return result
+	}
+
+	t := m.template
+	t.Source = claim
+	t.Destination = destinationClaim(claim, m.classes)
+	if t.Destination.Spec.StorageClassName != nil {
+		result.StorageClass = *t.Destination.Spec.StorageClassName
+	}
+	for attempt := 1; attempt <= m.retries+1; attempt++ {
+		result.Attempts = attempt
+		progress, err := m.run(&t, ctx)
+		if progress != nil {
+			result.TransferredFiles = progress.TransferredFiles
+			result.Source, result.Destination = progress.Source, progress.Destination
+		}
+		if err == nil {
+			result.Result, result.Error = pvcMigrated, ""
+			return result
+		}
+		result.Error = err.Error()
+		if ctx.Err() != nil {
+			break
+		}
+		if attempt <= m.retries {
+			m.log.Warnf("Transfer of PersistentVolumeClaim %s/%s failed, retrying: %v", namespace, name, err)
+		}
+	}
+	return result
+}
+
+// writePVCResults writes pvc-migrate-results.json at the root of the export directory
+func writePVCResults(exportDir string, results []pvcResult) error {
+	if err := os.MkdirAll(exportDir, 0700); err != nil {
+		return err
+	}
+	resultBytes, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(exportDir, exporter.PVCMigrateResultsFile), append(resultBytes, '\n'), 0600)
+}
@@ -0,0 +1,212 @@
+package pvc_migrate
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	transfer_pvc "github.com/konveyor-ecosystem/kubectl-migrate/cmd/transfer-pvc"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter/exportertest"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testLogger() logrus.FieldLogger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func testReplayObject(apiVersion, kind, namespace, name string) unstructured.Unstructured {
+	obj := unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+func Test_exportedClaims(t *testing.T) {
+	for _, layout := range []string{exporter.LayoutFlat, exporter.LayoutSingle} {
+		t.Run(layout, func(t *testing.T) {
+			exportDir := t.TempDir()
+			exportertest.WriteExport(t, exportDir, layout, false,
+				testReplayObject("v1", "PersistentVolumeClaim", "foo", "logs"),
+				testReplayObject("apps/v1", "Deployment", "foo", "web"),
+				testReplayObject("v1", "PersistentVolumeClaim", "foo", "data"),
+			)
+			got, err := exportedClaims(exportDir, "foo")
+			if err != nil {
+				t.Fatalf("exportedClaims() error = %v", err)
+			}
+			if want := []string{"data", "logs"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("exportedClaims() = %v, want %v", got, want)
+			}
+			if got, err := exportedClaims(exportDir, "bar"); err != nil || len(got) != 0 {
+				t.Errorf("exportedClaims() of another namespace = %v, %v, want none", got, err)
+			}
+		})
+	}
+}
+
+func Test_destinationClaim(t *testing.T) {
+	class := func(name string) *string { return &name }
+	tests := []struct {
+		name      string
+		class     *string
+		classes   exporter.StorageClassMap
+		wantClass *string
+	}{
+		{
+			name:      "given no mapping, should keep the storage class",
+			class:     class("gp2"),
+			wantClass: class("gp2"),
+		},
+		{
+			name:      "given a mapping of the class, should rewrite it",
+			class:     class("gp2"),
+			classes:   exporter.StorageClassMap{"gp2": "standard-rwo"},
+			wantClass: class("standard-rwo"),
+		},
+		{
+			name:      "given a wildcard mapping and the default class, should set the class",
+			classes:   exporter.StorageClassMap{"*": "standard-rwo"},
+			wantClass: class("standard-rwo"),
+		},
+		{
+			name:      "given an empty class, should keep it",
+			class:     class(""),
+			classes:   exporter.StorageClassMap{"*": "standard-rwo"},
+			wantClass: class(""),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "foo", Labels: map[string]string{"app": "db"}, ResourceVersion: "42", UID: "abc"},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					StorageClassName: tt.class,
+					VolumeName:       "pvc-abc",
+					Selector:         &metav1.LabelSelector{MatchLabels: map[string]string{"disk": "a"}},
+					AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				},
+			}
+			got := destinationClaim(source, tt.classes)
+			if !reflect.DeepEqual(got.Spec.StorageClassName, tt.wantClass) {
+				t.Errorf("destinationClaim() storage class = %v, want %v", got.Spec.StorageClassName, tt.wantClass)
+			}
+			if got.Name != "data" || got.Namespace != "foo" || got.ResourceVersion != "" || got.UID != "" || got.Labels["app"] != "db" {
+				t.Errorf("destinationClaim() metadata = %+v, want the name, namespace and labels only", got.ObjectMeta)
+			}
+			if got.Spec.VolumeName != "" || got.Spec.Selector != nil || len(got.Spec.AccessModes) != 1 {
+				t.Errorf("destinationClaim() spec = %+v, want it unbound with the access modes", got.Spec)
+			}
+			if source.Spec.VolumeName != "pvc-abc" {
+				t.Errorf("destinationClaim() modified the source claim")
+			}
+		})
+	}
+}
+
+func Test_pvcMigrator_migrate(t *testing.T) {
+	claim := func(name string, phase corev1.PersistentVolumeClaimPhase) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "foo"}, Status: corev1.PersistentVolumeClaimStatus{Phase: phase}}
+	}
+	source := fake.NewSimpleClientset(claim("data", corev1.ClaimBound), claim("pending", corev1.ClaimPending))
+	tests := []struct {
+		name         string
+		claim        string
+		failures     int
+		wantResult   string
+		wantAttempts int
+	}{
+		{name: "given a transfer succeeding, should migrate the claim", claim: "data", wantResult: pvcMigrated, wantAttempts: 1},
+		{name: "given a transfer failing once, should retry it", claim: "data", failures: 1, wantResult: pvcMigrated, wantAttempts: 2},
+		{name: "given a transfer failing every time, should fail after the retries", claim: "data", failures: 5, wantResult: pvcFailed, wantAttempts: 3},
+		{name: "given a claim not bound, should fail without transfer", claim: "pending", wantResult: pvcFailed},
+		{name: "given a missing claim, should fail without transfer", claim: "missing", wantResult: pvcFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs := 0
+			stats := &transfer_pvc.VolumeStats{Files: 3, Bytes: 1024}
+			m := &pvcMigrator{
+				source:  source,
+				classes: exporter.StorageClassMap{"*": "standard-rwo"},
+				retries: 2,
+				run: func(tr *transfer_pvc.Transfer, ctx context.Context) (*transfer_pvc.Progress, error) {
+					runs++
+					if tr.Source.Name != tt.claim || *tr.Destination.Spec.StorageClassName != "standard-rwo" {
+						t.Errorf("transfer of %s to %+v, want %s to the standard-rwo class", tr.Source.Name, tr.Destination.Spec, tt.claim)
+					}
+					if runs <= tt.failures {
+						return nil, errors.New("rsync transfer failed")
+					}
+					return &transfer_pvc.Progress{TransferredFiles: 3, Source: stats, Destination: stats}, nil
+				},
+				log: testLogger(),
+			}
+			got := m.migrate(context.Background(), "foo", tt.claim)
+			if got.Result != tt.wantResult || got.Attempts != tt.wantAttempts || runs != tt.wantAttempts {
+				t.Errorf("migrate() = %+v after %d transfers, want %s after %d attempts", got, runs, tt.wantResult, tt.wantAttempts)
+			}
+			if (got.Error == "") != (tt.wantResult == pvcMigrated) {
+				t.Errorf("migrate() error = %q, want one only when failed", got.Error)
+			}
+			if tt.wantResult == pvcMigrated && (got.StorageClass != "standard-rwo" || got.Source != stats || got.TransferredFiles != 3) {
+				t.Errorf("migrate() = %+v, want the storage class and the volume stats", got)
+			}
+		})
+	}
+}
+
+func TestPVCMigrateOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		o       *PVCMigrateOptions
+		wantErr bool
+	}{
+		{
+			name: "given a namespace, two contexts and a subdomain, should pass",
+			o:    &PVCMigrateOptions{namespaces: []string{"foo"}, sourceContext: "a", targetContext: "b", endpoint: transfer_pvc.EndpointFlags{Subdomain: "apps.example.com"}},
+		},
+		{
+			name:    "given no namespace, should fail",
+			o:       &PVCMigrateOptions{sourceContext: "a", targetContext: "b", endpoint: transfer_pvc.EndpointFlags{Subdomain: "apps.example.com"}},
+			wantErr: true,
+		},
+		{
+			name:    "given --pvc with several namespaces, should fail",
+			o:       &PVCMigrateOptions{namespaces: []string{"foo", "bar"}, pvcs: []string{"data"}, sourceContext: "a", targetContext: "b", endpoint: transfer_pvc.EndpointFlags{Subdomain: "apps.example.com"}},
+			wantErr: true,
+		},
+		{
+			name:    "given the same context twice, should fail",
+			o:       &PVCMigrateOptions{namespaces: []string{"foo"}, sourceContext: "a", targetContext: "a", endpoint: transfer_pvc.EndpointFlags{Subdomain: "apps.example.com"}},
+			wantErr: true,
+		},
+		{
+			name:    "given negative retries, should fail",
+			o:       &PVCMigrateOptions{namespaces: []string{"foo"}, sourceContext: "a", targetContext: "b", retries: -1, endpoint: transfer_pvc.EndpointFlags{Subdomain: "apps.example.com"}},
+			wantErr: true,
+		},
+		{
+			name:    "given the ingress endpoint without a subdomain, should fail",
+			o:       &PVCMigrateOptions{namespaces: []string{"foo"}, sourceContext: "a", targetContext: "b"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.o.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,453 @@
+package quiesce
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+)
+
+// skipQuiesceAnnotation leaves a workload running when set to true, e.g. a database the final sync
+// reads from
+const skipQuiesceAnnotation = "migrate.konveyor.io/skip-quiesce"
+
+// quiescedResources are the workloads quiesce stops: the scalable ones are scaled to zero and the
+// CronJobs suspended
+var quiescedResources = []struct {
+	resource schema.GroupVersionResource
+	kind     string
+}{
+	{resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, kind: "Deployment"},
+	{resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}, kind: "StatefulSet"},
+	{resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "replicasets"}, kind: "ReplicaSet"},
+	{resource: schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "cronjobs"}, kind: "CronJob"},
+}
+
+var podsGVR = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+// QuiesceTimeoutError is returned by quiesce when pods of the scaled down workloads are still
+// running after the timeout, the workloads are scaled down nonetheless
+type QuiesceTimeoutError struct {
+	Timeout time.Duration
+	Pods    int
+}
+
+func (e *QuiesceTimeoutError) Error() string {
+	return fmt.Sprintf("%d pods still running %s after scaling down the workloads", e.Pods, e.Timeout)
+}
+
+// quiescedWorkload is a workload stopped by quiesce with what unquiesce restores: the replicas of
+// the scalable ones, the suspend field of the CronJobs
+type quiescedWorkload struct {
+	Namespace  string `json:"namespace"`
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Replicas   *int64 `json:"replicas,omitempty"`
+	Suspend    *bool  `json:"suspend,omitempty"`
+	// Selector is the label selector of the pods of the scalable workloads, waited for
+	Selector string `json:"selector,omitempty"`
+}
+
+func (w quiescedWorkload) key() string {
+	return w.Namespace + "/" + w.Kind + "/" + w.Name
+}
+
+func (w quiescedWorkload) String() string {
+	return fmt.Sprintf("%s %s/%s", w.Kind, w.Namespace, w.Name)
+}
+
+// quiesceState is quiesce-state.json in the export directory, the workloads to restore
+type quiesceState struct {
+	Workloads []quiescedWorkload `json:"workloads"`
+}
+
+// readQuiesceState reads the state of a previous quiesce, empty when there is none
+func readQuiesceState(exportDir string) (*quiesceState, error) {
+	state := &quiesceState{Workloads: []quiescedWorkload{}}
+	stateBytes, err := os.ReadFile(filepath.Join(exportDir, exporter.QuiesceStateFile))
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(stateBytes, state); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", exporter.QuiesceStateFile, err)
+	}
+	return state, nil
+}
+
+// write writes the state in the export directory, or removes the file once nothing is left to restore
+func (s *quiesceState) write(exportDir string) error {
+	path := filepath.Join(exportDir, exporter.QuiesceStateFile)
+	if len(s.Workloads) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	sort.SliceStable(s.Workloads, func(i, j int) bool { return s.Workloads[i].key() < s.Workloads[j].key() })
+	stateBytes, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(exportDir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(stateBytes, '\n'), 0600)
+}
+
+// quiescer stops and restores the workloads of namespaces
+type quiescer struct {
+	client dynamic.Interface
+	log    logrus.FieldLogger
+}
+
+// quiesce records the workloads of the namespace that are not recorded yet in the state, then
+// scales them to zero and suspends the CronJobs. The recorded values of a previous run are kept so
+// that running quiesce again does not record the stopped workloads as they are.
+func (q *quiescer) quiesce(ctx context.Context, namespace string, state *quiesceState) ([]quiescedWorkload, error) {
+	recorded := map[string]bool{}
+	for _, w := range state.Workloads {
+		recorded[w.key()] = true
+	}
+	stopped := []quiescedWorkload{}
+	for _, r := range quiescedResources {
+		list, err := q.client.Resource(r.resource).Namespace(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return stopped, fmt.Errorf("cannot list %s in namespace %s: %w", r.resource.Resource, namespace, err)
+		}
+		for _, obj := range list.Items {
+			if exporter.HasTrueAnnotation(skipQuiesceAnnotation)(obj) {
+				q.log.Infof("skipping %s %s/%s, annotated %s", r.kind, namespace, obj.GetName(), skipQuiesceAnnotation)
+				continue
+			}
+			// the owned ReplicaSets are scaled by their Deployment
+			if r.kind == "ReplicaSet" && exporter.IsControlled(obj) {
+				continue
+			}
+			w := quiescedWorkload{Namespace: namespace, APIVersion: r.resource.GroupVersion().String(), Kind: r.kind, Name: obj.GetName()}
+			if r.kind == "CronJob" {
+				suspend, _, _ := unstructured.NestedBool(obj.Object, "spec", "suspend")
+				w.Suspend = &suspend
+			} else {
+				scaled := exporter.BuiltinScaleSubresources["apps/"+r.kind].Workload(obj)
+				w.Replicas, w.Selector = &scaled.Replicas, scaled.Selector
+			}
+			if !recorded[w.key()] {
+				state.Workloads = append(state.Workloads, w)
+				recorded[w.key()] = true
+			}
+			if err := q.patch(ctx, r.resource, namespace, obj.GetName(), stopPatch(r.kind)); err != nil {
+				return stopped, err
+			}
+			q.log.Infof("stopped %s", w)
+			stopped = append(stopped, w)
+		}
+	}
+	return stopped, nil
+}
+
+// stopPatch is the merge patch stopping a workload of the kind
+func stopPatch(kind string) map[string]interface{} {
+	if kind == "CronJob" {
+		return map[string]interface{}{"spec": map[string]interface{}{"suspend": true}}
+	}
+	return map[string]interface{}{"spec": map[string]interface{}{"replicas": 0}}
+}
+
+// restorePatch is the merge patch restoring the recorded workload
+func restorePatch(w quiescedWorkload) map[string]interface{} {
+	spec := map[string]interface{}{}
+	if w.Replicas != nil {
+		spec["replicas"] = *w.Replicas
+	}
+	if w.Suspend != nil {
+		spec["suspend"] = *w.Suspend
+	}
+	return map[string]interface{}{"spec": spec}
+}
+
+func (q *quiescer) patch(ctx context.Context, resource schema.GroupVersionResource, namespace, name string, patch map[string]interface{}) error {
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	_, err = q.client.Resource(resource).Namespace(namespace).Patch(ctx, name, types.MergePatchType, patchBytes, metav1.PatchOptions{})
+	return err
+}
+
+// waitTerminated waits for the pods of the stopped workloads to terminate and returns the number
+// of pods still running after the timeout
+func (q *quiescer) waitTerminated(ctx context.Context, stopped []quiescedWorkload, timeout time.Duration) (int, error) {
+	running := 0
+	err := wait.PollUntilContextTimeout(ctx, 2*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		running = 0
+		for _, w := range stopped {
+			if w.Selector == "" {
+				continue
+			}
+			pods, err := q.client.Resource(podsGVR).Namespace(w.Namespace).List(ctx, metav1.ListOptions{LabelSelector: w.Selector})
+			if err != nil {
+				return false, err
+			}
+			running += len(pods.Items)
+		}
+		if running > 0 {
+			q.log.Infof("waiting for %d pods to terminate", running)
+		}
+		return running == 0, nil
+	})
+	if wait.Interrupted(err) {
+		return running, nil
+	}
+	return running, err
+}
+
+// unquiesce restores the recorded workloads of the namespaces, all of them when none is given. It
+// returns the workloads left to restore, the ones of the other namespaces and the failed ones, with
+// the number of failed ones. The workloads deleted since quiesce, or annotated to be skipped, are
+// dropped from the state.
+func (q *quiescer) unquiesce(ctx context.Context, namespaces []string, state *quiesceState) ([]quiescedWorkload, int) {
+	kept := []quiescedWorkload{}
+	failed := 0
+	for _, w := range state.Workloads {
+		if len(namespaces) > 0 && !slices.Contains(namespaces, w.Namespace) {
+			kept = append(kept, w)
+			continue
+		}
+		gv, err := schema.ParseGroupVersion(w.APIVersion)
+		if err != nil {
+			q.log.Errorf("cannot restore %s: %v", w, err)
+			kept = append(kept, w)
+			failed++
+			continue
+		}
+		resource := schema.GroupVersionResource{}
+		for _, r := range quiescedResources {
+			if r.kind == w.Kind && r.resource.Group == gv.Group {
+				resource = r.resource
+			}
+		}
+		obj, err := q.client.Resource(resource).Namespace(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			q.log.Warnf("%s no longer exists, it is not restored", w)
+			continue
+		case err != nil:
+			q.log.Errorf("cannot restore %s: %v", w, err)
+			kept = append(kept, w)
+			failed++
+			continue
+		case exporter.HasTrueAnnotation(skipQuiesceAnnotation)(*obj):
+			q.log.Infof("skipping %s, annotated %s", w, skipQuiesceAnnotation)
+			continue
+		}
+		if err := q.patch(ctx, resource, w.Namespace, w.Name, restorePatch(w)); err != nil {
+			q.log.Errorf("cannot restore %s: %v", w, err)
+			kept = append(kept, w)
+			failed++
+			continue
+		}
+		q.log.Infof("restored %s", w)
+	}
+	return kept, failed
+}
+
+type QuiesceOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+
+	// Two GlobalFlags struct fields are needed
+	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
+	// 2. globalFlags for the args merged with values from the viper config file
+	cobraGlobalFlags *flags.GlobalFlags
+	globalFlags      *flags.GlobalFlags
+
+	exportDir  string
+	namespaces []string
+	timeout    time.Duration
+	// restore is set by unquiesce
+	restore bool
+
+	genericclioptions.IOStreams
+}
+
+func (o *QuiesceOptions) Complete(c *cobra.Command, args []string) error {
+	o.namespaces = exporter.UniqueNamespaces(o.namespaces)
+	if len(o.namespaces) == 0 && !o.restore {
+		namespace, _, err := o.configFlags.ToRawKubeConfigLoader().Namespace()
+		if err != nil {
+			return err
+		}
+		o.namespaces = []string{namespace}
+	}
+	return nil
+}
+
+func (o *QuiesceOptions) Validate() error {
+	if o.timeout < 0 {
+		return fmt.Errorf("--timeout must not be negative")
+	}
+	return nil
+}
+
+func (o *QuiesceOptions) Run() error {
+	return o.run(context.Background(), o.globalFlags.GetLogger())
+}
+
+func NewQuiesceCommand(streams genericclioptions.IOStreams, f *flags.GlobalFlags) *cobra.Command {
+	return newQuiesceCommand(streams, f, false)
+}
+
+func NewUnquiesceCommand(streams genericclioptions.IOStreams, f *flags.GlobalFlags) *cobra.Command {
+	return newQuiesceCommand(streams, f, true)
+}
+
+func newQuiesceCommand(streams genericclioptions.IOStreams, f *flags.GlobalFlags, restore bool) *cobra.Command {
+	o := &QuiesceOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+		restore:     restore,
+
+		IOStreams:        streams,
+		cobraGlobalFlags: f,
+		globalFlags:      f,
+	}
+	cmd := &cobra.Command{
+		Use:   "quiesce",
+		Short: "Scale down the workloads of the source namespaces before the final sync",
+		Long: `Scale down the workloads of the source namespaces before the final sync.
+
+The replicas of the Deployments, StatefulSets and ReplicaSets not owned by a Deployment, and the
+suspend field of the CronJobs, are recorded in ` + exporter.QuiesceStateFile + ` in the export directory.
+The workloads are then scaled to zero and the CronJobs suspended, and the pods of the scaled
+workloads are waited for until --timeout. unquiesce restores the recorded values.
+
+Running quiesce again keeps the values recorded by the first run, and the workloads annotated
+` + skipQuiesceAnnotation + `=true are left unchanged.
+
+Exit codes:
+  0    the workloads are scaled down and their pods terminated
+  1    fatal error, like an unreachable cluster
+  3    some pods are still running after --timeout`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			c.SilenceUsage = true
+			if err := o.Run(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+			viper.Unmarshal(o.globalFlags)
+			viper.Unmarshal(o.configFlags)
+			viper.UnmarshalKey("export-dir", &o.exportDir)
+		},
+	}
+	if restore {
+		cmd.Use = "unquiesce"
+		cmd.Short = "Restore the workloads scaled down by quiesce"
+		cmd.Long = `Restore the workloads scaled down by quiesce.
+
+The replicas and the suspend field recorded in ` + exporter.QuiesceStateFile + ` are restored, for the
+namespaces given or every recorded one. The restored workloads are removed from the file, which
+is deleted once every workload is restored, so unquiesce can be run again after a failure. The
+workloads deleted since quiesce, or annotated ` + skipQuiesceAnnotation + `=true, are left unchanged.
+
+Exit codes:
+  0    the recorded workloads are restored
+  1    fatal error, like an unreachable cluster`
+	}
+
+	cmd.Flags().StringVarP(&o.exportDir, "export-dir", "e", "export", "The export directory "+exporter.QuiesceStateFile+" is kept in")
+	if restore {
+		cmd.Flags().StringSliceVarP(&o.namespaces, "namespace", "n", nil, "The namespace to restore, defaults to every recorded namespace. Can be repeated or comma-separated")
+	} else {
+		cmd.Flags().StringSliceVarP(&o.namespaces, "namespace", "n", nil, "The namespace to quiesce, defaults to the namespace of the current context. Can be repeated or comma-separated")
+		cmd.Flags().DurationVar(&o.timeout, "timeout", 5*time.Minute, "How long to wait for the pods of the scaled down workloads to terminate, not waiting when 0")
+	}
+	// the namespace flag is registered above so it can take several values
+	o.configFlags.Namespace = nil
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func (o *QuiesceOptions) run(ctx context.Context, log logrus.FieldLogger) error {
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return fmt.Errorf("cannot create rest config: %w", err)
+	}
+	client, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("cannot create dynamic client: %w", err)
+	}
+	state, err := readQuiesceState(o.exportDir)
+	if err != nil {
+		return err
+	}
+	q := &quiescer{client: client, log: log}
+
+	if o.restore {
+		var failed int
+		state.Workloads, failed = q.unquiesce(ctx, o.namespaces, state)
+		if err := state.write(o.exportDir); err != nil {
+			return fmt.Errorf("cannot write %s: %w", exporter.QuiesceStateFile, err)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d workloads not restored, they are kept in %s to run unquiesce again", failed, exporter.QuiesceStateFile)
+		}
+		return nil
+	}
+
+	stopped := []quiescedWorkload{}
+	for _, namespace := range o.namespaces {
+		workloads, err := q.quiesce(ctx, namespace, state)
+		stopped = append(stopped, workloads...)
+		// the state is written before anything else so that the stopped workloads can be restored
+		if writeErr := state.write(o.exportDir); writeErr != nil {
+			return fmt.Errorf("cannot write %s: %w", exporter.QuiesceStateFile, writeErr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	log.Infof("%d workloads stopped in %s, their state is recorded in %s", len(stopped), strings.Join(o.namespaces, ", "), filepath.Join(o.exportDir, exporter.QuiesceStateFile))
+	if o.timeout == 0 {
+		return nil
+	}
+	running, err := q.waitTerminated(ctx, stopped, o.timeout)
+	if err != nil {
+		return err
+	}
+	if running > 0 {
+		return &QuiesceTimeoutError{Timeout: o.timeout, Pods: running}
+	}
+	return nil
+}
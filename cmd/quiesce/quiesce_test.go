@@ -0,0 +1,149 @@
+package quiesce
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func testLogger() logrus.FieldLogger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func testReplayObject(apiVersion, kind, namespace, name string) unstructured.Unstructured {
+	obj := unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+func testQuiesceClient() dynamic.Interface {
+	deployment := testReplayObject("apps/v1", "Deployment", "foo", "web")
+	deployment.Object["spec"] = map[string]interface{}{"replicas": int64(3), "selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}}}
+	database := testReplayObject("apps/v1", "StatefulSet", "foo", "db")
+	database.SetAnnotations(map[string]string{skipQuiesceAnnotation: "true"})
+	database.Object["spec"] = map[string]interface{}{"replicas": int64(1)}
+	controller := true
+	owned := testReplayObject("apps/v1", "ReplicaSet", "foo", "web-abc12")
+	owned.SetOwnerReferences([]metav1.OwnerReference{{Kind: "Deployment", Name: "web", Controller: &controller}})
+	owned.Object["spec"] = map[string]interface{}{"replicas": int64(3)}
+	cronJob := testReplayObject("batch/v1", "CronJob", "foo", "report")
+	cronJob.Object["spec"] = map[string]interface{}{"schedule": "0 * * * *"}
+	listKinds := map[schema.GroupVersionResource]string{podsGVR: "PodList"}
+	for _, r := range quiescedResources {
+		listKinds[r.resource] = r.kind + "List"
+	}
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, &deployment, &database, &owned, &cronJob)
+}
+
+func testQuiescedField(t *testing.T, client dynamic.Interface, gvr schema.GroupVersionResource, name string, field string) interface{} {
+	t.Helper()
+	obj, err := client.Resource(gvr).Namespace("foo").Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, _, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", field)
+	return value
+}
+
+func Test_quiescer(t *testing.T) {
+	client := testQuiesceClient()
+	q := &quiescer{client: client, log: testLogger()}
+	state := &quiesceState{Workloads: []quiescedWorkload{}}
+	stopped, err := q.quiesce(context.Background(), "foo", state)
+	if err != nil {
+		t.Fatalf("quiesce() error = %v", err)
+	}
+	replicas, suspend := int64(3), false
+	want := []quiescedWorkload{
+		{Namespace: "foo", APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Replicas: &replicas, Selector: "app=web"},
+		{Namespace: "foo", APIVersion: "batch/v1", Kind: "CronJob", Name: "report", Suspend: &suspend},
+	}
+	if !reflect.DeepEqual(stopped, want) || !reflect.DeepEqual(state.Workloads, want) {
+		t.Errorf("quiesce() = %+v, state %+v, want %+v", stopped, state.Workloads, want)
+	}
+	deployments, cronJobs := quiescedResources[0].resource, quiescedResources[3].resource
+	if got := testQuiescedField(t, client, deployments, "web", "replicas"); got != int64(0) {
+		t.Errorf("web replicas = %v, want 0", got)
+	}
+	if got := testQuiescedField(t, client, cronJobs, "report", "suspend"); got != true {
+		t.Errorf("report suspend = %v, want true", got)
+	}
+	if got := testQuiescedField(t, client, quiescedResources[1].resource, "db", "replicas"); got != int64(1) {
+		t.Errorf("skipped db replicas = %v, want 1", got)
+	}
+	if got := testQuiescedField(t, client, quiescedResources[2].resource, "web-abc12", "replicas"); got != int64(3) {
+		t.Errorf("owned ReplicaSet replicas = %v, want 3", got)
+	}
+
+	// running quiesce again keeps the recorded values
+	dir := t.TempDir()
+	if err := state.write(dir); err != nil {
+		t.Fatal(err)
+	}
+	state, err = readQuiesceState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.quiesce(context.Background(), "foo", state); err != nil {
+		t.Fatalf("second quiesce() error = %v", err)
+	}
+	// the state file is sorted on write
+	want = []quiescedWorkload{want[1], want[0]}
+	if !reflect.DeepEqual(state.Workloads, want) {
+		t.Errorf("state after a second quiesce = %+v, want %+v", state.Workloads, want)
+	}
+	if running, err := q.waitTerminated(context.Background(), stopped, 0); err != nil || running != 0 {
+		t.Errorf("waitTerminated() = %d, %v, want no pod running", running, err)
+	}
+
+	kept, failed := q.unquiesce(context.Background(), nil, state)
+	if len(kept) != 0 || failed != 0 {
+		t.Errorf("unquiesce() = %+v, %d failed, want every workload restored", kept, failed)
+	}
+	if got := testQuiescedField(t, client, deployments, "web", "replicas"); got != int64(3) {
+		t.Errorf("restored web replicas = %v, want 3", got)
+	}
+	if got := testQuiescedField(t, client, cronJobs, "report", "suspend"); got != false {
+		t.Errorf("restored report suspend = %v, want false", got)
+	}
+}
+
+func Test_quiescer_unquiesce(t *testing.T) {
+	replicas := int64(2)
+	state := &quiesceState{Workloads: []quiescedWorkload{
+		{Namespace: "foo", APIVersion: "apps/v1", Kind: "Deployment", Name: "deleted", Replicas: &replicas},
+		{Namespace: "bar", APIVersion: "apps/v1", Kind: "Deployment", Name: "api", Replicas: &replicas},
+		{Namespace: "foo", APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Replicas: &replicas},
+	}}
+	q := &quiescer{client: testQuiesceClient(), log: testLogger()}
+	kept, failed := q.unquiesce(context.Background(), []string{"foo"}, state)
+	want := []quiescedWorkload{state.Workloads[1]}
+	if !reflect.DeepEqual(kept, want) || failed != 0 {
+		t.Errorf("unquiesce() = %+v, %d failed, want the workloads of the other namespace kept", kept, failed)
+	}
+
+	dir := t.TempDir()
+	if err := (&quiesceState{Workloads: kept}).write(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&quiesceState{}).write(dir); err != nil {
+		t.Fatal(err)
+	}
+	if read, err := readQuiesceState(dir); err != nil || len(read.Workloads) != 0 {
+		t.Errorf("state once restored = %+v, %v, want the file removed", read, err)
+	}
+}
@@ -0,0 +1,474 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	k8sdiscovery "k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// applyFieldManager owns the fields applied by replay on the target cluster
+	applyFieldManager = "kubectl-migrate"
+
+	dryRunNone   = "none"
+	dryRunServer = "server"
+)
+
+// ReplayFailureError is returned when a replay completed but some objects could not be applied,
+// the details are in apply-results.json
+type ReplayFailureError struct {
+	Failures int
+}
+
+func (e *ReplayFailureError) Error() string {
+	return fmt.Sprintf("replay completed with %d objects not applied, see %s", e.Failures, exporter.ApplyResultsFile)
+}
+
+type ReplayOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+
+	// Two GlobalFlags struct fields are needed
+	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
+	// 2. globalFlags for the args merged with values from the viper config file
+	cobraGlobalFlags *flags.GlobalFlags
+	globalFlags      *flags.GlobalFlags
+
+	exportDir       string
+	namespaces      []string
+	targetNamespace string
+	dryRun          string
+	crdTimeout      time.Duration
+	forceConflicts  bool
+	skipExisting    bool
+	prune           bool
+	pruneAllowlist  []string
+	yes             bool
+
+	genericclioptions.IOStreams
+}
+
+func (o *ReplayOptions) Complete(c *cobra.Command, args []string) error {
+	return nil
+}
+
+func (o *ReplayOptions) Validate() error {
+	if o.dryRun != dryRunNone && o.dryRun != dryRunServer {
+		return fmt.Errorf("invalid --dry-run %q, must be %s or %s", o.dryRun, dryRunNone, dryRunServer)
+	}
+	if o.targetNamespace != "" {
+		if errs := validation.IsDNS1123Label(o.targetNamespace); len(errs) > 0 {
+			return fmt.Errorf("invalid --target-namespace %q: %s", o.targetNamespace, strings.Join(errs, ", "))
+		}
+	}
+	if o.crdTimeout <= 0 {
+		return fmt.Errorf("--crd-timeout must be positive")
+	}
+	if o.forceConflicts && o.skipExisting {
+		return fmt.Errorf("--force-conflicts and --skip-existing are mutually exclusive")
+	}
+	if !o.prune && (len(o.pruneAllowlist) > 0 || o.yes) {
+		return fmt.Errorf("--prune-allowlist and --yes require --prune")
+	}
+	return nil
+}
+
+func (o *ReplayOptions) Run() error {
+	return o.run(context.Background(), o.globalFlags.GetLogger())
+}
+
+func NewReplayCommand(streams genericclioptions.IOStreams, f *flags.GlobalFlags) *cobra.Command {
+	o := &ReplayOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+
+		IOStreams:        streams,
+		cobraGlobalFlags: f,
+		globalFlags:      f,
+	}
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Apply an export to the target cluster in dependency order",
+		Long: `Apply an export to the target cluster in dependency order.
+
+The objects of the export, including the cluster-scoped ones written under _cluster, are applied
+with server-side apply, as the kubectl-migrate field manager, in tiers: the Namespaces, the CRDs, the other cluster-scoped objects like
+the cluster RBAC, the ServiceAccounts and their Roles and RoleBindings, the ConfigMaps and Secrets,
+the workloads and the other namespaced objects, and the webhook configurations last. The CRDs must
+be Established before the next tier is applied. The Events are not applied, the encrypted
+files are skipped until the export is decrypted, and the Secrets exported with --redact-secrets are
+skipped with a warning, their values being placeholders.
+
+An object whose fields are owned by another field manager, like a previous kubectl apply or a
+controller, fails with the conflicting fields unless --force-conflicts takes them over.
+--skip-existing leaves the objects already on the target cluster unchanged instead. The result of
+each object, created, configured, unchanged, skipped or failed, is recorded in ` + exporter.ApplyResultsFile + `
+at the root of the export directory.
+
+The applied objects are labeled ` + exporter.ManagedLabel + `=true. With --prune, the labeled objects of
+the target namespaces that are not in the export anymore, like the objects deleted from the source
+since the previous replay, are listed and deleted with --yes. The objects owned by another one are
+left to the garbage collector, and --prune-allowlist limits the kinds pruned.
+
+Exit codes:
+  0    every object was applied
+  1    fatal error, like an invalid export or an unreachable cluster
+  2    some objects could not be applied, see ` + exporter.ApplyResultsFile,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			c.SilenceUsage = true
+			if err := o.Run(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+			viper.Unmarshal(o.globalFlags)
+			viper.Unmarshal(o.configFlags)
+			viper.UnmarshalKey("export-dir", &o.exportDir)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.exportDir, "export-dir", "e", "export", "The export directory to apply, as written by export")
+	cmd.Flags().StringSliceVarP(&o.namespaces, "namespace", "n", nil, "The exported namespace to apply, defaults to every namespace of the export. Can be repeated or comma-separated")
+	cmd.Flags().StringVar(&o.targetNamespace, "target-namespace", "", "Move the applied objects to this namespace, like export --target-namespace does")
+	cmd.Flags().StringVar(&o.dryRun, "dry-run", dryRunNone, "none or server. With server, the objects are submitted to the target cluster without being persisted")
+	cmd.Flags().DurationVar(&o.crdTimeout, "crd-timeout", time.Minute, "How long to wait for each applied CRD to become Established")
+	cmd.Flags().BoolVar(&o.forceConflicts, "force-conflicts", false, "Take over the fields owned by other field managers instead of failing the object")
+	cmd.Flags().BoolVar(&o.skipExisting, "skip-existing", false, "Leave the objects already on the target cluster unchanged, e.g. to resume a partial migration")
+	cmd.Flags().BoolVar(&o.prune, "prune", false, "List the objects of the target namespaces labeled "+exporter.ManagedLabel+"=true that are not in the export, and delete them with --yes")
+	cmd.Flags().StringSliceVar(&o.pruneAllowlist, "prune-allowlist", nil, "The kinds --prune may delete, like Deployment or Route.route.openshift.io, defaults to every kind. Can be repeated or comma-separated")
+	cmd.Flags().BoolVar(&o.yes, "yes", false, "Delete the objects listed by --prune")
+	// the namespace flag is registered above so it can take several values
+	o.configFlags.Namespace = nil
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func (o *ReplayOptions) run(ctx context.Context, log logrus.FieldLogger) error {
+	previous, err := exporter.ReadRunSummary(o.exportDir)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s has no export to apply, %s not found", o.exportDir, exporter.SummaryJSONFile)
+	}
+	if err != nil {
+		return err
+	}
+	namespaces, err := previous.SelectNamespaces(o.namespaces)
+	if err != nil {
+		return err
+	}
+	entries, err := index.Read(o.exportDir)
+	if err != nil {
+		return fmt.Errorf("cannot read the %s of %s: %w", index.File, o.exportDir, err)
+	}
+
+	renamer := exporter.NewNamespaceRenamer(o.targetNamespace)
+	tiers := make([][]exporter.ReplayObject, len(exporter.ApplyTiers))
+	results := []exporter.ApplyResult{}
+	seen := map[string]bool{}
+	// targets are the namespaces the objects are applied to, the only ones pruned
+	targets := []string{}
+	prunable := o.prune
+	for _, namespace := range namespaces {
+		objects, skipped, err := exporter.ReadReplayObjects(o.exportDir, namespace, entries)
+		if err != nil {
+			return err
+		}
+		results = append(results, skipped...)
+		// the objects were already moved when the export used --target-namespace
+		source := namespace
+		if target := previous.Flags["target-namespace"]; target != "" {
+			source = target
+		}
+		if warnings := exporter.RenameReplayObjects(renamer, source, objects); warnings > 0 {
+			log.Warnf("%d fields of namespace %s still mention it after --target-namespace", warnings, source)
+		}
+		target := source
+		if o.targetNamespace != "" {
+			target = o.targetNamespace
+		}
+		targets = append(targets, target)
+		// the encrypted objects are in the export even though they are not applied
+		for _, result := range skipped {
+			if result.Name == "" {
+				prunable = false
+				log.Warnf("%s holds encrypted objects that are not known, nothing is pruned", result.Path)
+				continue
+			}
+			if result.Error == exporter.SkippedRedacted {
+				log.Warnf("Skipping Secret %s/%s, its values were redacted by the export", result.Namespace, result.Name)
+			}
+			obj := unstructured.Unstructured{}
+			obj.SetAPIVersion(result.APIVersion)
+			obj.SetKind(result.Kind)
+			obj.SetName(result.Name)
+			if result.Namespace != "" {
+				obj.SetNamespace(target)
+			}
+			seen[exporter.ReplayKey(obj)] = true
+		}
+		for _, r := range objects {
+			// the CRDs and cluster-scoped objects referenced from several namespaces are exported
+			// with each, and the --all-versions exports hold each object once per version
+			key := exporter.ReplayKey(r.Object)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			tier := exporter.ApplyTier(r.Object)
+			tiers[tier] = append(tiers[tier], r)
+		}
+	}
+
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return fmt.Errorf("cannot create rest config: %w", err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("cannot create dynamic client: %w", err)
+	}
+	mapper, err := o.configFlags.ToRESTMapper()
+	if err != nil {
+		return fmt.Errorf("cannot create rest mapper: %w", err)
+	}
+	r := &replayer{
+		client:         dynamicClient,
+		mapper:         mapper,
+		dryRun:         o.dryRun == dryRunServer,
+		forceConflicts: o.forceConflicts,
+		skipExisting:   o.skipExisting,
+		crdTimeout:     o.crdTimeout,
+		log:            log,
+	}
+	results = append(results, r.apply(ctx, tiers)...)
+	if prunable {
+		pruneResults, err := o.runPrune(ctx, dynamicClient, exporter.UniqueNamespaces(targets), seen, log)
+		if err != nil {
+			return err
+		}
+		results = append(results, pruneResults...)
+	}
+
+	if err := exporter.WriteApplyResults(o.exportDir, results); err != nil {
+		return fmt.Errorf("cannot write %s: %w", exporter.ApplyResultsFile, err)
+	}
+	counts := map[string]int{}
+	for _, result := range results {
+		counts[result.Result]++
+	}
+	fmt.Fprintf(o.Out, "%d created, %d configured, %d unchanged, %d skipped, %d pruned, %d failed, see %s\n",
+		counts[exporter.ApplyCreated], counts[exporter.ApplyConfigured], counts[exporter.ApplyUnchanged], counts[exporter.ApplySkipped], counts[exporter.ApplyPruned], counts[exporter.ApplyFailed], filepath.Join(o.exportDir, exporter.ApplyResultsFile))
+	if counts[exporter.ApplyFailed] > 0 {
+		return &ReplayFailureError{Failures: counts[exporter.ApplyFailed]}
+	}
+	return nil
+}
+
+// runPrune lists the managed objects of the target namespaces missing from the export, and deletes
+// them with --yes
+func (o *ReplayOptions) runPrune(ctx context.Context, client dynamic.Interface, namespaces []string, exported map[string]bool, log logrus.FieldLogger) ([]exporter.ApplyResult, error) {
+	discoveryClient, err := o.configFlags.ToDiscoveryClient()
+	if err != nil {
+		return nil, fmt.Errorf("cannot create discovery client: %w", err)
+	}
+	resources, err := k8sdiscovery.ServerPreferredNamespacedResources(discoveryClient)
+	switch {
+	case k8sdiscovery.IsGroupDiscoveryFailedError(err):
+		// the objects of the groups that cannot be discovered are not pruned
+		log.Warnf("some API groups cannot be discovered, their objects are not pruned: %v", err)
+	case err != nil:
+		return nil, fmt.Errorf("cannot discover the resources to prune: %w", err)
+	}
+	p := exporter.NewPruner(client, resources, o.pruneAllowlist, o.dryRun == dryRunServer, log)
+	candidates, results := p.Candidates(ctx, namespaces, exported)
+	if !o.yes {
+		exporter.PrintPruneCandidates(o.Out, candidates)
+		return results, nil
+	}
+	return append(results, p.Prune(ctx, candidates)...), nil
+}
+
+// replayer applies the exported objects to the target cluster with server-side apply
+type replayer struct {
+	client         dynamic.Interface
+	mapper         meta.RESTMapper
+	dryRun         bool
+	forceConflicts bool
+	skipExisting   bool
+	crdTimeout     time.Duration
+	log            logrus.FieldLogger
+}
+
+// apply applies the objects tier by tier and returns the result of each. The REST mapper is reset
+// once the CRDs are Established, so that their custom resources can be mapped.
+func (r *replayer) apply(ctx context.Context, tiers [][]exporter.ReplayObject) []exporter.ApplyResult {
+	results := []exporter.ApplyResult{}
+	for tier, objects := range tiers {
+		start := len(results)
+		for _, o := range objects {
+			results = append(results, r.applyObject(ctx, exporter.ApplyTiers[tier], o))
+		}
+		if tier == exporter.TierCRDs && len(objects) > 0 {
+			r.waitEstablished(ctx, results[start:])
+			meta.MaybeResetRESTMapper(r.mapper)
+		}
+	}
+	return results
+}
+
+// applyObject applies the object and tells whether it was created, configured or left unchanged
+// by comparing it with the live object
+func (r *replayer) applyObject(ctx context.Context, tier string, o exporter.ReplayObject) exporter.ApplyResult {
+	// the export keeps the server-populated fields with --raw, the apply would be rejected
+	obj := o.Object.DeepCopy()
+	exporter.StripServerPopulated(obj)
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[exporter.ManagedLabel] = "true"
+	obj.SetLabels(labels)
+	result := exporter.ApplyResult{Tier: tier, Namespace: obj.GetNamespace(), APIVersion: obj.GetAPIVersion(), Kind: obj.GetKind(), Name: obj.GetName(), Path: o.Path}
+	fail := func(err error) exporter.ApplyResult {
+		result.Result, result.Error, result.Conflicts = exporter.ApplyFailed, err.Error(), applyConflicts(err)
+		r.log.Errorf("cannot apply %s %s/%s: %v", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+		return result
+	}
+
+	gvk := obj.GroupVersionKind()
+	mapping, err := r.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return fail(err)
+	}
+	var client dynamic.ResourceInterface = r.client.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		client = r.client.Resource(mapping.Resource).Namespace(obj.GetNamespace())
+	}
+	live, err := client.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		live = nil
+	case err != nil:
+		return fail(err)
+	case r.skipExisting:
+		result.Result = exporter.ApplySkipped
+		result.Error = "already exists"
+		r.log.Debugf("skipped %s %s/%s, it already exists", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+		return result
+	}
+
+	options := metav1.ApplyOptions{FieldManager: applyFieldManager, Force: r.forceConflicts}
+	if r.dryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	applied, err := client.Apply(ctx, obj.GetName(), obj, options)
+	if err != nil {
+		return fail(err)
+	}
+	switch {
+	case live == nil:
+		result.Result = exporter.ApplyCreated
+	case sameObject(live, applied):
+		result.Result = exporter.ApplyUnchanged
+	default:
+		result.Result = exporter.ApplyConfigured
+	}
+	r.log.Debugf("%s %s %s/%s", result.Result, obj.GetKind(), obj.GetNamespace(), obj.GetName())
+	return result
+}
+
+// sameObject reports whether an apply left the live object unchanged. The field managers are
+// ignored, applying an unchanged object still records the fields of kubectl-migrate.
+func sameObject(live, applied *unstructured.Unstructured) bool {
+	if applied == nil {
+		return false
+	}
+	strip := func(obj *unstructured.Unstructured) map[string]interface{} {
+		obj = obj.DeepCopy()
+		obj.SetManagedFields(nil)
+		return obj.Object
+	}
+	return equality.Semantic.DeepEqual(strip(live), strip(applied))
+}
+
+// applyConflicts returns the fields of a server-side apply conflict, e.g.
+// .spec.replicas: conflict with "kube-controller-manager"
+func applyConflicts(err error) []string {
+	var status apierrors.APIStatus
+	if !apierrors.IsConflict(err) || !errors.As(err, &status) || status.Status().Details == nil {
+		return nil
+	}
+	conflicts := []string{}
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type == metav1.CauseTypeFieldManagerConflict {
+			conflicts = append(conflicts, cause.Field+": "+cause.Message)
+		}
+	}
+	return conflicts
+}
+
+// waitEstablished waits for the applied CRDs to become Established, the ones that do not within
+// --crd-timeout are failed. The skipped ones already exist on the target cluster. A dry run creates no CRD, their custom resources fail to be mapped
+// unless the target cluster already has them.
+func (r *replayer) waitEstablished(ctx context.Context, results []exporter.ApplyResult) {
+	if r.dryRun {
+		return
+	}
+	for i := range results {
+		if results[i].Result == exporter.ApplyFailed || results[i].Result == exporter.ApplySkipped {
+			continue
+		}
+		name := results[i].Name
+		err := wait.PollUntilContextTimeout(ctx, time.Second, r.crdTimeout, true, func(ctx context.Context) (bool, error) {
+			crd, err := r.client.Resource(exporter.CRDResource).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return false, nil
+			}
+			return isEstablished(crd), nil
+		})
+		if err != nil {
+			results[i].Result = exporter.ApplyFailed
+			results[i].Error = fmt.Sprintf("not Established within %s", r.crdTimeout)
+			r.log.Errorf("CRD %s is not Established within %s", name, r.crdTimeout)
+		}
+	}
+}
+
+func isEstablished(crd *unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == "Established" && condition["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
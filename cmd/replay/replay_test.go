@@ -0,0 +1,173 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func testLogger() logrus.FieldLogger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func testReplayObject(apiVersion, kind, namespace, name string) unstructured.Unstructured {
+	obj := unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+func Test_replayer_apply(t *testing.T) {
+	crd := func(name string, established bool) unstructured.Unstructured {
+		obj := testReplayObject("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", name)
+		if established {
+			obj.Object["status"] = map[string]interface{}{"conditions": []interface{}{
+				map[string]interface{}{"type": "Established", "status": "True"},
+			}}
+		}
+		return obj
+	}
+	widgets, gadgets := crd("widgets.example.com", true), crd("gadgets.example.com", false)
+	unchanged := testReplayObject("v1", "ConfigMap", "foo", "web")
+	unchanged.SetLabels(map[string]string{exporter.ManagedLabel: "true"})
+	configured := testReplayObject("apps/v1", "Deployment", "foo", "web")
+	configured.SetLabels(map[string]string{"app": "web"})
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), &widgets, &gadgets, unchanged.DeepCopy(), &configured)
+	applied := []string{}
+	client.PrependReactor("patch", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		patch := action.(clienttesting.PatchAction)
+		obj := &unstructured.Unstructured{}
+		if err := json.Unmarshal(patch.GetPatch(), &obj.Object); err != nil {
+			return true, nil, err
+		}
+		if obj.GetResourceVersion() != "" || obj.Object["status"] != nil {
+			return true, nil, fmt.Errorf("%s has server-populated fields", obj.GetName())
+		}
+		applied = append(applied, obj.GetKind()+"/"+patch.GetNamespace()+"/"+patch.GetName())
+		switch obj.GetName() {
+		case "broken":
+			return true, nil, fmt.Errorf("admission denied")
+		case "contested":
+			return true, nil, apierrors.NewApplyConflict([]metav1.StatusCause{
+				{Type: metav1.CauseTypeFieldManagerConflict, Field: ".spec.replicas", Message: `conflict with "kubectl-client-side-apply"`},
+			}, "Apply failed with 1 conflict")
+		}
+		return true, obj, nil
+	})
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	for _, m := range []struct {
+		gvk   schema.GroupVersionKind
+		scope meta.RESTScope
+	}{
+		{schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot},
+		{schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace},
+		{schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace},
+		{schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}, meta.RESTScopeRoot},
+		{schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}, meta.RESTScopeNamespace},
+	} {
+		mapper.Add(m.gvk, m.scope)
+	}
+
+	raw := testReplayObject("v1", "ConfigMap", "foo", "web")
+	raw.SetResourceVersion("42")
+	raw.Object["status"] = map[string]interface{}{}
+	tiers := make([][]exporter.ReplayObject, len(exporter.ApplyTiers))
+	for _, obj := range []unstructured.Unstructured{
+		testReplayObject("apps/v1", "Deployment", "foo", "web"),
+		testReplayObject("example.com/v1", "Widget", "foo", "blue"),
+		testReplayObject("apps/v1", "Deployment", "foo", "broken"),
+		testReplayObject("apps/v1", "Deployment", "foo", "contested"),
+		testReplayObject("example.com/v1", "Gadget", "foo", "red"),
+		raw,
+		crd("widgets.example.com", false),
+		crd("gadgets.example.com", false),
+		testReplayObject("v1", "Namespace", "", "foo"),
+	} {
+		tiers[exporter.ApplyTier(obj)] = append(tiers[exporter.ApplyTier(obj)], exporter.ReplayObject{Path: "resources/foo/" + obj.GetName() + ".yaml", Object: obj})
+	}
+	resultsOf := func(results []exporter.ApplyResult) map[string]string {
+		got := map[string]string{}
+		for _, result := range results {
+			got[result.Kind+"/"+result.Name] = result.Tier + " " + result.Result
+		}
+		return got
+	}
+
+	r := &replayer{client: client, mapper: mapper, crdTimeout: 10 * time.Millisecond, log: testLogger()}
+	results := r.apply(context.Background(), tiers)
+
+	wantApplied := []string{
+		"Namespace//foo",
+		"CustomResourceDefinition//widgets.example.com",
+		"CustomResourceDefinition//gadgets.example.com",
+		"ConfigMap/foo/web",
+		"Deployment/foo/web",
+		"Widget/foo/blue",
+		"Deployment/foo/broken",
+		"Deployment/foo/contested",
+	}
+	if !reflect.DeepEqual(applied, wantApplied) {
+		t.Errorf("applied = %v, want %v", applied, wantApplied)
+	}
+	want := map[string]string{
+		"Namespace/foo": "namespaces created",
+		"CustomResourceDefinition/widgets.example.com": "crds configured",
+		"CustomResourceDefinition/gadgets.example.com": "crds failed",
+		"ConfigMap/web":        "config unchanged",
+		"Deployment/web":       "workloads configured",
+		"Widget/blue":          "workloads created",
+		"Deployment/broken":    "workloads failed",
+		"Deployment/contested": "workloads failed",
+		"Gadget/red":           "workloads failed",
+	}
+	if got := resultsOf(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("apply() = %v, want %v", got, want)
+	}
+	for _, result := range results {
+		if result.Name == "contested" && !reflect.DeepEqual(result.Conflicts, []string{`.spec.replicas: conflict with "kubectl-client-side-apply"`}) {
+			t.Errorf("apply() conflicts = %v, want the conflicting field", result.Conflicts)
+		}
+	}
+
+	skip := &replayer{client: client, mapper: mapper, skipExisting: true, crdTimeout: 10 * time.Millisecond, log: testLogger()}
+	applied = nil
+	want = map[string]string{
+		"Namespace/foo": "namespaces created",
+		"CustomResourceDefinition/widgets.example.com": "crds skipped",
+		"CustomResourceDefinition/gadgets.example.com": "crds skipped",
+		"ConfigMap/web": "config skipped",
+	}
+	if got := resultsOf(skip.apply(context.Background(), tiers[:exporter.TierConfig+1])); !reflect.DeepEqual(got, want) {
+		t.Errorf("apply() with --skip-existing = %v, want %v", got, want)
+	}
+	if want := []string{"Namespace//foo"}; !reflect.DeepEqual(applied, want) {
+		t.Errorf("applied with --skip-existing = %v, want %v", applied, want)
+	}
+
+	dryRun := &replayer{client: client, mapper: mapper, dryRun: true, crdTimeout: 10 * time.Millisecond, log: testLogger()}
+	for _, result := range dryRun.apply(context.Background(), [][]exporter.ReplayObject{tiers[exporter.TierNamespaces], tiers[exporter.TierCRDs]}) {
+		if result.Result == exporter.ApplyFailed {
+			t.Errorf("dry run %s %s = %s %s, want applied without waiting for the CRDs", result.Kind, result.Name, result.Result, result.Error)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package report
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+const (
+	formatMarkdown = "markdown"
+	formatHTML     = "html"
+)
+
+//go:embed templates/report.md.tmpl templates/report.html.tmpl
+var reportTemplates embed.FS
+
+// migrationReport is what the report templates render, read from the files of an export directory
+type migrationReport struct {
+	Summary *exporter.RunSummary
+	// Objects is the number of exported objects of every namespace
+	Objects      int
+	Namespaces   []reportNamespace
+	Images       []*exporter.Image
+	HelmReleases []*exporter.HelmRelease
+	// Missing lists the files of the export that could not be found, their sections are empty
+	Missing []string
+}
+
+// reportNamespace is the section of an exported namespace
+type reportNamespace struct {
+	Name           string
+	Objects        int
+	Failures       int
+	Resources      map[string]int
+	FailureRecords []exporter.FailureRecord
+	Deprecated     []exporter.DeprecatedObject
+	Dangling       []exporter.GraphEdge
+}
+
+// readMigrationReport reads the report of the export in exportDir. The summary is required, the
+// other files are optional as export writes them only when they have content.
+func readMigrationReport(exportDir string) (*migrationReport, error) {
+	summary, err := exporter.ReadRunSummary(exportDir)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s has no export to report on, %s not found", exportDir, exporter.SummaryJSONFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r := &migrationReport{Summary: summary, Namespaces: []reportNamespace{}, Images: []*exporter.Image{}, HelmReleases: []*exporter.HelmRelease{}, Missing: []string{}}
+	for _, s := range summary.Namespaces {
+		ns := reportNamespace{Name: s.Namespace, Objects: s.Total(), Failures: s.Failures, Resources: s.Resources, Deprecated: s.Deprecated, Dangling: s.Dangling}
+		r.Objects += ns.Objects
+		path := filepath.Join("failures", s.Namespace, exporter.FailuresFile)
+		if err := readReportFile(exportDir, path, &ns.FailureRecords); os.IsNotExist(err) {
+			if s.Failures > 0 {
+				r.Missing = append(r.Missing, filepath.ToSlash(path))
+			}
+		} else if err != nil {
+			return nil, err
+		}
+		r.Namespaces = append(r.Namespaces, ns)
+	}
+	for file, v := range map[string]interface{}{exporter.ImagesFile: &r.Images, exporter.HelmReleasesFile: &r.HelmReleases} {
+		if err := readReportFile(exportDir, file, v); os.IsNotExist(err) {
+			r.Missing = append(r.Missing, file)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(r.Missing)
+	return r, nil
+}
+
+// readReportFile decodes the JSON file at the path relative to exportDir into v
+func readReportFile(exportDir, path string, v interface{}) error {
+	fileBytes, err := os.ReadFile(filepath.Join(exportDir, path))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(fileBytes, v); err != nil {
+		return fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return nil
+}
+
+// markdownCell escapes a value written in a cell of a Markdown table
+func markdownCell(value interface{}) string {
+	s := strings.ReplaceAll(fmt.Sprint(value), "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+var reportFuncs = map[string]interface{}{
+	"cell": markdownCell,
+	"join": strings.Join,
+}
+
+// render renders the report in the format, markdown or html
+func (r *migrationReport) render(format string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	switch format {
+	case formatHTML:
+		t, err := htmltemplate.New("report.html.tmpl").Funcs(reportFuncs).ParseFS(reportTemplates, "templates/report.html.tmpl")
+		if err != nil {
+			return nil, err
+		}
+		if err := t.Execute(buf, r); err != nil {
+			return nil, err
+		}
+	default:
+		t, err := template.New("report.md.tmpl").Funcs(reportFuncs).ParseFS(reportTemplates, "templates/report.md.tmpl")
+		if err != nil {
+			return nil, err
+		}
+		if err := t.Execute(buf, r); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+type ReportOptions struct {
+	// Two GlobalFlags struct fields are needed
+	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
+	// 2. globalFlags for the args merged with values from the viper config file
+	cobraGlobalFlags *flags.GlobalFlags
+	globalFlags      *flags.GlobalFlags
+
+	exportDir  string
+	format     string
+	outputFile string
+
+	genericclioptions.IOStreams
+}
+
+func (o *ReportOptions) Complete(c *cobra.Command, args []string) error {
+	if o.outputFile == "" {
+		o.outputFile = filepath.Join(o.exportDir, exporter.ReportMarkdownFile)
+		if o.format == formatHTML {
+			o.outputFile = filepath.Join(o.exportDir, exporter.ReportHTMLFile)
+		}
+	}
+	return nil
+}
+
+func (o *ReportOptions) Validate() error {
+	if o.format != formatMarkdown && o.format != formatHTML {
+		return fmt.Errorf("invalid format %q, must be one of: %s, %s", o.format, formatMarkdown, formatHTML)
+	}
+	return nil
+}
+
+func (o *ReportOptions) Run() error {
+	return o.run(o.globalFlags.GetLogger())
+}
+
+func NewReportCommand(streams genericclioptions.IOStreams, f *flags.GlobalFlags) *cobra.Command {
+	o := &ReportOptions{
+		IOStreams:        streams,
+		cobraGlobalFlags: f,
+		globalFlags:      f,
+	}
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Render a readable migration report of an export",
+		Long: `Render a readable migration report of an export, as Markdown or HTML.
+
+The report is rendered from the export directory alone, without access to a cluster: the summary
+of the export and of each namespace, the failures, the container images of ` + exporter.ImagesFile + `, the
+Helm releases of ` + exporter.HelmReleasesFile + `, the API versions deprecated in the target Kubernetes
+version and the references to objects that are not exported. It is written to ` + exporter.ReportMarkdownFile + ` or
+` + exporter.ReportHTMLFile + ` in the export directory unless --output-file is given.
+
+Exit codes:
+  0    the report was rendered
+  1    fatal error, like an invalid export`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			c.SilenceUsage = true
+			if err := o.Run(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+			viper.Unmarshal(o.globalFlags)
+			viper.UnmarshalKey("export-dir", &o.exportDir)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.exportDir, "export-dir", "e", "export", "The export directory to report on, as written by export")
+	cmd.Flags().StringVar(&o.format, "format", formatMarkdown, "The format of the report, one of: markdown, html")
+	cmd.Flags().StringVar(&o.outputFile, "output-file", "", "The file the report is written to, defaults to "+exporter.ReportMarkdownFile+" or "+exporter.ReportHTMLFile+" in the export directory")
+
+	return cmd
+}
+
+func (o *ReportOptions) run(log logrus.FieldLogger) error {
+	report, err := readMigrationReport(o.exportDir)
+	if err != nil {
+		return err
+	}
+	for _, path := range report.Missing {
+		log.Debugf("%s not found in %s, its section is empty", path, o.exportDir)
+	}
+	rendered, err := report.render(o.format)
+	if err != nil {
+		return fmt.Errorf("cannot render the report: %w", err)
+	}
+	if err := os.WriteFile(o.outputFile, rendered, 0600); err != nil {
+		return err
+	}
+	log.Infof("Report written to %s", o.outputFile)
+	return nil
+}
@@ -0,0 +1,123 @@
+package report
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/sirupsen/logrus"
+)
+
+func testLogger() logrus.FieldLogger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func TestReportOptions_run(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		want   []string
+	}{
+		{
+			name:   "given the markdown format, should render every section",
+			format: formatMarkdown,
+			want: []string{
+				"Export started at 2026-10-01T08:00:00Z and took 12.5s. Source cluster version: v1.27.4.",
+				"| shop | 4 | 1 | 1 | 1 |",
+				"| deployments.apps | 1 |",
+				`| shop | list | cronjobs.batch |  | Forbidden | cronjobs.batch is forbidden: User <system:serviceaccount> cannot list \| watch |`,
+				"| quay.io/shop/web:1.2 | Deployment/web in shop (web) |",
+				"| shop | shop | web 0.3.1 | 4 | deployed | 1 |",
+				"| shop | Ingress/web | extensions/v1beta1 | removed in v1.22, use networking.k8s.io/v1 |",
+				"| shop | Deployment/web | spec.template.spec.volumes[0].secret.secretName | Secret/tls |",
+				"| Deployment/web | spec.template.spec.priorityClassName | PriorityClass/high (cluster-scoped) |",
+			},
+		},
+		{
+			name:   "given the html format, should render every section escaped",
+			format: formatHTML,
+			want: []string{
+				"<td><a href=\"#namespace-shop\">shop</a></td><td>4</td><td>1</td><td>1</td><td>1</td>",
+				"<td>cronjobs.batch is forbidden: User &lt;system:serviceaccount&gt; cannot list | watch</td>",
+				"<td>quay.io/shop/web:1.2</td><td>Deployment/web in shop (web)</td>",
+				"<td>removed in v1.22, use networking.k8s.io/v1</td>",
+				"<td>PriorityClass/high (cluster-scoped)</td>",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &ReportOptions{exportDir: filepath.Join("testdata", "report"), format: tt.format, outputFile: filepath.Join(t.TempDir(), "report")}
+			if err := o.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if err := o.run(testLogger()); err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+			got, err := os.ReadFile(o.outputFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("report does not contain %q:\n%s", want, got)
+				}
+			}
+			if strings.Contains(string(got), "Not found in the export directory") {
+				t.Errorf("report of a complete export lists missing files:\n%s", got)
+			}
+		})
+	}
+}
+
+func Test_readMigrationReport(t *testing.T) {
+	exportDir := t.TempDir()
+	if _, err := readMigrationReport(exportDir); err == nil || !strings.Contains(err.Error(), exporter.SummaryJSONFile) {
+		t.Errorf("readMigrationReport() of an empty directory error = %v, want the summary not found", err)
+	}
+
+	summary, err := os.ReadFile(filepath.Join("testdata", "report", exporter.SummaryJSONFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(exportDir, exporter.SummaryJSONFile), summary, 0600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := readMigrationReport(exportDir)
+	if err != nil {
+		t.Fatalf("readMigrationReport() error = %v", err)
+	}
+	want := []string{"failures/shop/failures.json", exporter.HelmReleasesFile, exporter.ImagesFile}
+	if !reflect.DeepEqual(r.Missing, want) {
+		t.Errorf("Missing = %v, want %v", r.Missing, want)
+	}
+	rendered, err := r.render(formatMarkdown)
+	if err != nil {
+		t.Fatalf("render() error = %v", err)
+	}
+	for _, section := range []string{"No images.", "No Helm releases.", "Not found in the export directory: failures/shop/failures.json, helm-releases.json, images.json."} {
+		if !strings.Contains(string(rendered), section) {
+			t.Errorf("report does not contain %q:\n%s", section, rendered)
+		}
+	}
+}
+
+func TestReportOptions_Complete(t *testing.T) {
+	o := &ReportOptions{exportDir: "out", format: "pdf"}
+	if err := o.Validate(); err == nil {
+		t.Errorf("Validate() of the pdf format succeeded, want error")
+	}
+	o.format = formatHTML
+	if err := o.Complete(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if o.outputFile != filepath.Join("out", exporter.ReportHTMLFile) {
+		t.Errorf("default output file = %s, want %s", o.outputFile, filepath.Join("out", exporter.ReportHTMLFile))
+	}
+}
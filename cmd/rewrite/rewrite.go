@@ -0,0 +1,95 @@
+package rewrite
+
+import (
+	"context"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+type RewriteOptions struct {
+	// Two GlobalFlags struct fields are needed
+	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
+	// 2. globalFlags for the args merged with values from the viper config file
+	cobraGlobalFlags *flags.GlobalFlags
+	globalFlags      *flags.GlobalFlags
+
+	rewrite *exporter.Rewrite
+
+	genericclioptions.IOStreams
+}
+
+func (o *RewriteOptions) Complete(c *cobra.Command, args []string) error {
+	return o.rewrite.Complete()
+}
+
+func (o *RewriteOptions) Validate() error {
+	return o.rewrite.Validate()
+}
+
+func (o *RewriteOptions) Run() error {
+	return o.rewrite.Run(context.Background(), o.globalFlags.GetLogger())
+}
+
+func NewRewriteCommand(streams genericclioptions.IOStreams, f *flags.GlobalFlags) *cobra.Command {
+	o := &RewriteOptions{
+		rewrite: exporter.NewRewrite(),
+
+		IOStreams:        streams,
+		cobraGlobalFlags: f,
+		globalFlags:      f,
+	}
+	cmd := &cobra.Command{
+		Use:   "rewrite",
+		Short: "Apply the export transforms to an export, writing a new export",
+		Long: `Apply the export transforms to an export, writing a new export.
+
+An export can be taken once and rewritten for each target: the --image-map, --storageclass-map,
+--target-namespace, --strip-fields and --transform-exec flags of export are applied to the objects
+of the export in --export-dir, in the same order as export applies them, and the result is written
+to --output-dir with its own ` + index.File + ` and ` + exporter.SummaryJSONFile + `. The export in
+--export-dir is left unchanged.
+
+The rewrite applies to the objects the export transforms: the Namespace is only stripped and
+renamed, the webhook configurations only renamed, while the CRDs, the cluster dependencies and the
+Events are copied unchanged. The encrypted files are copied unchanged too, decrypt the export first
+to transform them. The flags of the rewrite are recorded in ` + exporter.SummaryJSONFile + ` with the ones of
+the export, so that diff and replay treat the rewritten export like one taken with all of them.
+
+Exit codes:
+  0    the export was rewritten
+  1    fatal error, like an invalid export
+  2    some objects could not be transformed by --transform-exec, they are left out`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			c.SilenceUsage = true
+			if err := o.Run(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+			viper.Unmarshal(o.globalFlags)
+			viper.UnmarshalKey("export-dir", &o.rewrite.ExportDir)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.rewrite.ExportDir, "export-dir", "e", "export", "The export directory to rewrite, as written by export. It is left unchanged")
+	cmd.Flags().StringVar(&o.rewrite.OutputDir, "output-dir", "", "The directory the rewritten export is written to")
+	cmd.Flags().BoolVar(&o.rewrite.Overwrite, "overwrite", false, "Replace a previous export found in the output directory. Only the content written by export is removed")
+	// the transform flags are the ones of export, with the same help
+	o.rewrite.AddFlags(cmd.Flags())
+
+	return cmd
+}
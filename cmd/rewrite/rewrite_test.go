@@ -0,0 +1,53 @@
+package rewrite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/spf13/pflag"
+)
+
+func TestRewriteOptions_Complete(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{
+			name: "given a transform flag, should pass",
+			args: []string{"--target-namespace", "bar"},
+		},
+		{
+			name: "given several transform flags, should pass",
+			args: []string{"--image-map", "quay.io=mirror.example.com/quay", "--strip-fields", "Deployment:spec.replicas"},
+		},
+		{
+			name:    "given no transform flag, should fail",
+			wantErr: true,
+		},
+		{
+			name:    "given an invalid target namespace, should fail",
+			args:    []string{"--target-namespace", "Bar"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &RewriteOptions{rewrite: exporter.NewRewrite()}
+			o.rewrite.ExportDir, o.rewrite.OutputDir = dir, filepath.Join(t.TempDir(), "rewritten")
+			flags := pflag.NewFlagSet("rewrite", pflag.ContinueOnError)
+			o.rewrite.AddFlags(flags)
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if err := o.Complete(nil, nil); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,11 +3,11 @@ package skopeo_sync_gen
 import (
 	"context"
 	"encoding/json"
-	// "fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
 	"github.com/konveyor-ecosystem/kubectl-migrate/internal/file"
 	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
 	imagev1 "github.com/openshift/api/image/v1"
@@ -119,7 +119,7 @@ func (o *Options) Run() error {
 		return err
 	}
 
-	files, err := file.ReadFiles(context.TODO(), exportDir)
+	files, err := file.ReadFiles(context.TODO(), exportDir, exporter.ReportFiles)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,420 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/preflight"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+)
+
+const outputTable = "table"
+
+// UnhealthyError is returned by status when some of the migrated objects are not healthy
+type UnhealthyError struct {
+	Failures int
+}
+
+func (e *UnhealthyError) Error() string {
+	return fmt.Sprintf("%d migrated objects are not healthy", e.Failures)
+}
+
+// healthChecks are the kinds status checks, and how: each check returns the detail printed for the
+// object and whether it is healthy
+var healthChecks = []struct {
+	resource schema.GroupVersionResource
+	kind     string
+	check    func(obj unstructured.Unstructured) (detail string, healthy bool)
+}{
+	{resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, kind: "Deployment", check: deploymentHealth},
+	{resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}, kind: "StatefulSet", check: statefulSetHealth},
+	{resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "daemonsets"}, kind: "DaemonSet", check: daemonSetHealth},
+	{resource: schema.GroupVersionResource{Version: "v1", Resource: "persistentvolumeclaims"}, kind: "PersistentVolumeClaim", check: claimHealth},
+	{resource: schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}, kind: "Job", check: jobHealth},
+	{resource: schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"}, kind: "Ingress", check: ingressHealth},
+	{resource: schema.GroupVersionResource{Group: "route.openshift.io", Version: "v1", Resource: "routes"}, kind: "Route", check: routeHealth},
+}
+
+// observed reports whether the controller observed the last generation of the object
+func observed(obj unstructured.Unstructured) bool {
+	observedGeneration, _, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	return observedGeneration >= obj.GetGeneration()
+}
+
+// specReplicas returns the replicas of a workload, 1 when unset like the API server defaults it
+func specReplicas(obj unstructured.Unstructured) int64 {
+	replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if !found {
+		return 1
+	}
+	return replicas
+}
+
+// hasCondition reports whether the object has the condition with the status, and returns its message
+func hasCondition(obj unstructured.Unstructured, conditionType, status string) (string, bool) {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == conditionType && condition["status"] == status {
+			message, _ := condition["message"].(string)
+			return message, true
+		}
+	}
+	return "", false
+}
+
+// deploymentHealth checks the rollout of a Deployment like kubectl rollout status
+func deploymentHealth(obj unstructured.Unstructured) (string, bool) {
+	if !observed(obj) {
+		return "waiting for the rollout to be observed", false
+	}
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		if condition, ok := c.(map[string]interface{}); ok && condition["type"] == "Progressing" && condition["reason"] == "ProgressDeadlineExceeded" {
+			return "the rollout exceeded its progress deadline", false
+		}
+	}
+	replicas := specReplicas(obj)
+	current, _, _ := unstructured.NestedInt64(obj.Object, "status", "replicas")
+	updated, _, _ := unstructured.NestedInt64(obj.Object, "status", "updatedReplicas")
+	available, _, _ := unstructured.NestedInt64(obj.Object, "status", "availableReplicas")
+	switch {
+	case updated < replicas:
+		return fmt.Sprintf("%d of %d replicas updated", updated, replicas), false
+	case current > updated:
+		return fmt.Sprintf("%d old replicas pending termination", current-updated), false
+	case available < updated:
+		return fmt.Sprintf("%d of %d updated replicas available", available, updated), false
+	}
+	return fmt.Sprintf("%d/%d available", available, replicas), true
+}
+
+// statefulSetHealth checks the rollout of a StatefulSet like kubectl rollout status, the replicas
+// below the partition of a rolling update are not updated
+func statefulSetHealth(obj unstructured.Unstructured) (string, bool) {
+	if !observed(obj) {
+		return "waiting for the rollout to be observed", false
+	}
+	replicas := specReplicas(obj)
+	ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+	updated, _, _ := unstructured.NestedInt64(obj.Object, "status", "updatedReplicas")
+	if ready < replicas {
+		return fmt.Sprintf("%d of %d replicas ready", ready, replicas), false
+	}
+	strategy, _, _ := unstructured.NestedString(obj.Object, "spec", "updateStrategy", "type")
+	if strategy == "OnDelete" {
+		return fmt.Sprintf("%d/%d ready", ready, replicas), true
+	}
+	if partition, found, _ := unstructured.NestedInt64(obj.Object, "spec", "updateStrategy", "rollingUpdate", "partition"); found && partition > 0 {
+		if updated < replicas-partition {
+			return fmt.Sprintf("%d of %d replicas updated", updated, replicas-partition), false
+		}
+		return fmt.Sprintf("%d/%d ready", ready, replicas), true
+	}
+	currentRevision, _, _ := unstructured.NestedString(obj.Object, "status", "currentRevision")
+	updateRevision, _, _ := unstructured.NestedString(obj.Object, "status", "updateRevision")
+	if currentRevision != updateRevision {
+		return fmt.Sprintf("%d of %d replicas updated", updated, replicas), false
+	}
+	return fmt.Sprintf("%d/%d ready", ready, replicas), true
+}
+
+// daemonSetHealth checks the rollout of a DaemonSet like kubectl rollout status
+func daemonSetHealth(obj unstructured.Unstructured) (string, bool) {
+	if !observed(obj) {
+		return "waiting for the rollout to be observed", false
+	}
+	desired, _, _ := unstructured.NestedInt64(obj.Object, "status", "desiredNumberScheduled")
+	updated, _, _ := unstructured.NestedInt64(obj.Object, "status", "updatedNumberScheduled")
+	available, _, _ := unstructured.NestedInt64(obj.Object, "status", "numberAvailable")
+	switch {
+	case updated < desired:
+		return fmt.Sprintf("%d of %d pods updated", updated, desired), false
+	case available < desired:
+		return fmt.Sprintf("%d of %d pods available", available, desired), false
+	}
+	return fmt.Sprintf("%d/%d available", available, desired), true
+}
+
+func claimHealth(obj unstructured.Unstructured) (string, bool) {
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	if phase != "Bound" {
+		if phase == "" {
+			phase = "Pending"
+		}
+		return phase, false
+	}
+	volume, _, _ := unstructured.NestedString(obj.Object, "spec", "volumeName")
+	return "bound to " + volume, true
+}
+
+func jobHealth(obj unstructured.Unstructured) (string, bool) {
+	if message, failed := hasCondition(obj, "Failed", "True"); failed {
+		return "failed: " + message, false
+	}
+	if _, complete := hasCondition(obj, "Complete", "True"); complete {
+		return "complete", true
+	}
+	return "not complete", false
+}
+
+// ingressHealth checks that an ingress controller admitted the Ingress and published its address
+func ingressHealth(obj unstructured.Unstructured) (string, bool) {
+	addresses, _, _ := unstructured.NestedSlice(obj.Object, "status", "loadBalancer", "ingress")
+	for _, a := range addresses {
+		address, ok := a.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, field := range []string{"hostname", "ip"} {
+			if value, _ := address[field].(string); value != "" {
+				return "admitted at " + value, true
+			}
+		}
+	}
+	return "no address, not admitted by an ingress controller", false
+}
+
+// routeHealth checks that every router the Route was submitted to admitted it
+func routeHealth(obj unstructured.Unstructured) (string, bool) {
+	ingresses, _, _ := unstructured.NestedSlice(obj.Object, "status", "ingress")
+	admitted := 0
+	for _, i := range ingresses {
+		ingress, ok := i.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		router := unstructured.Unstructured{Object: map[string]interface{}{"status": map[string]interface{}{"conditions": ingress["conditions"]}}}
+		if message, rejected := hasCondition(router, "Admitted", "False"); rejected {
+			return fmt.Sprintf("rejected by router %v: %s", ingress["routerName"], message), false
+		}
+		if _, ok := hasCondition(router, "Admitted", "True"); ok {
+			admitted++
+		}
+	}
+	if admitted == 0 {
+		return "not admitted by a router", false
+	}
+	host, _, _ := unstructured.NestedString(obj.Object, "spec", "host")
+	return "admitted at " + host, true
+}
+
+// healthChecker checks the objects of the target namespaces labeled managedLabel, the ones applied
+// by replay
+type healthChecker struct {
+	client dynamic.Interface
+	log    logrus.FieldLogger
+}
+
+// check returns a result per checked object. A kind the target cluster does not serve, like the
+// Routes outside of OpenShift, is skipped.
+func (h *healthChecker) check(ctx context.Context, namespaces []string) []preflight.Result {
+	results := []preflight.Result{}
+	selector := metav1.ListOptions{LabelSelector: exporter.ManagedLabel + "=true"}
+	for _, namespace := range namespaces {
+		checked := 0
+		for _, hc := range healthChecks {
+			objects, err := h.client.Resource(hc.resource).Namespace(namespace).List(ctx, selector)
+			switch {
+			case apierrors.IsNotFound(err):
+				h.log.Debugf("%s are not served, skipping them", hc.resource.Resource)
+				continue
+			case err != nil:
+				results = append(results, preflight.Result{Check: hc.kind + " " + namespace, Status: preflight.StatusFail, Detail: "cannot list: " + err.Error()})
+				continue
+			}
+			for _, obj := range objects.Items {
+				detail, healthy := hc.check(obj)
+				result := preflight.Result{Check: fmt.Sprintf("%s %s/%s", hc.kind, namespace, obj.GetName()), Status: preflight.StatusPass, Detail: detail}
+				if !healthy {
+					result.Status = preflight.StatusFail
+				}
+				results = append(results, result)
+				checked++
+			}
+		}
+		if checked == 0 {
+			results = append(results, preflight.Result{Check: "namespace " + namespace, Status: preflight.StatusWarn, Detail: "no object labeled " + exporter.ManagedLabel + "=true to check"})
+		}
+	}
+	return results
+}
+
+// wait checks the objects until they are all healthy or the timeout expires, and returns the last
+// results
+func (h *healthChecker) wait(ctx context.Context, namespaces []string, interval, timeout time.Duration) []preflight.Result {
+	var results []preflight.Result
+	wait.PollUntilContextTimeout(ctx, interval, timeout, true, func(ctx context.Context) (bool, error) {
+		results = h.check(ctx, namespaces)
+		failures := countFailures(results)
+		if failures > 0 {
+			h.log.Infof("waiting for %d objects to become healthy", failures)
+		}
+		return failures == 0, nil
+	})
+	return results
+}
+
+func countFailures(results []preflight.Result) int {
+	failures := 0
+	for _, r := range results {
+		if r.Status == preflight.StatusFail {
+			failures++
+		}
+	}
+	return failures
+}
+
+// printStatus prints the results as a table, or as JSON
+func printStatus(out io.Writer, results []preflight.Result, output string) error {
+	if output != exporter.OutputJSON {
+		preflight.Print(out, results)
+		return nil
+	}
+	resultBytes, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s\n", resultBytes)
+	return err
+}
+
+type StatusOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+
+	// Two GlobalFlags struct fields are needed
+	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
+	// 2. globalFlags for the args merged with values from the viper config file
+	cobraGlobalFlags *flags.GlobalFlags
+	globalFlags      *flags.GlobalFlags
+
+	namespaces []string
+	wait       bool
+	timeout    time.Duration
+	output     string
+
+	genericclioptions.IOStreams
+}
+
+func (o *StatusOptions) Complete(c *cobra.Command, args []string) error {
+	o.namespaces = exporter.UniqueNamespaces(o.namespaces)
+	if len(o.namespaces) == 0 {
+		namespace, _, err := o.configFlags.ToRawKubeConfigLoader().Namespace()
+		if err != nil {
+			return err
+		}
+		o.namespaces = []string{namespace}
+	}
+	return nil
+}
+
+func (o *StatusOptions) Validate() error {
+	if o.output != outputTable && o.output != exporter.OutputJSON {
+		return fmt.Errorf("invalid output format %q, must be one of: %s, %s", o.output, outputTable, exporter.OutputJSON)
+	}
+	if o.timeout <= 0 {
+		return fmt.Errorf("--timeout must be positive")
+	}
+	return nil
+}
+
+func (o *StatusOptions) Run() error {
+	return o.run(context.Background(), o.globalFlags.GetLogger())
+}
+
+func NewStatusCommand(streams genericclioptions.IOStreams, f *flags.GlobalFlags) *cobra.Command {
+	o := &StatusOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+
+		IOStreams:        streams,
+		cobraGlobalFlags: f,
+		globalFlags:      f,
+	}
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Check the health of the migrated objects on the target cluster",
+		Long: `Check the health of the migrated objects on the target cluster.
+
+The objects of the namespaces labeled ` + exporter.ManagedLabel + `=true, the ones applied by replay, are
+checked: the rollout of the Deployments, StatefulSets and DaemonSets is complete like with kubectl
+rollout status, the PersistentVolumeClaims are bound, the Jobs are complete, the Ingresses have an
+address and the Routes are admitted. With --wait, the checks are run again until they all pass or
+--timeout expires. The checks are printed as a table, or as JSON with --output json.
+
+Exit codes:
+  0    every migrated object is healthy
+  1    fatal error, like an unreachable cluster
+  3    some objects are not healthy`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			c.SilenceUsage = true
+			if err := o.Run(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+			viper.Unmarshal(o.globalFlags)
+			viper.Unmarshal(o.configFlags)
+		},
+	}
+
+	cmd.Flags().StringSliceVarP(&o.namespaces, "namespace", "n", nil, "The target namespace to check, defaults to the namespace of the current context. Can be repeated or comma-separated")
+	cmd.Flags().BoolVar(&o.wait, "wait", false, "Check again until every object is healthy or --timeout expires")
+	cmd.Flags().DurationVar(&o.timeout, "timeout", 5*time.Minute, "How long to wait with --wait")
+	cmd.Flags().StringVarP(&o.output, "output", "o", outputTable, "The output format, one of: table, json")
+	// the namespace flag is registered above so it can take several values
+	o.configFlags.Namespace = nil
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func (o *StatusOptions) run(ctx context.Context, log logrus.FieldLogger) error {
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return fmt.Errorf("cannot create rest config: %w", err)
+	}
+	client, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("cannot create dynamic client: %w", err)
+	}
+	return o.status(ctx, &healthChecker{client: client, log: log}, 5*time.Second)
+}
+
+func (o *StatusOptions) status(ctx context.Context, h *healthChecker, interval time.Duration) error {
+	var results []preflight.Result
+	if o.wait {
+		results = h.wait(ctx, o.namespaces, interval, o.timeout)
+	} else {
+		results = h.check(ctx, o.namespaces)
+	}
+	if err := printStatus(o.Out, results, o.output); err != nil {
+		return err
+	}
+	if failures := countFailures(results); failures > 0 {
+		return &UnhealthyError{Failures: failures}
+	}
+	return nil
+}
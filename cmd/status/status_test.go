@@ -0,0 +1,264 @@
+package status
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/preflight"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func testLogger() logrus.FieldLogger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func testReplayObject(apiVersion, kind, namespace, name string) unstructured.Unstructured {
+	obj := unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+func Test_healthChecks(t *testing.T) {
+	object := func(generation int64, spec, status map[string]interface{}) unstructured.Unstructured {
+		obj := unstructured.Unstructured{Object: map[string]interface{}{"spec": spec, "status": status}}
+		obj.SetGeneration(generation)
+		return obj
+	}
+	condition := func(conditionType, status string) map[string]interface{} {
+		return map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"type": conditionType, "status": status, "message": "backoff limit"}}}
+	}
+	tests := []struct {
+		name        string
+		check       func(unstructured.Unstructured) (string, bool)
+		obj         unstructured.Unstructured
+		wantDetail  string
+		wantHealthy bool
+	}{
+		{
+			name:        "given a rolled out Deployment, should pass",
+			check:       deploymentHealth,
+			obj:         object(2, map[string]interface{}{"replicas": int64(3)}, map[string]interface{}{"observedGeneration": int64(2), "replicas": int64(3), "updatedReplicas": int64(3), "availableReplicas": int64(3)}),
+			wantDetail:  "3/3 available",
+			wantHealthy: true,
+		},
+		{
+			name:       "given a Deployment generation not observed, should fail",
+			check:      deploymentHealth,
+			obj:        object(3, map[string]interface{}{"replicas": int64(3)}, map[string]interface{}{"observedGeneration": int64(2), "replicas": int64(3), "updatedReplicas": int64(3), "availableReplicas": int64(3)}),
+			wantDetail: "waiting for the rollout to be observed",
+		},
+		{
+			name:       "given a Deployment with old replicas, should fail",
+			check:      deploymentHealth,
+			obj:        object(1, map[string]interface{}{"replicas": int64(2)}, map[string]interface{}{"observedGeneration": int64(1), "replicas": int64(3), "updatedReplicas": int64(2), "availableReplicas": int64(2)}),
+			wantDetail: "1 old replicas pending termination",
+		},
+		{
+			name:       "given a Deployment without replicas available, should fail",
+			check:      deploymentHealth,
+			obj:        object(1, map[string]interface{}{}, map[string]interface{}{"observedGeneration": int64(1), "replicas": int64(1), "updatedReplicas": int64(1)}),
+			wantDetail: "0 of 1 updated replicas available",
+		},
+		{
+			name:        "given a StatefulSet ready at the update revision, should pass",
+			check:       statefulSetHealth,
+			obj:         object(1, map[string]interface{}{"replicas": int64(2)}, map[string]interface{}{"observedGeneration": int64(1), "readyReplicas": int64(2), "updatedReplicas": int64(2), "currentRevision": "db-1", "updateRevision": "db-1"}),
+			wantDetail:  "2/2 ready",
+			wantHealthy: true,
+		},
+		{
+			name:       "given a StatefulSet being updated, should fail",
+			check:      statefulSetHealth,
+			obj:        object(1, map[string]interface{}{"replicas": int64(2)}, map[string]interface{}{"observedGeneration": int64(1), "readyReplicas": int64(2), "updatedReplicas": int64(1), "currentRevision": "db-1", "updateRevision": "db-2"}),
+			wantDetail: "1 of 2 replicas updated",
+		},
+		{
+			name:       "given a DaemonSet with pods unavailable, should fail",
+			check:      daemonSetHealth,
+			obj:        object(1, nil, map[string]interface{}{"observedGeneration": int64(1), "desiredNumberScheduled": int64(3), "updatedNumberScheduled": int64(3), "numberAvailable": int64(2)}),
+			wantDetail: "2 of 3 pods available",
+		},
+		{
+			name:        "given a bound claim, should pass",
+			check:       claimHealth,
+			obj:         object(0, map[string]interface{}{"volumeName": "pvc-abc"}, map[string]interface{}{"phase": "Bound"}),
+			wantDetail:  "bound to pvc-abc",
+			wantHealthy: true,
+		},
+		{
+			name:       "given a pending claim, should fail",
+			check:      claimHealth,
+			obj:        object(0, nil, map[string]interface{}{"phase": "Pending"}),
+			wantDetail: "Pending",
+		},
+		{
+			name:        "given a complete Job, should pass",
+			check:       jobHealth,
+			obj:         object(0, nil, condition("Complete", "True")),
+			wantDetail:  "complete",
+			wantHealthy: true,
+		},
+		{
+			name:       "given a failed Job, should fail with the message",
+			check:      jobHealth,
+			obj:        object(0, nil, condition("Failed", "True")),
+			wantDetail: "failed: backoff limit",
+		},
+		{
+			name:        "given an Ingress with an address, should pass",
+			check:       ingressHealth,
+			obj:         object(0, nil, map[string]interface{}{"loadBalancer": map[string]interface{}{"ingress": []interface{}{map[string]interface{}{"ip": "10.0.0.1"}}}}),
+			wantDetail:  "admitted at 10.0.0.1",
+			wantHealthy: true,
+		},
+		{
+			name:       "given an Ingress without address, should fail",
+			check:      ingressHealth,
+			obj:        object(0, nil, map[string]interface{}{"loadBalancer": map[string]interface{}{}}),
+			wantDetail: "no address, not admitted by an ingress controller",
+		},
+		{
+			name:        "given an admitted Route, should pass",
+			check:       routeHealth,
+			obj:         object(0, map[string]interface{}{"host": "web.apps.example.com"}, map[string]interface{}{"ingress": []interface{}{map[string]interface{}{"routerName": "default", "conditions": condition("Admitted", "True")["conditions"]}}}),
+			wantDetail:  "admitted at web.apps.example.com",
+			wantHealthy: true,
+		},
+		{
+			name:       "given a rejected Route, should fail",
+			check:      routeHealth,
+			obj:        object(0, nil, map[string]interface{}{"ingress": []interface{}{map[string]interface{}{"routerName": "default", "conditions": condition("Admitted", "False")["conditions"]}}}),
+			wantDetail: "rejected by router default: backoff limit",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detail, healthy := tt.check(tt.obj)
+			if detail != tt.wantDetail || healthy != tt.wantHealthy {
+				t.Errorf("check() = %q, %v, want %q, %v", detail, healthy, tt.wantDetail, tt.wantHealthy)
+			}
+		})
+	}
+}
+
+func TestStatusOptions_status(t *testing.T) {
+	object := func(apiVersion, kind, namespace, name string, managed bool, status map[string]interface{}) *unstructured.Unstructured {
+		obj := testReplayObject(apiVersion, kind, namespace, name)
+		if managed {
+			obj.SetLabels(map[string]string{exporter.ManagedLabel: "true"})
+		}
+		obj.Object["status"] = status
+		return &obj
+	}
+	listKinds := map[schema.GroupVersionResource]string{}
+	for _, hc := range healthChecks {
+		listKinds[hc.resource] = hc.kind + "List"
+	}
+	newClient := func(claimPhase string) *dynamicfake.FakeDynamicClient {
+		return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds,
+			object("apps/v1", "Deployment", "foo", "web", true, map[string]interface{}{"replicas": int64(1), "updatedReplicas": int64(1), "availableReplicas": int64(1)}),
+			object("v1", "PersistentVolumeClaim", "foo", "data", true, map[string]interface{}{"phase": claimPhase}),
+			object("v1", "PersistentVolumeClaim", "foo", "unmanaged", false, map[string]interface{}{"phase": "Pending"}),
+		)
+	}
+	tests := []struct {
+		name       string
+		namespaces []string
+		claimPhase string
+		want       []preflight.Result
+		wantErr    bool
+	}{
+		{
+			name:       "given healthy objects, should pass",
+			namespaces: []string{"foo"},
+			claimPhase: "Bound",
+			want: []preflight.Result{
+				{Check: "Deployment foo/web", Status: preflight.StatusPass, Detail: "1/1 available"},
+				{Check: "PersistentVolumeClaim foo/data", Status: preflight.StatusPass, Detail: "bound to "},
+			},
+		},
+		{
+			name:       "given a pending claim, should fail",
+			namespaces: []string{"foo"},
+			claimPhase: "Pending",
+			want: []preflight.Result{
+				{Check: "Deployment foo/web", Status: preflight.StatusPass, Detail: "1/1 available"},
+				{Check: "PersistentVolumeClaim foo/data", Status: preflight.StatusFail, Detail: "Pending"},
+			},
+			wantErr: true,
+		},
+		{
+			name:       "given a namespace without managed objects, should warn",
+			namespaces: []string{"bar"},
+			want: []preflight.Result{
+				{Check: "namespace bar", Status: preflight.StatusWarn, Detail: "no object labeled " + exporter.ManagedLabel + "=true to check"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			o := &StatusOptions{namespaces: tt.namespaces, output: exporter.OutputJSON, timeout: time.Second}
+			o.Out = out
+			err := o.status(context.Background(), &healthChecker{client: newClient(tt.claimPhase), log: testLogger()}, time.Millisecond)
+			var unhealthy *UnhealthyError
+			if (err != nil) != tt.wantErr || err != nil && !errors.As(err, &unhealthy) {
+				t.Fatalf("status() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got := []preflight.Result{}
+			if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON output %q: %v", out.String(), err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("status() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_healthChecker_wait(t *testing.T) {
+	claim := testReplayObject("v1", "PersistentVolumeClaim", "foo", "data")
+	claim.SetLabels(map[string]string{exporter.ManagedLabel: "true"})
+	listKinds := map[schema.GroupVersionResource]string{}
+	for _, hc := range healthChecks {
+		listKinds[hc.resource] = hc.kind + "List"
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, &claim)
+	h := &healthChecker{client: client, log: testLogger()}
+
+	results := h.wait(context.Background(), []string{"foo"}, time.Millisecond, 50*time.Millisecond)
+	if countFailures(results) != 1 {
+		t.Errorf("wait() for a claim never bound = %+v, want it failed after the timeout", results)
+	}
+
+	// the claim is bound while waiting
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		bound := claim.DeepCopy()
+		bound.Object["status"] = map[string]interface{}{"phase": "Bound"}
+		if _, err := client.Resource(schema.GroupVersionResource{Version: "v1", Resource: "persistentvolumeclaims"}).Namespace("foo").UpdateStatus(context.Background(), bound, metav1.UpdateOptions{}); err != nil {
+			t.Error(err)
+		}
+	}()
+	results = h.wait(context.Background(), []string{"foo"}, 5*time.Millisecond, 5*time.Second)
+	if countFailures(results) != 0 {
+		t.Errorf("wait() for a claim bound while waiting = %+v, want it passed", results)
+	}
+}
@@ -79,6 +79,9 @@ func (r *rsyncLogStream) Init() error {
 	}
 
 	r.progress = NewProgress(r.pvc)
+	// the cumulative progress of the retries and the failed files are of one transfer
+	pastAttempts = Progress{}
+	failedFiles = nil
 	var lastProgress *Progress
 
 	r.wg.Add(1)
@@ -183,6 +186,10 @@ func (r *rsyncLogStream) Streams() (stdout chan string, stderr chan string, err
 	return r.stdout, r.stderr, r.err
 }
 
+func (r *rsyncLogStream) Progress() *Progress {
+	return r.progress
+}
+
 // Progress defines transfer Progress
 type Progress struct {
 	PVC                types.NamespacedName `json:"pvc"`
@@ -194,8 +201,11 @@ type Progress struct {
 	ExitCode           *int32               `json:"exitCode"`
 	FailedFiles        []FailedFile         `json:"failedFiles"`
 	Errors             []string             `json:"miscErrors"`
-	retries            *int
-	startedAt          time.Time
+	// Source and Destination count the files of the volumes once the data is sent, when compared
+	Source      *VolumeStats `json:"source,omitempty"`
+	Destination *VolumeStats `json:"destination,omitempty"`
+	retries     *int
+	startedAt   time.Time
 }
 
 // pastAttempts stores cumulative progress info
@@ -0,0 +1,120 @@
+package transfer_pvc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultVolumeStatsImage runs the Pods counting the files of the volumes when no destination
+// image is given, it is the default image of the rsync daemon
+const defaultVolumeStatsImage = "quay.io/konveyor/rsync-transfer:latest"
+
+// volumeStatsScript prints the number of regular files under /data and the sum of their sizes.
+// The directories are not counted, their size depends on the file system.
+const volumeStatsScript = `find /data -xdev -type f -printf '%s\n' | awk '{ files++; bytes += $1 } END { printf "%d %d\n", files, bytes }'`
+
+// VolumeStats counts the regular files of a volume and their bytes
+type VolumeStats struct {
+	Files int64 `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+func (s VolumeStats) String() string {
+	return fmt.Sprintf("%d files of %d bytes", s.Files, s.Bytes)
+}
+
+// parseVolumeStats parses the output of volumeStatsScript
+func parseVolumeStats(out string) (VolumeStats, error) {
+	fields := strings.Fields(out)
+	if len(fields) != 2 {
+		return VolumeStats{}, fmt.Errorf("unexpected volume stats %q", strings.TrimSpace(out))
+	}
+	files, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return VolumeStats{}, fmt.Errorf("unexpected volume stats %q: %w", strings.TrimSpace(out), err)
+	}
+	bytes, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return VolumeStats{}, fmt.Errorf("unexpected volume stats %q: %w", strings.TrimSpace(out), err)
+	}
+	return VolumeStats{Files: files, Bytes: bytes}, nil
+}
+
+// countVolume counts the files of the PVC in a Pod mounting it read-only, on the node when given.
+// The Pod carries the labels of the transfer, so it is garbage collected with the other resources
+// of the transfer if it cannot be deleted here.
+func countVolume(ctx context.Context, c client.Client, cfg *rest.Config, pvc *corev1.PersistentVolumeClaim, nodeName, image string, labels map[string]string, secCtx *corev1.PodSecurityContext) (VolumeStats, error) {
+	trueBool := bool(true)
+	falseBool := bool(false)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "volume-stats-",
+			Namespace:    pvc.Namespace,
+			Labels:       labels,
+		},
+		Spec: corev1.PodSpec{
+			NodeName:      nodeName,
+			RestartPolicy: corev1.RestartPolicyNever,
+			SecurityContext: &corev1.PodSecurityContext{
+				FSGroup: secCtx.FSGroup,
+			},
+			Containers: []corev1.Container{{
+				Name:    "stats",
+				Image:   image,
+				Command: []string{"/bin/sh", "-c", volumeStatsScript},
+				SecurityContext: &corev1.SecurityContext{
+					Capabilities: &corev1.Capabilities{
+						Drop: []corev1.Capability{"ALL"},
+					},
+					RunAsNonRoot:             &trueBool,
+					RunAsUser:                secCtx.RunAsUser,
+					AllowPrivilegeEscalation: &falseBool,
+				},
+				VolumeMounts: []corev1.VolumeMount{{Name: "data", MountPath: "/data", ReadOnly: true}},
+			}},
+			Volumes: []corev1.Volume{{
+				Name: "data",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvc.Name, ReadOnly: true},
+				},
+			}},
+		},
+	}
+	if err := c.Create(ctx, pod); err != nil {
+		return VolumeStats{}, err
+	}
+	defer c.Delete(context.TODO(), pod, client.PropagationPolicy(metav1.DeletePropagationBackground))
+
+	err := wait.PollUntilContextTimeout(ctx, 2*time.Second, 30*time.Minute, true, func(ctx context.Context) (bool, error) {
+		if err := c.Get(ctx, client.ObjectKeyFromObject(pod), pod); err != nil {
+			return false, err
+		}
+		return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed, nil
+	})
+	if err != nil {
+		return VolumeStats{}, fmt.Errorf("pod %s did not complete: %w", pod.Name, err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return VolumeStats{}, err
+	}
+	logs, err := clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{Container: "stats"}).DoRaw(ctx)
+	if err != nil {
+		return VolumeStats{}, err
+	}
+	if pod.Status.Phase == corev1.PodFailed {
+		return VolumeStats{}, fmt.Errorf("pod %s failed: %s", pod.Name, strings.TrimSpace(string(logs)))
+	}
+	return parseVolumeStats(string(logs))
+}
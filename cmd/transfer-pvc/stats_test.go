@@ -0,0 +1,47 @@
+package transfer_pvc
+
+import (
+	"testing"
+)
+
+func Test_parseVolumeStats(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     string
+		want    VolumeStats
+		wantErr bool
+	}{
+		{
+			name: "given the files and bytes, should return them",
+			out:  "1204 73400320\n",
+			want: VolumeStats{Files: 1204, Bytes: 73400320},
+		},
+		{
+			name: "given an empty volume, should return zeros",
+			out:  "0 0\n",
+			want: VolumeStats{},
+		},
+		{
+			name:    "given an error message, should return error",
+			out:     "find: '/data/lost+found': Permission denied\n",
+			wantErr: true,
+		},
+		{
+			name:    "given no output, should return error",
+			out:     "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseVolumeStats(tt.out)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseVolumeStats() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseVolumeStats() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
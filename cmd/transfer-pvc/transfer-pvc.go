@@ -12,7 +12,6 @@ import (
 	"strings"
 	"time"
 
-	logrusr "github.com/bombsimon/logrusr/v3"
 	"github.com/go-logr/logr"
 	configv1 "github.com/openshift/api/config/v1"
 	routev1 "github.com/openshift/api/route/v1"
@@ -20,7 +19,6 @@ import (
 	"github.com/spf13/cobra"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -36,10 +34,7 @@ import (
 	"github.com/backube/pvc-transfer/endpoint"
 	ingressendpoint "github.com/backube/pvc-transfer/endpoint/ingress"
 	routeendpoint "github.com/backube/pvc-transfer/endpoint/route"
-	"github.com/backube/pvc-transfer/transfer"
 	rsynctransfer "github.com/backube/pvc-transfer/transfer/rsync"
-	"github.com/backube/pvc-transfer/transport"
-	stunneltransport "github.com/backube/pvc-transfer/transport/stunnel"
 	securityv1 "github.com/openshift/api/security/v1"
 	openshiftuid "github.com/openshift/library-go/pkg/security/uid"
 )
@@ -258,13 +253,16 @@ func (t *TransferPVCCommand) getClientFromContext(ctx string) (client.Client, er
 	if err != nil {
 		return nil, err
 	}
+	return newClient(restConfig, t.Endpoint.Type)
+}
 
-	err = routev1.Install(scheme.Scheme)
+func newClient(restConfig *rest.Config, endpoint endpointType) (client.Client, error) {
+	err := routev1.Install(scheme.Scheme)
 	if err != nil {
 		return nil, err
 	}
 
-	if t.Endpoint.Type == endpointRoute {
+	if endpoint == endpointRoute {
 		err = configv1.AddToScheme(scheme.Scheme)
 		if err != nil {
 			return nil, err
@@ -282,25 +280,19 @@ func (t *TransferPVCCommand) getRestConfigFromContext(ctx string) (*rest.Config,
 }
 
 func (t *TransferPVCCommand) run() error {
-	logrusLog := logrus.New()
-	logrusLog.SetFormatter(&logrus.JSONFormatter{})
-	logger := logrusr.New(logrusLog).WithName("transfer-pvc")
-
 	srcCfg, err := t.getRestConfigFromContext(t.Flags.SourceContext)
 	if err != nil {
-		log.Fatal(err, "unable to get source rest config")
+		return fmt.Errorf("unable to get source rest config: %w", err)
 	}
-
-	srcClient, err := t.getClientFromContext(t.Flags.SourceContext)
+	destCfg, err := t.getRestConfigFromContext(t.Flags.DestinationContext)
 	if err != nil {
-		log.Fatal(err, "unable to get source client")
+		return fmt.Errorf("unable to get destination rest config: %w", err)
 	}
-	destClient, err := t.getClientFromContext(t.Flags.DestinationContext)
+	srcClient, err := newClient(srcCfg, t.Endpoint.Type)
 	if err != nil {
-		log.Fatal(err, "unable to get destination client")
+		return fmt.Errorf("unable to get source client: %w", err)
 	}
 
-	// set up the PVC on destination to receive the data
 	srcPVC := &corev1.PersistentVolumeClaim{}
 	err = srcClient.Get(
 		context.TODO(),
@@ -311,188 +303,22 @@ func (t *TransferPVCCommand) run() error {
 		srcPVC,
 	)
 	if err != nil {
-		log.Fatal(err, "unable to get source PVC")
-	}
-
-	destPVC := t.buildDestinationPVC(srcPVC)
-	err = destClient.Create(context.TODO(), destPVC, &client.CreateOptions{})
-	if err != nil && !errors.IsAlreadyExists(err) {
-		log.Fatal(err, "unable to create destination PVC")
-	}
-
-	labels := map[string]string{
-		"app.kubernetes.io/name":          "crane",
-		"app.kubernetes.io/component":     "transfer-pvc",
-		"app.konveyor.io/created-for-pvc": getValidatedResourceName(srcPVC.Name),
-	}
-
-	e, err := createEndpoint(t.Endpoint, destPVC, labels, logger, destClient)
-	if err != nil {
-		log.Fatal(err, "failed creating endpoint")
+		return fmt.Errorf("unable to get source PVC: %w", err)
 	}
 
-	if err := waitForEndpoint(e, destClient); err != nil {
-		log.Fatal("endpoint not healthy")
+	transfer := &Transfer{
+		SourceConfig:      srcCfg,
+		DestinationConfig: destCfg,
+		Source:            srcPVC,
+		Destination:       t.buildDestinationPVC(srcPVC),
+		Endpoint:          t.Endpoint,
+		SourceImage:       t.Flags.SourceImage,
+		DestinationImage:  t.Flags.DestinationImage,
+		Verify:            t.Verify,
+		ProgressOutput:    t.ProgressOutput,
 	}
-
-	stunnelServer, err := stunneltransport.NewServer(
-		context.TODO(),
-		destClient,
-		logger,
-		types.NamespacedName{
-			Name:      getValidatedResourceName(destPVC.Name),
-			Namespace: destPVC.Namespace,
-		}, e, &transport.Options{
-			Labels: labels,
-			Image:  t.Flags.DestinationImage,
-		})
-	if err != nil {
-		log.Fatal(err, "error creating stunnel server")
-	}
-
-	secretList := &corev1.SecretList{}
-	err = destClient.List(
-		context.TODO(),
-		secretList,
-		client.InNamespace(destPVC.Namespace),
-		client.MatchingLabels(labels))
-	if err != nil {
-		log.Fatal(err, "failed to find certificate secrets")
-	}
-
-	for i := range secretList.Items {
-		destSecret := &secretList.Items[i]
-		srcSecret := &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:        destSecret.Name,
-				Namespace:   srcPVC.Namespace,
-				Labels:      destSecret.Labels,
-				Annotations: destSecret.Annotations,
-			},
-			StringData: destSecret.StringData,
-			Data:       destSecret.Data,
-		}
-		err = srcClient.Create(context.TODO(), srcSecret)
-		if err != nil {
-			log.Fatal(err, "failed to create certificate secret on source cluster")
-		}
-	}
-
-	stunnelClient, err := stunneltransport.NewClient(
-		context.TODO(),
-		srcClient,
-		logger,
-		types.NamespacedName{
-			Name:      getValidatedResourceName(srcPVC.Name),
-			Namespace: srcPVC.Namespace,
-		}, e.Hostname(), e.IngressPort(), &transport.Options{
-			Labels: labels,
-			Image:  t.Flags.DestinationImage, // Coderabbit suggest SourceImage, keep it for later once we know this better
-		},
-	)
-	if err != nil {
-		log.Fatal(err, "error creating stunnel server")
-	}
-
-	destPVCList := transfer.NewSingletonPVC(destPVC)
-	srcPVCList := transfer.NewSingletonPVC(srcPVC)
-
-	rsyncPassword := getRsyncPassword()
-
-	serverPodSecContext, err := getRsyncServerPodSecurityContext(destClient, destPVC.Namespace)
-	if err != nil {
-		log.Fatal(err, "error creating security context for rsync server")
-	}
-
-	trueBool := bool(true)
-	falseBool := bool(false)
-	rsyncServer, err := rsynctransfer.NewServer(
-		context.TODO(),
-		destClient,
-		logger, destPVCList, stunnelServer, e, labels, nil, rsyncPassword,
-		transfer.PodOptions{
-			ContainerSecurityContext: corev1.SecurityContext{
-				Capabilities: &corev1.Capabilities{
-					Drop: []corev1.Capability{"ALL"},
-				},
-				RunAsNonRoot:             &trueBool,
-				AllowPrivilegeEscalation: &falseBool,
-				SeccompProfile: &corev1.SeccompProfile{
-					Type: corev1.SeccompProfileTypeRuntimeDefault,
-				},
-			},
-			PodSecurityContext: corev1.PodSecurityContext{
-				FSGroup: serverPodSecContext.FSGroup,
-			},
-			Image: t.Flags.DestinationImage,
-		},
-	)
-	if err != nil {
-		log.Fatal(err, "error creating rsync transfer server")
-	}
-
-	// Create a context with timeout to prevent indefinite hanging
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-
-	err = wait.PollUntilContextCancel(ctx, time.Second*5, true, func(ctx context.Context) (done bool, err error) {
-		ready, err := rsyncServer.IsHealthy(ctx, destClient)
-		if err != nil {
-			log.Println(err, "unable to check rsync server health, retrying...")
-			return false, nil
-		}
-		return ready, nil
-	})
-	if err != nil {
-		log.Fatal(err, "rsync server failed to become healthy")
-	}
-
-	nodeName, err := getNodeNameForPVC(srcClient, srcPVC.Namespace, srcPVC.Name)
-	if err != nil {
-		log.Fatal(err, "failed to find node name - the PVC must be mounted by a running pod for transfer to work. Please ensure a pod is using this PVC and is in Running state")
-	}
-
-	clientPodSecCtx, err := getRsyncClientPodSecurityContext(srcClient, srcPVC.Namespace)
-	if err != nil {
-		log.Fatal(err, "error creating security context for rsync server")
-	}
-
-	_, err = rsynctransfer.NewClient(
-		context.TODO(),
-		srcClient, srcPVCList, stunnelClient, e, logger, "rsync-client", labels, nil, rsyncPassword,
-		transfer.PodOptions{
-			NodeName: nodeName,
-			CommandOptions: rsynctransfer.NewDefaultOptionsFrom(
-				verify(t.Verify),
-				restrictedContainers(true),
-				verbose(true),
-			),
-			ContainerSecurityContext: corev1.SecurityContext{
-				Privileged: &falseBool,
-				Capabilities: &corev1.Capabilities{
-					Drop: []corev1.Capability{"ALL"},
-				},
-				RunAsNonRoot:             &trueBool,
-				RunAsUser:                clientPodSecCtx.RunAsUser,
-				AllowPrivilegeEscalation: &falseBool,
-			},
-			PodSecurityContext: corev1.PodSecurityContext{
-				FSGroup: clientPodSecCtx.FSGroup,
-			},
-			Image: t.Flags.SourceImage,
-		},
-	)
-	if err != nil {
-		log.Fatal(err, "failed to create rsync client")
-	}
-
-	err = followClientLogs(
-		srcCfg, types.NamespacedName{Name: srcPVC.Name, Namespace: srcPVC.Namespace}, labels, t.ProgressOutput)
-	if err != nil {
-		log.Fatal(err, "error following rsync client logs")
-	}
-
-	return garbageCollect(srcClient, destClient, labels, t.Endpoint.Type, t.PVC.Namespace)
+	_, err = transfer.Run(context.TODO())
+	return err
 }
 
 // getValidatedResourceName returns a name for resources
@@ -506,7 +332,7 @@ func getValidatedResourceName(name string) string {
 }
 
 // getNodeNameForPVC returns name of the node on which the PVC is currently mounted on
-// returns name of the node as a string, and an error
+// returns name of the node as a string, empty when no running pod mounts the PVC, and an error
 func getNodeNameForPVC(srcClient client.Client, namespace string, pvcName string) (string, error) {
 	podList := corev1.PodList{}
 	err := srcClient.List(context.TODO(), &podList, client.InNamespace(namespace))
@@ -524,7 +350,7 @@ func getNodeNameForPVC(srcClient client.Client, namespace string, pvcName string
 			}
 		}
 	}
-	return "", fmt.Errorf("PVC %s in namespace %s is not mounted on any running pod", pvcName, namespace)
+	return "", nil
 }
 
 // getRsyncPassword returns a cryptographically secure random password for rsync
@@ -680,15 +506,16 @@ type LogStreams interface {
 	Streams() (stdout chan string, stderr chan string, err chan error)
 	// Close closes log streams
 	Close()
+	// Progress returns the progress parsed from the logs, final once the streams are closed
+	Progress() *Progress
 }
 
-func followClientLogs(srcConfig *rest.Config, pvc types.NamespacedName, labels map[string]string, outputFile string) error {
+func followClientLogs(srcConfig *rest.Config, pvc types.NamespacedName, labels map[string]string, outputFile string) (*Progress, error) {
 	logReader := NewRsyncLogStream(srcConfig, pvc, labels, outputFile)
 	err := logReader.Init()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer logReader.Close()
 	stdout, stderr, errChan := logReader.Streams()
 	for {
 		closed := false
@@ -720,7 +547,8 @@ func followClientLogs(srcConfig *rest.Config, pvc types.NamespacedName, labels m
 			break
 		}
 	}
-	return err
+	logReader.Close()
+	return logReader.Progress(), err
 }
 
 // waitForEndpoint waits for endpoint to become ready
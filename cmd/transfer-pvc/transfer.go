@@ -0,0 +1,276 @@
+package transfer_pvc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	logrusr "github.com/bombsimon/logrusr/v3"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/backube/pvc-transfer/transfer"
+	rsynctransfer "github.com/backube/pvc-transfer/transfer/rsync"
+	"github.com/backube/pvc-transfer/transport"
+	stunneltransport "github.com/backube/pvc-transfer/transport/stunnel"
+)
+
+// Transfer copies the data of a PVC of the source cluster to a PVC of the destination cluster. An
+// rsync daemon Pod receives the data in the destination namespace through an endpoint, and an
+// rsync client Pod sends it from the source namespace, over stunnel.
+type Transfer struct {
+	SourceConfig      *rest.Config
+	DestinationConfig *rest.Config
+	// Source is the PVC whose data is sent
+	Source *corev1.PersistentVolumeClaim
+	// Destination is the PVC receiving the data, created unless it exists
+	Destination *corev1.PersistentVolumeClaim
+	Endpoint    EndpointFlags
+	// SourceImage and DestinationImage default to the images of the rsync transfer
+	SourceImage      string
+	DestinationImage string
+	// Verify enables the checksum verification of rsync
+	Verify bool
+	// ProgressOutput is the file the final progress is written to, if any
+	ProgressOutput string
+	// CompareVolumes counts the files and bytes of both volumes once the data is sent, and fails
+	// the transfer when they differ
+	CompareVolumes bool
+}
+
+// Run transfers the data and returns the progress of the rsync client. The Pods, Secrets and
+// endpoint created for the transfer are deleted when it returns, also when it fails. The data
+// already sent by a failed transfer is kept in the destination PVC, so running the transfer again
+// only sends what is missing.
+func (t *Transfer) Run(ctx context.Context) (progress *Progress, err error) {
+	logrusLog := logrus.New()
+	logrusLog.SetFormatter(&logrus.JSONFormatter{})
+	logger := logrusr.New(logrusLog).WithName("transfer-pvc")
+
+	srcClient, err := newClient(t.SourceConfig, t.Endpoint.Type)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get source client: %w", err)
+	}
+	destClient, err := newClient(t.DestinationConfig, t.Endpoint.Type)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get destination client: %w", err)
+	}
+	srcPVC, destPVC := t.Source, t.Destination
+
+	// set up the PVC on destination to receive the data
+	err = destClient.Create(ctx, destPVC.DeepCopy(), &client.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("unable to create destination PVC: %w", err)
+	}
+
+	labels := map[string]string{
+		"app.kubernetes.io/name":          "crane",
+		"app.kubernetes.io/component":     "transfer-pvc",
+		"app.konveyor.io/created-for-pvc": getValidatedResourceName(srcPVC.Name),
+	}
+	defer func() {
+		gcErr := garbageCollect(srcClient, destClient, labels, t.Endpoint.Type, mappedNameVar{source: srcPVC.Namespace, destination: destPVC.Namespace})
+		if gcErr != nil && err == nil {
+			err = fmt.Errorf("unable to delete the transfer resources: %w", gcErr)
+		}
+	}()
+
+	e, err := createEndpoint(t.Endpoint, destPVC, labels, logger, destClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating endpoint: %w", err)
+	}
+
+	if err := waitForEndpoint(e, destClient); err != nil {
+		return nil, fmt.Errorf("endpoint not healthy: %w", err)
+	}
+
+	stunnelServer, err := stunneltransport.NewServer(
+		ctx,
+		destClient,
+		logger,
+		types.NamespacedName{
+			Name:      getValidatedResourceName(destPVC.Name),
+			Namespace: destPVC.Namespace,
+		}, e, &transport.Options{
+			Labels: labels,
+			Image:  t.DestinationImage,
+		})
+	if err != nil {
+		return nil, fmt.Errorf("error creating stunnel server: %w", err)
+	}
+
+	secretList := &corev1.SecretList{}
+	err = destClient.List(
+		ctx,
+		secretList,
+		client.InNamespace(destPVC.Namespace),
+		client.MatchingLabels(labels))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find certificate secrets: %w", err)
+	}
+
+	for i := range secretList.Items {
+		destSecret := &secretList.Items[i]
+		srcSecret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        destSecret.Name,
+				Namespace:   srcPVC.Namespace,
+				Labels:      destSecret.Labels,
+				Annotations: destSecret.Annotations,
+			},
+			StringData: destSecret.StringData,
+			Data:       destSecret.Data,
+		}
+		err = srcClient.Create(ctx, srcSecret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create certificate secret on source cluster: %w", err)
+		}
+	}
+
+	stunnelClient, err := stunneltransport.NewClient(
+		ctx,
+		srcClient,
+		logger,
+		types.NamespacedName{
+			Name:      getValidatedResourceName(srcPVC.Name),
+			Namespace: srcPVC.Namespace,
+		}, e.Hostname(), e.IngressPort(), &transport.Options{
+			Labels: labels,
+			Image:  t.DestinationImage, // Coderabbit suggest SourceImage, keep it for later once we know this better
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating stunnel client: %w", err)
+	}
+
+	destPVCList := transfer.NewSingletonPVC(destPVC)
+	srcPVCList := transfer.NewSingletonPVC(srcPVC)
+
+	rsyncPassword := getRsyncPassword()
+
+	serverPodSecContext, err := getRsyncServerPodSecurityContext(destClient, destPVC.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("error creating security context for rsync server: %w", err)
+	}
+
+	trueBool := bool(true)
+	falseBool := bool(false)
+	rsyncServer, err := rsynctransfer.NewServer(
+		ctx,
+		destClient,
+		logger, destPVCList, stunnelServer, e, labels, nil, rsyncPassword,
+		transfer.PodOptions{
+			ContainerSecurityContext: corev1.SecurityContext{
+				Capabilities: &corev1.Capabilities{
+					Drop: []corev1.Capability{"ALL"},
+				},
+				RunAsNonRoot:             &trueBool,
+				AllowPrivilegeEscalation: &falseBool,
+				SeccompProfile: &corev1.SeccompProfile{
+					Type: corev1.SeccompProfileTypeRuntimeDefault,
+				},
+			},
+			PodSecurityContext: corev1.PodSecurityContext{
+				FSGroup: serverPodSecContext.FSGroup,
+			},
+			Image: t.DestinationImage,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating rsync transfer server: %w", err)
+	}
+
+	// Create a context with timeout to prevent indefinite hanging
+	healthCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	err = wait.PollUntilContextCancel(healthCtx, time.Second*5, true, func(ctx context.Context) (done bool, err error) {
+		ready, err := rsyncServer.IsHealthy(ctx, destClient)
+		if err != nil {
+			log.Println(err, "unable to check rsync server health, retrying...")
+			return false, nil
+		}
+		return ready, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rsync server failed to become healthy: %w", err)
+	}
+
+	// the rsync client runs on the node the PVC is mounted on, if any, as the volume may not
+	// be attachable to another node
+	nodeName, err := getNodeNameForPVC(srcClient, srcPVC.Namespace, srcPVC.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the node the PVC is mounted on: %w", err)
+	}
+
+	clientPodSecCtx, err := getRsyncClientPodSecurityContext(srcClient, srcPVC.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("error creating security context for rsync client: %w", err)
+	}
+
+	_, err = rsynctransfer.NewClient(
+		ctx,
+		srcClient, srcPVCList, stunnelClient, e, logger, "rsync-client", labels, nil, rsyncPassword,
+		transfer.PodOptions{
+			NodeName: nodeName,
+			CommandOptions: rsynctransfer.NewDefaultOptionsFrom(
+				verify(t.Verify),
+				restrictedContainers(true),
+				verbose(true),
+			),
+			ContainerSecurityContext: corev1.SecurityContext{
+				Privileged: &falseBool,
+				Capabilities: &corev1.Capabilities{
+					Drop: []corev1.Capability{"ALL"},
+				},
+				RunAsNonRoot:             &trueBool,
+				RunAsUser:                clientPodSecCtx.RunAsUser,
+				AllowPrivilegeEscalation: &falseBool,
+			},
+			PodSecurityContext: corev1.PodSecurityContext{
+				FSGroup: clientPodSecCtx.FSGroup,
+			},
+			Image: t.SourceImage,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rsync client: %w", err)
+	}
+
+	progress, err = followClientLogs(
+		t.SourceConfig, types.NamespacedName{Name: srcPVC.Name, Namespace: srcPVC.Namespace}, labels, t.ProgressOutput)
+	if err != nil {
+		return progress, fmt.Errorf("error following rsync client logs: %w", err)
+	}
+	if status := progress.Status(); status != succeeded {
+		return progress, fmt.Errorf("rsync transfer %s", strings.ToLower(string(status)))
+	}
+
+	if t.CompareVolumes {
+		image := t.DestinationImage
+		if image == "" {
+			image = defaultVolumeStatsImage
+		}
+		source, err := countVolume(ctx, srcClient, t.SourceConfig, srcPVC, nodeName, image, labels, clientPodSecCtx)
+		if err != nil {
+			return progress, fmt.Errorf("unable to count the files of the source PVC: %w", err)
+		}
+		destination, err := countVolume(ctx, destClient, t.DestinationConfig, destPVC, "", image, labels, serverPodSecContext)
+		if err != nil {
+			return progress, fmt.Errorf("unable to count the files of the destination PVC: %w", err)
+		}
+		progress.Source, progress.Destination = &source, &destination
+		if source != destination {
+			return progress, fmt.Errorf("the destination PVC has %s, the source PVC %s", destination, source)
+		}
+	}
+	return progress, nil
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/transform/listplugins"
 	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/transform/optionals"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
 	"github.com/konveyor-ecosystem/kubectl-migrate/internal/file"
 	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
 	"github.com/konveyor-ecosystem/kubectl-migrate/internal/plugin"
@@ -133,7 +134,7 @@ func (o *Options) run() error {
 	if err != nil {
 		return err
 	}
-	files, err := file.ReadFiles(context.TODO(), exportDir)
+	files, err := file.ReadFiles(context.TODO(), exportDir, exporter.ReportFiles)
 	if err != nil {
 		return err
 	}
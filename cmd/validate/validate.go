@@ -0,0 +1,354 @@
+package validate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/preflight"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	k8sdiscovery "k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+)
+
+// ValidationFailedError is returned by validate when the target cluster cannot accept the export
+type ValidationFailedError struct {
+	Failures int
+}
+
+func (e *ValidationFailedError) Error() string {
+	return fmt.Sprintf("%d validation checks failed, see %s", e.Failures, exporter.ValidateResultsFile)
+}
+
+type ValidateOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+
+	// Two GlobalFlags struct fields are needed
+	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
+	// 2. globalFlags for the args merged with values from the viper config file
+	cobraGlobalFlags *flags.GlobalFlags
+	globalFlags      *flags.GlobalFlags
+
+	exportDir  string
+	namespaces []string
+	full       bool
+
+	genericclioptions.IOStreams
+}
+
+func (o *ValidateOptions) Complete(c *cobra.Command, args []string) error {
+	return nil
+}
+
+func (o *ValidateOptions) Validate() error {
+	return nil
+}
+
+func (o *ValidateOptions) Run() error {
+	return o.run(context.Background(), o.globalFlags.GetLogger())
+}
+
+func NewValidateCommand(streams genericclioptions.IOStreams, f *flags.GlobalFlags) *cobra.Command {
+	o := &ValidateOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+
+		IOStreams:        streams,
+		cobraGlobalFlags: f,
+		globalFlags:      f,
+	}
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check that the target cluster can accept an export",
+		Long: `Check that the target cluster can accept an export, before the migration window.
+
+The apiVersion and kind of the exported objects are checked against the API served by the target
+cluster, the custom resources whose CRD is missing are reported, and the CRDs found in the export
+only warn as replay applies them first. The first exported object of each kind and namespace, or
+every object with --full, is created with a server-side dry run. The StorageClasses,
+IngressClasses, PriorityClasses and RuntimeClasses referenced by the exported objects must exist
+on the target cluster or be in the export. The checks are printed as a table and recorded in
+` + exporter.ValidateResultsFile + ` at the root of the export directory.
+
+Exit codes:
+  0    the target cluster can accept the export, maybe with warnings
+  1    fatal error, like an invalid export or an unreachable cluster
+  3    some checks failed`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			c.SilenceUsage = true
+			if err := o.Run(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+			viper.Unmarshal(o.globalFlags)
+			viper.Unmarshal(o.configFlags)
+			viper.UnmarshalKey("export-dir", &o.exportDir)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.exportDir, "export-dir", "e", "export", "The export directory to validate, as written by export")
+	cmd.Flags().StringSliceVarP(&o.namespaces, "namespace", "n", nil, "The exported namespace to validate, defaults to every namespace of the export. Can be repeated or comma-separated")
+	cmd.Flags().BoolVar(&o.full, "full", false, "Dry run the creation of every exported object instead of the first one of each kind and namespace")
+	// the namespace flag is registered above so it can take several values
+	o.configFlags.Namespace = nil
+	o.configFlags.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func (o *ValidateOptions) run(ctx context.Context, log logrus.FieldLogger) error {
+	previous, err := exporter.ReadRunSummary(o.exportDir)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s has no export to validate, %s not found", o.exportDir, exporter.SummaryJSONFile)
+	}
+	if err != nil {
+		return err
+	}
+	namespaces, err := previous.SelectNamespaces(o.namespaces)
+	if err != nil {
+		return err
+	}
+	entries, err := index.Read(o.exportDir)
+	if err != nil {
+		return fmt.Errorf("cannot read the %s of %s: %w", index.File, o.exportDir, err)
+	}
+	objects := []unstructured.Unstructured{}
+	seen := map[string]bool{}
+	for _, namespace := range namespaces {
+		read, _, err := exporter.ReadReplayObjects(o.exportDir, namespace, entries)
+		if err != nil {
+			return err
+		}
+		for _, r := range read {
+			if key := exporter.ReplayKey(r.Object) + "/" + r.Object.GetAPIVersion(); !seen[key] {
+				seen[key] = true
+				objects = append(objects, r.Object)
+			}
+		}
+	}
+
+	discoveryClient, err := o.configFlags.ToDiscoveryClient()
+	if err != nil {
+		return fmt.Errorf("cannot create discovery client: %w", err)
+	}
+	results := []preflight.Result{}
+	_, resources, err := discoveryClient.ServerGroupsAndResources()
+	switch {
+	case k8sdiscovery.IsGroupDiscoveryFailedError(err):
+		results = append(results, preflight.Result{Check: "API discovery", Status: preflight.StatusWarn, Detail: err.Error()})
+	case err != nil:
+		return fmt.Errorf("cannot discover the target cluster resources: %w", err)
+	}
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return fmt.Errorf("cannot create rest config: %w", err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("cannot create dynamic client: %w", err)
+	}
+
+	v := &validator{client: dynamicClient, api: exporter.NewServedAPI(resources), full: o.full, log: log}
+	results = append(results, v.validate(ctx, objects)...)
+	preflight.Print(o.Out, results)
+	if err := writeValidateResults(o.exportDir, results); err != nil {
+		return fmt.Errorf("cannot write %s: %w", exporter.ValidateResultsFile, err)
+	}
+	failures := 0
+	for _, r := range results {
+		if r.Status == preflight.StatusFail {
+			failures++
+		}
+	}
+	if failures > 0 {
+		return &ValidationFailedError{Failures: failures}
+	}
+	return nil
+}
+
+// validator checks the exported objects against the target cluster
+type validator struct {
+	client dynamic.Interface
+	api    *exporter.ServedAPI
+	// full dry runs every object instead of the first one of each kind and namespace
+	full bool
+	log  logrus.FieldLogger
+}
+
+// validate returns the API checks of each exported kind, the dry runs and the class checks
+func (v *validator) validate(ctx context.Context, objects []unstructured.Unstructured) []preflight.Result {
+	results := v.api.Check(objects)
+	results = append(results, v.dryRun(ctx, objects)...)
+	return append(results, v.checkClasses(ctx, objects)...)
+}
+
+// dryRun creates the sample of the served objects with a server-side dry run and returns a result
+// per kind. The objects of a namespace missing on the target cannot be dry run, replay creates the
+// namespace first.
+func (v *validator) dryRun(ctx context.Context, objects []unstructured.Unstructured) []preflight.Result {
+	type outcome struct {
+		accepted, existing, skipped int
+		failures                    []string
+	}
+	outcomes := map[schema.GroupVersionKind]*outcome{}
+	sampled := map[string]bool{}
+	namespaces := map[string]bool{}
+	for _, obj := range objects {
+		gvk := obj.GroupVersionKind()
+		resource, served := v.api.Resource(gvk)
+		if !served {
+			continue
+		}
+		sample := gvk.String() + "/" + obj.GetNamespace()
+		if !v.full && sampled[sample] {
+			continue
+		}
+		sampled[sample] = true
+		o := outcomes[gvk]
+		if o == nil {
+			o = &outcome{}
+			outcomes[gvk] = o
+		}
+
+		gvr := gvk.GroupVersion().WithResource(resource.Name)
+		var client dynamic.ResourceInterface = v.client.Resource(gvr)
+		if resource.Namespaced {
+			exists, checked := namespaces[obj.GetNamespace()]
+			if !checked {
+				_, err := v.client.Resource(exporter.NamespacesGVR).Get(ctx, obj.GetNamespace(), metav1.GetOptions{})
+				exists = !apierrors.IsNotFound(err)
+				namespaces[obj.GetNamespace()] = exists
+			}
+			if !exists {
+				o.skipped++
+				continue
+			}
+			client = v.client.Resource(gvr).Namespace(obj.GetNamespace())
+		}
+		created := obj.DeepCopy()
+		exporter.StripServerPopulated(created)
+		_, err := client.Create(ctx, created, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+		switch {
+		case apierrors.IsAlreadyExists(err):
+			o.existing++
+		case err != nil:
+			o.failures = append(o.failures, fmt.Sprintf("%s: %v", objectName(obj), err))
+			v.log.Debugf("dry run of %s %s failed: %v", obj.GetKind(), objectName(obj), err)
+		default:
+			o.accepted++
+		}
+	}
+
+	gvks := make([]schema.GroupVersionKind, 0, len(outcomes))
+	for gvk := range outcomes {
+		gvks = append(gvks, gvk)
+	}
+	exporter.SortGVKs(gvks)
+	results := []preflight.Result{}
+	for _, gvk := range gvks {
+		o := outcomes[gvk]
+		apiVersion, kind := gvk.ToAPIVersionAndKind()
+		result := preflight.Result{Check: "dry run " + apiVersion + " " + kind}
+		detail := fmt.Sprintf("%d accepted", o.accepted)
+		if o.existing > 0 {
+			detail += fmt.Sprintf(", %d already exist", o.existing)
+		}
+		if o.skipped > 0 {
+			detail += fmt.Sprintf(", %d in namespaces missing on the target", o.skipped)
+		}
+		switch {
+		case len(o.failures) > 0:
+			result.Status = preflight.StatusFail
+			detail += fmt.Sprintf(", %d rejected, e.g. %s", len(o.failures), o.failures[0])
+		case o.existing > 0 || o.skipped > 0:
+			result.Status = preflight.StatusWarn
+		default:
+			result.Status = preflight.StatusPass
+		}
+		result.Detail = detail
+		results = append(results, result)
+	}
+	return results
+}
+
+func objectName(obj unstructured.Unstructured) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
+
+// checkClasses checks that the cluster-scoped classes referenced by the exported objects exist on
+// the target cluster or are in the export
+func (v *validator) checkClasses(ctx context.Context, objects []unstructured.Unstructured) []preflight.Result {
+	exported := map[string]bool{}
+	for _, obj := range objects {
+		exported[obj.GetKind()+"/"+obj.GetName()] = true
+	}
+	referencedBy := map[string]string{}
+	dependencies := map[string]exporter.ClusterDependency{}
+	for _, obj := range objects {
+		for _, d := range exporter.ClusterDependencies {
+			for _, name := range d.References(obj) {
+				key := d.Kind + "/" + name
+				if _, ok := referencedBy[key]; !ok {
+					referencedBy[key] = obj.GetKind() + "/" + objectName(obj)
+					dependencies[key] = d
+				}
+			}
+		}
+	}
+
+	results := []preflight.Result{}
+	for _, key := range slices.Sorted(maps.Keys(referencedBy)) {
+		d := dependencies[key]
+		name := strings.TrimPrefix(key, d.Kind+"/")
+		result := preflight.Result{Check: d.Kind + " " + name}
+		_, err := v.client.Resource(d.Resource).Get(ctx, name, metav1.GetOptions{})
+		switch {
+		case err == nil:
+			result.Status, result.Detail = preflight.StatusPass, "exists on the target"
+		case exported[key]:
+			result.Status, result.Detail = preflight.StatusPass, "in the export"
+		case apierrors.IsNotFound(err):
+			result.Status, result.Detail = preflight.StatusFail, "missing on the target, referenced by "+referencedBy[key]
+		default:
+			result.Status, result.Detail = preflight.StatusWarn, "cannot be checked: "+err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// writeValidateResults writes validate-results.json at the root of the export directory
+func writeValidateResults(exportDir string, results []preflight.Result) error {
+	resultBytes, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(exportDir, exporter.ValidateResultsFile), append(resultBytes, '\n'), 0600)
+}
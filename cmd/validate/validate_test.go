@@ -0,0 +1,136 @@
+package validate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/preflight"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func testLogger() logrus.FieldLogger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func testReplayObject(apiVersion, kind, namespace, name string) unstructured.Unstructured {
+	obj := unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+func testServedAPI() *exporter.ServedAPI {
+	return exporter.NewServedAPI([]*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "namespaces", Kind: "Namespace"},
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true},
+			{Name: "persistentvolumeclaims", Kind: "PersistentVolumeClaim", Namespaced: true},
+		}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "deployments", Kind: "Deployment", Namespaced: true},
+			{Name: "deployments/scale", Kind: "Scale", Namespaced: true},
+		}},
+		{GroupVersion: "networking.k8s.io/v1", APIResources: []metav1.APIResource{
+			{Name: "ingresses", Kind: "Ingress", Namespaced: true},
+		}},
+		{GroupVersion: "apiextensions.k8s.io/v1", APIResources: []metav1.APIResource{
+			{Name: "customresourcedefinitions", Kind: "CustomResourceDefinition"},
+		}},
+	})
+}
+
+func Test_validator_dryRun(t *testing.T) {
+	namespace := testReplayObject("v1", "Namespace", "", "foo")
+	existing := testReplayObject("v1", "ConfigMap", "foo", "existing")
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), &namespace, &existing)
+	client.PrependReactor("create", "deployments", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		obj := action.(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		if obj.GetName() == "invalid" {
+			return true, nil, fmt.Errorf("spec.replicas: Invalid value")
+		}
+		return false, nil, nil
+	})
+	objects := []unstructured.Unstructured{
+		namespace,
+		testReplayObject("apps/v1", "Deployment", "foo", "web"),
+		testReplayObject("apps/v1", "Deployment", "foo", "invalid"),
+		testReplayObject("v1", "ConfigMap", "foo", "existing"),
+		testReplayObject("v1", "ConfigMap", "bar", "config"),
+		testReplayObject("example.com/v1", "Widget", "foo", "blue"),
+	}
+
+	tests := []struct {
+		name string
+		full bool
+		want []preflight.Result
+	}{
+		{
+			name: "given no --full, should dry run the first object of each kind and namespace",
+			want: []preflight.Result{
+				{Check: "dry run apps/v1 Deployment", Status: preflight.StatusPass, Detail: "1 accepted"},
+				{Check: "dry run v1 ConfigMap", Status: preflight.StatusWarn, Detail: "0 accepted, 1 already exist, 1 in namespaces missing on the target"},
+				{Check: "dry run v1 Namespace", Status: preflight.StatusWarn, Detail: "0 accepted, 1 already exist"},
+			},
+		},
+		{
+			name: "given --full, should dry run every object",
+			full: true,
+			want: []preflight.Result{
+				{Check: "dry run apps/v1 Deployment", Status: preflight.StatusFail, Detail: "0 accepted, 1 already exist, 1 rejected, e.g. foo/invalid: spec.replicas: Invalid value"},
+				{Check: "dry run v1 ConfigMap", Status: preflight.StatusWarn, Detail: "0 accepted, 1 already exist, 1 in namespaces missing on the target"},
+				{Check: "dry run v1 Namespace", Status: preflight.StatusWarn, Detail: "0 accepted, 1 already exist"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &validator{client: client, api: testServedAPI(), full: tt.full, log: testLogger()}
+			if got := v.dryRun(context.Background(), objects); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dryRun() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_validator_checkClasses(t *testing.T) {
+	claim := func(name, class string) unstructured.Unstructured {
+		obj := testReplayObject("v1", "PersistentVolumeClaim", "foo", name)
+		obj.Object["spec"] = map[string]interface{}{"storageClassName": class}
+		return obj
+	}
+	ingress := testReplayObject("networking.k8s.io/v1", "Ingress", "foo", "web")
+	ingress.Object["spec"] = map[string]interface{}{"ingressClassName": "nginx"}
+	standard := testReplayObject("storage.k8s.io/v1", "StorageClass", "", "standard")
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), &standard)
+	objects := []unstructured.Unstructured{
+		claim("data", "standard"),
+		claim("logs", "gp3"),
+		claim("cache", "fast"),
+		testReplayObject("storage.k8s.io/v1", "StorageClass", "", "fast"),
+		ingress,
+	}
+
+	v := &validator{client: client, api: testServedAPI(), log: testLogger()}
+	want := []preflight.Result{
+		{Check: "IngressClass nginx", Status: preflight.StatusFail, Detail: "missing on the target, referenced by Ingress/foo/web"},
+		{Check: "StorageClass fast", Status: preflight.StatusPass, Detail: "in the export"},
+		{Check: "StorageClass gp3", Status: preflight.StatusFail, Detail: "missing on the target, referenced by PersistentVolumeClaim/foo/logs"},
+		{Check: "StorageClass standard", Status: preflight.StatusPass, Detail: "exists on the target"},
+	}
+	if got := v.checkClasses(context.Background(), objects); !reflect.DeepEqual(got, want) {
+		t.Errorf("checkClasses() = %+v, want %+v", got, want)
+	}
+}
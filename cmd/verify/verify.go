@@ -0,0 +1,102 @@
+package verify
+
+import (
+	"fmt"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type Options struct {
+	// Two GlobalFlags struct fields are needed
+	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
+	// 2. globalFlags for the args merged with values from the viper config file
+	cobraGlobalFlags *flags.GlobalFlags
+	globalFlags      *flags.GlobalFlags
+	// Two Flags struct fields are needed
+	// 1. cobraFlags for explicit CLI args parsed by cobra
+	// 2. Flags for the args merged with values from the viper config file
+	cobraFlags Flags
+	Flags
+}
+
+type Flags struct {
+	ExportDir string `mapstructure:"export-dir"`
+}
+
+func (o *Options) Complete(c *cobra.Command, args []string) error {
+	return nil
+}
+
+func (o *Options) Validate() error {
+	return nil
+}
+
+func (o *Options) Run() error {
+	return o.run()
+}
+
+func NewVerifyCommand(f *flags.GlobalFlags) *cobra.Command {
+	o := &Options{
+		cobraGlobalFlags: f,
+		globalFlags:      f,
+	}
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check the manifests of an export directory against the checksums of its " + index.File,
+		Long: `Check the manifests of an export directory against the checksums of its ` + index.File + `.
+The files that are missing, modified, or found under resources/ without being indexed are reported
+and the command exits with a non-zero code. Verify before running decrypt, which replaces the
+encrypted files.`,
+		RunE: func(c *cobra.Command, args []string) error {
+			if err := o.Complete(c, args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			c.SilenceUsage = true
+			if err := o.Run(); err != nil {
+				return err
+			}
+
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			_ = viper.BindPFlags(cmd.Flags())
+			_ = viper.Unmarshal(&o.Flags)
+			_ = viper.Unmarshal(&o.globalFlags)
+		},
+	}
+
+	addFlagsForOptions(&o.cobraFlags, cmd)
+
+	return cmd
+}
+
+func addFlagsForOptions(o *Flags, cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.ExportDir, "export-dir", "e", "export", "The path where the kubernetes resources are saved")
+}
+
+func (o *Options) run() error {
+	log := o.globalFlags.GetLogger()
+
+	entries, err := index.Read(o.ExportDir)
+	if err != nil {
+		return err
+	}
+	problems, err := index.Verify(o.ExportDir, entries)
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		log.Errorf("%s", p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d files in %s do not match %s", len(problems), o.ExportDir, index.File)
+	}
+	log.Infof("Verified %d files in %s", len(entries), o.ExportDir)
+	return nil
+}
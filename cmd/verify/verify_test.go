@@ -0,0 +1,39 @@
+package verify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+)
+
+func TestVerify(t *testing.T) {
+	exportDir := t.TempDir()
+	manifest := filepath.Join(exportDir, "resources", "foo", "configmaps_app.yaml")
+	if err := os.MkdirAll(filepath.Dir(manifest), 0700); err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("kind: ConfigMap\n")
+	if err := os.WriteFile(manifest, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	entries := []index.Entry{{Path: "resources/foo/configmaps_app.yaml", Size: int64(len(content)), SHA256: index.Sum(content)}}
+	if err := index.Write(exportDir, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	o := &Options{globalFlags: &flags.GlobalFlags{}}
+	o.ExportDir = exportDir
+	if err := o.run(); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	if err := os.WriteFile(manifest, []byte("kind: Secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.run(); err == nil {
+		t.Errorf("run() should fail on a modified file")
+	}
+}
@@ -1,29 +1,58 @@
 package version
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 
 	"github.com/konveyor-ecosystem/kubectl-migrate/internal/buildinfo"
 	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	outputJSON = "json"
+	outputYAML = "yaml"
 )
 
 type Options struct {
+	configFlags *genericclioptions.ConfigFlags
+
 	// Two GlobalFlags struct fields are needed
 	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
 	// 2. globalFlags for the args merged with values from the viper config file
 	cobraGlobalFlags *flags.GlobalFlags
 	globalFlags      *flags.GlobalFlags
+
+	client bool
+	output string
+	out    io.Writer
+}
+
+// Version is the version printed, the server one is only set without --client
+type Version struct {
+	ClientVersion buildinfo.Info `json:"clientVersion"`
+	ServerVersion *ServerVersion `json:"serverVersion,omitempty"`
+}
+
+// ServerVersion is the version of the connected cluster
+type ServerVersion struct {
+	GitVersion string `json:"gitVersion"`
+	Platform   string `json:"platform"`
 }
 
 func (o *Options) Complete(c *cobra.Command, args []string) error {
-	// TODO: @sseago
+	o.out = c.OutOrStdout()
 	return nil
 }
 
 func (o *Options) Validate() error {
-	// TODO: @sseago
+	if o.output != "" && o.output != outputJSON && o.output != outputYAML {
+		return fmt.Errorf("invalid output format %q, must be one of: %s, %s", o.output, outputJSON, outputYAML)
+	}
 	return nil
 }
 
@@ -33,11 +62,12 @@ func (o *Options) Run() error {
 
 func NewVersionCommand(f *flags.GlobalFlags) *cobra.Command {
 	o := &Options{
+		configFlags:      genericclioptions.NewConfigFlags(true),
 		cobraGlobalFlags: f,
 	}
 	cmd := &cobra.Command{
 		Use:   "version",
-		Short: "Return the current crane (and crane-lib) version",
+		Short: "Return the current kubectl-migrate (and crane-lib) version",
 		RunE: func(c *cobra.Command, args []string) error {
 			if err := o.Complete(c, args); err != nil {
 				return err
@@ -45,6 +75,8 @@ func NewVersionCommand(f *flags.GlobalFlags) *cobra.Command {
 			if err := o.Validate(); err != nil {
 				return err
 			}
+			// the usage is only relevant to flag errors
+			c.SilenceUsage = true
 			if err := o.Run(); err != nil {
 				return err
 			}
@@ -54,13 +86,66 @@ func NewVersionCommand(f *flags.GlobalFlags) *cobra.Command {
 			viper.Unmarshal(&o.globalFlags)
 		},
 	}
+	cmd.Flags().BoolVar(&o.client, "client", true, "Only print the version of kubectl-migrate, --client=false also prints the version of the cluster of the current context")
+	cmd.Flags().StringVarP(&o.output, "output", "o", "", "Print the version as json or yaml instead of text")
+	o.configFlags.AddFlags(cmd.Flags())
 	return cmd
 }
 
 func (o *Options) run() error {
-	fmt.Println("crane:")
-	fmt.Printf("\tVersion: %s\n", buildinfo.Version)
-	fmt.Println("crane-lib:")
-	fmt.Printf("\tVersion: %s\n", buildinfo.CranelibVersion)
+	v := Version{ClientVersion: buildinfo.Get()}
+	if !o.client {
+		server, err := o.serverVersion()
+		if err != nil {
+			return fmt.Errorf("cannot get the server version: %w", err)
+		}
+		v.ServerVersion = server
+	}
+	return printVersion(o.out, v, o.output)
+}
+
+func (o *Options) serverVersion() (*ServerVersion, error) {
+	discoveryClient, err := o.configFlags.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	info, err := discoveryClient.ServerVersion()
+	if err != nil {
+		return nil, err
+	}
+	return &ServerVersion{GitVersion: info.GitVersion, Platform: info.Platform}, nil
+}
+
+func printVersion(out io.Writer, v Version, output string) error {
+	switch output {
+	case outputJSON:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	case outputYAML:
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(data)
+		return err
+	}
+	client := v.ClientVersion
+	fmt.Fprintln(out, "kubectl-migrate:")
+	fmt.Fprintf(out, "\tVersion: %s\n", client.Version)
+	fmt.Fprintf(out, "\tCommit: %s\n", client.Commit)
+	fmt.Fprintf(out, "\tBuild date: %s\n", client.BuildDate)
+	fmt.Fprintf(out, "\tGo version: %s\n", client.GoVersion)
+	fmt.Fprintf(out, "\tPlatform: %s\n", client.Platform)
+	fmt.Fprintln(out, "crane-lib:")
+	fmt.Fprintf(out, "\tVersion: %s\n", client.CranelibVersion)
+	if v.ServerVersion != nil {
+		fmt.Fprintln(out, "Server:")
+		fmt.Fprintf(out, "\tVersion: %s\n", v.ServerVersion.GitVersion)
+		fmt.Fprintf(out, "\tPlatform: %s\n", v.ServerVersion.Platform)
+	}
 	return nil
 }
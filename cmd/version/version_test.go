@@ -0,0 +1,75 @@
+package version
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+func runVersion(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	root := &cobra.Command{Use: "kubectl-migrate"}
+	root.AddCommand(NewVersionCommand(&flags.GlobalFlags{}))
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+	root.SetArgs(append([]string{"version"}, args...))
+	err := root.Execute()
+	return out.String(), err
+}
+
+func TestNewVersionCommand(t *testing.T) {
+	t.Run("given --output json, should print every field of the client version", func(t *testing.T) {
+		out, err := runVersion(t, "--output", "json")
+		if err != nil {
+			t.Fatalf("version error = %v", err)
+		}
+		v := map[string]map[string]string{}
+		if err := json.Unmarshal([]byte(out), &v); err != nil {
+			t.Fatalf("version = %q is not JSON: %v", out, err)
+		}
+		for _, field := range []string{"version", "gitCommit", "buildDate", "goVersion", "platform", "cranelibVersion"} {
+			if v["clientVersion"][field] == "" {
+				t.Errorf("clientVersion.%s is empty in %s", field, out)
+			}
+		}
+		if _, found := v["serverVersion"]; found {
+			t.Errorf("version = %s, the server version should only be printed with --client=false", out)
+		}
+	})
+
+	t.Run("given --output yaml, should print the client version", func(t *testing.T) {
+		out, err := runVersion(t, "-o", "yaml")
+		if err != nil {
+			t.Fatalf("version error = %v", err)
+		}
+		v := Version{}
+		if err := yaml.Unmarshal([]byte(out), &v); err != nil || v.ClientVersion.GoVersion == "" {
+			t.Errorf("version = %q, %v, want the client version", out, err)
+		}
+	})
+
+	t.Run("given no output, should print the text version", func(t *testing.T) {
+		out, err := runVersion(t)
+		if err != nil || !strings.HasPrefix(out, "kubectl-migrate:\n\tVersion: ") || !strings.Contains(out, "\tGo version: go") {
+			t.Errorf("version = %q, %v", out, err)
+		}
+	})
+
+	t.Run("given an unknown output, should fail", func(t *testing.T) {
+		if _, err := runVersion(t, "-o", "xml"); err == nil || !strings.Contains(err.Error(), "invalid output format") {
+			t.Errorf("version error = %v, want the invalid output", err)
+		}
+	})
+
+	t.Run("given --client=false and an unreachable cluster, should fail", func(t *testing.T) {
+		if _, err := runVersion(t, "--client=false", "--server", "https://127.0.0.1:1", "--request-timeout", "1s"); err == nil || !strings.Contains(err.Error(), "cannot get the server version") {
+			t.Errorf("version error = %v, want the server version error", err)
+		}
+	})
+}
@@ -0,0 +1,210 @@
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChecksumExtension is appended to the path of an archive to name its checksum file
+const ChecksumExtension = ".sha256"
+
+// Pack writes the content of dir as a gzip compressed tarball to w. Entry names are relative to
+// dir and use forward slashes, so the archive extracts to the same layout on every platform.
+func Pack(dir string, w io.Writer) error {
+	gzw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gzw)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gzw.Close()
+}
+
+// PackFile packs dir into a new tarball at path
+func PackFile(dir string, path string) error {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := Pack(dir, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Unpack extracts the gzip compressed tarball read from r into dir. Only the directories and the
+// regular files are extracted, and an entry whose name is absolute or escapes dir with .. fails
+// the extraction before anything is written for it.
+func Unpack(r io.Reader, dir string) error {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gzr.Close()
+	tr := tar.NewReader(gzr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		path, err := entryPath(dir, header.Name)
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s: unsupported entry type %q, only directories and regular files are extracted", header.Name, header.Typeflag)
+		}
+	}
+}
+
+// entryPath returns the path in dir of an archive entry, or an error when the entry would be
+// written outside of dir
+func entryPath(dir string, name string) (string, error) {
+	if name == "" || strings.HasPrefix(name, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("%s: absolute entry names are not extracted", name)
+	}
+	for _, part := range strings.Split(strings.ReplaceAll(name, "\\", "/"), "/") {
+		if part == ".." {
+			return "", fmt.Errorf("%s: entry names with .. are not extracted", name)
+		}
+	}
+	return filepath.Join(dir, filepath.FromSlash(name)), nil
+}
+
+// UnpackFile extracts the tarball at path into dir
+func UnpackFile(path string, dir string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return Unpack(f, dir)
+}
+
+// WriteChecksum writes the SHA-256 of the file at path next to it, in the format of sha256sum, and
+// returns it
+func WriteChecksum(path string) (string, error) {
+	sum, err := fileSum(path)
+	if err != nil {
+		return "", err
+	}
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(path))
+	return sum, os.WriteFile(path+ChecksumExtension, []byte(line), 0600)
+}
+
+// VerifyChecksum checks the file at path against the checksum file written next to it by
+// WriteChecksum. The error is an os.IsNotExist one when there is no checksum file.
+func VerifyChecksum(path string) error {
+	f, err := os.Open(path + ChecksumExtension)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return fmt.Errorf("%s%s holds no checksum", path, ChecksumExtension)
+	}
+	sum, err := fileSum(path)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(fields[0], sum) {
+		return fmt.Errorf("%s does not match %s%s: sha256 %s, expected %s", path, path, ChecksumExtension, sum, fields[0])
+	}
+	return nil
+}
+
+func fileSum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,157 @@
+package archive_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/archive"
+)
+
+func TestPack(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"resources/foo/ConfigMap__v1_foo_bar.yaml": "kind: ConfigMap\n",
+		"failures/foo/pods.yaml":                   "error: forbidden\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := archive.Pack(dir, buf); err != nil {
+		t.Fatalf("Pack() error = %v", err)
+	}
+
+	gzr, err := gzip.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gzr)
+	got := map[string]string{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[header.Name] = string(content)
+	}
+	if !reflect.DeepEqual(got, files) {
+		t.Errorf("archive content = %v, want %v", got, files)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.json": "[]\n",
+		"resources/foo/ConfigMap__v1_foo_bar.yaml": "kind: ConfigMap\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	packed := &bytes.Buffer{}
+	if err := archive.Pack(dir, packed); err != nil {
+		t.Fatal(err)
+	}
+	restored := t.TempDir()
+	if err := archive.Unpack(packed, restored); err != nil {
+		t.Fatalf("Unpack() error = %v", err)
+	}
+	for name, content := range files {
+		got, err := os.ReadFile(filepath.Join(restored, filepath.FromSlash(name)))
+		if err != nil || string(got) != content {
+			t.Errorf("unpacked %s = %q, %v, want %q", name, got, err, content)
+		}
+	}
+}
+
+func TestUnpack_unsafe(t *testing.T) {
+	tests := []struct {
+		name   string
+		header tar.Header
+	}{
+		{name: "given a parent directory entry, should fail", header: tar.Header{Name: "../evil.yaml", Typeflag: tar.TypeReg, Mode: 0600}},
+		{name: "given a nested parent directory entry, should fail", header: tar.Header{Name: "resources/../../evil.yaml", Typeflag: tar.TypeReg, Mode: 0600}},
+		{name: "given an absolute entry, should fail", header: tar.Header{Name: "/tmp/evil.yaml", Typeflag: tar.TypeReg, Mode: 0600}},
+		{name: "given a symlink, should fail", header: tar.Header{Name: "resources/link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			gzw := gzip.NewWriter(buf)
+			tw := tar.NewWriter(gzw)
+			if err := tw.WriteHeader(&tt.header); err != nil {
+				t.Fatal(err)
+			}
+			if err := tw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := gzw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			parent := t.TempDir()
+			dir := filepath.Join(parent, "restored")
+			if err := archive.Unpack(buf, dir); err == nil {
+				t.Errorf("Unpack() of %s succeeded, want error", tt.header.Name)
+			}
+			if _, err := os.Stat(filepath.Join(parent, "evil.yaml")); !os.IsNotExist(err) {
+				t.Errorf("Unpack() wrote outside of the directory")
+			}
+		})
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.tar.gz")
+	if err := os.WriteFile(path, []byte("archive"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.VerifyChecksum(path); !os.IsNotExist(err) {
+		t.Errorf("VerifyChecksum() without checksum file error = %v, want not exist", err)
+	}
+	sum, err := archive.WriteChecksum(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, err := os.ReadFile(path + archive.ChecksumExtension)
+	if err != nil || string(line) != sum+"  out.tar.gz\n" {
+		t.Errorf("checksum file = %q, %v, want the sha256sum format", line, err)
+	}
+	if err := archive.VerifyChecksum(path); err != nil {
+		t.Errorf("VerifyChecksum() error = %v", err)
+	}
+	if err := os.WriteFile(path, []byte("tampered"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.VerifyChecksum(path); err == nil {
+		t.Errorf("VerifyChecksum() of a modified file succeeded, want error")
+	}
+}
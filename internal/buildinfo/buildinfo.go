@@ -1,6 +1,9 @@
 package buildinfo
 
 import (
+	"runtime"
+	"runtime/debug"
+
 	cranelibversion "github.com/konveyor/crane-lib/version"
 )
 
@@ -12,3 +15,49 @@ var (
 	Commit    string
 	BuildDate string
 )
+
+// unknown is reported for the build metadata that was neither injected nor recorded by go build
+const unknown = "unknown"
+
+// Info is the build metadata of the binary
+type Info struct {
+	Version         string `json:"version"`
+	Commit          string `json:"gitCommit"`
+	BuildDate       string `json:"buildDate"`
+	GoVersion       string `json:"goVersion"`
+	Platform        string `json:"platform"`
+	CranelibVersion string `json:"cranelibVersion"`
+}
+
+// Get returns the build metadata. The commit and build date are injected with -ldflags by make
+// build, the VCS information recorded by go build is used otherwise.
+func Get() Info {
+	info := Info{
+		Version:         Version,
+		Commit:          Commit,
+		BuildDate:       BuildDate,
+		GoVersion:       runtime.Version(),
+		Platform:        runtime.GOOS + "/" + runtime.GOARCH,
+		CranelibVersion: CranelibVersion,
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = setting.Value
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = unknown
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = unknown
+	}
+	if info.CranelibVersion == "" {
+		info.CranelibVersion = unknown
+	}
+	return info
+}
@@ -0,0 +1,122 @@
+// Package completion completes the flag values that name cluster objects, like the namespaces and
+// the resources. The cluster is asked with a short timeout, when it is not reachable nothing is
+// completed and the shell falls back to the static completion of the flags.
+package completion
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Timeout bounds the requests of a completion, unless --request-timeout is set
+const Timeout = 3 * time.Second
+
+// Func is the completion function of a flag value
+type Func func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+// Namespaces completes the namespaces of the cluster
+func Namespaces(configFlags *genericclioptions.ConfigFlags) Func {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		setTimeout(configFlags)
+		restConfig, err := configFlags.ToRESTConfig()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		client, err := kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+		defer cancel()
+		list, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		names := make([]string, 0, len(list.Items))
+		for _, ns := range list.Items {
+			names = append(names, ns.Name)
+		}
+		return listValues(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// Resources completes the resources of the cluster as resource or resource.group, e.g.
+// deployments.apps. The discovery is read from the kubectl discovery cache when it is fresh.
+func Resources(configFlags *genericclioptions.ConfigFlags) Func {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		setTimeout(configFlags)
+		discoveryClient, err := configFlags.ToDiscoveryClient()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		// the groups that cannot be discovered are skipped, the others are completed
+		lists, _ := discoveryClient.ServerPreferredResources()
+		return listValues(resourceNames(lists), toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// resourceNames returns the sorted resource.group names of the resources, without subresources
+func resourceNames(lists []*metav1.APIResourceList) []string {
+	seen := map[string]bool{}
+	for _, list := range lists {
+		if list == nil {
+			continue
+		}
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			if strings.Contains(resource.Name, "/") {
+				continue
+			}
+			name := resource.Name
+			if gv.Group != "" {
+				name += "." + gv.Group
+			}
+			seen[name] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// listValues completes the last value of a comma-separated list flag, the values already given are
+// kept as prefix and not completed again
+func listValues(values []string, toComplete string) []string {
+	given := map[string]bool{}
+	prefix := ""
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix = toComplete[:i+1]
+		for _, value := range strings.Split(toComplete[:i], ",") {
+			given[value] = true
+		}
+	}
+	completions := []string{}
+	for _, value := range values {
+		if !given[value] && strings.HasPrefix(prefix+value, toComplete) {
+			completions = append(completions, prefix+value)
+		}
+	}
+	return completions
+}
+
+// setTimeout bounds the requests of the completion so that an unreachable cluster does not hang
+// the shell
+func setTimeout(configFlags *genericclioptions.ConfigFlags) {
+	if configFlags.Timeout != nil && (*configFlags.Timeout == "" || *configFlags.Timeout == "0") {
+		*configFlags.Timeout = Timeout.String()
+	}
+}
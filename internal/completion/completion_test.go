@@ -0,0 +1,58 @@
+package completion
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func Test_listValues(t *testing.T) {
+	values := []string{"configmaps", "deployments.apps", "secrets"}
+	tests := []struct {
+		name       string
+		toComplete string
+		want       []string
+	}{
+		{name: "given nothing typed, should complete every value", toComplete: "", want: values},
+		{name: "given a prefix, should complete the matching values", toComplete: "de", want: []string{"deployments.apps"}},
+		{name: "given a list, should complete the last value", toComplete: "configmaps,s", want: []string{"configmaps,secrets"}},
+		{name: "given a list, should not complete the values already given", toComplete: "configmaps,", want: []string{"configmaps,deployments.apps", "configmaps,secrets"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listValues(values, tt.toComplete); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("listValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_resourceNames(t *testing.T) {
+	lists := []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "pods"}, {Name: "pods/log"}, {Name: "configmaps"}}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{{Name: "deployments"}, {Name: "deployments/scale"}}},
+		nil,
+	}
+	want := []string{"configmaps", "deployments.apps", "pods"}
+	if got := resourceNames(lists); !reflect.DeepEqual(got, want) {
+		t.Errorf("resourceNames() = %v, want %v", got, want)
+	}
+}
+
+func TestNamespaces_unreachable(t *testing.T) {
+	configFlags := genericclioptions.NewConfigFlags(false)
+	server := "https://127.0.0.1:1"
+	configFlags.APIServer = &server
+	for name, complete := range map[string]Func{"namespaces": Namespaces(configFlags), "resources": Resources(configFlags)} {
+		got, directive := complete(&cobra.Command{}, nil, "")
+		if len(got) != 0 || directive != cobra.ShellCompDirectiveNoFileComp {
+			t.Errorf("%s completion of an unreachable cluster = %v, %v, want nothing", name, got, directive)
+		}
+	}
+	if *configFlags.Timeout != Timeout.String() {
+		t.Errorf("request timeout = %s, want %s", *configFlags.Timeout, Timeout)
+	}
+}
@@ -0,0 +1,53 @@
+package encryption_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/encryption"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipients, err := encryption.ParseRecipients([]string{identity.Recipient().String()})
+	if err != nil {
+		t.Fatalf("ParseRecipients() error = %v", err)
+	}
+
+	plain := []byte("kind: Secret\ndata:\n  password: c2VjcmV0\n")
+	encrypted, err := encryption.Encrypt(plain, recipients)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	identityFile := filepath.Join(t.TempDir(), "key.txt")
+	if err := os.WriteFile(identityFile, []byte(identity.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	identities, err := encryption.ReadIdentities(identityFile)
+	if err != nil {
+		t.Fatalf("ReadIdentities() error = %v", err)
+	}
+	decrypted, err := encryption.Decrypt(encrypted, identities)
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if string(decrypted) != string(plain) {
+		t.Errorf("Decrypt() = %q, want %q", decrypted, plain)
+	}
+}
+
+func TestParseRecipients(t *testing.T) {
+	if _, err := encryption.ParseRecipients([]string{"age1notakey"}); err == nil {
+		t.Errorf("ParseRecipients() should reject an invalid recipient")
+	}
+	recipients, err := encryption.ParseRecipients([]string{"", " "})
+	if err != nil || len(recipients) != 0 {
+		t.Errorf("ParseRecipients() = %v, %v, want no recipients", recipients, err)
+	}
+}
@@ -0,0 +1,180 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/encryption"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/file"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	ApplyResultsFile = "apply-results.json"
+
+	ApplyCreated    = "created"
+	ApplyConfigured = "configured"
+	ApplyUnchanged  = "unchanged"
+	ApplySkipped    = "skipped"
+	ApplyFailed     = "failed"
+)
+
+// SkippedRedacted is the error of the Secrets exported with --redact-secrets, they are not applied
+// so that their placeholders do not replace the values of the target cluster
+const SkippedRedacted = "redacted by export, its values are placeholders"
+
+// The tiers of applyTiers, the objects of a tier are applied after the ones of the previous tiers
+const (
+	TierNamespaces = iota
+	TierCRDs
+	tierCluster
+	tierServiceAccounts
+	TierConfig
+	tierWorkloads
+	tierWebhooks
+)
+
+// ApplyTiers name the tiers replay applies the objects in. The webhooks come last, their services
+// are not running before the workloads are applied and they would reject the other objects.
+var ApplyTiers = []string{"namespaces", "crds", "cluster", "serviceaccounts", "config", "workloads", "webhooks"}
+
+var CRDResource = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+// ApplyResult is the outcome of applying an exported object, recorded in apply-results.json
+type ApplyResult struct {
+	Tier       string `json:"tier,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Name       string `json:"name,omitempty"`
+	Path       string `json:"path"`
+	Result     string `json:"result"`
+	Error      string `json:"error,omitempty"`
+	// Conflicts are the fields owned by another field manager, e.g.
+	// .spec.replicas: conflict with "kube-controller-manager"
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// ReplayObject is an exported object to apply and the file it was read from
+type ReplayObject struct {
+	Path   string
+	Object unstructured.Unstructured
+}
+
+// IsReplayedFile reports whether an exported file holds objects of the namespace: the files under
+// resources/<namespace>, and the files of the single layout
+func IsReplayedFile(path string, namespace string) bool {
+	if strings.HasPrefix(path, "resources/"+namespace+"/") {
+		return !strings.HasPrefix(path, "resources/"+namespace+"/"+EventsDir+"/")
+	}
+	name := strings.TrimSuffix(strings.TrimSuffix(path, encryption.Extension), gzipExtension)
+	for _, suffix := range []string{"", "-cluster", "-crds", "-deps", "-webhooks"} {
+		if name == "resources/"+namespace+suffix+".yaml" {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadReplayObjects reads the objects exported with the namespace per the index of the export. The
+// encrypted files cannot be applied, they are returned as skipped results.
+func ReadReplayObjects(exportDir, namespace string, entries []index.Entry) ([]ReplayObject, []ApplyResult, error) {
+	objects := []ReplayObject{}
+	skipped := []ApplyResult{}
+	for _, entry := range entries {
+		if !IsReplayedFile(entry.Path, namespace) {
+			continue
+		}
+		if strings.HasSuffix(entry.Path, encryption.Extension) {
+			skipped = append(skipped, ApplyResult{Namespace: entry.Namespace, APIVersion: entry.APIVersion, Kind: entry.Kind, Name: entry.Name, Path: entry.Path, Result: ApplySkipped, Error: "encrypted, decrypt the export first"})
+			continue
+		}
+		items, err := ReadManifestFile(filepath.Join(exportDir, filepath.FromSlash(entry.Path)))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, obj := range items {
+			if obj.GetKind() == "Event" {
+				continue
+			}
+			if isRedacted(obj) {
+				skipped = append(skipped, ApplyResult{Namespace: obj.GetNamespace(), APIVersion: obj.GetAPIVersion(), Kind: obj.GetKind(), Name: obj.GetName(), Path: entry.Path, Result: ApplySkipped, Error: SkippedRedacted})
+				continue
+			}
+			objects = append(objects, ReplayObject{Path: entry.Path, Object: obj})
+		}
+	}
+	return objects, skipped, nil
+}
+
+// RenameReplayObjects moves the objects from the source namespace with the renamer of
+// --target-namespace and returns the number of fields still mentioning the source namespace
+func RenameReplayObjects(renamer *namespaceRenamer, source string, objects []ReplayObject) int {
+	if renamer == nil {
+		return 0
+	}
+	list := &unstructured.UnstructuredList{}
+	for _, r := range objects {
+		list.Items = append(list.Items, r.Object)
+	}
+	warnings := renamer.rename(source, []*groupResource{{objects: list}})
+	for i := range objects {
+		objects[i].Object = list.Items[i]
+	}
+	return warnings
+}
+
+// ReplayKey identifies the object on the target cluster, whatever its version
+func ReplayKey(obj unstructured.Unstructured) string {
+	gvk := obj.GroupVersionKind()
+	return gvk.Group + "/" + gvk.Kind + "/" + obj.GetNamespace() + "/" + obj.GetName()
+}
+
+// ApplyTier returns the tier of applyTiers the object is applied in
+func ApplyTier(obj unstructured.Unstructured) int {
+	gvk := obj.GroupVersionKind()
+	switch {
+	case gvk.Group == "" && gvk.Kind == "Namespace":
+		return TierNamespaces
+	case gvk.Group == "apiextensions.k8s.io" && gvk.Kind == "CustomResourceDefinition":
+		return TierCRDs
+	case gvk.Group == "admissionregistration.k8s.io" && strings.HasSuffix(gvk.Kind, "WebhookConfiguration"):
+		return tierWebhooks
+	case obj.GetNamespace() == "":
+		return tierCluster
+	case gvk.Group == "" && gvk.Kind == "ServiceAccount",
+		gvk.Group == "rbac.authorization.k8s.io" && (gvk.Kind == "Role" || gvk.Kind == "RoleBinding"):
+		return tierServiceAccounts
+	case gvk.Group == "" && (gvk.Kind == "ConfigMap" || gvk.Kind == "Secret"):
+		return TierConfig
+	}
+	return tierWorkloads
+}
+
+// WriteApplyResults writes apply-results.json at the root of the export directory, atomically so
+// that a replay killed while writing it does not leave it truncated
+func WriteApplyResults(exportDir string, results []ApplyResult) error {
+	resultBytes, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return file.WriteAtomic(file.OS, filepath.Join(exportDir, ApplyResultsFile), append(resultBytes, '\n'), false)
+}
+
+// ReadApplyResults reads the apply-results.json written by the last replay
+func ReadApplyResults(exportDir string) ([]ApplyResult, error) {
+	resultBytes, err := os.ReadFile(filepath.Join(exportDir, ApplyResultsFile))
+	if err != nil {
+		return nil, err
+	}
+	results := []ApplyResult{}
+	if err := json.Unmarshal(resultBytes, &results); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ApplyResultsFile, err)
+	}
+	return results, nil
+}
@@ -0,0 +1,172 @@
+package exporter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// writeTestExport writes the deployments and the namespace of namespace foo with the layout and
+// returns the index of the export
+func writeTestExport(t *testing.T, exportDir string, layout string, compress bool, objects ...unstructured.Unstructured) []index.Entry {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(exportDir, "resources", "foo"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	manifests := newExportIndex(exportDir)
+	namespace := unstructured.Unstructured{}
+	namespace.SetAPIVersion("v1")
+	namespace.SetKind("Namespace")
+	namespace.SetName("foo")
+	w := &resourceWriter{
+		resourceDir: filepath.Join(exportDir, "resources", "foo"),
+		output:      OutputYAML,
+		layout:      layout,
+		singleFile:  filepath.Join(exportDir, "resources", "foo.yaml"),
+		workers:     1,
+		compress:    compress,
+		namespace:   &groupResource{APIVersion: "v1", APIResource: metav1.APIResource{Name: "namespaces", Kind: "Namespace"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{namespace}}},
+		index:       manifests,
+		log:         testLogger(),
+	}
+	resources := []*groupResource{
+		{APIGroup: "apps", APIVersion: "v1", APIResource: metav1.APIResource{Name: "deployments", Kind: "Deployment"}, objects: &unstructured.UnstructuredList{Items: objects}},
+	}
+	if errs := w.writeResources(resources); len(errs) > 0 {
+		t.Fatalf("writeResources() errors = %v", errs)
+	}
+	if err := manifests.write(); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := index.Read(exportDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func testReplayObject(apiVersion, kind, namespace, name string) unstructured.Unstructured {
+	obj := unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+func Test_applyTier(t *testing.T) {
+	tests := []struct {
+		obj  unstructured.Unstructured
+		want int
+	}{
+		{obj: testReplayObject("v1", "Namespace", "", "foo"), want: TierNamespaces},
+		{obj: testReplayObject("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "widgets.example.com"), want: TierCRDs},
+		{obj: testReplayObject("rbac.authorization.k8s.io/v1", "ClusterRoleBinding", "", "foo-view"), want: tierCluster},
+		{obj: testReplayObject("scheduling.k8s.io/v1", "PriorityClass", "", "high"), want: tierCluster},
+		{obj: testReplayObject("v1", "ServiceAccount", "foo", "web"), want: tierServiceAccounts},
+		{obj: testReplayObject("rbac.authorization.k8s.io/v1", "RoleBinding", "foo", "web"), want: tierServiceAccounts},
+		{obj: testReplayObject("v1", "Secret", "foo", "web"), want: TierConfig},
+		{obj: testReplayObject("apps/v1", "Deployment", "foo", "web"), want: tierWorkloads},
+		{obj: testReplayObject("example.com/v1", "Secret", "foo", "web"), want: tierWorkloads},
+		{obj: testReplayObject("admissionregistration.k8s.io/v1", "ValidatingWebhookConfiguration", "", "policy"), want: tierWebhooks},
+	}
+	for _, tt := range tests {
+		t.Run(tt.obj.GetAPIVersion()+" "+tt.obj.GetKind(), func(t *testing.T) {
+			if got := ApplyTier(tt.obj); got != tt.want {
+				t.Errorf("applyTier() = %s, want %s", ApplyTiers[got], ApplyTiers[tt.want])
+			}
+		})
+	}
+}
+
+func Test_isReplayedFile(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "resources/foo/Deployment_apps_v1_foo_web.yaml", want: true},
+		{path: "resources/foo/_cluster/crds/CustomResourceDefinition_widgets.example.com.yaml", want: true},
+		{path: "resources/foo/_events/Event_v1_foo_web.1.yaml"},
+		{path: "resources/foo.yaml.gz", want: true},
+		{path: "resources/foo-crds.yaml", want: true},
+		{path: "resources/foo-bar.yaml"},
+		{path: "resources/foobar/Deployment_apps_v1_foobar_web.yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := IsReplayedFile(tt.path, "foo"); got != tt.want {
+				t.Errorf("isReplayedFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_readReplayObjects(t *testing.T) {
+	for _, layout := range []string{LayoutFlat, LayoutSingle} {
+		t.Run(layout, func(t *testing.T) {
+			exportDir := t.TempDir()
+			redacted := testReplayObject("v1", "Secret", "foo", "db")
+			redactSecret(&redacted)
+			entries := writeTestExport(t, exportDir, layout, true, testReplayObject("apps/v1", "Deployment", "foo", "web"), redacted)
+			entries = append(entries, index.Entry{Path: "resources/foo/Secret_v1_foo_web.yaml.age", APIVersion: "v1", Kind: "Secret", Namespace: "foo", Name: "web"})
+
+			objects, skipped, err := ReadReplayObjects(exportDir, "foo", entries)
+			if err != nil {
+				t.Fatalf("readReplayObjects() error = %v", err)
+			}
+			got := []string{}
+			for _, r := range objects {
+				got = append(got, r.Object.GetKind()+"/"+r.Object.GetName())
+			}
+			if want := []string{"Deployment/web", "Namespace/foo"}; len(got) != 2 || !(reflect.DeepEqual(got, want) || reflect.DeepEqual(got, []string{want[1], want[0]})) {
+				t.Errorf("readReplayObjects() = %v, want %v", got, want)
+			}
+			skippedNames := []string{}
+			for _, result := range skipped {
+				if result.Kind != "Secret" || result.Result != ApplySkipped {
+					t.Errorf("readReplayObjects() skipped %+v, want only Secrets", result)
+				}
+				skippedNames = append(skippedNames, result.Name)
+			}
+			if want := []string{"db", "web"}; !reflect.DeepEqual(skippedNames, want) {
+				t.Errorf("readReplayObjects() skipped = %v, want the redacted Secret db and the encrypted Secret web", skippedNames)
+			}
+		})
+	}
+}
+
+func Test_renameReplayObjects(t *testing.T) {
+	objects := []ReplayObject{
+		{Object: testReplayObject("v1", "Namespace", "", "foo")},
+		{Object: testReplayObject("apps/v1", "Deployment", "foo", "web")},
+	}
+	RenameReplayObjects(NewNamespaceRenamer("bar"), "foo", objects)
+	if objects[0].Object.GetName() != "bar" || objects[1].Object.GetNamespace() != "bar" {
+		t.Errorf("renameReplayObjects() = %s, %s/%s, want the objects moved to bar", objects[0].Object.GetName(), objects[1].Object.GetNamespace(), objects[1].Object.GetName())
+	}
+}
+
+func Test_writeApplyResults(t *testing.T) {
+	dir := t.TempDir()
+	results := []ApplyResult{{Tier: "workloads", Namespace: "foo", APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Path: "resources/foo/web.yaml", Result: ApplyFailed, Error: "admission denied"}}
+	if err := WriteApplyResults(dir, results); err != nil {
+		t.Fatalf("writeApplyResults() error = %v", err)
+	}
+	resultBytes, err := os.ReadFile(filepath.Join(dir, ApplyResultsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []ApplyResult{}
+	if err := json.Unmarshal(resultBytes, &got); err != nil {
+		t.Fatalf("%s does not parse: %v", ApplyResultsFile, err)
+	}
+	if !reflect.DeepEqual(got, results) {
+		t.Errorf("results = %+v, want %+v", got, results)
+	}
+}
@@ -0,0 +1,108 @@
+package exporter
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// exportedVersions are the versions a group is exported in instead of its preferred version, the
+// first one served: the clusters before 1.23 prefer autoscaling/v1, which only keeps the CPU target
+// of the HorizontalPodAutoscalers
+var exportedVersions = map[string][]string{"autoscaling": {"v2", "v2beta2"}}
+
+// normalizedVersions are the versions of the objects exported in a newer version with the same
+// schema, autoscaling/v2beta2 being removed from 1.26
+var normalizedVersions = map[string]string{"autoscaling/v2beta2": "autoscaling/v2"}
+
+// normalizeVersions exports the objects listed in the preferred version of their resource at the
+// newer version of the same schema, the other versions exported with --all-versions are kept
+func normalizeVersions(resources []*groupResource) {
+	for _, r := range resources {
+		if r.objects == nil || r.nonPreferredVersion {
+			continue
+		}
+		for _, obj := range r.objects.Items {
+			if version, ok := normalizedVersions[obj.GetAPIVersion()]; ok {
+				obj.SetAPIVersion(version)
+			}
+		}
+	}
+}
+
+// isTopLevelWorkload reports whether the object runs pods and is not controlled by another object,
+// like the ReplicaSets of a Deployment
+func isTopLevelWorkload(obj unstructured.Unstructured) bool {
+	_, ok := podSpecPaths[obj.GetKind()]
+	return ok && metav1.GetControllerOfNoCopy(&obj) == nil
+}
+
+// selectsPods reports whether the label selector of a PodDisruptionBudget matches the pods of the
+// workload, an empty selector matching every pod of the namespace
+func selectsPods(budget unstructured.Unstructured, workload unstructured.Unstructured) bool {
+	if _, ok := podSpecPaths[workload.GetKind()]; !ok {
+		return false
+	}
+	raw, found, _ := unstructured.NestedMap(budget.Object, "spec", "selector")
+	if !found {
+		return false
+	}
+	selector := &metav1.LabelSelector{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, selector); err != nil {
+		return false
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false
+	}
+	return s.Matches(labels.Set(templateLabels(workload)))
+}
+
+// unresolvedWorkloadReferences returns the HorizontalPodAutoscalers scaling an object that is not
+// exported and the PodDisruptionBudgets selecting the pods of no exported workload, they would act
+// on nothing, or on other workloads, on the target cluster
+func unresolvedWorkloadReferences(resources []*groupResource) []string {
+	exported := map[graphNode]bool{}
+	workloads := []unstructured.Unstructured{}
+	for _, r := range resources {
+		if r.objects == nil {
+			continue
+		}
+		for _, obj := range r.objects.Items {
+			exported[objectNode(obj)] = true
+			if isTopLevelWorkload(obj) {
+				workloads = append(workloads, obj)
+			}
+		}
+	}
+
+	unresolved := []string{}
+	for _, r := range resources {
+		if r.objects == nil || r.nonPreferredVersion {
+			continue
+		}
+		for _, obj := range r.objects.Items {
+			switch obj.GetKind() {
+			case "HorizontalPodAutoscaler":
+				kind, _, _ := unstructured.NestedString(obj.Object, "spec", "scaleTargetRef", "kind")
+				name, _, _ := unstructured.NestedString(obj.Object, "spec", "scaleTargetRef", "name")
+				target := graphNode{Kind: kind, Namespace: obj.GetNamespace(), Name: name}
+				if !exported[target] {
+					unresolved = append(unresolved, fmt.Sprintf("%s/%s scales %s, which is not exported", obj.GetKind(), obj.GetName(), target))
+				}
+			case "PodDisruptionBudget":
+				selected := false
+				for _, workload := range workloads {
+					selected = selected || selectsPods(obj, workload)
+				}
+				if !selected {
+					unresolved = append(unresolved, fmt.Sprintf("%s/%s selects the pods of no exported workload", obj.GetKind(), obj.GetName()))
+				}
+			}
+		}
+	}
+	return unresolved
+}
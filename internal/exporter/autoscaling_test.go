@@ -0,0 +1,94 @@
+package exporter
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func Test_resourceToExtract_autoscaling(t *testing.T) {
+	hpas := metav1.APIResource{Name: "horizontalpodautoscalers", Namespaced: true, Kind: "HorizontalPodAutoscaler", Verbs: metav1.Verbs{"list"}}
+	lists := []*metav1.APIResourceList{
+		{GroupVersion: "autoscaling/v1", APIResources: []metav1.APIResource{hpas}},
+		{GroupVersion: "autoscaling/v2beta2", APIResources: []metav1.APIResource{hpas}},
+	}
+	// the clusters before 1.23 prefer autoscaling/v1
+	groups := []metav1.APIGroup{{
+		Name:             "autoscaling",
+		Versions:         []metav1.GroupVersionForDiscovery{{GroupVersion: "autoscaling/v1", Version: "v1"}, {GroupVersion: "autoscaling/v2beta2", Version: "v2beta2"}},
+		PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "autoscaling/v1", Version: "v1"},
+	}}
+	hpa := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "autoscaling/v2beta2",
+		"kind":       "HorizontalPodAutoscaler",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "foo"},
+	}}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		{Group: "autoscaling", Version: "v1", Resource: "horizontalpodautoscalers"}:      "HorizontalPodAutoscalerList",
+		{Group: "autoscaling", Version: "v2beta2", Resource: "horizontalpodautoscalers"}: "HorizontalPodAutoscalerList",
+	}, hpa)
+
+	resources, errs := resourceToExtract(context.Background(), "foo", metav1.ListOptions{}, false, false, &ResourceFilter{}, 1, 0, client, lists, groups, nil, nil, nil, nil, testLogger())
+	if len(errs) != 0 || len(resources) != 1 || resources[0].APIVersion != "v2beta2" {
+		t.Fatalf("resourceToExtract() = %v, %v, want the HorizontalPodAutoscalers in autoscaling/v2beta2", resources, errs)
+	}
+	normalizeVersions(resources)
+	if got := resources[0].objects.Items[0].GetAPIVersion(); got != "autoscaling/v2" {
+		t.Errorf("normalizeVersions() apiVersion = %s, want autoscaling/v2", got)
+	}
+}
+
+func Test_unresolvedWorkloadReferences(t *testing.T) {
+	withField := func(obj unstructured.Unstructured, value interface{}, path ...string) unstructured.Unstructured {
+		if err := unstructured.SetNestedField(obj.Object, value, path...); err != nil {
+			t.Fatal(err)
+		}
+		return obj
+	}
+	controller := true
+	web := withField(testOwnedObject("Deployment", "web"), map[string]interface{}{"app": "web"}, "spec", "template", "metadata", "labels")
+	webReplicas := withField(testOwnedObject("ReplicaSet", "web-5d4f", metav1.OwnerReference{Kind: "Deployment", Name: "web", Controller: &controller}), map[string]interface{}{"app": "db"}, "spec", "template", "metadata", "labels")
+	scaler := func(name string, target string) unstructured.Unstructured {
+		return withField(testOwnedObject("HorizontalPodAutoscaler", name), map[string]interface{}{"kind": "Deployment", "name": target}, "spec", "scaleTargetRef")
+	}
+	budget := func(name string, selector map[string]interface{}) unstructured.Unstructured {
+		return withField(testOwnedObject("PodDisruptionBudget", name), selector, "spec", "selector")
+	}
+	resources := []*groupResource{{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+		web,
+		webReplicas,
+		scaler("web", "web"),
+		scaler("api", "api"),
+		budget("web", map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}}),
+		budget("web-expr", map[string]interface{}{"matchExpressions": []interface{}{map[string]interface{}{"key": "app", "operator": "In", "values": []interface{}{"web", "api"}}}}),
+		// the pods of the ReplicaSets are those of their Deployment
+		budget("db", map[string]interface{}{"matchLabels": map[string]interface{}{"app": "db"}}),
+	}}}}
+
+	got := unresolvedWorkloadReferences(resources)
+	want := []string{
+		"HorizontalPodAutoscaler/api scales Deployment/api, which is not exported",
+		"PodDisruptionBudget/db selects the pods of no exported workload",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unresolvedWorkloadReferences() = %v, want %v", got, want)
+	}
+
+	graph := newDependencyGraph()
+	graph.add(resources)
+	edges := map[string]bool{}
+	for _, edge := range graph.resolved() {
+		edges[edge.String()] = edge.Found
+	}
+	for _, edge := range []string{"PodDisruptionBudget/web -> Deployment/web (spec.selector)", "PodDisruptionBudget/web-expr -> Deployment/web (spec.selector)"} {
+		if !edges[edge] {
+			t.Errorf("graph edges = %v, want %s found", edges, edge)
+		}
+	}
+}
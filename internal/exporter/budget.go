@@ -0,0 +1,88 @@
+package exporter
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// BudgetExceededError is returned when the export reached --max-resources or --max-bytes, the
+// objects written before are kept and the summary records the limit reached
+type BudgetExceededError struct {
+	Limit string
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("export stopped, %s reached, see %s", e.Limit, summaryTextFile)
+}
+
+// exportBudget bounds the objects and the bytes written by an export, across its namespaces and
+// export directories. The limits are checked before each write so that they are never exceeded
+// on disk. A nil budget is unlimited.
+type exportBudget struct {
+	maxObjects int64
+	maxBytes   int64
+	mu         sync.Mutex
+	objects    int64
+	bytes      int64
+	// exceeded is the limit reached, e.g. --max-resources 1000
+	exceeded string
+}
+
+// newExportBudget returns the budget of the export, nil when it is unlimited
+func newExportBudget(maxObjects int64, maxBytes int64) *exportBudget {
+	if maxObjects <= 0 && maxBytes <= 0 {
+		return nil
+	}
+	return &exportBudget{maxObjects: maxObjects, maxBytes: maxBytes}
+}
+
+// allow reserves the objects and bytes about to be written, it returns false once a limit is
+// reached and every write after it is refused
+func (b *exportBudget) allow(objects int, bytes int) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.exceeded != "":
+		return false
+	case b.maxObjects > 0 && b.objects+int64(objects) > b.maxObjects:
+		b.exceeded = fmt.Sprintf("--max-resources %d", b.maxObjects)
+		return false
+	case b.maxBytes > 0 && b.bytes+int64(bytes) > b.maxBytes:
+		b.exceeded = fmt.Sprintf("--max-bytes %d", b.maxBytes)
+		return false
+	}
+	b.objects += int64(objects)
+	b.bytes += int64(bytes)
+	return true
+}
+
+// exceededLimit returns the limit reached, empty while the export is within its budget
+func (b *exportBudget) exceededLimit() string {
+	if b == nil {
+		return ""
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.exceeded
+}
+
+// parseBytes parses a flag like --max-bytes as a number of bytes or a quantity, e.g. 500Mi or 10G
+func parseBytes(flag string, value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q, must be a number of bytes or a quantity like 500Mi or 10G", flag, value)
+	}
+	bytes, ok := quantity.AsInt64()
+	if !ok || bytes < 0 {
+		return 0, fmt.Errorf("invalid %s %q, must be a positive number of bytes", flag, value)
+	}
+	return bytes, nil
+}
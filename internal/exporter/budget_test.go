@@ -0,0 +1,97 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_exportBudget_allow(t *testing.T) {
+	t.Run("given --max-resources, should refuse the objects over it", func(t *testing.T) {
+		b := newExportBudget(2, 0)
+		if !b.allow(1, 100) || !b.allow(1, 100) {
+			t.Fatalf("allow() = false within the budget")
+		}
+		if b.allow(1, 100) || b.exceededLimit() != "--max-resources 2" {
+			t.Errorf("allow() over the budget, exceeded = %q", b.exceededLimit())
+		}
+	})
+
+	t.Run("given --max-bytes, should refuse every write once reached", func(t *testing.T) {
+		b := newExportBudget(0, 150)
+		if !b.allow(1, 100) {
+			t.Fatalf("allow() = false within the budget")
+		}
+		if b.allow(1, 100) || b.exceededLimit() != "--max-bytes 150" {
+			t.Errorf("allow() over the budget, exceeded = %q", b.exceededLimit())
+		}
+		if b.allow(1, 10) {
+			t.Errorf("allow() = true after the budget was exceeded")
+		}
+	})
+
+	t.Run("given no limits, should be unlimited", func(t *testing.T) {
+		b := newExportBudget(0, 0)
+		if b != nil || !b.allow(1000, 1<<30) || b.exceededLimit() != "" {
+			t.Errorf("newExportBudget(0, 0) = %v, want an unlimited budget", b)
+		}
+	})
+}
+
+func Test_parseBytes(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: "1048576", want: 1048576},
+		{value: "500Mi", want: 500 << 20},
+		{value: "10G", want: 10000000000},
+		{value: "-1", wantErr: true},
+		{value: "lots", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseBytes("--max-bytes", tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseBytes(%q) = %d, %v, want %d", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func Test_resourceWriter_budget(t *testing.T) {
+	objects := []unstructured.Unstructured{}
+	for _, name := range []string{"a", "b", "c"} {
+		objects = append(objects, testOwnedObject("ConfigMap", name))
+	}
+	for _, layout := range []string{LayoutFlat, LayoutSingle} {
+		t.Run("given --max-resources 2 and the "+layout+" layout, should write 2 objects", func(t *testing.T) {
+			dir := t.TempDir()
+			resources := []*groupResource{
+				{APIVersion: "v1", APIResource: metav1.APIResource{Name: "configmaps", Kind: "ConfigMap"}, objects: &unstructured.UnstructuredList{Items: objects}},
+			}
+			w := &resourceWriter{resourceDir: dir, output: OutputYAML, layout: layout, singleFile: filepath.Join(dir, "foo.yaml"), workers: 1, budget: newExportBudget(2, 0), log: testLogger()}
+			if errs := w.writeResources(resources); len(errs) > 0 {
+				t.Fatalf("writeResources() errors = %v", errs)
+			}
+			if w.overBudget != 1 || w.budget.exceededLimit() == "" {
+				t.Errorf("overBudget = %d, exceeded = %q, want 1 object over --max-resources", w.overBudget, w.budget.exceededLimit())
+			}
+			if layout == LayoutSingle {
+				stream, err := os.ReadFile(filepath.Join(dir, "foo.yaml"))
+				if err != nil || strings.Count(string(stream), "---\n") != 2 {
+					t.Errorf("stream = %q, %v, want 2 objects", stream, err)
+				}
+				return
+			}
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != 2 {
+				t.Errorf("written files = %d, want 2", len(entries))
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package exporter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// serverPopulatedFields are set by the API server and prevent the exported manifests from being
+// re-applied as is on another cluster
+var serverPopulatedFields = [][]string{
+	{"status"},
+	{"metadata", "managedFields"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "uid"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "generation"},
+	{"metadata", "selfLink"},
+}
+
+// stripServerPopulatedFields removes the server populated fields of all the objects in place
+func stripServerPopulatedFields(resources []*groupResource) {
+	for _, r := range resources {
+		if r.objects == nil {
+			continue
+		}
+		for i := range r.objects.Items {
+			StripServerPopulated(&r.objects.Items[i])
+		}
+	}
+}
+
+// StripServerPopulated removes the fields populated by the server from a single object
+func StripServerPopulated(obj *unstructured.Unstructured) {
+	stripObjectFields(obj, serverPopulatedFields)
+}
+
+// clusterAssignment tells which of the values allocated by the source cluster are kept
+type clusterAssignment struct {
+	preserveNodePorts bool
+	preserveClusterIP bool
+}
+
+// stripClusterAssigned removes the values allocated by the source cluster, which are immutable or
+// would collide on the target cluster: the cluster IPs and node ports of the Services and the node
+// of the Pods. A headless Service keeps its None cluster IP, removing it would make it a regular
+// Service.
+func stripClusterAssigned(resources []*groupResource, keep clusterAssignment) {
+	for _, r := range resources {
+		if r.objects == nil {
+			continue
+		}
+		for i := range r.objects.Items {
+			obj := &r.objects.Items[i]
+			switch {
+			case obj.GroupVersionKind().Group == "" && obj.GetKind() == "Service":
+				stripServiceAssigned(obj, keep)
+			case obj.GroupVersionKind().Group == "" && obj.GetKind() == "Pod":
+				unstructured.RemoveNestedField(obj.Object, "spec", "nodeName")
+			}
+		}
+	}
+}
+
+func stripServiceAssigned(obj *unstructured.Unstructured, keep clusterAssignment) {
+	clusterIP, _, _ := unstructured.NestedString(obj.Object, "spec", "clusterIP")
+	if !keep.preserveClusterIP && clusterIP != "None" {
+		stripObjectFields(obj, [][]string{{"spec", "clusterIP"}, {"spec", "clusterIPs"}})
+	}
+	if keep.preserveNodePorts {
+		return
+	}
+	unstructured.RemoveNestedField(obj.Object, "spec", "healthCheckNodePort")
+	ports, found, err := unstructured.NestedSlice(obj.Object, "spec", "ports")
+	if !found || err != nil {
+		return
+	}
+	for _, port := range ports {
+		if port, ok := port.(map[string]interface{}); ok {
+			delete(port, "nodePort")
+		}
+	}
+	unstructured.SetNestedSlice(obj.Object, ports, "spec", "ports")
+}
+
+func stripObjectFields(obj *unstructured.Unstructured, fields [][]string) {
+	for _, field := range fields {
+		unstructured.RemoveNestedField(obj.Object, field...)
+	}
+}
+
+// stripRule removes a field from the objects of a kind, "*" matching every kind
+type stripRule struct {
+	Kind string
+	Path []string
+}
+
+// parseStripRule parses a Kind:path rule. The path is dot separated, keys containing dots are
+// written in brackets, e.g. *:metadata.annotations['kubectl.kubernetes.io/last-applied-configuration']
+func parseStripRule(rule string) (stripRule, error) {
+	kind, path, found := strings.Cut(rule, ":")
+	if !found || kind == "" || path == "" {
+		return stripRule{}, fmt.Errorf("invalid strip rule %q, expected Kind:field.path", rule)
+	}
+	fields, err := parseFieldPath(path)
+	if err != nil {
+		return stripRule{}, fmt.Errorf("invalid strip rule %q: %w", rule, err)
+	}
+	return stripRule{Kind: kind, Path: fields}, nil
+}
+
+func parseFieldPath(path string) ([]string, error) {
+	fields := []string{}
+	current := strings.Builder{}
+	// closed is set after a bracketed key, which must be followed by a dot, a bracket or the end
+	closed := false
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '.':
+			if current.Len() == 0 && !closed {
+				return nil, fmt.Errorf("empty field name at position %d", i)
+			}
+			if !closed {
+				fields = append(fields, current.String())
+			}
+			current.Reset()
+			closed = false
+		case '[':
+			if current.Len() > 0 {
+				fields = append(fields, current.String())
+				current.Reset()
+			}
+			if i+1 >= len(path) || (path[i+1] != '\'' && path[i+1] != '"') {
+				return nil, fmt.Errorf("expected a quoted key after '[' at position %d", i)
+			}
+			quote := path[i+1]
+			end := strings.IndexByte(path[i+2:], quote)
+			if end < 0 || i+2+end+1 >= len(path) || path[i+2+end+1] != ']' {
+				return nil, fmt.Errorf("unterminated key starting at position %d", i)
+			}
+			key := path[i+2 : i+2+end]
+			if key == "" {
+				return nil, fmt.Errorf("empty key at position %d", i)
+			}
+			fields = append(fields, key)
+			i = i + 2 + end + 1
+			closed = true
+		case ']', '\'', '"':
+			return nil, fmt.Errorf("unexpected %q at position %d", c, i)
+		default:
+			if closed {
+				return nil, fmt.Errorf("expected '.' or '[' at position %d", i)
+			}
+			current.WriteByte(c)
+		}
+	}
+	if current.Len() > 0 {
+		fields = append(fields, current.String())
+	} else if !closed {
+		return nil, fmt.Errorf("path ends with an empty field name")
+	}
+	return fields, nil
+}
+
+func (r stripRule) matches(obj unstructured.Unstructured) bool {
+	return r.Kind == "*" || strings.EqualFold(r.Kind, obj.GetKind())
+}
+
+// applyStripRules removes the fields of the matching rules from all the objects in place. Paths
+// missing on an object are ignored.
+func applyStripRules(resources []*groupResource, rules []stripRule) {
+	if len(rules) == 0 {
+		return
+	}
+	for _, r := range resources {
+		if r.objects == nil {
+			continue
+		}
+		for i := range r.objects.Items {
+			for _, rule := range rules {
+				if rule.matches(r.objects.Items[i]) {
+					unstructured.RemoveNestedField(r.objects.Items[i].Object, rule.Path...)
+				}
+			}
+		}
+	}
+}
+
+// defaultStrippedAnnotations match the annotations kubectl and the controllers keep for their own
+// bookkeeping. The last applied configuration is a full copy of the object, possibly with the
+// data of an older Secret.
+var defaultStrippedAnnotations = []*regexp.Regexp{
+	regexp.MustCompile(`^kubectl\.kubernetes\.io/last-applied-configuration$`),
+	regexp.MustCompile(`^deployment\.kubernetes\.io/`),
+	regexp.MustCompile(`^pv\.kubernetes\.io/`),
+	regexp.MustCompile(`^control-plane\.alpha\.kubernetes\.io/leader$`),
+	regexp.MustCompile(`^endpoints\.kubernetes\.io/last-change-trigger-time$`),
+}
+
+// annotationFilter removes the metadata annotations matching strip, unless they match keep
+type annotationFilter struct {
+	strip []*regexp.Regexp
+	keep  []*regexp.Regexp
+}
+
+// newAnnotationFilter returns the filter of the default annotations, unless raw, and of the
+// --strip-annotations and --keep-annotations expressions
+func newAnnotationFilter(raw bool, strip []string, keep []string) (*annotationFilter, error) {
+	f := &annotationFilter{}
+	if !raw {
+		f.strip = append(f.strip, defaultStrippedAnnotations...)
+	}
+	for _, expr := range strip {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --strip-annotations %q: %w", expr, err)
+		}
+		f.strip = append(f.strip, re)
+	}
+	for _, expr := range keep {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --keep-annotations %q: %w", expr, err)
+		}
+		f.keep = append(f.keep, re)
+	}
+	return f, nil
+}
+
+// apply removes the matching keys of metadata.annotations from all the objects in place, nothing
+// else of the objects is changed
+func (f *annotationFilter) apply(resources []*groupResource) {
+	if f == nil || len(f.strip) == 0 {
+		return
+	}
+	for _, r := range resources {
+		if r.objects == nil {
+			continue
+		}
+		for i := range r.objects.Items {
+			f.stripObject(&r.objects.Items[i])
+		}
+	}
+}
+
+func (f *annotationFilter) stripObject(obj *unstructured.Unstructured) {
+	annotations := obj.GetAnnotations()
+	if len(annotations) == 0 {
+		return
+	}
+	stripped := false
+	for key := range annotations {
+		if matchesAny(f.strip, key) && !matchesAny(f.keep, key) {
+			delete(annotations, key)
+			stripped = true
+		}
+	}
+	if !stripped {
+		return
+	}
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	obj.SetAnnotations(annotations)
+}
+
+func matchesAny(expressions []*regexp.Regexp, value string) bool {
+	for _, re := range expressions {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
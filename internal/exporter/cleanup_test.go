@@ -0,0 +1,377 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_stripServerPopulatedFields(t *testing.T) {
+	obj := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":              "foo",
+			"namespace":         "bar",
+			"uid":               "0d9e4f1c-0000-0000-0000-000000000000",
+			"resourceVersion":   "1234",
+			"generation":        int64(2),
+			"creationTimestamp": "2024-01-01T00:00:00Z",
+			"managedFields":     []interface{}{map[string]interface{}{"manager": "kubectl"}},
+			"labels":            map[string]interface{}{"app": "foo"},
+		},
+		"data": map[string]interface{}{
+			"status": "kept",
+			"uid":    "kept",
+		},
+		"status": map[string]interface{}{"phase": "Active"},
+	}}
+	resources := []*groupResource{{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{obj}}}}
+
+	stripServerPopulatedFields(resources)
+
+	want := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":      "foo",
+			"namespace": "bar",
+			"labels":    map[string]interface{}{"app": "foo"},
+		},
+		"data": map[string]interface{}{
+			"status": "kept",
+			"uid":    "kept",
+		},
+	}
+	if got := resources[0].objects.Items[0].Object; !reflect.DeepEqual(got, want) {
+		t.Errorf("stripServerPopulatedFields() = %v, want %v", got, want)
+	}
+}
+
+func Test_parseStripRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		want    stripRule
+		wantErr bool
+	}{
+		{
+			name: "dotted path",
+			rule: "Service:spec.clusterIP",
+			want: stripRule{Kind: "Service", Path: []string{"spec", "clusterIP"}},
+		},
+		{
+			name: "bracketed key with dots",
+			rule: "*:metadata.annotations['kubectl.kubernetes.io/last-applied-configuration']",
+			want: stripRule{Kind: "*", Path: []string{"metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration"}},
+		},
+		{
+			name: "double quoted key followed by a field",
+			rule: `Pod:metadata["a.b"].c`,
+			want: stripRule{Kind: "Pod", Path: []string{"metadata", "a.b", "c"}},
+		},
+		{
+			name:    "missing kind",
+			rule:    "spec.clusterIP",
+			wantErr: true,
+		},
+		{
+			name:    "empty field",
+			rule:    "Service:spec..clusterIP",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated bracket",
+			rule:    "Service:metadata.annotations['foo",
+			wantErr: true,
+		},
+		{
+			name:    "unquoted bracket",
+			rule:    "Pod:spec.containers[0]",
+			wantErr: true,
+		},
+		{
+			name:    "trailing dot",
+			rule:    "Pod:spec.",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStripRule(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseStripRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseStripRule() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_applyStripRules(t *testing.T) {
+	service := unstructured.Unstructured{Object: map[string]interface{}{
+		"kind":     "Service",
+		"metadata": map[string]interface{}{"name": "foo"},
+		"spec":     map[string]interface{}{"clusterIP": "10.0.0.1", "type": "ClusterIP"},
+	}}
+	pod := unstructured.Unstructured{Object: map[string]interface{}{
+		"kind":     "Pod",
+		"metadata": map[string]interface{}{"name": "foo"},
+		"spec":     map[string]interface{}{"nodeName": "node-1"},
+	}}
+	resources := []*groupResource{{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{service, pod}}}}
+
+	rules := []stripRule{
+		{Kind: "Service", Path: []string{"spec", "clusterIP"}},
+		{Kind: "*", Path: []string{"spec", "nodeName"}},
+		{Kind: "*", Path: []string{"metadata", "annotations", "missing"}},
+	}
+	applyStripRules(resources, rules)
+
+	wantService := map[string]interface{}{"type": "ClusterIP"}
+	if got := resources[0].objects.Items[0].Object["spec"]; !reflect.DeepEqual(got, wantService) {
+		t.Errorf("service spec = %v, want %v", got, wantService)
+	}
+	wantPod := map[string]interface{}{}
+	if got := resources[0].objects.Items[1].Object["spec"]; !reflect.DeepEqual(got, wantPod) {
+		t.Errorf("pod spec = %v, want %v", got, wantPod)
+	}
+}
+
+func testService(serviceType string, spec map[string]interface{}) unstructured.Unstructured {
+	spec["type"] = serviceType
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "shop"},
+		"spec":       spec,
+	}}
+}
+
+func Test_stripClusterAssigned(t *testing.T) {
+	tests := []struct {
+		name    string
+		obj     unstructured.Unstructured
+		keep    clusterAssignment
+		wantObj map[string]interface{}
+	}{
+		{
+			name: "given a ClusterIP Service, should remove its cluster IPs",
+			obj: testService("ClusterIP", map[string]interface{}{
+				"clusterIP":  "10.96.12.7",
+				"clusterIPs": []interface{}{"10.96.12.7"},
+				"ports":      []interface{}{map[string]interface{}{"port": int64(80), "targetPort": int64(8080)}},
+			}),
+			wantObj: testService("ClusterIP", map[string]interface{}{
+				"ports": []interface{}{map[string]interface{}{"port": int64(80), "targetPort": int64(8080)}},
+			}).Object,
+		},
+		{
+			name: "given a headless Service, should keep its None cluster IP",
+			obj: testService("ClusterIP", map[string]interface{}{
+				"clusterIP":  "None",
+				"clusterIPs": []interface{}{"None"},
+			}),
+			wantObj: testService("ClusterIP", map[string]interface{}{
+				"clusterIP":  "None",
+				"clusterIPs": []interface{}{"None"},
+			}).Object,
+		},
+		{
+			name: "given a NodePort Service, should remove its cluster IPs and node ports",
+			obj: testService("NodePort", map[string]interface{}{
+				"clusterIP":  "10.96.12.8",
+				"clusterIPs": []interface{}{"10.96.12.8"},
+				"ports": []interface{}{
+					map[string]interface{}{"name": "http", "port": int64(80), "nodePort": int64(30080)},
+					map[string]interface{}{"name": "https", "port": int64(443), "nodePort": int64(30443)},
+				},
+			}),
+			wantObj: testService("NodePort", map[string]interface{}{
+				"ports": []interface{}{
+					map[string]interface{}{"name": "http", "port": int64(80)},
+					map[string]interface{}{"name": "https", "port": int64(443)},
+				},
+			}).Object,
+		},
+		{
+			name: "given a LoadBalancer Service, should remove its allocated values and keep the requested IP",
+			obj: testService("LoadBalancer", map[string]interface{}{
+				"clusterIP":             "10.96.12.9",
+				"clusterIPs":            []interface{}{"10.96.12.9"},
+				"loadBalancerIP":        "203.0.113.10",
+				"externalTrafficPolicy": "Local",
+				"healthCheckNodePort":   int64(32001),
+				"ports":                 []interface{}{map[string]interface{}{"port": int64(443), "nodePort": int64(31443)}},
+			}),
+			wantObj: testService("LoadBalancer", map[string]interface{}{
+				"loadBalancerIP":        "203.0.113.10",
+				"externalTrafficPolicy": "Local",
+				"ports":                 []interface{}{map[string]interface{}{"port": int64(443)}},
+			}).Object,
+		},
+		{
+			name: "given --preserve-nodeports, should keep the node ports of a LoadBalancer Service",
+			obj: testService("LoadBalancer", map[string]interface{}{
+				"clusterIP":           "10.96.12.9",
+				"healthCheckNodePort": int64(32001),
+				"ports":               []interface{}{map[string]interface{}{"port": int64(443), "nodePort": int64(31443)}},
+			}),
+			keep: clusterAssignment{preserveNodePorts: true},
+			wantObj: testService("LoadBalancer", map[string]interface{}{
+				"healthCheckNodePort": int64(32001),
+				"ports":               []interface{}{map[string]interface{}{"port": int64(443), "nodePort": int64(31443)}},
+			}).Object,
+		},
+		{
+			name: "given --preserve-cluster-ip, should keep the cluster IPs of a NodePort Service",
+			obj: testService("NodePort", map[string]interface{}{
+				"clusterIP":  "10.96.12.8",
+				"clusterIPs": []interface{}{"10.96.12.8"},
+				"ports":      []interface{}{map[string]interface{}{"port": int64(80), "nodePort": int64(30080)}},
+			}),
+			keep: clusterAssignment{preserveClusterIP: true},
+			wantObj: testService("NodePort", map[string]interface{}{
+				"clusterIP":  "10.96.12.8",
+				"clusterIPs": []interface{}{"10.96.12.8"},
+				"ports":      []interface{}{map[string]interface{}{"port": int64(80)}},
+			}).Object,
+		},
+		{
+			name: "given a Pod, should remove the node it was scheduled to",
+			obj: unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Pod",
+				"metadata":   map[string]interface{}{"name": "web"},
+				"spec":       map[string]interface{}{"nodeName": "worker-1", "containers": []interface{}{}},
+			}},
+			wantObj: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Pod",
+				"metadata":   map[string]interface{}{"name": "web"},
+				"spec":       map[string]interface{}{"containers": []interface{}{}},
+			},
+		},
+		{
+			name: "given a custom resource named Service, should leave it untouched",
+			obj: unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "serving.knative.dev/v1",
+				"kind":       "Service",
+				"metadata":   map[string]interface{}{"name": "web"},
+				"spec":       map[string]interface{}{"clusterIP": "10.96.12.7"},
+			}},
+			wantObj: map[string]interface{}{
+				"apiVersion": "serving.knative.dev/v1",
+				"kind":       "Service",
+				"metadata":   map[string]interface{}{"name": "web"},
+				"spec":       map[string]interface{}{"clusterIP": "10.96.12.7"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resources := []*groupResource{{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{tt.obj}}}}
+			stripClusterAssigned(resources, tt.keep)
+			if got := resources[0].objects.Items[0].Object; !reflect.DeepEqual(got, tt.wantObj) {
+				t.Errorf("stripClusterAssigned() = %v, want %v", got, tt.wantObj)
+			}
+		})
+	}
+}
+
+func Test_annotationFilter(t *testing.T) {
+	lastApplied := `{"apiVersion":"v1","kind":"Secret","data":{"password":"b2xk"},"metadata":{"annotations":{"team":"payments"}}}`
+	newObject := func() unstructured.Unstructured {
+		return unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]interface{}{
+				"name": "web",
+				"annotations": map[string]interface{}{
+					"kubectl.kubernetes.io/last-applied-configuration": lastApplied,
+					"deployment.kubernetes.io/revision":                "7",
+					"team":                                             "payments",
+					"example.com/owner":                                "alice",
+				},
+			},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"annotations": map[string]interface{}{"deployment.kubernetes.io/revision": "7"},
+					},
+				},
+			},
+		}}
+	}
+	tests := []struct {
+		name  string
+		raw   bool
+		strip []string
+		keep  []string
+		want  map[string]string
+	}{
+		{
+			name: "given the defaults, should only strip the bookkeeping annotations",
+			want: map[string]string{"team": "payments", "example.com/owner": "alice"},
+		},
+		{
+			name:  "given --strip-annotations, should also strip the matching user annotations",
+			strip: []string{`^example\.com/`},
+			want:  map[string]string{"team": "payments"},
+		},
+		{
+			name: "given --keep-annotations, should keep a default annotation",
+			keep: []string{`last-applied-configuration`},
+			want: map[string]string{"kubectl.kubernetes.io/last-applied-configuration": lastApplied, "team": "payments", "example.com/owner": "alice"},
+		},
+		{
+			name:  "given --keep-annotations and --strip-annotations, should keep the annotation",
+			strip: []string{`^team$`},
+			keep:  []string{`^team$`},
+			want:  map[string]string{"team": "payments", "example.com/owner": "alice"},
+		},
+		{
+			name: "given --raw, should strip nothing",
+			raw:  true,
+			want: map[string]string{"kubectl.kubernetes.io/last-applied-configuration": lastApplied, "deployment.kubernetes.io/revision": "7", "team": "payments", "example.com/owner": "alice"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newAnnotationFilter(tt.raw, tt.strip, tt.keep)
+			if err != nil {
+				t.Fatalf("newAnnotationFilter() error = %v", err)
+			}
+			resources := []*groupResource{{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{newObject()}}}}
+			f.apply(resources)
+			obj := resources[0].objects.Items[0]
+			if got := obj.GetAnnotations(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("annotations = %v, want %v", got, tt.want)
+			}
+			// only the keys of metadata.annotations are stripped
+			template, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "template", "metadata", "annotations")
+			if template["deployment.kubernetes.io/revision"] != "7" {
+				t.Errorf("pod template annotations = %v, should not be changed", template)
+			}
+		})
+	}
+
+	t.Run("given only bookkeeping annotations, should remove the annotations", func(t *testing.T) {
+		f, _ := newAnnotationFilter(false, nil, nil)
+		obj := unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "web", "annotations": map[string]interface{}{"deployment.kubernetes.io/revision": "1"}},
+		}}
+		f.apply([]*groupResource{{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{obj}}}})
+		if _, found := obj.Object["metadata"].(map[string]interface{})["annotations"]; found {
+			t.Errorf("metadata = %v, want no annotations", obj.Object["metadata"])
+		}
+	})
+
+	t.Run("given an invalid expression, should fail", func(t *testing.T) {
+		if _, err := newAnnotationFilter(false, []string{"("}, nil); err == nil {
+			t.Errorf("newAnnotationFilter() should fail")
+		}
+	})
+}
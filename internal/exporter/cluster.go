@@ -1,4 +1,4 @@
-package export
+package exporter
 
 import (
 	"fmt"
@@ -32,7 +32,7 @@ func NewClusterScopeHandler() *ClusterScopeHandler {
 	return clusterScopeHandler
 }
 
-func isClusterScopedResource(apiGroup string, kind string) bool {
+func IsClusterScopedResource(apiGroup string, kind string) bool {
 	for _, admitted := range admittedClusterScopeResources {
 		if admitted.Kind == kind && admitted.APIgroup == apiGroup {
 			return true
@@ -41,10 +41,22 @@ func isClusterScopedResource(apiGroup string, kind string) bool {
 	return false
 }
 
-func (c *ClusterScopeHandler) filterRbacResources(resources []*groupResource, log logrus.FieldLogger) []*groupResource {
+// builtinClusterRoles are the user-facing roles every cluster bootstraps, the roles prefixed with
+// system: are built-in too
+var builtinClusterRoles = map[string]bool{"cluster-admin": true, "admin": true, "edit": true, "view": true}
+
+func isBuiltinClusterRole(name string) bool {
+	return builtinClusterRoles[name] || strings.HasPrefix(name, "system:")
+}
+
+// filterRbacResources keeps the cluster-scoped RBAC resources related to the exported ServiceAccounts
+// and RoleBindings. The ClusterRoles referenced by the exported bindings that could not be exported
+// are returned as failures naming the binding.
+func (c *ClusterScopeHandler) filterRbacResources(resources []*groupResource, includeBuiltinRoles bool, log logrus.FieldLogger) ([]*groupResource, []FailureRecord) {
 	log.Debug("Looking for ServiceAccount resources")
 
 	handler := NewClusterScopedRbacHandler(log)
+	handler.includeBuiltinRoles = includeBuiltinRoles
 	var filteredResources []*groupResource
 	for _, r := range resources {
 		kind := r.APIResource.Kind
@@ -54,7 +66,10 @@ func (c *ClusterScopeHandler) filterRbacResources(resources []*groupResource, lo
 				handler.serviceAccounts = append(handler.serviceAccounts, obj)
 			}
 		}
-		if isClusterScopedResource(r.APIGroup, kind) {
+		if kind == "RoleBinding" && r.APIGroup == "rbac.authorization.k8s.io" {
+			handler.roleBindings = append(handler.roleBindings, r.objects.Items...)
+		}
+		if IsClusterScopedResource(r.APIGroup, kind) {
 			log.Debugf("Adding %d Cluster resource of type %s", len(r.objects.Items), kind)
 			handler.clusterResources[kind] = r
 		} else {
@@ -62,21 +77,31 @@ func (c *ClusterScopeHandler) filterRbacResources(resources []*groupResource, lo
 		}
 	}
 
+	exportedClusterRoles := map[string]bool{}
 	for _, k := range admittedClusterScopeResources {
 		filtered, ok := handler.filteredResourcesOfKind(k)
 		if ok && len(filtered.objects.Items) > 0 {
 			filteredResources = append(filteredResources, filtered)
 		}
+		if ok && k.Kind == "ClusterRole" {
+			for _, cr := range filtered.objects.Items {
+				exportedClusterRoles[cr.GetName()] = true
+			}
+		}
 	}
 
-	return filteredResources
+	return filteredResources, handler.unresolvedClusterRoles(exportedClusterRoles)
 }
 
 type ClusterScopedRbacHandler struct {
 	log              logrus.FieldLogger
 	readyToFilter    bool
 	serviceAccounts  []unstructured.Unstructured
+	roleBindings     []unstructured.Unstructured
 	clusterResources map[string]*groupResource
+	// includeBuiltinRoles exports the built-in ClusterRoles referenced by the bindings
+	includeBuiltinRoles bool
+	roleReferences      map[string]string
 
 	filteredClusterRoleBindings *groupResource
 }
@@ -175,29 +200,69 @@ func (c *ClusterScopedRbacHandler) acceptClusterRoleBinding(clusterResource unst
 }
 
 func (c *ClusterScopedRbacHandler) acceptClusterRole(clusterResource unstructured.Unstructured) bool {
-	var cr authv1.ClusterRole
-	err := runtime.DefaultUnstructuredConverter.
-		FromUnstructured(clusterResource.Object, &cr)
-	if err != nil {
-		c.log.Warnf("Cannot convert to authv1.ClusterRole: %s", err)
-	} else {
-		for _, f := range c.filteredClusterRoleBindings.objects.Items {
-			var crb authv1.ClusterRoleBinding
-			err := runtime.DefaultUnstructuredConverter.
-				FromUnstructured(f.Object, &crb)
-			if err != nil {
-				c.log.Warnf("Cannot convert to authv1.ClusterRoleBinding: %s", err)
-			} else {
-				if crb.RoleRef.Kind == "ClusterRole" && crb.RoleRef.Name == cr.Name {
-					c.log.Infof("Accepted %s of kind %s", clusterResource.GetName(), clusterResource.GetKind())
-					return true
-				}
-			}
-		}
+	if isBuiltinClusterRole(clusterResource.GetName()) && !c.includeBuiltinRoles {
+		return false
+	}
+	if _, ok := c.clusterRoleReferences()[clusterResource.GetName()]; ok {
+		c.log.Infof("Accepted %s of kind %s", clusterResource.GetName(), clusterResource.GetKind())
+		return true
 	}
 	return false
 }
 
+// clusterRoleReferences returns the ClusterRoles referenced by the matching ClusterRoleBindings and
+// the exported RoleBindings, with the first binding referencing each of them
+func (c *ClusterScopedRbacHandler) clusterRoleReferences() map[string]string {
+	if c.roleReferences != nil {
+		return c.roleReferences
+	}
+	references := map[string]string{}
+	add := func(binding unstructured.Unstructured, name string) {
+		kind, _, _ := unstructured.NestedString(binding.Object, "roleRef", "kind")
+		role, _, _ := unstructured.NestedString(binding.Object, "roleRef", "name")
+		if _, ok := references[role]; kind == "ClusterRole" && !ok {
+			references[role] = binding.GetKind() + " " + name
+		}
+	}
+	for _, crb := range c.filteredClusterRoleBindings.objects.Items {
+		add(crb, crb.GetName())
+	}
+	for _, rb := range c.roleBindings {
+		add(rb, rb.GetNamespace()+"/"+rb.GetName())
+	}
+	c.roleReferences = references
+	return references
+}
+
+// unresolvedClusterRoles returns a failure for each ClusterRole referenced by an exported binding
+// that is not among the exported ones, because it does not exist or could not be listed
+func (c *ClusterScopedRbacHandler) unresolvedClusterRoles(exported map[string]bool) []FailureRecord {
+	_, listed := c.clusterResources["ClusterRole"]
+	references := c.clusterRoleReferences()
+	records := []FailureRecord{}
+	for _, role := range sortedKeys(references) {
+		if exported[role] || (isBuiltinClusterRole(role) && !c.includeBuiltinRoles) {
+			continue
+		}
+		record := FailureRecord{
+			Operation: "resolve",
+			Group:     "rbac.authorization.k8s.io",
+			Version:   "v1",
+			Resource:  "clusterroles",
+			Name:      role,
+			Error:     fmt.Sprintf("ClusterRole %s referenced by %s not found", role, references[role]),
+			Category:  failureNotFound,
+		}
+		if !listed {
+			record.Error = fmt.Sprintf("ClusterRole %s referenced by %s could not be listed", role, references[role])
+			record.Category = failurePermission
+		}
+		c.log.Warnf("%s", record.Error)
+		records = append(records, record)
+	}
+	return records
+}
+
 func (c *ClusterScopedRbacHandler) acceptSecurityContextConstraints(clusterResource unstructured.Unstructured) bool {
 	var scc securityv1.SecurityContextConstraints
 	err := runtime.DefaultUnstructuredConverter.
@@ -0,0 +1,116 @@
+package exporter
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func testRbacObject(kind, namespace, name string, fields map[string]interface{}) unstructured.Unstructured {
+	obj := map[string]interface{}{
+		"apiVersion": "rbac.authorization.k8s.io/v1",
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+	}
+	for k, v := range fields {
+		obj[k] = v
+	}
+	return unstructured.Unstructured{Object: obj}
+}
+
+func testBinding(kind, namespace, name, role string, subjects ...interface{}) unstructured.Unstructured {
+	return testRbacObject(kind, namespace, name, map[string]interface{}{
+		"roleRef":  map[string]interface{}{"apiGroup": "rbac.authorization.k8s.io", "kind": "ClusterRole", "name": role},
+		"subjects": subjects,
+	})
+}
+
+func Test_filterRbacResources(t *testing.T) {
+	webSubject := map[string]interface{}{"kind": "ServiceAccount", "name": "web", "namespace": "foo"}
+	rbacResource := func(name, kind string, namespaced bool, objects ...unstructured.Unstructured) *groupResource {
+		return &groupResource{
+			APIGroup:    "rbac.authorization.k8s.io",
+			APIVersion:  "v1",
+			APIResource: metav1.APIResource{Name: name, Kind: kind, Namespaced: namespaced},
+			objects:     &unstructured.UnstructuredList{Items: objects},
+		}
+	}
+	resources := func() []*groupResource {
+		return []*groupResource{
+			{APIVersion: "v1", APIResource: metav1.APIResource{Name: "serviceaccounts", Kind: "ServiceAccount", Namespaced: true}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{testOwnedObject("ServiceAccount", "web")}}},
+			rbacResource("rolebindings", "RoleBinding", true,
+				testBinding("RoleBinding", "foo", "web-reader", "web-reader"),
+				testBinding("RoleBinding", "foo", "web-editor", "edit"),
+				testBinding("RoleBinding", "foo", "web-missing", "deleted-role"),
+			),
+			rbacResource("clusterrolebindings", "ClusterRoleBinding", false,
+				testBinding("ClusterRoleBinding", "", "web-nodes", "node-reader", webSubject),
+				testBinding("ClusterRoleBinding", "", "unrelated", "unrelated"),
+			),
+			rbacResource("clusterroles", "ClusterRole", false,
+				testRbacObject("ClusterRole", "", "web-reader", nil),
+				testRbacObject("ClusterRole", "", "node-reader", nil),
+				testRbacObject("ClusterRole", "", "edit", nil),
+				testRbacObject("ClusterRole", "", "unrelated", nil),
+			),
+		}
+	}
+	tests := []struct {
+		name         string
+		builtinRoles bool
+		resources    []*groupResource
+		wantRoles    []string
+		wantFailures []FailureRecord
+	}{
+		{
+			name:      "the ClusterRoles referenced by the bindings are exported, the built-in ones skipped",
+			resources: resources(),
+			wantRoles: []string{"node-reader", "web-reader"},
+			wantFailures: []FailureRecord{
+				{Operation: "resolve", Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles", Name: "deleted-role", Error: "ClusterRole deleted-role referenced by RoleBinding foo/web-missing not found", Category: failureNotFound},
+			},
+		},
+		{
+			name:         "built-in ClusterRoles are exported on request",
+			builtinRoles: true,
+			resources:    resources(),
+			wantRoles:    []string{"edit", "node-reader", "web-reader"},
+			wantFailures: []FailureRecord{
+				{Operation: "resolve", Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles", Name: "deleted-role", Error: "ClusterRole deleted-role referenced by RoleBinding foo/web-missing not found", Category: failureNotFound},
+			},
+		},
+		{
+			name:      "ClusterRoles that could not be listed are permission failures",
+			resources: resources()[:3],
+			wantFailures: []FailureRecord{
+				{Operation: "resolve", Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles", Name: "deleted-role", Error: "ClusterRole deleted-role referenced by RoleBinding foo/web-missing could not be listed", Category: failurePermission},
+				{Operation: "resolve", Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles", Name: "node-reader", Error: "ClusterRole node-reader referenced by ClusterRoleBinding web-nodes could not be listed", Category: failurePermission},
+				{Operation: "resolve", Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles", Name: "web-reader", Error: "ClusterRole web-reader referenced by RoleBinding foo/web-reader could not be listed", Category: failurePermission},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filtered, failures := NewClusterScopeHandler().filterRbacResources(tt.resources, tt.builtinRoles, testLogger())
+			roles := []string{}
+			for _, r := range filtered {
+				if r.APIResource.Kind != "ClusterRole" {
+					continue
+				}
+				for _, obj := range r.objects.Items {
+					roles = append(roles, obj.GetName())
+				}
+			}
+			sort.Strings(roles)
+			if len(roles) != len(tt.wantRoles) || (len(roles) > 0 && !reflect.DeepEqual(roles, tt.wantRoles)) {
+				t.Errorf("exported ClusterRoles = %v, want %v", roles, tt.wantRoles)
+			}
+			if !reflect.DeepEqual(failures, tt.wantFailures) {
+				t.Errorf("failures = %+v, want %+v", failures, tt.wantFailures)
+			}
+		})
+	}
+}
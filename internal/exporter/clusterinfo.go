@@ -0,0 +1,113 @@
+package exporter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+const clusterInfoFile = "cluster-info.json"
+
+const (
+	platformKubernetes = "kubernetes"
+	platformOpenShift  = "openshift"
+)
+
+// openShiftGroups tell OpenShift clusters apart: config.openshift.io is served from OpenShift 4,
+// apps.openshift.io by OpenShift 3 clusters too
+var openShiftGroups = map[string]bool{"config.openshift.io": true, "apps.openshift.io": true}
+
+// admissionResources are the resources changing what the target cluster admits, their presence
+// is recorded in cluster-info.json
+var admissionResources = map[string]bool{
+	"validatingwebhookconfigurations.admissionregistration.k8s.io": true,
+	"mutatingwebhookconfigurations.admissionregistration.k8s.io":   true,
+	"validatingadmissionpolicies.admissionregistration.k8s.io":     true,
+	"mutatingadmissionpolicies.admissionregistration.k8s.io":       true,
+	"podsecuritypolicies.policy":                                   true,
+	"securitycontextconstraints.security.openshift.io":             true,
+}
+
+// clusterInfo describes the source cluster of an export, written as cluster-info.json so that
+// apply failures can be investigated long after the export. Every field is best effort.
+type clusterInfo struct {
+	Context       string `json:"context,omitempty"`
+	Server        string `json:"server,omitempty"`
+	ServerVersion string `json:"serverVersion,omitempty"`
+	Platform      string `json:"platform"`
+	// AdmissionResources are the admission related resources served by the cluster
+	AdmissionResources []string `json:"admissionResources,omitempty"`
+	// PodCIDRs and ServiceCIDRs are the pod and service networks, the ipBlocks of the
+	// NetworkPolicies within them are reported
+	PodCIDRs     []string          `json:"podCIDRs,omitempty"`
+	ServiceCIDRs []string          `json:"serviceCIDRs,omitempty"`
+	APIResources []apiResourceList `json:"apiResources"`
+}
+
+type apiResourceList struct {
+	GroupVersion string   `json:"groupVersion"`
+	Resources    []string `json:"resources"`
+}
+
+// detectPlatform tells OpenShift clusters from vanilla Kubernetes by the API groups they serve
+func detectPlatform(lists []*metav1.APIResourceList) string {
+	for _, list := range lists {
+		if gv, err := schema.ParseGroupVersion(list.GroupVersion); err == nil && openShiftGroups[gv.Group] {
+			return platformOpenShift
+		}
+	}
+	return platformKubernetes
+}
+
+func newClusterInfo(rawConfig api.Config, contextName string, serverVersion string, lists []*metav1.APIResourceList) *clusterInfo {
+	if contextName == "" {
+		contextName = rawConfig.CurrentContext
+	}
+	info := &clusterInfo{
+		Context:       contextName,
+		ServerVersion: serverVersion,
+		Platform:      detectPlatform(lists),
+		APIResources:  []apiResourceList{},
+	}
+	if context, ok := rawConfig.Contexts[contextName]; ok {
+		if cluster, ok := rawConfig.Clusters[context.Cluster]; ok {
+			info.Server = cluster.Server
+		}
+	}
+
+	admission := map[string]bool{}
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		resources := []string{}
+		for _, r := range list.APIResources {
+			resources = append(resources, r.Name)
+			if name := GroupResourceName(gv.Group, r.Name); admissionResources[name] {
+				admission[name] = true
+			}
+		}
+		sort.Strings(resources)
+		info.APIResources = append(info.APIResources, apiResourceList{GroupVersion: list.GroupVersion, Resources: resources})
+	}
+	sort.Slice(info.APIResources, func(i, j int) bool { return info.APIResources[i].GroupVersion < info.APIResources[j].GroupVersion })
+	info.AdmissionResources = sortedKeys(admission)
+	return info
+}
+
+func (i *clusterInfo) write(exportDir string) error {
+	if err := os.MkdirAll(exportDir, 0700); err != nil {
+		return err
+	}
+	infoBytes, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(exportDir, clusterInfoFile), append(infoBytes, '\n'), 0600)
+}
@@ -0,0 +1,65 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func Test_newClusterInfo(t *testing.T) {
+	rawConfig := api.Config{
+		CurrentContext: "source",
+		Contexts:       map[string]*api.Context{"source": {Cluster: "prod"}},
+		Clusters:       map[string]*api.Cluster{"prod": {Server: "https://prod.example.com:6443"}},
+	}
+	kubernetesLists := []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "secrets"}, {Name: "configmaps"}}},
+		{GroupVersion: "admissionregistration.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "validatingwebhookconfigurations"}}},
+	}
+	openShiftLists := append([]*metav1.APIResourceList{
+		{GroupVersion: "config.openshift.io/v1", APIResources: []metav1.APIResource{{Name: "clusterversions"}}},
+		{GroupVersion: "security.openshift.io/v1", APIResources: []metav1.APIResource{{Name: "securitycontextconstraints"}}},
+	}, kubernetesLists...)
+
+	tests := []struct {
+		name          string
+		lists         []*metav1.APIResourceList
+		wantPlatform  string
+		wantAdmission []string
+	}{
+		{
+			name:          "vanilla Kubernetes",
+			lists:         kubernetesLists,
+			wantPlatform:  platformKubernetes,
+			wantAdmission: []string{"validatingwebhookconfigurations.admissionregistration.k8s.io"},
+		},
+		{
+			name:          "OpenShift is detected from config.openshift.io",
+			lists:         openShiftLists,
+			wantPlatform:  platformOpenShift,
+			wantAdmission: []string{"securitycontextconstraints.security.openshift.io", "validatingwebhookconfigurations.admissionregistration.k8s.io"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := newClusterInfo(rawConfig, "", "v1.30.0", tt.lists)
+			if info.Context != "source" || info.Server != "https://prod.example.com:6443" {
+				t.Errorf("newClusterInfo() context = %q, server = %q, want the current context and its server", info.Context, info.Server)
+			}
+			if info.Platform != tt.wantPlatform {
+				t.Errorf("newClusterInfo() platform = %q, want %q", info.Platform, tt.wantPlatform)
+			}
+			if !reflect.DeepEqual(info.AdmissionResources, tt.wantAdmission) {
+				t.Errorf("newClusterInfo() admission resources = %v, want %v", info.AdmissionResources, tt.wantAdmission)
+			}
+			if len(info.APIResources) != len(tt.lists) || info.APIResources[len(info.APIResources)-1].GroupVersion != "v1" {
+				t.Errorf("newClusterInfo() API resources = %v, want every group version sorted", info.APIResources)
+			}
+			if got := info.APIResources[len(info.APIResources)-1].Resources; !reflect.DeepEqual(got, []string{"configmaps", "secrets"}) {
+				t.Errorf("newClusterInfo() v1 resources = %v, want them sorted", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package exporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// gzipExtension is appended to the resource files written with --compress
+const gzipExtension = ".gz"
+
+// gzipBytes compresses a resource file, the reports at the root of the export are never
+// compressed so that they can be read without the export being unpacked
+func gzipBytes(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	zw := gzip.NewWriter(buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// gunzipBytes decompresses a resource file written with --compress
+func gunzipBytes(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
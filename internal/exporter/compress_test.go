@@ -0,0 +1,79 @@
+package exporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+func readGzipped(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("%s is not gzipped: %v", path, err)
+	}
+	defer reader.Close()
+	data, err = io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func Test_resourceWriter_compress(t *testing.T) {
+	resources := []*groupResource{
+		{APIGroup: "apps", APIVersion: "v1", APIResource: metav1.APIResource{Name: "deployments", Kind: "Deployment"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{testObject()}}},
+	}
+	tests := []struct {
+		layout string
+		file   string
+	}{
+		{layout: LayoutFlat, file: "deployments.apps_hello-world.yaml.gz"},
+		{layout: LayoutKind, file: "apps_deployments/hello-world.yaml.gz"},
+		{layout: LayoutSingle, file: "foo.yaml.gz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.layout, func(t *testing.T) {
+			dir := t.TempDir()
+			manifests := newExportIndex(dir)
+			w := &resourceWriter{
+				resourceDir: dir,
+				output:      OutputYAML,
+				layout:      tt.layout,
+				singleFile:  filepath.Join(dir, "foo.yaml"),
+				workers:     1,
+				compress:    true,
+				index:       manifests,
+				log:         testLogger(),
+			}
+			if errs := w.writeResources(resources); len(errs) > 0 {
+				t.Fatalf("writeResources() errors = %v", errs)
+			}
+
+			data := readGzipped(t, filepath.Join(dir, tt.file))
+			// the single layout writes a multi-document stream
+			data = bytes.TrimPrefix(data, []byte("---\n"))
+			obj := map[string]interface{}{}
+			if err := yaml.Unmarshal(data, &obj); err != nil {
+				t.Fatalf("the gunzipped file is not valid YAML: %v", err)
+			}
+			if u := (unstructured.Unstructured{Object: obj}); u.GetKind() != "Deployment" || u.GetName() != "hello-world" {
+				t.Errorf("gunzipped %s/%s, want Deployment/hello-world", u.GetKind(), u.GetName())
+			}
+			if len(manifests.entries) != 1 || manifests.entries[0].Path != tt.file {
+				t.Errorf("index entries = %v, want %s", manifests.entries, tt.file)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// configValue is the value of a flag in an export config file, a list for the flags taking several
+// values
+type configValue struct {
+	key    string
+	line   int
+	values []string
+	list   bool
+}
+
+// defaultConfigPath returns the config file read when --config is not set,
+// $XDG_CONFIG_HOME/kubectl-migrate/config.yaml or ~/.config/kubectl-migrate/config.yaml
+func defaultConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "kubectl-migrate", "config.yaml")
+}
+
+// readConfig reads an export config file, a YAML mapping of flag names to their value, e.g.
+//
+//	namespace: [frontend, backend]
+//	exclude-resources: events,*.events.k8s.io
+//	qps: 50
+func readConfig(path string) ([]configValue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	// an empty file sets nothing
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s:%d: expected a mapping of flag names to values", path, root.Line)
+	}
+
+	values := []configValue{}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, node := root.Content[i], root.Content[i+1]
+		v := configValue{key: key.Value, line: key.Line}
+		switch node.Kind {
+		case yaml.ScalarNode:
+			v.values = []string{node.Value}
+		case yaml.SequenceNode:
+			v.list = true
+			for _, item := range node.Content {
+				if item.Kind != yaml.ScalarNode {
+					return nil, fmt.Errorf("%s:%d: %s must be a list of values", path, item.Line, v.key)
+				}
+				v.values = append(v.values, item.Value)
+			}
+		default:
+			return nil, fmt.Errorf("%s:%d: %s must be a value or a list of values", path, node.Line, v.key)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// applyConfig sets the flags not given on the command line to their value in the config file,
+// explicit flags taking precedence. Unknown keys are ignored with a warning. It returns the names of
+// the flags set from the config file.
+func applyConfig(flags *pflag.FlagSet, path string, values []configValue, log logrus.FieldLogger) ([]string, error) {
+	applied := []string{}
+	for _, v := range values {
+		f := flags.Lookup(v.key)
+		if f == nil {
+			log.Warnf("%s:%d: unknown key %q, ignoring it", path, v.line, v.key)
+			continue
+		}
+		if f.Changed {
+			continue
+		}
+		// a single value is parsed like on the command line, comma-separated for the list flags
+		var err error
+		slice, isSlice := f.Value.(pflag.SliceValue)
+		switch {
+		case !v.list:
+			err = f.Value.Set(v.values[0])
+		case isSlice:
+			err = slice.Replace(v.values)
+		default:
+			err = errors.New("expected a single value")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid value for %s: %w", path, v.line, v.key, err)
+		}
+		applied = append(applied, v.key)
+	}
+	return applied, nil
+}
+
+// loadConfig applies the config file given with --config, or the default one when it exists, and
+// records the effective value of each flag set and where it comes from
+func (o *ExportOptions) loadConfig(flags *pflag.FlagSet, log logrus.FieldLogger) error {
+	path := o.configFile
+	if path == "" {
+		path = defaultConfigPath()
+		if _, err := os.Stat(path); path == "" || err != nil {
+			path = ""
+		}
+	}
+
+	sources := map[string]string{}
+	flags.Visit(func(f *pflag.Flag) {
+		sources[f.Name] = "flag"
+	})
+	if path != "" {
+		values, err := readConfig(path)
+		if err != nil {
+			return err
+		}
+		applied, err := applyConfig(flags, path, values, log)
+		if err != nil {
+			return err
+		}
+		log.Debugf("using the config file %s", path)
+		for _, name := range applied {
+			sources[name] = "config " + path
+		}
+	}
+
+	o.effectiveConfig = []string{}
+	for _, name := range sortedKeys(sources) {
+		o.effectiveConfig = append(o.effectiveConfig, fmt.Sprintf("--%s=%s (%s)", name, flags.Lookup(name).Value.String(), sources[name]))
+	}
+	return nil
+}
+
+// printEffectiveConfig prints the flags set on the command line or in the config file
+func (o *ExportOptions) printEffectiveConfig(out io.Writer) {
+	fmt.Fprintf(out, "Effective configuration:\n")
+	for _, line := range o.effectiveConfig {
+		fmt.Fprintf(out, "  %s\n", line)
+	}
+}
@@ -0,0 +1,119 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/pflag"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "team.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_applyConfig(t *testing.T) {
+	path := writeConfig(t, `namespace: [frontend, backend]
+exclude-resources: events,*.events.k8s.io
+strip-fields:
+  - Service:spec.clusterIP
+qps: 50
+burst: 200
+export-dir: /archive/team
+colour: blue
+`)
+	values, err := readConfig(path)
+	if err != nil {
+		t.Fatalf("readConfig() error = %v", err)
+	}
+
+	var namespaces, excludes, stripFields []string
+	var qps float32
+	var burst int
+	var exportDir string
+	flags := pflag.NewFlagSet("export", pflag.ContinueOnError)
+	flags.StringSliceVarP(&namespaces, "namespace", "n", nil, "")
+	flags.StringSliceVar(&excludes, "exclude-resources", nil, "")
+	flags.StringArrayVar(&stripFields, "strip-fields", nil, "")
+	flags.Float32Var(&qps, "qps", 100, "")
+	flags.IntVar(&burst, "burst", 1000, "")
+	flags.StringVar(&exportDir, "export-dir", "export", "")
+	// the flags given on the command line take precedence over the config file
+	if err := flags.Parse([]string{"--qps", "10", "-n", "payments"}); err != nil {
+		t.Fatal(err)
+	}
+
+	log, hook := logtest.NewNullLogger()
+	applied, err := applyConfig(flags, path, values, log)
+	if err != nil {
+		t.Fatalf("applyConfig() error = %v", err)
+	}
+
+	if want := []string{"exclude-resources", "strip-fields", "burst", "export-dir"}; !reflect.DeepEqual(applied, want) {
+		t.Errorf("applied %v, want %v", applied, want)
+	}
+	if !reflect.DeepEqual(namespaces, []string{"payments"}) {
+		t.Errorf("namespace = %v, want the command line value", namespaces)
+	}
+	if qps != 10 {
+		t.Errorf("qps = %v, want the command line value", qps)
+	}
+	if !reflect.DeepEqual(excludes, []string{"events", "*.events.k8s.io"}) {
+		t.Errorf("exclude-resources = %v", excludes)
+	}
+	if !reflect.DeepEqual(stripFields, []string{"Service:spec.clusterIP"}) {
+		t.Errorf("strip-fields = %v", stripFields)
+	}
+	if burst != 200 || exportDir != "/archive/team" {
+		t.Errorf("burst = %d, export-dir = %s, want the config values", burst, exportDir)
+	}
+
+	warnings := []string{}
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == logrus.WarnLevel {
+			warnings = append(warnings, entry.Message)
+		}
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], path+":8") || !strings.Contains(warnings[0], "colour") {
+		t.Errorf("warnings = %v, want the unknown key with its file and line", warnings)
+	}
+}
+
+func Test_applyConfig_invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "malformed YAML", content: "namespace: [frontend\n", wantErr: "team.yaml"},
+		{name: "not a mapping", content: "- frontend\n", wantErr: "team.yaml:1"},
+		{name: "nested value", content: "qps:\n  value: 10\n", wantErr: "team.yaml:2"},
+		{name: "invalid value", content: "workers: many\n", wantErr: "team.yaml:1: invalid value for workers"},
+		{name: "list for a single value", content: "workers: [1, 2]\n", wantErr: "team.yaml:1: invalid value for workers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, tt.content)
+			var workers int
+			flags := pflag.NewFlagSet("export", pflag.ContinueOnError)
+			flags.IntVar(&workers, "workers", 4, "")
+
+			values, err := readConfig(path)
+			if err == nil {
+				_, err = applyConfig(flags, path, values, testLogger())
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package exporter
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var CRDGVR = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+// crdCollector fetches the CustomResourceDefinitions backing the exported custom resources.
+// Each CRD is collected once per run, even when several namespaces use it.
+type crdCollector struct {
+	client dynamic.Interface
+	log    logrus.FieldLogger
+	// seen records the CRD names already looked up, with the namespace they were exported with
+	seen map[string]string
+}
+
+func newCRDCollector(client dynamic.Interface, log logrus.FieldLogger) *crdCollector {
+	return &crdCollector{client: client, log: log, seen: map[string]string{}}
+}
+
+// collect returns the CRDs of the resources that were not collected for a previous namespace
+func (c *crdCollector) collect(ctx context.Context, namespace string, resources []*groupResource) *groupResource {
+	crds := &groupResource{
+		APIGroup:        CRDGVR.Group,
+		APIVersion:      CRDGVR.Version,
+		APIGroupVersion: CRDGVR.GroupVersion().String(),
+		APIResource:     metav1.APIResource{Name: CRDGVR.Resource, Kind: "CustomResourceDefinition"},
+		objects:         &unstructured.UnstructuredList{Items: []unstructured.Unstructured{}},
+	}
+	for _, r := range resources {
+		// built-in groups have no dots, CRD groups must have at least one
+		if !strings.Contains(r.APIGroup, ".") {
+			continue
+		}
+		name := r.APIResource.Name + "." + r.APIGroup
+		if exportedWith, ok := c.seen[name]; ok {
+			if exportedWith != "" {
+				c.log.Debugf("CRD %s already exported with namespace %s", name, exportedWith)
+			}
+			continue
+		}
+		c.seen[name] = ""
+
+		crd, err := c.client.Resource(CRDGVR).Get(ctx, name, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			c.log.Debugf("resource %s is not backed by a CRD", name)
+			continue
+		case apierrors.IsForbidden(err):
+			c.log.Warnf("cannot read CRD %s, skipping it: %v", name, err)
+			continue
+		case err != nil:
+			c.log.Warnf("error getting CRD %s, skipping it: %v", name, err)
+			continue
+		}
+		c.seen[name] = namespace
+		c.log.Infof("Adding CRD %s", name)
+		crds.objects.Items = append(crds.objects.Items, *crd)
+	}
+	return crds
+}
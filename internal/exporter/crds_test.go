@@ -0,0 +1,38 @@
+package exporter
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func Test_crdCollector(t *testing.T) {
+	crd := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": "widgets.example.com"},
+	}}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{CRDGVR: "CustomResourceDefinitionList"}, crd)
+	resources := []*groupResource{
+		{APIGroup: "apps", APIResource: metav1.APIResource{Name: "deployments"}},
+		{APIGroup: "example.com", APIResource: metav1.APIResource{Name: "widgets"}},
+		{APIGroup: "other.example.com", APIResource: metav1.APIResource{Name: "gadgets"}},
+	}
+
+	c := newCRDCollector(client, testLogger())
+	crds := c.collect(context.Background(), "foo", resources)
+	if len(crds.objects.Items) != 1 || crds.objects.Items[0].GetName() != "widgets.example.com" {
+		t.Errorf("collect() = %v, want the widgets.example.com CRD", crds.objects.Items)
+	}
+
+	crds = c.collect(context.Background(), "bar", resources)
+	if len(crds.objects.Items) != 0 {
+		t.Errorf("collect() should not collect a CRD twice, got %v", crds.objects.Items)
+	}
+}
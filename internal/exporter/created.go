@@ -0,0 +1,97 @@
+package exporter
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// reasonCreatedOutside is the reason of the objects skipped by --created-after and --created-before
+const reasonCreatedOutside = "created outside of the time window"
+
+// createdWindow keeps the objects created after After and before Before, per their
+// metadata.creationTimestamp. It is recorded in the export summary with the objects it filtered.
+type createdWindow struct {
+	After    *time.Time `json:"after,omitempty"`
+	Before   *time.Time `json:"before,omitempty"`
+	Filtered int        `json:"filtered"`
+}
+
+// newCreatedWindow parses --created-after and --created-before, a duration before now like 24h or
+// an RFC3339 timestamp. It returns nil when neither is set.
+func newCreatedWindow(after string, before string, now time.Time) (*createdWindow, error) {
+	if after == "" && before == "" {
+		return nil, nil
+	}
+	w := &createdWindow{}
+	var err error
+	if w.After, err = parseCreatedTime("--created-after", after, now); err != nil {
+		return nil, err
+	}
+	if w.Before, err = parseCreatedTime("--created-before", before, now); err != nil {
+		return nil, err
+	}
+	if w.After != nil && w.Before != nil && !w.After.Before(*w.Before) {
+		return nil, fmt.Errorf("--created-after %s must be before --created-before %s", w.After.Format(time.RFC3339), w.Before.Format(time.RFC3339))
+	}
+	return w, nil
+}
+
+func parseCreatedTime(flag string, value string, now time.Time) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		if d < 0 {
+			return nil, fmt.Errorf("invalid %s %q, the duration must not be negative", flag, value)
+		}
+		t := now.Add(-d).UTC()
+		return &t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q, must be a duration like 24h or an RFC3339 timestamp", flag, value)
+	}
+	t = t.UTC()
+	return &t, nil
+}
+
+// skip reports whether the object was created outside of the window, the objects without creation
+// timestamp are kept
+func (w *createdWindow) skip(obj unstructured.Unstructured) bool {
+	created := obj.GetCreationTimestamp()
+	if created.IsZero() {
+		return false
+	}
+	if w.After != nil && created.Time.Before(*w.After) {
+		return true
+	}
+	return w.Before != nil && !created.Time.Before(*w.Before)
+}
+
+// summarize returns the window with the objects it filtered in the namespaces, nil without window
+func (w *createdWindow) summarize(summaries []*exportSummary) *createdWindow {
+	if w == nil {
+		return nil
+	}
+	s := *w
+	s.Filtered = 0
+	for _, ns := range summaries {
+		for _, count := range ns.Skipped[reasonCreatedOutside] {
+			s.Filtered += count
+		}
+	}
+	return &s
+}
+
+func (w *createdWindow) String() string {
+	switch {
+	case w.After != nil && w.Before != nil:
+		return "between " + w.After.Format(time.RFC3339) + " and " + w.Before.Format(time.RFC3339)
+	case w.After != nil:
+		return "after " + w.After.Format(time.RFC3339)
+	default:
+		return "before " + w.Before.Format(time.RFC3339)
+	}
+}
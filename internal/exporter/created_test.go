@@ -0,0 +1,162 @@
+package exporter
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_newCreatedWindow(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		after      string
+		before     string
+		wantAfter  string
+		wantBefore string
+		wantNil    bool
+		wantErr    bool
+	}{
+		{
+			name:    "given no flag, should return no window",
+			wantNil: true,
+		},
+		{
+			name:      "given a duration, should count it back from now",
+			after:     "24h",
+			wantAfter: "2024-05-31T12:00:00Z",
+		},
+		{
+			name:       "given timestamps, should keep them",
+			after:      "2024-01-01T00:00:00Z",
+			before:     "2024-02-01T00:00:00+01:00",
+			wantAfter:  "2024-01-01T00:00:00Z",
+			wantBefore: "2024-01-31T23:00:00Z",
+		},
+		{
+			name:    "given an after later than the before, should fail",
+			after:   "1h",
+			before:  "24h",
+			wantErr: true,
+		},
+		{
+			name:    "given a negative duration, should fail",
+			before:  "-1h",
+			wantErr: true,
+		},
+		{
+			name:    "given an invalid value, should fail",
+			after:   "yesterday",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := newCreatedWindow(tt.after, tt.before, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newCreatedWindow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (w == nil) != tt.wantNil {
+				t.Fatalf("newCreatedWindow() = %v, want nil %v", w, tt.wantNil)
+			}
+			if w == nil {
+				return
+			}
+			format := func(t *time.Time) string {
+				if t == nil {
+					return ""
+				}
+				return t.Format(time.RFC3339)
+			}
+			if got := format(w.After); got != tt.wantAfter {
+				t.Errorf("newCreatedWindow() after = %s, want %s", got, tt.wantAfter)
+			}
+			if got := format(w.Before); got != tt.wantBefore {
+				t.Errorf("newCreatedWindow() before = %s, want %s", got, tt.wantBefore)
+			}
+		})
+	}
+}
+
+func Test_applyObjectFilters_created(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	object := func(name string, age time.Duration) unstructured.Unstructured {
+		obj := testOwnedObject("ConfigMap", name)
+		if age > 0 {
+			obj.SetCreationTimestamp(metav1.NewTime(now.Add(-age)))
+		}
+		return obj
+	}
+	objects := []unstructured.Unstructured{
+		object("web-new", time.Hour),
+		object("web-old", 400*24*time.Hour),
+		object("db-new", 2*time.Hour),
+		object("web-unknown", 0),
+	}
+	tests := []struct {
+		name         string
+		after        string
+		before       string
+		nameRe       string
+		wantKept     []string
+		wantFiltered int
+	}{
+		{
+			name:         "given created after, should keep the recent objects",
+			after:        "24h",
+			wantKept:     []string{"web-new", "db-new", "web-unknown"},
+			wantFiltered: 1,
+		},
+		{
+			name:         "given created before, should keep the old objects",
+			before:       "8760h",
+			wantKept:     []string{"web-old", "web-unknown"},
+			wantFiltered: 2,
+		},
+		{
+			name:         "given a name regex, should compose with it",
+			after:        "24h",
+			nameRe:       `^web-`,
+			wantKept:     []string{"web-new", "web-unknown"},
+			wantFiltered: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := make([]unstructured.Unstructured, len(objects))
+			for i := range objects {
+				items[i] = *objects[i].DeepCopy()
+			}
+			resources := []*groupResource{{objects: &unstructured.UnstructuredList{Items: items}}}
+			summary := newExportSummary("foo")
+			o := &ExportOptions{includeOwned: true}
+			var err error
+			if o.created, err = newCreatedWindow(tt.after, tt.before, now); err != nil {
+				t.Fatal(err)
+			}
+			if tt.nameRe != "" {
+				o.nameRe = regexp.MustCompile(tt.nameRe)
+			}
+
+			applyObjectFilters(resources, o.objectFilters(resources), summary, testLogger())
+
+			kept := []string{}
+			for _, obj := range resources[0].objects.Items {
+				kept = append(kept, obj.GetName())
+			}
+			if !reflect.DeepEqual(kept, tt.wantKept) {
+				t.Errorf("kept %v, want %v", kept, tt.wantKept)
+			}
+			if got := o.created.summarize([]*exportSummary{summary}); got.Filtered != tt.wantFiltered {
+				t.Errorf("summarize() filtered = %d, want %d", got.Filtered, tt.wantFiltered)
+			}
+		})
+	}
+}
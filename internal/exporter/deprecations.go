@@ -0,0 +1,168 @@
+package exporter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// apiDeprecation is a served API version of a kind deprecated in a Kubernetes minor version and
+// removed in a later one. Replacement is empty when the kind was removed altogether.
+type apiDeprecation struct {
+	GroupVersion string
+	Kind         string
+	DeprecatedIn int
+	RemovedIn    int
+	Replacement  string
+}
+
+// apiDeprecations are the deprecated API versions of the built-in kinds, by Kubernetes 1.x minor
+// version, from https://kubernetes.io/docs/reference/using-api/deprecation-guide/
+var apiDeprecations = []apiDeprecation{
+	{GroupVersion: "extensions/v1beta1", Kind: "Deployment", DeprecatedIn: 9, RemovedIn: 16, Replacement: "apps/v1"},
+	{GroupVersion: "extensions/v1beta1", Kind: "DaemonSet", DeprecatedIn: 9, RemovedIn: 16, Replacement: "apps/v1"},
+	{GroupVersion: "extensions/v1beta1", Kind: "ReplicaSet", DeprecatedIn: 9, RemovedIn: 16, Replacement: "apps/v1"},
+	{GroupVersion: "extensions/v1beta1", Kind: "NetworkPolicy", DeprecatedIn: 9, RemovedIn: 16, Replacement: "networking.k8s.io/v1"},
+	{GroupVersion: "extensions/v1beta1", Kind: "PodSecurityPolicy", DeprecatedIn: 10, RemovedIn: 16, Replacement: "policy/v1beta1"},
+	{GroupVersion: "apps/v1beta1", Kind: "Deployment", DeprecatedIn: 9, RemovedIn: 16, Replacement: "apps/v1"},
+	{GroupVersion: "apps/v1beta1", Kind: "StatefulSet", DeprecatedIn: 9, RemovedIn: 16, Replacement: "apps/v1"},
+	{GroupVersion: "apps/v1beta2", Kind: "Deployment", DeprecatedIn: 9, RemovedIn: 16, Replacement: "apps/v1"},
+	{GroupVersion: "apps/v1beta2", Kind: "StatefulSet", DeprecatedIn: 9, RemovedIn: 16, Replacement: "apps/v1"},
+	{GroupVersion: "apps/v1beta2", Kind: "DaemonSet", DeprecatedIn: 9, RemovedIn: 16, Replacement: "apps/v1"},
+	{GroupVersion: "apps/v1beta2", Kind: "ReplicaSet", DeprecatedIn: 9, RemovedIn: 16, Replacement: "apps/v1"},
+	{GroupVersion: "extensions/v1beta1", Kind: "Ingress", DeprecatedIn: 14, RemovedIn: 22, Replacement: "networking.k8s.io/v1"},
+	{GroupVersion: "networking.k8s.io/v1beta1", Kind: "Ingress", DeprecatedIn: 19, RemovedIn: 22, Replacement: "networking.k8s.io/v1"},
+	{GroupVersion: "networking.k8s.io/v1beta1", Kind: "IngressClass", DeprecatedIn: 19, RemovedIn: 22, Replacement: "networking.k8s.io/v1"},
+	{GroupVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "Role", DeprecatedIn: 17, RemovedIn: 22, Replacement: "rbac.authorization.k8s.io/v1"},
+	{GroupVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "RoleBinding", DeprecatedIn: 17, RemovedIn: 22, Replacement: "rbac.authorization.k8s.io/v1"},
+	{GroupVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "ClusterRole", DeprecatedIn: 17, RemovedIn: 22, Replacement: "rbac.authorization.k8s.io/v1"},
+	{GroupVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "ClusterRoleBinding", DeprecatedIn: 17, RemovedIn: 22, Replacement: "rbac.authorization.k8s.io/v1"},
+	{GroupVersion: "apiextensions.k8s.io/v1beta1", Kind: "CustomResourceDefinition", DeprecatedIn: 16, RemovedIn: 22, Replacement: "apiextensions.k8s.io/v1"},
+	{GroupVersion: "admissionregistration.k8s.io/v1beta1", Kind: "MutatingWebhookConfiguration", DeprecatedIn: 16, RemovedIn: 22, Replacement: "admissionregistration.k8s.io/v1"},
+	{GroupVersion: "admissionregistration.k8s.io/v1beta1", Kind: "ValidatingWebhookConfiguration", DeprecatedIn: 16, RemovedIn: 22, Replacement: "admissionregistration.k8s.io/v1"},
+	{GroupVersion: "scheduling.k8s.io/v1beta1", Kind: "PriorityClass", DeprecatedIn: 14, RemovedIn: 22, Replacement: "scheduling.k8s.io/v1"},
+	{GroupVersion: "coordination.k8s.io/v1beta1", Kind: "Lease", DeprecatedIn: 14, RemovedIn: 22, Replacement: "coordination.k8s.io/v1"},
+	{GroupVersion: "certificates.k8s.io/v1beta1", Kind: "CertificateSigningRequest", DeprecatedIn: 19, RemovedIn: 22, Replacement: "certificates.k8s.io/v1"},
+	{GroupVersion: "storage.k8s.io/v1beta1", Kind: "StorageClass", DeprecatedIn: 19, RemovedIn: 22, Replacement: "storage.k8s.io/v1"},
+	{GroupVersion: "storage.k8s.io/v1beta1", Kind: "VolumeAttachment", DeprecatedIn: 19, RemovedIn: 22, Replacement: "storage.k8s.io/v1"},
+	{GroupVersion: "storage.k8s.io/v1beta1", Kind: "CSIDriver", DeprecatedIn: 19, RemovedIn: 22, Replacement: "storage.k8s.io/v1"},
+	{GroupVersion: "storage.k8s.io/v1beta1", Kind: "CSINode", DeprecatedIn: 19, RemovedIn: 22, Replacement: "storage.k8s.io/v1"},
+	{GroupVersion: "batch/v1beta1", Kind: "CronJob", DeprecatedIn: 21, RemovedIn: 25, Replacement: "batch/v1"},
+	{GroupVersion: "discovery.k8s.io/v1beta1", Kind: "EndpointSlice", DeprecatedIn: 21, RemovedIn: 25, Replacement: "discovery.k8s.io/v1"},
+	{GroupVersion: "events.k8s.io/v1beta1", Kind: "Event", DeprecatedIn: 19, RemovedIn: 25, Replacement: "events.k8s.io/v1"},
+	{GroupVersion: "policy/v1beta1", Kind: "PodDisruptionBudget", DeprecatedIn: 21, RemovedIn: 25, Replacement: "policy/v1"},
+	{GroupVersion: "policy/v1beta1", Kind: "PodSecurityPolicy", DeprecatedIn: 21, RemovedIn: 25},
+	{GroupVersion: "node.k8s.io/v1beta1", Kind: "RuntimeClass", DeprecatedIn: 20, RemovedIn: 25, Replacement: "node.k8s.io/v1"},
+	{GroupVersion: "autoscaling/v2beta1", Kind: "HorizontalPodAutoscaler", DeprecatedIn: 22, RemovedIn: 25, Replacement: "autoscaling/v2"},
+	{GroupVersion: "autoscaling/v2beta2", Kind: "HorizontalPodAutoscaler", DeprecatedIn: 23, RemovedIn: 26, Replacement: "autoscaling/v2"},
+	{GroupVersion: "flowcontrol.apiserver.k8s.io/v1beta1", Kind: "FlowSchema", DeprecatedIn: 23, RemovedIn: 26, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{GroupVersion: "flowcontrol.apiserver.k8s.io/v1beta1", Kind: "PriorityLevelConfiguration", DeprecatedIn: 23, RemovedIn: 26, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{GroupVersion: "flowcontrol.apiserver.k8s.io/v1beta2", Kind: "FlowSchema", DeprecatedIn: 26, RemovedIn: 29, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{GroupVersion: "flowcontrol.apiserver.k8s.io/v1beta2", Kind: "PriorityLevelConfiguration", DeprecatedIn: 26, RemovedIn: 29, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{GroupVersion: "flowcontrol.apiserver.k8s.io/v1beta3", Kind: "FlowSchema", DeprecatedIn: 29, RemovedIn: 32, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{GroupVersion: "flowcontrol.apiserver.k8s.io/v1beta3", Kind: "PriorityLevelConfiguration", DeprecatedIn: 29, RemovedIn: 32, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{GroupVersion: "storage.k8s.io/v1beta1", Kind: "CSIStorageCapacity", DeprecatedIn: 24, RemovedIn: 27, Replacement: "storage.k8s.io/v1"},
+}
+
+// DeprecatedObject is an exported object stored at an API version deprecated, or removed, in the
+// Kubernetes version the export is evaluated against
+type DeprecatedObject struct {
+	Object       string `json:"object"`
+	APIVersion   string `json:"apiVersion"`
+	Replacement  string `json:"replacement,omitempty"`
+	DeprecatedIn string `json:"deprecatedIn"`
+	RemovedIn    string `json:"removedIn"`
+	// Removed is set when the API version is no longer served by the target version
+	Removed bool `json:"removed,omitempty"`
+}
+
+func (d DeprecatedObject) String() string {
+	status := "deprecated in " + d.DeprecatedIn + " and removed in " + d.RemovedIn
+	if d.Removed {
+		status = "removed in " + d.RemovedIn
+	}
+	replacement := "no replacement"
+	if d.Replacement != "" {
+		replacement = "use " + d.Replacement
+	}
+	return fmt.Sprintf("%s %s is %s, %s", d.Object, d.APIVersion, status, replacement)
+}
+
+var kubeVersionRe = regexp.MustCompile(`^v?1\.(\d+)(\.\d+)?([-+].*)?$`)
+
+// ParseMinorVersion returns the minor version of a 1.x Kubernetes version, e.g. 1.25, v1.25.3 or
+// v1.27.3+k3s1
+func ParseMinorVersion(version string) (int, error) {
+	m := kubeVersionRe.FindStringSubmatch(version)
+	if m == nil {
+		return 0, fmt.Errorf("invalid Kubernetes version %q, expected e.g. 1.29", version)
+	}
+	return strconv.Atoi(m[1])
+}
+
+// deprecationTarget returns the minor version given with --target-version, or the one of the
+// source cluster
+func (o *ExportOptions) deprecationTarget(serverVersion string, log logrus.FieldLogger) int {
+	version := o.targetVersion
+	if version == "" {
+		version = serverVersion
+	}
+	target, err := ParseMinorVersion(version)
+	if err != nil {
+		log.Warnf("cannot tell the target Kubernetes version, reporting every deprecated API version: %v", err)
+		return 0
+	}
+	log.Debugf("checking the API versions for deprecations in Kubernetes 1.%d", target)
+	return target
+}
+
+// findDeprecated returns the objects stored at an API version deprecated in the target minor
+// version or earlier. Every deprecated API version is reported when the target is not known.
+func findDeprecated(resources []*groupResource, target int) []DeprecatedObject {
+	found := []DeprecatedObject{}
+	for _, r := range resources {
+		if r.objects == nil {
+			continue
+		}
+		found = append(found, DeprecatedObjects(r.objects.Items, target)...)
+	}
+	return found
+}
+
+// DeprecatedObjects returns the objects of the list stored at a deprecated API version, like
+// findDeprecated
+func DeprecatedObjects(objects []unstructured.Unstructured, target int) []DeprecatedObject {
+	found := []DeprecatedObject{}
+	for _, obj := range objects {
+		for _, d := range apiDeprecations {
+			if d.GroupVersion != obj.GetAPIVersion() || d.Kind != obj.GetKind() {
+				continue
+			}
+			if target > 0 && target < d.DeprecatedIn {
+				continue
+			}
+			found = append(found, DeprecatedObject{
+				Object:       obj.GetKind() + "/" + obj.GetName(),
+				APIVersion:   d.GroupVersion,
+				Replacement:  d.Replacement,
+				DeprecatedIn: fmt.Sprintf("1.%d", d.DeprecatedIn),
+				RemovedIn:    fmt.Sprintf("1.%d", d.RemovedIn),
+				Removed:      target >= d.RemovedIn,
+			})
+		}
+	}
+	return found
+}
+
+// DeprecatedAPIError is returned with --fail-on-deprecated when exported objects are stored at
+// deprecated API versions, the export itself is complete
+type DeprecatedAPIError struct {
+	Objects int
+}
+
+func (e *DeprecatedAPIError) Error() string {
+	return fmt.Sprintf("%d exported objects use deprecated API versions, see %s", e.Objects, summaryTextFile)
+}
@@ -0,0 +1,123 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_apiDeprecations(t *testing.T) {
+	seen := map[string]bool{}
+	for _, d := range apiDeprecations {
+		key := d.GroupVersion + "/" + d.Kind
+		if seen[key] {
+			t.Errorf("%s is listed twice", key)
+		}
+		seen[key] = true
+		if d.DeprecatedIn >= d.RemovedIn {
+			t.Errorf("%s is removed in 1.%d, not after its deprecation in 1.%d", key, d.RemovedIn, d.DeprecatedIn)
+		}
+		if d.Replacement == d.GroupVersion {
+			t.Errorf("%s is its own replacement", key)
+		}
+	}
+}
+
+func Test_parseMinorVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    int
+		wantErr bool
+	}{
+		{version: "1.25", want: 25},
+		{version: "v1.29.4", want: 29},
+		{version: "v1.27.3+k3s1", want: 27},
+		{version: "v1.28.5-eks-5e0fdde", want: 28},
+		{version: "", wantErr: true},
+		{version: "2.1", wantErr: true},
+		{version: "latest", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, err := ParseMinorVersion(tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMinorVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseMinorVersion() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_findDeprecated(t *testing.T) {
+	object := func(apiVersion, kind, name string) unstructured.Unstructured {
+		obj := unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetName(name)
+		return obj
+	}
+	resources := []*groupResource{
+		{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+			object("policy/v1beta1", "PodDisruptionBudget", "web"),
+			object("policy/v1", "PodDisruptionBudget", "api"),
+		}}},
+		{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+			object("batch/v1beta1", "CronJob", "cleanup"),
+		}}},
+		{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+			object("autoscaling/v2beta2", "HorizontalPodAutoscaler", "web"),
+		}}},
+		{},
+	}
+	pdb := DeprecatedObject{Object: "PodDisruptionBudget/web", APIVersion: "policy/v1beta1", Replacement: "policy/v1", DeprecatedIn: "1.21", RemovedIn: "1.25"}
+	cronJob := DeprecatedObject{Object: "CronJob/cleanup", APIVersion: "batch/v1beta1", Replacement: "batch/v1", DeprecatedIn: "1.21", RemovedIn: "1.25"}
+	hpa := DeprecatedObject{Object: "HorizontalPodAutoscaler/web", APIVersion: "autoscaling/v2beta2", Replacement: "autoscaling/v2", DeprecatedIn: "1.23", RemovedIn: "1.26"}
+	removed := func(d DeprecatedObject) DeprecatedObject {
+		d.Removed = true
+		return d
+	}
+
+	tests := []struct {
+		name   string
+		target int
+		want   []DeprecatedObject
+	}{
+		{
+			name:   "given a target before the deprecations, should report nothing",
+			target: 20,
+			want:   []DeprecatedObject{},
+		},
+		{
+			name:   "given a target deprecating some API versions, should report their objects",
+			target: 22,
+			want:   []DeprecatedObject{pdb, cronJob},
+		},
+		{
+			name:   "given a target removing some API versions, should report them as removed",
+			target: 25,
+			want:   []DeprecatedObject{removed(pdb), removed(cronJob), hpa},
+		},
+		{
+			name:   "given no target, should report every deprecated API version",
+			target: 0,
+			want:   []DeprecatedObject{pdb, cronJob, hpa},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findDeprecated(resources, tt.target); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findDeprecated() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_deprecatedObject_String(t *testing.T) {
+	d := DeprecatedObject{Object: "PodSecurityPolicy/restricted", APIVersion: "policy/v1beta1", DeprecatedIn: "1.21", RemovedIn: "1.25", Removed: true}
+	if got, want := d.String(), "PodSecurityPolicy/restricted policy/v1beta1 is removed in 1.25, no replacement"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,172 @@
+package exporter
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// ClusterDependency is a field of namespaced objects naming a cluster-scoped object they need on
+// the target cluster. A "*" in the path matches every item of a list.
+type ClusterDependency struct {
+	// kinds are the kinds of the referencing objects
+	kinds []string
+	// podSpec makes path relative to the pod spec of the workload kinds
+	podSpec  bool
+	path     []string
+	Resource schema.GroupVersionResource
+	Kind     string
+}
+
+var (
+	PriorityClassesGVR = schema.GroupVersionResource{Group: "scheduling.k8s.io", Version: "v1", Resource: "priorityclasses"}
+	RuntimeClassesGVR  = schema.GroupVersionResource{Group: "node.k8s.io", Version: "v1", Resource: "runtimeclasses"}
+	StorageClassesGVR  = schema.GroupVersionResource{Group: "storage.k8s.io", Version: "v1", Resource: "storageclasses"}
+	IngressClassesGVR  = schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "ingressclasses"}
+)
+
+// ClusterDependencies are the well-known references followed by --include-cluster-deps. The CRDs
+// of the custom resources are collected by the crdCollector.
+var ClusterDependencies = []ClusterDependency{
+	{podSpec: true, path: []string{"priorityClassName"}, Resource: PriorityClassesGVR, Kind: "PriorityClass"},
+	{podSpec: true, path: []string{"runtimeClassName"}, Resource: RuntimeClassesGVR, Kind: "RuntimeClass"},
+	{kinds: []string{"PersistentVolumeClaim"}, path: []string{"spec", "storageClassName"}, Resource: StorageClassesGVR, Kind: "StorageClass"},
+	{kinds: []string{"StatefulSet"}, path: []string{"spec", "volumeClaimTemplates", "*", "spec", "storageClassName"}, Resource: StorageClassesGVR, Kind: "StorageClass"},
+	{kinds: []string{"Ingress"}, path: []string{"spec", "ingressClassName"}, Resource: IngressClassesGVR, Kind: "IngressClass"},
+}
+
+// References returns the names referenced by the object through the dependency field
+func (d ClusterDependency) References(obj unstructured.Unstructured) []string {
+	path := d.path
+	if d.podSpec {
+		specPath, ok := podSpecPaths[obj.GetKind()]
+		if !ok {
+			return nil
+		}
+		path = append(append([]string{}, specPath...), d.path...)
+	} else if !containsString(d.kinds, obj.GetKind()) {
+		return nil
+	}
+	return nestedStrings(obj.Object, path)
+}
+
+func nestedStrings(obj interface{}, path []string) []string {
+	if len(path) == 0 {
+		if s, ok := obj.(string); ok && s != "" {
+			return []string{s}
+		}
+		return nil
+	}
+	if path[0] == "*" {
+		items, _ := obj.([]interface{})
+		values := []string{}
+		for _, item := range items {
+			values = append(values, nestedStrings(item, path[1:])...)
+		}
+		return values
+	}
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return nestedStrings(m[path[0]], path[1:])
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// clusterReference is a reference of an exported object to a cluster-scoped one, reported in the
+// summary
+type clusterReference struct {
+	Kind         string `json:"kind"`
+	Name         string `json:"name"`
+	ReferencedBy string `json:"referencedBy"`
+	Resolved     bool   `json:"resolved"`
+	// ExportedWith is the namespace the object was written with, when it was not this one
+	ExportedWith string `json:"exportedWith,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// clusterDepsCollector fetches the cluster-scoped objects referenced by the exported objects.
+// Each object is fetched once per run, even when several namespaces reference it.
+type clusterDepsCollector struct {
+	client dynamic.Interface
+	log    logrus.FieldLogger
+	// seen records the lookups of previous namespaces by kind/name
+	seen map[string]clusterReference
+}
+
+func newClusterDepsCollector(client dynamic.Interface, log logrus.FieldLogger) *clusterDepsCollector {
+	return &clusterDepsCollector{client: client, log: log, seen: map[string]clusterReference{}}
+}
+
+// collect returns the referenced objects not collected for a previous namespace, one resource per
+// kind, and every reference of the resources
+func (c *clusterDepsCollector) collect(ctx context.Context, namespace string, resources []*groupResource) ([]*groupResource, []clusterReference) {
+	collected := map[schema.GroupVersionResource]*groupResource{}
+	order := []*groupResource{}
+	references := []clusterReference{}
+	referenced := map[string]bool{}
+
+	for _, r := range resources {
+		if r.objects == nil {
+			continue
+		}
+		for _, obj := range r.objects.Items {
+			for _, d := range ClusterDependencies {
+				for _, name := range d.References(obj) {
+					key := d.Kind + "/" + name
+					if referenced[key] {
+						continue
+					}
+					referenced[key] = true
+
+					ref, seen := c.seen[key]
+					if seen {
+						ref.ReferencedBy = obj.GetKind() + "/" + obj.GetName()
+						references = append(references, ref)
+						continue
+					}
+					ref = clusterReference{Kind: d.Kind, Name: name, ReferencedBy: obj.GetKind() + "/" + obj.GetName()}
+					dep, err := c.client.Resource(d.Resource).Get(ctx, name, metav1.GetOptions{})
+					if err != nil {
+						c.log.Warnf("cannot get %s %s referenced by %s: %v", d.Kind, name, ref.ReferencedBy, err)
+						ref.Error = err.Error()
+						c.seen[key] = ref
+						references = append(references, ref)
+						continue
+					}
+					ref.Resolved = true
+					c.seen[key] = clusterReference{Kind: d.Kind, Name: name, Resolved: true, ExportedWith: namespace}
+					references = append(references, ref)
+
+					c.log.Infof("Adding %s %s referenced by %s", d.Kind, name, ref.ReferencedBy)
+					deps, ok := collected[d.Resource]
+					if !ok {
+						deps = &groupResource{
+							APIGroup:        d.Resource.Group,
+							APIVersion:      d.Resource.Version,
+							APIGroupVersion: d.Resource.GroupVersion().String(),
+							APIResource:     metav1.APIResource{Name: d.Resource.Resource, Kind: d.Kind},
+							objects:         &unstructured.UnstructuredList{Items: []unstructured.Unstructured{}},
+						}
+						collected[d.Resource] = deps
+						order = append(order, deps)
+					}
+					deps.objects.Items = append(deps.objects.Items, *dep)
+				}
+			}
+		}
+	}
+	return order, references
+}
@@ -0,0 +1,76 @@
+package exporter
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func Test_clusterDepsCollector(t *testing.T) {
+	clusterObject := func(apiVersion, kind, name string) runtime.Object {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": apiVersion,
+			"kind":       kind,
+			"metadata":   map[string]interface{}{"name": name},
+		}}
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			PriorityClassesGVR: "PriorityClassList",
+			StorageClassesGVR:  "StorageClassList",
+		},
+		clusterObject("scheduling.k8s.io/v1", "PriorityClass", "high"),
+		clusterObject("storage.k8s.io/v1", "StorageClass", "fast"),
+	)
+
+	deployment := testObject()
+	if err := unstructured.SetNestedField(deployment.Object, "high", "spec", "template", "spec", "priorityClassName"); err != nil {
+		t.Fatal(err)
+	}
+	statefulSet := testOwnedObject("StatefulSet", "db")
+	if err := unstructured.SetNestedSlice(statefulSet.Object, []interface{}{
+		map[string]interface{}{"spec": map[string]interface{}{"storageClassName": "fast"}},
+	}, "spec", "volumeClaimTemplates"); err != nil {
+		t.Fatal(err)
+	}
+	pvc := testOwnedObject("PersistentVolumeClaim", "data")
+	if err := unstructured.SetNestedField(pvc.Object, "deleted", "spec", "storageClassName"); err != nil {
+		t.Fatal(err)
+	}
+	resource := func(objects ...unstructured.Unstructured) *groupResource {
+		return &groupResource{objects: &unstructured.UnstructuredList{Items: objects}}
+	}
+	resources := []*groupResource{resource(deployment), resource(statefulSet, pvc)}
+
+	c := newClusterDepsCollector(client, testLogger())
+	deps, references := c.collect(context.Background(), "foo", resources)
+	got := []string{}
+	for _, r := range deps {
+		for _, obj := range r.objects.Items {
+			got = append(got, r.APIResource.Name+"/"+obj.GetName())
+		}
+	}
+	if want := []string{"priorityclasses/high", "storageclasses/fast"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("collect() objects = %v, want %v", got, want)
+	}
+	if len(references) != 3 {
+		t.Fatalf("collect() references = %+v, want 3", references)
+	}
+	if ref := references[2]; ref.Resolved || ref.Name != "deleted" || ref.ReferencedBy != "PersistentVolumeClaim/data" || ref.Error == "" {
+		t.Errorf("collect() reference = %+v, want the unresolved deleted StorageClass", ref)
+	}
+
+	deps, references = c.collect(context.Background(), "bar", resources)
+	if len(deps) != 0 {
+		t.Errorf("collect() should not collect an object twice, got %v", deps)
+	}
+	want := clusterReference{Kind: "PriorityClass", Name: "high", ReferencedBy: "Deployment/hello-world", Resolved: true, ExportedWith: "foo"}
+	if references[0] != want {
+		t.Errorf("collect() reference = %+v, want %+v", references[0], want)
+	}
+}
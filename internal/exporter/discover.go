@@ -0,0 +1,686 @@
+package exporter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"filippo.io/age"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/encryption"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/file"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+// groupResource contains the APIGroup and APIResource
+type groupResource struct {
+	APIGroup        string
+	APIVersion      string
+	APIGroupVersion string
+	APIResource     metav1.APIResource
+	objects         *unstructured.UnstructuredList
+	// nonPreferredVersion is set for the resources listed by --all-versions in another version than
+	// the preferred one, their file names end with the version
+	nonPreferredVersion bool
+	// pages is the number of pages fetched listing the objects
+	pages int
+}
+
+type groupResourceError struct {
+	APIResource metav1.APIResource `json:",inline"`
+	Error       error              `json:"error"`
+}
+
+const (
+	OutputYAML = "yaml"
+	OutputJSON = "json"
+)
+
+const (
+	// LayoutFlat writes every object of a namespace in resources/<namespace>
+	LayoutFlat = "flat"
+	// LayoutKind writes the objects in resources/<namespace>/<group>_<resource>
+	LayoutKind = "kind"
+	// LayoutSingle writes the objects of a namespace in a multi-document YAML stream
+	LayoutSingle = "single"
+)
+
+// maxFileNameLength keeps the file names well under the usual 255 bytes filesystem limit,
+// leaving room for the extension of encrypted files
+const maxFileNameLength = 200
+
+// invalidFileNameChars are the path separators and the characters not allowed in Windows file names
+var invalidFileNameChars = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f]`)
+
+// reservedFileNames are the device names Windows does not allow as file names, in any case and
+// with any extension, e.g. con or nul.tar.gz
+var reservedFileNames = regexp.MustCompile(`(?i)^(con|prn|aux|nul|com[0-9]|lpt[0-9])(\.|$)`)
+
+// upperCaseChars tell apart names that case-insensitive filesystems, the default on Windows and
+// macOS, would write to the same file, like the Roles admin and Admin
+var upperCaseChars = regexp.MustCompile(`[A-Z]`)
+
+// resourceWriter writes the exported objects of a namespace to files
+type resourceWriter struct {
+	resourceDir        string
+	clusterResourceDir string
+	output             string
+	layout             string
+	// singleFile and clusterSingleFile are the files written with the single layout
+	singleFile        string
+	clusterSingleFile string
+	workers           int
+	// Secrets are encrypted for these recipients when set
+	recipients []age.Recipient
+	// namespace holds the Namespace object, written to namespace.<output> in resourceDir or first in
+	// singleFile, when set
+	namespace *groupResource
+	// index records the written files when set
+	index *exportIndex
+	log   logrus.FieldLogger
+	// metrics records the bytes written per resource when set
+	metrics *exportMetrics
+	// budget refuses the writes once --max-resources or --max-bytes is reached, overBudget counts
+	// the objects not written
+	budget     *exportBudget
+	overBudget int
+
+	// compress gzips the files, which are written with a .gz extension
+	compress bool
+	// verifyRoundTrip reads each written file back and fails the objects it does not read back as
+	verifyRoundTrip bool
+	// fs is where the files are written atomically, the filesystem of the OS when nil. With durable
+	// each file is flushed to disk before being recorded in the index.
+	fs        file.FS
+	durable   bool
+	mu        sync.Mutex
+	encrypted []string
+}
+
+func (w *resourceWriter) writeResources(resources []*groupResource) []error {
+	if w.layout == LayoutSingle {
+		return w.writeSingle(resources)
+	}
+
+	errs := []error{}
+	if w.namespace != nil {
+		errs = append(errs, w.writeNamespace()...)
+	}
+
+	// each resource writes its own files, so the writes of different resources never collide.
+	// Writes are not cancelled on interruption so that everything listed so far lands on disk.
+	resourceErrs := make([][]error, len(resources))
+	RunWorkers(context.Background(), w.workers, len(resources), func(i int) {
+		resourceErrs[i] = w.writeResource(resources[i])
+	})
+
+	for _, e := range resourceErrs {
+		errs = append(errs, e...)
+	}
+	return errs
+}
+
+func (w *resourceWriter) writeNamespace() []error {
+	obj := w.namespace.objects.Items[0]
+	objBytes, ext, err := encodeObject(obj, w.output)
+	path := filepath.Join(w.resourceDir, namespaceFile+"."+ext)
+	if err == nil && w.compress {
+		objBytes, err = gzipBytes(objBytes)
+		path += gzipExtension
+	}
+	if err != nil {
+		return []error{&objectWriteError{resource: w.namespace, name: obj.GetName(), category: failureSerialization, err: err}}
+	}
+	if !w.budget.allow(1, len(objBytes)) {
+		w.refuse(1)
+		return nil
+	}
+	if w.index.unchanged(path, objBytes) {
+		w.index.add(path, objBytes, &obj)
+		return nil
+	}
+	if err := w.writeFile(path, objBytes); err != nil {
+		return []error{&objectWriteError{resource: w.namespace, name: obj.GetName(), category: failureIO, err: err}}
+	}
+	w.index.add(path, objBytes, &obj)
+	w.metrics.addBytes(w.namespace, len(objBytes))
+	return nil
+}
+
+func (w *resourceWriter) writeResource(r *groupResource) []error {
+	errs := []error{}
+	log := w.log.WithFields(resourceFields(r))
+	log.WithField(logFieldAction, actionWriting).Debugf("Writing objects of resource: %s to the output directory\n", r.APIResource.Name)
+	defer func() {
+		log.WithField(logFieldAction, actionWritten).Debugf("Wrote %d objects of resource %s, %d errors", len(r.objects.Items), r.APIResource.Name, len(errs))
+	}()
+
+	kind := r.APIResource.Kind
+
+	if kind == "" {
+		return errs
+	}
+
+	for i, obj := range r.objects.Items {
+		fail := func(category string, err error) {
+			errs = append(errs, &objectWriteError{resource: r, name: obj.GetName(), category: category, err: err})
+		}
+
+		objBytes, ext, err := encodeObject(obj, w.output)
+		if err != nil {
+			fail(failureSerialization, err)
+			continue
+		}
+		path := w.objectPath(r, obj, ext)
+
+		// compressed before being encrypted, encrypted data does not compress
+		if w.compress {
+			objBytes, err = gzipBytes(objBytes)
+			if err != nil {
+				fail(failureSerialization, err)
+				continue
+			}
+			path += gzipExtension
+		}
+
+		serialized := objBytes
+		encrypted := len(w.recipients) > 0 && isSecret(obj)
+		if encrypted {
+			objBytes, err = encryption.Encrypt(objBytes, w.recipients)
+			if err != nil {
+				fail(failureSerialization, err)
+				continue
+			}
+			path += encryption.Extension
+			w.mu.Lock()
+			w.encrypted = append(w.encrypted, path)
+			w.mu.Unlock()
+		}
+
+		if !w.budget.allow(1, len(objBytes)) {
+			w.refuse(len(r.objects.Items) - i)
+			break
+		}
+		// the file left unchanged by --incremental is read back too, it is the one of the export
+		unchanged := w.index.unchanged(path, objBytes)
+		if !unchanged {
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				fail(failureIO, err)
+				continue
+			}
+			if err := w.writeFile(path, objBytes); err != nil {
+				fail(failureIO, err)
+				continue
+			}
+		}
+		w.index.add(path, objBytes, &obj)
+		if w.verifyRoundTrip {
+			if err := w.checkRoundTrip(path, obj, serialized, encrypted); err != nil {
+				fail(failureRoundTrip, err)
+			}
+		}
+		if unchanged {
+			continue
+		}
+		w.metrics.addBytes(r, len(objBytes))
+		tracef(log.WithFields(logrus.Fields{logFieldObject: obj.GetName(), logFieldAction: actionWritten}), "Wrote %s", path)
+	}
+
+	return errs
+}
+
+// writeFile writes a file atomically, a file interrupted while being written is never left
+// truncated
+func (w *resourceWriter) writeFile(path string, data []byte) error {
+	fsys := w.fs
+	if fsys == nil {
+		fsys = file.OS
+	}
+	return file.WriteAtomic(fsys, path, data, w.durable)
+}
+
+// objectPath returns the file of an object with the extension of its encoder, before the
+// extensions of compression and encryption
+func (w *resourceWriter) objectPath(r *groupResource, obj unstructured.Unstructured, ext string) string {
+	targetDir := w.resourceDir
+	if obj.GetNamespace() == "" {
+		targetDir = w.clusterResourceDir
+	}
+	if w.layout == LayoutKind {
+		return filepath.Join(targetDir, kindDirName(r), safeFileName(objectFileName(r, obj), "."+ext))
+	}
+	return filepath.Join(targetDir, getFilePath(r, obj, ext))
+}
+
+// removeObject removes the file of an object no longer exported and its entry of the index, it
+// reports whether there was one
+func (w *resourceWriter) removeObject(r *groupResource, obj unstructured.Unstructured) (bool, error) {
+	// the extension is the one the encoder writes the object with
+	_, ext, err := encodeObject(obj, w.output)
+	if err != nil {
+		return false, err
+	}
+	base := w.objectPath(r, obj, ext)
+	removed := false
+	for _, path := range []string{base, base + gzipExtension, base + encryption.Extension, base + gzipExtension + encryption.Extension} {
+		err := os.Remove(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		w.index.remove(path)
+		removed = true
+	}
+	return removed, nil
+}
+
+// refuse counts the objects not written once the budget is exceeded
+func (w *resourceWriter) refuse(objects int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.overBudget += objects
+}
+
+func writeErrors(errors []*groupResourceError, failuresDir string, log logrus.FieldLogger) []error {
+	errs := []error{}
+	for _, r := range errors {
+		log.Debugf("Writing error for resource %s, error: %#v\n", r.APIResource.Name, r.Error)
+
+		kind := r.APIResource.Kind
+
+		if kind == "" {
+			continue
+		}
+
+		path := filepath.Join(failuresDir, r.APIResource.Name+".yaml")
+		f, err := os.Create(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		errBytes, err := yaml.Marshal(&r)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		_, err = f.Write(errBytes)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		err = f.Close()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+	}
+
+	return errs
+}
+
+// getFilePath returns the file name of an object as <resource>.<group>_<name>, the resource
+// keeping apart the objects of different kinds with the same name
+func getFilePath(r *groupResource, obj unstructured.Unstructured, ext string) string {
+	return safeFileName(GroupResourceName(r.APIGroup, r.APIResource.Name)+"_"+objectFileName(r, obj), "."+ext)
+}
+
+// objectFileName returns the object name, suffixed with _<version> for the versions other than the
+// preferred one. Object names cannot contain underscores so the suffix is unambiguous.
+func objectFileName(r *groupResource, obj unstructured.Unstructured) string {
+	if r.nonPreferredVersion {
+		return obj.GetName() + "_" + r.APIVersion
+	}
+	return obj.GetName()
+}
+
+// kindDirName returns the directory of the resource objects with the kind layout, e.g.
+// apps_deployments, or core_configmaps for the core group
+func kindDirName(r *groupResource) string {
+	group := r.APIGroup
+	if group == "" {
+		group = "core"
+	}
+	return group + "_" + r.APIResource.Name
+}
+
+// safeFileName returns name with the extension as a file name valid on every OS, whatever the OS
+// of the export, so that an export can be read anywhere. The invalid characters are replaced, the
+// dot of a device name is replaced and the name is truncated to maxFileNameLength; when the name is
+// changed or has upper case letters, a hash of the original name is appended so that file names
+// stay unique, on case-insensitive filesystems too, and stable across exports.
+func safeFileName(name string, ext string) string {
+	sanitized := invalidFileNameChars.ReplaceAllString(name, "_")
+	reserved := reservedFileNames.MatchString(name)
+	if sanitized == name && !reserved && !upperCaseChars.MatchString(name) && len(name)+len(ext) <= maxFileNameLength {
+		return name + ext
+	}
+	if reserved {
+		sanitized = strings.Replace(sanitized, ".", "_", 1)
+	}
+	sum := sha256.Sum256([]byte(name))
+	suffix := "-" + hex.EncodeToString(sum[:])[:10]
+	if limit := maxFileNameLength - len(suffix) - len(ext); len(sanitized) > limit {
+		sanitized = sanitized[:limit]
+	}
+	return sanitized + suffix + ext
+}
+
+// servesVersion reports whether the group is served in the version
+func servesVersion(group metav1.APIGroup, version string) bool {
+	for _, v := range group.Versions {
+		if v.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// ResourcesToList returns the admitted resources export lists in a namespace, in discovery order,
+// without their objects
+func ResourcesToList(clusterScopedRbac bool, allVersions bool, filter *ResourceFilter, lists []*metav1.APIResourceList, apiGroups []metav1.APIGroup, log logrus.FieldLogger) []*groupResource {
+	candidates := []*groupResource{}
+
+	preferredVersions := map[string]string{}
+	for _, a := range apiGroups {
+		preferredVersions[a.Name] = a.PreferredVersion.Version
+		for _, version := range exportedVersions[a.Name] {
+			if servesVersion(a, version) {
+				preferredVersions[a.Name] = version
+				break
+			}
+		}
+	}
+	// the groups without a known preferred version are listed in the first version discovered
+	listed := map[string]bool{}
+
+	for _, list := range lists {
+		if len(list.APIResources) == 0 {
+			continue
+		}
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			if len(resource.Verbs) == 0 {
+				continue
+			}
+
+			// the Events are exported apart with --include-events
+			if resource.Kind == "Event" {
+				log.Debugf("skipping extracting events\n")
+				continue
+			}
+
+			if !filter.admits(gv.Group, resource) {
+				log.Debugf("resource: %s.%s is not included or is excluded, skipping\n", gv.String(), resource.Kind)
+				continue
+			}
+
+			if !isAdmittedResource(clusterScopedRbac, gv, resource) {
+				log.Debugf("resource: %s.%s is clusterscoped or not admitted kind, skipping\n", gv.String(), resource.Kind)
+				continue
+			}
+
+			key := GroupResourceName(gv.Group, resource.Name)
+			preferredVersion, known := preferredVersions[gv.Group]
+			preferred := gv.Version == preferredVersion || (!known && !listed[key])
+			if !preferred && !allVersions {
+				log.Debugf("resource: %s.%s is not in the preferred version, skipping\n", gv.String(), resource.Kind)
+				continue
+			}
+			if preferred {
+				listed[key] = true
+			}
+
+			candidates = append(candidates, &groupResource{
+				APIGroup:            gv.Group,
+				APIVersion:          gv.Version,
+				APIGroupVersion:     gv.String(),
+				APIResource:         resource,
+				nonPreferredVersion: !preferred,
+			})
+		}
+	}
+	return candidates
+}
+
+// resourceToExtract lists the admitted resources of the namespace. Each resource is listed in the
+// preferred version of its group only, the objects served in several versions being the same, unless
+// allVersions is set. The objects of the resources streamed by stream are written as they are listed
+// and only their names and versions are returned.
+func resourceToExtract(ctx context.Context, namespace string, listOptions metav1.ListOptions, clusterScopedRbac bool, allVersions bool, filter *ResourceFilter, workers int, listTimeout time.Duration, dynamicClient dynamic.Interface, lists []*metav1.APIResourceList, apiGroups []metav1.APIGroup, metrics *exportMetrics, snapshot *listSnapshot, progress *exportProgress, stream *pageStream, log logrus.FieldLogger) ([]*groupResource, []*groupResourceError) {
+	candidates := ResourcesToList(clusterScopedRbac, allVersions, filter, lists, apiGroups, log)
+
+	// Each resource is listed by one of the workers, the results are kept in discovery order
+	listErrs := make([]error, len(candidates))
+	skipped := RunWorkers(ctx, workers, len(candidates), func(i int) {
+		g := candidates[i]
+		log.Debugf("processing resource: %s.%s\n", g.APIGroupVersion, g.APIResource.Kind)
+		// a single misbehaving API service must not use up the time of the whole export
+		listCtx, cancel := ctx, context.CancelFunc(func() {})
+		if listTimeout > 0 {
+			listCtx, cancel = context.WithTimeout(ctx, listTimeout)
+		}
+		defer cancel()
+		start := time.Now()
+		checkpoint := progress.resource(namespace, g)
+		onPage := stream.onPage(ctx, g)
+		if objects, pages, ok := checkpoint.completed(); ok {
+			g.objects, g.pages = objects, pages
+			if onPage != nil {
+				g.objects.Items = streamObjects(objects.Items, onPage)
+			}
+			log.WithFields(resourceFields(g)).WithField(logFieldAction, actionListed).Debugf("read %d objects of resource %s.%s listed before the export was resumed", len(g.objects.Items), g.APIGroupVersion, g.APIResource.Kind)
+			snapshot.record(g, start)
+			return
+		}
+		g.objects, listErrs[i] = getObjects(listCtx, g, namespace, listOptions, dynamicClient, checkpoint, stream, log)
+		metrics.recordList(g, time.Since(start), listErrs[i])
+		if listErrs[i] == nil {
+			log.WithFields(resourceFields(g)).WithField(logFieldAction, actionListed).Debugf("listed %d objects of resource %s.%s in %s", len(g.objects.Items), g.APIGroupVersion, g.APIResource.Kind, time.Since(start).Round(time.Millisecond))
+		}
+		if listErrs[i] == nil {
+			snapshot.record(g, start)
+		}
+	})
+	for _, i := range skipped {
+		listErrs[i] = ctx.Err()
+	}
+
+	resources := []*groupResource{}
+	resourceErrs := []*groupResourceError{}
+	for i, g := range candidates {
+		if err := listErrs[i]; err != nil {
+			log := log.WithFields(resourceFields(g)).WithError(err)
+			switch {
+			case apierrors.IsForbidden(err):
+				log.Errorf("cannot list obj in namespace for groupVersion %s, kind: %s\n", g.APIGroupVersion, g.APIResource.Kind)
+			case apierrors.IsMethodNotSupported(err):
+				log.Errorf("list method not supported on the groupVersion %s, kind: %s\n", g.APIGroupVersion, g.APIResource.Kind)
+			case apierrors.IsBadRequest(err) && listOptions.FieldSelector != "":
+				log.Errorf("field selector %q not supported on the groupVersion %s, kind: %s\n", listOptions.FieldSelector, g.APIGroupVersion, g.APIResource.Kind)
+			case apierrors.IsNotFound(err):
+				log.Errorf("could not find the resource, most likely this is a virtual resource, groupVersion %s, kind: %s\n", g.APIGroupVersion, g.APIResource.Kind)
+			case errors.Is(err, context.DeadlineExceeded):
+				log.Errorf("timed out listing groupVersion %s, kind: %s\n", g.APIGroupVersion, g.APIResource.Kind)
+			case ctx.Err() != nil:
+				log.Errorf("export interrupted before listing groupVersion %s, kind: %s\n", g.APIGroupVersion, g.APIResource.Kind)
+			default:
+				log.Errorf("error listing objects: %#v, groupVersion %s, kind: %s\n", err, g.APIGroupVersion, g.APIResource.Kind)
+			}
+			resource := g.APIResource
+			resource.Group = g.APIGroup
+			resource.Version = g.APIVersion
+			resourceErrs = append(resourceErrs, &groupResourceError{resource, err})
+			continue
+		}
+
+		if len(g.objects.Items) > 0 {
+			log.Debugf("adding resource: %s to the list of GVRs to be extracted", g.APIResource.Name)
+			resources = append(resources, g)
+			continue
+		}
+
+		log.Debugf("0 objects found, for resource %s, skipping\n", g.APIResource.Name)
+	}
+
+	return resources, resourceErrs
+}
+
+func isAdmittedResource(clusterScopedRbac bool, gv schema.GroupVersion, resource metav1.APIResource) bool {
+	if !resource.Namespaced {
+		return clusterScopedRbac && IsClusterScopedResource(gv.Group, resource.Kind)
+	}
+	return true
+}
+
+func getObjects(ctx context.Context, g *groupResource, namespace string, listOptions metav1.ListOptions, d dynamic.Interface, checkpoint *resourceCheckpoint, stream *pageStream, logger logrus.FieldLogger) (*unstructured.UnstructuredList, error) {
+	c := d.Resource(schema.GroupVersionResource{
+		Group:    g.APIGroup,
+		Version:  g.APIVersion,
+		Resource: g.APIResource.Name,
+	})
+	var client dynamic.ResourceInterface = c
+	if g.APIResource.Namespaced {
+		client = c.Namespace(namespace)
+	}
+	counter := &pageCounter{ResourceInterface: stream.lister(g, namespace, client)}
+	list, err := resumePages(ctx, counter, listOptions, checkpoint, stream.onPage(ctx, g), logger.WithFields(resourceFields(g)))
+	g.pages = counter.pages
+	if err != nil {
+		return nil, err
+	}
+	if g.APIResource.Name == "imagestreamtags" || g.APIResource.Name == "imagetags" {
+		unstructuredList, err := iterateItemsByGet(ctx, c, g, list, namespace, logger)
+		if err != nil {
+			return nil, err
+		}
+		unstructuredList.SetResourceVersion(list.GetResourceVersion())
+		checkpoint.replace(unstructuredList)
+		return unstructuredList, nil
+	}
+	return list, nil
+}
+
+// maxListRestarts bounds the restarts of a listing whose continue token expired
+const maxListRestarts = 3
+
+// listPages lists the objects listOptions.Limit at a time, all at once when it is 0. When the
+// continue token expires before the last page, the listing starts over and the objects already
+// received are not added twice.
+func listPages(ctx context.Context, client dynamic.ResourceInterface, listOptions metav1.ListOptions, logger logrus.FieldLogger) (*unstructured.UnstructuredList, error) {
+	return resumePages(ctx, client, listOptions, nil, nil, logger)
+}
+
+// resumePages is listPages recording each page in the checkpoint. When the checkpoint has the
+// pages listed by an interrupted export, the listing continues after the last one, or starts over
+// when its continue token expired. With onPage each page is handed to it as it is received, and
+// only the names and versions of the objects are kept in the list.
+func resumePages(ctx context.Context, client dynamic.ResourceInterface, listOptions metav1.ListOptions, checkpoint *resourceCheckpoint, onPage func([]unstructured.Unstructured), logger logrus.FieldLogger) (*unstructured.UnstructuredList, error) {
+	list := &unstructured.UnstructuredList{Items: []unstructured.Unstructured{}}
+	keep := func(objects []unstructured.Unstructured) {
+		if onPage != nil {
+			objects = streamObjects(objects, onPage)
+		}
+		list.Items = append(list.Items, objects...)
+	}
+	received := map[string]bool{}
+	restarts := 0
+	listOptions.Continue = ""
+	resumed, resuming := checkpoint.partial()
+	if resuming {
+		logger.Infof("resuming the listing after the %d objects listed before the export was resumed", len(resumed.Items))
+		list.SetResourceVersion(resumed.GetResourceVersion())
+		for _, obj := range resumed.Items {
+			received[obj.GetNamespace()+"/"+obj.GetName()] = true
+		}
+		keep(resumed.Items)
+		listOptions.Continue = resumed.GetContinue()
+	}
+	for {
+		page, err := client.List(ctx, listOptions)
+		if (apierrors.IsResourceExpired(err) || apierrors.IsGone(err)) && resuming {
+			// the pages listed before are too old to be continued, the resource is listed again
+			resuming = false
+			logger.Warnf("the continue token of the interrupted export expired, listing again from the start: %v", err)
+			list = &unstructured.UnstructuredList{Items: []unstructured.Unstructured{}}
+			received = map[string]bool{}
+			checkpoint.reset()
+			listOptions.Continue = ""
+			continue
+		}
+		if (apierrors.IsResourceExpired(err) || apierrors.IsGone(err)) && listOptions.Continue != "" && restarts < maxListRestarts {
+			restarts++
+			logger.Warnf("the continue token expired after %d objects, listing again from the start: %v", len(list.Items), err)
+			listOptions.Continue = ""
+			continue
+		}
+		resuming = false
+		if err != nil {
+			return nil, err
+		}
+		// the list is as of the resourceVersion of its first page
+		if listOptions.Continue == "" {
+			list.SetResourceVersion(page.GetResourceVersion())
+		}
+		added := []unstructured.Unstructured{}
+		for _, obj := range page.Items {
+			key := obj.GetNamespace() + "/" + obj.GetName()
+			if received[key] {
+				continue
+			}
+			received[key] = true
+			added = append(added, obj)
+		}
+		listOptions.Continue = page.GetContinue()
+		// recorded before onPage prepares the objects in place
+		checkpoint.page(added, list.GetResourceVersion(), listOptions.Continue)
+		keep(added)
+		if listOptions.Continue == "" {
+			return list, nil
+		}
+	}
+}
+
+func iterateItemsByGet(ctx context.Context, c dynamic.NamespaceableResourceInterface, g *groupResource, list runtime.Object, namespace string, logger logrus.FieldLogger) (*unstructured.UnstructuredList, error) {
+	unstructuredList := &unstructured.UnstructuredList{Items: []unstructured.Unstructured{}}
+	err := meta.EachListItem(list, func(object runtime.Object) error {
+		u, ok := object.(*unstructured.Unstructured)
+		if !ok {
+			// TODO: explore aggregating all the errors here instead of terminating the loop
+			logger.Errorf("expected unstructured.Unstructured but got %T for groupResource %s and object: %#v\n", g, object)
+			return fmt.Errorf("expected *unstructured.Unstructured but got %T", u)
+		}
+		obj, err := c.Namespace(namespace).Get(ctx, u.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		unstructuredList.Items = append(unstructuredList.Items, *obj)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to process the list for group: %s, kind: %s", g.APIGroup, g.APIResource.Kind)
+	}
+	return unstructuredList, nil
+}
@@ -0,0 +1,585 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/file"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"sigs.k8s.io/yaml"
+)
+
+func testLogger() logrus.FieldLogger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func testObject() unstructured.Unstructured {
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":      "hello-world",
+			"namespace": "foo",
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(1),
+		},
+	}}
+}
+
+func Test_getFilePath(t *testing.T) {
+	deployments := &groupResource{APIGroup: "apps", APIResource: metav1.APIResource{Name: "deployments"}}
+	services := &groupResource{APIResource: metav1.APIResource{Name: "services"}}
+	tests := []struct {
+		name     string
+		resource *groupResource
+		output   string
+		want     string
+	}{
+		{
+			name:     "yaml output uses the yaml extension",
+			resource: deployments,
+			output:   OutputYAML,
+			want:     "deployments.apps_hello-world.yaml",
+		},
+		{
+			name:     "json output uses the json extension",
+			resource: deployments,
+			output:   OutputJSON,
+			want:     "deployments.apps_hello-world.json",
+		},
+		{
+			name:     "core resources have no group",
+			resource: services,
+			output:   OutputYAML,
+			want:     "services_hello-world.yaml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getFilePath(tt.resource, testObject(), tt.output); got != tt.want {
+				t.Errorf("getFilePath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_safeFileName(t *testing.T) {
+	long := strings.Repeat("a", 253)
+	tests := []struct {
+		name      string
+		in        string
+		wantExact string
+		// wantPrefix is checked when the name has to be changed
+		wantPrefix string
+	}{
+		{name: "valid names are kept", in: "hello-world", wantExact: "hello-world.yaml"},
+		{name: "path separators are replaced", in: "team/app", wantPrefix: "team_app-"},
+		{name: "backslashes are replaced", in: `..\..\etc`, wantPrefix: ".._.._etc-"},
+		{name: "windows invalid characters are replaced", in: `v1:"a"<b>|c?*`, wantPrefix: "v1__a__b__c__-"},
+		{name: "control characters are replaced", in: "a\tb\x00", wantPrefix: "a_b_-"},
+		{name: "windows device names are suffixed", in: "con", wantPrefix: "con-"},
+		{name: "windows device names with an extension are changed", in: "nul.example.com", wantPrefix: "nul_example.com-"},
+		{name: "names starting like a device name are kept", in: "console", wantExact: "console.yaml"},
+		{name: "upper case names are suffixed", in: "Admin", wantPrefix: "Admin-"},
+		{name: "group-qualified names are sanitized", in: "system:controller:Admin", wantPrefix: "system_controller_Admin-"},
+		{name: "long names are truncated", in: long, wantPrefix: "aaaa"},
+	}
+	seen := map[string]string{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := safeFileName(tt.in, ".yaml")
+			if tt.wantExact != "" && got != tt.wantExact {
+				t.Errorf("safeFileName() = %q, want %q", got, tt.wantExact)
+			}
+			if tt.wantPrefix != "" && (!strings.HasPrefix(got, tt.wantPrefix) || !strings.HasSuffix(got, ".yaml")) {
+				t.Errorf("safeFileName() = %q, want %q followed by a hash and the extension", got, tt.wantPrefix)
+			}
+			if len(got) > maxFileNameLength || strings.ContainsAny(got, `/\:`) {
+				t.Errorf("safeFileName() = %q is not a valid file name", got)
+			}
+			if got != safeFileName(tt.in, ".yaml") {
+				t.Errorf("safeFileName() is not stable for %q", tt.in)
+			}
+			if other, ok := seen[got]; ok {
+				t.Errorf("safeFileName() returned %q for both %q and %q", got, other, tt.in)
+			}
+			seen[got] = tt.in
+		})
+	}
+
+	if strings.EqualFold(safeFileName("Admin", ".yaml"), safeFileName("admin", ".yaml")) {
+		t.Errorf("safeFileName() should tell apart names differing only by case")
+	}
+	if safeFileName("team/app", ".yaml") == safeFileName("team_app", ".yaml") {
+		t.Errorf("safeFileName() should tell apart a sanitized name from the same valid name")
+	}
+	if safeFileName(long, ".yaml") == safeFileName(long[1:]+"b", ".yaml") {
+		t.Errorf("safeFileName() should tell apart long names sharing a prefix")
+	}
+}
+
+func Test_resourceWriter_layout(t *testing.T) {
+	configMap := testConfigMaps(1)[0].(*unstructured.Unstructured)
+	resources := []*groupResource{
+		{APIGroup: "apps", APIVersion: "v1", APIResource: metav1.APIResource{Name: "deployments", Kind: "Deployment"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{testObject()}}},
+		{APIVersion: "v1", APIResource: metav1.APIResource{Name: "configmaps", Kind: "ConfigMap"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{*configMap}}},
+	}
+	tests := []struct {
+		layout string
+		want   []string
+	}{
+		{
+			layout: LayoutFlat,
+			want:   []string{"configmaps_" + configMap.GetName() + ".yaml", "deployments.apps_hello-world.yaml"},
+		},
+		{
+			layout: LayoutKind,
+			want:   []string{"apps_deployments/hello-world.yaml", "core_configmaps/" + configMap.GetName() + ".yaml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.layout, func(t *testing.T) {
+			dir := t.TempDir()
+			w := &resourceWriter{resourceDir: dir, output: OutputYAML, layout: tt.layout, workers: 2, log: testLogger()}
+			if errs := w.writeResources(resources); len(errs) > 0 {
+				t.Fatalf("writeResources() errors = %v", errs)
+			}
+			got := []string{}
+			err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				rel, err := filepath.Rel(dir, path)
+				got = append(got, filepath.ToSlash(rel))
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("written files = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_resourceWriter_sameName(t *testing.T) {
+	service := testOwnedObject("Service", "hello-world")
+	resources := []*groupResource{
+		{APIGroup: "apps", APIVersion: "v1", APIResource: metav1.APIResource{Name: "deployments", Kind: "Deployment"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{testObject()}}},
+		{APIVersion: "v1", APIResource: metav1.APIResource{Name: "services", Kind: "Service"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{service}}},
+	}
+	dir := t.TempDir()
+	w := &resourceWriter{resourceDir: dir, output: OutputYAML, layout: LayoutFlat, workers: 2, log: testLogger()}
+	if errs := w.writeResources(resources); len(errs) > 0 {
+		t.Fatalf("writeResources() errors = %v", errs)
+	}
+	for _, name := range []string{"deployments.apps_hello-world.yaml", "services_hello-world.yaml"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s should be written: %v", name, err)
+		}
+	}
+}
+
+// killedFS fails the rename of the temporary files, like an export killed while writing them
+type killedFS struct {
+	file.FS
+}
+
+func (killedFS) Rename(oldPath string, newPath string) error {
+	return errors.New("killed")
+}
+
+func Test_resourceWriter_atomic(t *testing.T) {
+	resources := []*groupResource{
+		{APIGroup: "apps", APIVersion: "v1", APIResource: metav1.APIResource{Name: "deployments", Kind: "Deployment"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{testObject()}}},
+	}
+	dir := t.TempDir()
+	manifests := newExportIndex(dir)
+	w := &resourceWriter{resourceDir: dir, output: OutputYAML, layout: LayoutFlat, workers: 1, index: manifests, fs: killedFS{FS: file.OS}, log: testLogger()}
+	errs := w.writeResources(resources)
+	if len(errs) != 1 {
+		t.Fatalf("writeResources() errors = %v, want the failed write", errs)
+	}
+	if e, ok := errs[0].(*objectWriteError); !ok || e.category != failureIO {
+		t.Errorf("writeResources() error = %v, want an I/O failure", errs[0])
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("writeResources() left %d files, want none", len(entries))
+	}
+	if len(manifests.entries) != 0 {
+		t.Errorf("the index records %v, want no file", manifests.entries)
+	}
+}
+
+func Test_marshalObject_deterministic(t *testing.T) {
+	obj := testObject()
+	labels := map[string]interface{}{}
+	for i := 0; i < 50; i++ {
+		labels[fmt.Sprintf("label-%02d", i)] = strconv.Itoa(i)
+	}
+	obj.Object["metadata"].(map[string]interface{})["labels"] = labels
+	obj.Object["data"] = map[string]interface{}{
+		"long": strings.Repeat("word ", 30),
+		"list": []interface{}{map[string]interface{}{"b": int64(1), "a": 2.5, "c": true}},
+	}
+	for _, output := range []string{OutputYAML, OutputJSON} {
+		want, err := marshalObject(obj, output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 100; i++ {
+			// a deep copy holds the same maps, iterated in another order
+			got, err := marshalObject(*obj.DeepCopy(), output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Fatalf("marshalObject() %s run %d differs:\n%s\nwant:\n%s", output, i, got, want)
+			}
+		}
+	}
+
+	// the indentation and the line width are part of the checksums of previous exports
+	obj = unstructured.Unstructured{Object: map[string]interface{}{
+		"kind": "ConfigMap",
+		"data": map[string]interface{}{"long": strings.TrimSpace(strings.Repeat("word ", 20))},
+		"list": []interface{}{map[string]interface{}{"b": int64(1), "a": int64(2)}},
+	}}
+	got, err := marshalObject(obj, OutputYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `data:
+  long: word word word word word word word word word word word word word word word
+    word word word word word
+kind: ConfigMap
+list:
+- a: 2
+  b: 1
+`
+	if string(got) != want {
+		t.Errorf("marshalObject() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func Test_marshalObject(t *testing.T) {
+	obj := testObject()
+
+	jsonBytes, err := marshalObject(obj, OutputJSON)
+	if err != nil {
+		t.Fatalf("marshalObject() error = %v", err)
+	}
+	if !json.Valid(jsonBytes) {
+		t.Errorf("marshalObject() produced invalid json: %s", jsonBytes)
+	}
+
+	yamlBytes, err := marshalObject(obj, OutputYAML)
+	if err != nil {
+		t.Fatalf("marshalObject() error = %v", err)
+	}
+	fromYAML, err := yaml.YAMLToJSON(yamlBytes)
+	if err != nil {
+		t.Fatalf("marshalObject() produced invalid yaml: %v", err)
+	}
+	if !json.Valid(fromYAML) {
+		t.Errorf("marshalObject() produced yaml that does not convert to json: %s", yamlBytes)
+	}
+}
+
+var testConfigMapsGVR = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+func testConfigMaps(n int) []runtime.Object {
+	objects := []runtime.Object{}
+	for i := 0; i < n; i++ {
+		objects = append(objects, &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name":      fmt.Sprintf("cm-%d", i),
+				"namespace": "foo",
+			},
+		}})
+	}
+	return objects
+}
+
+// testDiscovery serves configmaps along with resources that cannot be listed
+func testDiscovery(n int) ([]*metav1.APIResourceList, []metav1.APIGroup) {
+	resources := []metav1.APIResource{{Name: "configmaps", Namespaced: true, Kind: "ConfigMap", Verbs: metav1.Verbs{"list"}}}
+	for i := 0; i < n; i++ {
+		resources = append(resources, metav1.APIResource{Name: fmt.Sprintf("widgets%d", i), Namespaced: true, Kind: fmt.Sprintf("Widget%d", i), Verbs: metav1.Verbs{"list"}})
+	}
+	lists := []*metav1.APIResourceList{{GroupVersion: "v1", APIResources: resources}}
+	groups := []metav1.APIGroup{{Name: "", PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "v1", Version: "v1"}}}
+	return lists, groups
+}
+
+func newTestDynamicClient(n int, objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	listKinds := map[schema.GroupVersionResource]string{testConfigMapsGVR: "ConfigMapList"}
+	for i := 0; i < n; i++ {
+		listKinds[schema.GroupVersionResource{Version: "v1", Resource: fmt.Sprintf("widgets%d", i)}] = fmt.Sprintf("Widget%dList", i)
+	}
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, objects...)
+}
+
+// slowDynamicClient delays list calls to simulate the API server round trip. The fake client
+// serializes its reactors, so the delay has to happen outside of it.
+type slowDynamicClient struct {
+	dynamic.Interface
+	latency time.Duration
+}
+
+func (c slowDynamicClient) Resource(resource schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return slowResourceClient{NamespaceableResourceInterface: c.Interface.Resource(resource), latency: c.latency}
+}
+
+type slowResourceClient struct {
+	dynamic.NamespaceableResourceInterface
+	latency time.Duration
+}
+
+func (c slowResourceClient) Namespace(namespace string) dynamic.ResourceInterface {
+	return slowNamespacedClient{ResourceInterface: c.NamespaceableResourceInterface.Namespace(namespace), latency: c.latency}
+}
+
+type slowNamespacedClient struct {
+	dynamic.ResourceInterface
+	latency time.Duration
+}
+
+func (c slowNamespacedClient) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(c.latency):
+	}
+	return c.ResourceInterface.List(ctx, opts)
+}
+
+func Test_resourceToExtract_workers(t *testing.T) {
+	lists, groups := testDiscovery(5)
+	for _, workers := range []int{1, 4} {
+		client := newTestDynamicClient(5, testConfigMaps(3)...)
+		resources, errs := resourceToExtract(context.Background(), "foo", metav1.ListOptions{}, false, false, &ResourceFilter{}, workers, 0, client, lists, groups, nil, nil, nil, nil, testLogger())
+		if len(errs) != 0 {
+			t.Errorf("workers=%d: resourceToExtract() errors = %v", workers, errs)
+		}
+		if len(resources) != 1 || len(resources[0].objects.Items) != 3 {
+			t.Errorf("workers=%d: resourceToExtract() should return the 3 configmaps, got %v", workers, resources)
+		}
+	}
+}
+
+func Test_resourceToExtract_cancelled(t *testing.T) {
+	lists, groups := testDiscovery(5)
+	client := newTestDynamicClient(5, testConfigMaps(3)...)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	resources, errs := resourceToExtract(ctx, "foo", metav1.ListOptions{}, false, false, &ResourceFilter{}, 2, 0, client, lists, groups, nil, nil, nil, nil, testLogger())
+	if len(resources) != 0 {
+		t.Errorf("resourceToExtract() returned %d resources after cancellation, want 0", len(resources))
+	}
+	if len(errs) != 6 {
+		t.Fatalf("resourceToExtract() returned %d errors, want one per resource", len(errs))
+	}
+	if errs[0].APIResource.Version != "v1" || errs[0].APIResource.Name != "configmaps" {
+		t.Errorf("resourceToExtract() error should record the GVR, got %+v", errs[0].APIResource)
+	}
+}
+
+func Test_resourceToExtract_timeout(t *testing.T) {
+	lists, groups := testDiscovery(1)
+	client := slowDynamicClient{Interface: newTestDynamicClient(1, testConfigMaps(3)...), latency: time.Second}
+
+	t.Run("list timeout records each slow resource as timed out", func(t *testing.T) {
+		resources, errs := resourceToExtract(context.Background(), "foo", metav1.ListOptions{}, false, false, &ResourceFilter{}, 2, 10*time.Millisecond, client, lists, groups, nil, nil, nil, nil, testLogger())
+		if len(resources) != 0 || len(errs) != 2 {
+			t.Fatalf("resourceToExtract() = %d resources, %d errors, want 0 resources and 2 errors", len(resources), len(errs))
+		}
+		for _, e := range errs {
+			if _, category := classifyError(e.Error); category != failureTimedOut {
+				t.Errorf("resource %s failed with %v, want a timeout", e.APIResource.Name, e.Error)
+			}
+		}
+	})
+
+	t.Run("export deadline records the remaining resources as timed out", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, errs := resourceToExtract(ctx, "foo", metav1.ListOptions{}, false, false, &ResourceFilter{}, 1, 0, client, lists, groups, nil, nil, nil, nil, testLogger())
+		if len(errs) != 2 {
+			t.Fatalf("resourceToExtract() returned %d errors, want 2", len(errs))
+		}
+		for _, e := range errs {
+			if _, category := classifyError(e.Error); category != failureTimedOut {
+				t.Errorf("resource %s failed with %v, want a timeout", e.APIResource.Name, e.Error)
+			}
+		}
+	})
+}
+
+func benchmarkResourceToExtract(b *testing.B, workers int) {
+	lists, groups := testDiscovery(20)
+	client := slowDynamicClient{Interface: newTestDynamicClient(20, testConfigMaps(50)...), latency: 5 * time.Millisecond}
+	for i := 0; i < b.N; i++ {
+		resourceToExtract(context.Background(), "foo", metav1.ListOptions{}, false, false, &ResourceFilter{}, workers, 0, client, lists, groups, nil, nil, nil, nil, testLogger())
+	}
+}
+
+func BenchmarkResourceToExtract_1Worker(b *testing.B)  { benchmarkResourceToExtract(b, 1) }
+func BenchmarkResourceToExtract_4Workers(b *testing.B) { benchmarkResourceToExtract(b, 4) }
+
+func Test_resourceToExtract_versions(t *testing.T) {
+	crontab := func(version string) runtime.Object {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "stable.example.com/" + version,
+			"kind":       "CronTab",
+			"metadata":   map[string]interface{}{"name": "backup", "namespace": "foo"},
+		}}
+	}
+	crontabs := metav1.APIResource{Name: "crontabs", Namespaced: true, Kind: "CronTab", Verbs: metav1.Verbs{"list"}}
+	lists := []*metav1.APIResourceList{
+		{GroupVersion: "stable.example.com/v1beta1", APIResources: []metav1.APIResource{crontabs}},
+		{GroupVersion: "stable.example.com/v1", APIResources: []metav1.APIResource{crontabs}},
+	}
+	groups := []metav1.APIGroup{{Name: "stable.example.com", PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "stable.example.com/v1", Version: "v1"}}}
+	listKinds := map[schema.GroupVersionResource]string{
+		{Group: "stable.example.com", Version: "v1", Resource: "crontabs"}:      "CronTabList",
+		{Group: "stable.example.com", Version: "v1beta1", Resource: "crontabs"}: "CronTabList",
+	}
+
+	tests := []struct {
+		name        string
+		allVersions bool
+		apiGroups   []metav1.APIGroup
+		want        []string
+	}{
+		{
+			name:      "only the preferred version is listed",
+			apiGroups: groups,
+			want:      []string{"crontabs.stable.example.com_backup.yaml"},
+		},
+		{
+			name:      "the first version is listed when the preferred one is unknown",
+			apiGroups: nil,
+			want:      []string{"crontabs.stable.example.com_backup.yaml"},
+		},
+		{
+			name:        "every version is listed with --all-versions",
+			allVersions: true,
+			apiGroups:   groups,
+			want:        []string{"crontabs.stable.example.com_backup_v1beta1.yaml", "crontabs.stable.example.com_backup.yaml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, crontab("v1"), crontab("v1beta1"))
+			resources, errs := resourceToExtract(context.Background(), "foo", metav1.ListOptions{}, false, tt.allVersions, &ResourceFilter{}, 1, 0, client, lists, tt.apiGroups, nil, nil, nil, nil, testLogger())
+			if len(errs) != 0 {
+				t.Fatalf("resourceToExtract() errors = %v", errs)
+			}
+			got := []string{}
+			for _, r := range resources {
+				for _, obj := range r.objects.Items {
+					got = append(got, getFilePath(r, obj, OutputYAML))
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("exported files = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// pagedClient serves the configmaps in pages of limit objects, the continue token being the index
+// of the next object. expireAt makes the token of that index expire once.
+type pagedClient struct {
+	dynamic.ResourceInterface
+	objects  []unstructured.Unstructured
+	expireAt string
+	calls    []metav1.ListOptions
+}
+
+func (c *pagedClient) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	c.calls = append(c.calls, opts)
+	if opts.Continue != "" && opts.Continue == c.expireAt {
+		c.expireAt = ""
+		return nil, apierrors.NewResourceExpired("too old resource version")
+	}
+	start := 0
+	if opts.Continue != "" {
+		start, _ = strconv.Atoi(opts.Continue)
+	}
+	end := len(c.objects)
+	if opts.Limit > 0 && start+int(opts.Limit) < end {
+		end = start + int(opts.Limit)
+	}
+	list := &unstructured.UnstructuredList{Items: c.objects[start:end]}
+	if end < len(c.objects) {
+		list.SetContinue(strconv.Itoa(end))
+	}
+	return list, nil
+}
+
+func Test_listPages(t *testing.T) {
+	objects := []unstructured.Unstructured{}
+	for _, obj := range testConfigMaps(7) {
+		objects = append(objects, *obj.(*unstructured.Unstructured))
+	}
+	tests := []struct {
+		name      string
+		limit     int64
+		expireAt  string
+		wantCalls int
+	}{
+		{name: "three pages", limit: 3, wantCalls: 3},
+		{name: "a single request without limit", limit: 0, wantCalls: 1},
+		{name: "an expired continue token restarts the listing", limit: 3, expireAt: "6", wantCalls: 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &pagedClient{objects: objects, expireAt: tt.expireAt}
+			list, err := listPages(context.Background(), client, metav1.ListOptions{Limit: tt.limit}, testLogger())
+			if err != nil {
+				t.Fatalf("listPages() error = %v", err)
+			}
+			if len(client.calls) != tt.wantCalls {
+				t.Errorf("listPages() made %d requests, want %d", len(client.calls), tt.wantCalls)
+			}
+			if len(list.Items) != len(objects) {
+				t.Fatalf("listPages() returned %d objects, want %d", len(list.Items), len(objects))
+			}
+			for i, obj := range list.Items {
+				if obj.GetName() != objects[i].GetName() {
+					t.Errorf("listPages() object %d = %s, want %s", i, obj.GetName(), objects[i].GetName())
+				}
+			}
+		})
+	}
+}
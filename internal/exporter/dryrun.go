@@ -0,0 +1,81 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// dryRunEntry describes the objects of a resource that an export would write
+type dryRunEntry struct {
+	Namespace      string `json:"namespace"`
+	Resource       string `json:"resource"`
+	Kind           string `json:"kind"`
+	Count          int    `json:"count"`
+	EstimatedBytes int    `json:"estimatedBytes"`
+}
+
+func newDryRunEntries(namespace string, resources []*groupResource, output string) []dryRunEntry {
+	entries := []dryRunEntry{}
+	for _, r := range resources {
+		if r.objects == nil || len(r.objects.Items) == 0 {
+			continue
+		}
+		entry := dryRunEntry{
+			Namespace: namespace,
+			Resource:  GroupResourceName(r.APIGroup, r.APIResource.Name),
+			Kind:      r.APIResource.Kind,
+			Count:     len(r.objects.Items),
+		}
+		for _, obj := range r.objects.Items {
+			objBytes, err := marshalObject(obj, output)
+			if err != nil {
+				continue
+			}
+			entry.EstimatedBytes += len(objBytes)
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Resource < entries[j].Resource
+	})
+	return entries
+}
+
+func printDryRun(out io.Writer, entries []dryRunEntry, output string) error {
+	if output == OutputJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	}
+
+	total, totalBytes := 0, 0
+	table := tablewriter.NewWriter(out)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Namespace", "Resource", "Kind", "Count", "Estimated Size"})
+	for _, e := range entries {
+		table.Append([]string{e.Namespace, e.Resource, e.Kind, strconv.Itoa(e.Count), FormatBytes(e.EstimatedBytes)})
+		total += e.Count
+		totalBytes += e.EstimatedBytes
+	}
+	table.SetFooter([]string{"", "", "Total", strconv.Itoa(total), FormatBytes(totalBytes)})
+	table.Render()
+	return nil
+}
+
+func FormatBytes(b int) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := unit, 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,98 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_printDryRun(t *testing.T) {
+	resources := []*groupResource{
+		{
+			APIGroup:    "apps",
+			APIResource: metav1.APIResource{Name: "deployments", Kind: "Deployment"},
+			objects:     &unstructured.UnstructuredList{Items: []unstructured.Unstructured{testObject()}},
+		},
+		{
+			APIResource: metav1.APIResource{Name: "configmaps", Kind: "ConfigMap"},
+			objects:     &unstructured.UnstructuredList{Items: []unstructured.Unstructured{}},
+		},
+	}
+	entries := newDryRunEntries("foo", resources, OutputYAML)
+	if len(entries) != 1 {
+		t.Fatalf("newDryRunEntries() returned %d entries, want 1", len(entries))
+	}
+	if entries[0].Resource != "deployments.apps" || entries[0].Count != 1 || entries[0].EstimatedBytes == 0 {
+		t.Errorf("newDryRunEntries() = %+v", entries[0])
+	}
+
+	out := &bytes.Buffer{}
+	if err := printDryRun(out, entries, OutputJSON); err != nil {
+		t.Fatalf("printDryRun() error = %v", err)
+	}
+	decoded := []dryRunEntry{}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("printDryRun() produced invalid json: %v", err)
+	}
+	if len(decoded) != 1 || decoded[0] != entries[0] {
+		t.Errorf("printDryRun() json = %+v, want %+v", decoded, entries)
+	}
+
+	out.Reset()
+	if err := printDryRun(out, entries, OutputYAML); err != nil {
+		t.Fatalf("printDryRun() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "deployments.apps") {
+		t.Errorf("printDryRun() table does not list the resource:\n%s", out.String())
+	}
+}
+
+func Test_formatBytes(t *testing.T) {
+	tests := map[int]string{
+		512:         "512 B",
+		2048:        "2.0 KiB",
+		5 * 1 << 20: "5.0 MiB",
+	}
+	for in, want := range tests {
+		if got := FormatBytes(in); got != want {
+			t.Errorf("formatBytes(%d) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestExportOptions_prepareObjects_dryRun(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "transformed")
+	transformer, err := newExecTransformer(testTransformer(t, "touch "+marker+"; cat"), time.Second, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := testObject()
+	obj.SetResourceVersion("42")
+	resources := []*groupResource{{
+		APIGroup:    "apps",
+		APIVersion:  "v1",
+		APIResource: metav1.APIResource{Name: "deployments", Kind: "Deployment"},
+		objects:     &unstructured.UnstructuredList{Items: []unstructured.Unstructured{obj}},
+	}}
+	o := &ExportOptions{dryRun: true, transformer: transformer}
+
+	failures := o.prepareObjects(context.Background(), resources, newExportSummary("foo"), testLogger())
+
+	if len(failures) != 0 {
+		t.Errorf("prepareObjects() failures = %v, want none", failures)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Errorf("prepareObjects() should not run --transform-exec with --dry-run")
+	}
+	if got := resources[0].objects.Items[0].GetResourceVersion(); got != "" {
+		t.Errorf("prepareObjects() resourceVersion = %q, want the object stripped", got)
+	}
+}
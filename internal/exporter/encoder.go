@@ -0,0 +1,116 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// Encoder serializes an exported object for --output. It returns the bytes written and the
+// extension of the file, without the dot. The same object must always give the same bytes, which
+// --incremental and the checksums of the index rely on, and an encoder must always return the same
+// extension, which the file of an object is found again by, e.g. to prune it.
+type Encoder interface {
+	Encode(obj *unstructured.Unstructured) ([]byte, string, error)
+}
+
+// encoders are the serializations of the exported objects by --output value. Another format, like
+// CUE or Jsonnet, is added by implementing Encoder and registering it here under its name, which
+// makes it valid for --output. Only the yaml and json exports are read back by transform, apply and
+// the other commands, and --layout single always writes YAML.
+var encoders = map[string]Encoder{
+	OutputYAML: yamlEncoder{},
+	OutputJSON: jsonEncoder{},
+}
+
+// yamlEncoder writes YAML indented with two spaces, with the lists at the level of their key, the
+// map keys sorted and the long strings folded at 80 columns
+type yamlEncoder struct{}
+
+func (yamlEncoder) Encode(obj *unstructured.Unstructured) ([]byte, string, error) {
+	objBytes, err := yaml.Marshal(obj.Object)
+	return objBytes, OutputYAML, err
+}
+
+// jsonEncoder writes JSON indented with two spaces, with the map keys sorted
+type jsonEncoder struct{}
+
+func (jsonEncoder) Encode(obj *unstructured.Unstructured) ([]byte, string, error) {
+	objBytes, err := json.MarshalIndent(obj.Object, "", "  ")
+	if err != nil {
+		return nil, "", err
+	}
+	return append(objBytes, '\n'), OutputJSON, nil
+}
+
+// outputFormats returns the names of the registered encoders, sorted
+func outputFormats() []string {
+	formats := make([]string, 0, len(encoders))
+	for format := range encoders {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// encoderFor returns the encoder registered for the --output value
+func encoderFor(output string) (Encoder, error) {
+	encoder, ok := encoders[output]
+	if !ok {
+		return nil, fmt.Errorf("invalid output format %q, must be one of: %s", output, strings.Join(outputFormats(), ", "))
+	}
+	return encoder, nil
+}
+
+// encodeObject serializes an exported object with the encoder of the --output value, it returns
+// the extension of its file too
+func encodeObject(obj unstructured.Unstructured, output string) ([]byte, string, error) {
+	encoder, err := encoderFor(output)
+	if err != nil {
+		return nil, "", err
+	}
+	return encoder.Encode(&obj)
+}
+
+// marshalObject serializes an exported object with the encoder of the --output value, for the
+// callers that know the file already
+func marshalObject(obj unstructured.Unstructured, output string) ([]byte, error) {
+	objBytes, _, err := encodeObject(obj, output)
+	return objBytes, err
+}
+
+// ReadManifestFile reads the objects of an exported file, compressed or not. The files of the
+// single layout hold several objects.
+func ReadManifestFile(path string) ([]unstructured.Unstructured, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(path, gzipExtension) {
+		if data, err = gunzipBytes(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	objects := []unstructured.Unstructured{}
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		obj := unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if len(obj.Object) > 0 {
+			objects = append(objects, obj)
+		}
+	}
+	return objects, nil
+}
@@ -0,0 +1,90 @@
+package exporter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_encoders(t *testing.T) {
+	tests := []struct {
+		output  string
+		want    string
+		wantExt string
+	}{
+		{
+			output:  OutputYAML,
+			want:    "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: hello-world\n  namespace: foo\nspec:\n  replicas: 1\n",
+			wantExt: "yaml",
+		},
+		{
+			output:  OutputJSON,
+			want:    "{\n  \"apiVersion\": \"apps/v1\",\n  \"kind\": \"Deployment\",\n  \"metadata\": {\n    \"name\": \"hello-world\",\n    \"namespace\": \"foo\"\n  },\n  \"spec\": {\n    \"replicas\": 1\n  }\n}\n",
+			wantExt: "json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			encoder, err := encoderFor(tt.output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			obj := testObject()
+			got, ext, err := encoder.Encode(&obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want || ext != tt.wantExt {
+				t.Errorf("Encode() = %q, %q, want %q, %q", got, ext, tt.want, tt.wantExt)
+			}
+		})
+	}
+}
+
+func Test_encoderFor_unknown(t *testing.T) {
+	_, err := encoderFor("cue")
+	if err == nil || err.Error() != `invalid output format "cue", must be one of: json, yaml` {
+		t.Errorf("encoderFor() error = %v, want the registered formats listed", err)
+	}
+	o := &ExportOptions{output: "cue", layout: LayoutFlat}
+	if err := o.Validate(); err == nil || !strings.Contains(err.Error(), "must be one of: json, yaml") {
+		t.Errorf("Validate() error = %v, want the registered formats listed", err)
+	}
+}
+
+// nameEncoder writes the name of the object only, in files with its own extension
+type nameEncoder struct{}
+
+func (nameEncoder) Encode(obj *unstructured.Unstructured) ([]byte, string, error) {
+	return []byte(fmt.Sprintf("name: %q\n", obj.GetName())), "cue", nil
+}
+
+func Test_resourceWriter_registeredEncoder(t *testing.T) {
+	encoders["cue"] = nameEncoder{}
+	defer delete(encoders, "cue")
+
+	resources := []*groupResource{
+		{APIGroup: "apps", APIVersion: "v1", APIResource: metav1.APIResource{Name: "deployments", Kind: "Deployment"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{testObject()}}},
+	}
+	dir := t.TempDir()
+	w := &resourceWriter{resourceDir: dir, output: "cue", layout: LayoutFlat, workers: 1, log: testLogger()}
+	if errs := w.writeResources(resources); len(errs) > 0 {
+		t.Fatalf("writeResources() errors = %v", errs)
+	}
+	path := filepath.Join(dir, "deployments.apps_hello-world.cue")
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "name: \"hello-world\"\n" {
+		t.Errorf("%s = %q", path, got)
+	}
+	if removed, err := w.removeObject(resources[0], testObject()); err != nil || !removed {
+		t.Errorf("removeObject() = %v, %v, want the file removed", removed, err)
+	}
+}
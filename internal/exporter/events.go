@@ -0,0 +1,175 @@
+package exporter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/file"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// EventsDir is the directory of the Events exported with --include-events, they are a snapshot of
+// the namespace and are not read by transform and apply
+const EventsDir = "_events"
+
+// EventGVRs are the Event APIs, both serve the same objects. events.k8s.io comes first so that its
+// version of an Event is the one kept.
+var EventGVRs = []schema.GroupVersionResource{
+	{Group: "events.k8s.io", Version: "v1", Resource: "events"},
+	{Version: "v1", Resource: "events"},
+}
+
+// eventTimeFields are the fields holding the last time an Event was seen, by precedence, for the
+// core and the events.k8s.io Events
+var eventTimeFields = [][]string{
+	{"series", "lastObservedTime"},
+	{"lastTimestamp"},
+	{"deprecatedLastTimestamp"},
+	{"eventTime"},
+	{"metadata", "creationTimestamp"},
+}
+
+// listEvents lists the Events of the namespace from the served Event APIs, page by page, keeping
+// each Event once and only the ones seen within since when it is set
+func (o *ExportOptions) listEvents(ctx context.Context, namespace string, dynamicClient dynamic.Interface, lists []*metav1.APIResourceList, log logrus.FieldLogger) ([]unstructured.Unstructured, []FailureRecord) {
+	var cutoff time.Time
+	if o.eventsSince > 0 {
+		cutoff = time.Now().Add(-o.eventsSince)
+	}
+	events := []unstructured.Unstructured{}
+	failures := []FailureRecord{}
+	seen := map[string]bool{}
+	for _, gvr := range EventGVRs {
+		if !ServesResource(lists, gvr) {
+			continue
+		}
+		list, err := listPages(ctx, dynamicClient.Resource(gvr).Namespace(namespace), metav1.ListOptions{Limit: o.chunkSize}, log)
+		if err != nil {
+			log.Warnf("cannot list the events of %s: %v", gvr.GroupVersion(), err)
+			failures = append(failures, listFailureRecord(&groupResourceError{
+				APIResource: metav1.APIResource{Group: gvr.Group, Version: gvr.Version, Name: gvr.Resource, Kind: "Event"},
+				Error:       err,
+			}))
+			continue
+		}
+		for _, event := range list.Items {
+			if seen[string(event.GetUID())] {
+				continue
+			}
+			if !cutoff.IsZero() && eventTime(event).Before(cutoff) {
+				continue
+			}
+			seen[string(event.GetUID())] = true
+			events = append(events, event)
+		}
+	}
+	return events, failures
+}
+
+func ServesResource(lists []*metav1.APIResourceList, gvr schema.GroupVersionResource) bool {
+	for _, list := range lists {
+		if list.GroupVersion != gvr.GroupVersion().String() {
+			continue
+		}
+		for _, r := range list.APIResources {
+			if r.Name == gvr.Resource {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// eventTime returns the last time the Event was seen, the zero time when it is not set
+func eventTime(event unstructured.Unstructured) time.Time {
+	for _, field := range eventTimeFields {
+		value, _, _ := unstructured.NestedString(event.Object, field...)
+		if value == "" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// involvedObject returns the kind and name of the object the Event is about, from involvedObject
+// for the core Events and regarding for the events.k8s.io ones
+func involvedObject(event unstructured.Unstructured) (string, string) {
+	for _, field := range []string{"involvedObject", "regarding"} {
+		kind, _, _ := unstructured.NestedString(event.Object, field, "kind")
+		name, _, _ := unstructured.NestedString(event.Object, field, "name")
+		if kind != "" || name != "" {
+			return kind, name
+		}
+	}
+	return "", ""
+}
+
+// writeEvents writes the Events in dir, one List per involved object sorted by time, e.g.
+// pod_web-5d8f7.yaml
+func (o *ExportOptions) writeEvents(events []unstructured.Unstructured, dir string, manifests *exportIndex) []error {
+	byObject := map[string][]unstructured.Unstructured{}
+	for _, event := range events {
+		kind, name := involvedObject(event)
+		if kind == "" {
+			kind = "unknown"
+		}
+		key := strings.ToLower(kind) + "_" + name
+		byObject[key] = append(byObject[key], event)
+	}
+	if len(byObject) == 0 {
+		return nil
+	}
+
+	resource := &groupResource{APIVersion: "v1", APIGroupVersion: "v1", APIResource: metav1.APIResource{Name: "events", Kind: "Event"}}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return []error{&objectWriteError{resource: resource, name: EventsDir, category: failureIO, err: err}}
+	}
+	errs := []error{}
+	for _, key := range sortedKeys(byObject) {
+		objectEvents := byObject[key]
+		sort.SliceStable(objectEvents, func(i, j int) bool { return eventTime(objectEvents[i]).Before(eventTime(objectEvents[j])) })
+		items := make([]interface{}, 0, len(objectEvents))
+		for _, event := range objectEvents {
+			items = append(items, event.Object)
+		}
+		list := unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "List",
+			"items":      items,
+		}}
+		listBytes, ext, err := encodeObject(list, o.output)
+		path := filepath.Join(dir, safeFileName(key, "."+ext))
+		if err == nil && o.compress {
+			listBytes, err = gzipBytes(listBytes)
+			path += gzipExtension
+		}
+		if err != nil {
+			errs = append(errs, &objectWriteError{resource: resource, name: key, category: failureSerialization, err: err})
+			continue
+		}
+		if !o.budget.allow(len(objectEvents), len(listBytes)) {
+			break
+		}
+		if manifests.unchanged(path, listBytes) {
+			manifests.add(path, listBytes, nil)
+			continue
+		}
+		if err := file.WriteAtomic(file.OS, path, listBytes, o.durable); err != nil {
+			errs = append(errs, &objectWriteError{resource: resource, name: key, category: failureIO, err: err})
+			continue
+		}
+		manifests.add(path, listBytes, nil)
+	}
+	return errs
+}
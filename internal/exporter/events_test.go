@@ -0,0 +1,145 @@
+package exporter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"sigs.k8s.io/yaml"
+)
+
+func testCoreEvent(name, uid, kind, object string, lastTimestamp time.Time) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion":     "v1",
+		"kind":           "Event",
+		"metadata":       map[string]interface{}{"name": name, "namespace": "foo", "uid": uid},
+		"involvedObject": map[string]interface{}{"kind": kind, "name": object},
+		"lastTimestamp":  lastTimestamp.UTC().Format(time.RFC3339),
+	}}
+}
+
+func testEvent(name, uid, kind, object string, eventTime time.Time) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "events.k8s.io/v1",
+		"kind":       "Event",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "foo", "uid": uid},
+		"regarding":  map[string]interface{}{"kind": kind, "name": object},
+		"eventTime":  eventTime.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+	}}
+}
+
+func Test_listEvents(t *testing.T) {
+	now := time.Now()
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			{Version: "v1", Resource: "events"}:                         "EventList",
+			{Group: "events.k8s.io", Version: "v1", Resource: "events"}: "EventList",
+		},
+		testCoreEvent("web.1", "1", "Pod", "web", now.Add(-2*time.Hour)),
+		testCoreEvent("web.2", "2", "Pod", "web", now.Add(-10*time.Minute)),
+		testEvent("web.2", "2", "Pod", "web", now.Add(-10*time.Minute)),
+		testEvent("db.3", "3", "StatefulSet", "db", now.Add(-time.Minute)),
+	)
+	coreOnly := []*metav1.APIResourceList{{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "events"}}}}
+	both := append(coreOnly, &metav1.APIResourceList{GroupVersion: "events.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "events"}}})
+
+	tests := []struct {
+		name  string
+		lists []*metav1.APIResourceList
+		since time.Duration
+		want  []string
+	}{
+		{
+			name:  "each event is kept once, from events.k8s.io",
+			lists: both,
+			want:  []string{"events.k8s.io/v1 db.3", "events.k8s.io/v1 web.2", "v1 web.1"},
+		},
+		{
+			name:  "core events only when events.k8s.io is not served",
+			lists: coreOnly,
+			want:  []string{"v1 web.1", "v1 web.2"},
+		},
+		{
+			name:  "events seen within --events-since",
+			lists: both,
+			since: time.Hour,
+			want:  []string{"events.k8s.io/v1 db.3", "events.k8s.io/v1 web.2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &ExportOptions{eventsSince: tt.since}
+			events, failures := o.listEvents(context.Background(), "foo", client, tt.lists, testLogger())
+			if len(failures) != 0 {
+				t.Fatalf("listEvents() failures = %v", failures)
+			}
+			got := []string{}
+			for _, event := range events {
+				got = append(got, event.GetAPIVersion()+" "+event.GetName())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("listEvents() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_writeEvents(t *testing.T) {
+	now := time.Now()
+	events := []unstructured.Unstructured{
+		*testEvent("web.2", "2", "Pod", "web", now),
+		*testEvent("db.3", "3", "StatefulSet", "db", now),
+		*testCoreEvent("web.1", "1", "Pod", "web", now.Add(-time.Hour)),
+	}
+	exportDir := t.TempDir()
+	dir := filepath.Join(exportDir, "resources", "foo", EventsDir)
+	manifests := newExportIndex(exportDir)
+	o := &ExportOptions{output: OutputYAML}
+
+	if errs := o.writeEvents(events, dir, manifests); len(errs) != 0 {
+		t.Fatalf("writeEvents() errors = %v", errs)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	if want := []string{"pod_web.yaml", "statefulset_db.yaml"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("files = %v, want %v", names, want)
+	}
+	if len(manifests.entries) != 2 {
+		t.Errorf("indexed %d files, want 2", len(manifests.entries))
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "pod_web.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := &unstructured.UnstructuredList{}
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := list.UnmarshalJSON(jsonData); err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, event := range list.Items {
+		got = append(got, event.GetName())
+	}
+	if want := []string{"web.1", "web.2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("events of pod web = %v, want %v sorted by time", got, want)
+	}
+}
@@ -0,0 +1,24 @@
+package exporter
+
+// The exit codes of the commands besides 0 on success and 1 on fatal errors, each command documents
+// the ones it returns in its help
+const (
+	// ExitCodePartial is the exit code of a command that completed but failed on some of the
+	// objects: not exported, transformed, applied, migrated, copied or deleted
+	ExitCodePartial = 2
+
+	// ExitCodeCheckFailed is the exit code of a command whose checks did not all pass: objects
+	// that differ or are not healthy, failed validations, denied permissions, or pods still
+	// running after quiesce
+	ExitCodeCheckFailed = 3
+
+	// ExitCodeBudgetExceeded is the exit code of an export stopped by --max-resources or --max-bytes
+	ExitCodeBudgetExceeded = 4
+
+	// ExitCodeTimeout is the exit code of an export stopped by --timeout
+	ExitCodeTimeout = 5
+
+	// ExitCodeInterrupted is the exit code of an export stopped by SIGINT or SIGTERM, like a shell
+	// reports a process killed by SIGINT
+	ExitCodeInterrupted = 130
+)
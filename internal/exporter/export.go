@@ -0,0 +1,1527 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/archive"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/completion"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/encryption"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/preflight"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/s3"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/discovery"
+	"github.com/vmware-tanzu/velero/pkg/features"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+type ExportOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+
+	// Two GlobalFlags struct fields are needed
+	// 1. cobraGlobalFlags for explicit CLI args parsed by cobra
+	// 2. globalFlags for the args merged with values from the viper config file
+	cobraGlobalFlags *flags.GlobalFlags
+	globalFlags      *flags.GlobalFlags
+
+	rawConfig         api.Config
+	configFile        string
+	exportDir         string
+	archive           bool
+	archiveFile       string
+	compress          bool
+	verifyRoundTrip   bool
+	output            string
+	layout            string
+	raw               bool
+	dryRun            bool
+	maxResources      int64
+	maxBytes          string
+	maxObjectSize     string
+	objectLimit       int64
+	verbosity         int
+	quiet             bool
+	logFormat         string
+	workers           int
+	timeout           time.Duration
+	listTimeout       time.Duration
+	retries           int
+	retryBackoff      time.Duration
+	overwrite         bool
+	ignoreFailures    bool
+	strictDiscovery   bool
+	allVersions       bool
+	chunkSize         int64
+	builtinRoles      bool
+	clusterDeps       bool
+	webhooks          bool
+	platform          string
+	targetVersion     string
+	failOnDeprecated  bool
+	failOnDangling    bool
+	skipPreflight     bool
+	metricsFile       string
+	s3Endpoint        string
+	s3SSE             string
+	s3SSEKMSKeyID     string
+	quiesceCheck      bool
+	retryFailures     bool
+	resume            bool
+	watch             bool
+	watchDebounce     time.Duration
+	durable           bool
+	incremental       bool
+	prune             bool
+	events            bool
+	eventsSince       time.Duration
+	stripFields       []string
+	stripRules        []stripRule
+	stripAnnotations  []string
+	keepAnnotations   []string
+	annotations       *annotationFilter
+	transformExec     string
+	transformTimeout  time.Duration
+	transformWorkers  int
+	transformer       *execTransformer
+	imageMap          []string
+	imageMappings     []imageMapping
+	storageClassMap   []string
+	storageClasses    StorageClassMap
+	targetNamespace   string
+	preserveNodePorts bool
+	preserveClusterIP bool
+	redactSecrets     bool
+	secretTypes       []string
+	includeCRDs       bool
+	includeOwned      bool
+	includeJobHistory bool
+	excludeAnnotation string
+	onlyAnnotated     bool
+	skipHelmManaged   bool
+	includeSystem     bool
+	nameRegex         string
+	nameRe            *regexp.Regexp
+	excludeNameRegex  string
+	excludeNameRe     *regexp.Regexp
+	createdAfter      string
+	createdBefore     string
+	created           *createdWindow
+	ownedBy           string
+	application       *applicationSelector
+	encryptTo         []string
+	recipients        []age.Recipient
+	labelSelector     string
+	fieldSelector     string
+	includeResources  []string
+	excludeResources  []string
+	resourceFilter    *ResourceFilter
+	namespaces        []string
+	allNamespaces     bool
+	includeSystemNs   bool
+	clusterScopedRbac bool
+	asExtras          string
+	extras            map[string][]string
+	QPS               float32
+	Burst             int
+	// disableRateLimiter sends the requests without client-side rate limiter
+	disableRateLimiter bool
+	// flagsUsed are the flags set on the command line, recorded in the export summary
+	flagsUsed map[string]string
+	// effectiveConfig are the flags set on the command line or in the config file, with their
+	// source, printed by --dry-run
+	effectiveConfig []string
+	// resolvedDir is the expanded --export-dir, or --archive-file, of the run, recorded in the
+	// export summary
+	resolvedDir string
+	// retry is the previous export retried with --retry-failures
+	retry *failureRetry
+	// resumed is the checkpoint of the export resumed with --resume
+	resumed *exportProgress
+	// upload uploads the files of an export to an s3:// --export-dir as they are written
+	upload *s3Uploader
+	// budget bounds the objects and bytes written by the export with --max-resources and
+	// --max-bytes, byteLimit is --max-bytes in bytes
+	budget    *exportBudget
+	byteLimit int64
+	// flattenPaths writes the cluster-scoped objects next to the namespace ones, longPaths writes
+	// through paths with the Windows long path prefix
+	flattenPaths bool
+	longPaths    bool
+	// targetPodCIDRs and targetServiceCIDRs are the networks of the target cluster the ipBlocks
+	// of the NetworkPolicies are moved to, parsed into targetPod and targetService
+	targetPodCIDRs     []string
+	targetServiceCIDRs []string
+	targetPod          []netip.Prefix
+	targetService      []netip.Prefix
+	// metricsAddr serves the live metrics and the profiles of the export, live collects them
+	metricsAddr string
+	live        *liveMetrics
+
+	genericclioptions.IOStreams
+}
+
+func (o *ExportOptions) Complete(c *cobra.Command, args []string) error {
+	var err error
+
+	if err := o.loadConfig(c.Flags(), o.globalFlags.GetLogger()); err != nil {
+		return err
+	}
+
+	// a retry exports the failed resources like the previous export did
+	if o.retryFailures {
+		if o.retry, err = loadFailureRetry(o.exportDir); err != nil {
+			return err
+		}
+		if err := o.retry.applyFlags(c.Flags(), o.globalFlags.GetLogger()); err != nil {
+			return err
+		}
+	}
+	// a resumed export goes on with the flags of the interrupted one
+	if o.resume {
+		if o.resumed, err = loadExportProgress(o.exportDir); err != nil {
+			return err
+		}
+		if err := o.resumed.applyFlags(c.Flags(), o.globalFlags.GetLogger()); err != nil {
+			return err
+		}
+	}
+
+	o.rawConfig, err = o.configFlags.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return err
+	}
+	if err := validateContext(o.rawConfig, *o.configFlags.Context); err != nil {
+		return err
+	}
+
+	// the CRDs are the cluster-scoped dependencies of the custom resources
+	if o.clusterDeps {
+		o.includeCRDs = true
+	}
+
+	// an empty --namespace= is the same as no --namespace, like kubectl
+	o.namespaces = UniqueNamespaces(o.namespaces)
+	if len(o.namespaces) == 0 && !o.allNamespaces {
+		namespace, err := o.defaultNamespace()
+		if err != nil {
+			return err
+		}
+		o.globalFlags.GetLogger().Infof("No --namespace given, exporting the namespace %s of the current context", namespace)
+		o.namespaces = []string{namespace}
+	}
+
+	if o.asExtras != "" {
+		keysAndStrings := strings.Split(o.asExtras, ";")
+		o.extras = map[string][]string{}
+		for _, keysAndString := range keysAndStrings {
+			keyString := strings.Split(keysAndString, "=")
+			if len(keyString) != 2 {
+				return fmt.Errorf("extra options (%v) formatted incorrectly", o.asExtras)
+			}
+			o.extras[keyString[0]] = strings.Split(keyString[1], ",")
+		}
+	}
+
+	if err := o.completeTransforms(); err != nil {
+		return err
+	}
+
+	o.annotations, err = newAnnotationFilter(o.raw, o.stripAnnotations, o.keepAnnotations)
+	if err != nil {
+		return err
+	}
+
+	if o.targetPod, err = parseTargetCIDRs("target-pod-cidr", o.targetPodCIDRs); err != nil {
+		return err
+	}
+	if o.targetService, err = parseTargetCIDRs("target-service-cidr", o.targetServiceCIDRs); err != nil {
+		return err
+	}
+
+	o.recipients, err = encryption.ParseRecipients(o.encryptTo)
+	if err != nil {
+		return err
+	}
+
+	if o.byteLimit, err = parseBytes("--max-bytes", o.maxBytes); err != nil {
+		return err
+	}
+	if o.objectLimit, err = parseBytes("--max-object-size", o.maxObjectSize); err != nil {
+		return err
+	}
+
+	if o.archiveFile != "" {
+		o.archive = true
+	}
+	if o.archive && o.archiveFile == "" {
+		o.archiveFile = o.exportDir
+		if !strings.HasSuffix(o.archiveFile, ".tar.gz") && !strings.HasSuffix(o.archiveFile, ".tgz") {
+			o.archiveFile += ".tar.gz"
+		}
+	}
+
+	if o.nameRegex != "" {
+		o.nameRe, err = regexp.Compile(o.nameRegex)
+		if err != nil {
+			return fmt.Errorf("invalid --name-regex: %w", err)
+		}
+	}
+	if o.excludeNameRegex != "" {
+		o.excludeNameRe, err = regexp.Compile(o.excludeNameRegex)
+		if err != nil {
+			return fmt.Errorf("invalid --exclude-name-regex: %w", err)
+		}
+	}
+	if o.created, err = newCreatedWindow(o.createdAfter, o.createdBefore, time.Now()); err != nil {
+		return err
+	}
+	if o.ownedBy != "" {
+		if o.application, err = parseApplicationSelector(o.ownedBy); err != nil {
+			return err
+		}
+	}
+
+	if o.resourceFilter, err = NewResourceFilter(o.includeResources, o.excludeResources); err != nil {
+		return err
+	}
+
+	o.flagsUsed = map[string]string{}
+	c.Flags().Visit(func(f *pflag.Flag) {
+		o.flagsUsed[f.Name] = f.Value.String()
+	})
+	if o.resumed != nil {
+		for name, value := range o.resumed.Flags {
+			if _, used := o.flagsUsed[name]; !used && !resumeRunFlags[name] {
+				o.flagsUsed[name] = value
+			}
+		}
+	}
+
+	return nil
+}
+
+// completeTransforms parses the flags of the transforms applied to the exported objects, the ones
+// rewrite applies to a written export too
+func (o *ExportOptions) completeTransforms() error {
+	for _, field := range o.stripFields {
+		rule, err := parseStripRule(field)
+		if err != nil {
+			return err
+		}
+		o.stripRules = append(o.stripRules, rule)
+	}
+
+	var err error
+	o.transformer, err = newExecTransformer(o.transformExec, o.transformTimeout, o.transformWorkers)
+	if err != nil {
+		return err
+	}
+
+	o.imageMappings, err = parseImageMap(o.imageMap)
+	if err != nil {
+		return err
+	}
+
+	o.storageClasses, err = ParseStorageClassMap(o.storageClassMap)
+	return err
+}
+
+func (o *ExportOptions) Validate() error {
+	if o.asExtras != "" && *o.configFlags.Impersonate == "" && len(*o.configFlags.ImpersonateGroup) == 0 {
+		return fmt.Errorf("extras requires specifying a user or group to impersonate")
+	}
+	if _, err := encoderFor(o.output); err != nil {
+		return err
+	}
+	if o.layout != LayoutFlat && o.layout != LayoutKind && o.layout != LayoutSingle {
+		return fmt.Errorf("invalid layout %q, must be one of: %s, %s, %s", o.layout, LayoutFlat, LayoutKind, LayoutSingle)
+	}
+	if o.layout == LayoutSingle && o.output != OutputYAML {
+		return fmt.Errorf("--layout %s writes a multi-document YAML stream and requires --output %s", LayoutSingle, OutputYAML)
+	}
+	if o.eventsSince < 0 {
+		return fmt.Errorf("--events-since must not be negative")
+	}
+	if o.eventsSince > 0 && !o.events {
+		return fmt.Errorf("--events-since requires --include-events")
+	}
+	if o.verifyRoundTrip && o.output != OutputYAML && o.output != OutputJSON {
+		return fmt.Errorf("--verify-roundtrip reads back the %s and %s files only", OutputYAML, OutputJSON)
+	}
+	if o.verifyRoundTrip && o.layout == LayoutSingle {
+		return fmt.Errorf("--verify-roundtrip cannot be used with --layout %s", LayoutSingle)
+	}
+	if o.compress && o.archive {
+		return fmt.Errorf("--compress cannot be used with --archive, the archive is already compressed")
+	}
+	if o.platform != platformAuto && o.platform != platformKubernetes && o.platform != platformOpenShift {
+		return fmt.Errorf("invalid platform %q, must be one of: %s, %s, %s", o.platform, platformAuto, platformKubernetes, platformOpenShift)
+	}
+	if o.layout == LayoutSingle && len(o.encryptTo) > 0 {
+		return fmt.Errorf("--encrypt-secrets-to cannot be used with --layout %s", LayoutSingle)
+	}
+	if o.chunkSize < 0 {
+		return fmt.Errorf("--chunk-size must not be negative")
+	}
+	if o.maxResources < 0 {
+		return fmt.Errorf("--max-resources must not be negative")
+	}
+	if o.layout == LayoutSingle && o.allVersions {
+		return fmt.Errorf("--all-versions cannot be used with --layout %s, the versions of an object would conflict on apply", LayoutSingle)
+	}
+	if o.flattenPaths && o.layout != LayoutFlat {
+		return fmt.Errorf("--flatten-paths requires --layout %s", LayoutFlat)
+	}
+	if _, err := fields.ParseSelector(o.fieldSelector); err != nil {
+		return fmt.Errorf("invalid field selector %q: %w", o.fieldSelector, err)
+	}
+	if o.workers < 1 {
+		return fmt.Errorf("--workers must be at least 1")
+	}
+	if o.timeout < 0 || o.listTimeout < 0 {
+		return fmt.Errorf("--timeout and --list-timeout must not be negative")
+	}
+	if o.retries < 0 {
+		return fmt.Errorf("--retries must not be negative")
+	}
+	if err := o.validateTransforms(); err != nil {
+		return err
+	}
+	if o.retryBackoff <= 0 {
+		return fmt.Errorf("--retry-backoff must be positive")
+	}
+	if o.allNamespaces && len(o.namespaces) > 0 {
+		return fmt.Errorf("--namespace and --all-namespaces are mutually exclusive")
+	}
+	if o.ownedBy != "" && (o.allNamespaces || len(o.namespaces) > 1) {
+		return fmt.Errorf("--owned-by selects the objects of an application in a single namespace, it cannot be used with --all-namespaces or several namespaces")
+	}
+	if err := o.validatePathTemplates(); err != nil {
+		return err
+	}
+	if o.targetVersion != "" {
+		if _, err := ParseMinorVersion(o.targetVersion); err != nil {
+			return fmt.Errorf("invalid --target-version: %w", err)
+		}
+	}
+	if o.includeSystemNs && !o.allNamespaces {
+		return fmt.Errorf("--include-system-namespaces requires --all-namespaces")
+	}
+	if o.prune && !o.incremental {
+		return fmt.Errorf("--prune requires --incremental")
+	}
+	if o.incremental && (o.overwrite || o.archive || o.retryFailures) {
+		return fmt.Errorf("--incremental updates the previous export in --export-dir, it cannot be used with --overwrite, --archive or --retry-failures")
+	}
+	if err := o.validateS3(); err != nil {
+		return err
+	}
+	if err := o.validateWatch(); err != nil {
+		return err
+	}
+	if err := o.validateMetricsAddr(); err != nil {
+		return err
+	}
+	if err := o.validateRateLimiter(); err != nil {
+		return err
+	}
+	if o.logFormat != logFormatText && o.logFormat != logFormatJSON {
+		return fmt.Errorf("invalid log format %q, must be one of: %s, %s", o.logFormat, logFormatText, logFormatJSON)
+	}
+	_, verbosityChanged := o.flagsUsed["verbosity"]
+	if err := o.validateVerbosity(verbosityChanged); err != nil {
+		return err
+	}
+	if o.resume {
+		return o.validateResume()
+	}
+	if o.retryFailures {
+		return o.validateRetry()
+	}
+	return nil
+}
+
+// validateTransforms checks the flags of the transforms applied to the exported objects
+func (o *ExportOptions) validateTransforms() error {
+	if o.targetNamespace != "" {
+		if errs := validation.IsDNS1123Label(o.targetNamespace); len(errs) > 0 {
+			return fmt.Errorf("invalid --target-namespace %q: %s", o.targetNamespace, strings.Join(errs, ", "))
+		}
+	}
+	if o.transformTimeout < 0 {
+		return fmt.Errorf("--transform-timeout must not be negative")
+	}
+	if o.transformWorkers < 1 {
+		return fmt.Errorf("--transform-workers must be at least 1")
+	}
+	return nil
+}
+
+func (o *ExportOptions) Run() error {
+	log := o.newLogger()
+	o.budget = newExportBudget(o.maxResources, o.byteLimit)
+
+	// Ctrl-C stops listing the remaining resources, what was listed is still written. A second
+	// Ctrl-C quits without waiting for the writes.
+	ctx, stop := notifyContext(context.Background(), log, func() { os.Exit(ExitCodeInterrupted) })
+	defer stop()
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+	if o.metricsAddr != "" {
+		o.live = newLiveMetrics()
+		_, stopMetrics, err := serveMetrics(ctx, o.metricsAddr, o.live, log)
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+		defer stopMetrics()
+	}
+
+	return o.runTemplated(ctx, log)
+}
+
+// runOnce exports to the expanded export directory
+func (o *ExportOptions) runOnce(ctx context.Context, log logrus.FieldLogger) error {
+	if s3.IsURL(o.exportDir) && !o.dryRun {
+		return o.runToS3(ctx, log)
+	}
+	if o.archive && !o.dryRun {
+		return o.runToArchive(ctx, log)
+	}
+	return o.run(ctx, log)
+}
+
+// runToArchive exports into a staging directory and packs it into a single tarball, keeping the
+// same resources/<namespace> layout as a regular export
+func (o *ExportOptions) runToArchive(ctx context.Context, log logrus.FieldLogger) error {
+	stagingDir, err := os.MkdirTemp("", "kubectl-migrate-export-")
+	if err != nil {
+		log.Errorf("error creating the staging directory: %#v", err)
+		return err
+	}
+	defer os.RemoveAll(stagingDir)
+
+	exportDir := o.exportDir
+	o.exportDir = stagingDir
+	exportErr := o.run(ctx, log)
+	o.exportDir = exportDir
+
+	if err := archive.PackFile(stagingDir, o.archiveFile); err != nil {
+		log.Errorf("error writing the export archive: %#v", err)
+		return err
+	}
+	log.Infof("Export archive written to %s", o.archiveFile)
+
+	return exportErr
+}
+
+func (o *ExportOptions) run(ctx context.Context, log logrus.FieldLogger) error {
+	var err error
+	start := time.Now()
+	// previous are the files of the export updated with --incremental
+	var previous []index.Entry
+
+	if o.retry != nil {
+		o.namespaces = o.retry.namespaces()
+		if len(o.namespaces) == 0 {
+			log.Infof("No failures to retry in %s", o.exportDir)
+			return nil
+		}
+		log.Infof("Retrying the failures of %d namespaces: %s", len(o.namespaces), strings.Join(o.namespaces, ", "))
+	} else if o.incremental && !o.dryRun {
+		if previous, err = prepareIncremental(o.exportDir, log); err != nil {
+			log.Errorf("cannot use the export directory: %v", err)
+			return err
+		}
+	} else if !o.dryRun && o.resumed == nil {
+		if err := prepareExportDir(o.exportDir, o.overwrite, log); err != nil {
+			log.Errorf("cannot use the export directory: %v", err)
+			return err
+		}
+	}
+
+	discoveryClient, err := o.configFlags.ToDiscoveryClient()
+	if err != nil {
+		log.Errorf("cannot create discovery client: %#v", err)
+		return err
+	}
+
+	// Always request fresh data from the server
+	discoveryClient.Invalidate()
+
+	serverVersion := ""
+	if info, err := discoveryClient.ServerVersion(); err != nil {
+		log.Warnf("cannot get the server version: %v", err)
+	} else {
+		serverVersion = info.GitVersion
+	}
+
+	restConfig, err := o.restConfig(log)
+	if err != nil {
+		log.Errorf("cannot create rest config: %#v", err)
+		return err
+	}
+	log.Info(o.rateLimiterSettings())
+
+	restDynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		log.Errorf("cannot create dynamic client: %#v", err)
+		return err
+	}
+	policy := retryPolicy{retries: o.retries, backoff: o.retryBackoff, log: log}
+	dynamicClient := newRetryingDynamicClient(restDynamicClient, policy)
+
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		log.Errorf("cannot create kubernetes client: %#v", err)
+		return err
+	}
+
+	if o.allNamespaces {
+		o.namespaces, err = listNamespaces(client, o.includeSystemNs, log)
+		if err != nil {
+			log.Errorf("cannot list namespaces: %#v", err)
+			return err
+		}
+		log.Infof("Exporting %d namespaces: %s", len(o.namespaces), strings.Join(o.namespaces, ", "))
+	}
+
+	if !o.skipPreflight && !o.dryRun {
+		results := preflight.Run(ctx, client, o.namespaces, o.exportDir)
+		if !o.quietRun() || preflight.Failed(results) {
+			preflight.Print(o.ErrOut, results)
+		}
+		if preflight.Failed(results) {
+			log.Errorf("preflight checks failed, use --skip-preflight to export anyway")
+			return fmt.Errorf("preflight checks failed")
+		}
+	}
+
+	discoveryFailures, err := CheckDiscovery(discoveryClient, o.strictDiscovery, log)
+	if err != nil {
+		log.Errorf("cannot discover the server resources: %v", err)
+		return err
+	}
+
+	features.NewFeatureFlagSet()
+	features.Enable(velerov1api.APIGroupVersionsFeatureFlag)
+
+	discoveryHelper, err := discovery.NewHelper(discoveryClient, log)
+	if err != nil {
+		log.Errorf("cannot create discovery helper: %#v", err)
+		return err
+	}
+
+	if err := o.resourceFilter.Validate(discoveryHelper.Resources()); err != nil {
+		log.Errorf("invalid resource filter: %v", err)
+		return err
+	}
+
+	excluded := o.resourceFilter.excludedResources(discoveryHelper.Resources(), log)
+
+	if o.dryRun {
+		o.printEffectiveConfig(o.ErrOut)
+		entries := []dryRunEntry{}
+		for _, namespace := range o.namespaces {
+			resources, _, _ := o.collectResources(ctx, namespace, dynamicClient, discoveryHelper, newExportSummary(namespace), nil, nil, nil, nil, nil, log)
+			entries = append(entries, newDryRunEntries(namespace, resources, o.output)...)
+		}
+		return printDryRun(o.Out, entries, o.output)
+	}
+
+	// the cluster information only helps investigating later failures, it must not fail the export
+	info := newClusterInfo(o.rawConfig, *o.configFlags.Context, serverVersion, discoveryHelper.Resources())
+	networks := collectClusterNetworks(ctx, dynamicClient, log)
+	info.PodCIDRs, info.ServiceCIDRs = prefixStrings(networks.pod), prefixStrings(networks.service)
+	if o.retry == nil {
+		if err := info.write(o.exportDir); err != nil {
+			log.Warnf("cannot write %s: %v", clusterInfoFile, err)
+		}
+	}
+
+	exportRun := &exportRun{
+		images:            newImageInventory(),
+		helm:              newHelmReport(log),
+		imageRewrites:     newImageRewriter(o.imageMappings),
+		renamer:           NewNamespaceRenamer(o.targetNamespace),
+		manifests:         newExportIndex(o.exportDir),
+		excluded:          excluded,
+		discoveryFailures: discoveryFailures,
+		target:            o.deprecationTarget(serverVersion, log),
+		metrics:           newExportMetrics(),
+		retry:             o.retry,
+		streamer:          newObjectStreamer(client.CoreV1().RESTClient(), policy),
+	}
+	// the retry pass only exports the failed resources of the namespaces, the cluster-scoped
+	// objects and the events exported with them are kept
+	exportRun.manifests.upload = o.upload
+	exportRun.manifests.durable = o.durable
+	exportRun.metrics.live = o.live
+	exportRun.cidrs = newCIDRChecker(networks, o.targetPod, o.targetService)
+	if exportRun.cidrs == nil && len(o.targetPod)+len(o.targetService) > 0 {
+		log.Warnf("The pod and service networks of the source cluster could not be read, the ipBlocks of the NetworkPolicies are not rewritten by --target-pod-cidr and --target-service-cidr")
+	}
+	if o.retry != nil {
+		exportRun.manifests.load(o.retry.index)
+	}
+	if o.incremental {
+		exportRun.manifests.loadPrevious(previous)
+	}
+	if o.quiesceCheck {
+		exportRun.metadata, err = metadata.NewForConfig(restConfig)
+		if err != nil {
+			log.Errorf("cannot create metadata client: %#v", err)
+			return err
+		}
+	}
+	if o.retry == nil {
+		exportRun.graph = newDependencyGraph()
+		exportRun.workloads = newWorkloadReport(dynamicClient, log)
+	}
+	// the staging directory of an archive or of S3 is removed when the export stops
+	if o.retry == nil && !o.archive && o.upload == nil {
+		if o.resumed != nil {
+			exportRun.progress = o.resumed
+			exportRun.progress.resume(o.flagsUsed, log)
+		} else {
+			exportRun.progress = newExportProgress(o.exportDir, start, o.flagsUsed, log)
+		}
+	}
+	if o.includeCRDs && o.retry == nil {
+		exportRun.crds = newCRDCollector(dynamicClient, log)
+	}
+	if o.clusterDeps && o.retry == nil {
+		exportRun.clusterDeps = newClusterDepsCollector(dynamicClient, log)
+	}
+	if o.webhooks && o.retry == nil {
+		exportRun.webhooks = newWebhookCollector(dynamicClient, log)
+	}
+	platform := o.platform
+	if platform == platformAuto {
+		platform = info.Platform
+	}
+	if platform == platformOpenShift {
+		log.Infof("Exporting from OpenShift, resolving the ImageStreamTags of the pod templates and writing %s", routeHintsFile)
+		exportRun.streams = newImageStreamResolver(dynamicClient, log)
+		exportRun.routes = newRouteHints()
+	}
+
+	// A failing namespace must not abort the others, the export only fails when all of them did
+	var errs []error
+	summaries := []*exportSummary{}
+	notExported := []string{}
+	for _, namespace := range o.namespaces {
+		// after a timeout the namespaces are still visited so that their resources are recorded
+		// as timed out, an interruption skips them
+		if errors.Is(ctx.Err(), context.Canceled) {
+			log.Warnf("export interrupted, skipping namespace %s", namespace)
+			notExported = append(notExported, namespace)
+			continue
+		}
+		if limit := o.budget.exceededLimit(); limit != "" {
+			log.Warnf("%s reached, skipping namespace %s", limit, namespace)
+			notExported = append(notExported, namespace)
+			continue
+		}
+		if ctx.Err() == nil && o.allNamespaces && !namespaceExists(client, namespace) {
+			log.Warnf("namespace %s was deleted during the export, skipping", namespace)
+			continue
+		}
+		log.Infof("Exporting namespace %s", namespace)
+		summary, err := o.exportNamespace(ctx, namespace, dynamicClient, discoveryHelper, exportRun, log)
+		summaries = append(summaries, summary)
+		if err != nil {
+			log.Errorf("error exporting namespace %s: %v", namespace, err)
+			errs = append(errs, fmt.Errorf("namespace %s: %w", namespace, err))
+		}
+	}
+	// the objects are compared once every namespace is exported, over the whole export window
+	if exportRun.metadata != nil && ctx.Err() == nil {
+		for _, s := range summaries {
+			s.Drift = quiesceCheck(ctx, exportRun.metadata, s.Namespace, o.listOptions(), s.Lists, log)
+		}
+	}
+	if len(summaries) > 1 {
+		logNamespaceTotals(summaries, log)
+	}
+	var changes *incrementalChanges
+	if o.incremental {
+		// the files of an incomplete export are kept, their objects may still exist
+		complete := ctx.Err() == nil && len(errs) == 0
+		for _, s := range summaries {
+			complete = complete && s.Failures == 0
+		}
+		if o.prune && !complete {
+			log.Warnf("the export is incomplete, the files of the objects no longer exported are not pruned")
+		}
+		counts := exportRun.manifests.prune(o.namespaces, o.prune && complete, log)
+		log.Infof("Incremental export: %d added, %d changed, %d removed, %d unchanged", counts.Added, counts.Changed, counts.Removed, counts.Unchanged)
+		changes = &counts
+	}
+	runSummary := newRunSummary(start, serverVersion, o.flagsUsed, summaries)
+	runSummary.Incremental = changes
+	runSummary.ExportDir = o.resolvedDir
+	runSummary.SlowestResources = exportRun.metrics.slowest(slowestResources)
+	runSummary.Created = o.created.summarize(summaries)
+	runSummary.ExternalReferences = exportRun.graph.external()
+	for _, edge := range runSummary.ExternalReferences {
+		log.Warnf("%s references %s through %s, which is not exported", edge.From.location(), edge.To.location(), edge.Field)
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		runSummary.Interrupted = true
+		runSummary.NotExported = notExported
+	}
+	if runSummary.BudgetExceeded = o.budget.exceededLimit(); runSummary.BudgetExceeded != "" {
+		runSummary.NotExported = notExported
+	}
+	if exportRun.retry != nil {
+		runSummary = exportRun.retry.merge(runSummary)
+	}
+	if err := runSummary.write(o.exportDir); err != nil {
+		log.Errorf("error writing the export summary: %#v", err)
+		return err
+	}
+	// the reports of the whole export are kept by the retry pass
+	if exportRun.retry == nil {
+		if err := exportRun.images.write(o.exportDir); err != nil {
+			log.Errorf("error writing the image inventory: %#v", err)
+			return err
+		}
+		if err := exportRun.helm.write(o.exportDir); err != nil {
+			log.Errorf("error writing the Helm releases report: %#v", err)
+			return err
+		}
+		if err := exportRun.graph.write(o.exportDir, o.durable); err != nil {
+			log.Errorf("error writing %s: %#v", graphFile, err)
+			return err
+		}
+		if err := exportRun.workloads.write(o.exportDir, o.durable); err != nil {
+			log.Errorf("error writing %s: %#v", workloadsFile, err)
+			return err
+		}
+	}
+	if exportRun.renamer != nil && exportRun.retry == nil {
+		if warnings := len(exportRun.renamer.warnings); warnings > 0 {
+			log.Warnf("%d fields still mention the source namespace after --target-namespace, see %s", warnings, namespaceWarningsFile)
+		}
+		if err := exportRun.renamer.write(o.exportDir); err != nil {
+			log.Errorf("error writing %s: %#v", namespaceWarningsFile, err)
+			return err
+		}
+	}
+	if exportRun.imageRewrites != nil && exportRun.retry == nil {
+		if unmapped := len(exportRun.imageRewrites.unmapped); unmapped > 0 {
+			log.Warnf("%d images matched no --image-map, they are exported unchanged, see %s", unmapped, ImageRewritesFile)
+		}
+		if err := exportRun.imageRewrites.write(o.exportDir); err != nil {
+			log.Errorf("error writing %s: %#v", ImageRewritesFile, err)
+			return err
+		}
+	}
+	if exportRun.routes != nil && exportRun.retry == nil {
+		if err := exportRun.routes.write(o.exportDir); err != nil {
+			log.Errorf("error writing %s: %#v", routeHintsFile, err)
+			return err
+		}
+	}
+	if err := exportRun.manifests.write(); err != nil {
+		log.Errorf("error writing %s: %#v", index.File, err)
+		return err
+	}
+	if !o.quietRun() {
+		exportRun.metrics.print(o.ErrOut)
+	}
+	runSummary.printLine(o.ErrOut)
+	// the export stopped by a signal or --timeout can be resumed, the checkpoint is kept
+	if ctx.Err() == nil {
+		exportRun.progress.remove()
+	} else if exportRun.progress != nil {
+		log.Infof("The export can be resumed with --resume --export-dir %s", o.exportDir)
+	}
+	// the watch stopped by a signal or --timeout ends the export normally
+	timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+	if o.watch && ctx.Err() == nil && runSummary.BudgetExceeded == "" {
+		if err := o.watchExport(ctx, dynamicClient, exportRun, runSummary, log); err != nil {
+			return err
+		}
+	}
+	if o.metricsFile != "" {
+		if err := exportRun.metrics.write(o.metricsFile, start, o.flagsUsed); err != nil {
+			log.Errorf("error writing the metrics file: %#v", err)
+			return err
+		}
+	}
+	if runSummary.Interrupted {
+		return &InterruptedError{}
+	}
+	if runSummary.BudgetExceeded != "" {
+		return &BudgetExceededError{Limit: runSummary.BudgetExceeded}
+	}
+	if timedOut {
+		return &TimeoutError{Timeout: o.timeout}
+	}
+	if len(summaries) > 0 && len(errs) == len(summaries) {
+		return errorsutil.NewAggregate(errs)
+	}
+	deprecated := 0
+	for _, s := range summaries {
+		deprecated += len(s.Deprecated)
+	}
+	if deprecated > 0 && o.failOnDeprecated {
+		return &DeprecatedAPIError{Objects: deprecated}
+	}
+	dangling := len(runSummary.ExternalReferences)
+	for _, s := range summaries {
+		dangling += len(s.Dangling)
+	}
+	if dangling > 0 && o.failOnDangling {
+		return &DanglingReferencesError{References: dangling}
+	}
+
+	failures := len(errs)
+	for _, s := range summaries {
+		failures += s.Failures
+	}
+	if failures > 0 && !o.ignoreFailures {
+		return &PartialFailureError{Failures: failures}
+	}
+	return nil
+}
+
+// restConfig returns the client configuration of the export. User, group and uid impersonation
+// is handled from genericclioptions.ConfigFlags, the extras are added here.
+func (o *ExportOptions) restConfig(log logrus.FieldLogger) (*rest.Config, error) {
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	restConfig.Impersonate.Extra = o.extras
+	o.applyRateLimiter(restConfig, log)
+	if o.live != nil {
+		restConfig.Wrap(o.live.countRequests)
+	}
+
+	if imp := restConfig.Impersonate; imp.UserName != "" || len(imp.Groups) > 0 {
+		log.Infof("Exporting as user %q with groups %v, the resources it cannot list are recorded as permission failures", imp.UserName, imp.Groups)
+	}
+	return restConfig, nil
+}
+
+// exportRun holds what an export run collects across namespaces
+type exportRun struct {
+	crds        *crdCollector
+	clusterDeps *clusterDepsCollector
+	webhooks    *webhookCollector
+	// streams and routes are only set when exporting from OpenShift
+	streams   *imageStreamResolver
+	routes    *routeHints
+	images    *imageInventory
+	helm      *helmReport
+	manifests *exportIndex
+	// imageRewrites repoints the images with --image-map, it is nil without mappings
+	imageRewrites *imageRewriter
+	// renamer moves the objects to --target-namespace, it is nil without one
+	renamer *namespaceRenamer
+	// cidrs checks the ipBlocks of the NetworkPolicies, it is nil when the networks of the source
+	// cluster are not known
+	cidrs *cidrChecker
+	// workloads lists the scalable objects, it is not set by the retry pass
+	workloads *workloadReport
+	// excluded are the resources left out by --include-resources and --exclude-resources
+	excluded []string
+	// discoveryFailures are the API groups that could not be discovered, they are recorded in the
+	// failures of every namespace
+	discoveryFailures []FailureRecord
+	// target is the Kubernetes minor version the API deprecations are evaluated against, 0 when
+	// it is not known
+	target int
+	// metrics measures the listing and writing of each resource
+	metrics *exportMetrics
+	// metadata lists the resources again at the end of the export with --quiesce-check
+	metadata metadata.Interface
+	// retry is set by --retry-failures, only the failures of the previous export are exported
+	retry *failureRetry
+	// graph records the references between the exported objects, it is not set by the retry pass
+	graph *dependencyGraph
+	// progress is the checkpoint of the export, it is not set by the retry pass nor when the
+	// export is written to an archive or to S3
+	progress *exportProgress
+	// streamer lists the ConfigMaps and Secrets written page by page and fetches their large
+	// objects one by one
+	streamer *objectStreamer
+}
+
+func (o *ExportOptions) exportNamespace(ctx context.Context, namespace string, dynamicClient dynamic.Interface, discoveryHelper discovery.Helper, exportRun *exportRun, log logrus.FieldLogger) (*exportSummary, error) {
+	var err error
+	log = log.WithField(logFieldNamespace, namespace)
+
+	summary := newExportSummary(namespace)
+	summary.Excluded = exportRun.excluded
+
+	// create export directory if it doesnt exist, the single layout writes files next to it
+	resourceDir := filepath.Join(o.exportDir, "resources", namespace)
+	if o.layout != LayoutSingle {
+		err = os.MkdirAll(resourceDir, 0700)
+		switch {
+		case os.IsExist(err):
+		case err != nil:
+			log.Errorf("error creating the resources directory: %#v", err)
+			return summary, err
+		}
+	}
+	// create _cluster directory if it doesnt exist
+	clusterResourceDir := o.clusterDir(namespace)
+	if o.clusterScopedRbac && o.layout != LayoutSingle {
+		err = os.MkdirAll(clusterResourceDir, 0700)
+		switch {
+		case os.IsExist(err):
+		case err != nil:
+			log.Errorf("error creating the cluster resources directory: %#v", err)
+			return summary, err
+		}
+	}
+	// create export directory if it doesnt exist
+	failuresDir := filepath.Join(o.exportDir, "failures", namespace)
+	err = os.MkdirAll(failuresDir, 0700)
+	switch {
+	case os.IsExist(err):
+	case err != nil:
+		log.Errorf("error creating the failures directory: %#v", err)
+		return summary, err
+	}
+
+	var errs []error
+
+	// the failures that are not retried are recorded again with the new ones
+	var keptFailures []FailureRecord
+	if exportRun.retry != nil {
+		keptFailures = exportRun.retry.kept(namespace, discoveryHelper.Resources())
+		discoveryHelper = exportRun.retry.discovery(namespace, discoveryHelper)
+	}
+
+	// the objects of the streamed resources are written as they are listed, the others once every
+	// resource is listed
+	writer := o.newResourceWriter(namespace, exportRun, log)
+	writer.budget = o.budget
+	stream := o.newPageStream(namespace, exportRun, writer, summary, log)
+	resources, resourceErrs, referenceFailures := o.collectResources(ctx, namespace, dynamicClient, discoveryHelper, summary, exportRun.helm, exportRun.workloads, exportRun.metrics, exportRun.progress, stream, log)
+	referenceFailures = append(referenceFailures, stream.failureRecords()...)
+	if exportRun.retry != nil {
+		exportRun.retry.skipExported(namespace, resources)
+	}
+	if exportRun.streams != nil {
+		summary.ResolvedImages = exportRun.streams.resolve(ctx, resources)
+	}
+	if exportRun.routes != nil {
+		exportRun.routes.add(resources)
+	}
+	exportRun.images.add(resources)
+	summary.NetworkPolicyCIDRs = exportRun.cidrs.check(resources)
+	exportRun.graph.addObjects(stream.resources())
+	summary.Dangling = exportRun.graph.add(resources)
+	summary.UnresolvedWorkloadReferences = unresolvedWorkloadReferences(resources)
+	// the reports above are about the source namespace, and the image inventory keeps the source
+	// images, the ones to mirror
+	o.rewriteObjects(namespace, resources, exportRun, summary)
+
+	var namespaceObj *groupResource
+	if exportRun.retry == nil || exportRun.retry.rewritesNamespace(namespace) {
+		var synthesized bool
+		namespaceObj, synthesized = namespaceResource(ctx, dynamicClient, namespace, log)
+		if !o.raw {
+			stripServerPopulatedFields([]*groupResource{namespaceObj})
+		}
+		o.annotations.apply([]*groupResource{namespaceObj})
+		applyStripRules([]*groupResource{namespaceObj}, o.stripRules)
+		summary.NamespaceSynthesized = synthesized
+		exportRun.renamer.rename(namespace, []*groupResource{namespaceObj})
+	}
+
+	log.Debugf("attempting to write resources to files\n")
+	writer.namespace = namespaceObj
+	writeResourcesErrors := append(stream.errors(), writer.writeResources(resources)...)
+	// the cluster-scoped objects written with the namespace, checked for deprecated API versions
+	clusterObjects := []*groupResource{}
+	if exportRun.crds != nil {
+		writeResourcesErrors = append(writeResourcesErrors, o.writeCRDs(ctx, exportRun.crds, namespace, resources, exportRun.manifests, log)...)
+	}
+	if exportRun.webhooks != nil {
+		configs, failures := exportRun.webhooks.collect(ctx, namespace)
+		referenceFailures = append(referenceFailures, failures...)
+		summary.NamespaceWarnings += exportRun.renamer.rename(namespace, configs)
+		summary.addWebhooks(configs)
+		clusterObjects = append(clusterObjects, configs...)
+		writeResourcesErrors = append(writeResourcesErrors, o.writeClusterResources(configs, o.clusterDir(namespace, "webhooks"), namespace+"-webhooks.yaml", exportRun.manifests, log)...)
+	}
+	if exportRun.clusterDeps != nil {
+		deps, references := exportRun.clusterDeps.collect(ctx, namespace, resources)
+		summary.ClusterDependencies = references
+		clusterObjects = append(clusterObjects, deps...)
+		writeResourcesErrors = append(writeResourcesErrors, o.writeClusterDeps(namespace, deps, exportRun.manifests, log)...)
+	}
+	if o.events && exportRun.retry == nil {
+		events, failures := o.listEvents(ctx, namespace, dynamicClient, discoveryHelper.Resources(), log)
+		referenceFailures = append(referenceFailures, failures...)
+		summary.Events = len(events)
+		writeResourcesErrors = append(writeResourcesErrors, o.writeEvents(events, filepath.Join(resourceDir, EventsDir), exportRun.manifests)...)
+	}
+	for _, e := range writeResourcesErrors {
+		log.WithError(e).Warnf("error writing manifests to file: %#v, ignoring\n", e)
+	}
+
+	writeErrorsErrors := writeErrors(resourceErrs, failuresDir, log)
+	for _, e := range writeErrorsErrors {
+		log.Warnf("error writing errors to file: %#v, ignoring\n", e)
+	}
+
+	errs = append(errs, writeErrorsErrors...)
+
+	// the groups that could not be discovered and the objects that could not be written are
+	// partial failures, recorded with the list ones
+	records := append([]FailureRecord{}, exportRun.discoveryFailures...)
+	for _, e := range resourceErrs {
+		records = append(records, listFailureRecord(e))
+	}
+	records = append(records, referenceFailures...)
+	records = append(records, keptFailures...)
+	for _, e := range writeResourcesErrors {
+		records = append(records, writeFailureRecord(e))
+	}
+	if err := writeFailureRecords(failuresDir, records); err != nil {
+		log.Warnf("error writing %s: %#v\n", FailuresFile, err)
+		errs = append(errs, err)
+	}
+
+	exportRun.graph.addObjects(append([]*groupResource{namespaceObj}, clusterObjects...))
+	summary.addResources(resources)
+	summary.addResources(stream.resources())
+	summary.Deprecated = append(findDeprecated(resources, exportRun.target), findDeprecated(clusterObjects, exportRun.target)...)
+	summary.Failures = len(records)
+	summary.addEncrypted(o.exportDir, writer.encrypted)
+	summary.OverBudget = writer.overBudget
+	summary.log(log)
+
+	return summary, errorsutil.NewAggregate(errs)
+}
+
+// newResourceWriter returns the writer of the objects of the namespace
+func (o *ExportOptions) newResourceWriter(namespace string, exportRun *exportRun, log logrus.FieldLogger) *resourceWriter {
+	return &resourceWriter{
+		resourceDir:        filepath.Join(o.exportDir, "resources", namespace),
+		clusterResourceDir: o.clusterDir(namespace),
+		output:             o.output,
+		layout:             o.layout,
+		singleFile:         filepath.Join(o.exportDir, "resources", namespace+".yaml"),
+		clusterSingleFile:  filepath.Join(o.exportDir, "resources", namespace+"-cluster.yaml"),
+		workers:            o.workers,
+		recipients:         o.recipients,
+		compress:           o.compress,
+		verifyRoundTrip:    o.verifyRoundTrip,
+		durable:            o.durable,
+		index:              exportRun.manifests,
+		metrics:            exportRun.metrics,
+		log:                log,
+	}
+}
+
+// clusterDir returns the directory of the cluster-scoped objects exported with the namespace,
+// resources/<namespace>/_cluster/<elem>, or resources/<namespace> itself with --flatten-paths
+func (o *ExportOptions) clusterDir(namespace string, elem ...string) string {
+	if o.flattenPaths {
+		return filepath.Join(o.exportDir, "resources", namespace)
+	}
+	return filepath.Join(append([]string{o.exportDir, "resources", namespace, "_cluster"}, elem...)...)
+}
+
+// collectResources lists the admitted resources of the namespace and prepares the objects to be written.
+// The Helm-managed objects are recorded in the helm report, when given, before any of them is skipped.
+// The cluster-scoped objects referenced by the exported ones that could not be exported are returned
+// as failures. The resources streamed by stream are written as they are listed and not returned.
+func (o *ExportOptions) collectResources(ctx context.Context, namespace string, dynamicClient dynamic.Interface, discoveryHelper discovery.Helper, summary *exportSummary, helm *helmReport, workloads *workloadReport, metrics *exportMetrics, progress *exportProgress, stream *pageStream, log logrus.FieldLogger) ([]*groupResource, []*groupResourceError, []FailureRecord) {
+	snapshot := newListSnapshot()
+	resources, resourceErrs := resourceToExtract(ctx, namespace, o.listOptions(), o.clusterScopedRbac, o.allVersions, o.resourceFilter, o.workers, o.listTimeout, dynamicClient, discoveryHelper.Resources(), discoveryHelper.APIGroups(), metrics, snapshot, progress, stream, log)
+	summary.Lists = snapshot.lists()
+	resources = stream.exclude(resources)
+	clusterScopeHandler := NewClusterScopeHandler()
+	referenceFailures := o.dropLargeObjects(resources, log)
+	if o.clusterScopedRbac {
+		var rbacFailures []FailureRecord
+		resources, rbacFailures = clusterScopeHandler.filterRbacResources(resources, o.builtinRoles, log)
+		referenceFailures = append(referenceFailures, rbacFailures...)
+	}
+	if helm != nil {
+		helm.add(resources)
+	}
+	o.application.filter(namespace, resources, resources, summary, log)
+	applyObjectFilters(resources, o.objectFilters(resources), summary, log)
+	summary.Workloads = workloads.add(ctx, resources)
+	referenceFailures = append(referenceFailures, o.prepareObjects(ctx, resources, summary, log)...)
+
+	return resources, resourceErrs, referenceFailures
+}
+
+// prepareObjects removes the fields and annotations not exported from the objects kept by the
+// filters and transforms them. The objects that failed to be transformed are returned as failures.
+// With --dry-run the objects are only stripped, --transform-exec is not run and the sizes are
+// estimated from the stripped objects.
+func (o *ExportOptions) prepareObjects(ctx context.Context, resources []*groupResource, summary *exportSummary, log logrus.FieldLogger) []FailureRecord {
+	if !o.raw {
+		stripServerPopulatedFields(resources)
+		stripClusterAssigned(resources, clusterAssignment{preserveNodePorts: o.preserveNodePorts, preserveClusterIP: o.preserveClusterIP})
+		normalizeVersions(resources)
+	}
+	o.annotations.apply(resources)
+	applyStripRules(resources, o.stripRules)
+	if o.redactSecrets {
+		redactSecrets(resources)
+	}
+	failures := []FailureRecord{}
+	if !o.dryRun {
+		failures = o.transformer.apply(ctx, resources, summary, log)
+	}
+	if o.incremental {
+		normalizeObjects(resources)
+	}
+	return failures
+}
+
+// rewriteObjects rewrites the objects of a namespace for the target cluster in place: the images
+// are repointed with --image-map and the storage classes of the claims with --storageclass-map,
+// then the objects are moved to --target-namespace. The rewrites are added to the summary. diff
+// rewrites the live objects the same way to compare them with the export.
+func (o *ExportOptions) rewriteObjects(namespace string, resources []*groupResource, exportRun *exportRun, summary *exportSummary) {
+	summary.RewrittenImages += exportRun.imageRewrites.rewrite(resources)
+	storageClasses, unmapped := o.storageClasses.rewrite(resources)
+	summary.StorageClasses += storageClasses
+	summary.UnmappedStorageClasses = append(summary.UnmappedStorageClasses, unmapped...)
+	summary.NamespaceWarnings += exportRun.renamer.rename(namespace, resources)
+}
+
+// listOptions returns the options listing the resources of a namespace
+func (o *ExportOptions) listOptions() metav1.ListOptions {
+	return metav1.ListOptions{
+		LabelSelector: o.labelSelector,
+		FieldSelector: o.fieldSelector,
+		Limit:         o.chunkSize,
+	}
+}
+
+// writeCRDs writes the CRDs of the namespace custom resources under _cluster/crds
+func (o *ExportOptions) writeCRDs(ctx context.Context, crds *crdCollector, namespace string, resources []*groupResource, manifests *exportIndex, log logrus.FieldLogger) []error {
+	collected := crds.collect(ctx, namespace, resources)
+	if len(collected.objects.Items) == 0 {
+		return nil
+	}
+	return o.writeClusterResources([]*groupResource{collected}, o.clusterDir(namespace, "crds"), namespace+"-crds.yaml", manifests, log)
+}
+
+// writeClusterDeps writes the cluster-scoped objects referenced by the namespace objects under
+// _cluster/<resource>
+func (o *ExportOptions) writeClusterDeps(namespace string, deps []*groupResource, manifests *exportIndex, log logrus.FieldLogger) []error {
+	if o.layout == LayoutSingle {
+		return o.writeClusterResources(deps, "", namespace+"-deps.yaml", manifests, log)
+	}
+	errs := []error{}
+	for _, r := range deps {
+		errs = append(errs, o.writeClusterResources([]*groupResource{r}, o.clusterDir(namespace, r.APIResource.Name), "", manifests, log)...)
+	}
+	return errs
+}
+
+// writeClusterResources writes cluster-scoped objects exported with the namespace in dir, or in
+// resources/<singleFile> with the single layout
+func (o *ExportOptions) writeClusterResources(resources []*groupResource, dir string, singleFile string, manifests *exportIndex, log logrus.FieldLogger) []error {
+	if !o.raw {
+		stripServerPopulatedFields(resources)
+	}
+	o.annotations.apply(resources)
+	writer := &resourceWriter{
+		clusterResourceDir: dir,
+		output:             o.output,
+		workers:            1,
+		compress:           o.compress,
+		verifyRoundTrip:    o.verifyRoundTrip,
+		index:              manifests,
+		budget:             o.budget,
+		log:                log,
+	}
+	if o.layout == LayoutSingle {
+		writer.layout = LayoutSingle
+		writer.clusterSingleFile = filepath.Join(o.exportDir, "resources", singleFile)
+	}
+	return writer.writeResources(resources)
+}
+
+// NewExportOptions returns the options of the export command
+func NewExportOptions(streams genericclioptions.IOStreams, f *flags.GlobalFlags) *ExportOptions {
+	return &ExportOptions{
+		configFlags: genericclioptions.NewConfigFlags(true),
+
+		IOStreams:        streams,
+		cobraGlobalFlags: f,
+		globalFlags:      f,
+	}
+}
+
+// RegisterFlags registers the export flags and the kubeconfig ones on the command
+func (o *ExportOptions) RegisterFlags(cmd *cobra.Command) {
+	o.addFlags(cmd.Flags())
+	// the namespace flag is registered above so it can take several values
+	o.configFlags.Namespace = nil
+	o.configFlags.AddFlags(cmd.Flags())
+	cmd.RegisterFlagCompletionFunc("namespace", completion.Namespaces(o.configFlags))
+	cmd.RegisterFlagCompletionFunc("include-resources", completion.Resources(o.configFlags))
+	cmd.RegisterFlagCompletionFunc("exclude-resources", completion.Resources(o.configFlags))
+}
+
+// UnmarshalConfig merges the values of the viper config file with the flags
+func (o *ExportOptions) UnmarshalConfig() {
+	viper.Unmarshal(o.globalFlags)
+	viper.Unmarshal(o.configFlags)
+	viper.UnmarshalKey("export-dir", &o.exportDir)
+}
+
+// addFlags registers the export flags, the diff command reads the export ones recorded in the
+// summary with them
+func (o *ExportOptions) addFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&o.exportDir, "export-dir", "e", "export", "The path where files are to be exported, it may contain the tokens {namespace}, {context}, {date} and {time}, a literal brace is written {{ or }}. With {namespace} each namespace is exported to its own directory. An s3://bucket/prefix URL uploads each file to the bucket as it is written, with the credentials of the AWS environment variables or shared files")
+	flags.BoolVar(&o.overwrite, "overwrite", false, "Replace a previous export found in the export directory. Only the content written by export is removed")
+	flags.StringVar(&o.configFile, "config", "", "A YAML file of flag names and their values used as defaults, e.g. 'namespace: [frontend, backend]'. Explicit flags take precedence. Defaults to ~/.config/kubectl-migrate/config.yaml when it exists")
+	flags.BoolVar(&o.compress, "compress", false, "Gzip each resource file, written with a .gz extension (e.g. .yaml.gz). The reports at the root of the export directory and the failures are not compressed. Cannot be used with --archive")
+	flags.BoolVar(&o.verifyRoundTrip, "verify-roundtrip", false, "Read each written file back and compare it with the exported object, after the removal of the server populated fields. The objects whose file reads back with a field changed, in value or type like an int-or-string field, are recorded with the fields that differ in the round-trip category of failures/<namespace>/"+FailuresFile+". Off by default as every file is read again. Cannot be used with --layout single")
+	flags.BoolVar(&o.durable, "durable", false, "Flush each written file and index.json to disk before going on, so that a host crash cannot lose them. The files are always written to a temporary file renamed into place, a file is never left truncated")
+	flags.BoolVar(&o.incremental, "incremental", false, "Update the previous export in --export-dir, only the files whose content changed are written again, per the checksums of its index. The summary counts the added, changed, removed and unchanged files")
+	flags.BoolVar(&o.prune, "prune", false, "With --incremental, remove the files of the objects that no longer exist. They are kept when the export is incomplete")
+	flags.BoolVar(&o.retryFailures, "retry-failures", false, "Export again only the resources and objects recorded in the failures of the previous export in --export-dir, with its flags. The exported objects are added to it, the failures, the summary and the index are rewritten")
+	flags.BoolVar(&o.resume, "resume", false, "Resume the export in --export-dir stopped before its end, by Ctrl-C, --timeout or a crash, with its flags. The resources listed completely per its "+progressFile+" checkpoint are not listed again, the one being listed continues from its last page when its continue token is still valid. The checkpoint is removed when the export ends")
+	flags.BoolVar(&o.watch, "watch", false, "After the export, watch the exported resources of the namespaces and keep their files in sync until Ctrl-C or --timeout: the file of a changed object is written again and the file of a deleted object is removed, then the index is updated. Resources whose watch expired are listed again. The cluster-scoped objects, the events and the reports like "+ImagesFile+" are not updated. Cannot be used with --archive, an s3:// --export-dir or --layout single")
+	flags.DurationVar(&o.watchDebounce, "watch-debounce", 2*time.Second, "With --watch, the time an object must stay unchanged before its file is written, so that an object changed several times in a row is written once")
+	flags.BoolVar(&o.quiesceCheck, "quiesce-check", false, "List the metadata of every exported resource again at the end of the export and warn about the objects added, removed or modified after their resource was listed, they are recorded as drift in the export summary")
+	flags.StringVar(&o.s3Endpoint, "s3-endpoint", "", "With an s3:// --export-dir, the endpoint of the S3-compatible storage, like MinIO or Ceph (e.g. https://minio.example.com:9000), its buckets are addressed by path. Defaults to AWS S3 in the region of AWS_REGION")
+	flags.StringVar(&o.s3SSE, "s3-sse", "", "With an s3:// --export-dir, the server-side encryption of the uploaded files, one of: AES256, aws:kms")
+	flags.StringVar(&o.s3SSEKMSKeyID, "s3-sse-kms-key-id", "", "With --s3-sse aws:kms, the KMS key encrypting the uploaded files, defaults to the KMS key of the bucket")
+	flags.StringVar(&o.metricsAddr, "metrics-addr", "", "Serve the metrics of the export in the Prometheus format on http://<addr>/metrics, the objects listed and exported, the API requests, the client-side throttling and the list failures per resource, and the Go profiles on /debug/pprof, for the duration of the export (e.g. localhost:9090). Disabled by default, an address without host listens on every interface")
+	flags.StringVar(&o.metricsFile, "metrics-file", "", "Also write the time, pages, objects and bytes of every exported resource as JSON to this file, it may contain the same tokens as --export-dir")
+	flags.BoolVar(&o.skipPreflight, "skip-preflight", false, "Do not check the cluster connectivity, the namespaces, the list permissions and the export directory before exporting, see the preflight command")
+	flags.BoolVar(&o.events, "include-events", false, "Export the Events of the namespace, from the core and events.k8s.io APIs, under resources/<namespace>/"+EventsDir+" with one file per involved object. They are a snapshot for investigation and are not read by transform and apply")
+	flags.DurationVar(&o.eventsSince, "events-since", 0, "With --include-events, export only the Events seen within this duration (e.g. 1h), from their last timestamp or event time")
+	flags.StringSliceVar(&o.secretTypes, "exclude-secret-types", []string{"kubernetes.io/service-account-token", helmReleaseSecretType}, "A comma-separated list of Secret types to skip, the skipped Secrets are counted per type in the summary. An empty value exports the Secrets of every type")
+	flags.StringVar(&o.platform, "platform", platformAuto, "The platform of the source cluster, one of: auto, kubernetes, openshift. On OpenShift the ImageStreamTags referenced by the pod templates are replaced with the images they point to, by digest, and the Routes are described in "+routeHintsFile+" to help writing Ingresses. auto detects OpenShift from the API groups served")
+	flags.BoolVar(&o.webhooks, "include-webhooks", false, "Export the webhooks of the Validating and MutatingWebhookConfigurations calling a service of the exported namespace under resources/<namespace>/_cluster/webhooks. The CA bundles are kept as is and may need to be regenerated on the target cluster")
+	flags.BoolVar(&o.clusterDeps, "include-cluster-deps", false, "Export the cluster-scoped objects referenced by the exported ones, like PriorityClasses, RuntimeClasses, StorageClasses, IngressClasses and the CRDs of custom resources, under resources/<namespace>/_cluster/<resource>")
+	flags.BoolVar(&o.builtinRoles, "include-builtin-roles", false, "With --cluster-scoped-rbac, also export the built-in ClusterRoles referenced by the bindings: cluster-admin, admin, edit, view and the system: roles")
+	flags.Int64Var(&o.chunkSize, "chunk-size", 500, "The number of objects listed per request, 0 lists each resource in a single request. With the flat and kind layouts the ConfigMaps and Secrets are written page by page as they are listed")
+	flags.BoolVar(&o.allVersions, "all-versions", false, "Export the resources in every version served, not only the preferred one. The objects of the other versions are written with their version appended to the file name, e.g. <resource>.<group>_<name>_<version>.yaml")
+	flags.BoolVar(&o.strictDiscovery, "strict-discovery", false, "Fail when an API group cannot be discovered, like the aggregated API of an unavailable metrics-server. By default its resources are recorded as failures and the other groups are exported")
+	flags.BoolVar(&o.ignoreFailures, "ignore-failures", false, "Exit with 0 when some resources or objects could not be exported, instead of 2. The failures are still recorded in failures/<namespace>/"+FailuresFile)
+	flags.StringVar(&o.output, "output", OutputYAML, "The serialization of the exported resource files, one of: "+strings.Join(outputFormats(), ", "))
+	flags.StringVar(&o.layout, "layout", LayoutFlat, "The layout of the exported files in resources/<namespace>, one of: flat (all the files in the namespace directory), kind (a <group>_<resource> directory per resource, e.g. apps_deployments/hello-world.yaml), single (a resources/<namespace>.yaml multi-document YAML stream ordered to be applied as is, cluster-scoped objects in resources/<namespace>-cluster.yaml)")
+	flags.BoolVar(&o.flattenPaths, "flatten-paths", false, "Write the cluster-scoped objects exported with a namespace, like the CRDs and the --include-cluster-deps ones, in resources/<namespace> next to the namespace objects instead of the resources/<namespace>/_cluster/<resource> directories, keeping the paths short. Requires --layout flat")
+	flags.BoolVar(&o.longPaths, "long-paths", false, "On Windows, write the files through absolute paths with the \\\\?\\ long path prefix, so that the paths longer than 260 characters can be written without enabling long paths system-wide. No effect on other systems")
+	flags.IntVarP(&o.verbosity, "verbosity", "v", verbosityDefault, "The verbosity of the export logs: 0 only prints the errors and the final summary line, 1 the progress per namespace, 2 each resource as it is listed and written, 3 every object written. The logs are written to stderr")
+	flags.BoolVar(&o.quiet, "quiet", false, "Only print the errors and the final summary line, an alias of --verbosity 0")
+	flags.StringVar(&o.logFormat, "log-format", logFormatText, "The format of the logs on stderr: text or json, one JSON object per line with the level, timestamp and message, and the gvr, namespace, object, action and error fields when they apply")
+	flags.BoolVar(&o.dryRun, "dry-run", false, "List the resources that would be exported with their object count and estimated size, without writing anything. Use --output json for a machine readable list")
+	flags.BoolVar(&o.raw, "raw", false, "Export the objects as returned by the API server, without removing status, managedFields and other server populated metadata")
+	flags.StringVar(&o.targetVersion, "target-version", "", "The Kubernetes version of the target cluster (e.g. 1.29) the exported API versions are checked against for deprecations, defaults to the version of the source cluster")
+	flags.BoolVar(&o.failOnDeprecated, "fail-on-deprecated", false, "Fail the export when exported objects use API versions deprecated in the target version, after writing them")
+	flags.BoolVar(&o.failOnDangling, "fail-on-dangling-refs", false, "Fail the export when exported objects reference objects that are not exported, like a Secret of another namespace, after writing them")
+	flags.BoolVar(&o.preserveNodePorts, "preserve-nodeports", false, "Keep the node ports and health check node ports allocated to the Services, which are removed by default as they may collide on the target cluster")
+	flags.BoolVar(&o.preserveClusterIP, "preserve-cluster-ip", false, "Keep the cluster IPs allocated to the Services, which are removed by default as they are immutable and may not be free on the target cluster")
+	flags.StringArrayVar(&o.stripFields, "strip-fields", nil, "A Kind:field.path rule removing a field from the exported objects of a kind, * matching all kinds (e.g. Deployment:spec.replicas or *:metadata.labels['app.kubernetes.io/version']). Can be repeated")
+	flags.StringArrayVar(&o.stripAnnotations, "strip-annotations", nil, "Regular expressions of the metadata annotation keys removed from the exported objects, on top of the kubectl and controller bookkeeping ones removed by default (kubectl.kubernetes.io/last-applied-configuration, deployment.kubernetes.io/*, pv.kubernetes.io/*, ...). Can be repeated")
+	flags.StringArrayVar(&o.keepAnnotations, "keep-annotations", nil, "Regular expressions of the metadata annotation keys never removed, e.g. 'kubectl\\.kubernetes\\.io/last-applied-configuration' to keep the last applied configuration. Takes precedence over --strip-annotations. Can be repeated")
+	flags.StringArrayVar(&o.imageMap, "image-map", nil, "Repoint the container images of a registry to another one, e.g. quay.io=mirror.example.com:5000/quay, keeping their repository path, tag and digest. The images without a registry are matched as docker.io ones. The rewrites are listed in "+ImageRewritesFile+". Can be repeated")
+	flags.StringSliceVar(&o.targetPodCIDRs, "target-pod-cidr", nil, "The pod network of the target cluster, one per IP family (e.g. 10.128.0.0/14). The ipBlocks of the NetworkPolicies within the pod network of the source cluster are moved to it at the same offset")
+	flags.StringSliceVar(&o.targetServiceCIDRs, "target-service-cidr", nil, "The service network of the target cluster, one per IP family (e.g. 172.30.0.0/16). The ipBlocks of the NetworkPolicies within the service network of the source cluster are moved to it at the same offset")
+	flags.StringVar(&o.targetNamespace, "target-namespace", "", "Move the exported objects to this namespace: their metadata.namespace, the Namespace manifest, the ServiceAccount subjects of the bindings, the services of the webhook configurations and the service DNS names of the ExternalName Services are rewritten. The other fields mentioning the source namespace are listed in "+namespaceWarningsFile)
+	flags.StringArrayVar(&o.storageClassMap, "storageclass-map", nil, "Rewrite the storage class of the PersistentVolumeClaims and of the volumeClaimTemplates of the StatefulSets, e.g. gp2=standard-rwo. *=target maps the classes without a mapping of their own and the claims using the default class implicitly. Can be repeated")
+	flags.StringVar(&o.transformExec, "transform-exec", "", "An executable run on every exported object, reading it as JSON on stdin and writing the object to export as JSON on stdout. A non-zero exit code drops the object")
+	flags.DurationVar(&o.transformTimeout, "transform-timeout", 10*time.Second, "The maximum duration of --transform-exec on a single object, the objects it did not transform in time are recorded as failures. No limit when 0")
+	flags.IntVar(&o.transformWorkers, "transform-workers", 4, "The number of objects transformed concurrently by --transform-exec")
+	flags.BoolVar(&o.redactSecrets, "redact-secrets", false, "Replace the values of exported Secrets with a placeholder, keeping their keys. Redacted Secrets are annotated with "+redactedAnnotation+"=true")
+	flags.StringSliceVar(&o.encryptTo, "encrypt-secrets-to", nil, "A comma-separated list of age public keys (age1...) to encrypt the exported Secrets for. Encrypted Secrets are written with an additional .age extension, see the decrypt command")
+	flags.StringVar(&o.excludeAnnotation, "exclude-annotation", excludeAnnotation, "Skip the objects carrying this annotation set to true, each skipped object is listed in the export summary. Disabled when empty")
+	flags.BoolVar(&o.onlyAnnotated, "only-annotated", false, "Export only the objects annotated with "+includeAnnotation+"=true")
+	flags.BoolVar(&o.skipHelmManaged, "skip-helm-managed", false, "Skip the objects installed by Helm, they are still listed with their release in "+HelmReleasesFile)
+	flags.BoolVar(&o.includeSystem, "include-system-objects", false, "Export the objects generated by the cluster, which are skipped by default: the default ServiceAccount, service account token Secrets, the kube-root-ca.crt ConfigMap and the Endpoints of Services with a selector")
+	flags.BoolVar(&o.includeOwned, "include-owned", false, "Export the objects managed by a controller, like the ReplicaSets and Pods of a Deployment, which are skipped by default")
+	flags.BoolVar(&o.includeJobHistory, "include-job-history", false, "Export the Jobs run by CronJobs and the Pods run by Jobs, which are skipped by default")
+	flags.BoolVar(&o.includeCRDs, "include-crds", false, "Export the CustomResourceDefinitions of the exported custom resources under resources/<namespace>/_cluster/crds")
+	flags.BoolVar(&o.archive, "archive", false, "Write the export as a single tar.gz archive instead of a directory. The archive is written to the export-dir path, with a .tar.gz extension added when missing")
+	flags.StringVar(&o.archiveFile, "archive-file", "", "The path of the tar.gz archive to write, implies --archive")
+	flags.StringVarP(&o.labelSelector, "label-selector", "l", "", "Restrict export to resources matching a label selector")
+	flags.StringVar(&o.nameRegex, "name-regex", "", "Export only the objects whose name matches this regular expression, for every kind")
+	flags.StringVar(&o.ownedBy, "owned-by", "", `Export only the objects of the application of a top-level object given as Kind/name, e.g. Deployment/hello-world. From that object, the following objects of its namespace are added until none is: the objects it references (ConfigMaps, Secrets, PersistentVolumeClaims and ServiceAccount of the pod template, Role of a RoleBinding, Service of an Ingress), the objects it controls when exported with --include-owned, the Services and PodDisruptionBudgets whose selector matches its pod labels, and the Ingresses, HorizontalPodAutoscalers and RoleBindings referencing it. The references to objects that do not exist are warned about`)
+	flags.StringVar(&o.createdAfter, "created-after", "", "Export only the objects created after this time, a duration before now like 24h or an RFC3339 timestamp like 2024-01-31T00:00:00Z, per their metadata.creationTimestamp")
+	flags.StringVar(&o.createdBefore, "created-before", "", "Export only the objects created before this time, a duration before now like 8760h or an RFC3339 timestamp, per their metadata.creationTimestamp")
+	flags.StringVar(&o.excludeNameRegex, "exclude-name-regex", "", "Skip the objects whose name matches this regular expression, for every kind (e.g. '^sh\\.helm\\.release\\.' for Helm release Secrets)")
+	flags.StringVar(&o.fieldSelector, "field-selector", "", "Restrict export to resources matching a field selector (e.g. metadata.name=foo). Resources that do not support the selector are recorded as failures")
+	flags.StringSliceVar(&o.includeResources, "include-resources", nil, "A comma-separated list of resources to export, as resource or resource.group (e.g. deployments.apps,configmaps). All resources are exported when empty")
+	flags.StringSliceVar(&o.excludeResources, "exclude-resources", nil, "A comma-separated list of resources to skip, as resource or resource.group. Use *.group to skip a whole group (e.g. events,*.events.k8s.io). Takes precedence over --include-resources")
+	flags.BoolVarP(&o.clusterScopedRbac, "cluster-scoped-rbac", "c", false, "Include cluster-scoped RBAC resources")
+	flags.StringVar(&o.asExtras, "as-extras", "", "The extra info for impersonation can only be used with User or Group but is not required. An example is --as-extras key=string1,string2;key2=string3")
+	flags.IntVar(&o.workers, "workers", 4, "The number of resources listed and written concurrently")
+	flags.Int64Var(&o.maxResources, "max-resources", 0, "Stop the export once this number of objects is written, the namespaces left are not exported and the command exits with 4. No limit when 0")
+	flags.StringVar(&o.maxObjectSize, "max-object-size", "", "Skip the objects larger than this size, as bytes or a quantity like 5Mi, like a ConfigMap holding an archive. They are dropped as soon as they are listed, the ConfigMaps and Secrets above 1Mi written page by page without being fetched, and recorded as too-large failures. No limit when empty or 0")
+	flags.StringVar(&o.maxBytes, "max-bytes", "", "Stop the export once this number of bytes is written, as bytes or a quantity like 500Mi or 10G. The namespaces left are not exported and the command exits with 4. No limit when empty or 0")
+	flags.DurationVar(&o.timeout, "timeout", 0, "The maximum duration of the whole export, e.g. 10m. The resources not listed in time are recorded as timed out and the command exits with 5. No limit when 0")
+	flags.DurationVar(&o.listTimeout, "list-timeout", 2*time.Minute, "The maximum duration of listing a single resource, so that an unresponsive API service does not stall the export. No limit when 0")
+	flags.IntVar(&o.retries, "retries", 3, "The number of times a list or get failing with a transient error (429, 503, timeouts) is retried")
+	flags.DurationVar(&o.retryBackoff, "retry-backoff", 500*time.Millisecond, "The delay before the first retry, doubled on each following retry with some jitter. A longer Retry-After from the server is honored")
+	flags.Float32VarP(&o.QPS, "qps", "q", 100, "The queries per second of the client-side rate limiter, shared by every request of the export")
+	flags.IntVarP(&o.Burst, "burst", "b", 1000, "The burst of the client-side rate limiter, the number of requests sent at once before --qps applies")
+	flags.BoolVar(&o.disableRateLimiter, "disable-client-rate-limiter", false, "Send the requests without client-side rate limiter, for clusters where API Priority and Fairness paces the clients. Cannot be used with --qps or --burst")
+	flags.StringSliceVarP(&o.namespaces, "namespace", "n", nil, "The namespace to export, defaults to the namespace of the current context. Can be repeated or comma-separated to export several namespaces")
+	flags.BoolVarP(&o.allNamespaces, "all-namespaces", "A", false, "Export every namespace of the cluster, each one under resources/<namespace>. System namespaces are skipped")
+	flags.BoolVar(&o.includeSystemNs, "include-system-namespaces", false, "Do not skip kube-system, kube-public, kube-node-lease and openshift-* namespaces with --all-namespaces")
+}
+
+// ContextConfig returns the client configuration of a kubeconfig context, the current one when empty
+func ContextConfig(configFlags *genericclioptions.ConfigFlags, contextName string) (*rest.Config, error) {
+	rawConfig, err := configFlags.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return nil, err
+	}
+	return clientcmd.NewDefaultClientConfig(rawConfig, &clientcmd.ConfigOverrides{CurrentContext: contextName}).ClientConfig()
+}
+
+// validateContext checks that the --context given is defined in the kubeconfig, listing the
+// available ones otherwise
+func validateContext(rawConfig api.Config, contextName string) error {
+	if contextName == "" {
+		return nil
+	}
+	if _, ok := rawConfig.Contexts[contextName]; ok {
+		return nil
+	}
+	contexts := make([]string, 0, len(rawConfig.Contexts))
+	for name := range rawConfig.Contexts {
+		contexts = append(contexts, name)
+	}
+	sort.Strings(contexts)
+	return fmt.Errorf("context %q not found in the kubeconfig, available contexts: %s", contextName, strings.Join(contexts, ", "))
+}
+
+// defaultNamespace returns the namespace of the kubeconfig context, or of the pod when running in
+// a cluster without kubeconfig
+func (o *ExportOptions) defaultNamespace() (string, error) {
+	if len(o.rawConfig.Contexts) == 0 {
+		namespace, _, err := o.configFlags.ToRawKubeConfigLoader().Namespace()
+		return namespace, err
+	}
+	contextName := *o.configFlags.Context
+	if contextName == "" {
+		contextName = o.rawConfig.CurrentContext
+	}
+	if context, ok := o.rawConfig.Contexts[contextName]; ok && context.Namespace != "" {
+		return context.Namespace, nil
+	}
+	return "", fmt.Errorf("no --namespace given and the context %q has no namespace, use --namespace or --all-namespaces", contextName)
+}
+
+// UniqueNamespaces drops empty and repeated namespaces, preserving their order
+func UniqueNamespaces(namespaces []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, namespace := range namespaces {
+		namespace = strings.TrimSpace(namespace)
+		if namespace == "" || seen[namespace] {
+			continue
+		}
+		seen[namespace] = true
+		unique = append(unique, namespace)
+	}
+	return unique
+}
+
+// exportReportFiles are the files written by export at the root of an export directory, next to
+// the manifests under resources
+var exportReportFiles = []string{SummaryJSONFile, summaryTextFile, ImagesFile, HelmReleasesFile, graphFile, workloadsFile, ImageRewritesFile, namespaceWarningsFile, clusterInfoFile, routeHintsFile, progressFile, index.File}
+
+// exportManagedPaths are the entries of an export directory written by export, the only ones
+// removed when a previous export is overwritten
+var exportManagedPaths = append([]string{"resources", "failures", progressDir}, exportReportFiles...)
+
+// The files written at the root of an export directory by the other commands, about the export
+const (
+	ValidateResultsFile   = "validate-results.json"
+	PVCMigrateResultsFile = "pvc-migrate-results.json"
+	ImageCopyResultsFile  = "image-copy-results.json"
+	QuiesceStateFile      = "quiesce-state.json"
+	ReportMarkdownFile    = "report.md"
+	ReportHTMLFile        = "report.html"
+)
+
+// otherCommandsResultPaths are the entries of an export directory written by the other commands
+// about the export, like the results of replay and validate. They are not removed when the export is
+// overwritten, they are the user's, but they describe the previous export.
+var otherCommandsResultPaths = []string{ApplyResultsFile, ValidateResultsFile, PVCMigrateResultsFile, ImageCopyResultsFile, QuiesceStateFile, ReportMarkdownFile, ReportHTMLFile}
+
+// ReportFiles are the files written at the root of an export directory by export and the other
+// commands. They are not resource manifests, the commands reading the manifests skip them.
+var ReportFiles = slices.Concat(exportReportFiles, otherCommandsResultPaths)
+
+// prepareExportDir refuses to mix a new export with a previous one left in exportDir, unless
+// overwrite is set in which case the previous export is removed first
+func prepareExportDir(exportDir string, overwrite bool, log logrus.FieldLogger) error {
+	entries, err := os.ReadDir(filepath.Join(exportDir, "resources"))
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return err
+	case len(entries) == 0:
+		return nil
+	}
+
+	if !overwrite {
+		if _, err := os.Stat(filepath.Join(exportDir, progressFile)); err == nil {
+			return fmt.Errorf("%s contains an export that did not finish, use --resume to resume it or --overwrite to replace it", exportDir)
+		}
+		return fmt.Errorf("%s already contains an export, use --overwrite to replace it", exportDir)
+	}
+	for _, p := range exportManagedPaths {
+		path := filepath.Join(exportDir, p)
+		log.Infof("Removing previous export content %s", path)
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	for _, p := range otherCommandsResultPaths {
+		path := filepath.Join(exportDir, p)
+		if _, err := os.Stat(path); err == nil {
+			log.Warnf("Keeping %s, it describes the previous export and not the new one", path)
+		}
+	}
+	return nil
+}
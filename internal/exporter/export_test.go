@@ -0,0 +1,319 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func Test_uniqueNamespaces(t *testing.T) {
+	tests := []struct {
+		name       string
+		namespaces []string
+		want       []string
+	}{
+		{
+			name:       "given no namespaces, should return an empty list",
+			namespaces: nil,
+			want:       []string{},
+		},
+		{
+			name:       "given repeated namespaces, should keep the first occurrence in order",
+			namespaces: []string{"foo", "bar", "foo", "baz"},
+			want:       []string{"foo", "bar", "baz"},
+		},
+		{
+			name:       "given empty values, should drop them",
+			namespaces: []string{"", " foo ", ""},
+			want:       []string{"foo"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UniqueNamespaces(tt.namespaces); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("uniqueNamespaces() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_validateContext(t *testing.T) {
+	rawConfig := api.Config{Contexts: map[string]*api.Context{
+		"source-prod": {Cluster: "prod"},
+		"target":      {Cluster: "target"},
+	}}
+	tests := []struct {
+		name        string
+		context     string
+		errContains string
+	}{
+		{
+			name: "given no context, should use the current context",
+		},
+		{
+			name:    "given a known context, should succeed",
+			context: "source-prod",
+		},
+		{
+			name:        "given an unknown context, should list the available ones",
+			context:     "source-prd",
+			errContains: "available contexts: source-prod, target",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateContext(rawConfig, tt.context)
+			if tt.errContains == "" {
+				if err != nil {
+					t.Errorf("validateContext() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("validateContext() error = %v, should contain %q", err, tt.errContains)
+			}
+		})
+	}
+}
+
+func Test_restConfig_impersonation(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	err := os.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: source
+  cluster:
+    server: https://source.example.com:6443
+contexts:
+- name: source
+  context:
+    cluster: source
+    user: admin
+current-context: source
+users:
+- name: admin
+  user:
+    token: secret
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configFlags := genericclioptions.NewConfigFlags(false)
+	flags := pflag.NewFlagSet("export", pflag.ContinueOnError)
+	configFlags.AddFlags(flags)
+	err = flags.Parse([]string{
+		"--kubeconfig", kubeconfig,
+		"--as", "system:serviceaccount:foo:restricted",
+		"--as-group", "developers",
+		"--as-group", "auditors",
+		"--as-uid", "1234",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := &ExportOptions{configFlags: configFlags, extras: map[string][]string{"scopes": {"view"}}}
+
+	restConfig, err := o.restConfig(testLogger())
+	if err != nil {
+		t.Fatalf("restConfig() error = %v", err)
+	}
+	want := rest.ImpersonationConfig{
+		UserName: "system:serviceaccount:foo:restricted",
+		UID:      "1234",
+		Groups:   []string{"developers", "auditors"},
+		Extra:    map[string][]string{"scopes": {"view"}},
+	}
+	if !reflect.DeepEqual(restConfig.Impersonate, want) {
+		t.Errorf("restConfig() impersonation = %+v, want %+v", restConfig.Impersonate, want)
+	}
+}
+
+func TestExportOptions_Complete_kubectlFlags(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	err := os.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: source
+  cluster:
+    server: https://source.example.com:6443
+contexts:
+- name: source
+  context:
+    cluster: source
+    namespace: shop
+    user: admin
+- name: no-namespace
+  context:
+    cluster: source
+    user: admin
+current-context: source
+users:
+- name: admin
+  user:
+    token: secret
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name           string
+		args           []string
+		wantNamespaces []string
+		wantHost       string
+		wantToken      string
+		wantInsecure   bool
+		wantTimeout    time.Duration
+		wantErr        bool
+	}{
+		{
+			name:           "given no namespace, should default to the namespace of the current context",
+			wantNamespaces: []string{"shop"},
+			wantHost:       "https://source.example.com:6443",
+			wantToken:      "secret",
+		},
+		{
+			name:           "given an empty namespace, should default to the namespace of the current context",
+			args:           []string{"--namespace="},
+			wantNamespaces: []string{"shop"},
+			wantHost:       "https://source.example.com:6443",
+			wantToken:      "secret",
+		},
+		{
+			name:           "given an empty namespace and all namespaces, should not default the namespace",
+			args:           []string{"--namespace=", "--all-namespaces"},
+			wantNamespaces: []string{},
+			wantHost:       "https://source.example.com:6443",
+			wantToken:      "secret",
+		},
+		{
+			name:    "given no namespace and a context without namespace, should fail",
+			args:    []string{"--context", "no-namespace"},
+			wantErr: true,
+		},
+		{
+			name:           "given the kubectl flags, should use them like kubectl",
+			args:           []string{"-n", "cart", "--server", "https://other.example.com:6443", "--token", "other", "--insecure-skip-tls-verify", "--request-timeout", "30s"},
+			wantNamespaces: []string{"cart"},
+			wantHost:       "https://other.example.com:6443",
+			wantToken:      "other",
+			wantInsecure:   true,
+			wantTimeout:    30 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &ExportOptions{configFlags: genericclioptions.NewConfigFlags(true), globalFlags: &flags.GlobalFlags{}}
+			cmd := &cobra.Command{}
+			o.addFlags(cmd.Flags())
+			o.configFlags.Namespace = nil
+			o.configFlags.AddFlags(cmd.Flags())
+			if err := cmd.Flags().Parse(append([]string{"--kubeconfig", kubeconfig}, tt.args...)); err != nil {
+				t.Fatal(err)
+			}
+			err := o.Complete(cmd, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Complete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(o.namespaces, tt.wantNamespaces) {
+				t.Errorf("Complete() namespaces = %v, want %v", o.namespaces, tt.wantNamespaces)
+			}
+			restConfig, err := o.restConfig(testLogger())
+			if err != nil {
+				t.Fatalf("restConfig() error = %v", err)
+			}
+			if restConfig.Host != tt.wantHost || restConfig.BearerToken != tt.wantToken || restConfig.Insecure != tt.wantInsecure || restConfig.Timeout != tt.wantTimeout {
+				t.Errorf("restConfig() = host %s, token %s, insecure %v, timeout %s, want %s, %s, %v, %s", restConfig.Host, restConfig.BearerToken, restConfig.Insecure, restConfig.Timeout, tt.wantHost, tt.wantToken, tt.wantInsecure, tt.wantTimeout)
+			}
+		})
+	}
+}
+
+func Test_prepareExportDir(t *testing.T) {
+	writeFile := func(t *testing.T, path string) {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("stale"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+
+	t.Run("given an empty directory, should succeed", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := prepareExportDir(dir, false, testLogger()); err != nil {
+			t.Errorf("prepareExportDir() error = %v", err)
+		}
+	})
+
+	t.Run("given a previous export, should fail without overwrite", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "resources", "foo", "stale.yaml"))
+		if err := prepareExportDir(dir, false, testLogger()); err == nil {
+			t.Errorf("prepareExportDir() should fail on a non-empty resources directory")
+		}
+		if !exists(filepath.Join(dir, "resources", "foo", "stale.yaml")) {
+			t.Errorf("prepareExportDir() should not remove anything without overwrite")
+		}
+	})
+
+	t.Run("given a previous export, should only remove the export content with overwrite", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "resources", "foo", "stale.yaml"))
+		writeFile(t, filepath.Join(dir, "failures", "foo", "pods.yaml"))
+		writeFile(t, filepath.Join(dir, "notes.txt"))
+		writeFile(t, filepath.Join(dir, ApplyResultsFile))
+		writeFile(t, filepath.Join(dir, ReportMarkdownFile))
+		if err := prepareExportDir(dir, true, testLogger()); err != nil {
+			t.Fatalf("prepareExportDir() error = %v", err)
+		}
+		if exists(filepath.Join(dir, "resources")) || exists(filepath.Join(dir, "failures")) {
+			t.Errorf("prepareExportDir() should remove the previous resources and failures")
+		}
+		if !exists(filepath.Join(dir, "notes.txt")) {
+			t.Errorf("prepareExportDir() should not remove files it did not write")
+		}
+		if !exists(filepath.Join(dir, ApplyResultsFile)) || !exists(filepath.Join(dir, ReportMarkdownFile)) {
+			t.Errorf("prepareExportDir() should not remove the results of the other commands")
+		}
+	})
+}
+
+func TestExportOptions_clusterDir(t *testing.T) {
+	tests := []struct {
+		name         string
+		flattenPaths bool
+		elem         []string
+		want         string
+	}{
+		{name: "given no element, should return the _cluster directory", want: "export/resources/foo/_cluster"},
+		{name: "given a resource, should return its directory under _cluster", elem: []string{"crds"}, want: "export/resources/foo/_cluster/crds"},
+		{name: "given --flatten-paths, should return the namespace directory", flattenPaths: true, elem: []string{"crds"}, want: "export/resources/foo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &ExportOptions{exportDir: "export", flattenPaths: tt.flattenPaths}
+			if got := o.clusterDir("foo", tt.elem...); got != filepath.FromSlash(tt.want) {
+				t.Errorf("clusterDir() = %q, want %q", got, filepath.FromSlash(tt.want))
+			}
+		})
+	}
+}
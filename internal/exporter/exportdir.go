@@ -0,0 +1,270 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/file"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/s3"
+	"github.com/sirupsen/logrus"
+	errorsutil "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Tokens of a templated --export-dir, e.g. /archive/{context}/{namespace}/{date}. A literal brace
+// is written doubled, {{ or }}.
+const (
+	tokenNamespace = "namespace"
+	tokenContext   = "context"
+	tokenDate      = "date"
+	tokenTime      = "time"
+)
+
+// expandPath replaces the tokens of a templated path with their value. The values are sanitized
+// like file names so that a context such as arn:aws:eks:...:cluster/prod stays a single path
+// element. Only the tokens present in values are known, any other one is an error.
+func expandPath(template string, values map[string]string) (string, error) {
+	return walkPath(template, func(token string) (string, error) {
+		value, ok := values[token]
+		if !ok {
+			return "", fmt.Errorf("unknown token {%s} in %q, must be one of {%s}, {%s}, {%s}, {%s}", token, template, tokenNamespace, tokenContext, tokenDate, tokenTime)
+		}
+		return safeFileName(value, ""), nil
+	})
+}
+
+// hasToken reports whether the templated path uses the token, an escaped {{token}} does not count
+func hasToken(template string, token string) bool {
+	found := false
+	walkPath(template, func(t string) (string, error) {
+		found = found || t == token
+		return "", nil
+	})
+	return found
+}
+
+// walkPath copies the templated path replacing each token with the value returned by expand
+func walkPath(template string, expand func(token string) (string, error)) (string, error) {
+	b := &strings.Builder{}
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		switch {
+		case (c == '{' || c == '}') && i+1 < len(template) && template[i+1] == c:
+			b.WriteByte(c)
+			i++
+		case c == '{':
+			end := strings.IndexByte(template[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unclosed { in %q, a literal brace is written {{", template)
+			}
+			value, err := expand(template[i+1 : i+end])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i += end
+		case c == '}':
+			return "", fmt.Errorf("unopened } in %q, a literal brace is written }}", template)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+// pathValues returns the values of the tokens of a templated path, {date} and {time} are the
+// start of the export in UTC
+func pathValues(namespace string, contextName string, start time.Time) map[string]string {
+	start = start.UTC()
+	return map[string]string{
+		tokenNamespace: namespace,
+		tokenContext:   contextName,
+		tokenDate:      start.Format("2006-01-02"),
+		tokenTime:      start.Format("150405"),
+	}
+}
+
+// validatePathTemplates checks the tokens of --export-dir, --archive-file and --metrics-file. An
+// archive shared by the namespaces exported to their own directory would be overwritten by each of
+// them.
+func (o *ExportOptions) validatePathTemplates() error {
+	values := pathValues("", "", time.Time{})
+	if _, err := expandPath(o.exportDir, values); err != nil {
+		return fmt.Errorf("invalid --export-dir: %w", err)
+	}
+	if _, err := expandPath(o.metricsFile, values); err != nil {
+		return fmt.Errorf("invalid --metrics-file: %w", err)
+	}
+	if !o.archive {
+		return nil
+	}
+	if _, err := expandPath(o.archiveFile, values); err != nil {
+		return fmt.Errorf("invalid --archive-file: %w", err)
+	}
+	if hasToken(o.exportDir, tokenNamespace) && !hasToken(o.archiveFile, tokenNamespace) && (o.allNamespaces || len(o.namespaces) > 1) {
+		return fmt.Errorf("--archive-file must contain {%s} when --export-dir does", tokenNamespace)
+	}
+	return nil
+}
+
+// contextName returns the kubeconfig context of the export, for the {context} token
+func (o *ExportOptions) contextName() string {
+	if *o.configFlags.Context != "" {
+		return *o.configFlags.Context
+	}
+	return o.rawConfig.CurrentContext
+}
+
+// runTemplated expands the templated --export-dir and runs the export. With {namespace} each
+// namespace is exported on its own to its directory, with its own reports, one after the other.
+func (o *ExportOptions) runTemplated(ctx context.Context, log logrus.FieldLogger) error {
+	start := time.Now()
+	exportDir, archiveFile, metricsFile := o.exportDir, o.archiveFile, o.metricsFile
+	defer func() { o.exportDir, o.archiveFile, o.metricsFile = exportDir, archiveFile, metricsFile }()
+
+	if !hasToken(exportDir, tokenNamespace) {
+		if err := o.expandPaths(exportDir, archiveFile, metricsFile, pathValues("", o.contextName(), start), log); err != nil {
+			return err
+		}
+		return o.runOnce(ctx, log)
+	}
+
+	namespaces, allNamespaces := o.namespaces, o.allNamespaces
+	defer func() { o.namespaces, o.allNamespaces = namespaces, allNamespaces }()
+	exported := namespaces
+	var client kubernetes.Interface
+	if o.allNamespaces {
+		restConfig, err := o.restConfig(log)
+		if err != nil {
+			log.Errorf("cannot create rest config: %#v", err)
+			return err
+		}
+		client, err = kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			log.Errorf("cannot create kubernetes client: %#v", err)
+			return err
+		}
+		exported, err = listNamespaces(client, o.includeSystemNs, log)
+		if err != nil {
+			log.Errorf("cannot list namespaces: %#v", err)
+			return err
+		}
+		log.Infof("Exporting %d namespaces: %s", len(exported), strings.Join(exported, ", "))
+	}
+	// each run exports a single namespace, the deleted ones are skipped here
+	o.allNamespaces = false
+
+	errs := []error{}
+	runs := 0
+	for _, namespace := range exported {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			log.Warnf("export interrupted, skipping namespace %s", namespace)
+			errs = append(errs, &InterruptedError{})
+			continue
+		}
+		if limit := o.budget.exceededLimit(); limit != "" {
+			log.Warnf("%s reached, skipping namespace %s", limit, namespace)
+			continue
+		}
+		if client != nil && ctx.Err() == nil && !namespaceExists(client, namespace) {
+			log.Warnf("namespace %s was deleted during the export, skipping", namespace)
+			continue
+		}
+		o.namespaces = []string{namespace}
+		if err := o.expandPaths(exportDir, archiveFile, metricsFile, pathValues(namespace, o.contextName(), start), log); err != nil {
+			return err
+		}
+		runs++
+		if err := o.runOnce(ctx, log); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return combineRunErrors(errs, runs, o.ignoreFailures)
+}
+
+// expandPaths sets the export directory, the archive file and the metrics file of a run, the
+// export directory or archive written is printed and recorded in the summary
+func (o *ExportOptions) expandPaths(exportDir string, archiveFile string, metricsFile string, values map[string]string, log logrus.FieldLogger) error {
+	var err error
+	if o.exportDir, err = expandPath(exportDir, values); err != nil {
+		return err
+	}
+	if o.metricsFile, err = expandPath(metricsFile, values); err != nil {
+		return err
+	}
+	o.resolvedDir = o.exportDir
+	if o.archive {
+		if o.archiveFile, err = expandPath(archiveFile, values); err != nil {
+			return err
+		}
+		o.resolvedDir = o.archiveFile
+	}
+	if o.longPaths && !s3.IsURL(o.exportDir) {
+		if o.exportDir, err = file.LongPath(o.exportDir); err != nil {
+			return err
+		}
+	}
+	log.Infof("Exporting to %s", o.resolvedDir)
+	return nil
+}
+
+// combineRunErrors returns the error of the namespaces exported to their own directory with the
+// exit code a single run would have: an interruption, the budget or a timeout first, then the
+// deprecated API versions and dangling references the run was asked to fail on, a failure of all of
+// them, otherwise the failures of some of them unless they are ignored
+func combineRunErrors(errs []error, runs int, ignoreFailures bool) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	for _, err := range errs {
+		var interrupted *InterruptedError
+		if errors.As(err, &interrupted) {
+			return err
+		}
+	}
+	for _, err := range errs {
+		var budget *BudgetExceededError
+		if errors.As(err, &budget) {
+			return err
+		}
+	}
+	for _, err := range errs {
+		var timeout *TimeoutError
+		if errors.As(err, &timeout) {
+			return err
+		}
+	}
+	for _, err := range errs {
+		var deprecated *DeprecatedAPIError
+		if errors.As(err, &deprecated) {
+			return err
+		}
+	}
+	for _, err := range errs {
+		var dangling *DanglingReferencesError
+		if errors.As(err, &dangling) {
+			return err
+		}
+	}
+	failures := 0
+	partial := false
+	for _, err := range errs {
+		var partialErr *PartialFailureError
+		if errors.As(err, &partialErr) {
+			failures += partialErr.Failures
+			partial = true
+			continue
+		}
+		failures++
+	}
+	if !partial && len(errs) == runs {
+		return errorsutil.NewAggregate(errs)
+	}
+	if ignoreFailures {
+		return nil
+	}
+	return &PartialFailureError{Failures: failures}
+}
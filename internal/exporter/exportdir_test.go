@@ -0,0 +1,209 @@
+package exporter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_expandPath(t *testing.T) {
+	values := pathValues("payments", "arn:aws:eks:eu-west-1:123:cluster/prod", time.Date(2024, 3, 9, 14, 5, 7, 0, time.UTC))
+	tests := []struct {
+		name        string
+		template    string
+		want        string
+		errContains string
+	}{
+		{
+			name:     "given no tokens, should keep the path",
+			template: "/archive/export",
+			want:     "/archive/export",
+		},
+		{
+			name:     "given tokens, should replace them with their value",
+			template: "/archive/{date}/{namespace}-{time}",
+			want:     "/archive/2024-03-09/payments-140507",
+		},
+		{
+			name:     "given a context with separators, should keep it a single path element",
+			template: "/archive/{context}",
+			want:     "/archive/" + safeFileName("arn:aws:eks:eu-west-1:123:cluster/prod", ""),
+		},
+		{
+			name:     "given escaped braces, should write them literally",
+			template: "/archive/{{namespace}}/}}{namespace}{{",
+			want:     "/archive/{namespace}/}payments{",
+		},
+		{
+			name:        "given an unknown token, should fail naming it",
+			template:    "/archive/{cluster}",
+			errContains: "unknown token {cluster}",
+		},
+		{
+			name:        "given an unclosed brace, should fail",
+			template:    "/archive/{namespace",
+			errContains: "unclosed {",
+		},
+		{
+			name:        "given a lone closing brace, should fail",
+			template:    "/archive/namespace}",
+			errContains: "unopened }",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandPath(tt.template, values)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("expandPath() error = %v, want it to contain %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expandPath() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expandPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_hasToken(t *testing.T) {
+	if !hasToken("/archive/{context}/{namespace}", tokenNamespace) {
+		t.Errorf("hasToken() = false, want true for {namespace}")
+	}
+	if hasToken("/archive/{{namespace}}/{date}", tokenNamespace) {
+		t.Errorf("hasToken() = true, want false for an escaped {{namespace}}")
+	}
+}
+
+func Test_validatePathTemplates(t *testing.T) {
+	tests := []struct {
+		name        string
+		o           *ExportOptions
+		errContains string
+	}{
+		{
+			name: "given a templated export directory, should pass",
+			o:    &ExportOptions{exportDir: "/archive/{context}/{namespace}", namespaces: []string{"a", "b"}},
+		},
+		{
+			name:        "given an unknown token, should fail",
+			o:           &ExportOptions{exportDir: "/archive/{cluster}"},
+			errContains: "invalid --export-dir",
+		},
+		{
+			name:        "given an archive shared by the namespaces exported to their own directory, should fail",
+			o:           &ExportOptions{exportDir: "/archive/{namespace}", archive: true, archiveFile: "/archive/all.tar.gz", namespaces: []string{"a", "b"}},
+			errContains: "--archive-file must contain {namespace}",
+		},
+		{
+			name: "given an archive of a single namespace, should pass",
+			o:    &ExportOptions{exportDir: "/archive/{namespace}", archive: true, archiveFile: "/archive/all.tar.gz", namespaces: []string{"a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.o.validatePathTemplates()
+			if tt.errContains == "" {
+				if err != nil {
+					t.Errorf("validatePathTemplates() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("validatePathTemplates() error = %v, want it to contain %q", err, tt.errContains)
+			}
+		})
+	}
+}
+
+func Test_combineRunErrors(t *testing.T) {
+	failed := errors.New("cannot create dynamic client")
+	tests := []struct {
+		name           string
+		errs           []error
+		runs           int
+		ignoreFailures bool
+		want           error
+	}{
+		{
+			name: "given no errors, should succeed",
+			runs: 2,
+		},
+		{
+			name: "given an interruption, should report it first",
+			errs: []error{&PartialFailureError{Failures: 1}, &TimeoutError{}, &InterruptedError{}},
+			runs: 3,
+			want: &InterruptedError{},
+		},
+		{
+			name: "given an exceeded budget, should report it before the timeouts",
+			errs: []error{&TimeoutError{}, &BudgetExceededError{Limit: "--max-resources 10"}},
+			runs: 2,
+			want: &BudgetExceededError{},
+		},
+		{
+			name: "given a timeout, should report it before the failures",
+			errs: []error{&PartialFailureError{Failures: 1}, &TimeoutError{}},
+			runs: 2,
+			want: &TimeoutError{},
+		},
+		{
+			name: "given deprecated API versions, should report them before the failures",
+			errs: []error{&PartialFailureError{Failures: 1}, &DeprecatedAPIError{Objects: 2}},
+			runs: 2,
+			want: &DeprecatedAPIError{},
+		},
+		{
+			name: "given dangling references, should report them before the failures",
+			errs: []error{&PartialFailureError{Failures: 1}, &DanglingReferencesError{References: 2}},
+			runs: 2,
+			want: &DanglingReferencesError{},
+		},
+		{
+			name: "given some failed namespaces, should report a partial failure",
+			errs: []error{&PartialFailureError{Failures: 2}, failed},
+			runs: 3,
+			want: &PartialFailureError{Failures: 3},
+		},
+		{
+			name:           "given ignored failures, should succeed",
+			errs:           []error{failed},
+			runs:           2,
+			ignoreFailures: true,
+		},
+		{
+			name: "given all the namespaces failed, should fail",
+			errs: []error{failed, failed},
+			runs: 2,
+			want: failed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := combineRunErrors(tt.errs, tt.runs, tt.ignoreFailures)
+			switch want := tt.want.(type) {
+			case nil:
+				if err != nil {
+					t.Errorf("combineRunErrors() = %v, want nil", err)
+				}
+			case *PartialFailureError:
+				var partial *PartialFailureError
+				if !errors.As(err, &partial) || partial.Failures != want.Failures {
+					t.Errorf("combineRunErrors() = %v, want %v", err, want)
+				}
+			case *InterruptedError, *TimeoutError, *DeprecatedAPIError, *DanglingReferencesError, *BudgetExceededError:
+				if err != tt.errs[len(tt.errs)-1] {
+					t.Errorf("combineRunErrors() = %v, want %v", err, want)
+				}
+			default:
+				if !errors.Is(err, want) {
+					t.Errorf("combineRunErrors() = %v, want %v", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+// Package exportertest writes export directories for the tests of the commands reading them.
+package exportertest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// Namespace is the namespace the objects are exported from
+const Namespace = "foo"
+
+// WriteExport writes the objects and their Namespace to exportDir with the layout, flat, kind or
+// single, as export does, and the index of the files. The files are gzipped with compress. It
+// returns the index entries.
+func WriteExport(t *testing.T, exportDir string, layout string, compress bool, objects ...unstructured.Unstructured) []index.Entry {
+	t.Helper()
+	namespace := unstructured.Unstructured{}
+	namespace.SetAPIVersion("v1")
+	namespace.SetKind("Namespace")
+	namespace.SetName(Namespace)
+
+	entries := []index.Entry{}
+	write := func(name string, data []byte, obj *unstructured.Unstructured) {
+		if compress {
+			data = gzipBytes(t, data)
+			name += ".gz"
+		}
+		path := filepath.Join(exportDir, "resources", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		entry := index.Entry{Path: "resources/" + name, Size: int64(len(data)), SHA256: index.Sum(data)}
+		if obj != nil {
+			entry.APIVersion, entry.Kind, entry.Namespace, entry.Name = obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName()
+		}
+		entries = append(entries, entry)
+	}
+
+	if layout == "single" {
+		data := []byte{}
+		for _, obj := range append([]unstructured.Unstructured{namespace}, objects...) {
+			data = append(append(data, "---\n"...), marshal(t, obj)...)
+		}
+		write(Namespace+".yaml", data, nil)
+	} else {
+		for i := range objects {
+			write(path.Join(Namespace, fileName(layout, objects[i])), marshal(t, objects[i]), &objects[i])
+		}
+		write(path.Join(Namespace, "namespace.yaml"), marshal(t, namespace), &namespace)
+	}
+	if err := index.Write(exportDir, entries); err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+// fileName returns the file of the object in the namespace directory, the resource is guessed
+// from the kind
+func fileName(layout string, obj unstructured.Unstructured) string {
+	gvk := obj.GroupVersionKind()
+	resource := schema.GroupResource{Group: gvk.Group, Resource: strings.ToLower(gvk.Kind) + "s"}
+	if layout == "kind" {
+		group := gvk.Group
+		if group == "" {
+			group = "core"
+		}
+		return group + "_" + resource.Resource + "/" + obj.GetName() + ".yaml"
+	}
+	return resource.String() + "_" + obj.GetName() + ".yaml"
+}
+
+// WriteSummary writes the summary of the export written by WriteExport, with the flags it was run
+// with
+func WriteSummary(t *testing.T, exportDir string, flags map[string]string) {
+	t.Helper()
+	data, err := json.Marshal(map[string]interface{}{
+		"flags":      flags,
+		"resources":  map[string]int{},
+		"namespaces": []interface{}{map[string]interface{}{"namespace": Namespace, "resources": map[string]int{}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(exportDir, exporter.SummaryJSONFile), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func marshal(t *testing.T, obj unstructured.Unstructured) []byte {
+	t.Helper()
+	data, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,192 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sdiscovery "k8s.io/client-go/discovery"
+)
+
+const FailuresFile = "failures.json"
+
+// Failure categories let scripts tell apart the failures worth retrying from the ones that need
+// more permissions or a fix in the exported objects
+const (
+	failurePermission    = "permission"
+	failureThrottling    = "throttling"
+	failureSerialization = "serialization"
+	failureUnsupported   = "unsupported"
+	failureNotFound      = "not-found"
+	failureInterrupted   = "interrupted"
+	failureTimedOut      = "timed-out"
+	failureIO            = "io"
+	failureTooLarge      = "too-large"
+	failureRoundTrip     = "round-trip"
+	failureOther         = "other"
+)
+
+// PartialFailureError is returned when an export completed but some resources or objects could
+// not be exported, the details are in failures/<namespace>/failures.json
+type PartialFailureError struct {
+	Failures int
+}
+
+func (e *PartialFailureError) Error() string {
+	return fmt.Sprintf("export completed with %d failures, see failures/<namespace>/%s", e.Failures, FailuresFile)
+}
+
+// TimeoutError is returned when the export did not complete within --timeout, the resources
+// that were not listed in time are recorded as timed out in failures/<namespace>/failures.json
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("export timed out after %s, see failures/<namespace>/%s", e.Timeout, FailuresFile)
+}
+
+// FailureRecord is one failed list or write in failures/<namespace>/failures.json
+type FailureRecord struct {
+	Operation  string `json:"operation"`
+	Group      string `json:"group"`
+	Version    string `json:"version"`
+	Resource   string `json:"resource"`
+	Name       string `json:"name,omitempty"`
+	Error      string `json:"error"`
+	StatusCode int32  `json:"statusCode,omitempty"`
+	Category   string `json:"category"`
+}
+
+// objectWriteError is an error writing one exported object
+type objectWriteError struct {
+	resource *groupResource
+	name     string
+	category string
+	err      error
+}
+
+func (e *objectWriteError) Error() string {
+	return fmt.Sprintf("error writing %s %s: %v", GroupResourceName(e.resource.APIGroup, e.resource.APIResource.Name), e.name, e.err)
+}
+
+func (e *objectWriteError) Unwrap() error {
+	return e.err
+}
+
+func listFailureRecord(e *groupResourceError) FailureRecord {
+	code, category := classifyError(e.Error)
+	return FailureRecord{
+		Operation:  "list",
+		Group:      e.APIResource.Group,
+		Version:    e.APIResource.Version,
+		Resource:   e.APIResource.Name,
+		Error:      e.Error.Error(),
+		StatusCode: code,
+		Category:   category,
+	}
+}
+
+// CheckDiscovery discovers the API groups of the server and returns the groups that could not be
+// discovered, like the aggregated API of a metrics-server that is down. The export goes on with
+// the other groups, unless strict is set.
+func CheckDiscovery(client serverGroupsAndResources, strict bool, log logrus.FieldLogger) ([]FailureRecord, error) {
+	_, _, err := client.ServerGroupsAndResources()
+	var groupErr *k8sdiscovery.ErrGroupDiscoveryFailed
+	if err == nil || !errors.As(err, &groupErr) {
+		return nil, err
+	}
+	if strict {
+		return nil, fmt.Errorf("%w, remove --strict-discovery to export the other groups", err)
+	}
+
+	groupVersions := make([]schema.GroupVersion, 0, len(groupErr.Groups))
+	for gv := range groupErr.Groups {
+		groupVersions = append(groupVersions, gv)
+	}
+	sort.Slice(groupVersions, func(i, j int) bool { return groupVersions[i].String() < groupVersions[j].String() })
+
+	records := []FailureRecord{}
+	for _, gv := range groupVersions {
+		gvErr := groupErr.Groups[gv]
+		log.Warnf("cannot discover %s, its resources are not exported: %v", gv, gvErr)
+		code, category := classifyError(gvErr)
+		records = append(records, FailureRecord{
+			Operation:  "discover",
+			Group:      gv.Group,
+			Version:    gv.Version,
+			Error:      gvErr.Error(),
+			StatusCode: code,
+			Category:   category,
+		})
+	}
+	return records, nil
+}
+
+type serverGroupsAndResources interface {
+	ServerGroupsAndResources() ([]*metav1.APIGroup, []*metav1.APIResourceList, error)
+}
+
+func writeFailureRecord(err error) FailureRecord {
+	code, category := classifyError(err)
+	record := FailureRecord{
+		Operation:  "write",
+		Error:      err.Error(),
+		StatusCode: code,
+		Category:   category,
+	}
+	var writeErr *objectWriteError
+	if errors.As(err, &writeErr) {
+		record.Group = writeErr.resource.APIGroup
+		record.Version = writeErr.resource.APIVersion
+		record.Resource = writeErr.resource.APIResource.Name
+		record.Name = writeErr.name
+		record.Category = writeErr.category
+	}
+	return record
+}
+
+// classifyError returns the HTTP status code of an API error, if any, and the failure category
+func classifyError(err error) (int32, string) {
+	var code int32
+	var status apierrors.APIStatus
+	if errors.As(err, &status) {
+		code = status.Status().Code
+	}
+	switch {
+	case apierrors.IsForbidden(err), apierrors.IsUnauthorized(err):
+		return code, failurePermission
+	case apierrors.IsTooManyRequests(err), apierrors.IsServerTimeout(err), apierrors.IsTimeout(err), apierrors.IsServiceUnavailable(err):
+		return code, failureThrottling
+	case apierrors.IsNotFound(err):
+		return code, failureNotFound
+	case apierrors.IsMethodNotSupported(err), apierrors.IsBadRequest(err):
+		return code, failureUnsupported
+	case errors.Is(err, context.DeadlineExceeded):
+		return code, failureTimedOut
+	case errors.Is(err, context.Canceled):
+		return code, failureInterrupted
+	}
+	return code, failureOther
+}
+
+// writeFailureRecords writes failures.json in failuresDir, an empty list when nothing failed
+func writeFailureRecords(failuresDir string, records []FailureRecord) error {
+	if records == nil {
+		records = []FailureRecord{}
+	}
+	recordBytes, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(failuresDir, FailuresFile), append(recordBytes, '\n'), 0600)
+}
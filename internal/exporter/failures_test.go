@@ -0,0 +1,184 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sdiscovery "k8s.io/client-go/discovery"
+)
+
+func Test_classifyError(t *testing.T) {
+	gr := schema.GroupResource{Group: "apps", Resource: "deployments"}
+	tests := []struct {
+		name         string
+		err          error
+		wantCode     int32
+		wantCategory string
+	}{
+		{
+			name:         "forbidden is a permission failure",
+			err:          apierrors.NewForbidden(gr, "", errors.New("denied")),
+			wantCode:     403,
+			wantCategory: failurePermission,
+		},
+		{
+			name:         "too many requests is a throttling failure",
+			err:          apierrors.NewTooManyRequests("slow down", 1),
+			wantCode:     429,
+			wantCategory: failureThrottling,
+		},
+		{
+			name:         "service unavailable is a throttling failure",
+			err:          apierrors.NewServiceUnavailable("busy"),
+			wantCode:     503,
+			wantCategory: failureThrottling,
+		},
+		{
+			name:         "wrapped not found keeps its status code",
+			err:          fmt.Errorf("listing: %w", apierrors.NewNotFound(gr, "")),
+			wantCode:     404,
+			wantCategory: failureNotFound,
+		},
+		{
+			name:         "cancelled context is an interruption",
+			err:          context.Canceled,
+			wantCategory: failureInterrupted,
+		},
+		{
+			name:         "other errors have no status code",
+			err:          errors.New("boom"),
+			wantCategory: failureOther,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, category := classifyError(tt.err)
+			if code != tt.wantCode || category != tt.wantCategory {
+				t.Errorf("classifyError() = %d, %s, want %d, %s", code, category, tt.wantCode, tt.wantCategory)
+			}
+		})
+	}
+}
+
+func Test_writeFailureRecords(t *testing.T) {
+	listErr := &groupResourceError{
+		APIResource: metav1.APIResource{Name: "secrets", Version: "v1"},
+		Error:       apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "", errors.New("denied")),
+	}
+	writeErr := &objectWriteError{
+		resource: &groupResource{APIGroup: "apps", APIVersion: "v1", APIResource: metav1.APIResource{Name: "deployments"}},
+		name:     "web",
+		category: failureSerialization,
+		err:      errors.New("unsupported value"),
+	}
+
+	dir := t.TempDir()
+	records := []FailureRecord{listFailureRecord(listErr), writeFailureRecord(writeErr)}
+	if err := writeFailureRecords(dir, records); err != nil {
+		t.Fatalf("writeFailureRecords() error = %v", err)
+	}
+
+	recordBytes, err := os.ReadFile(filepath.Join(dir, FailuresFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []FailureRecord{}
+	if err := json.Unmarshal(recordBytes, &got); err != nil {
+		t.Fatalf("%s does not parse: %v", FailuresFile, err)
+	}
+	want := []FailureRecord{
+		{Operation: "list", Version: "v1", Resource: "secrets", Error: listErr.Error.Error(), StatusCode: 403, Category: failurePermission},
+		{Operation: "write", Group: "apps", Version: "v1", Resource: "deployments", Name: "web", Error: writeErr.Error(), Category: failureSerialization},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d records, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("record %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	t.Run("clean export writes an empty list", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := writeFailureRecords(dir, nil); err != nil {
+			t.Fatalf("writeFailureRecords() error = %v", err)
+		}
+		recordBytes, err := os.ReadFile(filepath.Join(dir, FailuresFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(recordBytes) != "[]\n" {
+			t.Errorf("%s = %q, want an empty list", FailuresFile, recordBytes)
+		}
+	})
+}
+
+type fakeDiscovery struct {
+	err error
+}
+
+func (d *fakeDiscovery) ServerGroupsAndResources() ([]*metav1.APIGroup, []*metav1.APIResourceList, error) {
+	return []*metav1.APIGroup{}, []*metav1.APIResourceList{}, d.err
+}
+
+func Test_checkDiscovery(t *testing.T) {
+	metricsDown := &k8sdiscovery.ErrGroupDiscoveryFailed{Groups: map[schema.GroupVersion]error{
+		{Group: "metrics.k8s.io", Version: "v1beta1"}:   apierrors.NewServiceUnavailable("the server is currently unable to handle the request"),
+		{Group: "custom.metrics.k8s.io", Version: "v1"}: errors.New("connection refused"),
+	}}
+	tests := []struct {
+		name        string
+		err         error
+		strict      bool
+		wantRecords []FailureRecord
+		wantErr     bool
+	}{
+		{
+			name: "complete discovery has no failures",
+		},
+		{
+			name: "unreachable groups are recorded",
+			err:  metricsDown,
+			wantRecords: []FailureRecord{
+				{Operation: "discover", Group: "custom.metrics.k8s.io", Version: "v1", Error: "connection refused", Category: failureOther},
+				{Operation: "discover", Group: "metrics.k8s.io", Version: "v1beta1", Error: "the server is currently unable to handle the request", StatusCode: 503, Category: failureThrottling},
+			},
+		},
+		{
+			name:    "unreachable groups fail with --strict-discovery",
+			err:     metricsDown,
+			strict:  true,
+			wantErr: true,
+		},
+		{
+			name:    "other discovery errors fail",
+			err:     errors.New("connection refused"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CheckDiscovery(&fakeDiscovery{err: tt.err}, tt.strict, testLogger())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkDiscovery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.wantRecords) {
+				t.Fatalf("checkDiscovery() = %+v, want %+v", got, tt.wantRecords)
+			}
+			for i := range got {
+				if got[i] != tt.wantRecords[i] {
+					t.Errorf("checkDiscovery()[%d] = %+v, want %+v", i, got[i], tt.wantRecords[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package exporter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// resourceMatcher matches an API resource by its plural name and, optionally, its group.
+// It is parsed from the resource.group notation used by kubectl, e.g. deployments.apps.
+// A "*" resource matches every resource of the group, e.g. *.events.k8s.io
+type resourceMatcher struct {
+	Resource string
+	Group    string
+	HasGroup bool
+}
+
+func parseResourceMatchers(values []string) ([]resourceMatcher, error) {
+	matchers := []resourceMatcher{}
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+		m := resourceMatcher{Resource: value}
+		if i := strings.Index(value, "."); i >= 0 {
+			m.Resource = value[:i]
+			m.Group = value[i+1:]
+			m.HasGroup = true
+		}
+		if m.Resource == "" {
+			return nil, fmt.Errorf("invalid resource %q, expected resource or resource.group", value)
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+func (m resourceMatcher) String() string {
+	if !m.HasGroup {
+		return m.Resource
+	}
+	return m.Resource + "." + m.Group
+}
+
+func (m resourceMatcher) matches(group string, resource metav1.APIResource) bool {
+	if m.HasGroup && m.Group != group {
+		return false
+	}
+	return m.Resource == "*" || m.Resource == resource.Name || (resource.SingularName != "" && m.Resource == resource.SingularName)
+}
+
+// ResourceFilter restricts the GVRs visited during export. A resource is admitted when it is
+// included (or no includes are given) and it is not excluded; exclusion wins on conflict.
+type ResourceFilter struct {
+	include []resourceMatcher
+	exclude []resourceMatcher
+}
+
+// NewResourceFilter parses the values of --include-resources and --exclude-resources
+func NewResourceFilter(includeResources, excludeResources []string) (*ResourceFilter, error) {
+	includes, err := parseResourceMatchers(includeResources)
+	if err != nil {
+		return nil, err
+	}
+	excludes, err := parseResourceMatchers(excludeResources)
+	if err != nil {
+		return nil, err
+	}
+	return &ResourceFilter{include: includes, exclude: excludes}, nil
+}
+
+func (f *ResourceFilter) admits(group string, resource metav1.APIResource) bool {
+	return f.included(group, resource) && !f.excluded(group, resource)
+}
+
+func (f *ResourceFilter) included(group string, resource metav1.APIResource) bool {
+	if len(f.include) == 0 {
+		return true
+	}
+	return anyMatches(f.include, group, resource)
+}
+
+func (f *ResourceFilter) excluded(group string, resource metav1.APIResource) bool {
+	return anyMatches(f.exclude, group, resource)
+}
+
+func anyMatches(matchers []resourceMatcher, group string, resource metav1.APIResource) bool {
+	for _, m := range matchers {
+		if m.matches(group, resource) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludedResources returns the served resources removed by the exclude list, warning about
+// the ones that were explicitly included as well
+func (f *ResourceFilter) excludedResources(lists []*metav1.APIResourceList, log logrus.FieldLogger) []string {
+	excluded := map[string]bool{}
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			if strings.Contains(resource.Name, "/") || !f.excluded(gv.Group, resource) {
+				continue
+			}
+			name := GroupResourceName(gv.Group, resource.Name)
+			if len(f.include) > 0 && f.included(gv.Group, resource) && !excluded[name] {
+				log.Warnf("resource %s is both included and excluded, excluding it", name)
+			}
+			excluded[name] = true
+		}
+	}
+	names := make([]string, 0, len(excluded))
+	for name := range excluded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks that every included resource is served by the cluster, so that a typo
+// fails fast with a list of candidates instead of silently producing an empty export
+func (f *ResourceFilter) Validate(lists []*metav1.APIResourceList) error {
+	available := map[string]bool{}
+	for _, m := range f.include {
+		found := false
+		for _, list := range lists {
+			gv, err := schema.ParseGroupVersion(list.GroupVersion)
+			if err != nil {
+				continue
+			}
+			for _, resource := range list.APIResources {
+				if strings.Contains(resource.Name, "/") {
+					continue
+				}
+				available[GroupResourceName(gv.Group, resource.Name)] = true
+				if m.matches(gv.Group, resource) {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return fmt.Errorf("resource %q is not served by the cluster, valid candidates: %s", m.String(), strings.Join(candidates(m, available), ", "))
+		}
+	}
+	return nil
+}
+
+// candidates returns the available resources closest to the unmatched one, or all of them
+// when nothing is reasonably close
+func candidates(m resourceMatcher, available map[string]bool) []string {
+	all := []string{}
+	close := []string{}
+	for name := range available {
+		all = append(all, name)
+		resource := strings.SplitN(name, ".", 2)[0]
+		if levenshtein(resource, m.Resource) <= 2 {
+			close = append(close, name)
+		}
+	}
+	if len(close) > 0 {
+		all = close
+	}
+	sort.Strings(all)
+	return all
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// GroupResourceName returns the resource.group notation for a resource, dropping the
+// trailing dot for the core group
+func GroupResourceName(group, resource string) string {
+	if group == "" {
+		return resource
+	}
+	return resource + "." + group
+}
@@ -0,0 +1,204 @@
+package exporter
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testAPIResourceLists() []*metav1.APIResourceList {
+	return []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "configmaps", SingularName: "configmap", Namespaced: true, Kind: "ConfigMap"},
+				{Name: "secrets", SingularName: "secret", Namespaced: true, Kind: "Secret"},
+				{Name: "events", SingularName: "event", Namespaced: true, Kind: "Event"},
+			},
+		},
+		{
+			GroupVersion: "apps/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "deployments", SingularName: "deployment", Namespaced: true, Kind: "Deployment"},
+				{Name: "deployments/scale", Namespaced: true, Kind: "Scale"},
+			},
+		},
+		{
+			GroupVersion: "events.k8s.io/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "events", SingularName: "event", Namespaced: true, Kind: "Event"},
+			},
+		},
+	}
+}
+
+func Test_resourceFilter_admits(t *testing.T) {
+	tests := []struct {
+		name     string
+		include  []string
+		group    string
+		resource metav1.APIResource
+		want     bool
+	}{
+		{
+			name:     "empty filter admits everything",
+			group:    "apps",
+			resource: metav1.APIResource{Name: "deployments"},
+			want:     true,
+		},
+		{
+			name:     "resource.group matches",
+			include:  []string{"deployments.apps"},
+			group:    "apps",
+			resource: metav1.APIResource{Name: "deployments"},
+			want:     true,
+		},
+		{
+			name:     "resource.group does not match another group",
+			include:  []string{"deployments.apps"},
+			group:    "extensions",
+			resource: metav1.APIResource{Name: "deployments"},
+			want:     false,
+		},
+		{
+			name:     "bare resource matches the core group",
+			include:  []string{"configmaps"},
+			group:    "",
+			resource: metav1.APIResource{Name: "configmaps"},
+			want:     true,
+		},
+		{
+			name:     "singular name matches",
+			include:  []string{"Secret"},
+			group:    "",
+			resource: metav1.APIResource{Name: "secrets", SingularName: "secret"},
+			want:     true,
+		},
+		{
+			name:     "resource not in the list is rejected",
+			include:  []string{"configmaps", "deployments.apps"},
+			group:    "",
+			resource: metav1.APIResource{Name: "secrets"},
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			include, err := parseResourceMatchers(tt.include)
+			if err != nil {
+				t.Fatalf("parseResourceMatchers() error = %v", err)
+			}
+			f := &ResourceFilter{include: include}
+			if got := f.admits(tt.group, tt.resource); got != tt.want {
+				t.Errorf("admits() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_resourceFilter_validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		include     []string
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name:    "known resources are valid",
+			include: []string{"deployments.apps", "configmaps"},
+		},
+		{
+			name:        "typo lists close candidates",
+			include:     []string{"deploymnets"},
+			wantErr:     true,
+			errContains: "deployments.apps",
+		},
+		{
+			name:        "unknown group is rejected",
+			include:     []string{"deployments.extensions"},
+			wantErr:     true,
+			errContains: "deployments.extensions",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			include, err := parseResourceMatchers(tt.include)
+			if err != nil {
+				t.Fatalf("parseResourceMatchers() error = %v", err)
+			}
+			f := &ResourceFilter{include: include}
+			err = f.Validate(testAPIResourceLists())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("validate() error = %v, should contain %q", err, tt.errContains)
+			}
+		})
+	}
+}
+
+func Test_resourceFilter_exclude(t *testing.T) {
+	tests := []struct {
+		name         string
+		include      []string
+		exclude      []string
+		wantAdmitted []string
+		wantExcluded []string
+	}{
+		{
+			name:         "group wildcard excludes every resource of the group",
+			exclude:      []string{"*.events.k8s.io"},
+			wantAdmitted: []string{"configmaps", "secrets", "events", "deployments.apps"},
+			wantExcluded: []string{"events.events.k8s.io"},
+		},
+		{
+			name:         "bare resource excludes it in every group",
+			exclude:      []string{"events"},
+			wantAdmitted: []string{"configmaps", "secrets", "deployments.apps"},
+			wantExcluded: []string{"events", "events.events.k8s.io"},
+		},
+		{
+			name:         "exclude wins over include",
+			include:      []string{"configmaps", "secrets"},
+			exclude:      []string{"secrets"},
+			wantAdmitted: []string{"configmaps"},
+			wantExcluded: []string{"secrets"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			include, err := parseResourceMatchers(tt.include)
+			if err != nil {
+				t.Fatalf("parseResourceMatchers() error = %v", err)
+			}
+			exclude, err := parseResourceMatchers(tt.exclude)
+			if err != nil {
+				t.Fatalf("parseResourceMatchers() error = %v", err)
+			}
+			f := &ResourceFilter{include: include, exclude: exclude}
+
+			admitted := []string{}
+			for _, list := range testAPIResourceLists() {
+				group := strings.Split(list.GroupVersion, "/")[0]
+				if !strings.Contains(list.GroupVersion, "/") {
+					group = ""
+				}
+				for _, r := range list.APIResources {
+					if !strings.Contains(r.Name, "/") && f.admits(group, r) {
+						admitted = append(admitted, GroupResourceName(group, r.Name))
+					}
+				}
+			}
+			if strings.Join(admitted, ",") != strings.Join(tt.wantAdmitted, ",") {
+				t.Errorf("admitted = %v, want %v", admitted, tt.wantAdmitted)
+			}
+
+			excluded := f.excludedResources(testAPIResourceLists(), testLogger())
+			if strings.Join(excluded, ",") != strings.Join(tt.wantExcluded, ",") {
+				t.Errorf("excludedResources() = %v, want %v", excluded, tt.wantExcluded)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/file"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const graphFile = "graph.json"
+
+// graphNode is an object of the dependency graph, the cluster-scoped ones have no namespace
+type graphNode struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+func (n graphNode) String() string {
+	return n.Kind + "/" + n.Name
+}
+
+// location is the node with its namespace, for the references across namespaces
+func (n graphNode) location() string {
+	if n.Namespace == "" {
+		return n.String() + " (cluster-scoped)"
+	}
+	return n.String() + " in namespace " + n.Namespace
+}
+
+// GraphEdge is a reference of an exported object to another object through one of its fields,
+// Found is set when the referenced object is exported too
+type GraphEdge struct {
+	From  graphNode `json:"from"`
+	To    graphNode `json:"to"`
+	Field string    `json:"field"`
+	Found bool      `json:"found"`
+}
+
+func (e GraphEdge) String() string {
+	return fmt.Sprintf("%s -> %s (%s)", e.From, e.To, e.Field)
+}
+
+// objectReference is a field of the objects of some kinds naming another object. A "*" in the
+// path matches every item of a list.
+type objectReference struct {
+	// kinds are the kinds of the referencing objects
+	kinds []string
+	// podSpec makes path relative to the pod spec of the workload kinds
+	podSpec bool
+	path    []string
+	// kind is the kind of the referenced objects. When empty the path leads to references with
+	// their own kind, name and optional namespace fields, like the roleRef of a RoleBinding.
+	kind string
+	// clusterScoped is set when the referenced objects are cluster-scoped
+	clusterScoped bool
+}
+
+// objectReferences are the references recorded in the dependency graph
+var objectReferences = []objectReference{
+	{podSpec: true, path: []string{"volumes", "*", "configMap", "name"}, kind: "ConfigMap"},
+	{podSpec: true, path: []string{"volumes", "*", "secret", "secretName"}, kind: "Secret"},
+	{podSpec: true, path: []string{"volumes", "*", "persistentVolumeClaim", "claimName"}, kind: "PersistentVolumeClaim"},
+	{podSpec: true, path: []string{"volumes", "*", "projected", "sources", "*", "configMap", "name"}, kind: "ConfigMap"},
+	{podSpec: true, path: []string{"volumes", "*", "projected", "sources", "*", "secret", "name"}, kind: "Secret"},
+	{podSpec: true, path: []string{"containers", "*", "envFrom", "*", "configMapRef", "name"}, kind: "ConfigMap"},
+	{podSpec: true, path: []string{"containers", "*", "envFrom", "*", "secretRef", "name"}, kind: "Secret"},
+	{podSpec: true, path: []string{"containers", "*", "env", "*", "valueFrom", "configMapKeyRef", "name"}, kind: "ConfigMap"},
+	{podSpec: true, path: []string{"containers", "*", "env", "*", "valueFrom", "secretKeyRef", "name"}, kind: "Secret"},
+	{podSpec: true, path: []string{"initContainers", "*", "envFrom", "*", "configMapRef", "name"}, kind: "ConfigMap"},
+	{podSpec: true, path: []string{"initContainers", "*", "envFrom", "*", "secretRef", "name"}, kind: "Secret"},
+	{podSpec: true, path: []string{"initContainers", "*", "env", "*", "valueFrom", "configMapKeyRef", "name"}, kind: "ConfigMap"},
+	{podSpec: true, path: []string{"initContainers", "*", "env", "*", "valueFrom", "secretKeyRef", "name"}, kind: "Secret"},
+	{podSpec: true, path: []string{"imagePullSecrets", "*", "name"}, kind: "Secret"},
+	{podSpec: true, path: []string{"serviceAccountName"}, kind: "ServiceAccount"},
+	{kinds: []string{"Ingress"}, path: []string{"spec", "defaultBackend", "service", "name"}, kind: "Service"},
+	{kinds: []string{"Ingress"}, path: []string{"spec", "rules", "*", "http", "paths", "*", "backend", "service", "name"}, kind: "Service"},
+	{kinds: []string{"Ingress"}, path: []string{"spec", "tls", "*", "secretName"}, kind: "Secret"},
+	{kinds: []string{"HorizontalPodAutoscaler"}, path: []string{"spec", "scaleTargetRef"}},
+	{kinds: []string{"RoleBinding"}, path: []string{"roleRef"}},
+	{kinds: []string{"RoleBinding"}, path: []string{"subjects", "*"}},
+	{kinds: []string{"NetworkPolicy"}, path: []string{"spec", "ingress", "*", "from", "*", "namespaceSelector", "matchLabels", namespaceNameLabel}, kind: "Namespace", clusterScoped: true},
+	{kinds: []string{"NetworkPolicy"}, path: []string{"spec", "egress", "*", "to", "*", "namespaceSelector", "matchLabels", namespaceNameLabel}, kind: "Namespace", clusterScoped: true},
+}
+
+// namespaceNameLabel is set by the API server on every namespace to its name, the network policies
+// select a namespace by name with it
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// clusterScopedKinds are the kinds referenced by kind and name that are cluster-scoped
+var clusterScopedKinds = map[string]bool{"ClusterRole": true, "Namespace": true}
+
+// nonObjectKinds are the kinds of the RoleBinding subjects that are not API objects
+var nonObjectKinds = map[string]bool{"User": true, "Group": true}
+
+// targets returns the objects referenced by the object through the reference field, and the field
+func (r objectReference) targets(obj unstructured.Unstructured) ([]graphNode, string) {
+	path := r.path
+	if r.podSpec {
+		specPath, ok := podSpecPaths[obj.GetKind()]
+		if !ok {
+			return nil, ""
+		}
+		path = append(append([]string{}, specPath...), r.path...)
+	} else if !containsString(r.kinds, obj.GetKind()) {
+		return nil, ""
+	}
+	field := strings.Join(path, ".")
+
+	targets := []graphNode{}
+	if r.kind != "" {
+		for _, name := range nestedStrings(obj.Object, path) {
+			targets = append(targets, referencedNode(obj, r.kind, name, r.clusterScoped))
+		}
+		return targets, field
+	}
+	for _, ref := range nestedMaps(obj.Object, path) {
+		kind, _ := ref["kind"].(string)
+		name, _ := ref["name"].(string)
+		if kind == "" || name == "" || nonObjectKinds[kind] {
+			continue
+		}
+		node := referencedNode(obj, kind, name, clusterScopedKinds[kind])
+		if namespace, _ := ref["namespace"].(string); namespace != "" && node.Namespace != "" {
+			node.Namespace = namespace
+		}
+		targets = append(targets, node)
+	}
+	return targets, field
+}
+
+// nestedMaps returns the maps at the path of obj, like nestedStrings
+func nestedMaps(obj interface{}, path []string) []map[string]interface{} {
+	if len(path) == 0 {
+		if m, ok := obj.(map[string]interface{}); ok {
+			return []map[string]interface{}{m}
+		}
+		return nil
+	}
+	if path[0] == "*" {
+		items, _ := obj.([]interface{})
+		maps := []map[string]interface{}{}
+		for _, item := range items {
+			maps = append(maps, nestedMaps(item, path[1:])...)
+		}
+		return maps
+	}
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return nestedMaps(m[path[0]], path[1:])
+}
+
+func referencedNode(obj unstructured.Unstructured, kind string, name string, clusterScoped bool) graphNode {
+	node := graphNode{Kind: kind, Name: name}
+	if !clusterScoped {
+		node.Namespace = obj.GetNamespace()
+	}
+	return node
+}
+
+// recreatedByCluster reports whether a referenced object is created by the cluster in every
+// namespace, it is left out of the export without being dangling
+func recreatedByCluster(node graphNode) bool {
+	return (node.Kind == "ServiceAccount" && node.Name == "default") || (node.Kind == "ConfigMap" && node.Name == "kube-root-ca.crt")
+}
+
+// dependencyGraph records the references between the exported objects, written to graph.json
+type dependencyGraph struct {
+	nodes map[graphNode]bool
+	edges []GraphEdge
+}
+
+func newDependencyGraph() *dependencyGraph {
+	return &dependencyGraph{nodes: map[graphNode]bool{}}
+}
+
+// addObjects records the exported objects, without their references
+func (g *dependencyGraph) addObjects(resources []*groupResource) {
+	if g == nil {
+		return
+	}
+	for _, r := range resources {
+		if r == nil || r.objects == nil {
+			continue
+		}
+		for _, obj := range r.objects.Items {
+			g.nodes[graphNode{Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName()}] = true
+		}
+	}
+}
+
+// add records the objects exported with a namespace and their references. It returns the
+// references to objects of the namespace that are not exported and will not be recreated by the
+// cluster, the objects of a namespace being all exported with it.
+func (g *dependencyGraph) add(resources []*groupResource) []GraphEdge {
+	if g == nil {
+		return nil
+	}
+	g.addObjects(resources)
+	workloads := []unstructured.Unstructured{}
+	for _, r := range resources {
+		if r.objects == nil {
+			continue
+		}
+		for _, obj := range r.objects.Items {
+			if isTopLevelWorkload(obj) {
+				workloads = append(workloads, obj)
+			}
+		}
+	}
+	dangling := []GraphEdge{}
+	for _, r := range resources {
+		if r.objects == nil {
+			continue
+		}
+		for _, obj := range r.objects.Items {
+			from := graphNode{Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName()}
+			// the PodDisruptionBudgets select the pods of their workloads
+			if obj.GetKind() == "PodDisruptionBudget" {
+				for _, workload := range workloads {
+					if selectsPods(obj, workload) {
+						g.edges = append(g.edges, GraphEdge{From: from, To: objectNode(workload), Field: "spec.selector", Found: true})
+					}
+				}
+			}
+			for _, ref := range objectReferences {
+				targets, field := ref.targets(obj)
+				for _, to := range targets {
+					edge := GraphEdge{From: from, To: to, Field: field, Found: g.nodes[to]}
+					g.edges = append(g.edges, edge)
+					if !edge.Found && to.Namespace == from.Namespace && !recreatedByCluster(to) {
+						dangling = append(dangling, edge)
+					}
+				}
+			}
+		}
+	}
+	return dangling
+}
+
+// external returns the references to objects of other namespaces or cluster-scoped that are not
+// exported, once every namespace is exported. The target cluster must already have them.
+func (g *dependencyGraph) external() []GraphEdge {
+	if g == nil {
+		return nil
+	}
+	external := []GraphEdge{}
+	for _, edge := range g.resolved() {
+		if !edge.Found && edge.To.Namespace != edge.From.Namespace && !recreatedByCluster(edge.To) {
+			external = append(external, edge)
+		}
+	}
+	return external
+}
+
+// write writes graph.json once every namespace is exported, the references to cluster-scoped
+// objects being found in any of them. It is written atomically, flushed to disk with durable.
+func (g *dependencyGraph) write(exportDir string, durable bool) error {
+	graphBytes, err := json.MarshalIndent(map[string]interface{}{"edges": g.resolved()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return file.WriteAtomic(file.OS, filepath.Join(exportDir, graphFile), append(graphBytes, '\n'), durable)
+}
+
+// resolved returns the sorted edges, found among the objects of every namespace exported
+func (g *dependencyGraph) resolved() []GraphEdge {
+	edges := make([]GraphEdge, 0, len(g.edges))
+	for _, edge := range g.edges {
+		edge.Found = g.nodes[edge.To]
+		edges = append(edges, edge)
+	}
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].From.Namespace != edges[j].From.Namespace {
+			return edges[i].From.Namespace < edges[j].From.Namespace
+		}
+		return edges[i].String() < edges[j].String()
+	})
+	return edges
+}
+
+// DanglingReferencesError is returned with --fail-on-dangling-refs when exported objects reference
+// objects that are not exported, the export itself is complete
+type DanglingReferencesError struct {
+	References int
+}
+
+func (e *DanglingReferencesError) Error() string {
+	return fmt.Sprintf("%d references to objects not exported, see %s", e.References, summaryTextFile)
+}
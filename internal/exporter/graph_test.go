@@ -0,0 +1,201 @@
+package exporter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_objectReferences(t *testing.T) {
+	withField := func(obj unstructured.Unstructured, value interface{}, path ...string) unstructured.Unstructured {
+		if err := unstructured.SetNestedField(obj.Object, value, path...); err != nil {
+			t.Fatal(err)
+		}
+		return obj
+	}
+	node := func(kind string, name string) graphNode {
+		return graphNode{Kind: kind, Namespace: "foo", Name: name}
+	}
+	tests := []struct {
+		name string
+		obj  unstructured.Unstructured
+		want []graphNode
+	}{
+		{
+			name: "given a deployment with envFrom and volumes, should reference the configmaps and secrets",
+			obj: withField(withField(testOwnedObject("Deployment", "web"),
+				[]interface{}{map[string]interface{}{
+					"name": "web",
+					"envFrom": []interface{}{
+						map[string]interface{}{"configMapRef": map[string]interface{}{"name": "web-config"}},
+						map[string]interface{}{"secretRef": map[string]interface{}{"name": "web-env"}},
+					},
+				}}, "spec", "template", "spec", "containers"),
+				[]interface{}{
+					map[string]interface{}{"name": "certs", "secret": map[string]interface{}{"secretName": "web-certs"}},
+					map[string]interface{}{"name": "data", "persistentVolumeClaim": map[string]interface{}{"claimName": "web-data"}},
+				}, "spec", "template", "spec", "volumes"),
+			want: []graphNode{node("Secret", "web-certs"), node("PersistentVolumeClaim", "web-data"), node("ConfigMap", "web-config"), node("Secret", "web-env")},
+		},
+		{
+			name: "given a pod with a service account, should reference the service account",
+			obj:  withField(testOwnedObject("Pod", "debug"), "debugger", "spec", "serviceAccountName"),
+			want: []graphNode{node("ServiceAccount", "debugger")},
+		},
+		{
+			name: "given an ingress, should reference the backend services and the tls secrets",
+			obj: withField(withField(testOwnedObject("Ingress", "web"),
+				[]interface{}{map[string]interface{}{"http": map[string]interface{}{"paths": []interface{}{
+					map[string]interface{}{"path": "/", "backend": map[string]interface{}{"service": map[string]interface{}{"name": "web"}}},
+				}}}}, "spec", "rules"),
+				[]interface{}{map[string]interface{}{"secretName": "web-tls"}}, "spec", "tls"),
+			want: []graphNode{node("Service", "web"), node("Secret", "web-tls")},
+		},
+		{
+			name: "given an hpa, should reference its scale target",
+			obj:  withField(testOwnedObject("HorizontalPodAutoscaler", "web"), map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "name": "web"}, "spec", "scaleTargetRef"),
+			want: []graphNode{node("Deployment", "web")},
+		},
+		{
+			name: "given a rolebinding to a clusterrole, should reference the cluster-scoped role",
+			obj:  withField(testOwnedObject("RoleBinding", "view"), map[string]interface{}{"apiGroup": "rbac.authorization.k8s.io", "kind": "ClusterRole", "name": "view"}, "roleRef"),
+			want: []graphNode{{Kind: "ClusterRole", Name: "view"}},
+		},
+		{
+			name: "given a rolebinding to a service account of another namespace, should reference it there",
+			obj: withField(testOwnedObject("RoleBinding", "reader"), []interface{}{
+				map[string]interface{}{"kind": "ServiceAccount", "name": "operator", "namespace": "external-secrets"},
+				map[string]interface{}{"apiGroup": "rbac.authorization.k8s.io", "kind": "User", "name": "jane"},
+			}, "subjects"),
+			want: []graphNode{{Kind: "ServiceAccount", Namespace: "external-secrets", Name: "operator"}},
+		},
+		{
+			name: "given a networkpolicy selecting a namespace by name, should reference the namespace",
+			obj: withField(testOwnedObject("NetworkPolicy", "allow-monitoring"),
+				[]interface{}{map[string]interface{}{"from": []interface{}{
+					map[string]interface{}{"namespaceSelector": map[string]interface{}{"matchLabels": map[string]interface{}{namespaceNameLabel: "monitoring"}}},
+				}}}, "spec", "ingress"),
+			want: []graphNode{{Kind: "Namespace", Name: "monitoring"}},
+		},
+		{
+			name: "given a configmap, should not reference anything",
+			obj:  withField(testOwnedObject("ConfigMap", "web-config"), "web", "data", "serviceAccountName"),
+			want: []graphNode{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []graphNode{}
+			for _, ref := range objectReferences {
+				targets, _ := ref.targets(tt.obj)
+				got = append(got, targets...)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("targets = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_dependencyGraph(t *testing.T) {
+	pod := testOwnedObject("Pod", "web")
+	if err := unstructured.SetNestedSlice(pod.Object, []interface{}{
+		map[string]interface{}{"name": "config", "configMap": map[string]interface{}{"name": "web-config"}},
+		map[string]interface{}{"name": "certs", "secret": map[string]interface{}{"secretName": "missing"}},
+		map[string]interface{}{"name": "ca", "configMap": map[string]interface{}{"name": "kube-root-ca.crt"}},
+	}, "spec", "volumes"); err != nil {
+		t.Fatal(err)
+	}
+	binding := testOwnedObject("RoleBinding", "view")
+	if err := unstructured.SetNestedMap(binding.Object, map[string]interface{}{"kind": "ClusterRole", "name": "view"}, "roleRef"); err != nil {
+		t.Fatal(err)
+	}
+	resources := []*groupResource{{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+		pod, binding, testOwnedObject("ConfigMap", "web-config"),
+	}}}}
+
+	g := newDependencyGraph()
+	dangling := g.add(resources)
+	wantDangling := []GraphEdge{{
+		From:  graphNode{Kind: "Pod", Namespace: "foo", Name: "web"},
+		To:    graphNode{Kind: "Secret", Namespace: "foo", Name: "missing"},
+		Field: "spec.volumes.*.secret.secretName",
+	}}
+	if !reflect.DeepEqual(dangling, wantDangling) {
+		t.Errorf("add() = %+v, want %+v", dangling, wantDangling)
+	}
+
+	// the cluster-scoped objects are found once collected
+	clusterRole := testOwnedObject("ClusterRole", "view")
+	clusterRole.SetNamespace("")
+	g.addObjects([]*groupResource{nil, {objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{clusterRole}}}})
+
+	dir := t.TempDir()
+	if err := g.write(dir, false); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	graphBytes, err := os.ReadFile(filepath.Join(dir, graphFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := struct {
+		Edges []GraphEdge `json:"edges"`
+	}{}
+	if err := json.Unmarshal(graphBytes, &got); err != nil {
+		t.Fatalf("%s does not parse: %v", graphFile, err)
+	}
+	found := map[string]bool{}
+	for _, edge := range got.Edges {
+		found[edge.To.String()] = edge.Found
+	}
+	want := map[string]bool{
+		"ConfigMap/web-config":       true,
+		"Secret/missing":             false,
+		"ConfigMap/kube-root-ca.crt": false,
+		"ClusterRole/view":           true,
+	}
+	if !reflect.DeepEqual(found, want) {
+		t.Errorf("edges found = %+v, want %+v", found, want)
+	}
+}
+
+func Test_dependencyGraph_nil(t *testing.T) {
+	var g *dependencyGraph
+	g.addObjects([]*groupResource{{}})
+	if got := g.add([]*groupResource{{}}); got != nil {
+		t.Errorf("add() = %+v, want nil", got)
+	}
+}
+
+func Test_dependencyGraph_external(t *testing.T) {
+	binding := testOwnedObject("RoleBinding", "reader")
+	if err := unstructured.SetNestedField(binding.Object, []interface{}{
+		map[string]interface{}{"kind": "ServiceAccount", "name": "operator", "namespace": "external-secrets"},
+		map[string]interface{}{"kind": "ServiceAccount", "name": "exported", "namespace": "bar"},
+	}, "subjects"); err != nil {
+		t.Fatal(err)
+	}
+	if err := unstructured.SetNestedMap(binding.Object, map[string]interface{}{"kind": "ClusterRole", "name": "view"}, "roleRef"); err != nil {
+		t.Fatal(err)
+	}
+	g := newDependencyGraph()
+	if dangling := g.add([]*groupResource{{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{binding}}}}); len(dangling) != 0 {
+		t.Errorf("add() = %+v, want no dangling reference in the namespace", dangling)
+	}
+	sa := testOwnedObject("ServiceAccount", "exported")
+	sa.SetNamespace("bar")
+	g.add([]*groupResource{{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{sa}}}})
+
+	got := []string{}
+	for _, edge := range g.external() {
+		got = append(got, edge.To.location())
+	}
+	want := []string{"ClusterRole/view (cluster-scoped)", "ServiceAccount/operator in namespace external-secrets"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("external() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,194 @@
+package exporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	HelmReleasesFile = "helm-releases.json"
+
+	helmManagedByLabel          = "app.kubernetes.io/managed-by"
+	helmReleaseNameAnnotation   = "meta.helm.sh/release-name"
+	helmReleaseNsAnnotation     = "meta.helm.sh/release-namespace"
+	helmInstanceLabel           = "app.kubernetes.io/instance"
+	helmChartLabel              = "helm.sh/chart"
+	helmReleaseSecretType       = "helm.sh/release.v1"
+	helmReleaseSecretOwnerLabel = "owner"
+)
+
+// helmObject is a member object of a Helm release
+type helmObject struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// HelmRelease is an entry of helm-releases.json. The chart is read from the latest release
+// Secret when it was exported, or from the helm.sh/chart label of the members otherwise.
+type HelmRelease struct {
+	Name         string       `json:"name"`
+	Namespace    string       `json:"namespace"`
+	Chart        string       `json:"chart,omitempty"`
+	ChartVersion string       `json:"chartVersion,omitempty"`
+	AppVersion   string       `json:"appVersion,omitempty"`
+	Revision     int          `json:"revision,omitempty"`
+	Status       string       `json:"status,omitempty"`
+	Objects      []helmObject `json:"objects"`
+}
+
+// helmReport collects the Helm-managed objects of the export grouped by release, re-applying
+// them behind Helm's back makes the release drift on the target cluster
+type helmReport struct {
+	releases map[string]*HelmRelease
+	log      logrus.FieldLogger
+}
+
+func newHelmReport(log logrus.FieldLogger) *helmReport {
+	return &helmReport{releases: map[string]*HelmRelease{}, log: log}
+}
+
+// isHelmManaged reports whether the object was installed by Helm
+func isHelmManaged(obj unstructured.Unstructured) bool {
+	return obj.GetLabels()[helmManagedByLabel] == "Helm" || obj.GetAnnotations()[helmReleaseNameAnnotation] != ""
+}
+
+func (r *helmReport) release(name, namespace string) *HelmRelease {
+	key := namespace + "/" + name
+	release, ok := r.releases[key]
+	if !ok {
+		release = &HelmRelease{Name: name, Namespace: namespace, Objects: []helmObject{}}
+		r.releases[key] = release
+	}
+	return release
+}
+
+// add records the Helm-managed objects and the release Secrets among the resources
+func (r *helmReport) add(resources []*groupResource) {
+	for _, gr := range resources {
+		// the objects of the other versions are the same as the preferred ones
+		if gr.objects == nil || gr.nonPreferredVersion {
+			continue
+		}
+		for _, obj := range gr.objects.Items {
+			if isSecret(obj) && secretType(obj) == helmReleaseSecretType {
+				r.addReleaseSecret(obj)
+				continue
+			}
+			if !isHelmManaged(obj) {
+				continue
+			}
+			name := obj.GetAnnotations()[helmReleaseNameAnnotation]
+			if name == "" {
+				name = obj.GetLabels()[helmInstanceLabel]
+			}
+			namespace := obj.GetAnnotations()[helmReleaseNsAnnotation]
+			if namespace == "" {
+				namespace = obj.GetNamespace()
+			}
+			release := r.release(name, namespace)
+			release.Objects = append(release.Objects, helmObject{
+				APIVersion: obj.GetAPIVersion(),
+				Kind:       obj.GetKind(),
+				Namespace:  obj.GetNamespace(),
+				Name:       obj.GetName(),
+			})
+			if release.Chart == "" {
+				release.Chart = obj.GetLabels()[helmChartLabel]
+			}
+		}
+	}
+}
+
+// addReleaseSecret reads the chart of a release from its Secret, keeping the latest revision
+func (r *helmReport) addReleaseSecret(secret unstructured.Unstructured) {
+	labels := secret.GetLabels()
+	if labels[helmReleaseSecretOwnerLabel] != "helm" || labels["name"] == "" {
+		return
+	}
+	release := r.release(labels["name"], secret.GetNamespace())
+	revision, _ := strconv.Atoi(labels["version"])
+	if revision < release.Revision {
+		return
+	}
+	release.Revision = revision
+	release.Status = labels["status"]
+
+	data, err := decodeHelmRelease(secret)
+	if err != nil {
+		r.log.Debugf("cannot decode the Helm release Secret %s/%s: %v", secret.GetNamespace(), secret.GetName(), err)
+		return
+	}
+	release.Chart = data.Chart.Metadata.Name
+	release.ChartVersion = data.Chart.Metadata.Version
+	release.AppVersion = data.Chart.Metadata.AppVersion
+}
+
+type helmReleaseData struct {
+	Chart struct {
+		Metadata struct {
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			AppVersion string `json:"appVersion"`
+		} `json:"metadata"`
+	} `json:"chart"`
+}
+
+// decodeHelmRelease decodes the release stored by Helm in a Secret, a base64 encoded and
+// gzipped JSON document on top of the base64 encoding of the Secret data
+func decodeHelmRelease(secret unstructured.Unstructured) (*helmReleaseData, error) {
+	value, _, _ := unstructured.NestedString(secret.Object, "data", "release")
+	encoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	releaseBytes, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(releaseBytes, []byte{0x1f, 0x8b}) {
+		reader, err := gzip.NewReader(bytes.NewReader(releaseBytes))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		releaseBytes, err = io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+	}
+	data := &helmReleaseData{}
+	if err := json.Unmarshal(releaseBytes, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// list returns the releases with member objects, sorted by namespace and name
+func (r *helmReport) list() []*HelmRelease {
+	releases := []*HelmRelease{}
+	for _, key := range sortedKeys(r.releases) {
+		if len(r.releases[key].Objects) > 0 {
+			releases = append(releases, r.releases[key])
+		}
+	}
+	return releases
+}
+
+func (r *helmReport) write(exportDir string) error {
+	releaseBytes, err := json.MarshalIndent(r.list(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(exportDir, HelmReleasesFile), append(releaseBytes, '\n'), 0600)
+}
@@ -0,0 +1,117 @@
+package exporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func testHelmReleaseSecret(t *testing.T, release string, revision string, chart string, version string) unstructured.Unstructured {
+	releaseBytes, err := json.Marshal(map[string]interface{}{
+		"name": release,
+		"chart": map[string]interface{}{
+			"metadata": map[string]interface{}{"name": chart, "version": version, "appVersion": "1.0"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(releaseBytes); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	helmEncoded := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	secret := testSecret("sh.helm.release.v1."+release+".v"+revision, helmReleaseSecretType, map[string]interface{}{
+		"release": base64.StdEncoding.EncodeToString([]byte(helmEncoded)),
+	})
+	secret.SetLabels(map[string]string{"owner": "helm", "name": release, "version": revision, "status": "deployed"})
+	return secret
+}
+
+func Test_helmReport(t *testing.T) {
+	managed := func(kind string, name string, labels map[string]string, annotations map[string]string) unstructured.Unstructured {
+		obj := testOwnedObject(kind, name)
+		obj.SetLabels(labels)
+		obj.SetAnnotations(annotations)
+		return obj
+	}
+	objects := []unstructured.Unstructured{
+		managed("Deployment", "web", map[string]string{helmManagedByLabel: "Helm"}, map[string]string{helmReleaseNameAnnotation: "web", helmReleaseNsAnnotation: "foo"}),
+		managed("Service", "web", map[string]string{helmManagedByLabel: "Helm"}, map[string]string{helmReleaseNameAnnotation: "web"}),
+		managed("ConfigMap", "legacy", map[string]string{helmManagedByLabel: "Helm", helmInstanceLabel: "legacy", helmChartLabel: "legacy-0.1.0"}, nil),
+		managed("ConfigMap", "manual", nil, nil),
+		testHelmReleaseSecret(t, "web", "1", "web", "2.0.0"),
+		testHelmReleaseSecret(t, "web", "2", "web", "2.1.0"),
+	}
+
+	report := newHelmReport(testLogger())
+	report.add([]*groupResource{{objects: &unstructured.UnstructuredList{Items: objects}}})
+
+	dir := t.TempDir()
+	if err := report.write(dir); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	releaseBytes, err := os.ReadFile(filepath.Join(dir, HelmReleasesFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []HelmRelease{}
+	if err := json.Unmarshal(releaseBytes, &got); err != nil {
+		t.Fatalf("%s does not parse: %v", HelmReleasesFile, err)
+	}
+	want := []HelmRelease{
+		{
+			Name:      "legacy",
+			Namespace: "foo",
+			Chart:     "legacy-0.1.0",
+			Objects:   []helmObject{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "foo", Name: "legacy"}},
+		},
+		{
+			Name:         "web",
+			Namespace:    "foo",
+			Chart:        "web",
+			ChartVersion: "2.1.0",
+			AppVersion:   "1.0",
+			Revision:     2,
+			Status:       "deployed",
+			Objects: []helmObject{
+				{APIVersion: "v1", Kind: "Deployment", Namespace: "foo", Name: "web"},
+				{APIVersion: "v1", Kind: "Service", Namespace: "foo", Name: "web"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("releases = %+v, want %+v", got, want)
+	}
+
+	t.Run("skip-helm-managed drops the release members only", func(t *testing.T) {
+		items := make([]unstructured.Unstructured, len(objects))
+		for i := range objects {
+			items[i] = *objects[i].DeepCopy()
+		}
+		resources := []*groupResource{{objects: &unstructured.UnstructuredList{Items: items}}}
+		o := &ExportOptions{skipHelmManaged: true}
+		applyObjectFilters(resources, o.objectFilters(resources), newExportSummary("foo"), testLogger())
+
+		kept := []string{}
+		for _, obj := range resources[0].objects.Items {
+			kept = append(kept, obj.GetName())
+		}
+		want := []string{"manual", "sh.helm.release.v1.web.v1", "sh.helm.release.v1.web.v2"}
+		if !reflect.DeepEqual(kept, want) {
+			t.Errorf("kept %v, want %v", kept, want)
+		}
+	})
+}
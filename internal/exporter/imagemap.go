@@ -0,0 +1,169 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const ImageRewritesFile = "image-rewrites.json"
+
+// imageMapping is an --image-map, the images of the from registry, or of a repository path
+// under it, are pulled from the to one
+type imageMapping struct {
+	from string
+	to   string
+}
+
+// parseImageMap parses the --image-map values, e.g. quay.io=mirror.example.com:5000/quay. The
+// longest prefixes are matched first.
+func parseImageMap(values []string) ([]imageMapping, error) {
+	mappings := []imageMapping{}
+	for _, value := range values {
+		from, to, ok := strings.Cut(value, "=")
+		from, to = strings.TrimSuffix(strings.TrimSpace(from), "/"), strings.TrimSuffix(strings.TrimSpace(to), "/")
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid --image-map %q, must be old-registry=new-registry", value)
+		}
+		if strings.ContainsAny(from+to, "@ ") || strings.Contains(from+to, "://") {
+			return nil, fmt.Errorf("invalid --image-map %q, the registries must not have a scheme, a tag or a digest", value)
+		}
+		mappings = append(mappings, imageMapping{from: from, to: to})
+	}
+	sort.SliceStable(mappings, func(i, j int) bool { return len(mappings[i].from) > len(mappings[j].from) })
+	return mappings, nil
+}
+
+// SplitImage splits an image reference into its name and its tag and digest suffix, e.g.
+// quay.io/app and :v1@sha256:abcd
+func SplitImage(ref string) (string, string) {
+	name := ref
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	return name, ref[len(name):]
+}
+
+// rewriteImage returns the image repointed by the first matching mapping, keeping its repository
+// path, tag and digest. The images without a registry are matched as docker.io ones, the way
+// container runtimes pull them.
+func rewriteImage(ref string, mappings []imageMapping) (string, bool) {
+	name, suffix := SplitImage(ref)
+	parsed := ParseImage(name)
+	candidates := []string{name}
+	if normalized := parsed.Registry + "/" + parsed.Repository; normalized != name {
+		candidates = append(candidates, normalized)
+	}
+	for _, m := range mappings {
+		for _, candidate := range candidates {
+			if candidate == m.from || strings.HasPrefix(candidate, m.from+"/") {
+				return m.to + candidate[len(m.from):] + suffix, true
+			}
+		}
+	}
+	return ref, false
+}
+
+// imageRewrite is an image of a container repointed by --image-map
+type imageRewrite struct {
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Container string `json:"container"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+}
+
+// imageRewriter repoints the container images of the workloads with --image-map and reports the
+// rewrites in image-rewrites.json, with the images no mapping matched. A nil rewriter leaves the
+// images unchanged.
+type imageRewriter struct {
+	mappings []imageMapping
+	rewrites []imageRewrite
+	unmapped map[string]bool
+}
+
+// newImageRewriter returns the rewriter of the mappings, nil without any
+func newImageRewriter(mappings []imageMapping) *imageRewriter {
+	if len(mappings) == 0 {
+		return nil
+	}
+	return &imageRewriter{mappings: mappings, rewrites: []imageRewrite{}, unmapped: map[string]bool{}}
+}
+
+// rewrite repoints the images of the workloads among the resources in place and returns the
+// number of containers rewritten
+func (w *imageRewriter) rewrite(resources []*groupResource) int {
+	if w == nil {
+		return 0
+	}
+	rewritten := 0
+	for _, r := range resources {
+		if r.objects == nil {
+			continue
+		}
+		for _, obj := range r.objects.Items {
+			rewritten += w.rewriteObject(obj)
+		}
+	}
+	return rewritten
+}
+
+func (w *imageRewriter) rewriteObject(obj unstructured.Unstructured) int {
+	path, ok := podSpecPaths[obj.GetKind()]
+	if !ok {
+		return 0
+	}
+	rewritten := 0
+	for _, field := range containerFields {
+		containers, _, _ := unstructured.NestedFieldNoCopy(obj.Object, append(path, field)...)
+		list, _ := containers.([]interface{})
+		for _, c := range list {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := container["name"].(string)
+			ref, _ := container["image"].(string)
+			if ref == "" {
+				continue
+			}
+			to, ok := rewriteImage(ref, w.mappings)
+			if !ok {
+				w.unmapped[ref] = true
+				continue
+			}
+			container["image"] = to
+			w.rewrites = append(w.rewrites, imageRewrite{
+				Namespace: obj.GetNamespace(),
+				Kind:      obj.GetKind(),
+				Name:      obj.GetName(),
+				Container: name,
+				From:      ref,
+				To:        to,
+			})
+			rewritten++
+		}
+	}
+	return rewritten
+}
+
+func (w *imageRewriter) write(exportDir string) error {
+	report := struct {
+		Rewrites []imageRewrite `json:"rewrites"`
+		Unmapped []string       `json:"unmapped"`
+	}{Rewrites: w.rewrites, Unmapped: sortedKeys(w.unmapped)}
+	reportBytes, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(exportDir, ImageRewritesFile), append(reportBytes, '\n'), 0600)
+}
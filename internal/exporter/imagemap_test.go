@@ -0,0 +1,142 @@
+package exporter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_parseImageMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    []imageMapping
+		wantErr bool
+	}{
+		{
+			name:   "given mappings, should sort them by longest prefix",
+			values: []string{"quay.io=mirror.example.com/quay", "quay.io/konveyor/=mirror.example.com/konveyor/"},
+			want: []imageMapping{
+				{from: "quay.io/konveyor", to: "mirror.example.com/konveyor"},
+				{from: "quay.io", to: "mirror.example.com/quay"},
+			},
+		},
+		{name: "given no target, should fail", values: []string{"quay.io="}, wantErr: true},
+		{name: "given no separator, should fail", values: []string{"quay.io"}, wantErr: true},
+		{name: "given a scheme, should fail", values: []string{"https://quay.io=mirror.example.com"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseImageMap(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseImageMap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseImageMap() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_rewriteImage(t *testing.T) {
+	mappings, err := parseImageMap([]string{
+		"quay.io=mirror.example.com:5000/quay",
+		"quay.io/konveyor=mirror.example.com:5000/konveyor",
+		"registry.example.com:5000=mirror.example.com:5000/internal",
+		"docker.io=mirror.example.com:5000/dockerhub",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ref    string
+		want   string
+		wantOK bool
+	}{
+		{ref: "quay.io/app:v1", want: "mirror.example.com:5000/quay/app:v1", wantOK: true},
+		{ref: "quay.io/konveyor/hello-world:v1", want: "mirror.example.com:5000/konveyor/hello-world:v1", wantOK: true},
+		{ref: "quay.io/app@sha256:abcd", want: "mirror.example.com:5000/quay/app@sha256:abcd", wantOK: true},
+		{ref: "quay.io/app:1.0@sha256:abcd", want: "mirror.example.com:5000/quay/app:1.0@sha256:abcd", wantOK: true},
+		{ref: "registry.example.com:5000/team/app", want: "mirror.example.com:5000/internal/team/app", wantOK: true},
+		{ref: "registry.example.com:5000/team/app:2.1", want: "mirror.example.com:5000/internal/team/app:2.1", wantOK: true},
+		{ref: "nginx", want: "mirror.example.com:5000/dockerhub/library/nginx", wantOK: true},
+		{ref: "bitnami/redis:7.2", want: "mirror.example.com:5000/dockerhub/bitnami/redis:7.2", wantOK: true},
+		{ref: "docker.io/library/busybox:1.36", want: "mirror.example.com:5000/dockerhub/library/busybox:1.36", wantOK: true},
+		{ref: "quay.io.evil.com/app", want: "quay.io.evil.com/app"},
+		{ref: "localhost:5000/app", want: "localhost:5000/app"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, ok := rewriteImage(tt.ref, mappings)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("rewriteImage() = %s, %v, want %s, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func Test_imageRewriter(t *testing.T) {
+	container := func(name, image string) interface{} {
+		return map[string]interface{}{"name": name, "image": image}
+	}
+	deployment := testOwnedObject("Deployment", "web")
+	if err := unstructured.SetNestedSlice(deployment.Object, []interface{}{container("init", "busybox")}, "spec", "template", "spec", "initContainers"); err != nil {
+		t.Fatal(err)
+	}
+	if err := unstructured.SetNestedSlice(deployment.Object, []interface{}{container("web", "quay.io/konveyor/hello-world:v1")}, "spec", "template", "spec", "containers"); err != nil {
+		t.Fatal(err)
+	}
+	pod := testOwnedObject("Pod", "debug")
+	if err := unstructured.SetNestedSlice(pod.Object, []interface{}{container("shell", "ghcr.io/tools/shell:1")}, "spec", "ephemeralContainers"); err != nil {
+		t.Fatal(err)
+	}
+	resources := []*groupResource{{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{deployment, pod}}}}
+
+	mappings, err := parseImageMap([]string{"quay.io=mirror.example.com/quay", "docker.io=mirror.example.com/dockerhub"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := newImageRewriter(mappings)
+	if got := w.rewrite(resources); got != 2 {
+		t.Errorf("rewrite() = %d, want 2", got)
+	}
+	containers, _, _ := unstructured.NestedSlice(resources[0].objects.Items[0].Object, "spec", "template", "spec", "containers")
+	if got := containers[0].(map[string]interface{})["image"]; got != "mirror.example.com/quay/konveyor/hello-world:v1" {
+		t.Errorf("image = %v, want the rewritten one", got)
+	}
+
+	dir := t.TempDir()
+	if err := w.write(dir); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	reportBytes, err := os.ReadFile(filepath.Join(dir, ImageRewritesFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := struct {
+		Rewrites []imageRewrite `json:"rewrites"`
+		Unmapped []string       `json:"unmapped"`
+	}{}
+	if err := json.Unmarshal(reportBytes, &got); err != nil {
+		t.Fatalf("%s does not parse: %v", ImageRewritesFile, err)
+	}
+	wantRewrites := []imageRewrite{
+		{Namespace: "foo", Kind: "Deployment", Name: "web", Container: "init", From: "busybox", To: "mirror.example.com/dockerhub/library/busybox"},
+		{Namespace: "foo", Kind: "Deployment", Name: "web", Container: "web", From: "quay.io/konveyor/hello-world:v1", To: "mirror.example.com/quay/konveyor/hello-world:v1"},
+	}
+	if !reflect.DeepEqual(got.Rewrites, wantRewrites) {
+		t.Errorf("rewrites = %+v, want %+v", got.Rewrites, wantRewrites)
+	}
+	if want := []string{"ghcr.io/tools/shell:1"}; !reflect.DeepEqual(got.Unmapped, want) {
+		t.Errorf("unmapped = %v, want %v", got.Unmapped, want)
+	}
+
+	var unset *imageRewriter
+	if got := unset.rewrite(resources); got != 0 {
+		t.Errorf("rewrite() without mappings = %d, want 0", got)
+	}
+}
@@ -0,0 +1,148 @@
+package exporter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const ImagesFile = "images.json"
+
+// podSpecPaths are the paths of the pod spec in the objects of the workload kinds
+var podSpecPaths = map[string][]string{
+	"Pod":              {"spec"},
+	"Deployment":       {"spec", "template", "spec"},
+	"StatefulSet":      {"spec", "template", "spec"},
+	"DaemonSet":        {"spec", "template", "spec"},
+	"ReplicaSet":       {"spec", "template", "spec"},
+	"Job":              {"spec", "template", "spec"},
+	"CronJob":          {"spec", "jobTemplate", "spec", "template", "spec"},
+	"DeploymentConfig": {"spec", "template", "spec"},
+}
+
+var containerFields = []string{"initContainers", "containers", "ephemeralContainers"}
+
+// imageReference is an object container using an image
+type imageReference struct {
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Container string `json:"container"`
+}
+
+// Image is an entry of images.json, the inventory of the container images used by the exported
+// workloads so that they can be mirrored to the target registry
+type Image struct {
+	Image      string           `json:"image"`
+	Registry   string           `json:"registry"`
+	Repository string           `json:"repository"`
+	Tag        string           `json:"tag,omitempty"`
+	Digest     string           `json:"digest,omitempty"`
+	References []imageReference `json:"references"`
+}
+
+// imageInventory collects the images of the exported objects across namespaces
+type imageInventory struct {
+	images map[string]*Image
+}
+
+func newImageInventory() *imageInventory {
+	return &imageInventory{images: map[string]*Image{}}
+}
+
+// add records the images of the workloads among the resources, from the listed objects
+func (inv *imageInventory) add(resources []*groupResource) {
+	for _, r := range resources {
+		// the objects of the other versions are the same as the preferred ones
+		if r.objects == nil || r.nonPreferredVersion {
+			continue
+		}
+		for _, obj := range r.objects.Items {
+			inv.addObject(obj)
+		}
+	}
+}
+
+func (inv *imageInventory) addObject(obj unstructured.Unstructured) {
+	path, ok := podSpecPaths[obj.GetKind()]
+	if !ok {
+		return
+	}
+	for _, field := range containerFields {
+		containers, _, _ := unstructured.NestedSlice(obj.Object, append(path, field)...)
+		for _, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := container["name"].(string)
+			ref, _ := container["image"].(string)
+			if ref == "" {
+				continue
+			}
+			img, ok := inv.images[ref]
+			if !ok {
+				img = ParseImage(ref)
+				inv.images[ref] = img
+			}
+			img.References = append(img.References, imageReference{
+				Namespace: obj.GetNamespace(),
+				Kind:      obj.GetKind(),
+				Name:      obj.GetName(),
+				Container: name,
+			})
+		}
+	}
+}
+
+// list returns the images sorted by reference
+func (inv *imageInventory) list() []*Image {
+	images := make([]*Image, 0, len(inv.images))
+	for _, ref := range sortedKeys(inv.images) {
+		images = append(images, inv.images[ref])
+	}
+	return images
+}
+
+func (inv *imageInventory) write(exportDir string) error {
+	imageBytes, err := json.MarshalIndent(inv.list(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(exportDir, ImagesFile), append(imageBytes, '\n'), 0600)
+}
+
+// ParseImage breaks down an image reference the way container runtimes resolve it, images
+// without a registry are pulled from docker.io
+func ParseImage(ref string) *Image {
+	img := &Image{Image: ref}
+	name := ref
+	if i := strings.Index(name, "@"); i >= 0 {
+		img.Digest = name[i+1:]
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		img.Tag = name[i+1:]
+		name = name[:i]
+	}
+	if img.Tag == "" && img.Digest == "" {
+		img.Tag = "latest"
+	}
+
+	img.Registry = "docker.io"
+	img.Repository = name
+	if i := strings.Index(name, "/"); i >= 0 {
+		host := name[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			img.Registry = host
+			img.Repository = name[i+1:]
+		}
+	}
+	if img.Registry == "docker.io" && !strings.Contains(img.Repository, "/") {
+		img.Repository = "library/" + img.Repository
+	}
+	return img
+}
@@ -0,0 +1,115 @@
+package exporter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_parseImage(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want Image
+	}{
+		{
+			ref:  "nginx",
+			want: Image{Registry: "docker.io", Repository: "library/nginx", Tag: "latest"},
+		},
+		{
+			ref:  "bitnami/redis:7.2",
+			want: Image{Registry: "docker.io", Repository: "bitnami/redis", Tag: "7.2"},
+		},
+		{
+			ref:  "quay.io/konveyor/hello-world:v1",
+			want: Image{Registry: "quay.io", Repository: "konveyor/hello-world", Tag: "v1"},
+		},
+		{
+			ref:  "localhost:5000/app",
+			want: Image{Registry: "localhost:5000", Repository: "app", Tag: "latest"},
+		},
+		{
+			ref:  "registry.example.com:5000/team/app:1.0@sha256:abcd",
+			want: Image{Registry: "registry.example.com:5000", Repository: "team/app", Tag: "1.0", Digest: "sha256:abcd"},
+		},
+		{
+			ref:  "quay.io/app@sha256:abcd",
+			want: Image{Registry: "quay.io", Repository: "app", Digest: "sha256:abcd"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			tt.want.Image = tt.ref
+			if got := ParseImage(tt.ref); !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("parseImage() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_imageInventory(t *testing.T) {
+	workload := func(kind string, name string, path []string, containers map[string][]interface{}) unstructured.Unstructured {
+		obj := testOwnedObject(kind, name)
+		for field, c := range containers {
+			if err := unstructured.SetNestedSlice(obj.Object, c, append(path, field)...); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return obj
+	}
+	container := func(name, image string) interface{} {
+		return map[string]interface{}{"name": name, "image": image}
+	}
+	resources := []*groupResource{{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+		workload("Deployment", "hello-world", []string{"spec", "template", "spec"}, map[string][]interface{}{
+			"initContainers": {container("init", "busybox")},
+			"containers":     {container("web", "quay.io/konveyor/hello-world:v1")},
+		}),
+		workload("CronJob", "backup", []string{"spec", "jobTemplate", "spec", "template", "spec"}, map[string][]interface{}{
+			"containers": {container("backup", "busybox")},
+		}),
+		workload("Pod", "debug", []string{"spec"}, map[string][]interface{}{
+			"ephemeralContainers": {container("shell", "quay.io/konveyor/hello-world:v1")},
+		}),
+		workload("ConfigMap", "not-a-workload", []string{"data"}, map[string][]interface{}{
+			"containers": {container("x", "ignored")},
+		}),
+	}}}}
+
+	inv := newImageInventory()
+	inv.add(resources)
+
+	dir := t.TempDir()
+	if err := inv.write(dir); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	imageBytes, err := os.ReadFile(filepath.Join(dir, ImagesFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []Image{}
+	if err := json.Unmarshal(imageBytes, &got); err != nil {
+		t.Fatalf("%s does not parse: %v", ImagesFile, err)
+	}
+
+	refs := map[string][]imageReference{}
+	for _, img := range got {
+		refs[img.Image] = img.References
+	}
+	want := map[string][]imageReference{
+		"busybox": {
+			{Namespace: "foo", Kind: "Deployment", Name: "hello-world", Container: "init"},
+			{Namespace: "foo", Kind: "CronJob", Name: "backup", Container: "backup"},
+		},
+		"quay.io/konveyor/hello-world:v1": {
+			{Namespace: "foo", Kind: "Deployment", Name: "hello-world", Container: "web"},
+			{Namespace: "foo", Kind: "Pod", Name: "debug", Container: "shell"},
+		},
+	}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("images = %+v, want %+v", refs, want)
+	}
+}
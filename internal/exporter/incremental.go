@@ -0,0 +1,160 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	"github.com/sirupsen/logrus"
+)
+
+// incrementalChanges counts the files of an --incremental export compared to the previous export.
+// Stale are the files of the objects no longer exported that were kept, without --prune.
+type incrementalChanges struct {
+	Added     int `json:"added"`
+	Changed   int `json:"changed"`
+	Removed   int `json:"removed"`
+	Unchanged int `json:"unchanged"`
+	Stale     int `json:"stale,omitempty"`
+}
+
+// prepareIncremental prepares the export directory of an --incremental export, the failures of the
+// previous export are removed as they are written again
+func prepareIncremental(exportDir string, log logrus.FieldLogger) ([]index.Entry, error) {
+	entries, err := index.Read(exportDir)
+	switch {
+	case os.IsNotExist(err):
+		log.Infof("No %s found in %s, every file is written", index.File, exportDir)
+	case err != nil:
+		return nil, err
+	}
+	if err := os.RemoveAll(filepath.Join(exportDir, "failures")); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// loadPrevious sets the files of the previous export, the files written with the same content
+// are then left untouched
+func (x *exportIndex) loadPrevious(entries []index.Entry) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.previous = map[string]index.Entry{}
+	for _, e := range entries {
+		x.previous[e.Path] = e
+	}
+}
+
+// unchanged reports whether the file at path already holds data, per the checksum of the previous
+// export, in which case it is not written again. The encrypted files never are, their content
+// differs on every export.
+func (x *exportIndex) unchanged(path string, data []byte) bool {
+	if x == nil || x.previous == nil {
+		return false
+	}
+	x.mu.Lock()
+	previous, found := x.previous[x.relPath(path)]
+	x.mu.Unlock()
+	if !found || previous.SHA256 != index.Sum(data) {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Size() == previous.Size
+}
+
+// countChange records whether a file added to the index is new, changed or unchanged since the
+// previous export
+func (x *exportIndex) countChange(entry index.Entry) {
+	if x.previous == nil {
+		return
+	}
+	previous, found := x.previous[entry.Path]
+	switch {
+	case !found:
+		x.changes.Added++
+	case previous.SHA256 != entry.SHA256:
+		x.changes.Changed++
+	default:
+		x.changes.Unchanged++
+	}
+}
+
+// prune handles the files of the previous export of the namespaces that were not written again,
+// their objects no longer being exported. They are deleted when remove is set, otherwise they are
+// kept in the index.
+func (x *exportIndex) prune(namespaces []string, remove bool, log logrus.FieldLogger) incrementalChanges {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	written := map[string]bool{}
+	for _, e := range x.entries {
+		written[e.Path] = true
+	}
+	paths := make([]string, 0, len(x.previous))
+	for path := range x.previous {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		previous := x.previous[path]
+		if written[path] {
+			continue
+		}
+		if !remove || !InNamespaces(path, namespaces) {
+			x.entries = append(x.entries, previous)
+			if InNamespaces(path, namespaces) {
+				x.changes.Stale++
+			}
+			continue
+		}
+		fullPath := filepath.Join(x.exportDir, filepath.FromSlash(path))
+		log.Infof("Removing %s, its object is no longer exported", fullPath)
+		if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+			log.Warnf("cannot remove %s: %v", fullPath, err)
+			x.entries = append(x.entries, previous)
+			continue
+		}
+		// the kind layout leaves the directory of a resource without objects empty
+		os.Remove(filepath.Dir(fullPath))
+		x.changes.Removed++
+	}
+	if x.changes.Stale > 0 {
+		log.Infof("%d files of objects no longer exported are kept, use --prune to remove them", x.changes.Stale)
+	}
+	return x.changes
+}
+
+// InNamespaces reports whether an indexed path belongs to one of the namespaces, under
+// resources/<namespace>/ or one of its files with the single layout
+func InNamespaces(path string, namespaces []string) bool {
+	for _, namespace := range namespaces {
+		if strings.HasPrefix(path, "resources/"+namespace+"/") {
+			return true
+		}
+		for _, name := range []string{namespace + ".yaml", namespace + "-cluster.yaml"} {
+			if path == "resources/"+name || path == "resources/"+name+gzipExtension {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalizeObjects orders the lists of the objects whose order carries no meaning, so that an
+// object written again has the same content. The keys of the maps are always sorted when the
+// objects are marshaled.
+func normalizeObjects(resources []*groupResource) {
+	for _, r := range resources {
+		if r.objects == nil {
+			continue
+		}
+		for _, obj := range r.objects.Items {
+			if finalizers := obj.GetFinalizers(); len(finalizers) > 1 {
+				sort.Strings(finalizers)
+				obj.SetFinalizers(finalizers)
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// writeIncremental writes the deployments of namespace foo with the index of the previous export
+// of exportDir, if any, and returns the changes
+func writeIncremental(t *testing.T, exportDir string, prune bool, objects ...unstructured.Unstructured) incrementalChanges {
+	t.Helper()
+	previous, err := prepareIncremental(exportDir, testLogger())
+	if err != nil {
+		t.Fatalf("prepareIncremental() error = %v", err)
+	}
+	manifests := newExportIndex(exportDir)
+	manifests.loadPrevious(previous)
+	w := &resourceWriter{
+		resourceDir: filepath.Join(exportDir, "resources", "foo"),
+		output:      OutputYAML,
+		layout:      LayoutFlat,
+		workers:     1,
+		index:       manifests,
+		log:         testLogger(),
+	}
+	resources := []*groupResource{
+		{APIGroup: "apps", APIVersion: "v1", APIResource: metav1.APIResource{Name: "deployments", Kind: "Deployment"}, objects: &unstructured.UnstructuredList{Items: objects}},
+	}
+	normalizeObjects(resources)
+	if errs := w.writeResources(resources); len(errs) > 0 {
+		t.Fatalf("writeResources() errors = %v", errs)
+	}
+	changes := manifests.prune([]string{"foo"}, prune, testLogger())
+	if err := manifests.write(); err != nil {
+		t.Fatal(err)
+	}
+	return changes
+}
+
+func testDeployment(name string, replicas int64) unstructured.Unstructured {
+	obj := testObject()
+	obj.SetName(name)
+	obj.Object["spec"] = map[string]interface{}{"replicas": replicas}
+	return obj
+}
+
+func Test_incrementalExport(t *testing.T) {
+	exportDir := t.TempDir()
+	changes := writeIncremental(t, exportDir, false, testDeployment("api", 1), testDeployment("web", 1), testDeployment("db", 1))
+	if want := (incrementalChanges{Added: 3}); changes != want {
+		t.Errorf("first export changes = %+v, want %+v", changes, want)
+	}
+
+	unchangedPath := filepath.Join(exportDir, "resources", "foo", "deployments.apps_api.yaml")
+	before, err := os.Stat(unchangedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a rewritten file would get a new modification time
+	time.Sleep(10 * time.Millisecond)
+
+	changes = writeIncremental(t, exportDir, false, testDeployment("api", 1), testDeployment("web", 3))
+	if want := (incrementalChanges{Changed: 1, Unchanged: 1, Stale: 1}); changes != want {
+		t.Errorf("export without db changes = %+v, want %+v", changes, want)
+	}
+	after, err := os.Stat(unchangedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !after.ModTime().Equal(before.ModTime()) {
+		t.Errorf("the unchanged file was written again")
+	}
+	// the stale file is still on disk and in the index
+	entries, err := index.Read(exportDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("index entries = %v, want the 3 files on disk", entries)
+	}
+
+	changes = writeIncremental(t, exportDir, true, testDeployment("api", 1), testDeployment("web", 3))
+	if want := (incrementalChanges{Removed: 1, Unchanged: 2}); changes != want {
+		t.Errorf("pruned export changes = %+v, want %+v", changes, want)
+	}
+	if _, err := os.Stat(filepath.Join(exportDir, "resources", "foo", "deployments.apps_db.yaml")); !os.IsNotExist(err) {
+		t.Errorf("the file of the removed object should be pruned, stat error = %v", err)
+	}
+	entries, err = index.Read(exportDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems, err := index.Verify(exportDir, entries); err != nil || len(problems) > 0 {
+		t.Errorf("Verify() = %v, %v, want no problems", problems, err)
+	}
+}
+
+func Test_inNamespaces(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "resources/foo/deployments.apps_web.yaml", want: true},
+		{path: "resources/foo/_cluster/crds/widgets.example.com.yaml", want: true},
+		{path: "resources/foo.yaml", want: true},
+		{path: "resources/foo-cluster.yaml.gz", want: true},
+		{path: "resources/foobar/deployments.apps_web.yaml", want: false},
+		{path: "resources/bar.yaml", want: false},
+	}
+	for _, tt := range tests {
+		if got := InNamespaces(tt.path, []string{"foo"}); got != tt.want {
+			t.Errorf("inNamespaces(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func Test_normalizeObjects(t *testing.T) {
+	obj := testObject()
+	obj.SetFinalizers([]string{"kubernetes.io/pvc-protection", "example.com/cleanup"})
+	normalizeObjects([]*groupResource{{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{obj}}}})
+	if got := obj.GetFinalizers(); !reflect.DeepEqual(got, []string{"example.com/cleanup", "kubernetes.io/pvc-protection"}) {
+		t.Errorf("finalizers = %v, want them sorted", got)
+	}
+}
@@ -0,0 +1,100 @@
+package exporter
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// exportIndex collects the files written by the resource writers so that index.json can be
+// written at the end of the run
+type exportIndex struct {
+	exportDir string
+
+	mu      sync.Mutex
+	entries []index.Entry
+	// previous are the files of the previous export by path with --incremental, changes counts
+	// the files written compared to them
+	previous map[string]index.Entry
+	changes  incrementalChanges
+	// upload uploads the files to an s3:// export directory once written
+	upload *s3Uploader
+	// durable flushes index.json to disk
+	durable bool
+}
+
+func newExportIndex(exportDir string) *exportIndex {
+	return &exportIndex{exportDir: exportDir, entries: []index.Entry{}}
+}
+
+// add records a written file with the object it holds, obj is nil for the files holding several objects
+func (x *exportIndex) add(path string, data []byte, obj *unstructured.Unstructured) {
+	if x == nil {
+		return
+	}
+	entry := index.Entry{
+		Path:   x.relPath(path),
+		Size:   int64(len(data)),
+		SHA256: index.Sum(data),
+	}
+	if obj != nil {
+		entry.APIVersion = obj.GetAPIVersion()
+		entry.Kind = obj.GetKind()
+		entry.Namespace = obj.GetNamespace()
+		entry.Name = obj.GetName()
+	}
+	x.mu.Lock()
+	x.countChange(entry)
+	x.entries = append(x.entries, entry)
+	x.mu.Unlock()
+	x.upload.enqueue(path)
+}
+
+// remove forgets a file removed from the export directory
+func (x *exportIndex) remove(path string) {
+	if x == nil {
+		return
+	}
+	rel := x.relPath(path)
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	kept := x.entries[:0]
+	for _, e := range x.entries {
+		if e.Path != rel {
+			kept = append(kept, e)
+		}
+	}
+	x.entries = kept
+}
+
+// relPath returns the path of a file relative to the export directory, as written in the index
+func (x *exportIndex) relPath(path string) string {
+	if rel, err := filepath.Rel(x.exportDir, path); err == nil {
+		path = rel
+	}
+	return filepath.ToSlash(path)
+}
+
+// load adds the entries of a previous export, the files written again replace them
+func (x *exportIndex) load(entries []index.Entry) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.entries = append(append([]index.Entry{}, entries...), x.entries...)
+}
+
+// write writes index.json, a file written several times is indexed once with its last content
+func (x *exportIndex) write() error {
+	entries := []index.Entry{}
+	positions := map[string]int{}
+	for _, e := range x.entries {
+		if i, found := positions[e.Path]; found {
+			entries[i] = e
+			continue
+		}
+		positions[e.Path] = len(entries)
+		entries = append(entries, e)
+	}
+	return index.WriteDurable(x.exportDir, entries, x.durable)
+}
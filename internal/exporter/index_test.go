@@ -0,0 +1,59 @@
+package exporter
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_exportIndex(t *testing.T) {
+	resources := []*groupResource{
+		{APIGroup: "apps", APIVersion: "v1", APIResource: metav1.APIResource{Name: "deployments", Kind: "Deployment"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{testObject()}}},
+	}
+	for _, layout := range []string{LayoutFlat, LayoutSingle} {
+		t.Run(layout, func(t *testing.T) {
+			exportDir := t.TempDir()
+			manifests := newExportIndex(exportDir)
+			w := &resourceWriter{
+				resourceDir: filepath.Join(exportDir, "resources", "test"),
+				output:      OutputYAML,
+				layout:      layout,
+				singleFile:  filepath.Join(exportDir, "resources", "test.yaml"),
+				workers:     1,
+				index:       manifests,
+				log:         testLogger(),
+			}
+			if errs := w.writeResources(resources); len(errs) > 0 {
+				t.Fatalf("writeResources() errors = %v", errs)
+			}
+			if err := manifests.write(); err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := index.Read(exportDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Fatalf("index entries = %v, want 1", entries)
+			}
+			wantName := ""
+			if layout == LayoutFlat {
+				wantName = "hello-world"
+			}
+			if entries[0].Name != wantName {
+				t.Errorf("indexed object name = %q, want %q", entries[0].Name, wantName)
+			}
+			problems, err := index.Verify(exportDir, entries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(problems) > 0 {
+				t.Errorf("Verify() = %v, want no problems", problems)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// largeObjectSize is the size of the JSON of an object above which the streamed resources list it
+// as a stub, to be fetched and written on its own
+const largeObjectSize = 1 << 20
+
+// largeObjectAnnotation holds the size of an object listed as a stub until it is fetched. The
+// checkpoint records the stubs like the other objects, so that --resume fetches them again.
+const largeObjectAnnotation = "migrate.konveyor.io/large-object-size"
+
+// objectSize estimates the size of the object serialized as JSON by walking its content, so that
+// the objects above --max-object-size are dropped without serializing them once more
+func objectSize(v interface{}) int {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		size := 2
+		for key, value := range v {
+			size += len(key) + 4 + objectSize(value)
+		}
+		return size
+	case []interface{}:
+		size := 2
+		for _, item := range v {
+			size += objectSize(item) + 1
+		}
+		return size
+	case string:
+		return len(v) + 2
+	case bool:
+		return 5
+	case nil:
+		return 4
+	default:
+		return 8
+	}
+}
+
+// dropLargeObjects removes the objects above --max-object-size from the listed resources as soon as
+// they are listed, before they are copied by the filters and transformations, so that a few huge
+// ConfigMaps or Secrets do not exhaust the memory of the export. Each one is recorded as a failure.
+func (o *ExportOptions) dropLargeObjects(resources []*groupResource, log logrus.FieldLogger) []FailureRecord {
+	if o.objectLimit <= 0 {
+		return nil
+	}
+	failures := []FailureRecord{}
+	for _, r := range resources {
+		if r.objects == nil {
+			continue
+		}
+		kept := r.objects.Items[:0]
+		for _, obj := range r.objects.Items {
+			size := objectSize(obj.Object)
+			if int64(size) <= o.objectLimit {
+				kept = append(kept, obj)
+				continue
+			}
+			failures = append(failures, o.tooLargeFailure(r, obj, size, log))
+		}
+		// the dropped objects are released with the tail of the list
+		for i := len(kept); i < len(r.objects.Items); i++ {
+			r.objects.Items[i] = unstructured.Unstructured{}
+		}
+		r.objects.Items = kept
+	}
+	return failures
+}
+
+// tooLargeFailure logs and records an object above --max-object-size
+func (o *ExportOptions) tooLargeFailure(r *groupResource, obj unstructured.Unstructured, size int, log logrus.FieldLogger) FailureRecord {
+	log.Warnf("%s %s is about %s, above --max-object-size %s, it is not exported", obj.GetKind(), obj.GetName(), FormatBytes(size), o.maxObjectSize)
+	return FailureRecord{
+		Operation: "size",
+		Group:     r.APIGroup,
+		Version:   r.APIVersion,
+		Resource:  r.APIResource.Name,
+		Name:      obj.GetName(),
+		Error:     fmt.Sprintf("the object is about %d bytes, above --max-object-size %d", size, o.objectLimit),
+		Category:  failureTooLarge,
+	}
+}
+
+// objectStreamer lists the streamed resources with raw requests, decoding the items of a page one
+// at a time from the response. The items above its threshold are not decoded with their page but
+// listed as stubs, and fetched one by one with a raw GET once the rest of the page is written, so
+// that a namespace storing archives in its ConfigMaps or Secrets holds a single one in memory.
+type objectStreamer struct {
+	client    rest.Interface
+	retry     retryPolicy
+	threshold int
+}
+
+func newObjectStreamer(client rest.Interface, retry retryPolicy) *objectStreamer {
+	return &objectStreamer{client: client, retry: retry, threshold: largeObjectSize}
+}
+
+// lister returns the client listing the resource with the streamer, the other calls go to client
+func (s *objectStreamer) lister(g *groupResource, namespace string, client dynamic.ResourceInterface) dynamic.ResourceInterface {
+	if s == nil || g.APIGroup != "" || !g.APIResource.Namespaced {
+		return client
+	}
+	return &rawLister{ResourceInterface: client, streamer: s, resource: g.APIResource.Name, namespace: namespace}
+}
+
+// get fetches an object listed as a stub with a raw GET, decoding it from the response body
+func (s *objectStreamer) get(ctx context.Context, g *groupResource, stub unstructured.Unstructured) (unstructured.Unstructured, error) {
+	obj := unstructured.Unstructured{}
+	err := s.retry.do(ctx, func() error {
+		body, err := s.client.Get().
+			Namespace(stub.GetNamespace()).
+			Resource(g.APIResource.Name).
+			Name(stub.GetName()).
+			SetHeader("Accept", "application/json").
+			Stream(ctx)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		decoder := json.NewDecoder(body)
+		decoder.UseNumber()
+		obj.Object = map[string]interface{}{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			return err
+		}
+		return utiljson.ConvertMapNumbers(obj.Object, 0)
+	})
+	return obj, err
+}
+
+// rawLister lists a core resource with the objectStreamer
+type rawLister struct {
+	dynamic.ResourceInterface
+	streamer  *objectStreamer
+	resource  string
+	namespace string
+}
+
+func (l *rawLister) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	var list *unstructured.UnstructuredList
+	err := l.streamer.retry.do(ctx, func() error {
+		body, err := l.streamer.client.Get().
+			Namespace(l.namespace).
+			Resource(l.resource).
+			VersionedParams(&opts, scheme.ParameterCodec).
+			SetHeader("Accept", "application/json").
+			Stream(ctx)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		list, err = decodeList(body, l.streamer.threshold)
+		return err
+	})
+	return list, err
+}
+
+// decodeList decodes a list response item by item, the items above threshold bytes are decoded
+// as stubs holding their identity and size
+func decodeList(r io.Reader, threshold int) (*unstructured.UnstructuredList, error) {
+	decoder := json.NewDecoder(r)
+	if err := expectDelim(decoder, '{'); err != nil {
+		return nil, err
+	}
+	list := &unstructured.UnstructuredList{Object: map[string]interface{}{}, Items: []unstructured.Unstructured{}}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := token.(string)
+		if key != "items" {
+			var raw json.RawMessage
+			if err := decoder.Decode(&raw); err != nil {
+				return nil, err
+			}
+			var value interface{}
+			if err := utiljson.Unmarshal(raw, &value); err != nil {
+				return nil, err
+			}
+			list.Object[key] = value
+			continue
+		}
+		if err := expectDelim(decoder, '['); err != nil {
+			return nil, err
+		}
+		for decoder.More() {
+			var raw json.RawMessage
+			if err := decoder.Decode(&raw); err != nil {
+				return nil, err
+			}
+			obj, err := decodeItem(raw, threshold)
+			if err != nil {
+				return nil, err
+			}
+			list.Items = append(list.Items, obj)
+		}
+		if err := expectDelim(decoder, ']'); err != nil {
+			return nil, err
+		}
+	}
+	// the items of a list have no kind, they are given the one of the list like the dynamic
+	// client does
+	apiVersion, kind := list.GetAPIVersion(), strings.TrimSuffix(list.GetKind(), "List")
+	for i := range list.Items {
+		if list.Items[i].GetKind() == "" {
+			list.Items[i].SetAPIVersion(apiVersion)
+			list.Items[i].SetKind(kind)
+		}
+	}
+	return list, nil
+}
+
+// decodeItem decodes an item of a list, the stub of an item above threshold bytes skips its content
+func decodeItem(raw json.RawMessage, threshold int) (unstructured.Unstructured, error) {
+	obj := unstructured.Unstructured{Object: map[string]interface{}{}}
+	if len(raw) <= threshold {
+		err := utiljson.Unmarshal(raw, &obj.Object)
+		return obj, err
+	}
+	var identity struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		Metadata   struct {
+			Name            string `json:"name"`
+			Namespace       string `json:"namespace"`
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(raw, &identity); err != nil {
+		return obj, err
+	}
+	if identity.Kind != "" {
+		obj.SetAPIVersion(identity.APIVersion)
+		obj.SetKind(identity.Kind)
+	}
+	obj.SetNamespace(identity.Metadata.Namespace)
+	obj.SetName(identity.Metadata.Name)
+	if identity.Metadata.ResourceVersion != "" {
+		obj.SetResourceVersion(identity.Metadata.ResourceVersion)
+	}
+	obj.SetAnnotations(map[string]string{largeObjectAnnotation: strconv.Itoa(len(raw))})
+	return obj, nil
+}
+
+// isLargeStub reports whether the object is the stub of a large object not fetched yet
+func isLargeStub(obj unstructured.Unstructured) bool {
+	_, found := obj.GetAnnotations()[largeObjectAnnotation]
+	return found
+}
+
+func expectDelim(decoder *json.Decoder, want json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != want {
+		return fmt.Errorf("invalid list response: got %v, want %v", token, want)
+	}
+	return nil
+}
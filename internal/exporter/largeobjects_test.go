@@ -0,0 +1,308 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakerest "k8s.io/client-go/rest/fake"
+)
+
+// testLargeConfigMap returns a ConfigMap holding size bytes of data, like an archive stored in it
+func testLargeConfigMap(name string, size int) unstructured.Unstructured {
+	obj := testOwnedObject("ConfigMap", name)
+	obj.Object["data"] = map[string]interface{}{"archive.tar.b64": strings.Repeat("QUJD", size/4)}
+	return obj
+}
+
+func Test_objectSize(t *testing.T) {
+	for _, obj := range []unstructured.Unstructured{testObject(), testLargeConfigMap("large", 1<<20)} {
+		jsonBytes, err := json.Marshal(obj.Object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, want := objectSize(obj.Object), len(jsonBytes)
+		if got < want*9/10 || got > want*11/10 {
+			t.Errorf("objectSize() of %s = %d, want about %d", obj.GetName(), got, want)
+		}
+	}
+}
+
+func Test_dropLargeObjects(t *testing.T) {
+	configMaps := &groupResource{APIVersion: "v1", APIResource: metav1.APIResource{Name: "configmaps", Kind: "ConfigMap"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+		testLargeConfigMap("small", 1<<10),
+		testLargeConfigMap("large", 2<<20),
+		testLargeConfigMap("other", 1<<10),
+	}}}
+	tests := []struct {
+		name         string
+		limit        int64
+		wantKept     []string
+		wantFailures []string
+	}{
+		{
+			name:     "given no limit, should keep every object",
+			wantKept: []string{"small", "large", "other"},
+		},
+		{
+			name:         "given a limit, should drop the larger objects as too large",
+			limit:        1 << 20,
+			wantKept:     []string{"small", "other"},
+			wantFailures: []string{"large"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := *configMaps
+			r.objects = configMaps.objects.DeepCopy()
+			o := &ExportOptions{objectLimit: tt.limit, maxObjectSize: fmt.Sprint(tt.limit)}
+
+			failures := o.dropLargeObjects([]*groupResource{&r}, testLogger())
+
+			kept := []string{}
+			for _, obj := range r.objects.Items {
+				kept = append(kept, obj.GetName())
+			}
+			if strings.Join(kept, ",") != strings.Join(tt.wantKept, ",") {
+				t.Errorf("kept %v, want %v", kept, tt.wantKept)
+			}
+			if len(failures) != len(tt.wantFailures) {
+				t.Fatalf("dropLargeObjects() failures = %v, want %v", failures, tt.wantFailures)
+			}
+			for i, f := range failures {
+				if f.Name != tt.wantFailures[i] || f.Category != failureTooLarge || f.Resource != "configmaps" {
+					t.Errorf("dropLargeObjects() failure = %+v, want %s too large", f, tt.wantFailures[i])
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMarshalObject_largeConfigMap(b *testing.B) {
+	obj := testLargeConfigMap("large", 8<<20)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := marshalObject(obj, OutputYAML); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDropLargeObjects(b *testing.B) {
+	items := []unstructured.Unstructured{}
+	for i := 0; i < 10; i++ {
+		items = append(items, testLargeConfigMap(fmt.Sprintf("large-%d", i), 8<<20))
+	}
+	o := &ExportOptions{objectLimit: 1 << 20, maxObjectSize: "1Mi"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r := &groupResource{APIVersion: "v1", APIResource: metav1.APIResource{Name: "configmaps", Kind: "ConfigMap"}, objects: &unstructured.UnstructuredList{Items: append([]unstructured.Unstructured{}, items...)}}
+		o.dropLargeObjects([]*groupResource{r}, testLogger())
+	}
+}
+
+// testStreamer returns a streamer serving the configmaps of namespace foo like the API server, the
+// items of the list without their kind. The configmap named deleted is listed but not found. The
+// paths requested are recorded in requests.
+func testStreamer(objects []unstructured.Unstructured, deleted string, threshold int, requests *[]string) *objectStreamer {
+	client := &fakerest.RESTClient{
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		GroupVersion:         schema.GroupVersion{Version: "v1"},
+		VersionedAPIPath:     "/api/v1",
+		Client: fakerest.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			*requests = append(*requests, req.URL.Path)
+			var body interface{}
+			code := http.StatusOK
+			if name, found := strings.CutPrefix(req.URL.Path, "/api/v1/namespaces/foo/configmaps/"); found {
+				body = apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, name).Status()
+				code = http.StatusNotFound
+				for _, obj := range objects {
+					if obj.GetName() == name && name != deleted {
+						body, code = obj.Object, http.StatusOK
+					}
+				}
+			} else {
+				items := []interface{}{}
+				for _, obj := range objects {
+					item := obj.DeepCopy().Object
+					delete(item, "apiVersion")
+					delete(item, "kind")
+					items = append(items, item)
+				}
+				body = map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "ConfigMapList",
+					"metadata":   map[string]interface{}{"resourceVersion": "42"},
+					"items":      items,
+				}
+			}
+			data, err := json.Marshal(body)
+			if err != nil {
+				return nil, err
+			}
+			header := http.Header{"Content-Type": []string{"application/json"}}
+			return &http.Response{StatusCode: code, Header: header, Body: io.NopCloser(bytes.NewReader(data))}, nil
+		}),
+	}
+	return &objectStreamer{client: client, retry: retryPolicy{log: testLogger()}, threshold: threshold}
+}
+
+func Test_decodeList(t *testing.T) {
+	var requests []string
+	streamer := testStreamer([]unstructured.Unstructured{
+		testLargeConfigMap("small", 1<<10),
+		testLargeConfigMap("large", 1<<20),
+	}, "", 64<<10, &requests)
+	configMaps := &groupResource{APIVersion: "v1", APIResource: metav1.APIResource{Name: "configmaps", Namespaced: true, Kind: "ConfigMap"}}
+
+	list, err := streamer.lister(configMaps, "foo", nil).List(context.Background(), metav1.ListOptions{Limit: 500})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if list.GetResourceVersion() != "42" {
+		t.Errorf("List() resourceVersion = %q, want 42", list.GetResourceVersion())
+	}
+	if len(list.Items) != 2 {
+		t.Fatalf("List() returned %d objects, want 2", len(list.Items))
+	}
+	small, large := list.Items[0], list.Items[1]
+	if small.GetKind() != "ConfigMap" || small.GetAPIVersion() != "v1" || isLargeStub(small) {
+		t.Errorf("List() small = %v, want a ConfigMap decoded with its page", small.Object)
+	}
+	if _, found := small.Object["data"]; !found {
+		t.Errorf("List() dropped the data of the small configmap")
+	}
+	if large.GetKind() != "ConfigMap" || large.GetName() != "large" || large.GetNamespace() != "foo" || !isLargeStub(large) {
+		t.Errorf("List() large = %v, want the stub of a ConfigMap", large.Object)
+	}
+	if _, found := large.Object["data"]; found {
+		t.Errorf("List() decoded the data of the large configmap")
+	}
+}
+
+func Test_pageStream_largeObjects(t *testing.T) {
+	configMaps := &groupResource{APIVersion: "v1", APIResource: metav1.APIResource{Name: "configmaps", Namespaced: true, Kind: "ConfigMap"}}
+	tests := []struct {
+		name         string
+		limit        int64
+		deleted      string
+		wantWritten  []string
+		wantFailures []string
+		wantRequests int
+	}{
+		{
+			name:         "given a large configmap, should fetch it on its own",
+			wantWritten:  []string{"small", "large"},
+			wantRequests: 2,
+		},
+		{
+			name:         "given a large configmap above --max-object-size, should record it as too large without fetching it",
+			limit:        512 << 10,
+			wantWritten:  []string{"small"},
+			wantFailures: []string{"large"},
+			wantRequests: 1,
+		},
+		{
+			name:         "given a large configmap deleted since it was listed, should skip it",
+			deleted:      "large",
+			wantWritten:  []string{"small"},
+			wantRequests: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := []unstructured.Unstructured{testLargeConfigMap("small", 1<<10), testLargeConfigMap("large", 1<<20)}
+			var requests []string
+			streamer := testStreamer(objects, tt.deleted, 64<<10, &requests)
+			dir := t.TempDir()
+			o := &ExportOptions{layout: LayoutFlat, chunkSize: 500, output: OutputYAML, exportDir: dir, includeSystem: true, objectLimit: tt.limit, maxObjectSize: fmt.Sprint(tt.limit)}
+			exportRun := &exportRun{manifests: newExportIndex(dir), streamer: streamer}
+			writer := o.newResourceWriter("foo", exportRun, testLogger())
+			stream := o.newPageStream("foo", exportRun, writer, newExportSummary("foo"), testLogger())
+
+			if _, err := resumePages(context.Background(), stream.lister(configMaps, "foo", nil), metav1.ListOptions{Limit: 500}, nil, stream.onPage(context.Background(), configMaps), testLogger()); err != nil {
+				t.Fatalf("resumePages() error = %v", err)
+			}
+
+			if errs := stream.errors(); len(errs) > 0 {
+				t.Fatalf("stream errors = %v", errs)
+			}
+			written := []string{}
+			for _, r := range stream.resources() {
+				for _, obj := range r.objects.Items {
+					written = append(written, obj.GetName())
+				}
+			}
+			if strings.Join(written, ",") != strings.Join(tt.wantWritten, ",") {
+				t.Errorf("written %v, want %v", written, tt.wantWritten)
+			}
+			failures := []string{}
+			for _, f := range stream.failureRecords() {
+				failures = append(failures, f.Name)
+			}
+			if strings.Join(failures, ",") != strings.Join(tt.wantFailures, ",") {
+				t.Errorf("failures %v, want %v", failures, tt.wantFailures)
+			}
+			if len(requests) != tt.wantRequests {
+				t.Errorf("requests = %v, want %d", requests, tt.wantRequests)
+			}
+			for _, name := range tt.wantWritten {
+				data, err := os.ReadFile(filepath.Join(dir, "resources", "foo", "configmaps_"+name+".yaml"))
+				if err != nil {
+					t.Fatalf("the configmap %s was not written: %v", name, err)
+				}
+				if !strings.Contains(string(data), "archive.tar.b64: QUJD") || strings.Contains(string(data), largeObjectAnnotation) {
+					t.Errorf("the configmap %s was not written with its data", name)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkPageStream_largeConfigMaps lists and writes a page of large ConfigMaps, decoded with
+// their page or fetched one by one
+func BenchmarkPageStream_largeConfigMaps(b *testing.B) {
+	configMaps := &groupResource{APIVersion: "v1", APIResource: metav1.APIResource{Name: "configmaps", Namespaced: true, Kind: "ConfigMap"}}
+	objects := []unstructured.Unstructured{}
+	for i := 0; i < 10; i++ {
+		objects = append(objects, testLargeConfigMap(fmt.Sprintf("large-%d", i), 4<<20))
+	}
+	for _, bb := range []struct {
+		name      string
+		threshold int
+	}{
+		{name: "decoded with the page", threshold: 1 << 30},
+		{name: "fetched one by one", threshold: largeObjectSize},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			var requests []string
+			streamer := testStreamer(objects, "", bb.threshold, &requests)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				dir := b.TempDir()
+				o := &ExportOptions{layout: LayoutFlat, chunkSize: 500, output: OutputYAML, exportDir: dir, includeSystem: true}
+				exportRun := &exportRun{manifests: newExportIndex(dir), streamer: streamer}
+				writer := o.newResourceWriter("foo", exportRun, testLogger())
+				stream := o.newPageStream("foo", exportRun, writer, newExportSummary("foo"), testLogger())
+				if _, err := resumePages(context.Background(), stream.lister(configMaps, "foo", nil), metav1.ListOptions{Limit: 500}, nil, stream.onPage(context.Background(), configMaps), testLogger()); err != nil {
+					b.Fatal(err)
+				}
+				if errs := stream.errors(); len(errs) > 0 {
+					b.Fatal(errs)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/discovery"
+	"github.com/vmware-tanzu/velero/pkg/features"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
+)
+
+// LiveExport lists the live namespaces of an export like the export did: the namespaces are
+// listed with the flags recorded in its summary, so that the same resources and objects are
+// selected, prepared and rewritten
+type LiveExport struct {
+	o               *ExportOptions
+	dynamicClient   dynamic.Interface
+	discoveryHelper discovery.Helper
+	// streams resolves the images of the pod templates from their ImageStreamTags on OpenShift
+	streams *imageStreamResolver
+}
+
+// NewLiveExport returns the listing of the live namespaces of the export in exportDir, from the
+// flags recorded in its summary and the config file
+func NewLiveExport(previous *RunSummary, exportDir string, configFlags *genericclioptions.ConfigFlags, streams genericclioptions.IOStreams, cobraGlobalFlags *flags.GlobalFlags, globalFlags *flags.GlobalFlags, log logrus.FieldLogger) (*LiveExport, error) {
+	o := &ExportOptions{
+		configFlags:      configFlags,
+		IOStreams:        streams,
+		cobraGlobalFlags: cobraGlobalFlags,
+		globalFlags:      globalFlags,
+	}
+	c := &cobra.Command{}
+	o.addFlags(c.Flags())
+	if err := applyRecordedFlags(c.Flags(), previous.Flags); err != nil {
+		return nil, err
+	}
+	if err := o.Complete(c, nil); err != nil {
+		return nil, err
+	}
+	o.exportDir = exportDir
+
+	dynamicClient, discoveryHelper, err := o.liveClients(log)
+	if err != nil {
+		return nil, err
+	}
+	l := &LiveExport{o: o, dynamicClient: dynamicClient, discoveryHelper: discoveryHelper}
+	platform := o.platform
+	if platform == platformAuto {
+		platform = newClusterInfo(o.rawConfig, *o.configFlags.Context, "", discoveryHelper.Resources()).Platform
+	}
+	if platform == platformOpenShift {
+		l.streams = newImageStreamResolver(dynamicClient, log)
+	}
+	return l, nil
+}
+
+// ClusterScopedRBAC reports whether the export has the cluster-scoped RBAC of the namespaces,
+// exported with --cluster-scoped-rbac
+func (l *LiveExport) ClusterScopedRBAC() bool {
+	return l.o.clusterScopedRbac
+}
+
+// Objects lists the objects of the live namespace and returns them as the export would write
+// them. A resource that cannot be listed fails the listing, its objects would be reported removed.
+func (l *LiveExport) Objects(ctx context.Context, namespace string, log logrus.FieldLogger) ([]unstructured.Unstructured, error) {
+	resources, resourceErrs, _ := l.o.collectResources(ctx, namespace, l.dynamicClient, l.discoveryHelper, newExportSummary(namespace), nil, nil, nil, nil, nil, log)
+	if len(resourceErrs) > 0 {
+		for _, re := range resourceErrs {
+			log.Errorf("cannot list %s in namespace %s: %v", re.APIResource.Name, namespace, re.Error)
+		}
+		return nil, fmt.Errorf("cannot list %d resources of namespace %s, their objects cannot be compared", len(resourceErrs), namespace)
+	}
+	// the images of the pod templates are exported resolved from their ImageStreamTags
+	if l.streams != nil {
+		l.streams.resolve(ctx, resources)
+	}
+	return l.o.exportedObjects(namespace, resources), nil
+}
+
+// exportedObjects returns the objects listed from a namespace with the rewrites of the export
+// flags applied
+func (o *ExportOptions) exportedObjects(namespace string, resources []*groupResource) []unstructured.Unstructured {
+	exportRun := &exportRun{
+		imageRewrites: newImageRewriter(o.imageMappings),
+		renamer:       NewNamespaceRenamer(o.targetNamespace),
+	}
+	o.rewriteObjects(namespace, resources, exportRun, newExportSummary(namespace))
+	objects := []unstructured.Unstructured{}
+	for _, r := range resources {
+		if r.objects != nil {
+			objects = append(objects, r.objects.Items...)
+		}
+	}
+	return objects
+}
+
+// applyRecordedFlags sets the flags recorded in an export summary, the flags describing the run
+// rather than the exported content are left out. They are set like on the command line so that
+// they take precedence over the config file.
+func applyRecordedFlags(flags *pflag.FlagSet, recorded map[string]string) error {
+	for _, name := range sortedKeys(recorded) {
+		f := flags.Lookup(name)
+		if f == nil || retryRunFlags[name] {
+			continue
+		}
+		if err := flags.Set(name, recordedValue(f, recorded[name])); err != nil {
+			return fmt.Errorf("invalid value of --%s in the export: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// liveClients returns the clients listing the live namespaces like the export does. A group that
+// cannot be discovered fails the listing, its objects would be reported removed.
+func (o *ExportOptions) liveClients(log logrus.FieldLogger) (dynamic.Interface, discovery.Helper, error) {
+	discoveryClient, err := o.configFlags.ToDiscoveryClient()
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot create discovery client: %w", err)
+	}
+	discoveryClient.Invalidate()
+	if _, err := CheckDiscovery(discoveryClient, true, log); err != nil {
+		return nil, nil, fmt.Errorf("cannot discover the server resources: %w", err)
+	}
+
+	restConfig, err := o.restConfig(log)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot create rest config: %w", err)
+	}
+	restDynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot create dynamic client: %w", err)
+	}
+	dynamicClient := newRetryingDynamicClient(restDynamicClient, retryPolicy{retries: o.retries, backoff: o.retryBackoff, log: log})
+
+	features.NewFeatureFlagSet()
+	features.Enable(velerov1api.APIGroupVersionsFeatureFlag)
+	discoveryHelper, err := discovery.NewHelper(discoveryClient, log)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot create discovery helper: %w", err)
+	}
+	return dynamicClient, discoveryHelper, nil
+}
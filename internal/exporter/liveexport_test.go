@@ -0,0 +1,130 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// testWorkloadResources groups the Deployments and StatefulSets by resource
+func testWorkloadResources(objects ...unstructured.Unstructured) []*groupResource {
+	deployments := &groupResource{APIGroup: "apps", APIVersion: "v1", APIResource: metav1.APIResource{Name: "deployments", Kind: "Deployment"}, objects: &unstructured.UnstructuredList{}}
+	statefulSets := &groupResource{APIGroup: "apps", APIVersion: "v1", APIResource: metav1.APIResource{Name: "statefulsets", Kind: "StatefulSet"}, objects: &unstructured.UnstructuredList{}}
+	for _, obj := range objects {
+		if obj.GetKind() == "StatefulSet" {
+			statefulSets.objects.Items = append(statefulSets.objects.Items, obj)
+		} else {
+			deployments.objects.Items = append(deployments.objects.Items, obj)
+		}
+	}
+	return []*groupResource{deployments, statefulSets}
+}
+
+func TestExportOptions_exportedObjects(t *testing.T) {
+	deployment := func(name string) unstructured.Unstructured {
+		obj := testDeployment(name, 1)
+		obj.SetNamespace("foo")
+		obj.Object["spec"].(map[string]interface{})["template"] = map[string]interface{}{
+			"spec": map[string]interface{}{
+				"containers": []interface{}{map[string]interface{}{"name": "app", "image": "quay.io/team/app:1"}},
+			},
+		}
+		return obj
+	}
+	statefulSet := func(name string) unstructured.Unstructured {
+		obj := testDeployment(name, 1)
+		obj.SetKind("StatefulSet")
+		obj.SetNamespace("foo")
+		obj.Object["spec"].(map[string]interface{})["volumeClaimTemplates"] = []interface{}{
+			map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "data"},
+				"spec":     map[string]interface{}{"storageClassName": "standard"},
+			},
+		}
+		return obj
+	}
+	imageMappings, err := parseImageMap([]string{"quay.io=mirror.example.com/quay"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	storageClasses, err := ParseStorageClassMap([]string{"standard=gp3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		options      *ExportOptions
+		namespace    string
+		image        string
+		storageClass string
+	}{
+		{
+			name:         "given --target-namespace, should move the live objects to the target namespace",
+			options:      &ExportOptions{targetNamespace: "bar"},
+			namespace:    "bar",
+			image:        "quay.io/team/app:1",
+			storageClass: "standard",
+		},
+		{
+			name:         "given --image-map, should repoint the images of the live objects",
+			options:      &ExportOptions{imageMappings: imageMappings},
+			namespace:    "foo",
+			image:        "mirror.example.com/quay/team/app:1",
+			storageClass: "standard",
+		},
+		{
+			name:         "given --storageclass-map, should rewrite the storage classes of the live objects",
+			options:      &ExportOptions{storageClasses: storageClasses},
+			namespace:    "foo",
+			image:        "quay.io/team/app:1",
+			storageClass: "gp3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := tt.options.exportedObjects("foo", testWorkloadResources(deployment("web"), statefulSet("db")))
+			if len(objects) != 2 {
+				t.Fatalf("exportedObjects() = %d objects, want 2", len(objects))
+			}
+			for _, obj := range objects {
+				if obj.GetNamespace() != tt.namespace {
+					t.Errorf("%s namespace = %s, want %s", obj.GetName(), obj.GetNamespace(), tt.namespace)
+				}
+			}
+			containers, _, _ := unstructured.NestedSlice(objects[0].Object, "spec", "template", "spec", "containers")
+			if got := containers[0].(map[string]interface{})["image"]; got != tt.image {
+				t.Errorf("image = %v, want %s", got, tt.image)
+			}
+			templates, _, _ := unstructured.NestedSlice(objects[1].Object, "spec", "volumeClaimTemplates")
+			if got, _, _ := unstructured.NestedString(templates[0].(map[string]interface{}), "spec", "storageClassName"); got != tt.storageClass {
+				t.Errorf("storage class = %s, want %s", got, tt.storageClass)
+			}
+		})
+	}
+}
+
+func Test_applyRecordedFlags(t *testing.T) {
+	flags := pflag.NewFlagSet("export", pflag.ContinueOnError)
+	output := flags.String("output", "yaml", "")
+	exclude := flags.StringSlice("exclude-resources", nil, "")
+	exportDir := flags.String("export-dir", "", "")
+	err := applyRecordedFlags(flags, map[string]string{"output": "json", "exclude-resources": "[events,pods]", "export-dir": "elsewhere", "removed-flag": "x"})
+	if err != nil {
+		t.Fatalf("applyRecordedFlags() error = %v", err)
+	}
+	if *output != "json" || !reflect.DeepEqual(*exclude, []string{"events", "pods"}) || *exportDir != "" {
+		t.Errorf("applyRecordedFlags() = output %q, exclude-resources %v, export-dir %q", *output, *exclude, *exportDir)
+	}
+	// set like on the command line, the config file does not override them
+	if !flags.Lookup("output").Changed {
+		t.Errorf("the recorded flags should be marked as set")
+	}
+	if _, err := ReadRunSummary(filepath.Join(t.TempDir(), "missing")); !os.IsNotExist(err) {
+		t.Errorf("readRunSummary() error = %v, want not exist", err)
+	}
+}
@@ -0,0 +1,175 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// slowestResources is the number of resources listed in the summary and printed at the end of the
+// run, the metrics file has all of them
+const slowestResources = 10
+
+// resourceMetrics measures the export of a resource, summed over the namespaces it was listed in
+type resourceMetrics struct {
+	Resource string `json:"resource"`
+	Version  string `json:"version"`
+	// Lists is the number of namespaces the resource was listed in, Failures the lists that failed
+	Lists    int   `json:"lists"`
+	Failures int   `json:"failures,omitempty"`
+	Pages    int   `json:"pages"`
+	Objects  int   `json:"objects"`
+	Bytes    int64 `json:"bytes"`
+	// Duration is the wall time spent listing the resource, DurationMs the same in milliseconds
+	Duration   string `json:"duration"`
+	DurationMs int64  `json:"durationMs"`
+
+	duration time.Duration
+}
+
+// exportMetrics collects the resource metrics of an export run. It is safe for concurrent use and
+// a nil exportMetrics records nothing.
+type exportMetrics struct {
+	mu        sync.Mutex
+	resources map[string]*resourceMetrics
+	// live also counts the lists and writes for --metrics-addr
+	live *liveMetrics
+}
+
+func newExportMetrics() *exportMetrics {
+	return &exportMetrics{resources: map[string]*resourceMetrics{}}
+}
+
+func (m *exportMetrics) resource(r *groupResource) *resourceMetrics {
+	key := GroupResourceName(r.APIGroup, r.APIResource.Name) + "/" + r.APIVersion
+	if m.resources[key] == nil {
+		m.resources[key] = &resourceMetrics{Resource: GroupResourceName(r.APIGroup, r.APIResource.Name), Version: r.APIVersion}
+	}
+	return m.resources[key]
+}
+
+// recordList records the listing of a resource in a namespace, its objects and pages being set on
+// the resource when the listing succeeded
+func (m *exportMetrics) recordList(r *groupResource, elapsed time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.live.recordList(r, err)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rm := m.resource(r)
+	rm.Lists++
+	rm.duration += elapsed
+	rm.Pages += r.pages
+	if err != nil {
+		rm.Failures++
+		return
+	}
+	if r.objects != nil {
+		rm.Objects += len(r.objects.Items)
+	}
+}
+
+// addBytes records bytes written for the objects of a resource
+func (m *exportMetrics) addBytes(r *groupResource, n int) {
+	if m == nil {
+		return
+	}
+	m.live.addWritten(r, n)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.resource(r).Bytes += int64(n)
+}
+
+// sorted returns the metrics of all the resources, the slowest first
+func (m *exportMetrics) sorted() []resourceMetrics {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	all := make([]resourceMetrics, 0, len(m.resources))
+	for _, rm := range m.resources {
+		rm.Duration = rm.duration.Round(time.Millisecond).String()
+		rm.DurationMs = rm.duration.Milliseconds()
+		all = append(all, *rm)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].duration != all[j].duration {
+			return all[i].duration > all[j].duration
+		}
+		if all[i].Resource != all[j].Resource {
+			return all[i].Resource < all[j].Resource
+		}
+		return all[i].Version < all[j].Version
+	})
+	return all
+}
+
+// slowest returns the n slowest resources to list
+func (m *exportMetrics) slowest(n int) []resourceMetrics {
+	all := m.sorted()
+	if len(all) > n {
+		all = all[:n]
+	}
+	return all
+}
+
+// print writes the slowest resources as a table
+func (m *exportMetrics) print(out io.Writer) {
+	slowest := m.slowest(slowestResources)
+	if len(slowest) == 0 {
+		return
+	}
+	table := tablewriter.NewWriter(out)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Resource", "Time", "Pages", "Objects", "Size"})
+	for _, rm := range slowest {
+		table.Append([]string{rm.Resource, rm.Duration, strconv.Itoa(rm.Pages), strconv.Itoa(rm.Objects), FormatBytes(int(rm.Bytes))})
+	}
+	table.Render()
+}
+
+// metricsReport is the content of the --metrics-file
+type metricsReport struct {
+	StartTime time.Time         `json:"startTime"`
+	Duration  string            `json:"duration"`
+	Flags     map[string]string `json:"flags"`
+	Resources []resourceMetrics `json:"resources"`
+}
+
+// write writes the metrics of all the resources to path as JSON
+func (m *exportMetrics) write(path string, start time.Time, flags map[string]string) error {
+	report := metricsReport{
+		StartTime: start.UTC(),
+		Duration:  time.Since(start).Round(time.Millisecond).String(),
+		Flags:     flags,
+		Resources: m.sorted(),
+	}
+	reportBytes, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(reportBytes, '\n'), 0600)
+}
+
+// pageCounter counts the pages of the List calls of a resource client
+type pageCounter struct {
+	dynamic.ResourceInterface
+	pages int
+}
+
+func (c *pageCounter) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	c.pages++
+	return c.ResourceInterface.List(ctx, opts)
+}
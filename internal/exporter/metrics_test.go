@@ -0,0 +1,108 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_exportMetrics(t *testing.T) {
+	lists, groups := testDiscovery(1)
+	client := slowDynamicClient{Interface: newTestDynamicClient(1, testConfigMaps(3)...), latency: 20 * time.Millisecond}
+	metrics := newExportMetrics()
+	resources, _ := resourceToExtract(context.Background(), "foo", metav1.ListOptions{}, false, false, &ResourceFilter{}, 2, 0, client, lists, groups, metrics, nil, nil, nil, testLogger())
+	if len(resources) != 1 {
+		t.Fatalf("resourceToExtract() = %d resources, want the configmaps", len(resources))
+	}
+
+	dir := t.TempDir()
+	w := &resourceWriter{resourceDir: dir, output: OutputYAML, layout: LayoutFlat, workers: 2, metrics: metrics, log: testLogger()}
+	if errs := w.writeResources(resources); len(errs) != 0 {
+		t.Fatalf("writeResources() errors = %v", errs)
+	}
+	written := int64(0)
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		info, _ := e.Info()
+		written += info.Size()
+	}
+
+	all := metrics.sorted()
+	if len(all) != 2 {
+		t.Fatalf("sorted() = %v, want the configmaps and the widgets", all)
+	}
+	var configMaps, widgets resourceMetrics
+	for _, rm := range all {
+		switch rm.Resource {
+		case "configmaps":
+			configMaps = rm
+		case "widgets0":
+			widgets = rm
+		}
+	}
+	if configMaps.Lists != 1 || configMaps.Pages != 1 || configMaps.Objects != 3 || configMaps.Failures != 0 {
+		t.Errorf("configmaps metrics = %+v, want 1 list of 1 page with 3 objects", configMaps)
+	}
+	if configMaps.Bytes != written || written == 0 {
+		t.Errorf("configmaps bytes = %d, want the %d bytes written", configMaps.Bytes, written)
+	}
+	if configMaps.DurationMs < 20 {
+		t.Errorf("configmaps duration = %dms, want at least the 20ms latency", configMaps.DurationMs)
+	}
+	// the empty resources are measured too, though they are not exported
+	if widgets.Lists != 1 || widgets.Pages != 1 || widgets.Objects != 0 {
+		t.Errorf("widgets metrics = %+v, want 1 list without objects", widgets)
+	}
+}
+
+func Test_exportMetrics_slowest(t *testing.T) {
+	metrics := newExportMetrics()
+	for i, name := range []string{"configmaps", "secrets", "pods"} {
+		r := &groupResource{APIVersion: "v1", APIResource: metav1.APIResource{Name: name}}
+		metrics.recordList(r, time.Duration(i+1)*time.Second, nil)
+	}
+	// a resource listed in several namespaces is counted once
+	metrics.recordList(&groupResource{APIVersion: "v1", APIResource: metav1.APIResource{Name: "configmaps"}}, 5*time.Second, nil)
+
+	slowest := metrics.slowest(2)
+	if len(slowest) != 2 || slowest[0].Resource != "configmaps" || slowest[0].Lists != 2 || slowest[0].Duration != "6s" || slowest[1].Resource != "pods" {
+		t.Errorf("slowest() = %+v, want configmaps then pods", slowest)
+	}
+
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	if err := metrics.write(path, time.Now(), map[string]string{"qps": "50"}); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := metricsReport{}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("the metrics file is not valid JSON: %v", err)
+	}
+	if len(report.Resources) != 3 || report.Resources[0].DurationMs != 6000 || report.Flags["qps"] != "50" {
+		t.Errorf("metrics file = %s", data)
+	}
+
+	out := &strings.Builder{}
+	metrics.print(out)
+	if !strings.Contains(out.String(), "configmaps") || !strings.Contains(out.String(), "6s") {
+		t.Errorf("print() = %s, want the slowest resources", out)
+	}
+}
+
+func Test_exportMetrics_nil(t *testing.T) {
+	var metrics *exportMetrics
+	metrics.recordList(&groupResource{}, time.Second, nil)
+	metrics.addBytes(&groupResource{}, 10)
+	if got := metrics.slowest(slowestResources); len(got) != 0 {
+		t.Errorf("slowest() = %v, want nothing from a nil exportMetrics", got)
+	}
+}
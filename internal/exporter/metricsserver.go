@@ -0,0 +1,224 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// throttleWaitMin is the wait for the client-side rate limiter from which a request is counted as
+// throttled, the limiter takes a little time even when a token is available
+const throttleWaitMin = time.Millisecond
+
+// metricsShutdownTimeout bounds the wait for the scrapes in progress when the export ends
+const metricsShutdownTimeout = 5 * time.Second
+
+// liveMetrics are the counters served on --metrics-addr during the export, summed over the
+// namespaces and the runs of a templated --export-dir. It is safe for concurrent use and a nil
+// liveMetrics records nothing.
+type liveMetrics struct {
+	mu sync.Mutex
+	// listed, written, bytes and failures are per group, version and resource
+	listed   map[gvrLabels]int64
+	written  map[gvrLabels]int64
+	bytes    map[gvrLabels]int64
+	failures map[gvrLabels]int64
+	// requests are the API requests per status code, "error" when no response was received
+	requests        map[string]int64
+	throttled       int64
+	throttleSeconds float64
+}
+
+// gvrLabels are the labels of the per resource metrics
+type gvrLabels struct {
+	group, version, resource string
+}
+
+func newLiveMetrics() *liveMetrics {
+	return &liveMetrics{
+		listed:   map[gvrLabels]int64{},
+		written:  map[gvrLabels]int64{},
+		bytes:    map[gvrLabels]int64{},
+		failures: map[gvrLabels]int64{},
+		requests: map[string]int64{},
+	}
+}
+
+func labelsOf(r *groupResource) gvrLabels {
+	return gvrLabels{group: r.APIGroup, version: r.APIVersion, resource: r.APIResource.Name}
+}
+
+// recordList counts the objects listed of a resource, or the failed list
+func (m *liveMetrics) recordList(r *groupResource, err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.failures[labelsOf(r)]++
+		return
+	}
+	if r.objects != nil {
+		m.listed[labelsOf(r)] += int64(len(r.objects.Items))
+	}
+}
+
+// addWritten counts an object written and its bytes
+func (m *liveMetrics) addWritten(r *groupResource, n int) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.written[labelsOf(r)]++
+	m.bytes[labelsOf(r)] += int64(n)
+}
+
+// addThrottled counts a request delayed by the client-side rate limiter
+func (m *liveMetrics) addThrottled(waited time.Duration) {
+	if m == nil || waited < throttleWaitMin {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.throttled++
+	m.throttleSeconds += waited.Seconds()
+}
+
+// countRequests wraps the transport of the export clients to count the API requests
+func (m *liveMetrics) countRequests(rt http.RoundTripper) http.RoundTripper {
+	return requestCounter{RoundTripper: rt, metrics: m}
+}
+
+type requestCounter struct {
+	http.RoundTripper
+	metrics *liveMetrics
+}
+
+func (c requestCounter) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := c.RoundTripper.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	c.metrics.mu.Lock()
+	c.metrics.requests[code]++
+	c.metrics.mu.Unlock()
+	return resp, err
+}
+
+// labelEscaper escapes the label values of the Prometheus text format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// write writes the metrics in the Prometheus text format, the series sorted by their labels
+func (m *liveMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counters := []struct {
+		name, help string
+		values     map[gvrLabels]int64
+	}{
+		{"kubectl_migrate_objects_listed_total", "Objects listed, per resource.", m.listed},
+		{"kubectl_migrate_objects_exported_total", "Objects written to the export, per resource.", m.written},
+		{"kubectl_migrate_exported_bytes_total", "Bytes written to the export before compression and encryption, per resource.", m.bytes},
+		{"kubectl_migrate_list_failures_total", "Lists of a resource in a namespace that failed, per resource.", m.failures},
+	}
+	for _, c := range counters {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		keys := make([]gvrLabels, 0, len(c.values))
+		for key := range c.values {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].group != keys[j].group {
+				return keys[i].group < keys[j].group
+			}
+			if keys[i].resource != keys[j].resource {
+				return keys[i].resource < keys[j].resource
+			}
+			return keys[i].version < keys[j].version
+		})
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s{group=\"%s\",version=\"%s\",resource=\"%s\"} %d\n", c.name, labelEscaper.Replace(key.group), labelEscaper.Replace(key.version), labelEscaper.Replace(key.resource), c.values[key])
+		}
+	}
+	fmt.Fprintf(w, "# HELP kubectl_migrate_api_requests_total API requests sent, per status code.\n# TYPE kubectl_migrate_api_requests_total counter\n")
+	for _, code := range sortedKeys(m.requests) {
+		fmt.Fprintf(w, "kubectl_migrate_api_requests_total{code=\"%s\"} %d\n", code, m.requests[code])
+	}
+	fmt.Fprintf(w, "# HELP kubectl_migrate_client_throttled_requests_total Requests delayed by the client-side rate limiter.\n# TYPE kubectl_migrate_client_throttled_requests_total counter\n")
+	fmt.Fprintf(w, "kubectl_migrate_client_throttled_requests_total %d\n", m.throttled)
+	fmt.Fprintf(w, "# HELP kubectl_migrate_client_throttle_seconds_total Time the requests waited for the client-side rate limiter.\n# TYPE kubectl_migrate_client_throttle_seconds_total counter\n")
+	fmt.Fprintf(w, "kubectl_migrate_client_throttle_seconds_total %s\n", strconv.FormatFloat(m.throttleSeconds, 'g', -1, 64))
+}
+
+// serveMetrics serves the metrics on /metrics and the Go profiles on /debug/pprof at addr until
+// ctx is done or stop is called, stop waits for the server to be shut down. The address listened
+// on is returned, addr may have the port 0.
+func serveMetrics(ctx context.Context, addr string, metrics *liveMetrics, log logrus.FieldLogger) (string, func(), error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", nil, fmt.Errorf("error listening on --metrics-addr %s: %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		metrics.write(w)
+	})
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Warnf("The metrics server stopped: %v", err)
+		}
+	}()
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+			<-done
+		})
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			stop()
+		case <-done:
+		}
+	}()
+	bound := listener.Addr().String()
+	log.Infof("Serving the metrics on http://%s/metrics and the profiles on http://%s/debug/pprof/", bound, bound)
+	return bound, stop, nil
+}
+
+// validateMetricsAddr checks --metrics-addr is a host:port address
+func (o *ExportOptions) validateMetricsAddr() error {
+	if o.metricsAddr == "" {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(o.metricsAddr); err != nil {
+		return fmt.Errorf("invalid --metrics-addr %q, must be a host:port address like localhost:9090: %v", o.metricsAddr, err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package exporter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_serveMetrics(t *testing.T) {
+	lists, groups := testDiscovery(1)
+	live := newLiveMetrics()
+	metrics := newExportMetrics()
+	metrics.live = live
+	resources, _ := resourceToExtract(context.Background(), "foo", metav1.ListOptions{}, false, false, &ResourceFilter{}, 2, 0, newTestDynamicClient(1, testConfigMaps(3)...), lists, groups, metrics, nil, nil, nil, testLogger())
+	w := &resourceWriter{resourceDir: t.TempDir(), output: OutputYAML, layout: LayoutFlat, workers: 2, metrics: metrics, log: testLogger()}
+	if errs := w.writeResources(resources); len(errs) != 0 {
+		t.Fatalf("writeResources() errors = %v", errs)
+	}
+	live.addThrottled(250 * time.Millisecond)
+	live.addThrottled(time.Microsecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr, stop, err := serveMetrics(ctx, "127.0.0.1:0", live, testLogger())
+	if err != nil {
+		t.Fatalf("serveMetrics() error = %v", err)
+	}
+	defer stop()
+
+	// the scrapes are counted as API requests, like the requests of the export clients
+	client := &http.Client{Transport: live.countRequests(http.DefaultTransport)}
+	resp, err := client.Get("http://" + addr + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	for _, want := range []string{
+		"# TYPE kubectl_migrate_objects_exported_total counter\n",
+		`kubectl_migrate_objects_listed_total{group="",version="v1",resource="configmaps"} 3` + "\n",
+		`kubectl_migrate_objects_exported_total{group="",version="v1",resource="configmaps"} 3` + "\n",
+		"kubectl_migrate_client_throttled_requests_total 1\n",
+		"kubectl_migrate_client_throttle_seconds_total 0.25\n",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("/metrics does not contain %q:\n%s", want, body)
+		}
+	}
+
+	resp, err = client.Get("http://" + addr + "/debug/pprof/")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /debug/pprof/ = %v, %v, want 200", resp, err)
+	}
+	resp.Body.Close()
+	var scrapes strings.Builder
+	live.write(&scrapes)
+	if !strings.Contains(scrapes.String(), `kubectl_migrate_api_requests_total{code="200"} 2`) {
+		t.Errorf("the requests were not counted:\n%s", scrapes.String())
+	}
+
+	// the server stops with the export context
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := http.Get("http://" + addr + "/metrics"); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the metrics server is still serving after the export context is done")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func Test_liveMetrics_nil(t *testing.T) {
+	var live *liveMetrics
+	live.recordList(&groupResource{}, nil)
+	live.addWritten(&groupResource{}, 10)
+	live.addThrottled(time.Second)
+}
+
+func TestExportOptions_validateMetricsAddr(t *testing.T) {
+	for addr, wantErr := range map[string]bool{"": false, "localhost:9090": false, ":9090": false, "9090": true} {
+		o := &ExportOptions{metricsAddr: addr}
+		if err := o.validateMetricsAddr(); (err != nil) != wantErr {
+			t.Errorf("validateMetricsAddr(%q) error = %v, wantErr %v", addr, err, wantErr)
+		}
+	}
+}
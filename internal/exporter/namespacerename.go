@@ -0,0 +1,146 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const namespaceWarningsFile = "namespace-warnings.json"
+
+// namespaceWarning is a field of an exported object mentioning its source namespace, left
+// unchanged by --target-namespace
+type namespaceWarning struct {
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Field     string `json:"field"`
+}
+
+// namespaceRenamer moves the exported objects to the --target-namespace. The namespace is
+// rewritten where the API defines a namespace field or a service DNS name: the object metadata,
+// the RoleBinding subjects, the webhook services and the externalName of the Services, which
+// Ingresses use to reach the Services of other namespaces. The other fields mentioning the source
+// namespace are reported in namespace-warnings.json, their meaning is unknown. A nil renamer
+// leaves the objects unchanged.
+type namespaceRenamer struct {
+	target   string
+	warnings []namespaceWarning
+}
+
+// NewNamespaceRenamer returns the renamer to the target namespace, nil without one
+func NewNamespaceRenamer(target string) *namespaceRenamer {
+	if target == "" {
+		return nil
+	}
+	return &namespaceRenamer{target: target, warnings: []namespaceWarning{}}
+}
+
+// rename moves the objects of the resources from the source namespace to the target one in place
+// and returns the number of fields left unchanged that mention the source namespace
+func (n *namespaceRenamer) rename(source string, resources []*groupResource) int {
+	if n == nil || source == n.target {
+		return 0
+	}
+	warnings := len(n.warnings)
+	mention := namespaceMention(source)
+	for _, r := range resources {
+		if r == nil || r.objects == nil {
+			continue
+		}
+		for i := range r.objects.Items {
+			obj := &r.objects.Items[i]
+			n.renameObject(source, obj)
+			n.warn(source, obj, mention)
+		}
+	}
+	return len(n.warnings) - warnings
+}
+
+func (n *namespaceRenamer) renameObject(source string, obj *unstructured.Unstructured) {
+	if obj.GetNamespace() == source {
+		obj.SetNamespace(n.target)
+	}
+	rename := func(m map[string]interface{}, field string) {
+		if m[field] == source {
+			m[field] = n.target
+		}
+	}
+	switch obj.GetKind() {
+	case "Namespace":
+		if obj.GetName() == source {
+			obj.SetName(n.target)
+			if labels := obj.GetLabels(); labels[namespaceNameLabel] == source {
+				labels[namespaceNameLabel] = n.target
+				obj.SetLabels(labels)
+			}
+		}
+	case "RoleBinding", "ClusterRoleBinding":
+		for _, subject := range nestedMaps(obj.Object, []string{"subjects", "*"}) {
+			rename(subject, "namespace")
+		}
+	case "ValidatingWebhookConfiguration", "MutatingWebhookConfiguration":
+		for _, service := range nestedMaps(obj.Object, []string{"webhooks", "*", "clientConfig", "service"}) {
+			rename(service, "namespace")
+		}
+	case "Service":
+		spec, _ := obj.Object["spec"].(map[string]interface{})
+		if externalName, ok := spec["externalName"].(string); ok {
+			spec["externalName"] = renameServiceHost(externalName, source, n.target)
+		}
+	}
+}
+
+// renameServiceHost renames the namespace of a service DNS name, e.g. web.foo.svc.cluster.local
+func renameServiceHost(host string, source string, target string) string {
+	labels := strings.Split(host, ".")
+	if len(labels) >= 3 && labels[1] == source && labels[2] == "svc" {
+		labels[1] = target
+	}
+	return strings.Join(labels, ".")
+}
+
+// namespaceMention matches the namespace as a whole word of a free-form value, e.g. in
+// web.foo.svc or foo/config but not in foo-config
+func namespaceMention(namespace string) *regexp.Regexp {
+	return regexp.MustCompile(`(^|[^a-z0-9-])` + regexp.QuoteMeta(namespace) + `($|[^a-z0-9-])`)
+}
+
+// warn records the fields of the object still mentioning the source namespace
+func (n *namespaceRenamer) warn(source string, obj *unstructured.Unstructured, mention *regexp.Regexp) {
+	var walk func(value interface{}, path string)
+	walk = func(value interface{}, path string) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for _, key := range sortedKeys(v) {
+				if path == "metadata" && key == "managedFields" {
+					continue
+				}
+				walk(v[key], strings.TrimPrefix(path+"."+key, "."))
+			}
+		case []interface{}:
+			for i, item := range v {
+				walk(item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		case string:
+			if mention.MatchString(v) {
+				n.warnings = append(n.warnings, namespaceWarning{Namespace: source, Kind: obj.GetKind(), Name: obj.GetName(), Field: path})
+			}
+		}
+	}
+	walk(obj.Object, "")
+}
+
+// write writes namespace-warnings.json, the values are left out as they may be sensitive
+func (n *namespaceRenamer) write(exportDir string) error {
+	warningBytes, err := json.MarshalIndent(n.warnings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(exportDir, namespaceWarningsFile), append(warningBytes, '\n'), 0600)
+}
@@ -0,0 +1,112 @@
+package exporter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_namespaceRenamer(t *testing.T) {
+	object := func(kind string, name string, namespace string, fields map[string]interface{}) unstructured.Unstructured {
+		obj := testOwnedObject(kind, name)
+		obj.SetNamespace(namespace)
+		for k, v := range fields {
+			obj.Object[k] = v
+		}
+		return obj
+	}
+	namespace := object("Namespace", "foo", "", nil)
+	namespace.SetLabels(map[string]string{namespaceNameLabel: "foo", "team": "a"})
+	resources := []*groupResource{{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+		object("RoleBinding", "reader", "foo", map[string]interface{}{"subjects": []interface{}{
+			map[string]interface{}{"kind": "ServiceAccount", "name": "web", "namespace": "foo"},
+			map[string]interface{}{"kind": "ServiceAccount", "name": "operator", "namespace": "external-secrets"},
+		}}),
+		object("ClusterRoleBinding", "foo-view", "", map[string]interface{}{"subjects": []interface{}{
+			map[string]interface{}{"kind": "ServiceAccount", "name": "web", "namespace": "foo"},
+		}}),
+		object("Service", "api", "foo", map[string]interface{}{"spec": map[string]interface{}{
+			"type": "ExternalName", "externalName": "api.foo.svc.cluster.local",
+		}}),
+		object("ValidatingWebhookConfiguration", "policy", "", map[string]interface{}{"webhooks": []interface{}{
+			map[string]interface{}{"clientConfig": map[string]interface{}{"service": map[string]interface{}{"name": "policy", "namespace": "foo"}}},
+		}}),
+		object("ConfigMap", "web-config", "foo", map[string]interface{}{"data": map[string]interface{}{
+			"DATABASE_URL": "postgres://db.foo.svc:5432/orders",
+			"PREFIX":       "foo-config",
+		}}),
+	}}}, {objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{namespace}}}}
+
+	r := NewNamespaceRenamer("bar")
+	if got := r.rename("foo", resources); got != 1 {
+		t.Errorf("rename() = %d warnings, want 1", got)
+	}
+
+	items := resources[0].objects.Items
+	checks := []struct {
+		obj  unstructured.Unstructured
+		path []string
+		want interface{}
+	}{
+		{items[0], []string{"metadata", "namespace"}, "bar"},
+		{items[0], []string{"subjects"}, []interface{}{
+			map[string]interface{}{"kind": "ServiceAccount", "name": "web", "namespace": "bar"},
+			map[string]interface{}{"kind": "ServiceAccount", "name": "operator", "namespace": "external-secrets"},
+		}},
+		{items[1], []string{"metadata", "namespace"}, nil},
+		{items[1], []string{"subjects"}, []interface{}{map[string]interface{}{"kind": "ServiceAccount", "name": "web", "namespace": "bar"}}},
+		{items[2], []string{"spec", "externalName"}, "api.bar.svc.cluster.local"},
+		{items[3], []string{"webhooks"}, []interface{}{
+			map[string]interface{}{"clientConfig": map[string]interface{}{"service": map[string]interface{}{"name": "policy", "namespace": "bar"}}},
+		}},
+		{items[4], []string{"data", "DATABASE_URL"}, "postgres://db.foo.svc:5432/orders"},
+		{resources[1].objects.Items[0], []string{"metadata", "name"}, "bar"},
+		{resources[1].objects.Items[0], []string{"metadata", "labels"}, map[string]interface{}{namespaceNameLabel: "bar", "team": "a"}},
+	}
+	for _, c := range checks {
+		got, _, _ := unstructured.NestedFieldNoCopy(c.obj.Object, c.path...)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s %s %v = %v, want %v", c.obj.GetKind(), c.obj.GetName(), c.path, got, c.want)
+		}
+	}
+
+	dir := t.TempDir()
+	if err := r.write(dir); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	warningBytes, err := os.ReadFile(filepath.Join(dir, namespaceWarningsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	warnings := []namespaceWarning{}
+	if err := json.Unmarshal(warningBytes, &warnings); err != nil {
+		t.Fatalf("%s does not parse: %v", namespaceWarningsFile, err)
+	}
+	want := []namespaceWarning{{Namespace: "foo", Kind: "ConfigMap", Name: "web-config", Field: "data.DATABASE_URL"}}
+	if !reflect.DeepEqual(warnings, want) {
+		t.Errorf("warnings = %+v, want %+v", warnings, want)
+	}
+}
+
+func Test_renameServiceHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "api.foo.svc.cluster.local", want: "api.bar.svc.cluster.local"},
+		{host: "api.foo.svc", want: "api.bar.svc"},
+		{host: "api.foo.example.com", want: "api.foo.example.com"},
+		{host: "foo.svc", want: "foo.svc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := renameServiceHost(tt.host, "foo", "bar"); got != tt.want {
+				t.Errorf("renameServiceHost() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
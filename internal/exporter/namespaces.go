@@ -0,0 +1,83 @@
+package exporter
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+var systemNamespaces = []string{"kube-system", "kube-public", "kube-node-lease"}
+
+var systemNamespacePrefixes = []string{"openshift-"}
+
+func isSystemNamespace(namespace string) bool {
+	for _, n := range systemNamespaces {
+		if namespace == n {
+			return true
+		}
+	}
+	for _, prefix := range systemNamespacePrefixes {
+		if strings.HasPrefix(namespace, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// listNamespaces returns the names of all namespaces of the cluster, skipping the system ones
+// unless includeSystem is set
+func listNamespaces(client kubernetes.Interface, includeSystem bool, log logrus.FieldLogger) ([]string, error) {
+	list, err := client.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	namespaces := []string{}
+	for _, ns := range list.Items {
+		if !includeSystem && isSystemNamespace(ns.Name) {
+			log.Debugf("skipping system namespace %s", ns.Name)
+			continue
+		}
+		namespaces = append(namespaces, ns.Name)
+	}
+	return namespaces, nil
+}
+
+// namespaceExists reports whether the namespace is still present, so namespaces deleted while
+// an --all-namespaces run is in progress can be skipped. Errors other than NotFound are
+// reported as existing and left for the export itself to surface.
+func namespaceExists(client kubernetes.Interface, namespace string) bool {
+	_, err := client.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{})
+	return !apierrors.IsNotFound(err)
+}
+
+const namespaceFile = "namespace"
+
+var NamespacesGVR = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+
+// namespaceResource returns the Namespace object of the namespace, with its labels and annotations
+// like the pod security levels, so that it can be created first on the target cluster. A minimal
+// manifest is synthesized when it cannot be read, synthesized is then set.
+func namespaceResource(ctx context.Context, dynamicClient dynamic.Interface, namespace string, log logrus.FieldLogger) (r *groupResource, synthesized bool) {
+	obj, err := dynamicClient.Resource(NamespacesGVR).Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		log.Warnf("cannot get the namespace %s, writing a manifest without its labels and annotations: %v", namespace, err)
+		obj = &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("Namespace")
+		obj.SetName(namespace)
+		synthesized = true
+	}
+	return &groupResource{
+		APIVersion:      "v1",
+		APIGroupVersion: "v1",
+		APIResource:     metav1.APIResource{Name: "namespaces", Kind: "Namespace"},
+		objects:         &unstructured.UnstructuredList{Items: []unstructured.Unstructured{*obj}},
+	}, synthesized
+}
@@ -0,0 +1,108 @@
+package exporter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_listNamespaces(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-node-lease"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "openshift-monitoring"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "my-openshift-app"}},
+	)
+	tests := []struct {
+		name          string
+		includeSystem bool
+		want          []string
+	}{
+		{
+			name: "system namespaces are skipped by default",
+			want: []string{"default", "my-openshift-app"},
+		},
+		{
+			name:          "system namespaces are included on request",
+			includeSystem: true,
+			want:          []string{"default", "kube-node-lease", "kube-system", "my-openshift-app", "openshift-monitoring"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := listNamespaces(client, tt.includeSystem, testLogger())
+			if err != nil {
+				t.Fatalf("listNamespaces() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("listNamespaces() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_namespaceResource(t *testing.T) {
+	namespace := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata": map[string]interface{}{
+			"name":   "foo",
+			"labels": map[string]interface{}{"pod-security.kubernetes.io/enforce": "restricted"},
+		},
+	}}
+	tests := []struct {
+		name            string
+		objects         []runtime.Object
+		wantSynthesized bool
+		wantLabels      map[string]string
+	}{
+		{
+			name:       "the Namespace object is exported with its labels",
+			objects:    []runtime.Object{namespace},
+			wantLabels: map[string]string{"pod-security.kubernetes.io/enforce": "restricted"},
+		},
+		{
+			name:            "a minimal manifest is synthesized when the Namespace cannot be read",
+			wantSynthesized: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), tt.objects...)
+			r, synthesized := namespaceResource(context.Background(), client, "foo", testLogger())
+			if synthesized != tt.wantSynthesized {
+				t.Errorf("namespaceResource() synthesized = %v, want %v", synthesized, tt.wantSynthesized)
+			}
+			obj := r.objects.Items[0]
+			if obj.GetKind() != "Namespace" || obj.GetName() != "foo" {
+				t.Errorf("namespaceResource() = %s/%s, want Namespace/foo", obj.GetKind(), obj.GetName())
+			}
+			if !reflect.DeepEqual(obj.GetLabels(), tt.wantLabels) {
+				t.Errorf("namespaceResource() labels = %v, want %v", obj.GetLabels(), tt.wantLabels)
+			}
+		})
+	}
+}
+
+func Test_resourceWriter_writeNamespace(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	namespace, _ := namespaceResource(context.Background(), client, "foo", testLogger())
+	dir := t.TempDir()
+	w := &resourceWriter{resourceDir: dir, output: OutputYAML, layout: LayoutFlat, workers: 1, namespace: namespace, log: testLogger()}
+	if errs := w.writeResources(nil); len(errs) > 0 {
+		t.Fatalf("writeResources() errors = %v", errs)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "namespace.yaml")); err != nil {
+		t.Errorf("namespace.yaml should be written: %v", err)
+	}
+}
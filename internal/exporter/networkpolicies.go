@@ -0,0 +1,307 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	podNetwork     = "pod"
+	serviceNetwork = "service"
+)
+
+var (
+	openShiftNetworkGVR = schema.GroupVersionResource{Group: "config.openshift.io", Version: "v1", Resource: "networks"}
+	serviceCIDRGVR      = schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "servicecidrs"}
+	nodeGVR             = schema.GroupVersionResource{Version: "v1", Resource: "nodes"}
+	configMapGVR        = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+)
+
+// clusterNetworks are the pod and service ranges of a cluster
+type clusterNetworks struct {
+	pod     []netip.Prefix
+	service []netip.Prefix
+}
+
+// collectClusterNetworks reads the pod and service ranges of the source cluster, from the first of
+// the OpenShift network configuration, the kubeadm configuration, the ServiceCIDRs and the pod
+// ranges of the nodes that has them. Every source is best effort, the ranges not found are left
+// empty.
+func collectClusterNetworks(ctx context.Context, client dynamic.Interface, log logrus.FieldLogger) clusterNetworks {
+	networks := clusterNetworks{}
+	if network, err := client.Resource(openShiftNetworkGVR).Get(ctx, "cluster", metav1.GetOptions{}); err == nil {
+		clusterNetwork, _, _ := unstructured.NestedSlice(network.Object, "status", "clusterNetwork")
+		for _, entry := range clusterNetwork {
+			networks.pod = appendPrefixes(networks.pod, nestedStrings(entry, []string{"cidr"})...)
+		}
+		serviceNetwork, _, _ := unstructured.NestedStringSlice(network.Object, "status", "serviceNetwork")
+		networks.service = appendPrefixes(networks.service, serviceNetwork...)
+	}
+	if len(networks.pod) == 0 || len(networks.service) == 0 {
+		if config, err := client.Resource(configMapGVR).Namespace("kube-system").Get(ctx, "kubeadm-config", metav1.GetOptions{}); err == nil {
+			data, _, _ := unstructured.NestedString(config.Object, "data", "ClusterConfiguration")
+			var clusterConfig struct {
+				Networking struct {
+					PodSubnet     string `json:"podSubnet"`
+					ServiceSubnet string `json:"serviceSubnet"`
+				} `json:"networking"`
+			}
+			if err := yaml.Unmarshal([]byte(data), &clusterConfig); err == nil {
+				if len(networks.pod) == 0 {
+					networks.pod = appendPrefixes(nil, strings.Split(clusterConfig.Networking.PodSubnet, ",")...)
+				}
+				if len(networks.service) == 0 {
+					networks.service = appendPrefixes(nil, strings.Split(clusterConfig.Networking.ServiceSubnet, ",")...)
+				}
+			}
+		}
+	}
+	if len(networks.service) == 0 {
+		if list, err := client.Resource(serviceCIDRGVR).List(ctx, metav1.ListOptions{}); err == nil {
+			for _, serviceCIDR := range list.Items {
+				cidrs, _, _ := unstructured.NestedStringSlice(serviceCIDR.Object, "spec", "cidrs")
+				networks.service = appendPrefixes(networks.service, cidrs...)
+			}
+		}
+	}
+	// the nodes only know their own part of the pod network, it is approximated by the smallest
+	// range holding all of them
+	if len(networks.pod) == 0 {
+		if list, err := client.Resource(nodeGVR).List(ctx, metav1.ListOptions{}); err == nil {
+			nodeCIDRs := []netip.Prefix{}
+			for _, node := range list.Items {
+				cidrs, _, _ := unstructured.NestedStringSlice(node.Object, "spec", "podCIDRs")
+				if len(cidrs) == 0 {
+					cidr, _, _ := unstructured.NestedString(node.Object, "spec", "podCIDR")
+					cidrs = []string{cidr}
+				}
+				nodeCIDRs = appendPrefixes(nodeCIDRs, cidrs...)
+			}
+			networks.pod = commonPrefixes(nodeCIDRs)
+		}
+	}
+	if len(networks.pod) == 0 && len(networks.service) == 0 {
+		log.Debugf("The pod and service networks of the cluster could not be read, the ipBlocks of the NetworkPolicies are not checked")
+	}
+	return networks
+}
+
+// appendPrefixes appends the valid CIDRs of values to prefixes, masked and without duplicates
+func appendPrefixes(prefixes []netip.Prefix, values ...string) []netip.Prefix {
+	for _, value := range values {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		prefix = prefix.Masked()
+		duplicate := false
+		for _, p := range prefixes {
+			duplicate = duplicate || p == prefix
+		}
+		if !duplicate {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// commonPrefixes returns, per IP family, the smallest range holding all the prefixes
+func commonPrefixes(prefixes []netip.Prefix) []netip.Prefix {
+	common := []netip.Prefix{}
+	for _, is4 := range []bool{true, false} {
+		var merged netip.Prefix
+		for _, p := range prefixes {
+			if p.Addr().Is4() != is4 {
+				continue
+			}
+			if !merged.IsValid() {
+				merged = p
+				continue
+			}
+			for bits := merged.Bits(); bits >= 0; bits-- {
+				candidate, _ := merged.Addr().Prefix(bits)
+				if bits <= p.Bits() && candidate.Contains(p.Addr()) {
+					merged = candidate
+					break
+				}
+			}
+		}
+		if merged.IsValid() {
+			common = append(common, merged)
+		}
+	}
+	return common
+}
+
+// prefixStrings returns the prefixes as CIDRs, for cluster-info.json
+func prefixStrings(prefixes []netip.Prefix) []string {
+	cidrs := []string{}
+	for _, p := range prefixes {
+		cidrs = append(cidrs, p.String())
+	}
+	return cidrs
+}
+
+// parseTargetCIDRs parses the --target-pod-cidr or --target-service-cidr values, at most one per
+// IP family
+func parseTargetCIDRs(flag string, values []string) ([]netip.Prefix, error) {
+	prefixes := []netip.Prefix{}
+	families := map[bool]bool{}
+	for _, value := range values {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s %q, must be a CIDR like 10.96.0.0/12: %v", flag, value, err)
+		}
+		if families[prefix.Addr().Is4()] {
+			return nil, fmt.Errorf("invalid --%s %q, a single range is accepted per IP family", flag, value)
+		}
+		families[prefix.Addr().Is4()] = true
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// cidrWarning is an ipBlock of a NetworkPolicy within the pod or service network of the source
+// cluster, which will likely not match the same pods or services on the target cluster
+type cidrWarning struct {
+	Policy string `json:"policy"`
+	CIDR   string `json:"cidr"`
+	// Network is pod or service, SourceCIDR the range of the source cluster the block is in
+	Network    string `json:"network"`
+	SourceCIDR string `json:"sourceCIDR"`
+	// RewrittenTo is the block exported instead with --target-pod-cidr or --target-service-cidr
+	RewrittenTo string `json:"rewrittenTo,omitempty"`
+}
+
+func (w cidrWarning) String() string {
+	s := fmt.Sprintf("%s ipBlock %s is in the %s network %s of the source cluster", w.Policy, w.CIDR, w.Network, w.SourceCIDR)
+	if w.RewrittenTo != "" {
+		s += ", rewritten to " + w.RewrittenTo
+	}
+	return s
+}
+
+// cidrChecker finds the ipBlocks of the NetworkPolicies in the pod and service networks of the
+// source cluster, and moves them to the networks of the target cluster when they are given. A nil
+// cidrChecker checks nothing.
+type cidrChecker struct {
+	source clusterNetworks
+	target clusterNetworks
+}
+
+// newCIDRChecker returns the checker of the source networks, nil when none is known
+func newCIDRChecker(source clusterNetworks, targetPod, targetService []netip.Prefix) *cidrChecker {
+	if len(source.pod) == 0 && len(source.service) == 0 {
+		return nil
+	}
+	return &cidrChecker{source: source, target: clusterNetworks{pod: targetPod, service: targetService}}
+}
+
+// check checks, and rewrites in place, the ipBlocks of the NetworkPolicies of the resources
+func (c *cidrChecker) check(resources []*groupResource) []cidrWarning {
+	if c == nil {
+		return nil
+	}
+	warnings := []cidrWarning{}
+	for _, r := range resources {
+		if r.objects == nil || r.APIResource.Kind != "NetworkPolicy" {
+			continue
+		}
+		for _, obj := range r.objects.Items {
+			policy := obj.GetKind() + "/" + obj.GetName()
+			for _, rules := range [][]string{{"spec", "ingress"}, {"spec", "egress"}} {
+				peersField := "from"
+				if rules[1] == "egress" {
+					peersField = "to"
+				}
+				list, _, _ := unstructured.NestedFieldNoCopy(obj.Object, rules...)
+				items, _ := list.([]interface{})
+				for _, rule := range items {
+					rule, _ := rule.(map[string]interface{})
+					peers, _ := rule[peersField].([]interface{})
+					for _, peer := range peers {
+						peer, _ := peer.(map[string]interface{})
+						ipBlock, ok := peer["ipBlock"].(map[string]interface{})
+						if !ok {
+							continue
+						}
+						warnings = append(warnings, c.checkBlock(policy, ipBlock)...)
+					}
+				}
+			}
+		}
+	}
+	return warnings
+}
+
+// checkBlock checks the cidr and the except ranges of an ipBlock
+func (c *cidrChecker) checkBlock(policy string, ipBlock map[string]interface{}) []cidrWarning {
+	warnings := []cidrWarning{}
+	check := func(cidr string) string {
+		block, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return cidr
+		}
+		for _, network := range []struct {
+			name           string
+			source, target []netip.Prefix
+		}{{podNetwork, c.source.pod, c.target.pod}, {serviceNetwork, c.source.service, c.target.service}} {
+			for _, source := range network.source {
+				// the broader blocks, like 0.0.0.0/0, are meant to match whatever the networks
+				if block.Bits() < source.Bits() || !source.Contains(block.Addr()) {
+					continue
+				}
+				warning := cidrWarning{Policy: policy, CIDR: cidr, Network: network.name, SourceCIDR: source.String()}
+				for _, target := range network.target {
+					if rewritten, ok := translatePrefix(block, source, target); ok {
+						warning.RewrittenTo = rewritten.String()
+						cidr = warning.RewrittenTo
+					}
+				}
+				warnings = append(warnings, warning)
+				return cidr
+			}
+		}
+		return cidr
+	}
+	if cidr, ok := ipBlock["cidr"].(string); ok {
+		ipBlock["cidr"] = check(cidr)
+	}
+	if except, ok := ipBlock["except"].([]interface{}); ok {
+		for i, e := range except {
+			if cidr, ok := e.(string); ok {
+				except[i] = check(cidr)
+			}
+		}
+	}
+	return warnings
+}
+
+// translatePrefix moves block from the source range to the target range at the same offset, e.g.
+// 10.244.3.0/24 of 10.244.0.0/16 becomes 10.128.3.0/24 of 10.128.0.0/14. The block must be in the
+// source range and fit in the target range of the same IP family.
+func translatePrefix(block, source, target netip.Prefix) (netip.Prefix, bool) {
+	if block.Addr().Is4() != target.Addr().Is4() || block.Bits() < source.Bits() || !source.Contains(block.Addr()) || block.Bits() < target.Bits() {
+		return netip.Prefix{}, false
+	}
+	addrBits := block.Addr().BitLen()
+	offset := new(big.Int).Sub(new(big.Int).SetBytes(block.Masked().Addr().AsSlice()), new(big.Int).SetBytes(source.Addr().AsSlice()))
+	// the block must end in the target range
+	end := new(big.Int).Add(offset, new(big.Int).Lsh(big.NewInt(1), uint(addrBits-block.Bits())))
+	if end.Cmp(new(big.Int).Lsh(big.NewInt(1), uint(addrBits-target.Bits()))) > 0 {
+		return netip.Prefix{}, false
+	}
+	translated := new(big.Int).Add(new(big.Int).SetBytes(target.Addr().AsSlice()), offset).FillBytes(make([]byte, addrBits/8))
+	addr, _ := netip.AddrFromSlice(translated)
+	return netip.PrefixFrom(addr, block.Bits()), true
+}
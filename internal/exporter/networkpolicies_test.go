@@ -0,0 +1,170 @@
+package exporter
+
+import (
+	"context"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+// testNetworkPolicy returns a NetworkPolicy allowing the ingress from the ipBlock cidr except the
+// ranges, and the egress to anywhere
+func testNetworkPolicy(name string, cidr string, except ...interface{}) unstructured.Unstructured {
+	obj := testOwnedObject("NetworkPolicy", name)
+	obj.Object["spec"] = map[string]interface{}{
+		"ingress": []interface{}{map[string]interface{}{"from": []interface{}{
+			map[string]interface{}{"ipBlock": map[string]interface{}{"cidr": cidr, "except": except}},
+			map[string]interface{}{"podSelector": map[string]interface{}{}},
+		}}},
+		"egress": []interface{}{map[string]interface{}{"to": []interface{}{
+			map[string]interface{}{"ipBlock": map[string]interface{}{"cidr": "0.0.0.0/0"}},
+		}}},
+	}
+	return obj
+}
+
+func Test_cidrChecker_check(t *testing.T) {
+	source := clusterNetworks{
+		pod:     []netip.Prefix{netip.MustParsePrefix("10.244.0.0/16")},
+		service: []netip.Prefix{netip.MustParsePrefix("10.96.0.0/12")},
+	}
+	tests := []struct {
+		name         string
+		policy       unstructured.Unstructured
+		targetPod    []netip.Prefix
+		wantWarnings []cidrWarning
+		wantIngress  string
+		wantExcept   []interface{}
+	}{
+		{
+			name:   "given an ipBlock outside of the cluster networks, should not warn",
+			policy: testNetworkPolicy("office", "192.168.10.0/24"),
+		},
+		{
+			name:   "given an ipBlock in the pod network, should warn",
+			policy: testNetworkPolicy("pods", "10.244.3.0/24"),
+			wantWarnings: []cidrWarning{
+				{Policy: "NetworkPolicy/pods", CIDR: "10.244.3.0/24", Network: podNetwork, SourceCIDR: "10.244.0.0/16"},
+			},
+		},
+		{
+			name:      "given a target pod network, should move the ipBlock and its exceptions",
+			policy:    testNetworkPolicy("pods", "10.244.3.0/24", "10.244.3.128/25", "10.97.0.1/32"),
+			targetPod: []netip.Prefix{netip.MustParsePrefix("10.128.0.0/14")},
+			wantWarnings: []cidrWarning{
+				{Policy: "NetworkPolicy/pods", CIDR: "10.244.3.0/24", Network: podNetwork, SourceCIDR: "10.244.0.0/16", RewrittenTo: "10.128.3.0/24"},
+				{Policy: "NetworkPolicy/pods", CIDR: "10.244.3.128/25", Network: podNetwork, SourceCIDR: "10.244.0.0/16", RewrittenTo: "10.128.3.128/25"},
+				{Policy: "NetworkPolicy/pods", CIDR: "10.97.0.1/32", Network: serviceNetwork, SourceCIDR: "10.96.0.0/12"},
+			},
+			wantIngress: "10.128.3.0/24",
+			wantExcept:  []interface{}{"10.128.3.128/25", "10.97.0.1/32"},
+		},
+		{
+			name:      "given a target pod network too small for the ipBlock, should only warn",
+			policy:    testNetworkPolicy("pods", "10.244.200.0/24"),
+			targetPod: []netip.Prefix{netip.MustParsePrefix("10.128.0.0/20")},
+			wantWarnings: []cidrWarning{
+				{Policy: "NetworkPolicy/pods", CIDR: "10.244.200.0/24", Network: podNetwork, SourceCIDR: "10.244.0.0/16"},
+			},
+			wantIngress: "10.244.200.0/24",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resources := []*groupResource{{
+				APIGroup:    "networking.k8s.io",
+				APIVersion:  "v1",
+				APIResource: metav1.APIResource{Name: "networkpolicies", Kind: "NetworkPolicy"},
+				objects:     &unstructured.UnstructuredList{Items: []unstructured.Unstructured{tt.policy}},
+			}}
+			got := newCIDRChecker(source, tt.targetPod, nil).check(resources)
+			if len(got) != 0 || len(tt.wantWarnings) != 0 {
+				if !reflect.DeepEqual(got, tt.wantWarnings) {
+					t.Errorf("check() = %v, want %v", got, tt.wantWarnings)
+				}
+			}
+			if tt.wantIngress == "" {
+				return
+			}
+			peers, _, _ := unstructured.NestedSlice(tt.policy.Object, "spec", "ingress")
+			ipBlock := peers[0].(map[string]interface{})["from"].([]interface{})[0].(map[string]interface{})["ipBlock"].(map[string]interface{})
+			if ipBlock["cidr"] != tt.wantIngress {
+				t.Errorf("ipBlock cidr = %v, want %v", ipBlock["cidr"], tt.wantIngress)
+			}
+			if tt.wantExcept != nil && !reflect.DeepEqual(ipBlock["except"], tt.wantExcept) {
+				t.Errorf("ipBlock except = %v, want %v", ipBlock["except"], tt.wantExcept)
+			}
+		})
+	}
+
+	if newCIDRChecker(clusterNetworks{}, nil, nil).check(nil) != nil {
+		t.Errorf("check() without source networks should check nothing")
+	}
+}
+
+func Test_translatePrefix(t *testing.T) {
+	tests := []struct {
+		block, source, target string
+		want                  string
+	}{
+		{block: "10.244.0.0/16", source: "10.244.0.0/16", target: "10.128.0.0/14", want: "10.128.0.0/16"},
+		{block: "10.244.255.7/32", source: "10.244.0.0/16", target: "172.16.0.0/16", want: "172.16.255.7/32"},
+		{block: "fd00:10:244:3::/64", source: "fd00:10:244::/48", target: "fd00:99::/48", want: "fd00:99:0:3::/64"},
+		// the block is larger than the target network, or not in the same family
+		{block: "10.244.0.0/16", source: "10.244.0.0/16", target: "10.128.0.0/20"},
+		{block: "10.244.1.0/24", source: "10.244.0.0/16", target: "fd00:99::/48"},
+	}
+	for _, tt := range tests {
+		got, ok := translatePrefix(netip.MustParsePrefix(tt.block), netip.MustParsePrefix(tt.source), netip.MustParsePrefix(tt.target))
+		if (tt.want == "") == ok || (ok && got.String() != tt.want) {
+			t.Errorf("translatePrefix(%s, %s, %s) = %s, %v, want %q", tt.block, tt.source, tt.target, got, ok, tt.want)
+		}
+	}
+}
+
+func Test_collectClusterNetworks(t *testing.T) {
+	kubeadm := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "kubeadm-config", "namespace": "kube-system"},
+		"data":       map[string]interface{}{"ClusterConfiguration": "apiVersion: kubeadm.k8s.io/v1beta3\nkind: ClusterConfiguration\nnetworking:\n  serviceSubnet: 10.96.0.0/12\n"},
+	}}
+	node := func(name string, cidrs ...interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Node",
+			"metadata":   map[string]interface{}{"name": name},
+			"spec":       map[string]interface{}{"podCIDRs": cidrs},
+		}}
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		nodeGVR:        "NodeList",
+		serviceCIDRGVR: "ServiceCIDRList",
+		configMapGVR:   "ConfigMapList",
+	}, kubeadm, node("a", "10.244.0.0/24", "fd00:10:244::/64"), node("b", "10.244.1.0/24"), node("c", "10.244.6.0/24"))
+
+	networks := collectClusterNetworks(context.Background(), client, testLogger())
+	if got := prefixStrings(networks.pod); !reflect.DeepEqual(got, []string{"10.244.0.0/21", "fd00:10:244::/64"}) {
+		t.Errorf("pod networks = %v, want the range holding the node ranges per family", got)
+	}
+	if got := prefixStrings(networks.service); !reflect.DeepEqual(got, []string{"10.96.0.0/12"}) {
+		t.Errorf("service networks = %v, want the kubeadm service subnet", got)
+	}
+}
+
+func Test_parseTargetCIDRs(t *testing.T) {
+	if _, err := parseTargetCIDRs("target-pod-cidr", []string{"10.128.0.0/14", "fd00:99::/48"}); err != nil {
+		t.Errorf("parseTargetCIDRs() of a dual-stack network error = %v", err)
+	}
+	for _, values := range [][]string{{"10.128.0.0"}, {"10.128.0.0/14", "10.0.0.0/8"}} {
+		if _, err := parseTargetCIDRs("target-pod-cidr", values); err == nil {
+			t.Errorf("parseTargetCIDRs(%v) should fail", values)
+		}
+	}
+}
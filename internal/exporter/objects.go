@@ -0,0 +1,182 @@
+package exporter
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// excludeAnnotation marks an object that must not be migrated, when set to true
+	excludeAnnotation = "migrate.konveyor.io/exclude"
+	// includeAnnotation marks the objects exported with --only-annotated, when set to true
+	includeAnnotation = "migrate.konveyor.io/include"
+)
+
+// objectFilter skips exported objects for a reason reported in the summary
+type objectFilter struct {
+	reason string
+	skip   func(obj unstructured.Unstructured) bool
+	// recordNames lists each skipped object in the summary, on top of the count per kind
+	recordNames bool
+}
+
+// objectFilters returns the filters applied to every listed object of the resources, in order
+func (o *ExportOptions) objectFilters(resources []*groupResource) []objectFilter {
+	filters := []objectFilter{}
+	// one filter per type so that the skipped Secrets are counted per type
+	for _, t := range o.secretTypes {
+		if t = strings.TrimSpace(t); t == "" {
+			continue
+		}
+		secretType := t
+		filters = append(filters, objectFilter{reason: "of Secret type " + secretType, skip: isSecretOfType(secretType)})
+	}
+	if !o.includeSystem {
+		filters = append(filters, objectFilter{reason: "generated by the cluster", skip: isSystemObject(resources)})
+	}
+	if o.excludeAnnotation != "" {
+		filters = append(filters, objectFilter{
+			reason:      "annotated with " + o.excludeAnnotation + "=true",
+			skip:        HasTrueAnnotation(o.excludeAnnotation),
+			recordNames: true,
+		})
+	}
+	if o.onlyAnnotated {
+		isIncluded := HasTrueAnnotation(includeAnnotation)
+		filters = append(filters, objectFilter{
+			reason: "not annotated with " + includeAnnotation + "=true",
+			skip:   func(obj unstructured.Unstructured) bool { return !isIncluded(obj) },
+		})
+	}
+	if o.nameRe != nil {
+		filters = append(filters, objectFilter{
+			reason: "not matching --name-regex",
+			skip:   func(obj unstructured.Unstructured) bool { return !o.nameRe.MatchString(obj.GetName()) },
+		})
+	}
+	if o.excludeNameRe != nil {
+		filters = append(filters, objectFilter{
+			reason: "matching --exclude-name-regex",
+			skip:   func(obj unstructured.Unstructured) bool { return o.excludeNameRe.MatchString(obj.GetName()) },
+		})
+	}
+	if o.created != nil {
+		filters = append(filters, objectFilter{reason: reasonCreatedOutside, skip: o.created.skip})
+	}
+	if o.skipHelmManaged {
+		filters = append(filters, objectFilter{reason: "managed by Helm", skip: isHelmManaged})
+	}
+	if !o.includeJobHistory {
+		filters = append(filters, objectFilter{reason: "job history", skip: isJobHistory})
+	}
+	if !o.includeOwned {
+		skip := IsControlled
+		if o.includeJobHistory {
+			skip = func(obj unstructured.Unstructured) bool { return IsControlled(obj) && !isJobHistory(obj) }
+		}
+		filters = append(filters, objectFilter{reason: "owned by a controller", skip: skip})
+	}
+	return filters
+}
+
+// applyObjectFilters removes the skipped objects from the resources in place and counts them
+// per reason and kind in the summary
+func applyObjectFilters(resources []*groupResource, filters []objectFilter, summary *exportSummary, log logrus.FieldLogger) {
+	if len(filters) == 0 {
+		return
+	}
+	for _, r := range resources {
+		if r.objects == nil {
+			continue
+		}
+		kept := []unstructured.Unstructured{}
+	objects:
+		for _, obj := range r.objects.Items {
+			for _, f := range filters {
+				if f.skip(obj) {
+					log.Debugf("skipping %s %s: %s", obj.GetKind(), obj.GetName(), f.reason)
+					summary.addSkipped(f.reason, obj, f.recordNames)
+					continue objects
+				}
+			}
+			kept = append(kept, obj)
+		}
+		r.objects.Items = kept
+	}
+}
+
+func isSecretOfType(t string) func(obj unstructured.Unstructured) bool {
+	return func(obj unstructured.Unstructured) bool {
+		return isSecret(obj) && secretType(obj) == t
+	}
+}
+
+func HasTrueAnnotation(key string) func(obj unstructured.Unstructured) bool {
+	return func(obj unstructured.Unstructured) bool {
+		return strings.EqualFold(obj.GetAnnotations()[key], "true")
+	}
+}
+
+// isSystemObject returns whether an object of the resources is created by the cluster in every
+// namespace or for every Service, and would be recreated on the target cluster:
+//   - the default ServiceAccount and its token Secrets
+//   - the kube-root-ca.crt ConfigMap
+//   - the Endpoints maintained for the Services with a selector
+func isSystemObject(resources []*groupResource) func(obj unstructured.Unstructured) bool {
+	selectorServices := map[string]bool{}
+	for _, r := range resources {
+		if r.objects == nil {
+			continue
+		}
+		for _, obj := range r.objects.Items {
+			if obj.GetKind() != "Service" || obj.GroupVersionKind().Group != "" {
+				continue
+			}
+			if selector, _, _ := unstructured.NestedMap(obj.Object, "spec", "selector"); len(selector) > 0 {
+				selectorServices[obj.GetNamespace()+"/"+obj.GetName()] = true
+			}
+		}
+	}
+
+	return func(obj unstructured.Unstructured) bool {
+		if obj.GroupVersionKind().Group != "" {
+			return false
+		}
+		switch obj.GetKind() {
+		case "ServiceAccount":
+			return obj.GetName() == "default"
+		case "ConfigMap":
+			return obj.GetName() == "kube-root-ca.crt"
+		case "Secret":
+			return secretType(obj) == "kubernetes.io/service-account-token"
+		case "Endpoints":
+			return selectorServices[obj.GetNamespace()+"/"+obj.GetName()]
+		}
+		return false
+	}
+}
+
+// IsControlled reports whether the object is managed by a controller, like the ReplicaSets of a
+// Deployment, and would be recreated by it on the target cluster
+func IsControlled(obj unstructured.Unstructured) bool {
+	return metav1.GetControllerOfNoCopy(&obj) != nil
+}
+
+// isJobHistory reports whether the object is a Job run by a CronJob or a Pod run by a Job, the
+// runs of the source cluster are of no use on the target one. The standalone Jobs are kept.
+func isJobHistory(obj unstructured.Unstructured) bool {
+	controller := metav1.GetControllerOfNoCopy(&obj)
+	if controller == nil {
+		return false
+	}
+	switch gvk := obj.GroupVersionKind(); {
+	case gvk.Group == "batch" && gvk.Kind == "Job":
+		return controller.Kind == "CronJob"
+	case gvk.Group == "" && gvk.Kind == "Pod":
+		return controller.Kind == "Job"
+	}
+	return false
+}
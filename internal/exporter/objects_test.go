@@ -0,0 +1,420 @@
+package exporter
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func testOwnedObject(kind string, name string, owners ...metav1.OwnerReference) unstructured.Unstructured {
+	obj := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": "foo",
+		},
+	}}
+	obj.SetOwnerReferences(owners)
+	return obj
+}
+
+func Test_applyObjectFilters_owned(t *testing.T) {
+	controller := true
+	notController := false
+	objects := []unstructured.Unstructured{
+		testOwnedObject("Pod", "standalone"),
+		testOwnedObject("Pod", "web-abc12", metav1.OwnerReference{Kind: "ReplicaSet", Name: "web", Controller: &controller}),
+		testOwnedObject("Pod", "operand", metav1.OwnerReference{Kind: "Operator", Name: "op", Controller: &notController}),
+		testOwnedObject("ControllerRevision", "db-1", metav1.OwnerReference{Kind: "StatefulSet", Name: "db", Controller: &controller}),
+	}
+
+	tests := []struct {
+		name         string
+		includeOwned bool
+		wantKept     []string
+		wantSkipped  map[string]int
+	}{
+		{
+			name:        "controlled objects are skipped by default",
+			wantKept:    []string{"standalone", "operand"},
+			wantSkipped: map[string]int{"Pod": 1, "ControllerRevision": 1},
+		},
+		{
+			name:         "controlled objects are kept with --include-owned",
+			includeOwned: true,
+			wantKept:     []string{"standalone", "web-abc12", "operand", "db-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := make([]unstructured.Unstructured, len(objects))
+			for i := range objects {
+				items[i] = *objects[i].DeepCopy()
+			}
+			resources := []*groupResource{{objects: &unstructured.UnstructuredList{Items: items}}}
+			summary := newExportSummary("foo")
+			o := &ExportOptions{includeOwned: tt.includeOwned}
+
+			applyObjectFilters(resources, o.objectFilters(resources), summary, testLogger())
+
+			kept := []string{}
+			for _, obj := range resources[0].objects.Items {
+				kept = append(kept, obj.GetName())
+			}
+			if len(kept) != len(tt.wantKept) {
+				t.Fatalf("kept %v, want %v", kept, tt.wantKept)
+			}
+			for i := range kept {
+				if kept[i] != tt.wantKept[i] {
+					t.Errorf("kept %v, want %v", kept, tt.wantKept)
+				}
+			}
+			skipped := summary.Skipped["owned by a controller"]
+			for kind, count := range tt.wantSkipped {
+				if skipped[kind] != count {
+					t.Errorf("skipped %s = %d, want %d", kind, skipped[kind], count)
+				}
+			}
+		})
+	}
+}
+
+func Test_applyObjectFilters_jobHistory(t *testing.T) {
+	controller := true
+	job := func(name string, owners ...metav1.OwnerReference) unstructured.Unstructured {
+		obj := testOwnedObject("Job", name, owners...)
+		obj.SetAPIVersion("batch/v1")
+		return obj
+	}
+	objects := []unstructured.Unstructured{
+		job("backup-28451520", metav1.OwnerReference{APIVersion: "batch/v1", Kind: "CronJob", Name: "backup", Controller: &controller}),
+		testOwnedObject("Pod", "backup-28451520-x7k2p", metav1.OwnerReference{APIVersion: "batch/v1", Kind: "Job", Name: "backup-28451520", Controller: &controller}),
+		job("migrate-db"),
+		job("operand", metav1.OwnerReference{Kind: "Operator", Name: "op", Controller: &controller}),
+	}
+
+	tests := []struct {
+		name              string
+		includeOwned      bool
+		includeJobHistory bool
+		wantKept          []string
+		wantSkipped       map[string]int
+	}{
+		{
+			name:        "given the defaults, should skip the job history and keep the standalone jobs",
+			wantKept:    []string{"migrate-db"},
+			wantSkipped: map[string]int{"Job": 1, "Pod": 1},
+		},
+		{
+			name:         "given --include-owned, should still skip the job history",
+			includeOwned: true,
+			wantKept:     []string{"migrate-db", "operand"},
+			wantSkipped:  map[string]int{"Job": 1, "Pod": 1},
+		},
+		{
+			name:              "given --include-job-history, should keep the job history only",
+			includeJobHistory: true,
+			wantKept:          []string{"backup-28451520", "backup-28451520-x7k2p", "migrate-db"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := make([]unstructured.Unstructured, len(objects))
+			for i := range objects {
+				items[i] = *objects[i].DeepCopy()
+			}
+			resources := []*groupResource{{objects: &unstructured.UnstructuredList{Items: items}}}
+			summary := newExportSummary("foo")
+			o := &ExportOptions{includeOwned: tt.includeOwned, includeJobHistory: tt.includeJobHistory}
+
+			applyObjectFilters(resources, o.objectFilters(resources), summary, testLogger())
+
+			kept := []string{}
+			for _, obj := range resources[0].objects.Items {
+				kept = append(kept, obj.GetName())
+			}
+			if !reflect.DeepEqual(kept, tt.wantKept) {
+				t.Errorf("kept %v, want %v", kept, tt.wantKept)
+			}
+			skipped := summary.Skipped["job history"]
+			for kind, count := range tt.wantSkipped {
+				if skipped[kind] != count {
+					t.Errorf("skipped %s = %d, want %d", kind, skipped[kind], count)
+				}
+			}
+		})
+	}
+}
+
+func Test_applyObjectFilters_annotations(t *testing.T) {
+	annotated := func(kind string, name string, annotations map[string]string) unstructured.Unstructured {
+		obj := testOwnedObject(kind, name)
+		obj.SetAnnotations(annotations)
+		return obj
+	}
+	objects := []unstructured.Unstructured{
+		annotated("Deployment", "hello-world", nil),
+		annotated("ConfigMap", "scratch", map[string]string{excludeAnnotation: "true"}),
+		annotated("Secret", "kept", map[string]string{excludeAnnotation: "false"}),
+		annotated("Secret", "legacy", map[string]string{"example.com/skip": "True"}),
+		annotated("Service", "web", map[string]string{includeAnnotation: "true"}),
+	}
+
+	tests := []struct {
+		name              string
+		excludeAnnotation string
+		onlyAnnotated     bool
+		wantKept          []string
+		wantSkipped       []string
+	}{
+		{
+			name:              "exclude annotation set to true is skipped",
+			excludeAnnotation: excludeAnnotation,
+			wantKept:          []string{"hello-world", "kept", "legacy", "web"},
+			wantSkipped:       []string{"ConfigMap/scratch"},
+		},
+		{
+			name:              "exclude annotation key can be overridden",
+			excludeAnnotation: "example.com/skip",
+			wantKept:          []string{"hello-world", "scratch", "kept", "web"},
+			wantSkipped:       []string{"Secret/legacy"},
+		},
+		{
+			name:              "only annotated objects are exported",
+			excludeAnnotation: excludeAnnotation,
+			onlyAnnotated:     true,
+			wantKept:          []string{"web"},
+			wantSkipped:       []string{"ConfigMap/scratch"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := make([]unstructured.Unstructured, len(objects))
+			for i := range objects {
+				items[i] = *objects[i].DeepCopy()
+			}
+			resources := []*groupResource{{objects: &unstructured.UnstructuredList{Items: items}}}
+			summary := newExportSummary("foo")
+			o := &ExportOptions{excludeAnnotation: tt.excludeAnnotation, onlyAnnotated: tt.onlyAnnotated}
+
+			applyObjectFilters(resources, o.objectFilters(resources), summary, testLogger())
+
+			kept := []string{}
+			for _, obj := range resources[0].objects.Items {
+				kept = append(kept, obj.GetName())
+			}
+			if !reflect.DeepEqual(kept, tt.wantKept) {
+				t.Errorf("kept %v, want %v", kept, tt.wantKept)
+			}
+			skipped := summary.SkippedObjects["annotated with "+tt.excludeAnnotation+"=true"]
+			if !reflect.DeepEqual(skipped, tt.wantSkipped) {
+				t.Errorf("skipped objects %v, want %v", skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func Test_applyObjectFilters_names(t *testing.T) {
+	objects := []unstructured.Unstructured{
+		testOwnedObject("Secret", "sh.helm.release.v1.web.v1"),
+		testOwnedObject("Secret", "web-tls"),
+		testOwnedObject("ConfigMap", "web-config"),
+		testOwnedObject("ConfigMap", "ca-bundle"),
+	}
+
+	tests := []struct {
+		name        string
+		nameRe      string
+		excludeRe   string
+		wantKept    []string
+		wantSkipped map[string]int
+	}{
+		{
+			name:        "exclude regex drops Helm release secrets",
+			excludeRe:   `^sh\.helm\.release\.`,
+			wantKept:    []string{"web-tls", "web-config", "ca-bundle"},
+			wantSkipped: map[string]int{"matching --exclude-name-regex": 1},
+		},
+		{
+			name:        "name regex keeps the matching objects of every kind",
+			nameRe:      `^web-`,
+			wantKept:    []string{"web-tls", "web-config"},
+			wantSkipped: map[string]int{"not matching --name-regex": 2},
+		},
+		{
+			name:        "both regexes apply",
+			nameRe:      `^web-`,
+			excludeRe:   `-tls$`,
+			wantKept:    []string{"web-config"},
+			wantSkipped: map[string]int{"not matching --name-regex": 2, "matching --exclude-name-regex": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := make([]unstructured.Unstructured, len(objects))
+			for i := range objects {
+				items[i] = *objects[i].DeepCopy()
+			}
+			resources := []*groupResource{{objects: &unstructured.UnstructuredList{Items: items}}}
+			summary := newExportSummary("foo")
+			o := &ExportOptions{includeOwned: true}
+			if tt.nameRe != "" {
+				o.nameRe = regexp.MustCompile(tt.nameRe)
+			}
+			if tt.excludeRe != "" {
+				o.excludeNameRe = regexp.MustCompile(tt.excludeRe)
+			}
+
+			applyObjectFilters(resources, o.objectFilters(resources), summary, testLogger())
+
+			kept := []string{}
+			for _, obj := range resources[0].objects.Items {
+				kept = append(kept, obj.GetName())
+			}
+			if !reflect.DeepEqual(kept, tt.wantKept) {
+				t.Errorf("kept %v, want %v", kept, tt.wantKept)
+			}
+			for reason, count := range tt.wantSkipped {
+				total := 0
+				for _, n := range summary.Skipped[reason] {
+					total += n
+				}
+				if total != count {
+					t.Errorf("skipped %s = %d, want %d", reason, total, count)
+				}
+			}
+		})
+	}
+}
+
+func Test_applyObjectFilters_system(t *testing.T) {
+	service := func(name string, selector map[string]interface{}) unstructured.Unstructured {
+		obj := testOwnedObject("Service", name)
+		if selector != nil {
+			if err := unstructured.SetNestedMap(obj.Object, selector, "spec", "selector"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return obj
+	}
+	objects := []unstructured.Unstructured{
+		testOwnedObject("ServiceAccount", "default"),
+		testOwnedObject("ServiceAccount", "builder"),
+		testOwnedObject("ConfigMap", "kube-root-ca.crt"),
+		testSecret("builder-token-x7k2p", "kubernetes.io/service-account-token", nil),
+		testSecret("app", "Opaque", nil),
+		service("web", map[string]interface{}{"app": "web"}),
+		service("external-db", nil),
+		testOwnedObject("Endpoints", "web"),
+		testOwnedObject("Endpoints", "external-db"),
+	}
+
+	tests := []struct {
+		name          string
+		includeSystem bool
+		wantKept      []string
+	}{
+		{
+			name:     "generated objects are skipped by default",
+			wantKept: []string{"builder", "app", "web", "external-db", "external-db"},
+		},
+		{
+			name:          "generated objects are kept with --include-system-objects",
+			includeSystem: true,
+			wantKept:      []string{"default", "builder", "kube-root-ca.crt", "builder-token-x7k2p", "app", "web", "external-db", "web", "external-db"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := make([]unstructured.Unstructured, len(objects))
+			for i := range objects {
+				items[i] = *objects[i].DeepCopy()
+			}
+			resources := []*groupResource{{objects: &unstructured.UnstructuredList{Items: items}}}
+			summary := newExportSummary("foo")
+			o := &ExportOptions{includeSystem: tt.includeSystem}
+
+			applyObjectFilters(resources, o.objectFilters(resources), summary, testLogger())
+
+			kept := []string{}
+			for _, obj := range resources[0].objects.Items {
+				kept = append(kept, obj.GetName())
+			}
+			if !reflect.DeepEqual(kept, tt.wantKept) {
+				t.Errorf("kept %v, want %v", kept, tt.wantKept)
+			}
+			if !tt.includeSystem && len(summary.Skipped["generated by the cluster"]) != 4 {
+				t.Errorf("skipped %v, want 4 kinds of generated objects", summary.Skipped["generated by the cluster"])
+			}
+		})
+	}
+}
+
+func Test_applyObjectFilters_secretTypes(t *testing.T) {
+	objects := []unstructured.Unstructured{
+		testSecret("builder-token-x7k2p", "kubernetes.io/service-account-token", nil),
+		testSecret("deployer-token-p2x9q", "kubernetes.io/service-account-token", nil),
+		testSecret("sh.helm.release.v1.web.v1", "helm.sh/release.v1", nil),
+		testSecret("pull", "kubernetes.io/dockerconfigjson", nil),
+		testSecret("app", "Opaque", nil),
+		testOwnedObject("ConfigMap", "app"),
+	}
+
+	tests := []struct {
+		name        string
+		secretTypes []string
+		wantKept    []string
+		wantSkipped map[string]map[string]int
+	}{
+		{
+			name:        "default types",
+			secretTypes: []string{"kubernetes.io/service-account-token", "helm.sh/release.v1"},
+			wantKept:    []string{"pull", "app", "app"},
+			wantSkipped: map[string]map[string]int{
+				"of Secret type kubernetes.io/service-account-token": {"Secret": 2},
+				"of Secret type helm.sh/release.v1":                  {"Secret": 1},
+			},
+		},
+		{
+			name:        "empty value disables the filter",
+			secretTypes: []string{},
+			wantKept:    []string{"builder-token-x7k2p", "deployer-token-p2x9q", "sh.helm.release.v1.web.v1", "pull", "app", "app"},
+			wantSkipped: map[string]map[string]int{},
+		},
+		{
+			name:        "custom type",
+			secretTypes: []string{" kubernetes.io/dockerconfigjson", ""},
+			wantKept:    []string{"builder-token-x7k2p", "deployer-token-p2x9q", "sh.helm.release.v1.web.v1", "app", "app"},
+			wantSkipped: map[string]map[string]int{"of Secret type kubernetes.io/dockerconfigjson": {"Secret": 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := make([]unstructured.Unstructured, len(objects))
+			for i := range objects {
+				items[i] = *objects[i].DeepCopy()
+			}
+			resources := []*groupResource{{objects: &unstructured.UnstructuredList{Items: items}}}
+			summary := newExportSummary("foo")
+			// the system objects are kept to tell the two filters apart
+			o := &ExportOptions{secretTypes: tt.secretTypes, includeSystem: true, includeOwned: true}
+
+			applyObjectFilters(resources, o.objectFilters(resources), summary, testLogger())
+
+			kept := []string{}
+			for _, obj := range resources[0].objects.Items {
+				kept = append(kept, obj.GetName())
+			}
+			if !reflect.DeepEqual(kept, tt.wantKept) {
+				t.Errorf("kept %v, want %v", kept, tt.wantKept)
+			}
+			if !reflect.DeepEqual(summary.Skipped, tt.wantSkipped) {
+				t.Errorf("skipped %v, want %v", summary.Skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
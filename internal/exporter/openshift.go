@@ -0,0 +1,298 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	platformAuto = "auto"
+
+	routeHintsFile = "route-hints.json"
+)
+
+var imageStreamsGVR = schema.GroupVersionResource{Group: "image.openshift.io", Version: "v1", Resource: "imagestreams"}
+
+// resolvedImage is an image reference of a container replaced by the image it points to
+type resolvedImage struct {
+	Object    string `json:"object"`
+	Container string `json:"container"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+}
+
+// imageStreamResolver replaces the references to ImageStreamTags in the pod templates with the
+// images they point to, by digest. The internal registry and the ImageStreams do not exist on
+// the target cluster. The ImageStreams are read once per run.
+type imageStreamResolver struct {
+	client  dynamic.Interface
+	streams map[string]*unstructured.Unstructured
+	log     logrus.FieldLogger
+}
+
+func newImageStreamResolver(client dynamic.Interface, log logrus.FieldLogger) *imageStreamResolver {
+	return &imageStreamResolver{client: client, streams: map[string]*unstructured.Unstructured{}, log: log}
+}
+
+// imageStream returns the ImageStream, nil when it cannot be read
+func (r *imageStreamResolver) imageStream(ctx context.Context, namespace, name string) *unstructured.Unstructured {
+	key := namespace + "/" + name
+	if stream, ok := r.streams[key]; ok {
+		return stream
+	}
+	stream, err := r.client.Resource(imageStreamsGVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		r.log.Debugf("cannot get the ImageStream %s: %v", key, err)
+		stream = nil
+	}
+	r.streams[key] = stream
+	return stream
+}
+
+// resolve rewrites the images of the workloads among the resources and returns the replaced ones
+func (r *imageStreamResolver) resolve(ctx context.Context, resources []*groupResource) []resolvedImage {
+	resolved := []resolvedImage{}
+	for _, gr := range resources {
+		if gr.objects == nil {
+			continue
+		}
+		for i := range gr.objects.Items {
+			resolved = append(resolved, r.resolveObject(ctx, &gr.objects.Items[i])...)
+		}
+	}
+	return resolved
+}
+
+func (r *imageStreamResolver) resolveObject(ctx context.Context, obj *unstructured.Unstructured) []resolvedImage {
+	path, ok := podSpecPaths[obj.GetKind()]
+	if !ok {
+		return nil
+	}
+	// the image change triggers of DeploymentConfigs name the tag of each container
+	triggered := map[string]string{}
+	if obj.GetKind() == "DeploymentConfig" {
+		triggered = r.triggeredImages(ctx, obj)
+	}
+
+	resolved := []resolvedImage{}
+	for _, field := range containerFields {
+		containers, _, _ := unstructured.NestedFieldNoCopy(obj.Object, append(path, field)...)
+		list, _ := containers.([]interface{})
+		for _, c := range list {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := container["name"].(string)
+			ref, _ := container["image"].(string)
+			to := triggered[name]
+			if to == "" {
+				to = r.resolveImage(ctx, ref)
+			}
+			if to == "" || to == ref {
+				continue
+			}
+			container["image"] = to
+			resolved = append(resolved, resolvedImage{Object: obj.GetKind() + "/" + obj.GetName(), Container: name, From: ref, To: to})
+		}
+	}
+	return resolved
+}
+
+// triggeredImages returns the images of the ImageStreamTags of the image change triggers, by
+// container name
+func (r *imageStreamResolver) triggeredImages(ctx context.Context, obj *unstructured.Unstructured) map[string]string {
+	images := map[string]string{}
+	triggers, _, _ := unstructured.NestedSlice(obj.Object, "spec", "triggers")
+	for _, t := range triggers {
+		trigger, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		kind, _, _ := unstructured.NestedString(trigger, "imageChangeParams", "from", "kind")
+		from, _, _ := unstructured.NestedString(trigger, "imageChangeParams", "from", "name")
+		if kind != "ImageStreamTag" || from == "" {
+			continue
+		}
+		namespace, _, _ := unstructured.NestedString(trigger, "imageChangeParams", "from", "namespace")
+		if namespace == "" {
+			namespace = obj.GetNamespace()
+		}
+		name, tag := from, "latest"
+		if i := strings.LastIndex(from, ":"); i >= 0 {
+			name, tag = from[:i], from[i+1:]
+		}
+		stream := r.imageStream(ctx, namespace, name)
+		if stream == nil {
+			continue
+		}
+		image := tagImage(stream, tag)
+		if image == "" {
+			continue
+		}
+		containers, _, _ := unstructured.NestedStringSlice(trigger, "imageChangeParams", "containerNames")
+		for _, container := range containers {
+			images[container] = image
+		}
+	}
+	return images
+}
+
+// resolveImage returns the image behind a reference to an ImageStream through the internal
+// registry, empty when the reference is not one. Only the registries served by a cluster service
+// are looked up, not to get an ImageStream for every image pulled from elsewhere.
+func (r *imageStreamResolver) resolveImage(ctx context.Context, ref string) string {
+	img := ParseImage(ref)
+	if !isClusterRegistry(img.Registry) {
+		return ""
+	}
+	parts := strings.Split(img.Repository, "/")
+	if len(parts) != 2 {
+		return ""
+	}
+	stream := r.imageStream(ctx, parts[0], parts[1])
+	if stream == nil {
+		return ""
+	}
+	repository := img.Registry + "/" + img.Repository
+	internal, _, _ := unstructured.NestedString(stream.Object, "status", "dockerImageRepository")
+	public, _, _ := unstructured.NestedString(stream.Object, "status", "publicDockerImageRepository")
+	if repository != internal && repository != public {
+		return ""
+	}
+	if img.Digest != "" {
+		return digestImage(stream, img.Digest)
+	}
+	return tagImage(stream, img.Tag)
+}
+
+// isClusterRegistry reports whether the registry is a cluster service, like
+// image-registry.openshift-image-registry.svc:5000 or docker-registry.default.svc:5000 on 3.x
+func isClusterRegistry(registry string) bool {
+	host := registry
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	return strings.HasSuffix(host, ".svc") || strings.HasSuffix(host, ".svc.cluster.local")
+}
+
+// tagImage returns the image the tag of the ImageStream currently points to
+func tagImage(stream *unstructured.Unstructured, tag string) string {
+	for _, item := range streamItems(stream, tag) {
+		if image, _ := item["dockerImageReference"].(string); image != "" {
+			return image
+		}
+	}
+	return ""
+}
+
+// digestImage returns the image of the ImageStream with the digest, from the history of its tags
+func digestImage(stream *unstructured.Unstructured, digest string) string {
+	for _, item := range streamItems(stream, "") {
+		if item["image"] == digest {
+			image, _ := item["dockerImageReference"].(string)
+			return image
+		}
+	}
+	return ""
+}
+
+// streamItems returns the history of the tag of the ImageStream, latest first, or of all its tags
+// when tag is empty
+func streamItems(stream *unstructured.Unstructured, tag string) []map[string]interface{} {
+	items := []map[string]interface{}{}
+	tags, _, _ := unstructured.NestedSlice(stream.Object, "status", "tags")
+	for _, t := range tags {
+		event, ok := t.(map[string]interface{})
+		if !ok || (tag != "" && event["tag"] != tag) {
+			continue
+		}
+		history, _ := event["items"].([]interface{})
+		for _, i := range history {
+			if item, ok := i.(map[string]interface{}); ok {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// routeHint is an entry of route-hints.json, what an Ingress replacing the Route needs
+type routeHint struct {
+	Namespace      string `json:"namespace"`
+	Route          string `json:"route"`
+	Host           string `json:"host,omitempty"`
+	Path           string `json:"path,omitempty"`
+	Service        string `json:"service"`
+	ServicePort    string `json:"servicePort,omitempty"`
+	TLSTermination string `json:"tlsTermination,omitempty"`
+	// Notes are the settings of the Route with no Ingress equivalent
+	Notes []string `json:"notes,omitempty"`
+}
+
+// routeHints collects the exported Routes, the target cluster may not serve them
+type routeHints struct {
+	hints []routeHint
+}
+
+func newRouteHints() *routeHints {
+	return &routeHints{hints: []routeHint{}}
+}
+
+// add records the Routes among the resources
+func (h *routeHints) add(resources []*groupResource) {
+	for _, gr := range resources {
+		if gr.objects == nil || gr.nonPreferredVersion || gr.APIGroup != "route.openshift.io" || gr.APIResource.Kind != "Route" {
+			continue
+		}
+		for _, obj := range gr.objects.Items {
+			h.hints = append(h.hints, newRouteHint(obj))
+		}
+	}
+}
+
+func newRouteHint(route unstructured.Unstructured) routeHint {
+	hint := routeHint{Namespace: route.GetNamespace(), Route: route.GetName()}
+	hint.Host, _, _ = unstructured.NestedString(route.Object, "spec", "host")
+	hint.Path, _, _ = unstructured.NestedString(route.Object, "spec", "path")
+	hint.Service, _, _ = unstructured.NestedString(route.Object, "spec", "to", "name")
+	if port, ok, _ := unstructured.NestedFieldNoCopy(route.Object, "spec", "port", "targetPort"); ok {
+		hint.ServicePort = fmt.Sprint(port)
+	}
+	hint.TLSTermination, _, _ = unstructured.NestedString(route.Object, "spec", "tls", "termination")
+
+	switch hint.TLSTermination {
+	case "passthrough":
+		hint.Notes = append(hint.Notes, "passthrough termination requires an ingress controller supporting SSL passthrough")
+	case "reencrypt":
+		hint.Notes = append(hint.Notes, "reencrypt termination requires the ingress controller to connect to the service with TLS")
+	}
+	if policy, _, _ := unstructured.NestedString(route.Object, "spec", "tls", "insecureEdgeTerminationPolicy"); policy == "Redirect" {
+		hint.Notes = append(hint.Notes, "HTTP is redirected to HTTPS, configure the redirect of the ingress controller")
+	}
+	if backends, _, _ := unstructured.NestedSlice(route.Object, "spec", "alternateBackends"); len(backends) > 0 {
+		hint.Notes = append(hint.Notes, "the traffic is split with alternate backends, which Ingress does not support")
+	}
+	if policy, _, _ := unstructured.NestedString(route.Object, "spec", "wildcardPolicy"); policy == "Subdomain" {
+		hint.Notes = append(hint.Notes, "the Route serves a wildcard subdomain")
+	}
+	return hint
+}
+
+func (h *routeHints) write(exportDir string) error {
+	hintBytes, err := json.MarshalIndent(h.hints, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(exportDir, routeHintsFile), append(hintBytes, '\n'), 0600)
+}
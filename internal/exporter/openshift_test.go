@@ -0,0 +1,164 @@
+package exporter
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func Test_imageStreamResolver(t *testing.T) {
+	const (
+		registry = "image-registry.openshift-image-registry.svc:5000"
+		built    = registry + "/foo/app@sha256:2222"
+		imported = "quay.io/org/base@sha256:aaaa"
+	)
+	stream := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "image.openshift.io/v1",
+		"kind":       "ImageStream",
+		"metadata":   map[string]interface{}{"name": "app", "namespace": "foo"},
+		"status": map[string]interface{}{
+			"dockerImageRepository": registry + "/foo/app",
+			"tags": []interface{}{
+				map[string]interface{}{"tag": "latest", "items": []interface{}{
+					map[string]interface{}{"dockerImageReference": built, "image": "sha256:2222"},
+					map[string]interface{}{"dockerImageReference": registry + "/foo/app@sha256:1111", "image": "sha256:1111"},
+				}},
+				map[string]interface{}{"tag": "base", "items": []interface{}{
+					map[string]interface{}{"dockerImageReference": imported, "image": "sha256:aaaa"},
+				}},
+			},
+		},
+	}}
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), stream)
+
+	container := func(name, image string) interface{} {
+		return map[string]interface{}{"name": name, "image": image}
+	}
+	deploymentConfig := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps.openshift.io/v1",
+		"kind":       "DeploymentConfig",
+		"metadata":   map[string]interface{}{"name": "app", "namespace": "foo"},
+		"spec": map[string]interface{}{
+			"triggers": []interface{}{
+				map[string]interface{}{"type": "ImageChange", "imageChangeParams": map[string]interface{}{
+					"containerNames": []interface{}{"app"},
+					"from":           map[string]interface{}{"kind": "ImageStreamTag", "name": "app:base"},
+				}},
+			},
+			"template": map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{
+				container("app", registry+"/foo/app@sha256:1111"),
+				container("sidecar", registry+"/foo/app:latest"),
+				container("proxy", "docker.io/library/nginx:1.25"),
+			}}},
+		},
+	}}
+	deployment := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "old", "namespace": "foo"},
+		"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{"initContainers": []interface{}{
+			container("init", registry+"/foo/app@sha256:aaaa"),
+			container("missing", registry+"/foo/other:latest"),
+		}}}},
+	}}
+	resources := []*groupResource{{
+		APIGroup:    "apps.openshift.io",
+		APIResource: metav1.APIResource{Name: "deploymentconfigs", Kind: "DeploymentConfig"},
+		objects:     &unstructured.UnstructuredList{Items: []unstructured.Unstructured{*deploymentConfig}},
+	}, {
+		APIGroup:    "apps",
+		APIResource: metav1.APIResource{Name: "deployments", Kind: "Deployment"},
+		objects:     &unstructured.UnstructuredList{Items: []unstructured.Unstructured{*deployment}},
+	}}
+
+	resolved := newImageStreamResolver(client, testLogger()).resolve(context.Background(), resources)
+	want := []resolvedImage{
+		{Object: "DeploymentConfig/app", Container: "app", From: registry + "/foo/app@sha256:1111", To: imported},
+		{Object: "DeploymentConfig/app", Container: "sidecar", From: registry + "/foo/app:latest", To: built},
+		{Object: "Deployment/old", Container: "init", From: registry + "/foo/app@sha256:aaaa", To: imported},
+	}
+	if !reflect.DeepEqual(resolved, want) {
+		t.Errorf("resolve() = %v, want %v", resolved, want)
+	}
+
+	images := []string{}
+	for _, r := range resources {
+		for _, obj := range r.objects.Items {
+			path := podSpecPaths[obj.GetKind()]
+			for _, field := range containerFields {
+				containers, _, _ := unstructured.NestedSlice(obj.Object, append(path, field)...)
+				for _, c := range containers {
+					images = append(images, c.(map[string]interface{})["image"].(string))
+				}
+			}
+		}
+	}
+	wantImages := []string{imported, built, "docker.io/library/nginx:1.25", imported, registry + "/foo/other:latest"}
+	if !reflect.DeepEqual(images, wantImages) {
+		t.Errorf("images after resolve() = %v, want %v", images, wantImages)
+	}
+}
+
+func Test_isClusterRegistry(t *testing.T) {
+	tests := []struct {
+		registry string
+		want     bool
+	}{
+		{"image-registry.openshift-image-registry.svc:5000", true},
+		{"docker-registry.default.svc:5000", true},
+		{"registry.ns.svc.cluster.local", true},
+		{"docker.io", false},
+		{"default-route-openshift-image-registry.apps.example.com", false},
+	}
+	for _, tt := range tests {
+		if got := isClusterRegistry(tt.registry); got != tt.want {
+			t.Errorf("isClusterRegistry(%q) = %v, want %v", tt.registry, got, tt.want)
+		}
+	}
+}
+
+func Test_routeHints(t *testing.T) {
+	route := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "route.openshift.io/v1",
+		"kind":       "Route",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "foo"},
+		"spec": map[string]interface{}{
+			"host": "web.apps.example.com",
+			"path": "/api",
+			"to":   map[string]interface{}{"kind": "Service", "name": "web"},
+			"port": map[string]interface{}{"targetPort": int64(8080)},
+			"tls":  map[string]interface{}{"termination": "passthrough", "insecureEdgeTerminationPolicy": "Redirect"},
+		},
+	}}
+	hints := newRouteHints()
+	hints.add([]*groupResource{{
+		APIGroup:    "route.openshift.io",
+		APIResource: metav1.APIResource{Name: "routes", Kind: "Route"},
+		objects:     &unstructured.UnstructuredList{Items: []unstructured.Unstructured{*route}},
+	}, {
+		APIGroup:    "",
+		APIResource: metav1.APIResource{Name: "services", Kind: "Service"},
+		objects:     &unstructured.UnstructuredList{Items: []unstructured.Unstructured{{Object: map[string]interface{}{"kind": "Service"}}}},
+	}})
+	want := []routeHint{{
+		Namespace:      "foo",
+		Route:          "web",
+		Host:           "web.apps.example.com",
+		Path:           "/api",
+		Service:        "web",
+		ServicePort:    "8080",
+		TLSTermination: "passthrough",
+		Notes: []string{
+			"passthrough termination requires an ingress controller supporting SSL passthrough",
+			"HTTP is redirected to HTTPS, configure the redirect of the ingress controller",
+		},
+	}}
+	if !reflect.DeepEqual(hints.hints, want) {
+		t.Errorf("add() = %+v, want %+v", hints.hints, want)
+	}
+}
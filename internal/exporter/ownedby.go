@@ -0,0 +1,179 @@
+package exporter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// referrerKinds are the kinds exported with an application when they reference one of its objects,
+// like the Ingress of its Service. The other objects referencing it, like another Deployment
+// mounting the same ConfigMap, belong to other applications.
+var referrerKinds = []string{"Ingress", "HorizontalPodAutoscaler", "RoleBinding"}
+
+// applicationSelector selects the objects of the application of a top-level object with --owned-by
+type applicationSelector struct {
+	kind string
+	name string
+}
+
+// parseApplicationSelector parses the Kind/name of --owned-by
+func parseApplicationSelector(value string) (*applicationSelector, error) {
+	kind, name, ok := strings.Cut(value, "/")
+	if !ok || kind == "" || name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid --owned-by %q, must be Kind/name like Deployment/hello-world", value)
+	}
+	return &applicationSelector{kind: kind, name: name}, nil
+}
+
+func (s *applicationSelector) String() string {
+	return s.kind + "/" + s.name
+}
+
+func (s *applicationSelector) reason() string {
+	return "not part of " + s.String()
+}
+
+// members returns the objects of the application among the listed resources of a namespace. From
+// the named object, the following objects are added until none is:
+//   - the objects referenced by a member, like the ConfigMaps, Secrets, PersistentVolumeClaims and
+//     ServiceAccount of a pod template, or the Role of a RoleBinding
+//   - the objects controlled by a member, like its ReplicaSets, when they are exported
+//   - the Services and PodDisruptionBudgets whose selector matches the pod labels of a member
+//   - the Ingresses, HorizontalPodAutoscalers and RoleBindings referencing a member
+//
+// The references to objects of the namespace that were not listed are warned about.
+func (s *applicationSelector) members(namespace string, resources []*groupResource, log logrus.FieldLogger) map[graphNode]bool {
+	objects := []unstructured.Unstructured{}
+	listed := map[graphNode]bool{}
+	for _, r := range resources {
+		if r.objects == nil {
+			continue
+		}
+		for _, obj := range r.objects.Items {
+			objects = append(objects, obj)
+			listed[objectNode(obj)] = true
+		}
+	}
+
+	members := map[graphNode]bool{}
+	queue := []unstructured.Unstructured{}
+	add := func(obj unstructured.Unstructured) {
+		if node := objectNode(obj); !members[node] {
+			members[node] = true
+			queue = append(queue, obj)
+		}
+	}
+	for _, obj := range objects {
+		if strings.EqualFold(obj.GetKind(), s.kind) && obj.GetName() == s.name {
+			add(obj)
+		}
+	}
+	if len(members) == 0 {
+		log.Warnf("%s not found in namespace %s, none of its objects are exported", s, namespace)
+		return members
+	}
+
+	warned := map[GraphEdge]bool{}
+	for len(queue) > 0 {
+		obj := queue[0]
+		queue = queue[1:]
+		from := objectNode(obj)
+		referenced := map[graphNode]bool{}
+		for _, ref := range objectReferences {
+			targets, field := ref.targets(obj)
+			for _, to := range targets {
+				if to.Namespace != from.Namespace {
+					continue
+				}
+				referenced[to] = true
+				edge := GraphEdge{From: from, To: to, Field: field}
+				if !listed[to] && !recreatedByCluster(to) && !warned[edge] {
+					warned[edge] = true
+					log.Warnf("%s of %s references %s through %s, which is not found in namespace %s", from, s, to, field, namespace)
+				}
+			}
+		}
+		podLabels := templateLabels(obj)
+		for _, other := range objects {
+			switch {
+			case referenced[objectNode(other)]:
+				add(other)
+			case isControlledBy(other, obj):
+				add(other)
+			case other.GetKind() == "Service" && selectsLabels(other, podLabels):
+				add(other)
+			case other.GetKind() == "PodDisruptionBudget" && selectsPods(other, obj):
+				add(other)
+			case containsString(referrerKinds, other.GetKind()) && references(other, from):
+				add(other)
+			}
+		}
+	}
+	return members
+}
+
+// filter removes the objects of the resources that are not members of the application, the members
+// being found among all the listed resources
+func (s *applicationSelector) filter(namespace string, all []*groupResource, resources []*groupResource, summary *exportSummary, log logrus.FieldLogger) {
+	if s == nil {
+		return
+	}
+	members := s.members(namespace, all, log)
+	filter := objectFilter{
+		reason: s.reason(),
+		skip:   func(obj unstructured.Unstructured) bool { return !members[objectNode(obj)] },
+	}
+	applyObjectFilters(resources, []objectFilter{filter}, summary, log)
+}
+
+func objectNode(obj unstructured.Unstructured) graphNode {
+	return graphNode{Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName()}
+}
+
+// templateLabels returns the labels of the pods of a workload, or of a Pod
+func templateLabels(obj unstructured.Unstructured) map[string]string {
+	specPath, ok := podSpecPaths[obj.GetKind()]
+	if !ok {
+		return nil
+	}
+	path := append(append([]string{}, specPath[:len(specPath)-1]...), "metadata", "labels")
+	labels, _, _ := unstructured.NestedStringMap(obj.Object, path...)
+	return labels
+}
+
+// selectsLabels reports whether the selector of a Service matches the pod labels
+func selectsLabels(service unstructured.Unstructured, labels map[string]string) bool {
+	selector, _, _ := unstructured.NestedStringMap(service.Object, "spec", "selector")
+	if len(selector) == 0 || len(labels) == 0 {
+		return false
+	}
+	for key, value := range selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// isControlledBy reports whether the controller of obj is owner
+func isControlledBy(obj unstructured.Unstructured, owner unstructured.Unstructured) bool {
+	controller := metav1.GetControllerOfNoCopy(&obj)
+	return controller != nil && controller.Kind == owner.GetKind() && controller.Name == owner.GetName()
+}
+
+// references reports whether obj references the node through one of the objectReferences
+func references(obj unstructured.Unstructured, node graphNode) bool {
+	for _, ref := range objectReferences {
+		targets, _ := ref.targets(obj)
+		for _, to := range targets {
+			if to == node {
+				return true
+			}
+		}
+	}
+	return false
+}
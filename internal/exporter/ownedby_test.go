@@ -0,0 +1,151 @@
+package exporter
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func testApplicationObject(apiVersion string, kind string, name string, fields map[string]interface{}) unstructured.Unstructured {
+	obj := testOwnedObject(kind, name)
+	obj.SetAPIVersion(apiVersion)
+	for k, v := range fields {
+		obj.Object[k] = v
+	}
+	return obj
+}
+
+func Test_applicationSelector_filter(t *testing.T) {
+	podTemplate := map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "web", "tier": "frontend"}},
+		"spec": map[string]interface{}{
+			"serviceAccountName": "web",
+			"containers": []interface{}{map[string]interface{}{
+				"name":    "web",
+				"envFrom": []interface{}{map[string]interface{}{"configMapRef": map[string]interface{}{"name": "web-config"}}},
+				"env": []interface{}{map[string]interface{}{
+					"name":      "PASSWORD",
+					"valueFrom": map[string]interface{}{"secretKeyRef": map[string]interface{}{"name": "web-missing", "key": "password"}},
+				}},
+			}},
+		},
+	}
+	deployments := &groupResource{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+		testApplicationObject("apps/v1", "Deployment", "web", map[string]interface{}{"spec": map[string]interface{}{"template": podTemplate}}),
+		testApplicationObject("apps/v1", "Deployment", "db", map[string]interface{}{"spec": map[string]interface{}{"template": map[string]interface{}{
+			"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "db"}},
+			"spec": map[string]interface{}{"volumes": []interface{}{map[string]interface{}{
+				"name": "config", "configMap": map[string]interface{}{"name": "web-config"},
+			}}},
+		}}}),
+	}}}
+	core := &groupResource{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+		testApplicationObject("v1", "ConfigMap", "web-config", nil),
+		testApplicationObject("v1", "ConfigMap", "db-config", nil),
+		testApplicationObject("v1", "ServiceAccount", "web", nil),
+		testApplicationObject("v1", "Service", "web", map[string]interface{}{"spec": map[string]interface{}{"selector": map[string]interface{}{"app": "web"}}}),
+		testApplicationObject("v1", "Service", "db", map[string]interface{}{"spec": map[string]interface{}{"selector": map[string]interface{}{"app": "db"}}}),
+	}}}
+	networking := &groupResource{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+		testApplicationObject("networking.k8s.io/v1", "Ingress", "web", map[string]interface{}{"spec": map[string]interface{}{
+			"defaultBackend": map[string]interface{}{"service": map[string]interface{}{"name": "web"}},
+			"tls":            []interface{}{map[string]interface{}{"secretName": "web-tls"}},
+		}}),
+		testApplicationObject("networking.k8s.io/v1", "Ingress", "db", map[string]interface{}{"spec": map[string]interface{}{
+			"defaultBackend": map[string]interface{}{"service": map[string]interface{}{"name": "db"}},
+		}}),
+	}}}
+	rbac := &groupResource{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+		testApplicationObject("rbac.authorization.k8s.io/v1", "RoleBinding", "web", map[string]interface{}{
+			"roleRef":  map[string]interface{}{"kind": "Role", "name": "web"},
+			"subjects": []interface{}{map[string]interface{}{"kind": "ServiceAccount", "name": "web"}},
+		}),
+		testApplicationObject("rbac.authorization.k8s.io/v1", "Role", "web", nil),
+		testApplicationObject("rbac.authorization.k8s.io/v1", "Role", "db", nil),
+	}}}
+	tls := testApplicationObject("v1", "Secret", "web-tls", nil)
+	core.objects.Items = append(core.objects.Items, tls)
+
+	tests := []struct {
+		name         string
+		ownedBy      string
+		wantKept     []string
+		wantWarnings []string
+	}{
+		{
+			name:    "given a Deployment, should keep what it references and what selects or references it",
+			ownedBy: "Deployment/web",
+			wantKept: []string{
+				"ConfigMap/web-config", "Deployment/web", "Ingress/web", "Role/web", "RoleBinding/web",
+				"Secret/web-tls", "Service/web", "ServiceAccount/web",
+			},
+			wantWarnings: []string{"Secret/web-missing"},
+		},
+		{
+			name:     "given a lowercase kind, should match the kind",
+			ownedBy:  "deployment/db",
+			wantKept: []string{"ConfigMap/web-config", "Deployment/db", "Ingress/db", "Service/db"},
+		},
+		{
+			name:         "given an object that does not exist, should keep nothing",
+			ownedBy:      "Deployment/cache",
+			wantKept:     []string{},
+			wantWarnings: []string{"Deployment/cache not found"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resources := []*groupResource{}
+			for _, r := range []*groupResource{deployments, core, networking, rbac} {
+				copied := *r
+				copied.objects = r.objects.DeepCopy()
+				resources = append(resources, &copied)
+			}
+			selector, err := parseApplicationSelector(tt.ownedBy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			logs := &bytes.Buffer{}
+			log := logrus.New()
+			log.SetOutput(logs)
+			summary := newExportSummary("foo")
+
+			selector.filter("foo", resources, resources, summary, log)
+
+			kept := []string{}
+			for _, r := range resources {
+				for _, obj := range r.objects.Items {
+					kept = append(kept, obj.GetKind()+"/"+obj.GetName())
+				}
+			}
+			sort.Strings(kept)
+			if !reflect.DeepEqual(kept, tt.wantKept) {
+				t.Errorf("kept %v, want %v", kept, tt.wantKept)
+			}
+			for _, want := range tt.wantWarnings {
+				if !strings.Contains(logs.String(), want) {
+					t.Errorf("logs do not warn about %s:\n%s", want, logs.String())
+				}
+			}
+			if len(tt.wantWarnings) == 0 && strings.Contains(logs.String(), "level=warning") {
+				t.Errorf("unexpected warnings:\n%s", logs.String())
+			}
+		})
+	}
+}
+
+func Test_parseApplicationSelector(t *testing.T) {
+	for _, value := range []string{"Deployment", "Deployment/", "/web", "apps/Deployment/web"} {
+		if _, err := parseApplicationSelector(value); err == nil {
+			t.Errorf("parseApplicationSelector(%q) succeeded, want error", value)
+		}
+	}
+	if s, err := parseApplicationSelector("Deployment/web"); err != nil || s.kind != "Deployment" || s.name != "web" {
+		t.Errorf("parseApplicationSelector() = %v, %v", s, err)
+	}
+}
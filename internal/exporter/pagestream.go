@@ -0,0 +1,254 @@
+package exporter
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// streamedResources are the resources whose objects are written page by page as they are listed,
+// the ones a huge namespace has tens of thousands of. No step looking at several objects at once
+// reads them: they are filtered, prepared and rewritten one by one, and only referenced by the
+// other objects.
+var streamedResources = map[schema.GroupResource]bool{
+	{Group: "", Resource: "configmaps"}: true,
+	{Group: "", Resource: "secrets"}:    true,
+}
+
+// pageStream writes the objects of the streamed resources of a namespace as each page is listed,
+// instead of once every resource of the namespace is listed, so that all their objects are never
+// held in memory at once. Only the names of the objects are kept, for the summary and the
+// dependency graph. A nil pageStream streams nothing.
+type pageStream struct {
+	o         *ExportOptions
+	namespace string
+	exportRun *exportRun
+	writer    *resourceWriter
+	summary   *exportSummary
+	log       logrus.FieldLogger
+
+	// the resources are listed concurrently, their pages are written one at a time
+	mu sync.Mutex
+	// written are the objects already written, by resource, version and name: the pages listed
+	// again after an expired continue token are not written twice
+	written map[string]bool
+	// exported are the names of the objects written, by resource
+	exported map[*groupResource]*groupResource
+	order    []*groupResource
+	errs     []error
+	failures []FailureRecord
+}
+
+// newPageStream returns the stream of the namespace, nil when the objects must all be listed
+// before being written: with the single layout, which writes a single file, with --chunk-size 0,
+// with --owned-by, which selects the objects from the whole namespace, and when retrying failures
+func (o *ExportOptions) newPageStream(namespace string, exportRun *exportRun, writer *resourceWriter, summary *exportSummary, log logrus.FieldLogger) *pageStream {
+	if o.layout == LayoutSingle || o.chunkSize == 0 || o.application != nil || exportRun.retry != nil {
+		return nil
+	}
+	return &pageStream{
+		o:         o,
+		namespace: namespace,
+		exportRun: exportRun,
+		writer:    writer,
+		summary:   summary,
+		log:       log,
+		written:   map[string]bool{},
+		exported:  map[*groupResource]*groupResource{},
+	}
+}
+
+// onPage returns the function writing the pages of the resource, nil when it is not streamed
+func (s *pageStream) onPage(ctx context.Context, g *groupResource) func([]unstructured.Unstructured) {
+	if s == nil || !streamedResources[schema.GroupResource{Group: g.APIGroup, Resource: g.APIResource.Name}] {
+		return nil
+	}
+	return func(objects []unstructured.Unstructured) {
+		s.write(ctx, g, objects)
+	}
+}
+
+// write prepares and writes a page of objects of the resource like the objects listed at once are.
+// The large objects listed as stubs are fetched and written one at a time after the rest of the page.
+func (s *pageStream) write(ctx context.Context, g *groupResource, objects []unstructured.Unstructured) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	page := []unstructured.Unstructured{}
+	large := []unstructured.Unstructured{}
+	for _, obj := range objects {
+		key := GroupResourceName(g.APIGroup, g.APIResource.Name) + "/" + g.APIVersion + "/" + obj.GetNamespace() + "/" + obj.GetName()
+		if s.written[key] {
+			continue
+		}
+		s.written[key] = true
+		if isLargeStub(obj) {
+			large = append(large, obj)
+			continue
+		}
+		page = append(page, obj)
+	}
+	s.process(ctx, g, page)
+	for _, stub := range large {
+		if obj, ok := s.fetch(ctx, g, stub); ok {
+			s.process(ctx, g, []unstructured.Unstructured{obj})
+		}
+	}
+}
+
+// fetch gets a large object listed as a stub, false when it is not written: when it is above
+// --max-object-size, deleted since it was listed or cannot be fetched
+func (s *pageStream) fetch(ctx context.Context, g *groupResource, stub unstructured.Unstructured) (unstructured.Unstructured, bool) {
+	size, _ := strconv.Atoi(stub.GetAnnotations()[largeObjectAnnotation])
+	if s.o.objectLimit > 0 && int64(size) > s.o.objectLimit {
+		s.failures = append(s.failures, s.o.tooLargeFailure(g, stub, size, s.log))
+		return unstructured.Unstructured{}, false
+	}
+	obj, err := s.exportRun.streamer.get(ctx, g, stub)
+	if apierrors.IsNotFound(err) {
+		s.log.Debugf("%s %s was deleted since it was listed", stub.GetKind(), stub.GetName())
+		return obj, false
+	}
+	if err != nil {
+		s.log.WithError(err).Errorf("cannot get %s %s", stub.GetKind(), stub.GetName())
+		code, category := classifyError(err)
+		s.failures = append(s.failures, FailureRecord{
+			Operation:  "get",
+			Group:      g.APIGroup,
+			Version:    g.APIVersion,
+			Resource:   g.APIResource.Name,
+			Name:       stub.GetName(),
+			Error:      err.Error(),
+			StatusCode: code,
+			Category:   category,
+		})
+		return obj, false
+	}
+	return obj, true
+}
+
+// process prepares and writes objects of the resource not written yet
+func (s *pageStream) process(ctx context.Context, g *groupResource, objects []unstructured.Unstructured) {
+	if len(objects) == 0 {
+		return
+	}
+	page := *g
+	page.objects = &unstructured.UnstructuredList{Items: objects}
+
+	resources := []*groupResource{&page}
+	s.failures = append(s.failures, s.o.dropLargeObjects(resources, s.log)...)
+	if s.exportRun.helm != nil {
+		s.exportRun.helm.add(resources)
+	}
+	applyObjectFilters(resources, s.o.objectFilters(resources), s.summary, s.log)
+	s.failures = append(s.failures, s.o.prepareObjects(ctx, resources, s.summary, s.log)...)
+
+	// the names are kept before the objects are moved to --target-namespace, the summary and the
+	// graph are about the source namespace
+	exported, ok := s.exported[g]
+	if !ok {
+		exported = &groupResource{
+			APIGroup:            g.APIGroup,
+			APIVersion:          g.APIVersion,
+			APIGroupVersion:     g.APIGroupVersion,
+			APIResource:         g.APIResource,
+			nonPreferredVersion: g.nonPreferredVersion,
+			objects:             &unstructured.UnstructuredList{Items: []unstructured.Unstructured{}},
+		}
+		s.exported[g] = exported
+		s.order = append(s.order, g)
+	}
+	for _, obj := range page.objects.Items {
+		exported.objects.Items = append(exported.objects.Items, objectStub(obj))
+	}
+
+	s.o.rewriteObjects(s.namespace, resources, s.exportRun, s.summary)
+	s.errs = append(s.errs, s.writer.writeResource(&page)...)
+}
+
+// lister returns the client listing the resource, the streamer of the run for the streamed resources
+func (s *pageStream) lister(g *groupResource, namespace string, client dynamic.ResourceInterface) dynamic.ResourceInterface {
+	if s == nil || !streamedResources[schema.GroupResource{Group: g.APIGroup, Resource: g.APIResource.Name}] {
+		return client
+	}
+	return s.exportRun.streamer.lister(g, namespace, client)
+}
+
+// exclude returns the resources that are not streamed, their objects are written with the namespace
+func (s *pageStream) exclude(resources []*groupResource) []*groupResource {
+	if s == nil {
+		return resources
+	}
+	kept := []*groupResource{}
+	for _, r := range resources {
+		if !streamedResources[schema.GroupResource{Group: r.APIGroup, Resource: r.APIResource.Name}] {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// resources returns the streamed resources with the names of the objects written
+func (s *pageStream) resources() []*groupResource {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resources := make([]*groupResource, 0, len(s.order))
+	for _, g := range s.order {
+		resources = append(resources, s.exported[g])
+	}
+	return resources
+}
+
+// errors returns the objects of the streamed resources that could not be written
+func (s *pageStream) errors() []error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.errs
+}
+
+// failureRecords returns the objects of the streamed resources that were too large or could not
+// be transformed
+func (s *pageStream) failureRecords() []FailureRecord {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failures
+}
+
+// streamObjects writes the objects with onPage and returns what is kept of them in the list: the
+// names and versions the listing is recorded with
+func streamObjects(objects []unstructured.Unstructured, onPage func([]unstructured.Unstructured)) []unstructured.Unstructured {
+	stubs := make([]unstructured.Unstructured, 0, len(objects))
+	for _, obj := range objects {
+		stubs = append(stubs, objectStub(obj))
+	}
+	onPage(objects)
+	return stubs
+}
+
+// objectStub returns the identity of an object without its content
+func objectStub(obj unstructured.Unstructured) unstructured.Unstructured {
+	stub := unstructured.Unstructured{Object: map[string]interface{}{}}
+	stub.SetAPIVersion(obj.GetAPIVersion())
+	stub.SetKind(obj.GetKind())
+	stub.SetNamespace(obj.GetNamespace())
+	stub.SetName(obj.GetName())
+	if version := obj.GetResourceVersion(); version != "" {
+		stub.SetResourceVersion(version)
+	}
+	return stub
+}
@@ -0,0 +1,110 @@
+package exporter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_pageStream(t *testing.T) {
+	configMaps := &groupResource{APIVersion: "v1", APIResource: metav1.APIResource{Name: "configmaps", Namespaced: true, Kind: "ConfigMap"}}
+
+	tests := []struct {
+		name   string
+		layout string
+		file   string
+	}{
+		{name: "flat layout", layout: LayoutFlat, file: "configmaps_cm-0.yaml"},
+		{name: "kind layout", layout: LayoutKind, file: "core_configmaps/cm-0.yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the objects are prepared in place when written
+			objects := []unstructured.Unstructured{}
+			for _, obj := range testConfigMaps(7) {
+				u := *obj.(*unstructured.Unstructured)
+				u.SetResourceVersion("42")
+				if err := unstructured.SetNestedField(u.Object, "value", "data", "key"); err != nil {
+					t.Fatal(err)
+				}
+				objects = append(objects, u)
+			}
+			dir := t.TempDir()
+			o := &ExportOptions{layout: tt.layout, chunkSize: 3, output: OutputYAML, exportDir: dir, includeSystem: true}
+			exportRun := &exportRun{manifests: newExportIndex(dir)}
+			summary := newExportSummary("foo")
+			writer := o.newResourceWriter("foo", exportRun, testLogger())
+			stream := o.newPageStream("foo", exportRun, writer, summary, testLogger())
+			onPage := stream.onPage(context.Background(), configMaps)
+			if onPage == nil {
+				t.Fatalf("onPage() = nil, want the configmaps streamed")
+			}
+
+			// the continue token expires on the last page, the listing starts over
+			client := &pagedClient{objects: objects, expireAt: "6"}
+			list, err := resumePages(context.Background(), client, metav1.ListOptions{Limit: 3}, nil, onPage, testLogger())
+			if err != nil {
+				t.Fatalf("resumePages() error = %v", err)
+			}
+			if len(list.Items) != len(objects) {
+				t.Fatalf("resumePages() returned %d objects, want %d", len(list.Items), len(objects))
+			}
+			for _, obj := range list.Items {
+				if _, found := obj.Object["data"]; found {
+					t.Errorf("resumePages() kept the data of %s, want only its name", obj.GetName())
+				}
+				if obj.GetResourceVersion() != "42" {
+					t.Errorf("resumePages() dropped the resourceVersion of %s", obj.GetName())
+				}
+			}
+			// a listing started over from an expired checkpoint hands the objects again
+			onPage(objects)
+
+			if errs := stream.errors(); len(errs) > 0 {
+				t.Fatalf("stream errors = %v", errs)
+			}
+			if got := len(exportRun.manifests.entries); got != len(objects) {
+				t.Errorf("index entries = %d, want each configmap written once, %d", got, len(objects))
+			}
+			data, err := os.ReadFile(filepath.Join(dir, "resources", "foo", tt.file))
+			if err != nil {
+				t.Fatalf("the configmap was not written: %v", err)
+			}
+			if !strings.Contains(string(data), "key: value") {
+				t.Errorf("the configmap was written without its data:\n%s", data)
+			}
+			resources := stream.resources()
+			if len(resources) != 1 || len(resources[0].objects.Items) != len(objects) {
+				t.Errorf("stream resources = %v, want the %d configmaps", resources, len(objects))
+			}
+		})
+	}
+}
+
+func TestExportOptions_newPageStream(t *testing.T) {
+	tests := []struct {
+		name      string
+		o         *ExportOptions
+		exportRun *exportRun
+		want      bool
+	}{
+		{name: "flat layout with --chunk-size", o: &ExportOptions{layout: LayoutFlat, chunkSize: 500}, exportRun: &exportRun{}, want: true},
+		{name: "kind layout with --chunk-size", o: &ExportOptions{layout: LayoutKind, chunkSize: 500}, exportRun: &exportRun{}, want: true},
+		{name: "single layout", o: &ExportOptions{layout: LayoutSingle, chunkSize: 500}, exportRun: &exportRun{}},
+		{name: "without --chunk-size", o: &ExportOptions{layout: LayoutFlat}, exportRun: &exportRun{}},
+		{name: "with --owned-by", o: &ExportOptions{layout: LayoutFlat, chunkSize: 500, application: &applicationSelector{}}, exportRun: &exportRun{}},
+		{name: "with --retry-failures", o: &ExportOptions{layout: LayoutFlat, chunkSize: 500}, exportRun: &exportRun{retry: &failureRetry{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.o.newPageStream("foo", tt.exportRun, nil, nil, testLogger()) != nil; got != tt.want {
+				t.Errorf("newPageStream() streams = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
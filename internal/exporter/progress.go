@@ -0,0 +1,392 @@
+package exporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	progressFile = "progress.json"
+	// progressDir keeps the objects listed so far, a resumed export reads them instead of
+	// listing their resources again
+	progressDir = ".progress"
+)
+
+// resumeRunFlags are the flags of the interrupted export not applied when it is resumed, they
+// describe the run rather than the exported content
+var resumeRunFlags = map[string]bool{
+	"config":         true,
+	"export-dir":     true,
+	"overwrite":      true,
+	"dry-run":        true,
+	"resume":         true,
+	"metrics-file":   true,
+	"timeout":        true,
+	"skip-preflight": true,
+	"verbosity":      true,
+	"quiet":          true,
+	"log-format":     true,
+}
+
+// validateResume checks the flags of a --resume export, it goes on in the export directory of the
+// interrupted export
+func (o *ExportOptions) validateResume() error {
+	templated := false
+	walkPath(o.exportDir, func(string) (string, error) {
+		templated = true
+		return "", nil
+	})
+	switch {
+	case templated:
+		return fmt.Errorf("--resume needs the export directory of the interrupted export, --export-dir cannot contain tokens")
+	case o.dryRun, o.overwrite, o.archive, o.retryFailures:
+		return fmt.Errorf("--resume cannot be used with --dry-run, --overwrite, --archive or --retry-failures")
+	}
+	return nil
+}
+
+// exportProgress is the checkpoint of an export, written to progress.json in the export directory
+// after each page listed. The objects of each page are appended to a file of the resource under
+// .progress, so that an export stopped before its end can be resumed with --resume: the
+// resources listed completely are read back, the one being listed continues from its last page.
+// The checkpoint is removed once the export runs to its end.
+type exportProgress struct {
+	StartTime time.Time         `json:"startTime"`
+	Flags     map[string]string `json:"flags"`
+	// Namespaces are the resources listed by namespace, by group/version/resource
+	Namespaces map[string]map[string]*resourceProgress `json:"namespaces"`
+
+	exportDir string
+	log       logrus.FieldLogger
+	mu        sync.Mutex
+	// disabled is set once the checkpoint could not be written, the export goes on without it
+	disabled bool
+}
+
+// resourceProgress is the listing of a resource in a namespace
+type resourceProgress struct {
+	Complete bool `json:"complete"`
+	// Continue is the token of the next page, ResourceVersion the one of the first page
+	Continue        string `json:"continue,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	Pages           int    `json:"pages"`
+	Objects         int    `json:"objects"`
+	// Size is the length of the objects file, what was written after it is from a page the
+	// checkpoint did not record
+	Size int64 `json:"size"`
+}
+
+// newExportProgress starts the checkpoint of a new export in exportDir, the one of a previous
+// export is replaced
+func newExportProgress(exportDir string, start time.Time, flags map[string]string, log logrus.FieldLogger) *exportProgress {
+	p := &exportProgress{
+		StartTime:  start.UTC(),
+		Flags:      flags,
+		Namespaces: map[string]map[string]*resourceProgress{},
+		exportDir:  exportDir,
+		log:        log,
+	}
+	if err := os.RemoveAll(filepath.Join(exportDir, progressDir)); err != nil {
+		p.disable(err)
+		return p
+	}
+	if err := os.MkdirAll(exportDir, 0700); err != nil {
+		p.disable(err)
+		return p
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.save()
+	return p
+}
+
+// loadExportProgress reads the checkpoint of the export in exportDir to resume it
+func loadExportProgress(exportDir string) (*exportProgress, error) {
+	progressBytes, err := os.ReadFile(filepath.Join(exportDir, progressFile))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s has no export to resume, %s not found", exportDir, progressFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p := &exportProgress{}
+	if err := json.Unmarshal(progressBytes, p); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", progressFile, err)
+	}
+	if p.Namespaces == nil {
+		p.Namespaces = map[string]map[string]*resourceProgress{}
+	}
+	p.exportDir = exportDir
+	return p, nil
+}
+
+// applyFlags sets the flags not given on the command line to their value in the interrupted
+// export, so that the resumed one exports the same content
+func (p *exportProgress) applyFlags(flags *pflag.FlagSet, log logrus.FieldLogger) error {
+	for _, name := range sortedKeys(p.Flags) {
+		f := flags.Lookup(name)
+		if f == nil || f.Changed || resumeRunFlags[name] {
+			continue
+		}
+		if err := f.Value.Set(recordedValue(f, p.Flags[name])); err != nil {
+			return fmt.Errorf("invalid value of --%s in %s: %w", name, progressFile, err)
+		}
+		log.Debugf("using --%s=%s of the interrupted export", name, p.Flags[name])
+	}
+	return nil
+}
+
+// resume prepares the checkpoint loaded from the export directory to be updated by the resumed
+// export, the flags it records are those of the resumed export
+func (p *exportProgress) resume(flags map[string]string, log logrus.FieldLogger) {
+	p.Flags = flags
+	p.log = log
+	listed := 0
+	for _, resources := range p.Namespaces {
+		for _, r := range resources {
+			if r.Complete {
+				listed++
+			}
+		}
+	}
+	log.Infof("Resuming the export started at %s, %d resources already listed are not listed again", p.StartTime.Format(time.RFC3339), listed)
+}
+
+// resource returns the checkpoint of the resource in the namespace, nil without checkpoint
+func (p *exportProgress) resource(namespace string, g *groupResource) *resourceCheckpoint {
+	if p == nil {
+		return nil
+	}
+	key := g.APIGroupVersion + "/" + g.APIResource.Name
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Namespaces[namespace] == nil {
+		p.Namespaces[namespace] = map[string]*resourceProgress{}
+	}
+	if p.Namespaces[namespace][key] == nil {
+		p.Namespaces[namespace][key] = &resourceProgress{}
+	}
+	return &resourceCheckpoint{
+		progress: p,
+		state:    p.Namespaces[namespace][key],
+		path:     filepath.Join(p.exportDir, progressDir, namespace, strings.ReplaceAll(key, "/", "_")+".json"),
+	}
+}
+
+// remove deletes the checkpoint once the export ran to its end
+func (p *exportProgress) remove() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.disabled = true
+	for _, path := range []string{progressFile, progressDir} {
+		if err := os.RemoveAll(filepath.Join(p.exportDir, path)); err != nil {
+			p.log.Warnf("cannot remove the checkpoint %s: %v", path, err)
+		}
+	}
+}
+
+// save writes progress.json, it is replaced at once so that an export stopped while writing it
+// leaves the previous one. p.mu must be held.
+func (p *exportProgress) save() {
+	if p.disabled {
+		return
+	}
+	progressBytes, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		p.disable(err)
+		return
+	}
+	path := filepath.Join(p.exportDir, progressFile)
+	if err := os.WriteFile(path+".tmp", append(progressBytes, '\n'), 0600); err != nil {
+		p.disable(err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		p.disable(err)
+	}
+}
+
+// disable stops updating the checkpoint after an error, a checkpoint must not fail the export
+func (p *exportProgress) disable(err error) {
+	p.log.Warnf("cannot write the checkpoint of the export, it cannot be resumed: %v", err)
+	p.disabled = true
+}
+
+// resourceCheckpoint records the pages of a resource as they are listed. Its methods do nothing
+// on a nil checkpoint.
+type resourceCheckpoint struct {
+	progress *exportProgress
+	state    *resourceProgress
+	// path is the file of the listed objects, one JSON object per line
+	path string
+}
+
+// completed returns the objects of the resource when it was listed completely by the interrupted
+// export
+func (c *resourceCheckpoint) completed() (*unstructured.UnstructuredList, int, bool) {
+	if c == nil || !c.state.Complete {
+		return nil, 0, false
+	}
+	list, err := c.read()
+	if err != nil {
+		c.progress.log.Warnf("cannot read the objects listed by the interrupted export from %s, listing them again: %v", c.path, err)
+		c.reset()
+		return nil, 0, false
+	}
+	return list, c.state.Pages, true
+}
+
+// partial returns the objects of the pages listed by the interrupted export when the resource
+// was being listed, with the continue token of the next page
+func (c *resourceCheckpoint) partial() (*unstructured.UnstructuredList, bool) {
+	if c == nil || c.state.Complete || c.state.Continue == "" {
+		return nil, false
+	}
+	list, err := c.read()
+	if err != nil {
+		c.progress.log.Warnf("cannot read the objects listed by the interrupted export from %s, listing them again: %v", c.path, err)
+		c.reset()
+		return nil, false
+	}
+	list.SetContinue(c.state.Continue)
+	return list, true
+}
+
+// read reads the objects of the pages recorded in the checkpoint
+func (c *resourceCheckpoint) read() (*unstructured.UnstructuredList, error) {
+	f, err := os.Open(c.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	list := &unstructured.UnstructuredList{Items: []unstructured.Unstructured{}}
+	list.SetResourceVersion(c.state.ResourceVersion)
+	decoder := json.NewDecoder(bufio.NewReader(io.LimitReader(f, c.state.Size)))
+	for {
+		obj := map[string]interface{}{}
+		err := decoder.Decode(&obj)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, unstructured.Unstructured{Object: obj})
+	}
+	if len(list.Items) != c.state.Objects {
+		return nil, fmt.Errorf("%d objects found, %d recorded", len(list.Items), c.state.Objects)
+	}
+	return list, nil
+}
+
+// reset forgets the pages listed, the resource is listed again from the start
+func (c *resourceCheckpoint) reset() {
+	if c == nil {
+		return
+	}
+	c.progress.mu.Lock()
+	defer c.progress.mu.Unlock()
+	*c.state = resourceProgress{}
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		c.progress.disable(err)
+		return
+	}
+	c.progress.save()
+}
+
+// page records a page listed: the objects not received before and the token of the next page,
+// empty after the last one
+func (c *resourceCheckpoint) page(objects []unstructured.Unstructured, resourceVersion, continueToken string) {
+	if c == nil {
+		return
+	}
+	c.progress.mu.Lock()
+	defer c.progress.mu.Unlock()
+	if c.progress.disabled {
+		return
+	}
+	written, err := c.append(objects)
+	if err != nil {
+		c.progress.disable(err)
+		return
+	}
+	c.state.Pages++
+	c.state.Objects += len(objects)
+	c.state.Size += written
+	c.state.ResourceVersion = resourceVersion
+	c.state.Continue = continueToken
+	c.state.Complete = continueToken == ""
+	c.progress.save()
+}
+
+// append writes the objects at the end of the recorded ones, what a page the checkpoint did not
+// record left after them is overwritten
+func (c *resourceCheckpoint) append(objects []unstructured.Unstructured) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(c.path, os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if err := f.Truncate(c.state.Size); err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(c.state.Size, io.SeekStart); err != nil {
+		return 0, err
+	}
+	w := bufio.NewWriter(f)
+	written := int64(0)
+	for _, obj := range objects {
+		objBytes, err := json.Marshal(obj.Object)
+		if err != nil {
+			return 0, err
+		}
+		n, err := w.Write(append(objBytes, '\n'))
+		if err != nil {
+			return 0, err
+		}
+		written += int64(n)
+	}
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+	return written, nil
+}
+
+// replace records the objects of the resource as listed completely, in place of its pages, for
+// the resources whose objects are read one by one after they are listed
+func (c *resourceCheckpoint) replace(list *unstructured.UnstructuredList) {
+	if c == nil {
+		return
+	}
+	c.progress.mu.Lock()
+	defer c.progress.mu.Unlock()
+	if c.progress.disabled {
+		return
+	}
+	pages := c.state.Pages
+	*c.state = resourceProgress{}
+	written, err := c.append(list.Items)
+	if err != nil {
+		c.progress.disable(err)
+		return
+	}
+	*c.state = resourceProgress{Complete: true, ResourceVersion: list.GetResourceVersion(), Pages: pages, Objects: len(list.Items), Size: written}
+	c.progress.save()
+}
@@ -0,0 +1,160 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// stoppedClient is a pagedClient failing the request of a continue token, like an export stopped
+// while listing the resource
+type stoppedClient struct {
+	*pagedClient
+	stopAt string
+}
+
+func (c *stoppedClient) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	if opts.Continue == c.stopAt {
+		return nil, errors.New("connection reset by peer")
+	}
+	return c.pagedClient.List(ctx, opts)
+}
+
+func Test_resumePages(t *testing.T) {
+	objects := []unstructured.Unstructured{}
+	for _, obj := range testConfigMaps(7) {
+		objects = append(objects, *obj.(*unstructured.Unstructured))
+	}
+	configMaps := &groupResource{APIGroupVersion: "v1", APIVersion: "v1", APIResource: metav1.APIResource{Name: "configmaps", Kind: "ConfigMap", Namespaced: true}}
+	tests := []struct {
+		name string
+		// garbage is written after the recorded pages, like a page the checkpoint did not record
+		garbage   bool
+		expireAt  string
+		wantCalls []string
+	}{
+		{
+			name:      "given an export stopped at the third page, should resume after the second one",
+			wantCalls: []string{"6"},
+		},
+		{
+			name:      "given objects written after the last page recorded, should ignore them",
+			garbage:   true,
+			wantCalls: []string{"6"},
+		},
+		{
+			name:      "given an expired continue token, should list again from the start",
+			expireAt:  "6",
+			wantCalls: []string{"6", "", "3", "6"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exportDir := t.TempDir()
+			progress := newExportProgress(exportDir, time.Now(), map[string]string{"namespace": "[foo]"}, testLogger())
+			stopped := &stoppedClient{pagedClient: &pagedClient{objects: objects}, stopAt: "6"}
+			if _, err := resumePages(context.Background(), stopped, metav1.ListOptions{Limit: 3}, progress.resource("foo", configMaps), nil, testLogger()); err == nil {
+				t.Fatalf("resumePages() of a stopped export succeeded, want error")
+			}
+			checkpoint := progress.resource("foo", configMaps)
+			if tt.garbage {
+				f, err := os.OpenFile(checkpoint.path, os.O_APPEND|os.O_WRONLY, 0600)
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.WriteString(`{"kind":"ConfigMap"}` + "\n{")
+				f.Close()
+			}
+
+			resumed, err := loadExportProgress(exportDir)
+			if err != nil {
+				t.Fatalf("loadExportProgress() error = %v", err)
+			}
+			if resumed.Flags["namespace"] != "[foo]" {
+				t.Errorf("loadExportProgress() flags = %v, want the namespace recorded", resumed.Flags)
+			}
+			resumed.resume(resumed.Flags, testLogger())
+			client := &pagedClient{objects: objects, expireAt: tt.expireAt}
+			list, err := resumePages(context.Background(), client, metav1.ListOptions{Limit: 3}, resumed.resource("foo", configMaps), nil, testLogger())
+			if err != nil {
+				t.Fatalf("resumePages() error = %v", err)
+			}
+			calls := []string{}
+			for _, c := range client.calls {
+				calls = append(calls, c.Continue)
+			}
+			if len(calls) != len(tt.wantCalls) {
+				t.Fatalf("resumePages() made the requests %q, want %q", calls, tt.wantCalls)
+			}
+			for i := range calls {
+				if calls[i] != tt.wantCalls[i] {
+					t.Errorf("resumePages() made the requests %q, want %q", calls, tt.wantCalls)
+				}
+			}
+			if len(list.Items) != len(objects) {
+				t.Fatalf("resumePages() returned %d objects, want %d", len(list.Items), len(objects))
+			}
+			for i, obj := range list.Items {
+				if obj.GetName() != objects[i].GetName() {
+					t.Errorf("resumePages() object %d = %s, want %s", i, obj.GetName(), objects[i].GetName())
+				}
+			}
+
+			// the resource listed completely is read back by the next resumed export
+			again, err := loadExportProgress(exportDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			read, _, ok := again.resource("foo", configMaps).completed()
+			if !ok || len(read.Items) != len(objects) {
+				t.Fatalf("completed() = %v, want the %d objects listed", ok, len(objects))
+			}
+			if read.Items[6].GetName() != "cm-6" {
+				t.Errorf("completed() object 6 = %s, want cm-6", read.Items[6].GetName())
+			}
+
+			again.remove()
+			for _, path := range []string{progressFile, progressDir} {
+				if _, err := os.Stat(filepath.Join(exportDir, path)); !os.IsNotExist(err) {
+					t.Errorf("remove() left %s: %v", path, err)
+				}
+			}
+		})
+	}
+}
+
+func Test_loadExportProgress(t *testing.T) {
+	exportDir := t.TempDir()
+	if _, err := loadExportProgress(exportDir); err == nil {
+		t.Errorf("loadExportProgress() of a directory without checkpoint succeeded, want error")
+	}
+	if err := os.WriteFile(filepath.Join(exportDir, progressFile), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadExportProgress(exportDir); err == nil {
+		t.Errorf("loadExportProgress() of an invalid checkpoint succeeded, want error")
+	}
+}
+
+func Test_prepareExportDir_progress(t *testing.T) {
+	exportDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(exportDir, "resources", "foo"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	newExportProgress(exportDir, time.Now(), nil, testLogger())
+	if err := prepareExportDir(exportDir, false, testLogger()); err == nil {
+		t.Errorf("prepareExportDir() of an unfinished export succeeded, want error")
+	}
+	if err := prepareExportDir(exportDir, true, testLogger()); err != nil {
+		t.Fatalf("prepareExportDir() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(exportDir, progressFile)); !os.IsNotExist(err) {
+		t.Errorf("prepareExportDir() with overwrite left %s: %v", progressFile, err)
+	}
+}
@@ -0,0 +1,142 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// ManagedLabel is set on every object applied by replay, only the objects carrying it are pruned
+	ManagedLabel = "migrate.konveyor.io/managed"
+
+	ApplyPruned = "pruned"
+	// tierPrune is the tier of the results of the pruned objects
+	tierPrune = "prune"
+)
+
+// pruneCandidate is a managed object of a target namespace that is not in the export
+type pruneCandidate struct {
+	Resource schema.GroupVersionResource
+	Object   unstructured.Unstructured
+}
+
+func (c pruneCandidate) String() string {
+	return fmt.Sprintf("%s %s/%s", c.Object.GetKind(), c.Object.GetNamespace(), c.Object.GetName())
+}
+
+// pruner deletes the objects applied by a previous replay that are no longer in the export. Only
+// the namespaced objects of the target namespaces carrying managedLabel are considered, and the
+// ones owned by another object are left to the garbage collector.
+type pruner struct {
+	client dynamic.Interface
+	// resources are the namespaced resources of the target cluster that can be listed and deleted
+	resources []*metav1.APIResourceList
+	// allowlist limits the pruned kinds with --prune-allowlist, e.g. Deployment or Route.route.openshift.io
+	allowlist []string
+	dryRun    bool
+	log       logrus.FieldLogger
+}
+
+// NewPruner returns the pruner of the resources, limited to the kinds of allowlist when not empty
+func NewPruner(client dynamic.Interface, resources []*metav1.APIResourceList, allowlist []string, dryRun bool, log logrus.FieldLogger) *pruner {
+	return &pruner{client: client, resources: resources, allowlist: allowlist, dryRun: dryRun, log: log}
+}
+
+// allowed reports whether objects of the kind may be pruned
+func (p *pruner) allowed(group string, kind string) bool {
+	if len(p.allowlist) == 0 {
+		return true
+	}
+	for _, allowed := range p.allowlist {
+		allowedKind, allowedGroup, hasGroup := strings.Cut(allowed, ".")
+		if strings.EqualFold(allowedKind, kind) && (!hasGroup || allowedGroup == group) {
+			return true
+		}
+	}
+	return false
+}
+
+// Candidates lists the managed objects of the namespaces that are not in exported, keyed by
+// replayKey. The resources that cannot be listed are returned as failed results, their objects
+// are not pruned.
+func (p *pruner) Candidates(ctx context.Context, namespaces []string, exported map[string]bool) ([]pruneCandidate, []ApplyResult) {
+	candidates := []pruneCandidate{}
+	failures := []ApplyResult{}
+	selector := metav1.ListOptions{LabelSelector: ManagedLabel + "=true"}
+	for _, list := range p.resources {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			if !resource.Namespaced || strings.Contains(resource.Name, "/") || !p.allowed(gv.Group, resource.Kind) ||
+				!containsString(resource.Verbs, "list") || !containsString(resource.Verbs, "delete") {
+				continue
+			}
+			gvr := gv.WithResource(resource.Name)
+			for _, namespace := range namespaces {
+				objects, err := p.client.Resource(gvr).Namespace(namespace).List(ctx, selector)
+				if err != nil {
+					p.log.Errorf("cannot list %s in namespace %s to prune them: %v", resource.Name, namespace, err)
+					failures = append(failures, ApplyResult{Tier: tierPrune, Namespace: namespace, APIVersion: list.GroupVersion, Kind: resource.Kind, Result: ApplyFailed, Error: err.Error()})
+					continue
+				}
+				for _, obj := range objects.Items {
+					// the label selector is checked again, the objects outside the namespaces are never pruned
+					if obj.GetLabels()[ManagedLabel] != "true" || obj.GetNamespace() != namespace || len(obj.GetOwnerReferences()) > 0 {
+						continue
+					}
+					obj.SetAPIVersion(list.GroupVersion)
+					obj.SetKind(resource.Kind)
+					if !exported[ReplayKey(obj)] {
+						candidates = append(candidates, pruneCandidate{Resource: gvr, Object: obj})
+					}
+				}
+			}
+		}
+	}
+	return candidates, failures
+}
+
+// Prune deletes the candidates and returns their results
+func (p *pruner) Prune(ctx context.Context, candidates []pruneCandidate) []ApplyResult {
+	results := []ApplyResult{}
+	options := metav1.DeleteOptions{}
+	if p.dryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	for _, c := range candidates {
+		result := ApplyResult{Tier: tierPrune, Namespace: c.Object.GetNamespace(), APIVersion: c.Object.GetAPIVersion(), Kind: c.Object.GetKind(), Name: c.Object.GetName(), Result: ApplyPruned}
+		// the object is only deleted if it was not replaced since it was listed
+		uid := c.Object.GetUID()
+		options.Preconditions = &metav1.Preconditions{UID: &uid}
+		if err := p.client.Resource(c.Resource).Namespace(c.Object.GetNamespace()).Delete(ctx, c.Object.GetName(), options); err != nil {
+			result.Result, result.Error = ApplyFailed, err.Error()
+			p.log.Errorf("cannot prune %s: %v", c, err)
+		} else {
+			p.log.Infof("pruned %s", c)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// PrintPruneCandidates prints the objects --prune would delete without --yes
+func PrintPruneCandidates(out io.Writer, candidates []pruneCandidate) {
+	if len(candidates) == 0 {
+		fmt.Fprintf(out, "No object to prune\n")
+		return
+	}
+	fmt.Fprintf(out, "%d objects are not in the export and would be pruned, run again with --yes to delete them:\n", len(candidates))
+	for _, c := range candidates {
+		fmt.Fprintf(out, "  %s\n", c)
+	}
+}
@@ -0,0 +1,110 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func Test_pruner(t *testing.T) {
+	object := func(apiVersion, kind, namespace, name string, managed bool) *unstructured.Unstructured {
+		obj := testReplayObject(apiVersion, kind, namespace, name)
+		if managed {
+			obj.SetLabels(map[string]string{ManagedLabel: "true"})
+		}
+		return &obj
+	}
+	owned := object("apps/v1", "ReplicaSet", "bar", "web-abc", true)
+	owned.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"}})
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			{Version: "v1", Resource: "configmaps"}:                 "ConfigMapList",
+			{Group: "apps", Version: "v1", Resource: "deployments"}: "DeploymentList",
+			{Group: "apps", Version: "v1", Resource: "replicasets"}: "ReplicaSetList",
+		},
+		object("apps/v1", "Deployment", "bar", "web", true),
+		object("apps/v1", "Deployment", "bar", "removed", true),
+		object("apps/v1", "Deployment", "bar", "unmanaged", false),
+		object("apps/v1", "Deployment", "other", "removed", true),
+		object("v1", "ConfigMap", "bar", "removed", true),
+		owned,
+	)
+	resources := []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: []string{"list", "delete"}},
+			{Name: "namespaces", Kind: "Namespace", Verbs: []string{"list", "delete"}},
+		}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "deployments", Kind: "Deployment", Namespaced: true, Verbs: []string{"list", "delete"}},
+			{Name: "deployments/scale", Kind: "Scale", Namespaced: true, Verbs: []string{"get"}},
+			{Name: "replicasets", Kind: "ReplicaSet", Namespaced: true, Verbs: []string{"list", "delete"}},
+		}},
+	}
+	exported := map[string]bool{ReplayKey(*object("apps/v1", "Deployment", "bar", "web", true)): true}
+	names := func(candidates []pruneCandidate) []string {
+		got := []string{}
+		for _, c := range candidates {
+			got = append(got, c.String())
+		}
+		return got
+	}
+
+	tests := []struct {
+		name      string
+		allowlist []string
+		want      []string
+	}{
+		{
+			name: "given no allowlist, should return the managed objects of the namespace missing from the export",
+			want: []string{"ConfigMap bar/removed", "Deployment bar/removed"},
+		},
+		{
+			name:      "given an allowlist, should only return the allowed kinds",
+			allowlist: []string{"deployment.apps"},
+			want:      []string{"Deployment bar/removed"},
+		},
+		{
+			name:      "given an allowlist of another group, should return nothing",
+			allowlist: []string{"Deployment.apps.openshift.io"},
+			want:      []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &pruner{client: client, resources: resources, allowlist: tt.allowlist, log: testLogger()}
+			candidates, failures := p.Candidates(context.Background(), []string{"bar"}, exported)
+			if len(failures) > 0 {
+				t.Fatalf("candidates() failures = %+v", failures)
+			}
+			if got := names(candidates); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("candidates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	p := &pruner{client: client, resources: resources, log: testLogger()}
+	candidates, _ := p.Candidates(context.Background(), []string{"bar"}, exported)
+	out := &bytes.Buffer{}
+	PrintPruneCandidates(out, candidates)
+	if want := "2 objects are not in the export and would be pruned, run again with --yes to delete them:\n  ConfigMap bar/removed\n  Deployment bar/removed\n"; out.String() != want {
+		t.Errorf("printPruneCandidates() = %q, want %q", out.String(), want)
+	}
+	for _, result := range p.Prune(context.Background(), candidates) {
+		if result.Result != ApplyPruned {
+			t.Errorf("prune() %s %s = %s %s", result.Kind, result.Name, result.Result, result.Error)
+		}
+	}
+	if candidates, _ := p.Candidates(context.Background(), []string{"bar"}, exported); len(candidates) != 0 {
+		t.Errorf("candidates() after prune() = %v, want none", names(candidates))
+	}
+	if _, err := client.Resource(schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}).Namespace("other").Get(context.Background(), "removed", metav1.GetOptions{}); err != nil {
+		t.Errorf("prune() should not delete the objects of other namespaces: %v", err)
+	}
+}
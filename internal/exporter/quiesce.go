@@ -0,0 +1,170 @@
+package exporter
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/metadata"
+)
+
+// Changes of the objects found by --quiesce-check
+const (
+	DriftAdded    = "added"
+	DriftRemoved  = "removed"
+	DriftModified = "modified"
+)
+
+// listRecord describes the listing of a resource in a namespace. Each resource is listed at its own
+// time, the export is not a consistent snapshot of the namespace, so the resourceVersion and the
+// time of each list are recorded to audit it.
+type listRecord struct {
+	Resource        string    `json:"resource"`
+	Version         string    `json:"version"`
+	ResourceVersion string    `json:"resourceVersion,omitempty"`
+	ListedAt        time.Time `json:"listedAt"`
+
+	gvr        schema.GroupVersionResource
+	namespaced bool
+	// resource is the resource listed, without its objects, kept in sync by --watch
+	resource *groupResource
+	// objects are the resourceVersions of the listed objects by namespace/name
+	objects map[string]string
+}
+
+// driftedObject is an object added, removed or modified after its resource was listed
+type driftedObject struct {
+	Resource string `json:"resource"`
+	Name     string `json:"name"`
+	Change   string `json:"change"`
+}
+
+func (d driftedObject) String() string {
+	return d.Change + " " + d.Resource + " " + d.Name
+}
+
+// listSnapshot records the lists of the resources of a namespace. It is safe for concurrent use and
+// a nil listSnapshot records nothing.
+type listSnapshot struct {
+	mu      sync.Mutex
+	records []listRecord
+}
+
+func newListSnapshot() *listSnapshot {
+	return &listSnapshot{}
+}
+
+// record records a successful listing of the resource started at listedAt, before any object is
+// filtered out
+func (s *listSnapshot) record(r *groupResource, listedAt time.Time) {
+	if s == nil || r.objects == nil {
+		return
+	}
+	resource := *r
+	resource.objects = nil
+	objects := make(map[string]string, len(r.objects.Items))
+	for _, obj := range r.objects.Items {
+		objects[obj.GetNamespace()+"/"+obj.GetName()] = obj.GetResourceVersion()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, listRecord{
+		Resource:        GroupResourceName(r.APIGroup, r.APIResource.Name),
+		Version:         r.APIVersion,
+		ResourceVersion: r.objects.GetResourceVersion(),
+		ListedAt:        listedAt.UTC(),
+		gvr:             schema.GroupVersionResource{Group: r.APIGroup, Version: r.APIVersion, Resource: r.APIResource.Name},
+		namespaced:      r.APIResource.Namespaced,
+		resource:        &resource,
+		objects:         objects,
+	})
+}
+
+// lists returns the recorded lists in the order they were started
+func (s *listSnapshot) lists() []listRecord {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := append([]listRecord{}, s.records...)
+	sort.SliceStable(records, func(i, j int) bool {
+		if !records[i].ListedAt.Equal(records[j].ListedAt) {
+			return records[i].ListedAt.Before(records[j].ListedAt)
+		}
+		return records[i].Resource < records[j].Resource
+	})
+	return records
+}
+
+// quiesceCheck lists again the metadata of the resources of the namespace at the end of the export
+// and returns the objects that changed after their resource was listed. A resource that cannot be
+// listed again is skipped with a warning, the check does not fail the export.
+func quiesceCheck(ctx context.Context, client metadata.Interface, namespace string, listOptions metav1.ListOptions, records []listRecord, log logrus.FieldLogger) []driftedObject {
+	drift := []driftedObject{}
+	for _, r := range records {
+		current, err := listMetadata(ctx, client, namespace, r, listOptions)
+		if err != nil {
+			log.Warnf("cannot list %s again to check for changes during the export: %v", r.Resource, err)
+			continue
+		}
+		drift = append(drift, compareLists(r, current)...)
+	}
+	for _, d := range drift {
+		log.Warnf("Changed during the export: %s", d)
+	}
+	return drift
+}
+
+// listMetadata returns the resourceVersions of the objects of a resource by namespace/name
+func listMetadata(ctx context.Context, client metadata.Interface, namespace string, r listRecord, listOptions metav1.ListOptions) (map[string]string, error) {
+	var lister metadata.ResourceInterface = client.Resource(r.gvr)
+	if r.namespaced {
+		lister = client.Resource(r.gvr).Namespace(namespace)
+	}
+	objects := map[string]string{}
+	listOptions.Continue = ""
+	for {
+		page, err := lister.List(ctx, listOptions)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Items {
+			objects[obj.Namespace+"/"+obj.Name] = obj.ResourceVersion
+		}
+		listOptions.Continue = page.Continue
+		if listOptions.Continue == "" {
+			return objects, nil
+		}
+	}
+}
+
+// compareLists returns the objects added, removed or modified since the list was recorded
+func compareLists(r listRecord, current map[string]string) []driftedObject {
+	drift := []driftedObject{}
+	// the keys are namespace/name, the namespace being the exported one
+	keyName := func(key string) string {
+		_, name, _ := strings.Cut(key, "/")
+		return name
+	}
+	for _, key := range sortedKeys(r.objects) {
+		version, found := current[key]
+		switch {
+		case !found:
+			drift = append(drift, driftedObject{Resource: r.Resource, Name: keyName(key), Change: DriftRemoved})
+		case version != r.objects[key]:
+			drift = append(drift, driftedObject{Resource: r.Resource, Name: keyName(key), Change: DriftModified})
+		}
+	}
+	for _, key := range sortedKeys(current) {
+		if _, found := r.objects[key]; !found {
+			drift = append(drift, driftedObject{Resource: r.Resource, Name: keyName(key), Change: DriftAdded})
+		}
+	}
+	return drift
+}
@@ -0,0 +1,117 @@
+package exporter
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	metadatafake "k8s.io/client-go/metadata/fake"
+)
+
+func testObjectMeta(name string, resourceVersion string) *metav1.PartialObjectMetadata {
+	return &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "foo", ResourceVersion: resourceVersion},
+	}
+}
+
+func testListedConfigMaps(resourceVersions map[string]string) *groupResource {
+	list := &unstructured.UnstructuredList{}
+	list.SetResourceVersion("100")
+	for _, name := range sortedKeys(resourceVersions) {
+		obj := unstructured.Unstructured{}
+		obj.SetName(name)
+		obj.SetNamespace("foo")
+		obj.SetResourceVersion(resourceVersions[name])
+		list.Items = append(list.Items, obj)
+	}
+	return &groupResource{
+		APIVersion:  "v1",
+		APIResource: metav1.APIResource{Name: "configmaps", Namespaced: true},
+		objects:     list,
+	}
+}
+
+func Test_listSnapshot(t *testing.T) {
+	start := time.Date(2024, 3, 9, 14, 5, 7, 0, time.UTC)
+	snapshot := newListSnapshot()
+	snapshot.record(&groupResource{APIGroup: "apps", APIVersion: "v1", APIResource: metav1.APIResource{Name: "deployments"}, objects: &unstructured.UnstructuredList{}}, start.Add(time.Second))
+	snapshot.record(testListedConfigMaps(map[string]string{"cm-0": "1"}), start)
+	// a failed list has no objects and is not recorded
+	snapshot.record(&groupResource{APIVersion: "v1", APIResource: metav1.APIResource{Name: "secrets"}}, start)
+
+	lists := snapshot.lists()
+	if len(lists) != 2 || lists[0].Resource != "configmaps" || lists[1].Resource != "deployments.apps" {
+		t.Fatalf("lists() = %+v, want configmaps then deployments.apps", lists)
+	}
+	if lists[0].ResourceVersion != "100" || !lists[0].ListedAt.Equal(start) {
+		t.Errorf("lists()[0] = %+v, want resourceVersion 100 listed at %s", lists[0], start)
+	}
+
+	var nilSnapshot *listSnapshot
+	nilSnapshot.record(testListedConfigMaps(nil), start)
+	if lists := nilSnapshot.lists(); lists != nil {
+		t.Errorf("nil lists() = %v, want nil", lists)
+	}
+}
+
+func Test_quiesceCheck(t *testing.T) {
+	snapshot := newListSnapshot()
+	snapshot.record(testListedConfigMaps(map[string]string{"kept": "1", "modified": "2", "removed": "3"}), time.Now())
+
+	scheme := metadatafake.NewTestScheme()
+	metav1.AddMetaToScheme(scheme)
+	client := metadatafake.NewSimpleMetadataClient(scheme,
+		testObjectMeta("kept", "1"),
+		testObjectMeta("modified", "5"),
+		testObjectMeta("added", "6"),
+	)
+	drift := quiesceCheck(context.Background(), client, "foo", metav1.ListOptions{}, snapshot.lists(), testLogger())
+	want := []driftedObject{
+		{Resource: "configmaps", Name: "modified", Change: DriftModified},
+		{Resource: "configmaps", Name: "removed", Change: DriftRemoved},
+		{Resource: "configmaps", Name: "added", Change: DriftAdded},
+	}
+	if !reflect.DeepEqual(drift, want) {
+		t.Errorf("quiesceCheck() = %+v, want %+v", drift, want)
+	}
+}
+
+func Test_compareLists(t *testing.T) {
+	tests := []struct {
+		name    string
+		listed  map[string]string
+		current map[string]string
+		want    []driftedObject
+	}{
+		{
+			name:    "given the same objects, should report no drift",
+			listed:  map[string]string{"foo/a": "1", "foo/b": "2"},
+			current: map[string]string{"foo/a": "1", "foo/b": "2"},
+			want:    []driftedObject{},
+		},
+		{
+			name:    "given an object created after the list, should report it added",
+			listed:  map[string]string{},
+			current: map[string]string{"foo/a": "1"},
+			want:    []driftedObject{{Resource: "configmaps", Name: "a", Change: DriftAdded}},
+		},
+		{
+			name:    "given a cluster-scoped object, should name it without namespace",
+			listed:  map[string]string{"/a": "1"},
+			current: map[string]string{"/a": "4"},
+			want:    []driftedObject{{Resource: "configmaps", Name: "a", Change: DriftModified}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compareLists(listRecord{Resource: "configmaps", objects: tt.listed}, tt.current)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("compareLists() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// throttleHintDelay is the delay of a request by the client-side rate limiter from which the hint
+// about --qps and --burst is logged, client-go logs its own throttling messages from 1s too
+const throttleHintDelay = time.Second
+
+// hintingRateLimiter is the client-side rate limiter of the export clients. The first time it
+// delays a request noticeably it suggests raising the limits, once for the whole export.
+type hintingRateLimiter struct {
+	flowcontrol.RateLimiter
+	burst int
+	delay time.Duration
+	once  sync.Once
+	log   logrus.FieldLogger
+	// live counts the requests delayed, with --metrics-addr
+	live *liveMetrics
+}
+
+func newHintingRateLimiter(qps float32, burst int, log logrus.FieldLogger) *hintingRateLimiter {
+	return &hintingRateLimiter{
+		RateLimiter: flowcontrol.NewTokenBucketRateLimiter(qps, burst),
+		burst:       burst,
+		delay:       throttleHintDelay,
+		log:         log,
+	}
+}
+
+func (l *hintingRateLimiter) Wait(ctx context.Context) error {
+	start := time.Now()
+	err := l.RateLimiter.Wait(ctx)
+	waited := time.Since(start)
+	l.live.addThrottled(waited)
+	if waited >= l.delay {
+		l.once.Do(func() {
+			l.log.Warnf("A request waited %s for the client-side rate limiter (--qps %g, --burst %d), raise them or use --disable-client-rate-limiter when the cluster has API Priority and Fairness", waited.Round(time.Millisecond), l.QPS(), l.burst)
+		})
+	}
+	return err
+}
+
+// applyRateLimiter sets the client-side rate limiter of the export to restConfig. A single limiter
+// is shared by the clients created from it, so --qps and --burst bound the whole export.
+func (o *ExportOptions) applyRateLimiter(restConfig *rest.Config, log logrus.FieldLogger) {
+	if o.disableRateLimiter {
+		// a negative QPS creates the clients without rate limiter
+		restConfig.QPS = -1
+		restConfig.Burst = 0
+		restConfig.RateLimiter = nil
+		return
+	}
+	restConfig.QPS = o.QPS
+	restConfig.Burst = o.Burst
+	limiter := newHintingRateLimiter(o.QPS, o.Burst, log)
+	limiter.live = o.live
+	restConfig.RateLimiter = limiter
+}
+
+// rateLimiterSettings describes the client-side rate limiter, logged at the start of the export
+func (o *ExportOptions) rateLimiterSettings() string {
+	if o.disableRateLimiter {
+		return "Client-side rate limiter disabled, the API server paces the requests with API Priority and Fairness"
+	}
+	return fmt.Sprintf("Client-side rate limiter: %g queries per second, burst %d", o.QPS, o.Burst)
+}
+
+// validateRateLimiter checks --qps, --burst and --disable-client-rate-limiter
+func (o *ExportOptions) validateRateLimiter() error {
+	if o.disableRateLimiter {
+		_, qps := o.flagsUsed["qps"]
+		_, burst := o.flagsUsed["burst"]
+		if qps || burst {
+			return fmt.Errorf("--qps and --burst cannot be used with --disable-client-rate-limiter")
+		}
+		return nil
+	}
+	if o.QPS <= 0 || o.Burst <= 0 {
+		return fmt.Errorf("--qps and --burst must be positive, use --disable-client-rate-limiter to send the requests without client-side limit")
+	}
+	return nil
+}
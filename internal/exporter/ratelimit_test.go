@@ -0,0 +1,124 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func TestExportOptions_restConfig_rateLimiter(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	err := os.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: source
+  cluster:
+    server: https://source.example.com:6443
+contexts:
+- name: source
+  context:
+    cluster: source
+current-context: source
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name            string
+		args            []string
+		wantQPS         float32
+		wantBurst       int
+		wantRateLimiter bool
+		wantErr         bool
+	}{
+		{
+			name:            "given no flag, should use the export defaults",
+			wantQPS:         100,
+			wantBurst:       1000,
+			wantRateLimiter: true,
+		},
+		{
+			name:            "given --qps and --burst, should use them",
+			args:            []string{"--qps", "20", "--burst", "40"},
+			wantQPS:         20,
+			wantBurst:       40,
+			wantRateLimiter: true,
+		},
+		{
+			name:    "given --disable-client-rate-limiter, should create the clients without rate limiter",
+			args:    []string{"--disable-client-rate-limiter"},
+			wantQPS: -1,
+		},
+		{
+			name:    "given --disable-client-rate-limiter with --qps, should fail",
+			args:    []string{"--disable-client-rate-limiter", "--qps", "20"},
+			wantErr: true,
+		},
+		{
+			name:    "given a zero --burst, should fail",
+			args:    []string{"--burst", "0"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &ExportOptions{configFlags: genericclioptions.NewConfigFlags(false)}
+			flags := pflag.NewFlagSet("export", pflag.ContinueOnError)
+			o.addFlags(flags)
+			o.configFlags.Namespace = nil
+			o.configFlags.AddFlags(flags)
+			if err := flags.Parse(append([]string{"--kubeconfig", kubeconfig}, tt.args...)); err != nil {
+				t.Fatal(err)
+			}
+			o.flagsUsed = map[string]string{}
+			flags.Visit(func(f *pflag.Flag) {
+				o.flagsUsed[f.Name] = f.Value.String()
+			})
+			err := o.validateRateLimiter()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateRateLimiter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			restConfig, err := o.restConfig(testLogger())
+			if err != nil {
+				t.Fatalf("restConfig() error = %v", err)
+			}
+			if restConfig.QPS != tt.wantQPS || restConfig.Burst != tt.wantBurst {
+				t.Errorf("restConfig() qps = %g, burst = %d, want %g, %d", restConfig.QPS, restConfig.Burst, tt.wantQPS, tt.wantBurst)
+			}
+			if (restConfig.RateLimiter != nil) != tt.wantRateLimiter {
+				t.Fatalf("restConfig() rate limiter = %v, want one %v", restConfig.RateLimiter, tt.wantRateLimiter)
+			}
+			if tt.wantRateLimiter && restConfig.RateLimiter.QPS() != tt.wantQPS {
+				t.Errorf("restConfig() rate limiter qps = %g, want %g", restConfig.RateLimiter.QPS(), tt.wantQPS)
+			}
+		})
+	}
+}
+
+func Test_hintingRateLimiter(t *testing.T) {
+	logs := &bytes.Buffer{}
+	log := logrus.New()
+	log.SetOutput(logs)
+	limiter := newHintingRateLimiter(50, 1, log)
+	limiter.delay = 10 * time.Millisecond
+	for i := 0; i < 4; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := strings.Count(logs.String(), "--disable-client-rate-limiter"); got != 1 {
+		t.Errorf("the throttling hint was logged %d times, want once:\n%s", got, logs.String())
+	}
+}
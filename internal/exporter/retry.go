@@ -0,0 +1,122 @@
+package exporter
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+)
+
+// retryPolicy retries the API calls failing with a transient error, like a 429 or a 503 from a
+// busy API server, with an exponential backoff and jitter. A longer delay requested by the
+// server with a Retry-After header is honored.
+type retryPolicy struct {
+	retries int
+	backoff time.Duration
+	log     logrus.FieldLogger
+}
+
+func (p retryPolicy) do(ctx context.Context, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.retries || !isTransient(err) {
+			return err
+		}
+		delay := p.delay(attempt, err)
+		p.log.Warnf("transient error, retrying in %s (%d/%d): %v", delay, attempt+1, p.retries, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (p retryPolicy) delay(attempt int, err error) time.Duration {
+	delay := wait.Jitter(p.backoff<<attempt, 1.0)
+	if seconds, ok := apierrors.SuggestsClientDelay(err); ok {
+		if retryAfter := time.Duration(seconds) * time.Second; retryAfter > delay {
+			delay = retryAfter
+		}
+	}
+	return delay
+}
+
+// isTransient reports whether the error is worth retrying. Permanent errors, like a forbidden or
+// not found resource, fail right away.
+func isTransient(err error) bool {
+	return apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err)
+}
+
+// retryingDynamicClient applies a retryPolicy to the Get and List calls of a dynamic client
+type retryingDynamicClient struct {
+	dynamic.Interface
+	policy retryPolicy
+}
+
+func newRetryingDynamicClient(client dynamic.Interface, policy retryPolicy) dynamic.Interface {
+	return retryingDynamicClient{Interface: client, policy: policy}
+}
+
+func (c retryingDynamicClient) Resource(resource schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return retryingResourceClient{NamespaceableResourceInterface: c.Interface.Resource(resource), policy: c.policy}
+}
+
+type retryingResourceClient struct {
+	dynamic.NamespaceableResourceInterface
+	policy retryPolicy
+}
+
+func (c retryingResourceClient) Namespace(namespace string) dynamic.ResourceInterface {
+	return retryingNamespacedClient{ResourceInterface: c.NamespaceableResourceInterface.Namespace(namespace), policy: c.policy}
+}
+
+func (c retryingResourceClient) Get(ctx context.Context, name string, opts metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return retryGet(ctx, c.policy, c.NamespaceableResourceInterface, name, opts, subresources...)
+}
+
+func (c retryingResourceClient) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	return retryList(ctx, c.policy, c.NamespaceableResourceInterface, opts)
+}
+
+type retryingNamespacedClient struct {
+	dynamic.ResourceInterface
+	policy retryPolicy
+}
+
+func (c retryingNamespacedClient) Get(ctx context.Context, name string, opts metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return retryGet(ctx, c.policy, c.ResourceInterface, name, opts, subresources...)
+}
+
+func (c retryingNamespacedClient) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	return retryList(ctx, c.policy, c.ResourceInterface, opts)
+}
+
+func retryGet(ctx context.Context, p retryPolicy, c dynamic.ResourceInterface, name string, opts metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	var obj *unstructured.Unstructured
+	err := p.do(ctx, func() error {
+		var err error
+		obj, err = c.Get(ctx, name, opts, subresources...)
+		return err
+	})
+	return obj, err
+}
+
+func retryList(ctx context.Context, p retryPolicy, c dynamic.ResourceInterface, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	var list *unstructured.UnstructuredList
+	err := p.do(ctx, func() error {
+		var err error
+		list, err = c.List(ctx, opts)
+		return err
+	})
+	return list, err
+}
@@ -0,0 +1,119 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func Test_retryingDynamicClient_List(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		err       error
+		retries   int
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name:      "throttled list succeeds after retries",
+			failures:  2,
+			err:       apierrors.NewTooManyRequests("slow down", 0),
+			retries:   3,
+			wantCalls: 3,
+		},
+		{
+			name:      "unavailable server is retried",
+			failures:  1,
+			err:       apierrors.NewServiceUnavailable("busy"),
+			retries:   3,
+			wantCalls: 2,
+		},
+		{
+			name:      "gives up after the configured retries",
+			failures:  5,
+			err:       apierrors.NewServiceUnavailable("busy"),
+			retries:   3,
+			wantCalls: 4,
+			wantErr:   true,
+		},
+		{
+			name:      "forbidden is not retried",
+			failures:  5,
+			err:       apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "", errors.New("denied")),
+			retries:   3,
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			name:      "not found is not retried",
+			failures:  5,
+			err:       apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, ""),
+			retries:   3,
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newTestDynamicClient(0, testConfigMaps(2)...)
+			calls := 0
+			fake.PrependReactor("list", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				calls++
+				if calls <= tt.failures {
+					return true, nil, tt.err
+				}
+				return false, nil, nil
+			})
+			client := newRetryingDynamicClient(fake, retryPolicy{retries: tt.retries, backoff: time.Millisecond, log: testLogger()})
+
+			list, err := client.Resource(testConfigMapsGVR).Namespace("foo").List(context.Background(), metav1.ListOptions{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("List() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("List() called %d times, want %d", calls, tt.wantCalls)
+			}
+			if err == nil && len(list.Items) != 2 {
+				t.Errorf("List() returned %d items, want 2", len(list.Items))
+			}
+		})
+	}
+}
+
+func Test_retryPolicy_delay(t *testing.T) {
+	p := retryPolicy{retries: 3, backoff: 100 * time.Millisecond}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		base := p.backoff << attempt
+		delay := p.delay(attempt, apierrors.NewServiceUnavailable("busy"))
+		if delay < base || delay > 2*base {
+			t.Errorf("attempt %d: delay = %s, want between %s and %s", attempt, delay, base, 2*base)
+		}
+	}
+
+	if delay := p.delay(0, apierrors.NewTooManyRequests("slow down", 5)); delay != 5*time.Second {
+		t.Errorf("delay with Retry-After = %s, want 5s", delay)
+	}
+}
+
+func Test_retryPolicy_cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	p := retryPolicy{retries: 3, backoff: time.Hour, log: testLogger()}
+	err := p.do(ctx, func() error {
+		calls++
+		return apierrors.NewServiceUnavailable("busy")
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("do() = %v after %d calls, want the error after 1 call", err, calls)
+	}
+}
@@ -0,0 +1,294 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/vmware-tanzu/velero/pkg/discovery"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// retryRunFlags are the flags of the previous export not applied to a --retry-failures pass, they
+// describe the run rather than the exported content
+var retryRunFlags = map[string]bool{
+	"config":                    true,
+	"export-dir":                true,
+	"overwrite":                 true,
+	"dry-run":                   true,
+	"archive":                   true,
+	"archive-file":              true,
+	"metrics-file":              true,
+	"namespace":                 true,
+	"all-namespaces":            true,
+	"include-system-namespaces": true,
+	"timeout":                   true,
+	"skip-preflight":            true,
+	"quiesce-check":             true,
+	"s3-endpoint":               true,
+	"s3-sse":                    true,
+	"s3-sse-kms-key-id":         true,
+	"verbosity":                 true,
+	"quiet":                     true,
+	"log-format":                true,
+	"max-resources":             true,
+	"max-bytes":                 true,
+}
+
+// failureRetry is a --retry-failures pass over a previous export: only the resources that failed
+// to be listed, in the groups that failed to be discovered, and the objects that failed to be
+// written are exported again, into the previous export directory
+type failureRetry struct {
+	previous *RunSummary
+	// records are the failures of the previous export by namespace
+	records map[string][]FailureRecord
+	// index are the files written by the previous export
+	index []index.Entry
+}
+
+// loadFailureRetry reads the summary, the index and the failures of the export in exportDir
+func loadFailureRetry(exportDir string) (*failureRetry, error) {
+	previous, err := ReadRunSummary(exportDir)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s has no previous export to retry, %s not found", exportDir, SummaryJSONFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries, err := index.Read(exportDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	r := &failureRetry{previous: previous, records: map[string][]FailureRecord{}, index: entries}
+	for _, s := range previous.Namespaces {
+		recordBytes, err := os.ReadFile(filepath.Join(exportDir, "failures", s.Namespace, FailuresFile))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		records := []FailureRecord{}
+		if err := json.Unmarshal(recordBytes, &records); err != nil {
+			return nil, fmt.Errorf("invalid %s of namespace %s: %w", FailuresFile, s.Namespace, err)
+		}
+		r.records[s.Namespace] = records
+	}
+	return r, nil
+}
+
+// applyFlags sets the flags not given on the command line to their value in the previous export,
+// so that the retried resources are exported like the others
+func (r *failureRetry) applyFlags(flags *pflag.FlagSet, log logrus.FieldLogger) error {
+	for _, name := range sortedKeys(r.previous.Flags) {
+		f := flags.Lookup(name)
+		if f == nil || f.Changed || retryRunFlags[name] {
+			continue
+		}
+		if err := f.Value.Set(recordedValue(f, r.previous.Flags[name])); err != nil {
+			return fmt.Errorf("invalid value of --%s in the previous export: %w", name, err)
+		}
+		log.Debugf("using --%s=%s of the previous export", name, r.previous.Flags[name])
+	}
+	return nil
+}
+
+// recordedValue returns the value of a flag recorded in the export summary as it is given on the
+// command line, the list flags are recorded as [a,b]
+func recordedValue(f *pflag.Flag, value string) string {
+	if _, isSlice := f.Value.(pflag.SliceValue); isSlice {
+		return strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	}
+	return value
+}
+
+// namespaces returns the namespaces of the previous export with failures to retry
+func (r *failureRetry) namespaces() []string {
+	namespaces := []string{}
+	for _, s := range r.previous.Namespaces {
+		for _, record := range r.records[s.Namespace] {
+			if isRetriable(record) {
+				namespaces = append(namespaces, s.Namespace)
+				break
+			}
+		}
+	}
+	return namespaces
+}
+
+// isRetriable reports whether a failure is retried, the cluster dependencies that could not be
+// resolved are not
+func isRetriable(record FailureRecord) bool {
+	switch record.Operation {
+	case "list", "write", "discover":
+		return true
+	}
+	return false
+}
+
+// retries reports whether the resource is exported again in the namespace, and whether all its
+// objects are, when it failed to be listed or its group to be discovered, or only the named ones
+// that failed to be written
+func (r *failureRetry) retries(namespace string, gv schema.GroupVersion, resource string) (bool, bool, map[string]bool) {
+	names := map[string]bool{}
+	for _, record := range r.records[namespace] {
+		if record.Group != gv.Group || record.Version != gv.Version {
+			continue
+		}
+		switch {
+		case record.Operation == "discover":
+			return true, true, nil
+		case record.Operation == "list" && record.Resource == resource:
+			return true, true, nil
+		case record.Operation == "write" && record.Resource == resource:
+			names[record.Name] = true
+		}
+	}
+	return len(names) > 0, false, names
+}
+
+// discovery returns the discovery of the namespace restricted to the resources to retry
+func (r *failureRetry) discovery(namespace string, helper discovery.Helper) discovery.Helper {
+	lists := []*metav1.APIResourceList{}
+	for _, list := range helper.Resources() {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		retried := &metav1.APIResourceList{TypeMeta: list.TypeMeta, GroupVersion: list.GroupVersion}
+		for _, resource := range list.APIResources {
+			if ok, _, _ := r.retries(namespace, gv, resource.Name); ok {
+				retried.APIResources = append(retried.APIResources, resource)
+			}
+		}
+		if len(retried.APIResources) > 0 {
+			lists = append(lists, retried)
+		}
+	}
+	return retryDiscovery{Helper: helper, resources: lists}
+}
+
+// retryDiscovery is a discovery helper serving only the resources to retry
+type retryDiscovery struct {
+	discovery.Helper
+	resources []*metav1.APIResourceList
+}
+
+func (d retryDiscovery) Resources() []*metav1.APIResourceList {
+	return d.resources
+}
+
+// kept returns the failures of the namespace that are not retried: the unresolved cluster
+// dependencies and the resources no longer served. The groups that still cannot be discovered are
+// recorded again by the retry pass.
+func (r *failureRetry) kept(namespace string, lists []*metav1.APIResourceList) []FailureRecord {
+	served := map[string]bool{}
+	for _, list := range lists {
+		for _, resource := range list.APIResources {
+			served[list.GroupVersion+"/"+resource.Name] = true
+		}
+	}
+	kept := []FailureRecord{}
+	for _, record := range r.records[namespace] {
+		gv := schema.GroupVersion{Group: record.Group, Version: record.Version}
+		switch {
+		case !isRetriable(record):
+			kept = append(kept, record)
+		case record.Operation != "discover" && !served[gv.String()+"/"+record.Resource]:
+			kept = append(kept, record)
+		}
+	}
+	return kept
+}
+
+// skipExported removes from the resources the objects already exported, those of the resources
+// that were listed but some objects of which failed to be written
+func (r *failureRetry) skipExported(namespace string, resources []*groupResource) {
+	for _, res := range resources {
+		gv := schema.GroupVersion{Group: res.APIGroup, Version: res.APIVersion}
+		_, all, names := r.retries(namespace, gv, res.APIResource.Name)
+		if all || res.objects == nil {
+			continue
+		}
+		kept := []unstructured.Unstructured{}
+		for _, obj := range res.objects.Items {
+			if names[obj.GetName()] {
+				kept = append(kept, obj)
+			}
+		}
+		res.objects.Items = kept
+	}
+}
+
+// merge returns the summary of the previous export updated with the namespaces of the retry pass,
+// the objects they exported are recorded as retried
+func (r *failureRetry) merge(current *RunSummary) *RunSummary {
+	merged := *r.previous
+	merged.Resources = map[string]int{}
+	merged.Failures = 0
+	merged.Interrupted = current.Interrupted
+	merged.NotExported = current.NotExported
+	merged.BudgetExceeded = current.BudgetExceeded
+	for _, s := range merged.Namespaces {
+		for _, retried := range current.Namespaces {
+			if retried.Namespace != s.Namespace {
+				continue
+			}
+			if s.Resources == nil {
+				s.Resources = map[string]int{}
+			}
+			for name, count := range retried.Resources {
+				s.Resources[name] += count
+				if s.Retried == nil {
+					s.Retried = map[string]int{}
+				}
+				s.Retried[name] += count
+			}
+			s.Failures = retried.Failures
+			s.Encrypted = append(s.Encrypted, retried.Encrypted...)
+			s.Deprecated = append(s.Deprecated, retried.Deprecated...)
+			s.Lists = append(s.Lists, retried.Lists...)
+		}
+		for name, count := range s.Resources {
+			merged.Resources[name] += count
+		}
+		merged.Failures += s.Failures
+	}
+	return &merged
+}
+
+// rewritesNamespace reports whether the namespace manifest failed to be written, the retry pass
+// keeps it otherwise
+func (r *failureRetry) rewritesNamespace(namespace string) bool {
+	retried, _, _ := r.retries(namespace, schema.GroupVersion{Version: "v1"}, "namespaces")
+	return retried
+}
+
+// validateRetry checks the flags of a --retry-failures pass, it adds to the export directory of the
+// previous export and cannot be combined with the flags writing elsewhere
+func (o *ExportOptions) validateRetry() error {
+	templated := false
+	walkPath(o.exportDir, func(string) (string, error) {
+		templated = true
+		return "", nil
+	})
+	switch {
+	case templated:
+		return fmt.Errorf("--retry-failures needs the export directory of the previous export, --export-dir cannot contain tokens")
+	case o.dryRun, o.overwrite, o.archive:
+		return fmt.Errorf("--retry-failures cannot be used with --dry-run, --overwrite or --archive")
+	case o.allNamespaces || o.flagsUsed["namespace"] != "":
+		return fmt.Errorf("--retry-failures exports the namespaces with failures, it cannot be used with --namespace or --all-namespaces")
+	case o.layout == LayoutSingle:
+		return fmt.Errorf("--retry-failures cannot be used with --layout %s, the objects of a namespace are in a single file", LayoutSingle)
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package exporter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// writePreviousExport writes the summary, the index and the failures of a previous export
+func writePreviousExport(t *testing.T, dir string, records map[string][]FailureRecord) {
+	t.Helper()
+	summaries := []*exportSummary{}
+	for _, namespace := range sortedKeys(records) {
+		s := newExportSummary(namespace)
+		s.Resources["configmaps"] = 2
+		s.Failures = len(records[namespace])
+		summaries = append(summaries, s)
+		failuresDir := filepath.Join(dir, "failures", namespace)
+		if err := os.MkdirAll(failuresDir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeFailureRecords(failuresDir, records[namespace]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	flags := map[string]string{"output": "json", "exclude-resources": "[events,pods]", "export-dir": dir}
+	if err := newRunSummary(time.Now(), "v1.29.0", flags, summaries).write(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Write(dir, []index.Entry{{Path: "resources/foo/ConfigMap_foo_cm-0.json", Size: 1, SHA256: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testRetryLists() []*metav1.APIResourceList {
+	return []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "configmaps", Namespaced: true, Kind: "ConfigMap", Verbs: []string{"list"}},
+			{Name: "secrets", Namespaced: true, Kind: "Secret", Verbs: []string{"list"}},
+			{Name: "services", Namespaced: true, Kind: "Service", Verbs: []string{"list"}},
+		}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "deployments", Namespaced: true, Kind: "Deployment", Verbs: []string{"list"}},
+		}},
+		{GroupVersion: "metrics.k8s.io/v1beta1", APIResources: []metav1.APIResource{
+			{Name: "pods", Namespaced: true, Kind: "PodMetrics", Verbs: []string{"list"}},
+		}},
+	}
+}
+
+func Test_failureRetry(t *testing.T) {
+	dir := t.TempDir()
+	writePreviousExport(t, dir, map[string][]FailureRecord{
+		"foo": {
+			{Operation: "list", Version: "v1", Resource: "secrets", Category: failurePermission},
+			{Operation: "write", Group: "apps", Version: "v1", Resource: "deployments", Name: "web", Category: failureSerialization},
+			{Operation: "discover", Group: "metrics.k8s.io", Version: "v1beta1", Category: failureThrottling},
+			{Operation: "list", Group: "example.com", Version: "v1", Resource: "widgets", Category: failurePermission},
+			{Operation: "resolve", Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles", Name: "deleted-role", Category: failureNotFound},
+		},
+		"bar": {
+			{Operation: "resolve", Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles", Name: "deleted-role", Category: failureNotFound},
+		},
+		"baz": {},
+	})
+
+	retry, err := loadFailureRetry(dir)
+	if err != nil {
+		t.Fatalf("loadFailureRetry() error = %v", err)
+	}
+	if got := retry.namespaces(); !reflect.DeepEqual(got, []string{"foo"}) {
+		t.Errorf("namespaces() = %v, want only foo with failures to retry", got)
+	}
+
+	t.Run("given the failures, should serve only the resources to retry", func(t *testing.T) {
+		helper := retryDiscovery{resources: testRetryLists()}
+		got := []string{}
+		for _, list := range retry.discovery("foo", helper).Resources() {
+			for _, r := range list.APIResources {
+				got = append(got, list.GroupVersion+"/"+r.Name)
+			}
+		}
+		want := []string{"v1/secrets", "apps/v1/deployments", "metrics.k8s.io/v1beta1/pods"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("discovery().Resources() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("given resources no longer served, should keep their failures", func(t *testing.T) {
+		kept := retry.kept("foo", testRetryLists())
+		if len(kept) != 2 || kept[0].Resource != "widgets" || kept[1].Operation != "resolve" {
+			t.Errorf("kept() = %+v, want the widgets list and the unresolved cluster role", kept)
+		}
+	})
+
+	t.Run("given write failures, should only export the failed objects", func(t *testing.T) {
+		objects := func(names ...string) *unstructured.UnstructuredList {
+			list := &unstructured.UnstructuredList{}
+			for _, name := range names {
+				obj := unstructured.Unstructured{}
+				obj.SetName(name)
+				list.Items = append(list.Items, obj)
+			}
+			return list
+		}
+		deployments := &groupResource{APIGroup: "apps", APIVersion: "v1", APIResource: metav1.APIResource{Name: "deployments"}, objects: objects("api", "web")}
+		secrets := &groupResource{APIVersion: "v1", APIResource: metav1.APIResource{Name: "secrets"}, objects: objects("a", "b")}
+		retry.skipExported("foo", []*groupResource{deployments, secrets})
+		if len(deployments.objects.Items) != 1 || deployments.objects.Items[0].GetName() != "web" {
+			t.Errorf("deployments = %v, want only web", deployments.objects.Items)
+		}
+		if len(secrets.objects.Items) != 2 {
+			t.Errorf("secrets = %v, want all of them", secrets.objects.Items)
+		}
+	})
+
+	t.Run("given the retry pass, should merge it into the previous summary", func(t *testing.T) {
+		retried := newExportSummary("foo")
+		retried.Resources["secrets"] = 3
+		retried.Resources["configmaps"] = 1
+		retried.Failures = 2
+		merged := retry.merge(newRunSummary(time.Now(), "", nil, []*exportSummary{retried}))
+
+		var foo *exportSummary
+		for _, s := range merged.Namespaces {
+			if s.Namespace == "foo" {
+				foo = s
+			}
+		}
+		if foo == nil {
+			t.Fatalf("merge() namespaces = %v, want foo", merged.Namespaces)
+		}
+		if foo.Resources["configmaps"] != 3 || foo.Resources["secrets"] != 3 || foo.Failures != 2 {
+			t.Errorf("merged foo = %+v, want 3 configmaps, 3 secrets and 2 failures", foo)
+		}
+		if !reflect.DeepEqual(foo.Retried, map[string]int{"configmaps": 1, "secrets": 3}) {
+			t.Errorf("merged foo retried = %v, want the objects of the retry pass", foo.Retried)
+		}
+		// bar keeps its unresolved dependency
+		if merged.Failures != 3 || merged.Resources["configmaps"] != 7 {
+			t.Errorf("merged totals = %d failures, %v, want 3 failures and 7 configmaps", merged.Failures, merged.Resources)
+		}
+		if merged.Flags["output"] != "json" {
+			t.Errorf("merged flags = %v, want the flags of the previous export", merged.Flags)
+		}
+		if !strings.Contains(merged.text(), "retried secrets: 3") {
+			t.Errorf("text() = %q, want the retried resources", merged.text())
+		}
+	})
+
+	t.Run("given the flags of the previous export, should apply those not set", func(t *testing.T) {
+		flags := pflag.NewFlagSet("export", pflag.ContinueOnError)
+		output := flags.String("output", "yaml", "")
+		exclude := flags.StringSlice("exclude-resources", nil, "")
+		exportDir := flags.String("export-dir", "", "")
+		if err := flags.Parse([]string{"--export-dir", "elsewhere"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := retry.applyFlags(flags, testLogger()); err != nil {
+			t.Fatalf("applyFlags() error = %v", err)
+		}
+		if *output != "json" || !reflect.DeepEqual(*exclude, []string{"events", "pods"}) || *exportDir != "elsewhere" {
+			t.Errorf("applyFlags() = output %q, exclude-resources %v, export-dir %q", *output, *exclude, *exportDir)
+		}
+	})
+}
+
+func Test_loadFailureRetry_noExport(t *testing.T) {
+	_, err := loadFailureRetry(t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "no previous export") {
+		t.Errorf("loadFailureRetry() error = %v, want no previous export", err)
+	}
+}
+
+func Test_validateRetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		o           *ExportOptions
+		errContains string
+	}{
+		{
+			name: "given the previous export directory, should pass",
+			o:    &ExportOptions{exportDir: "out", layout: LayoutFlat},
+		},
+		{
+			name:        "given a templated export directory, should fail",
+			o:           &ExportOptions{exportDir: "out/{date}", layout: LayoutFlat},
+			errContains: "cannot contain tokens",
+		},
+		{
+			name:        "given --overwrite, should fail",
+			o:           &ExportOptions{exportDir: "out", layout: LayoutFlat, overwrite: true},
+			errContains: "--overwrite",
+		},
+		{
+			name:        "given a namespace, should fail",
+			o:           &ExportOptions{exportDir: "out", layout: LayoutFlat, flagsUsed: map[string]string{"namespace": "[foo]"}},
+			errContains: "--namespace",
+		},
+		{
+			name:        "given the single layout, should fail",
+			o:           &ExportOptions{exportDir: "out", layout: LayoutSingle},
+			errContains: "--layout single",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.o.validateRetry()
+			if tt.errContains == "" {
+				if err != nil {
+					t.Errorf("validateRetry() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("validateRetry() error = %v, want it to contain %q", err, tt.errContains)
+			}
+		})
+	}
+}
+
+func Test_exportIndex_load(t *testing.T) {
+	dir := t.TempDir()
+	manifests := newExportIndex(dir)
+	manifests.load([]index.Entry{{Path: "resources/foo/a.yaml", SHA256: "old"}, {Path: "resources/foo/b.yaml", SHA256: "b"}})
+	manifests.add(filepath.Join(dir, "resources", "foo", "a.yaml"), []byte("new"), nil)
+	if err := manifests.write(); err != nil {
+		t.Fatal(err)
+	}
+	indexBytes, _ := os.ReadFile(filepath.Join(dir, index.File))
+	entries := []index.Entry{}
+	if err := json.Unmarshal(indexBytes, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].SHA256 != index.Sum([]byte("new")) {
+		t.Errorf("index entries = %+v, want a.yaml with its new content and b.yaml", entries)
+	}
+}
@@ -0,0 +1,401 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/encryption"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// RewriteFlags are the export flags a rewrite applies to a written export
+var RewriteFlags = []string{"image-map", "storageclass-map", "target-namespace", "strip-fields", "transform-exec", "transform-timeout", "transform-workers"}
+
+// rewriteSkippedPaths are the files of the source export that rewrite writes again or that do not
+// describe the rewritten export, the results of replay and validate being about its objects
+var rewriteSkippedPaths = []string{"resources", SummaryJSONFile, summaryTextFile, index.File, ApplyResultsFile, ValidateResultsFile, PVCMigrateResultsFile, ReportMarkdownFile, ReportHTMLFile, progressFile, progressDir}
+
+// Rewrite applies the export transforms to an export and writes the result to a new export, the
+// export in ExportDir is left unchanged. The transforms are set with the export flags in
+// RewriteFlags.
+type Rewrite struct {
+	ExportDir string
+	OutputDir string
+	// Overwrite replaces a previous export found in OutputDir
+	Overwrite bool
+
+	// flags are the transform flags, transforms holds their values and flagsUsed the ones set
+	flags      *pflag.FlagSet
+	transforms *ExportOptions
+	flagsUsed  map[string]string
+}
+
+// NewRewrite returns a rewrite applying no transform until its flags are set
+func NewRewrite() *Rewrite {
+	o := &Rewrite{
+		flags:      pflag.NewFlagSet("export", pflag.ContinueOnError),
+		transforms: &ExportOptions{},
+	}
+	o.transforms.addFlags(o.flags)
+	return o
+}
+
+// AddFlags adds the transform flags to flags, with the help of export
+func (o *Rewrite) AddFlags(flags *pflag.FlagSet) {
+	for _, name := range RewriteFlags {
+		flags.AddFlag(o.flags.Lookup(name))
+	}
+}
+
+// SetFlag sets a transform flag like on the command line
+func (o *Rewrite) SetFlag(name string, value string) error {
+	return o.flags.Set(name, value)
+}
+
+// Complete parses the transform flags set
+func (o *Rewrite) Complete() error {
+	if err := o.transforms.completeTransforms(); err != nil {
+		return err
+	}
+	o.flagsUsed = map[string]string{}
+	for _, name := range RewriteFlags {
+		if f := o.flags.Lookup(name); f.Changed {
+			o.flagsUsed[name] = f.Value.String()
+		}
+	}
+	return nil
+}
+
+func (o *Rewrite) Validate() error {
+	if o.OutputDir == "" {
+		return fmt.Errorf("--output-dir is required")
+	}
+	source, err := filepath.Abs(o.ExportDir)
+	if err != nil {
+		return err
+	}
+	output, err := filepath.Abs(o.OutputDir)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(source, output); err == nil && (rel == "." || !strings.HasPrefix(rel, "..")) {
+		return fmt.Errorf("--output-dir must not be the export directory or under it, the export is left unchanged")
+	}
+	if len(o.flagsUsed) == 0 {
+		return fmt.Errorf("no transform to apply, set at least one of --image-map, --storageclass-map, --target-namespace, --strip-fields or --transform-exec")
+	}
+	return o.transforms.validateTransforms()
+}
+
+// Run rewrites the export
+func (o *Rewrite) Run(ctx context.Context, log logrus.FieldLogger) error {
+	previous, err := ReadRunSummary(o.ExportDir)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s has no export to rewrite, %s not found", o.ExportDir, SummaryJSONFile)
+	}
+	if err != nil {
+		return err
+	}
+	entries, err := index.Read(o.ExportDir)
+	if err != nil {
+		return fmt.Errorf("cannot read the %s of %s: %w", index.File, o.ExportDir, err)
+	}
+	if err := prepareExportDir(o.OutputDir, o.Overwrite, log); err != nil {
+		return err
+	}
+
+	t := o.transforms
+	r := &rewriter{
+		exportDir:     o.ExportDir,
+		outputDir:     o.OutputDir,
+		stripRules:    t.stripRules,
+		transformer:   t.transformer,
+		imageRewrites: newImageRewriter(t.imageMappings),
+		storageClass:  t.storageClasses,
+		renamer:       NewNamespaceRenamer(t.targetNamespace),
+		log:           log,
+	}
+	summaries := map[string]*exportSummary{}
+	for _, s := range previous.Namespaces {
+		summaries[s.Namespace] = s
+	}
+	rewritten := []index.Entry{}
+	failures := 0
+	for _, entry := range entries {
+		namespace := exportedNamespace(entry.Path, previous.Namespaces)
+		summary := summaries[namespace]
+		if summary == nil {
+			summary = newExportSummary(namespace)
+		}
+		if summary.Skipped == nil {
+			summary.Skipped = map[string]map[string]int{}
+		}
+		// the objects were already moved when the export used --target-namespace
+		source := namespace
+		if target := previous.Flags["target-namespace"]; target != "" {
+			source = target
+		}
+		written, failed, err := r.rewriteFile(ctx, entry, source, summary)
+		if err != nil {
+			return err
+		}
+		summary.Failures += failed
+		failures += failed
+		rewritten = append(rewritten, written...)
+	}
+	if err := copyExportFiles(o.ExportDir, o.OutputDir, r.skippedPaths()); err != nil {
+		return err
+	}
+	if err := index.Write(o.OutputDir, rewritten); err != nil {
+		log.Errorf("error writing %s: %#v", index.File, err)
+		return err
+	}
+
+	if r.renamer != nil {
+		if warnings := len(r.renamer.warnings); warnings > 0 {
+			log.Warnf("%d fields still mention the source namespace after --target-namespace, see %s", warnings, namespaceWarningsFile)
+		}
+		if err := r.renamer.write(o.OutputDir); err != nil {
+			log.Errorf("error writing %s: %#v", namespaceWarningsFile, err)
+			return err
+		}
+	}
+	if r.imageRewrites != nil {
+		if unmapped := len(r.imageRewrites.unmapped); unmapped > 0 {
+			log.Warnf("%d images matched no --image-map, they are exported unchanged, see %s", unmapped, ImageRewritesFile)
+		}
+		if err := r.imageRewrites.write(o.OutputDir); err != nil {
+			log.Errorf("error writing %s: %#v", ImageRewritesFile, err)
+			return err
+		}
+	}
+
+	// the rewrite flags are recorded as if the export had been taken with them
+	if previous.Flags == nil {
+		previous.Flags = map[string]string{}
+	}
+	for name, value := range o.flagsUsed {
+		previous.Flags[name] = value
+	}
+	previous.ExportDir = o.OutputDir
+	previous.Failures += failures
+	if err := previous.write(o.OutputDir); err != nil {
+		log.Errorf("error writing the export summary: %#v", err)
+		return err
+	}
+	log.Infof("Export %s rewritten to %s", o.ExportDir, o.OutputDir)
+	if failures > 0 {
+		return &PartialFailureError{Failures: failures}
+	}
+	return nil
+}
+
+// rewriter applies the export transforms to the files of an export
+type rewriter struct {
+	exportDir     string
+	outputDir     string
+	stripRules    []stripRule
+	transformer   *execTransformer
+	imageRewrites *imageRewriter
+	storageClass  StorageClassMap
+	renamer       *namespaceRenamer
+	log           logrus.FieldLogger
+}
+
+// skippedPaths are the files of the export not copied to the output directory, the reports
+// rewritten being replaced
+func (r *rewriter) skippedPaths() []string {
+	paths := append([]string{}, rewriteSkippedPaths...)
+	if r.renamer != nil {
+		paths = append(paths, namespaceWarningsFile)
+	}
+	if r.imageRewrites != nil {
+		paths = append(paths, ImageRewritesFile)
+	}
+	return paths
+}
+
+// exportedNamespace returns the namespace an exported file was written with, empty for the files
+// of no namespace
+func exportedNamespace(path string, summaries []*exportSummary) string {
+	for _, s := range summaries {
+		if IsReplayedFile(path, s.Namespace) || strings.HasPrefix(path, "resources/"+s.Namespace+"/") {
+			return s.Namespace
+		}
+	}
+	return ""
+}
+
+// rewriteFile transforms the objects of an exported file and writes them to the same path of the
+// output directory. It returns the index entry of the file, none when every object was dropped,
+// and the number of objects --transform-exec failed to transform.
+func (r *rewriter) rewriteFile(ctx context.Context, entry index.Entry, source string, summary *exportSummary) ([]index.Entry, int, error) {
+	path := filepath.Join(r.exportDir, filepath.FromSlash(entry.Path))
+	target := filepath.Join(r.outputDir, filepath.FromSlash(entry.Path))
+	if strings.HasSuffix(entry.Path, encryption.Extension) {
+		r.log.Warnf("%s is encrypted, it is copied without the transforms", entry.Path)
+		return []index.Entry{entry}, 0, copyFile(path, target)
+	}
+	objects, err := ReadManifestFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// the objects are grouped by API group, the storage class rewrite matching the core and apps ones
+	full, namespaces, webhooks, unchanged := []*groupResource{}, []*groupResource{}, []*groupResource{}, []*groupResource{}
+	groups := map[string]*groupResource{}
+	for _, obj := range objects {
+		single := &groupResource{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{obj}}}
+		switch kind := obj.GetKind(); {
+		case kind == "Namespace":
+			namespaces = append(namespaces, single)
+		case kind == "ValidatingWebhookConfiguration" || kind == "MutatingWebhookConfiguration":
+			webhooks = append(webhooks, single)
+		case kind == "Event" || (obj.GetNamespace() == "" && kind != "ClusterRole" && kind != "ClusterRoleBinding"):
+			// the CRDs and the cluster dependencies are exported unchanged
+			unchanged = append(unchanged, single)
+		default:
+			group := obj.GroupVersionKind().Group
+			if groups[group] == nil {
+				groups[group] = &groupResource{APIGroup: group, objects: &unstructured.UnstructuredList{}}
+				full = append(full, groups[group])
+			}
+			groups[group].objects.Items = append(groups[group].objects.Items, obj)
+		}
+	}
+	if len(full) == 0 && len(namespaces) == 0 && (r.renamer == nil || len(webhooks) == 0) {
+		return []index.Entry{entry}, 0, copyFile(path, target)
+	}
+
+	// the transforms are applied in the order of export, the Namespace is only stripped and
+	// renamed and the webhook configurations only renamed
+	applyStripRules(full, r.stripRules)
+	failures := r.transformer.apply(ctx, full, summary, r.log)
+	for _, f := range failures {
+		r.log.Warnf("cannot transform %s %s of %s, it is left out", f.Resource, f.Name, entry.Path)
+	}
+	summary.RewrittenImages += r.imageRewrites.rewrite(full)
+	rewritten, unmapped := r.storageClass.rewrite(full)
+	summary.StorageClasses += rewritten
+	summary.UnmappedStorageClasses = append(summary.UnmappedStorageClasses, unmapped...)
+	summary.NamespaceWarnings += r.renamer.rename(source, full)
+	applyStripRules(namespaces, r.stripRules)
+	r.renamer.rename(source, namespaces)
+	summary.NamespaceWarnings += r.renamer.rename(source, webhooks)
+
+	kept := []streamObject{}
+	for _, resources := range [][]*groupResource{namespaces, full, webhooks, unchanged} {
+		for _, g := range resources {
+			for _, obj := range g.objects.Items {
+				kept = append(kept, streamObject{resource: g, obj: obj})
+			}
+		}
+	}
+	if len(kept) == 0 {
+		r.log.Infof("every object of %s was dropped, it is not written", entry.Path)
+		return nil, len(failures), nil
+	}
+
+	data, err := encodeManifestFile(entry.Path, kept)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", entry.Path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return nil, 0, err
+	}
+	if err := os.WriteFile(target, data, 0600); err != nil {
+		return nil, 0, err
+	}
+	written := index.Entry{Path: entry.Path, Size: int64(len(data)), SHA256: index.Sum(data)}
+	if len(kept) == 1 && !isStreamFile(entry.Path) {
+		obj := kept[0].obj
+		written.APIVersion, written.Kind, written.Namespace, written.Name = obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName()
+	}
+	return []index.Entry{written}, len(failures), nil
+}
+
+// isStreamFile reports whether an exported file is a multi-document YAML stream of the single
+// layout, written directly under resources
+func isStreamFile(path string) bool {
+	return !strings.Contains(strings.TrimPrefix(path, "resources/"), "/")
+}
+
+// encodeManifestFile encodes the objects like the resource writers did for the file: a YAML
+// stream sorted for apply for the single layout, or a single YAML or JSON object, gzipped when
+// the file is
+func encodeManifestFile(path string, objects []streamObject) ([]byte, error) {
+	name := strings.TrimSuffix(path, gzipExtension)
+	var data []byte
+	if isStreamFile(path) {
+		sortForApply(objects)
+		buf := &bytes.Buffer{}
+		for _, o := range objects {
+			objBytes, err := marshalObject(o.obj, OutputYAML)
+			if err != nil {
+				return nil, err
+			}
+			buf.WriteString("---\n")
+			buf.Write(objBytes)
+		}
+		data = buf.Bytes()
+	} else {
+		if len(objects) != 1 {
+			return nil, fmt.Errorf("holds %d objects, expected one", len(objects))
+		}
+		output := OutputYAML
+		if strings.HasSuffix(name, "."+OutputJSON) {
+			output = OutputJSON
+		}
+		var err error
+		if data, err = marshalObject(objects[0].obj, output); err != nil {
+			return nil, err
+		}
+	}
+	if name != path {
+		return gzipBytes(data)
+	}
+	return data, nil
+}
+
+// copyExportFiles copies the files of the export that are not under the skipped paths, like the
+// failures and the reports of the export
+func copyExportFiles(exportDir string, outputDir string, skipped []string) error {
+	return filepath.WalkDir(exportDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(exportDir, path)
+		if err != nil {
+			return err
+		}
+		if containsString(skipped, filepath.ToSlash(rel)) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		return copyFile(path, filepath.Join(outputDir, rel))
+	})
+}
+
+func copyFile(path string, target string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(target, data, 0600)
+}
@@ -0,0 +1,259 @@
+package exporter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// writeRewriteExport writes an export of namespace foo with the layout, after applying the
+// transforms of export when given
+func writeRewriteExport(t *testing.T, exportDir string, layout string, output string, compress bool, transforms *ExportOptions) []index.Entry {
+	t.Helper()
+	namespace := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   map[string]interface{}{"name": "foo", "labels": map[string]interface{}{namespaceNameLabel: "foo", "team": "a"}},
+	}}
+	deployment := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "foo", "labels": map[string]interface{}{"version": "1"}},
+		"spec": map[string]interface{}{"replicas": int64(3), "template": map[string]interface{}{"spec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"name": "web", "image": "quay.io/acme/web:1.0"}},
+		}}},
+	}}
+	claim := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "PersistentVolumeClaim",
+		"metadata":   map[string]interface{}{"name": "data", "namespace": "foo"},
+		"spec":       map[string]interface{}{"storageClassName": "gp2"},
+	}}
+	binding := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "rbac.authorization.k8s.io/v1",
+		"kind":       "RoleBinding",
+		"metadata":   map[string]interface{}{"name": "reader", "namespace": "foo"},
+		"subjects":   []interface{}{map[string]interface{}{"kind": "ServiceAccount", "name": "web", "namespace": "foo"}},
+	}}
+	resources := []*groupResource{
+		{APIGroup: "apps", APIVersion: "v1", APIResource: metav1.APIResource{Name: "deployments", Kind: "Deployment"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{deployment}}},
+		{APIVersion: "v1", APIResource: metav1.APIResource{Name: "persistentvolumeclaims", Kind: "PersistentVolumeClaim"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{claim}}},
+		{APIGroup: "rbac.authorization.k8s.io", APIVersion: "v1", APIResource: metav1.APIResource{Name: "rolebindings", Kind: "RoleBinding"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{binding}}},
+	}
+	namespaceObj := &groupResource{APIVersion: "v1", APIResource: metav1.APIResource{Name: "namespaces", Kind: "Namespace"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{namespace}}}
+	if transforms != nil {
+		applyStripRules(resources, transforms.stripRules)
+		newImageRewriter(transforms.imageMappings).rewrite(resources)
+		transforms.storageClasses.rewrite(resources)
+		renamer := NewNamespaceRenamer(transforms.targetNamespace)
+		renamer.rename("foo", resources)
+		applyStripRules([]*groupResource{namespaceObj}, transforms.stripRules)
+		renamer.rename("foo", []*groupResource{namespaceObj})
+	}
+
+	if err := os.MkdirAll(filepath.Join(exportDir, "resources", "foo"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	manifests := newExportIndex(exportDir)
+	w := &resourceWriter{
+		resourceDir: filepath.Join(exportDir, "resources", "foo"),
+		output:      output,
+		layout:      layout,
+		singleFile:  filepath.Join(exportDir, "resources", "foo.yaml"),
+		workers:     1,
+		compress:    compress,
+		namespace:   namespaceObj,
+		index:       manifests,
+		log:         testLogger(),
+	}
+	if errs := w.writeResources(resources); len(errs) > 0 {
+		t.Fatalf("writeResources() errors = %v", errs)
+	}
+	if err := manifests.write(); err != nil {
+		t.Fatal(err)
+	}
+	summary := &RunSummary{Flags: map[string]string{"namespace": "[foo]"}, Namespaces: []*exportSummary{newExportSummary("foo")}}
+	if err := summary.write(exportDir); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := index.Read(exportDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func testRewriteTransforms(t *testing.T) *ExportOptions {
+	t.Helper()
+	rule, err := parseStripRule("Deployment:spec.replicas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mappings, err := parseImageMap([]string{"quay.io=mirror.example.com/quay"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	classes, err := ParseStorageClassMap([]string{"gp2=standard-rwo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ExportOptions{stripRules: []stripRule{rule}, imageMappings: mappings, storageClasses: classes, targetNamespace: "bar"}
+}
+
+func TestRewrite_Run_roundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		layout   string
+		output   string
+		compress bool
+	}{
+		{name: "given the flat layout, should write the export taken with the transforms", layout: LayoutFlat, output: OutputYAML},
+		{name: "given the kind layout in JSON, should write the export taken with the transforms", layout: LayoutKind, output: OutputJSON},
+		{name: "given the single layout, should write the export taken with the transforms", layout: LayoutSingle, output: OutputYAML},
+		{name: "given a compressed export, should write the export taken with the transforms", layout: LayoutFlat, output: OutputYAML, compress: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			exportDir, outputDir, wantDir := filepath.Join(dir, "raw"), filepath.Join(dir, "rewritten"), filepath.Join(dir, "transformed")
+			raw := writeRewriteExport(t, exportDir, tt.layout, tt.output, tt.compress, nil)
+			want := writeRewriteExport(t, wantDir, tt.layout, tt.output, tt.compress, testRewriteTransforms(t))
+
+			o := &Rewrite{ExportDir: exportDir, OutputDir: outputDir, transforms: testRewriteTransforms(t), flagsUsed: map[string]string{"target-namespace": "bar"}}
+			if err := o.Run(context.Background(), testLogger()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			got, err := index.Read(outputDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("rewritten index = %+v, want %+v", got, want)
+			}
+			if unchanged, err := index.Read(exportDir); err != nil || !reflect.DeepEqual(unchanged, raw) {
+				t.Errorf("source index = %+v, %v, want it unchanged", unchanged, err)
+			}
+			summary, err := ReadRunSummary(outputDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary.Flags["target-namespace"] != "bar" || summary.Flags["namespace"] != "[foo]" {
+				t.Errorf("summary flags = %v, want the export and rewrite flags", summary.Flags)
+			}
+			if s := summary.Namespaces[0]; s.RewrittenImages != 1 || s.StorageClasses != 1 {
+				t.Errorf("namespace summary = %+v, want 1 rewritten image and 1 storage class", s)
+			}
+			for _, report := range []string{ImageRewritesFile, namespaceWarningsFile} {
+				if _, err := os.Stat(filepath.Join(outputDir, report)); err != nil {
+					t.Errorf("%s not written: %v", report, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRewrite_Run_copiesUntransformed(t *testing.T) {
+	dir := t.TempDir()
+	exportDir, outputDir := filepath.Join(dir, "export"), filepath.Join(dir, "rewritten")
+	writeRewriteExport(t, exportDir, LayoutFlat, OutputYAML, false, nil)
+	files := map[string]string{
+		"resources/foo/_cluster/crds/apiextensions.k8s.io_customresourcedefinitions_widgets.example.com.yaml": "apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\nmetadata:\n  name: widgets.example.com\n",
+		"resources/foo/secrets_db.yaml.age": "encrypted",
+		"failures/foo/failures.json":        "[]\n",
+		ApplyResultsFile:                    "[]\n",
+	}
+	entries, err := index.Read(exportDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range files {
+		full := filepath.Join(exportDir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(path, "resources/") {
+			entries = append(entries, index.Entry{Path: path, Size: int64(len(content)), SHA256: index.Sum([]byte(content))})
+		}
+	}
+	if err := index.Write(exportDir, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	o := &Rewrite{ExportDir: exportDir, OutputDir: outputDir, transforms: &ExportOptions{targetNamespace: "bar"}, flagsUsed: map[string]string{"target-namespace": "bar"}}
+	if err := o.Run(context.Background(), testLogger()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	for path, content := range files {
+		got, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(path)))
+		switch {
+		case path == ApplyResultsFile:
+			if !os.IsNotExist(err) {
+				t.Errorf("%s copied, want it left out", path)
+			}
+		case err != nil:
+			t.Errorf("%s not copied: %v", path, err)
+		case string(got) != content:
+			t.Errorf("%s = %q, want it unchanged", path, got)
+		}
+	}
+	rewritten, err := ReadManifestFile(filepath.Join(outputDir, "resources", "foo", "rolebindings.rbac.authorization.k8s.io_reader.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rewritten) != 1 || rewritten[0].GetNamespace() != "bar" {
+		t.Errorf("rewritten RoleBinding = %+v, want it in namespace bar", rewritten)
+	}
+}
+
+func TestRewrite_Validate(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		o       *Rewrite
+		wantErr bool
+	}{
+		{
+			name: "given an output directory and a transform, should pass",
+			o:    &Rewrite{ExportDir: dir, OutputDir: dir + "-bar", transforms: &ExportOptions{targetNamespace: "bar", transformWorkers: 1}, flagsUsed: map[string]string{"target-namespace": "bar"}},
+		},
+		{
+			name:    "given no output directory, should fail",
+			o:       &Rewrite{ExportDir: dir, transforms: &ExportOptions{targetNamespace: "bar", transformWorkers: 1}, flagsUsed: map[string]string{"target-namespace": "bar"}},
+			wantErr: true,
+		},
+		{
+			name:    "given the output directory under the export directory, should fail",
+			o:       &Rewrite{ExportDir: dir, OutputDir: filepath.Join(dir, "bar"), transforms: &ExportOptions{targetNamespace: "bar", transformWorkers: 1}, flagsUsed: map[string]string{"target-namespace": "bar"}},
+			wantErr: true,
+		},
+		{
+			name:    "given no transform, should fail",
+			o:       &Rewrite{ExportDir: dir, OutputDir: dir + "-bar", transforms: &ExportOptions{transformWorkers: 1}, flagsUsed: map[string]string{}},
+			wantErr: true,
+		},
+		{
+			name:    "given an invalid target namespace, should fail",
+			o:       &Rewrite{ExportDir: dir, OutputDir: dir + "-bar", transforms: &ExportOptions{targetNamespace: "Bar", transformWorkers: 1}, flagsUsed: map[string]string{"target-namespace": "Bar"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.o.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
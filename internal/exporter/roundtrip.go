@@ -0,0 +1,135 @@
+package exporter
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// maxRoundTripDiffs bounds the fields listed in the failure of an object that does not round trip
+const maxRoundTripDiffs = 10
+
+// roundTripError is the failure of an exported object whose file does not read back as the object,
+// e.g. an int-or-string field written in a way that reads back as the other type
+type roundTripError struct {
+	diffs []string
+}
+
+func (e *roundTripError) Error() string {
+	diffs := e.diffs
+	more := ""
+	if len(diffs) > maxRoundTripDiffs {
+		more = fmt.Sprintf("; and %d more fields", len(diffs)-maxRoundTripDiffs)
+		diffs = diffs[:maxRoundTripDiffs]
+	}
+	return "the file does not read back as the exported object: " + strings.Join(diffs, "; ") + more
+}
+
+// checkRoundTrip reads the file of an object back and compares it with the object, after the
+// fields removed by export were removed. The Secrets encrypted with --encrypt-secrets-to are
+// compared from their serialization, they cannot be decrypted.
+func (w *resourceWriter) checkRoundTrip(path string, obj unstructured.Unstructured, serialized []byte, encrypted bool) error {
+	data := serialized
+	if !encrypted {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return err
+		}
+		if w.compress {
+			if data, err = gunzipBytes(data); err != nil {
+				return err
+			}
+		}
+	}
+	diffs, err := roundTripDiffs(obj, data)
+	if err != nil {
+		return err
+	}
+	if len(diffs) > 0 {
+		return &roundTripError{diffs: diffs}
+	}
+	return nil
+}
+
+// roundTripDiffs decodes a YAML or JSON file to unstructured like transform and apply read it, and
+// returns the fields that differ from the object, by value or by type
+func roundTripDiffs(obj unstructured.Unstructured, data []byte) ([]string, error) {
+	jsonBytes, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the file back: %w", err)
+	}
+	read := &unstructured.Unstructured{}
+	if err := read.UnmarshalJSON(jsonBytes); err != nil {
+		return nil, fmt.Errorf("cannot read the file back: %w", err)
+	}
+	return fieldDiffs("", obj.Object, read.Object), nil
+}
+
+// fieldDiffs returns the fields of want and got that differ, as path: want read back as got
+func fieldDiffs(path string, want interface{}, got interface{}) []string {
+	wantMap, wantIsMap := want.(map[string]interface{})
+	gotMap, gotIsMap := got.(map[string]interface{})
+	if wantIsMap && gotIsMap {
+		keys := []string{}
+		for key := range wantMap {
+			keys = append(keys, key)
+		}
+		for key := range gotMap {
+			if _, ok := wantMap[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		diffs := []string{}
+		for _, key := range keys {
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+			wantValue, inWant := wantMap[key]
+			gotValue, inGot := gotMap[key]
+			switch {
+			case !inGot:
+				diffs = append(diffs, fmt.Sprintf("%s: %s missing", fieldPath, describeValue(wantValue)))
+			case !inWant:
+				diffs = append(diffs, fmt.Sprintf("%s: %s added", fieldPath, describeValue(gotValue)))
+			default:
+				diffs = append(diffs, fieldDiffs(fieldPath, wantValue, gotValue)...)
+			}
+		}
+		return diffs
+	}
+
+	wantSlice, wantIsSlice := want.([]interface{})
+	gotSlice, gotIsSlice := got.([]interface{})
+	if wantIsSlice && gotIsSlice && len(wantSlice) == len(gotSlice) {
+		diffs := []string{}
+		for i := range wantSlice {
+			diffs = append(diffs, fieldDiffs(fmt.Sprintf("%s[%d]", path, i), wantSlice[i], gotSlice[i])...)
+		}
+		return diffs
+	}
+
+	if reflect.DeepEqual(want, got) {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s: %s read back as %s", path, describeValue(want), describeValue(got))}
+}
+
+// describeValue returns a value with its type, the types telling apart 8080 and "8080"
+func describeValue(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return fmt.Sprintf("map of %d fields", len(v))
+	case []interface{}:
+		return fmt.Sprintf("list of %d items", len(v))
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%#v (%T)", value, value)
+}
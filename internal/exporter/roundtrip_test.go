@@ -0,0 +1,139 @@
+package exporter
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// trickyObject has the fields YAML is known to mangle: quantities, int-or-string ports and
+// strings that read as numbers, booleans or null when unquoted
+func trickyObject() unstructured.Unstructured {
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"metadata":   map[string]interface{}{"name": "tricky", "namespace": "foo"},
+		"spec": map[string]interface{}{
+			"resources": map[string]interface{}{
+				"limits":   map[string]interface{}{"cpu": "500m", "memory": "1Gi", "ephemeral-storage": "1e3"},
+				"requests": map[string]interface{}{"cpu": int64(1), "memory": "128974848"},
+			},
+			"ports": []interface{}{
+				map[string]interface{}{"port": int64(80), "targetPort": "8080"},
+				map[string]interface{}{"port": int64(443), "targetPort": int64(8443)},
+				map[string]interface{}{"port": int64(9090), "targetPort": "metrics"},
+			},
+			"maxUnavailable": "25%",
+			"mode":           "0755",
+			"octal":          "0o17",
+			"hex":            "0x1F",
+			"sexagesimal":    "1:30",
+			"enabled":        "yes",
+			"flag":           "on",
+			"empty":          "",
+			"tilde":          "~",
+			"null":           "null",
+			"ratio":          0.5,
+			"version":        "1.10",
+		},
+	}}
+}
+
+func Test_roundTripDiffs(t *testing.T) {
+	for _, output := range []string{OutputYAML, OutputJSON} {
+		t.Run(output+" keeps the tricky fields", func(t *testing.T) {
+			obj := trickyObject()
+			data, err := marshalObject(obj, output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			diffs, err := roundTripDiffs(obj, data)
+			if err != nil || len(diffs) != 0 {
+				t.Errorf("roundTripDiffs() = %v, %v, want no difference in:\n%s", diffs, err, data)
+			}
+		})
+	}
+
+	// a transform may give the integers as float64 or int, they are read back as int64
+	obj := trickyObject()
+	ports, _, _ := unstructured.NestedSlice(obj.Object, "spec", "ports")
+	ports[1].(map[string]interface{})["targetPort"] = float64(8443)
+	unstructured.SetNestedSlice(obj.Object, ports, "spec", "ports")
+	obj.Object["spec"].(map[string]interface{})["replicas"] = 3
+	data, err := marshalObject(obj, OutputYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := roundTripDiffs(obj, data)
+	want := []string{
+		"spec.ports[1].targetPort: 8443 (float64) read back as 8443 (int64)",
+		"spec.replicas: 3 (int) read back as 3 (int64)",
+	}
+	if err != nil || !reflect.DeepEqual(diffs, want) {
+		t.Errorf("roundTripDiffs() = %q, %v, want %q", diffs, err, want)
+	}
+
+	if _, err := roundTripDiffs(obj, []byte("spec: [")); err == nil {
+		t.Errorf("roundTripDiffs() of an invalid file should fail")
+	}
+}
+
+func Test_fieldDiffs(t *testing.T) {
+	want := map[string]interface{}{"a": "1", "b": []interface{}{int64(1), int64(2)}, "c": map[string]interface{}{"d": true}}
+	got := map[string]interface{}{"a": int64(1), "b": []interface{}{int64(1)}, "c": map[string]interface{}{}, "e": nil}
+	diffs := fieldDiffs("", want, got)
+	wantDiffs := []string{
+		`a: "1" (string) read back as 1 (int64)`,
+		"b: list of 2 items read back as list of 1 items",
+		"c.d: true (bool) missing",
+		"e: null added",
+	}
+	if !reflect.DeepEqual(diffs, wantDiffs) {
+		t.Errorf("fieldDiffs() = %q, want %q", diffs, wantDiffs)
+	}
+}
+
+func Test_resourceWriter_verifyRoundTrip(t *testing.T) {
+	changed := trickyObject()
+	changed.SetName("changed")
+	unstructured.SetNestedField(changed.Object, float64(2), "spec", "replicas")
+	resources := []*groupResource{
+		{APIGroup: "example.com", APIVersion: "v1", APIResource: metav1.APIResource{Name: "widgets", Kind: "Widget"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{trickyObject(), changed}}},
+	}
+	for _, compress := range []bool{false, true} {
+		w := &resourceWriter{resourceDir: t.TempDir(), output: OutputYAML, layout: LayoutFlat, workers: 1, compress: compress, verifyRoundTrip: true, log: testLogger()}
+		errs := w.writeResources(resources)
+		if len(errs) != 1 {
+			t.Fatalf("writeResources() compress=%v errors = %v, want the changed object only", compress, errs)
+		}
+		record := writeFailureRecord(errs[0])
+		var roundTrip *roundTripError
+		if !errors.As(errs[0], &roundTrip) || record.Name != "changed" || record.Category != failureRoundTrip || !strings.Contains(record.Error, "spec.replicas: 2 (float64) read back as 2 (int64)") {
+			t.Errorf("writeFailureRecord() compress=%v = %+v", compress, record)
+		}
+	}
+
+	// the files left unchanged by --incremental are read back too
+	exportDir := t.TempDir()
+	first := newExportIndex(exportDir)
+	w := &resourceWriter{resourceDir: exportDir, output: OutputYAML, layout: LayoutFlat, workers: 1, index: first, log: testLogger()}
+	if errs := w.writeResources(resources); len(errs) != 0 {
+		t.Fatalf("writeResources() errors = %v, want none without verifyRoundTrip", errs)
+	}
+	second := newExportIndex(exportDir)
+	second.loadPrevious(first.entries)
+	w = &resourceWriter{resourceDir: exportDir, output: OutputYAML, layout: LayoutFlat, workers: 1, index: second, verifyRoundTrip: true, log: testLogger()}
+	if errs := w.writeResources(resources); len(errs) != 1 || writeFailureRecord(errs[0]).Category != failureRoundTrip {
+		t.Errorf("writeResources() incremental errors = %v, want the changed object only", errs)
+	}
+
+	// off by default
+	w = &resourceWriter{resourceDir: t.TempDir(), output: OutputYAML, layout: LayoutFlat, workers: 1, log: testLogger()}
+	if errs := w.writeResources(resources); len(errs) != 0 {
+		t.Errorf("writeResources() errors = %v, want none without verifyRoundTrip", errs)
+	}
+}
@@ -0,0 +1,274 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/s3"
+	"github.com/sirupsen/logrus"
+)
+
+// s3LastFiles are uploaded once every other file is, in this order, so that an export with an
+// index in the bucket is complete
+var s3LastFiles = []string{summaryTextFile, SummaryJSONFile, index.File}
+
+// objectStore is the part of the S3 client the uploads use
+type objectStore interface {
+	PutObject(ctx context.Context, bucket, key string, data []byte) error
+	HasObjects(ctx context.Context, bucket, prefix string) (bool, error)
+}
+
+// uploadFailure is a file of the export that could not be uploaded
+type uploadFailure struct {
+	Path string
+	Err  error
+}
+
+// s3Uploader uploads the files of an export staged in a local directory as they are written. The
+// upload is concurrent, the files are small and the staging directory is removed once done.
+type s3Uploader struct {
+	store      objectStore
+	location   s3.Location
+	stagingDir string
+	queue      chan string
+	wg         sync.WaitGroup
+	mu         sync.Mutex
+	// uploaded and failed are the paths of the files relative to the staging directory
+	uploaded map[string]bool
+	failed   []uploadFailure
+	log      logrus.FieldLogger
+}
+
+func newS3Uploader(store objectStore, location s3.Location, stagingDir string, workers int, log logrus.FieldLogger) *s3Uploader {
+	u := &s3Uploader{
+		store:      store,
+		location:   location,
+		stagingDir: stagingDir,
+		queue:      make(chan string, workers),
+		uploaded:   map[string]bool{},
+		log:        log,
+	}
+	for i := 0; i < workers; i++ {
+		u.wg.Add(1)
+		go func() {
+			defer u.wg.Done()
+			for rel := range u.queue {
+				u.upload(rel)
+			}
+		}()
+	}
+	return u
+}
+
+// enqueue uploads a file once written to the staging directory
+func (u *s3Uploader) enqueue(path string) {
+	if u == nil {
+		return
+	}
+	rel, err := filepath.Rel(u.stagingDir, path)
+	if err != nil {
+		return
+	}
+	u.queue <- filepath.ToSlash(rel)
+}
+
+// upload uploads a file of the staging directory, the failures are recorded. The uploads are not
+// stopped by an interruption of the export, what it wrote is uploaded.
+func (u *s3Uploader) upload(rel string) {
+	data, err := os.ReadFile(filepath.Join(u.stagingDir, filepath.FromSlash(rel)))
+	if err == nil {
+		err = u.store.PutObject(context.Background(), u.location.Bucket, u.location.Key(rel), data)
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if err != nil {
+		u.log.Errorf("cannot upload %s: %v", rel, err)
+		u.failed = append(u.failed, uploadFailure{Path: rel, Err: err})
+		return
+	}
+	u.log.Debugf("Uploaded %s to %s", rel, u.location)
+	u.uploaded[rel] = true
+}
+
+// wait waits for the files being uploaded
+func (u *s3Uploader) wait() {
+	close(u.queue)
+	u.wg.Wait()
+}
+
+// finish uploads the files written without being indexed, like the reports and the failures, then
+// the summary and the index, unless some files could not be uploaded
+func (u *s3Uploader) finish() error {
+	u.wait()
+	last := map[string]bool{}
+	for _, name := range s3LastFiles {
+		last[name] = true
+	}
+	err := filepath.Walk(u.stagingDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(u.stagingDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !u.uploaded[rel] && !last[rel] && !u.hasFailed(rel) {
+			u.upload(rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(u.failed) > 0 {
+		return fmt.Errorf("%d files could not be uploaded to %s, the summary and the index were not uploaded", len(u.failed), u.location)
+	}
+	for _, name := range s3LastFiles {
+		if _, err := os.Stat(filepath.Join(u.stagingDir, name)); os.IsNotExist(err) {
+			continue
+		}
+		u.upload(name)
+		if len(u.failed) > 0 {
+			return fmt.Errorf("cannot upload %s to %s: %w", name, u.location, u.failed[0].Err)
+		}
+	}
+	return nil
+}
+
+func (u *s3Uploader) hasFailed(rel string) bool {
+	for _, f := range u.failed {
+		if f.Path == rel {
+			return true
+		}
+	}
+	return false
+}
+
+// printFailures prints the files that could not be uploaded and, when the export failed, those
+// already uploaded, so that the incomplete export can be cleaned up from the bucket
+func (u *s3Uploader) printFailures(out io.Writer, exportFailed bool) {
+	if len(u.failed) > 0 {
+		fmt.Fprintf(out, "Files not uploaded to %s:\n", u.location)
+		for _, f := range sortedFailures(u.failed) {
+			fmt.Fprintf(out, "  %s: %v\n", u.location.Key(f.Path), f.Err)
+		}
+	}
+	if exportFailed && len(u.uploaded) > 0 {
+		fmt.Fprintf(out, "Files of the failed export uploaded to %s:\n", u.location)
+		for _, rel := range sortedKeys(u.uploaded) {
+			fmt.Fprintf(out, "  %s\n", u.location.Key(rel))
+		}
+	}
+}
+
+// runToS3 exports into a staging directory and uploads each file to the bucket as it is written,
+// the summary and the index last
+func (o *ExportOptions) runToS3(ctx context.Context, log logrus.FieldLogger) error {
+	location, err := s3.ParseURL(o.exportDir)
+	if err != nil {
+		return err
+	}
+	client, err := s3.NewClient(s3.Config{
+		Endpoint:    o.s3Endpoint,
+		SSE:         o.s3SSE,
+		SSEKMSKeyID: o.s3SSEKMSKeyID,
+		Retries:     o.retries,
+		Backoff:     o.retryBackoff,
+	}, log)
+	if err != nil {
+		return err
+	}
+	return o.runToStore(ctx, client, location, log)
+}
+
+func (o *ExportOptions) runToStore(ctx context.Context, store objectStore, location s3.Location, log logrus.FieldLogger) error {
+	exists, err := store.HasObjects(ctx, location.Bucket, location.Key("resources"))
+	if err != nil {
+		log.Errorf("cannot list %s: %v", location, err)
+		return err
+	}
+	if exists && !o.overwrite {
+		return fmt.Errorf("%s already contains an export, use --overwrite to replace it", location)
+	}
+
+	stagingDir, err := os.MkdirTemp("", "kubectl-migrate-export-")
+	if err != nil {
+		log.Errorf("error creating the staging directory: %#v", err)
+		return err
+	}
+	defer os.RemoveAll(stagingDir)
+
+	exportDir := o.exportDir
+	o.exportDir = stagingDir
+	o.upload = newS3Uploader(store, location, stagingDir, o.workers, log)
+	exportErr := o.run(ctx, log)
+	o.exportDir = exportDir
+	upload := o.upload
+	o.upload = nil
+
+	if isFatal(exportErr) {
+		upload.wait()
+		upload.printFailures(o.ErrOut, true)
+		return exportErr
+	}
+	if err := upload.finish(); err != nil {
+		upload.printFailures(o.ErrOut, false)
+		return err
+	}
+	log.Infof("Export uploaded to %s", location)
+	return exportErr
+}
+
+// isFatal reports whether the export failed before writing its summary, the partial failures, the
+// timeouts, the interruptions, the deprecations and the budget leave a complete summary
+func isFatal(err error) bool {
+	if err == nil {
+		return false
+	}
+	var interrupted *InterruptedError
+	var timeout *TimeoutError
+	var partial *PartialFailureError
+	var deprecated *DeprecatedAPIError
+	var budget *BudgetExceededError
+	return !errors.As(err, &interrupted) && !errors.As(err, &timeout) && !errors.As(err, &partial) && !errors.As(err, &deprecated) && !errors.As(err, &budget)
+}
+
+// validateS3 checks the flags of an export to an s3:// URL, the flags reading the previous export
+// need a local export directory
+func (o *ExportOptions) validateS3() error {
+	if !s3.IsURL(o.exportDir) {
+		if o.s3Endpoint != "" || o.s3SSE != "" || o.s3SSEKMSKeyID != "" {
+			return fmt.Errorf("--s3-endpoint, --s3-sse and --s3-sse-kms-key-id require an %s --export-dir", s3.Scheme)
+		}
+		return nil
+	}
+	if _, err := s3.ParseURL(o.exportDir); err != nil {
+		return err
+	}
+	switch {
+	case o.archive:
+		return fmt.Errorf("--archive cannot be used with an %s --export-dir", s3.Scheme)
+	case o.incremental, o.retryFailures:
+		return fmt.Errorf("--incremental and --retry-failures read the previous export, they cannot be used with an %s --export-dir", s3.Scheme)
+	case o.s3SSE != "" && o.s3SSE != s3.SSEAES256 && o.s3SSE != s3.SSEKMS:
+		return fmt.Errorf("invalid --s3-sse %q, must be one of: %s, %s", o.s3SSE, s3.SSEAES256, s3.SSEKMS)
+	case o.s3SSEKMSKeyID != "" && o.s3SSE != s3.SSEKMS:
+		return fmt.Errorf("--s3-sse-kms-key-id requires --s3-sse %s", s3.SSEKMS)
+	}
+	return nil
+}
+
+// sortedFailures returns the upload failures sorted by path
+func sortedFailures(failures []uploadFailure) []uploadFailure {
+	sorted := append([]uploadFailure{}, failures...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+	return sorted
+}
@@ -0,0 +1,222 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/index"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/s3"
+)
+
+// fakeStore records the uploaded keys in order, the keys in fail cannot be uploaded
+type fakeStore struct {
+	mu       sync.Mutex
+	uploaded []string
+	objects  map[string]string
+	fail     map[string]bool
+}
+
+func (s *fakeStore) PutObject(ctx context.Context, bucket, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail[key] {
+		return errors.New("S3 returned 403 AccessDenied: denied")
+	}
+	s.uploaded = append(s.uploaded, key)
+	s.objects[bucket+"/"+key] = string(data)
+	return nil
+}
+
+func (s *fakeStore) HasObjects(ctx context.Context, bucket, prefix string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.objects {
+		if strings.HasPrefix(key, bucket+"/"+prefix+"/") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// stageExport writes the files of an export in the staging directory, the resources through the
+// index uploading them
+func stageExport(t *testing.T, u *s3Uploader, stagingDir string) {
+	t.Helper()
+	manifests := newExportIndex(stagingDir)
+	manifests.upload = u
+	resourceDir := filepath.Join(stagingDir, "resources", "foo")
+	if err := os.MkdirAll(resourceDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"configmaps_a.yaml", "configmaps_b.yaml"} {
+		path := filepath.Join(resourceDir, name)
+		if err := os.WriteFile(path, []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+		manifests.add(path, []byte(name), nil)
+	}
+	failuresDir := filepath.Join(stagingDir, "failures", "foo")
+	if err := os.MkdirAll(failuresDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFailureRecords(failuresDir, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := newRunSummary(time.Now(), "", nil, nil).write(stagingDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := manifests.write(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_s3Uploader(t *testing.T) {
+	location := s3.Location{Bucket: "bucket", Prefix: "exports/prod"}
+
+	t.Run("given a complete export, should upload the summary and the index last", func(t *testing.T) {
+		store := &fakeStore{objects: map[string]string{}}
+		stagingDir := t.TempDir()
+		u := newS3Uploader(store, location, stagingDir, 2, testLogger())
+		stageExport(t, u, stagingDir)
+		if err := u.finish(); err != nil {
+			t.Fatalf("finish() error = %v", err)
+		}
+		if len(store.uploaded) != 6 {
+			t.Fatalf("uploaded = %v, want 6 files", store.uploaded)
+		}
+		last := store.uploaded[3:]
+		want := []string{"exports/prod/" + summaryTextFile, "exports/prod/" + SummaryJSONFile, "exports/prod/" + index.File}
+		if !reflect.DeepEqual(last, want) {
+			t.Errorf("last uploads = %v, want %v", last, want)
+		}
+		if store.objects["bucket/exports/prod/resources/foo/configmaps_a.yaml"] != "configmaps_a.yaml" {
+			t.Errorf("uploaded objects = %v, want the resource files", sortedKeys(store.objects))
+		}
+	})
+
+	t.Run("given a file that cannot be uploaded, should not upload the index and print it", func(t *testing.T) {
+		store := &fakeStore{objects: map[string]string{}, fail: map[string]bool{"exports/prod/resources/foo/configmaps_b.yaml": true}}
+		stagingDir := t.TempDir()
+		u := newS3Uploader(store, location, stagingDir, 2, testLogger())
+		stageExport(t, u, stagingDir)
+		err := u.finish()
+		if err == nil || !strings.Contains(err.Error(), "1 files could not be uploaded to s3://bucket/exports/prod") {
+			t.Errorf("finish() error = %v, want the failed upload", err)
+		}
+		if _, found := store.objects["bucket/exports/prod/"+index.File]; found {
+			t.Errorf("the index of an incomplete upload was uploaded")
+		}
+		out := &bytes.Buffer{}
+		u.printFailures(out, false)
+		if !strings.Contains(out.String(), "  exports/prod/resources/foo/configmaps_b.yaml: S3 returned 403 AccessDenied") {
+			t.Errorf("printFailures() = %q, want the file not uploaded", out.String())
+		}
+	})
+
+	t.Run("given a failed export, should print the files already uploaded", func(t *testing.T) {
+		store := &fakeStore{objects: map[string]string{}}
+		stagingDir := t.TempDir()
+		u := newS3Uploader(store, location, stagingDir, 1, testLogger())
+		path := filepath.Join(stagingDir, "a.yaml")
+		os.WriteFile(path, []byte("a"), 0600)
+		u.enqueue(path)
+		u.wait()
+		out := &bytes.Buffer{}
+		u.printFailures(out, true)
+		if out.String() != "Files of the failed export uploaded to s3://bucket/exports/prod:\n  exports/prod/a.yaml\n" {
+			t.Errorf("printFailures() = %q", out.String())
+		}
+	})
+
+	var nilUploader *s3Uploader
+	nilUploader.enqueue("a.yaml")
+}
+
+func Test_runToStore_existingExport(t *testing.T) {
+	store := &fakeStore{objects: map[string]string{"bucket/exports/resources/foo/configmaps_a.yaml": "a"}}
+	o := &ExportOptions{}
+	err := o.runToStore(context.Background(), store, s3.Location{Bucket: "bucket", Prefix: "exports"}, testLogger())
+	if err == nil || !strings.Contains(err.Error(), "already contains an export") {
+		t.Errorf("runToStore() error = %v, want the previous export found", err)
+	}
+}
+
+func Test_validateS3(t *testing.T) {
+	tests := []struct {
+		name        string
+		o           *ExportOptions
+		errContains string
+	}{
+		{
+			name: "given an s3 URL with encryption, should pass",
+			o:    &ExportOptions{exportDir: "s3://bucket/exports", s3SSE: s3.SSEKMS, s3SSEKMSKeyID: "alias/exports"},
+		},
+		{
+			name: "given a local directory, should pass",
+			o:    &ExportOptions{exportDir: "export"},
+		},
+		{
+			name:        "given an endpoint without s3 URL, should fail",
+			o:           &ExportOptions{exportDir: "export", s3Endpoint: "http://minio:9000"},
+			errContains: "require an s3:// --export-dir",
+		},
+		{
+			name:        "given --archive, should fail",
+			o:           &ExportOptions{exportDir: "s3://bucket", archive: true},
+			errContains: "--archive",
+		},
+		{
+			name:        "given --incremental, should fail",
+			o:           &ExportOptions{exportDir: "s3://bucket", incremental: true},
+			errContains: "read the previous export",
+		},
+		{
+			name:        "given an unknown encryption, should fail",
+			o:           &ExportOptions{exportDir: "s3://bucket", s3SSE: "aes"},
+			errContains: "invalid --s3-sse",
+		},
+		{
+			name:        "given a KMS key without aws:kms, should fail",
+			o:           &ExportOptions{exportDir: "s3://bucket", s3SSE: s3.SSEAES256, s3SSEKMSKeyID: "alias/exports"},
+			errContains: "requires --s3-sse aws:kms",
+		},
+		{
+			name:        "given no bucket, should fail",
+			o:           &ExportOptions{exportDir: "s3:///exports"},
+			errContains: "has no bucket",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.o.validateS3()
+			if tt.errContains == "" {
+				if err != nil {
+					t.Errorf("validateS3() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("validateS3() error = %v, want it to contain %q", err, tt.errContains)
+			}
+		})
+	}
+}
+
+func Test_isFatal(t *testing.T) {
+	for _, err := range []error{nil, &PartialFailureError{Failures: 1}, &TimeoutError{}, &InterruptedError{}, &DeprecatedAPIError{Objects: 1}} {
+		if isFatal(err) {
+			t.Errorf("isFatal(%v) = true, want false", err)
+		}
+	}
+	if !isFatal(errors.New("cannot create discovery client")) {
+		t.Errorf("isFatal() = false for a fatal error")
+	}
+}
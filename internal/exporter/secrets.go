@@ -0,0 +1,60 @@
+package exporter
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	redactedAnnotation = "migrate.konveyor.io/redacted"
+	redactedValue      = "<redacted>"
+)
+
+func isSecret(obj unstructured.Unstructured) bool {
+	return obj.GetKind() == "Secret" && obj.GroupVersionKind().Group == ""
+}
+
+// isRedacted tells whether the object is a Secret exported with --redact-secrets, whose values are
+// placeholders
+func isRedacted(obj unstructured.Unstructured) bool {
+	return isSecret(obj) && obj.GetAnnotations()[redactedAnnotation] == "true"
+}
+
+func secretType(obj unstructured.Unstructured) string {
+	t, _, _ := unstructured.NestedString(obj.Object, "type")
+	return t
+}
+
+// redactSecrets replaces the values of every Secret with a placeholder in place, keeping the keys
+// so the exported Secrets can still be used for migration planning
+func redactSecrets(resources []*groupResource) {
+	for _, r := range resources {
+		if r.objects == nil {
+			continue
+		}
+		for i := range r.objects.Items {
+			if isSecret(r.objects.Items[i]) {
+				redactSecret(&r.objects.Items[i])
+			}
+		}
+	}
+}
+
+func redactSecret(obj *unstructured.Unstructured) {
+	for _, field := range []string{"data", "stringData"} {
+		values, found, err := unstructured.NestedMap(obj.Object, field)
+		if !found || err != nil {
+			continue
+		}
+		for key := range values {
+			values[key] = redactedValue
+		}
+		_ = unstructured.SetNestedMap(obj.Object, values, field)
+	}
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[redactedAnnotation] = "true"
+	obj.SetAnnotations(annotations)
+}
@@ -0,0 +1,57 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func testSecret(name string, secretType string, data map[string]interface{}) unstructured.Unstructured {
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": "foo",
+		},
+		"type": secretType,
+		"data": data,
+	}}
+}
+
+func Test_redactSecrets(t *testing.T) {
+	opaque := testSecret("app", "Opaque", map[string]interface{}{"password": "c2VjcmV0"})
+	opaque.Object["stringData"] = map[string]interface{}{"token": "plain"}
+	token := testSecret("sa-token", "kubernetes.io/service-account-token", map[string]interface{}{"token": "ZXlKaGJHY2lPaUpTVXpJMU5pSXNJbXRwWkNJNkltWm9iR0lpZlE="})
+	configMap := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "config"},
+		"data":       map[string]interface{}{"key": "value"},
+	}}
+	resources := []*groupResource{{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{opaque, token, configMap}}}}
+
+	redactSecrets(resources)
+
+	items := resources[0].objects.Items
+	for _, secret := range items[:2] {
+		data, _, _ := unstructured.NestedStringMap(secret.Object, "data")
+		for key, value := range data {
+			if value != redactedValue {
+				t.Errorf("secret %s key %s was not redacted: %s", secret.GetName(), key, value)
+			}
+		}
+		if secret.GetAnnotations()[redactedAnnotation] != "true" {
+			t.Errorf("secret %s is missing the %s annotation", secret.GetName(), redactedAnnotation)
+		}
+	}
+	stringData, _, _ := unstructured.NestedStringMap(items[0].Object, "stringData")
+	if !reflect.DeepEqual(stringData, map[string]string{"token": redactedValue}) {
+		t.Errorf("stringData was not redacted: %v", stringData)
+	}
+	configData, _, _ := unstructured.NestedStringMap(items[2].Object, "data")
+	if !reflect.DeepEqual(configData, map[string]string{"key": "value"}) {
+		t.Errorf("configmap data should not be redacted: %v", configData)
+	}
+}
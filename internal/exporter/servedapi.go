@@ -0,0 +1,102 @@
+package exporter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/preflight"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ServedAPI is the API served by the target cluster, from its discovery data
+type ServedAPI struct {
+	resources map[schema.GroupVersionKind]metav1.APIResource
+	versions  map[schema.GroupKind][]string
+}
+
+func NewServedAPI(lists []*metav1.APIResourceList) *ServedAPI {
+	api := &ServedAPI{resources: map[schema.GroupVersionKind]metav1.APIResource{}, versions: map[schema.GroupKind][]string{}}
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			if strings.Contains(resource.Name, "/") {
+				continue
+			}
+			gvk := gv.WithKind(resource.Kind)
+			if _, ok := api.resources[gvk]; ok {
+				continue
+			}
+			api.resources[gvk] = resource
+			api.versions[gvk.GroupKind()] = append(api.versions[gvk.GroupKind()], gv.Version)
+		}
+	}
+	return api
+}
+
+// Resource returns the resource of the kind, reporting whether it is served
+func (a *ServedAPI) Resource(gvk schema.GroupVersionKind) (metav1.APIResource, bool) {
+	resource, served := a.resources[gvk]
+	return resource, served
+}
+
+// Check checks that each exported apiVersion and kind is served. A missing custom resource
+// only warns when its CRD is in the export, replay applies it first.
+func (a *ServedAPI) Check(objects []unstructured.Unstructured) []preflight.Result {
+	counts := map[schema.GroupVersionKind]int{}
+	exportedCRDs := map[schema.GroupKind]string{}
+	for _, obj := range objects {
+		gvk := obj.GroupVersionKind()
+		counts[gvk]++
+		if gvk.Group == "apiextensions.k8s.io" && gvk.Kind == "CustomResourceDefinition" {
+			group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+			kind, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "kind")
+			exportedCRDs[schema.GroupKind{Group: group, Kind: kind}] = obj.GetName()
+		}
+	}
+	gvks := make([]schema.GroupVersionKind, 0, len(counts))
+	for gvk := range counts {
+		gvks = append(gvks, gvk)
+	}
+	SortGVKs(gvks)
+
+	results := []preflight.Result{}
+	for _, gvk := range gvks {
+		apiVersion, kind := gvk.ToAPIVersionAndKind()
+		result := preflight.Result{Check: "API " + apiVersion + " " + kind}
+		versions := a.versions[gvk.GroupKind()]
+		crd, crdExported := exportedCRDs[gvk.GroupKind()]
+		_, served := a.resources[gvk]
+		switch {
+		case served:
+			result.Status, result.Detail = preflight.StatusPass, fmt.Sprintf("served, %d objects", counts[gvk])
+		case len(versions) > 0:
+			result.Status, result.Detail = preflight.StatusFail, fmt.Sprintf("%d objects, the target serves %s only in %s", counts[gvk], kind, strings.Join(versions, ", "))
+		case crdExported:
+			result.Status, result.Detail = preflight.StatusWarn, fmt.Sprintf("%d objects, the CRD %s is missing on the target, replay applies it from the export", counts[gvk], crd)
+		case isCustomGroup(gvk.Group):
+			result.Status, result.Detail = preflight.StatusFail, fmt.Sprintf("%d objects, the CRD of %s is missing on the target", counts[gvk], gvk.GroupKind())
+		default:
+			result.Status, result.Detail = preflight.StatusFail, fmt.Sprintf("%d objects, not served by the target", counts[gvk])
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// isCustomGroup reports whether the API group is not one of the built-in Kubernetes groups
+func isCustomGroup(group string) bool {
+	return group != "" && strings.Contains(group, ".") && !strings.HasSuffix(group, ".k8s.io")
+}
+
+// SortGVKs sorts the kinds like their checks, by apiVersion and kind
+func SortGVKs(gvks []schema.GroupVersionKind) {
+	sort.Slice(gvks, func(i, j int) bool {
+		return gvks[i].GroupVersion().String()+" "+gvks[i].Kind < gvks[j].GroupVersion().String()+" "+gvks[j].Kind
+	})
+}
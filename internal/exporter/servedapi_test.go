@@ -0,0 +1,55 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/preflight"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func testServedAPI() *ServedAPI {
+	return NewServedAPI([]*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "namespaces", Kind: "Namespace"},
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true},
+			{Name: "persistentvolumeclaims", Kind: "PersistentVolumeClaim", Namespaced: true},
+		}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "deployments", Kind: "Deployment", Namespaced: true},
+			{Name: "deployments/scale", Kind: "Scale", Namespaced: true},
+		}},
+		{GroupVersion: "networking.k8s.io/v1", APIResources: []metav1.APIResource{
+			{Name: "ingresses", Kind: "Ingress", Namespaced: true},
+		}},
+		{GroupVersion: "apiextensions.k8s.io/v1", APIResources: []metav1.APIResource{
+			{Name: "customresourcedefinitions", Kind: "CustomResourceDefinition"},
+		}},
+	})
+}
+
+func Test_servedAPI_check(t *testing.T) {
+	crd := testReplayObject("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "widgets.example.com")
+	crd.Object["spec"] = map[string]interface{}{"group": "example.com", "names": map[string]interface{}{"kind": "Widget"}}
+	objects := []unstructured.Unstructured{
+		testReplayObject("apps/v1", "Deployment", "foo", "web"),
+		testReplayObject("apps/v1", "Deployment", "foo", "api"),
+		testReplayObject("networking.k8s.io/v1beta1", "Ingress", "foo", "web"),
+		testReplayObject("example.com/v1", "Widget", "foo", "blue"),
+		testReplayObject("example.org/v1", "Gadget", "foo", "red"),
+		testReplayObject("policy/v1beta1", "PodSecurityPolicy", "", "restricted"),
+		crd,
+	}
+	want := []preflight.Result{
+		{Check: "API apiextensions.k8s.io/v1 CustomResourceDefinition", Status: preflight.StatusPass, Detail: "served, 1 objects"},
+		{Check: "API apps/v1 Deployment", Status: preflight.StatusPass, Detail: "served, 2 objects"},
+		{Check: "API example.com/v1 Widget", Status: preflight.StatusWarn, Detail: "1 objects, the CRD widgets.example.com is missing on the target, replay applies it from the export"},
+		{Check: "API example.org/v1 Gadget", Status: preflight.StatusFail, Detail: "1 objects, the CRD of Gadget.example.org is missing on the target"},
+		{Check: "API networking.k8s.io/v1beta1 Ingress", Status: preflight.StatusFail, Detail: "1 objects, the target serves Ingress only in v1"},
+		{Check: "API policy/v1beta1 PodSecurityPolicy", Status: preflight.StatusFail, Detail: "1 objects, not served by the target"},
+	}
+	if got := testServedAPI().Check(objects); !reflect.DeepEqual(got, want) {
+		t.Errorf("check() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,49 @@
+package exporter
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+// InterruptedError is returned when the export was stopped by a signal, what was listed before
+// is written and the resources left are recorded as interrupted in failures/<namespace>/failures.json
+type InterruptedError struct{}
+
+func (e *InterruptedError) Error() string {
+	return "export interrupted, see " + summaryTextFile + " and failures/<namespace>/" + FailuresFile
+}
+
+// notifyContext returns a context cancelled on the first SIGINT or SIGTERM, so that the export
+// stops listing and writes what it has. forceQuit is called on a second signal.
+func notifyContext(parent context.Context, log logrus.FieldLogger, forceQuit func()) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			log.Warnf("received %s, writing what was exported so far, send it again to quit immediately", sig)
+			cancel()
+		case <-done:
+			return
+		}
+		select {
+		case sig := <-signals:
+			log.Errorf("received %s again, quitting", sig)
+			forceQuit()
+		case <-done:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		close(done)
+		cancel()
+	}
+}
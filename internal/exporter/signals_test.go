@@ -0,0 +1,39 @@
+//go:build !windows
+
+package exporter
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func Test_notifyContext(t *testing.T) {
+	forced := make(chan struct{})
+	ctx, stop := notifyContext(context.Background(), testLogger(), func() { close(forced) })
+	defer stop()
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGINT); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the first signal should cancel the context")
+	}
+	select {
+	case <-forced:
+		t.Fatal("the first signal should not force quit")
+	default:
+	}
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-forced:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the second signal should force quit")
+	}
+}
@@ -0,0 +1,146 @@
+package exporter
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// kindRanks orders the documents of a single file export so that it can be applied as is: the
+// namespace, then RBAC, then the configuration the workloads depend on, then the workloads.
+// The other kinds come last.
+var kindRanks = map[string]int{
+	"Namespace": 0,
+
+	"ServiceAccount":     1,
+	"Role":               1,
+	"RoleBinding":        1,
+	"ClusterRole":        1,
+	"ClusterRoleBinding": 1,
+
+	"ConfigMap":             2,
+	"Secret":                2,
+	"PersistentVolumeClaim": 2,
+	"Service":               2,
+	"ResourceQuota":         2,
+	"LimitRange":            2,
+
+	"Deployment":       3,
+	"StatefulSet":      3,
+	"DaemonSet":        3,
+	"ReplicaSet":       3,
+	"Job":              3,
+	"CronJob":          3,
+	"Pod":              3,
+	"DeploymentConfig": 3,
+}
+
+const otherKindsRank = 4
+
+func kindRank(kind string) int {
+	if rank, ok := kindRanks[kind]; ok {
+		return rank
+	}
+	return otherKindsRank
+}
+
+// streamObject is an object of a single file export with its resource
+type streamObject struct {
+	resource *groupResource
+	obj      unstructured.Unstructured
+}
+
+// sortForApply sorts the objects by kind rank, then alphabetically by kind and name
+func sortForApply(objects []streamObject) {
+	sort.SliceStable(objects, func(i, j int) bool {
+		a, b := objects[i].obj, objects[j].obj
+		if kindRank(a.GetKind()) != kindRank(b.GetKind()) {
+			return kindRank(a.GetKind()) < kindRank(b.GetKind())
+		}
+		if a.GetKind() != b.GetKind() {
+			return a.GetKind() < b.GetKind()
+		}
+		return a.GetName() < b.GetName()
+	})
+}
+
+// writeSingle writes the namespaced objects to singleFile and the cluster-scoped ones, if any,
+// to clusterSingleFile
+func (w *resourceWriter) writeSingle(resources []*groupResource) []error {
+	namespaced := []streamObject{}
+	clusterScoped := []streamObject{}
+	for _, r := range resources {
+		if r.objects == nil || r.APIResource.Kind == "" {
+			continue
+		}
+		for _, obj := range r.objects.Items {
+			if obj.GetNamespace() == "" {
+				clusterScoped = append(clusterScoped, streamObject{resource: r, obj: obj})
+			} else {
+				namespaced = append(namespaced, streamObject{resource: r, obj: obj})
+			}
+		}
+	}
+
+	// the Namespace leads the namespace file so that it is created first
+	if w.namespace != nil {
+		namespaced = append(namespaced, streamObject{resource: w.namespace, obj: w.namespace.objects.Items[0]})
+	}
+
+	errs := []error{}
+	if len(namespaced) > 0 {
+		errs = append(errs, w.writeStream(w.singleFile, namespaced)...)
+	}
+	if len(clusterScoped) > 0 {
+		errs = append(errs, w.writeStream(w.clusterSingleFile, clusterScoped)...)
+	}
+	return errs
+}
+
+func (w *resourceWriter) writeStream(path string, objects []streamObject) []error {
+	errs := []error{}
+	w.log.Infof("Writing %d objects to %s\n", len(objects), path)
+	sortForApply(objects)
+
+	buf := &bytes.Buffer{}
+	for i, o := range objects {
+		objBytes, err := marshalObject(o.obj, OutputYAML)
+		if err != nil {
+			errs = append(errs, &objectWriteError{resource: o.resource, name: o.obj.GetName(), category: failureSerialization, err: err})
+			continue
+		}
+		// the stream is cut at the first object over the budget, it is counted uncompressed
+		if !w.budget.allow(1, len("---\n")+len(objBytes)) {
+			w.refuse(len(objects) - i)
+			break
+		}
+		buf.WriteString("---\n")
+		buf.Write(objBytes)
+		// the objects share the file, each is counted for its uncompressed size
+		w.metrics.addBytes(o.resource, len("---\n")+len(objBytes))
+	}
+
+	data := buf.Bytes()
+	if w.compress {
+		var err error
+		if data, err = gzipBytes(data); err != nil {
+			return append(errs, err)
+		}
+		path += gzipExtension
+	}
+	if w.index.unchanged(path, data) {
+		w.index.add(path, data, nil)
+		return errs
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return append(errs, err)
+	}
+	if err := w.writeFile(path, data); err != nil {
+		return append(errs, err)
+	}
+	w.index.add(path, data, nil)
+	return errs
+}
@@ -0,0 +1,83 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+func Test_resourceWriter_writeSingle(t *testing.T) {
+	object := func(kind string, namespace string, name string) unstructured.Unstructured {
+		obj := testOwnedObject(kind, name)
+		obj.SetNamespace(namespace)
+		return obj
+	}
+	resource := func(name string, kind string, objects ...unstructured.Unstructured) *groupResource {
+		return &groupResource{APIResource: metav1.APIResource{Name: name, Kind: kind}, objects: &unstructured.UnstructuredList{Items: objects}}
+	}
+	resources := []*groupResource{
+		resource("widgets", "Widget", object("Widget", "foo", "w1")),
+		resource("deployments", "Deployment", object("Deployment", "foo", "web"), object("Deployment", "foo", "api")),
+		resource("configmaps", "ConfigMap", object("ConfigMap", "foo", "web-config")),
+		resource("secrets", "Secret", object("Secret", "foo", "web-tls")),
+		resource("rolebindings", "RoleBinding", object("RoleBinding", "foo", "view")),
+		resource("serviceaccounts", "ServiceAccount", object("ServiceAccount", "foo", "web")),
+		resource("clusterroles", "ClusterRole", object("ClusterRole", "", "web-reader")),
+	}
+
+	namespace := testOwnedObject("Namespace", "foo")
+	dir := t.TempDir()
+	w := &resourceWriter{
+		namespace:         resource("namespaces", "Namespace", namespace),
+		layout:            LayoutSingle,
+		singleFile:        filepath.Join(dir, "resources", "foo.yaml"),
+		clusterSingleFile: filepath.Join(dir, "resources", "foo-cluster.yaml"),
+		log:               testLogger(),
+	}
+	if errs := w.writeResources(resources); len(errs) > 0 {
+		t.Fatalf("writeResources() errors = %v", errs)
+	}
+
+	documents := func(path string) []string {
+		stream, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, doc := range strings.Split(string(stream), "---\n") {
+			if strings.TrimSpace(doc) == "" {
+				continue
+			}
+			obj := map[string]interface{}{}
+			if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+				t.Fatalf("document does not parse: %v\n%s", err, doc)
+			}
+			u := unstructured.Unstructured{Object: obj}
+			got = append(got, u.GetKind()+"/"+u.GetName())
+		}
+		return got
+	}
+
+	want := []string{
+		"Namespace/foo",
+		"RoleBinding/view",
+		"ServiceAccount/web",
+		"ConfigMap/web-config",
+		"Secret/web-tls",
+		"Deployment/api",
+		"Deployment/web",
+		"Widget/w1",
+	}
+	if got := documents(w.singleFile); !reflect.DeepEqual(got, want) {
+		t.Errorf("%s documents = %v, want %v", w.singleFile, got, want)
+	}
+	if got := documents(w.clusterSingleFile); !reflect.DeepEqual(got, []string{"ClusterRole/web-reader"}) {
+		t.Errorf("%s documents = %v, want the ClusterRole", w.clusterSingleFile, got)
+	}
+}
@@ -0,0 +1,104 @@
+package exporter
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// storageClassWildcard maps the storage classes without a mapping of their own, and the claims
+// using the default class implicitly
+const storageClassWildcard = "*"
+
+// StorageClassMap rewrites the storage classes of the PersistentVolumeClaims and of the
+// volumeClaimTemplates of the StatefulSets with --storageclass-map. A nil map leaves them
+// unchanged.
+type StorageClassMap map[string]string
+
+// ParseStorageClassMap parses the --storageclass-map values, e.g. gp2=standard-rwo or *=standard
+func ParseStorageClassMap(values []string) (StorageClassMap, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	m := StorageClassMap{}
+	for _, value := range values {
+		source, target, ok := strings.Cut(value, "=")
+		source, target = strings.TrimSpace(source), strings.TrimSpace(target)
+		if !ok || source == "" || target == "" {
+			return nil, fmt.Errorf("invalid --storageclass-map %q, must be source=target or *=target", value)
+		}
+		if previous, ok := m[source]; ok && previous != target {
+			return nil, fmt.Errorf("invalid --storageclass-map %q, %s is already mapped to %s", value, source, previous)
+		}
+		m[source] = target
+	}
+	return m, nil
+}
+
+// Target returns the storage class to use instead of the class, the wildcard one when it has no
+// mapping. An empty class is the default class, used implicitly.
+func (m StorageClassMap) Target(class string) (string, bool) {
+	if target, ok := m[class]; ok {
+		return target, true
+	}
+	target, ok := m[storageClassWildcard]
+	return target, ok
+}
+
+// rewrite rewrites the storage classes of the resources in place. It returns the number of
+// claims rewritten and the claims whose storage class has no mapping, e.g.
+// StatefulSet/db volumeClaimTemplates data: fast-ssd.
+func (m StorageClassMap) rewrite(resources []*groupResource) (int, []string) {
+	if m == nil {
+		return 0, nil
+	}
+	rewritten := 0
+	unmapped := []string{}
+	rewriteClaim := func(spec map[string]interface{}, claim string) {
+		class, found := spec["storageClassName"].(string)
+		// an empty storageClassName asks for no class at all, to bind a PersistentVolume without one
+		if found && class == "" {
+			return
+		}
+		target, ok := m.Target(class)
+		switch {
+		case !ok && found:
+			unmapped = append(unmapped, claim+": "+class)
+		case ok && target != class:
+			spec["storageClassName"] = target
+			rewritten++
+		}
+	}
+
+	for _, r := range resources {
+		if r.objects == nil || (r.APIGroup != "" && r.APIGroup != "apps") {
+			continue
+		}
+		for _, obj := range r.objects.Items {
+			switch obj.GetKind() {
+			case "PersistentVolumeClaim":
+				spec, ok := obj.Object["spec"].(map[string]interface{})
+				if ok {
+					rewriteClaim(spec, "PersistentVolumeClaim/"+obj.GetName())
+				}
+			case "StatefulSet":
+				templates, _, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", "volumeClaimTemplates")
+				list, _ := templates.([]interface{})
+				for _, t := range list {
+					template, ok := t.(map[string]interface{})
+					if !ok {
+						continue
+					}
+					spec, ok := template["spec"].(map[string]interface{})
+					if !ok {
+						continue
+					}
+					name, _, _ := unstructured.NestedString(template, "metadata", "name")
+					rewriteClaim(spec, "StatefulSet/"+obj.GetName()+" volumeClaimTemplates "+name)
+				}
+			}
+		}
+	}
+	return rewritten, unmapped
+}
@@ -0,0 +1,135 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_parseStorageClassMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    StorageClassMap
+		wantErr bool
+	}{
+		{name: "given no mapping, should return nil"},
+		{
+			name:   "given mappings and a wildcard, should return them",
+			values: []string{"gp2=standard-rwo", "*=standard"},
+			want:   StorageClassMap{"gp2": "standard-rwo", "*": "standard"},
+		},
+		{name: "given no target, should fail", values: []string{"gp2="}, wantErr: true},
+		{name: "given no separator, should fail", values: []string{"gp2"}, wantErr: true},
+		{name: "given a class mapped twice, should fail", values: []string{"gp2=a", "gp2=b"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStorageClassMap(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseStorageClassMap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseStorageClassMap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_storageClassMap_rewrite(t *testing.T) {
+	claimSpec := func(class interface{}) map[string]interface{} {
+		spec := map[string]interface{}{"accessModes": []interface{}{"ReadWriteOnce"}}
+		if class != nil {
+			spec["storageClassName"] = class
+		}
+		return spec
+	}
+	claim := func(name string, class interface{}) unstructured.Unstructured {
+		obj := testOwnedObject("PersistentVolumeClaim", name)
+		obj.Object["spec"] = claimSpec(class)
+		return obj
+	}
+	statefulSet := func(name string, classes ...interface{}) unstructured.Unstructured {
+		obj := testOwnedObject("StatefulSet", name)
+		templates := []interface{}{}
+		for i, class := range classes {
+			templates = append(templates, map[string]interface{}{
+				"metadata": map[string]interface{}{"name": []string{"data", "logs"}[i]},
+				"spec":     claimSpec(class),
+			})
+		}
+		obj.Object["spec"] = map[string]interface{}{"volumeClaimTemplates": templates}
+		return obj
+	}
+	resources := func() []*groupResource {
+		return []*groupResource{
+			{APIGroup: "", objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+				claim("mapped", "gp2"),
+				claim("other", "fast-ssd"),
+				claim("implicit-default", nil),
+				claim("no-class", ""),
+			}}},
+			{APIGroup: "apps", objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+				statefulSet("db", "gp2", "fast-ssd"),
+				statefulSet("cache", nil),
+			}}},
+		}
+	}
+	classes := func(resources []*groupResource) map[string]interface{} {
+		got := map[string]interface{}{}
+		for _, obj := range resources[0].objects.Items {
+			got[obj.GetName()], _, _ = unstructured.NestedFieldNoCopy(obj.Object, "spec", "storageClassName")
+		}
+		for _, obj := range resources[1].objects.Items {
+			templates, _, _ := unstructured.NestedSlice(obj.Object, "spec", "volumeClaimTemplates")
+			for _, t := range templates {
+				name, _, _ := unstructured.NestedString(t.(map[string]interface{}), "metadata", "name")
+				got[obj.GetName()+"/"+name], _, _ = unstructured.NestedFieldNoCopy(t.(map[string]interface{}), "spec", "storageClassName")
+			}
+		}
+		return got
+	}
+
+	tests := []struct {
+		name          string
+		values        []string
+		want          map[string]interface{}
+		wantRewritten int
+		wantUnmapped  []string
+	}{
+		{
+			name:          "given a mapping, should rewrite the claims and the volumeClaimTemplates of the class",
+			values:        []string{"gp2=standard-rwo"},
+			want:          map[string]interface{}{"mapped": "standard-rwo", "other": "fast-ssd", "implicit-default": nil, "no-class": "", "db/data": "standard-rwo", "db/logs": "fast-ssd", "cache/data": nil},
+			wantRewritten: 2,
+			wantUnmapped:  []string{"PersistentVolumeClaim/other: fast-ssd", "StatefulSet/db volumeClaimTemplates logs: fast-ssd"},
+		},
+		{
+			name:          "given a wildcard, should rewrite the unmapped classes and the implicit default class",
+			values:        []string{"gp2=standard-rwo", "*=standard"},
+			want:          map[string]interface{}{"mapped": "standard-rwo", "other": "standard", "implicit-default": "standard", "no-class": "", "db/data": "standard-rwo", "db/logs": "standard", "cache/data": "standard"},
+			wantRewritten: 6,
+			wantUnmapped:  []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseStorageClassMap(tt.values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resources := resources()
+			rewritten, unmapped := m.rewrite(resources)
+			if rewritten != tt.wantRewritten {
+				t.Errorf("rewrite() rewritten = %d, want %d", rewritten, tt.wantRewritten)
+			}
+			if !reflect.DeepEqual(unmapped, tt.wantUnmapped) {
+				t.Errorf("rewrite() unmapped = %v, want %v", unmapped, tt.wantUnmapped)
+			}
+			if got := classes(resources); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("storage classes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
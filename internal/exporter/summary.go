@@ -0,0 +1,449 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// exportSummary collects what an export run produced so it can be reported at the end of the run
+type exportSummary struct {
+	Namespace string `json:"namespace"`
+	// NamespaceSynthesized is set when the Namespace object could not be read and a manifest
+	// without its labels and annotations was written instead
+	NamespaceSynthesized bool           `json:"namespaceSynthesized,omitempty"`
+	Resources            map[string]int `json:"resources"`
+	Excluded             []string       `json:"excluded,omitempty"`
+	Failures             int            `json:"failures"`
+	// Encrypted lists the files encrypted with age, relative to the export directory
+	Encrypted []string `json:"encrypted,omitempty"`
+	// Skipped counts the objects left out of the export by reason and kind
+	Skipped map[string]map[string]int `json:"skipped,omitempty"`
+	// ClusterDependencies lists the references to cluster-scoped objects followed with
+	// --include-cluster-deps
+	ClusterDependencies []clusterReference `json:"clusterDependencies,omitempty"`
+	// Webhooks lists the exported webhook configurations as Kind/name, their CA bundles may need
+	// to be regenerated on the target cluster
+	Webhooks []string `json:"webhooks,omitempty"`
+	// Events is the number of Events exported with --include-events
+	Events int `json:"events,omitempty"`
+	// Workloads is the number of scalable objects exported, listed in workloads.json
+	Workloads int `json:"scalableWorkloads,omitempty"`
+	// RewrittenImages is the number of container images repointed by --image-map, listed in
+	// image-rewrites.json
+	RewrittenImages int `json:"rewrittenImages,omitempty"`
+	// StorageClasses is the number of claims whose storage class was rewritten by
+	// --storageclass-map, UnmappedStorageClasses the claims whose storage class has no mapping
+	StorageClasses         int      `json:"rewrittenStorageClasses,omitempty"`
+	UnmappedStorageClasses []string `json:"unmappedStorageClasses,omitempty"`
+	// NetworkPolicyCIDRs lists the ipBlocks of the NetworkPolicies within the pod or service
+	// network of the source cluster, which will likely be wrong on the target cluster unless
+	// rewritten
+	NetworkPolicyCIDRs []cidrWarning `json:"networkPolicyCIDRs,omitempty"`
+	// NamespaceWarnings is the number of fields still mentioning the namespace after
+	// --target-namespace, listed in namespace-warnings.json
+	NamespaceWarnings int `json:"namespaceWarnings,omitempty"`
+	// ResolvedImages lists the ImageStreamTag references of the pod templates replaced with the
+	// images they point to, when exporting from OpenShift
+	ResolvedImages []resolvedImage `json:"resolvedImages,omitempty"`
+	// Deprecated lists the exported objects stored at API versions deprecated in the target
+	// Kubernetes version
+	Deprecated []DeprecatedObject `json:"deprecatedAPIs,omitempty"`
+	// SkippedObjects lists the objects left out for the reasons worth naming them, like an
+	// exclude annotation, as Kind/name
+	SkippedObjects map[string][]string `json:"skippedObjects,omitempty"`
+	// Lists records the resourceVersion and the time each resource was listed, the resources
+	// being listed one after the other, Drift the objects that changed after their resource was
+	// listed, found with --quiesce-check
+	Lists []listRecord    `json:"lists,omitempty"`
+	Drift []driftedObject `json:"drift,omitempty"`
+	// Retried counts the objects per resource exported by a --retry-failures pass, they are
+	// counted in Resources too
+	Retried map[string]int `json:"retried,omitempty"`
+	// Dangling lists the references of the exported objects to objects of the namespace that
+	// are not exported, e.g. a mounted Secret that does not exist. Every reference is in graph.json.
+	Dangling []GraphEdge `json:"danglingReferences,omitempty"`
+	// UnresolvedWorkloadReferences lists the HorizontalPodAutoscalers and PodDisruptionBudgets
+	// whose scaleTargetRef or selector matches no exported workload
+	UnresolvedWorkloadReferences []string `json:"unresolvedWorkloadReferences,omitempty"`
+	// OverBudget counts the objects listed but not written once --max-resources or --max-bytes
+	// was reached, they are counted in Resources too
+	OverBudget int `json:"overBudget,omitempty"`
+}
+
+func newExportSummary(namespace string) *exportSummary {
+	return &exportSummary{
+		Namespace: namespace,
+		Resources: map[string]int{},
+		Skipped:   map[string]map[string]int{},
+	}
+}
+
+func (s *exportSummary) addSkipped(reason string, obj unstructured.Unstructured, recordName bool) {
+	if s.Skipped[reason] == nil {
+		s.Skipped[reason] = map[string]int{}
+	}
+	s.Skipped[reason][obj.GetKind()]++
+	if recordName {
+		if s.SkippedObjects == nil {
+			s.SkippedObjects = map[string][]string{}
+		}
+		s.SkippedObjects[reason] = append(s.SkippedObjects[reason], obj.GetKind()+"/"+obj.GetName())
+	}
+}
+
+func (s *exportSummary) addResources(resources []*groupResource) {
+	for _, r := range resources {
+		if r.objects == nil || len(r.objects.Items) == 0 {
+			continue
+		}
+		s.Resources[GroupResourceName(r.APIGroup, r.APIResource.Name)] += len(r.objects.Items)
+	}
+}
+
+func (s *exportSummary) addWebhooks(configs []*groupResource) {
+	for _, r := range configs {
+		for _, obj := range r.objects.Items {
+			s.Webhooks = append(s.Webhooks, obj.GetKind()+"/"+obj.GetName())
+		}
+	}
+}
+
+func (s *exportSummary) addEncrypted(exportDir string, paths []string) {
+	for _, path := range paths {
+		if rel, err := filepath.Rel(exportDir, path); err == nil {
+			path = filepath.ToSlash(rel)
+		}
+		s.Encrypted = append(s.Encrypted, path)
+	}
+	sort.Strings(s.Encrypted)
+}
+
+func (s *exportSummary) log(log logrus.FieldLogger) {
+	names := sortedKeys(s.Resources)
+
+	log.Infof("Export summary for namespace %s: %d objects of %d resource kinds, %d failures", s.Namespace, s.Total(), len(names), s.Failures)
+	for _, name := range names {
+		log.Infof("  %s: %d", name, s.Resources[name])
+	}
+	if s.NamespaceSynthesized {
+		log.Infof("The Namespace could not be read, its manifest has no labels or annotations")
+	}
+	if s.Events > 0 {
+		log.Infof("Events: %d, written under %s", s.Events, EventsDir)
+	}
+	if len(s.Excluded) > 0 {
+		log.Infof("Excluded resources: %s", strings.Join(s.Excluded, ", "))
+	}
+	for _, reason := range sortedKeys(s.Skipped) {
+		kinds := make([]string, 0, len(s.Skipped[reason]))
+		for kind, count := range s.Skipped[reason] {
+			kinds = append(kinds, fmt.Sprintf("%s: %d", kind, count))
+		}
+		sort.Strings(kinds)
+		log.Infof("Skipped objects %s: %s", reason, strings.Join(kinds, ", "))
+		if names := s.SkippedObjects[reason]; len(names) > 0 {
+			log.Infof("  %s", strings.Join(names, ", "))
+		}
+	}
+	if len(s.Encrypted) > 0 {
+		log.Infof("Encrypted files: %s", strings.Join(s.Encrypted, ", "))
+	}
+	if len(s.Webhooks) > 0 {
+		log.Warnf("Exported webhook configurations %s keep their CA bundles, they may need to be regenerated on the target cluster", strings.Join(s.Webhooks, ", "))
+	}
+	for _, d := range s.Deprecated {
+		log.Warnf("Deprecated API version: %s", d)
+	}
+	for _, claim := range s.UnmappedStorageClasses {
+		log.Warnf("Storage class of %s matches no --storageclass-map, it is exported unchanged", claim)
+	}
+	for _, w := range s.NetworkPolicyCIDRs {
+		if w.RewrittenTo == "" {
+			log.Warnf("%s, it will likely be wrong on the target cluster, see --target-%s-cidr", w, w.Network)
+		}
+	}
+	for _, img := range s.ResolvedImages {
+		log.Infof("Resolved image %s of %s container %s to %s", img.From, img.Object, img.Container, img.To)
+	}
+	for _, ref := range s.ClusterDependencies {
+		if !ref.Resolved {
+			log.Warnf("Unresolved cluster dependency %s/%s referenced by %s: %s", ref.Kind, ref.Name, ref.ReferencedBy, ref.Error)
+		}
+	}
+	for _, edge := range s.Dangling {
+		log.Warnf("Dangling reference: %s", edge)
+	}
+	for _, ref := range s.UnresolvedWorkloadReferences {
+		log.Warnf("Unresolved workload reference: %s", ref)
+	}
+}
+
+// logNamespaceTotals reports the number of exported objects per namespace of a multi-namespace run
+func logNamespaceTotals(summaries []*exportSummary, log logrus.FieldLogger) {
+	log.Infof("Exported objects per namespace:")
+	for _, s := range summaries {
+		log.Infof("  %s: %d objects, %d failures", s.Namespace, s.Total(), s.Failures)
+	}
+}
+
+func (s *exportSummary) Total() int {
+	total := 0
+	for _, count := range s.Resources {
+		total += count
+	}
+	return total
+}
+
+const (
+	SummaryJSONFile = "export-summary.json"
+	summaryTextFile = "export-summary.txt"
+)
+
+// RunSummary describes a whole export run. It is written at the root of the export directory so
+// that pipelines can inspect an export without parsing the logs
+type RunSummary struct {
+	StartTime     time.Time         `json:"startTime"`
+	Duration      string            `json:"duration"`
+	ExportDir     string            `json:"exportDir,omitempty"`
+	ServerVersion string            `json:"serverVersion,omitempty"`
+	Flags         map[string]string `json:"flags"`
+	Resources     map[string]int    `json:"resources"`
+	Failures      int               `json:"failures"`
+	// Interrupted is set when the export was stopped by a signal, NotExported lists the
+	// namespaces it did not get to
+	Interrupted bool             `json:"interrupted,omitempty"`
+	NotExported []string         `json:"notExported,omitempty"`
+	Namespaces  []*exportSummary `json:"namespaces"`
+	// BudgetExceeded is the limit that stopped the export, --max-resources or --max-bytes
+	BudgetExceeded string `json:"budgetExceeded,omitempty"`
+	// Created is the time window of --created-after and --created-before, with the objects it
+	// filtered out
+	Created *createdWindow `json:"created,omitempty"`
+	// Incremental counts the files compared to the previous export with --incremental
+	Incremental *incrementalChanges `json:"incremental,omitempty"`
+	// ExternalReferences are the references of the exported objects to objects of other
+	// namespaces or cluster-scoped that are not exported
+	ExternalReferences []GraphEdge `json:"externalReferences,omitempty"`
+	// SlowestResources are the resources that took the longest to list, with their pages,
+	// objects and bytes written
+	SlowestResources []resourceMetrics `json:"slowestResources,omitempty"`
+	// Watch counts the changes written by --watch after the export, the other counts are those of
+	// the export
+	Watch *watchChanges `json:"watch,omitempty"`
+}
+
+func newRunSummary(start time.Time, serverVersion string, flags map[string]string, summaries []*exportSummary) *RunSummary {
+	s := &RunSummary{
+		StartTime:     start.UTC(),
+		Duration:      time.Since(start).Round(time.Millisecond).String(),
+		ServerVersion: serverVersion,
+		Flags:         flags,
+		Resources:     map[string]int{},
+		Namespaces:    summaries,
+	}
+	for _, ns := range summaries {
+		for name, count := range ns.Resources {
+			s.Resources[name] += count
+		}
+		s.Failures += ns.Failures
+	}
+	return s
+}
+
+// write writes the summary as export-summary.json and export-summary.txt in exportDir
+func (s *RunSummary) write(exportDir string) error {
+	if err := os.MkdirAll(exportDir, 0700); err != nil {
+		return err
+	}
+	jsonBytes, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(exportDir, SummaryJSONFile), append(jsonBytes, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(exportDir, summaryTextFile), []byte(s.text()), 0600)
+}
+
+// ReadRunSummary reads the export-summary.json of the export in exportDir
+func ReadRunSummary(exportDir string) (*RunSummary, error) {
+	summaryBytes, err := os.ReadFile(filepath.Join(exportDir, SummaryJSONFile))
+	if err != nil {
+		return nil, err
+	}
+	s := &RunSummary{}
+	if err := json.Unmarshal(summaryBytes, s); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", SummaryJSONFile, err)
+	}
+	return s, nil
+}
+
+// SelectNamespaces returns the exported namespaces given, every exported namespace when none is
+func (s *RunSummary) SelectNamespaces(namespaces []string) ([]string, error) {
+	exported := []string{}
+	for _, n := range s.Namespaces {
+		exported = append(exported, n.Namespace)
+	}
+	if len(namespaces) == 0 {
+		return exported, nil
+	}
+	namespaces = UniqueNamespaces(namespaces)
+	for _, namespace := range namespaces {
+		found := false
+		for _, e := range exported {
+			found = found || e == namespace
+		}
+		if !found {
+			return nil, fmt.Errorf("namespace %s is not in the export, it has: %s", namespace, strings.Join(exported, ", "))
+		}
+	}
+	return namespaces, nil
+}
+
+func (s *RunSummary) text() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "Export started at %s and took %s\n", s.StartTime.Format(time.RFC3339), s.Duration)
+	if s.ExportDir != "" {
+		fmt.Fprintf(b, "Exported to: %s\n", s.ExportDir)
+	}
+	if s.ServerVersion != "" {
+		fmt.Fprintf(b, "Server version: %s\n", s.ServerVersion)
+	}
+	if s.Interrupted {
+		fmt.Fprintf(b, "Interrupted, the export is incomplete\n")
+		if len(s.NotExported) > 0 {
+			fmt.Fprintf(b, "Namespaces not exported: %s\n", strings.Join(s.NotExported, ", "))
+		}
+	}
+	if s.BudgetExceeded != "" {
+		fmt.Fprintf(b, "Budget exceeded, %s reached, the export is incomplete\n", s.BudgetExceeded)
+		if len(s.NotExported) > 0 && !s.Interrupted {
+			fmt.Fprintf(b, "Namespaces not exported: %s\n", strings.Join(s.NotExported, ", "))
+		}
+	}
+	if w := s.Created; w != nil {
+		fmt.Fprintf(b, "Objects created %s, %d filtered out\n", w, w.Filtered)
+	}
+	if c := s.Incremental; c != nil {
+		fmt.Fprintf(b, "Files: %d added, %d changed, %d removed, %d unchanged\n", c.Added, c.Changed, c.Removed, c.Unchanged)
+		if c.Stale > 0 {
+			fmt.Fprintf(b, "Files of objects no longer exported kept: %d\n", c.Stale)
+		}
+	}
+	if w := s.Watch; w != nil {
+		fmt.Fprintf(b, "Watched until %s: %d files written, %d removed, %d relists\n", w.Until.Format(time.RFC3339), w.Written, w.Removed, w.Relists)
+	}
+	if len(s.ExternalReferences) > 0 {
+		fmt.Fprintf(b, "\nWARNING: %d references to objects outside the export, they must exist on the target cluster:\n", len(s.ExternalReferences))
+		for _, edge := range s.ExternalReferences {
+			fmt.Fprintf(b, "  %s %s -> %s\n", edge.From.location(), edge.Field, edge.To.location())
+		}
+		fmt.Fprintf(b, "\n")
+	}
+	if len(s.Flags) > 0 {
+		fmt.Fprintf(b, "Flags:\n")
+		for _, name := range sortedKeys(s.Flags) {
+			fmt.Fprintf(b, "  --%s=%s\n", name, s.Flags[name])
+		}
+	}
+	for _, ns := range s.Namespaces {
+		fmt.Fprintf(b, "\nNamespace %s: %d objects, %d failures\n", ns.Namespace, ns.Total(), ns.Failures)
+		for _, name := range sortedKeys(ns.Resources) {
+			fmt.Fprintf(b, "  %s: %d\n", name, ns.Resources[name])
+		}
+		for _, name := range sortedKeys(ns.Retried) {
+			fmt.Fprintf(b, "  retried %s: %d\n", name, ns.Retried[name])
+		}
+		if len(ns.Lists) > 0 {
+			fmt.Fprintf(b, "  listed between %s and %s\n", ns.Lists[0].ListedAt.Format(time.RFC3339), ns.Lists[len(ns.Lists)-1].ListedAt.Format(time.RFC3339))
+		}
+		if ns.NamespaceSynthesized {
+			fmt.Fprintf(b, "  namespace manifest synthesized, the Namespace could not be read\n")
+		}
+		if ns.Events > 0 {
+			fmt.Fprintf(b, "  events: %d\n", ns.Events)
+		}
+		if ns.Workloads > 0 {
+			fmt.Fprintf(b, "  scalable workloads: %d, see %s\n", ns.Workloads, workloadsFile)
+		}
+		if len(ns.Excluded) > 0 {
+			fmt.Fprintf(b, "  excluded resources: %s\n", strings.Join(ns.Excluded, ", "))
+		}
+		for _, reason := range sortedKeys(ns.Skipped) {
+			for _, kind := range sortedKeys(ns.Skipped[reason]) {
+				fmt.Fprintf(b, "  skipped %s %s: %d\n", kind, reason, ns.Skipped[reason][kind])
+			}
+			for _, name := range ns.SkippedObjects[reason] {
+				fmt.Fprintf(b, "    %s\n", name)
+			}
+		}
+		for _, path := range ns.Encrypted {
+			fmt.Fprintf(b, "  encrypted: %s\n", path)
+		}
+		for _, webhook := range ns.Webhooks {
+			fmt.Fprintf(b, "  webhook configuration: %s (the CA bundle may need to be regenerated on the target cluster)\n", webhook)
+		}
+		for _, d := range ns.Deprecated {
+			fmt.Fprintf(b, "  deprecated API version: %s\n", d)
+		}
+		for _, d := range ns.Drift {
+			fmt.Fprintf(b, "  changed during the export: %s\n", d)
+		}
+		if ns.RewrittenImages > 0 {
+			fmt.Fprintf(b, "  rewritten images: %d, see %s\n", ns.RewrittenImages, ImageRewritesFile)
+		}
+		if ns.StorageClasses > 0 {
+			fmt.Fprintf(b, "  rewritten storage classes: %d\n", ns.StorageClasses)
+		}
+		if ns.NamespaceWarnings > 0 {
+			fmt.Fprintf(b, "  WARNING: %d fields still mention the namespace after --target-namespace, see %s\n", ns.NamespaceWarnings, namespaceWarningsFile)
+		}
+		for _, claim := range ns.UnmappedStorageClasses {
+			fmt.Fprintf(b, "  WARNING: storage class matching no --storageclass-map: %s\n", claim)
+		}
+		for _, w := range ns.NetworkPolicyCIDRs {
+			if w.RewrittenTo != "" {
+				fmt.Fprintf(b, "  network policy CIDR: %s\n", w)
+				continue
+			}
+			fmt.Fprintf(b, "  WARNING: network policy CIDR: %s\n", w)
+		}
+		for _, img := range ns.ResolvedImages {
+			fmt.Fprintf(b, "  resolved image of %s container %s: %s -> %s\n", img.Object, img.Container, img.From, img.To)
+		}
+		for _, ref := range ns.ClusterDependencies {
+			status := "resolved"
+			if ref.ExportedWith != "" {
+				status = "exported with namespace " + ref.ExportedWith
+			}
+			if !ref.Resolved {
+				status = "unresolved: " + ref.Error
+			}
+			fmt.Fprintf(b, "  cluster dependency %s/%s of %s: %s\n", ref.Kind, ref.Name, ref.ReferencedBy, status)
+		}
+		for _, edge := range ns.Dangling {
+			fmt.Fprintf(b, "  dangling reference: %s\n", edge)
+		}
+		for _, ref := range ns.UnresolvedWorkloadReferences {
+			fmt.Fprintf(b, "  WARNING: unresolved workload reference: %s\n", ref)
+		}
+	}
+	return b.String()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,107 @@
+package exporter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_runSummary_write(t *testing.T) {
+	foo := newExportSummary("foo")
+	foo.Resources["deployments.apps"] = 1
+	foo.Resources["configmaps"] = 2
+	foo.Failures = 1
+	foo.addSkipped("owned by a controller", testOwnedObject("ReplicaSet", "web-abc12"), false)
+	bar := newExportSummary("bar")
+	bar.Resources["configmaps"] = 3
+
+	dir := filepath.Join(t.TempDir(), "export")
+	s := newRunSummary(time.Now().Add(-time.Second), "v1.30.0", map[string]string{"namespace": "[foo,bar]"}, []*exportSummary{foo, bar})
+	if err := s.write(dir); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+
+	jsonBytes, err := os.ReadFile(filepath.Join(dir, SummaryJSONFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &RunSummary{}
+	if err := json.Unmarshal(jsonBytes, got); err != nil {
+		t.Fatalf("%s does not parse: %v", SummaryJSONFile, err)
+	}
+	if got.Resources["configmaps"] != 5 || got.Resources["deployments.apps"] != 1 {
+		t.Errorf("resources = %v, want configmaps: 5, deployments.apps: 1", got.Resources)
+	}
+	if got.Failures != 1 {
+		t.Errorf("failures = %d, want 1", got.Failures)
+	}
+	if got.ServerVersion != "v1.30.0" {
+		t.Errorf("serverVersion = %q, want v1.30.0", got.ServerVersion)
+	}
+	if got.Flags["namespace"] != "[foo,bar]" {
+		t.Errorf("flags = %v, want namespace: [foo,bar]", got.Flags)
+	}
+	if len(got.Namespaces) != 2 || got.Namespaces[0].Skipped["owned by a controller"]["ReplicaSet"] != 1 {
+		t.Errorf("namespaces = %+v, want foo with 1 skipped ReplicaSet and bar", got.Namespaces)
+	}
+	if d, err := time.ParseDuration(got.Duration); err != nil || d < time.Second {
+		t.Errorf("duration = %q, want at least 1s", got.Duration)
+	}
+
+	text, err := os.ReadFile(filepath.Join(dir, summaryTextFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Server version: v1.30.0", "--namespace=[foo,bar]", "Namespace foo: 3 objects, 1 failures", "deployments.apps: 1", "skipped ReplicaSet owned by a controller: 1"} {
+		if !strings.Contains(string(text), want) {
+			t.Errorf("%s should contain %q, got:\n%s", summaryTextFile, want, text)
+		}
+	}
+}
+
+func Test_runSummary_interrupted(t *testing.T) {
+	s := newRunSummary(time.Now(), "", nil, []*exportSummary{newExportSummary("foo")})
+	s.Interrupted = true
+	s.NotExported = []string{"bar", "baz"}
+	text := s.text()
+	for _, want := range []string{"Interrupted, the export is incomplete", "Namespaces not exported: bar, baz"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text() should contain %q, got:\n%s", want, text)
+		}
+	}
+}
+
+func Test_runSummary_externalReferences(t *testing.T) {
+	s := newRunSummary(time.Now(), "", nil, []*exportSummary{newExportSummary("foo")})
+	s.ExternalReferences = []GraphEdge{{
+		From:  graphNode{Kind: "RoleBinding", Namespace: "foo", Name: "reader"},
+		To:    graphNode{Kind: "ServiceAccount", Namespace: "external-secrets", Name: "operator"},
+		Field: "subjects.*",
+	}}
+	text := s.text()
+	for _, want := range []string{
+		"WARNING: 1 references to objects outside the export",
+		"RoleBinding/reader in namespace foo subjects.* -> ServiceAccount/operator in namespace external-secrets",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text() should contain %q, got:\n%s", want, text)
+		}
+	}
+}
+
+func Test_runSummary_selectNamespaces(t *testing.T) {
+	previous := newRunSummary(time.Now(), "", nil, []*exportSummary{newExportSummary("foo"), newExportSummary("bar")})
+	if got, err := previous.SelectNamespaces(nil); err != nil || !reflect.DeepEqual(got, []string{"foo", "bar"}) {
+		t.Errorf("selectNamespaces() = %v, %v, want every exported namespace", got, err)
+	}
+	if got, err := previous.SelectNamespaces([]string{"bar"}); err != nil || !reflect.DeepEqual(got, []string{"bar"}) {
+		t.Errorf("selectNamespaces(bar) = %v, %v, want bar", got, err)
+	}
+	if _, err := previous.SelectNamespaces([]string{"baz"}); err == nil || !strings.Contains(err.Error(), "not in the export") {
+		t.Errorf("selectNamespaces(baz) error = %v, want baz not in the export", err)
+	}
+}
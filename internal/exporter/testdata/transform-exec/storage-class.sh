@@ -0,0 +1,14 @@
+#!/bin/sh
+# Sample --transform-exec executable: the exported object is read as JSON on stdin and the object
+# to export is written as JSON on stdout, a non-zero exit code drops the object.
+#
+# It drops the objects annotated with example.com/scratch and moves the PersistentVolumeClaims of
+# the standard storage class to the gp3 one. Real transformers would rather use jq or a program
+# parsing the JSON, the objects are passed as compact JSON which keeps this sample to sed.
+set -eu
+
+object=$(cat)
+case "$object" in
+*'"example.com/scratch":"true"'*) exit 1 ;;
+esac
+printf '%s\n' "$object" | sed 's/"storageClassName":"standard"/"storageClassName":"gp3"/'
@@ -0,0 +1,144 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// transformDropped is the reason of the objects dropped by --transform-exec in the summary
+const transformDropped = "dropped by --transform-exec"
+
+// execTransformer runs the --transform-exec executable on every exported object: it reads the
+// object as JSON on stdin and writes the object to export as JSON on stdout, a non-zero exit
+// code drops the object. A nil transformer leaves the objects unchanged.
+type execTransformer struct {
+	path    string
+	timeout time.Duration
+	workers int
+}
+
+// newExecTransformer checks the executable and returns its transformer, nil without one
+func newExecTransformer(path string, timeout time.Duration, workers int) (*execTransformer, error) {
+	if path == "" {
+		return nil, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --transform-exec: %w", err)
+	}
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		return nil, fmt.Errorf("invalid --transform-exec %s, must be an executable file", path)
+	}
+	return &execTransformer{path: path, timeout: timeout, workers: workers}, nil
+}
+
+// errTransformDropped is returned by transform when the executable exits with a non-zero code
+var errTransformDropped = errors.New("dropped")
+
+// transformResult is the outcome of the transformation of one object
+type transformResult struct {
+	obj unstructured.Unstructured
+	err error
+}
+
+// apply transforms the objects of the resources in place, at most workers at a time. The dropped
+// objects are counted in the summary, the objects that failed to be transformed are left out of
+// the export and returned as failures.
+func (t *execTransformer) apply(ctx context.Context, resources []*groupResource, summary *exportSummary, log logrus.FieldLogger) []FailureRecord {
+	if t == nil {
+		return nil
+	}
+	failures := []FailureRecord{}
+	for _, r := range resources {
+		if r.objects == nil || len(r.objects.Items) == 0 {
+			continue
+		}
+		items := r.objects.Items
+		results := make([]transformResult, len(items))
+		skipped := RunWorkers(ctx, t.workers, len(items), func(i int) {
+			results[i].obj, results[i].err = t.transform(ctx, items[i])
+		})
+		for _, i := range skipped {
+			results[i].err = ctx.Err()
+		}
+
+		kept := []unstructured.Unstructured{}
+		for i, result := range results {
+			switch {
+			case errors.Is(result.err, errTransformDropped):
+				log.Debugf("%s %s %s", items[i].GetKind(), items[i].GetName(), transformDropped)
+				summary.addSkipped(transformDropped, items[i], true)
+			case result.err != nil:
+				log.WithError(result.err).Warnf("cannot transform %s %s, it is not exported", items[i].GetKind(), items[i].GetName())
+				failures = append(failures, transformFailureRecord(r, items[i].GetName(), result.err))
+			default:
+				kept = append(kept, result.obj)
+			}
+		}
+		r.objects.Items = kept
+	}
+	return failures
+}
+
+// transform runs the executable on the object within the timeout
+func (t *execTransformer) transform(ctx context.Context, obj unstructured.Unstructured) (unstructured.Unstructured, error) {
+	in, err := obj.MarshalJSON()
+	if err != nil {
+		return obj, err
+	}
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, t.path)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// the children of a killed script may keep its output open
+	cmd.WaitDelay = time.Second
+	err = cmd.Run()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return obj, fmt.Errorf("%s did not complete: %w", t.path, ctxErr)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return obj, errTransformDropped
+	}
+	if err != nil {
+		return obj, err
+	}
+
+	transformed := unstructured.Unstructured{}
+	if err := transformed.UnmarshalJSON(stdout.Bytes()); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return obj, fmt.Errorf("invalid output of %s: %w: %s", t.path, err, msg)
+		}
+		return obj, fmt.Errorf("invalid output of %s: %w", t.path, err)
+	}
+	return transformed, nil
+}
+
+func transformFailureRecord(r *groupResource, name string, err error) FailureRecord {
+	code, category := classifyError(err)
+	return FailureRecord{
+		Operation:  "transform",
+		Group:      r.APIGroup,
+		Version:    r.APIVersion,
+		Resource:   r.APIResource.Name,
+		Name:       name,
+		Error:      err.Error(),
+		StatusCode: code,
+		Category:   category,
+	}
+}
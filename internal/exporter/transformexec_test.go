@@ -0,0 +1,161 @@
+package exporter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// testTransformer writes a shell transformer to a temporary directory
+func testTransformer(t *testing.T, script string) string {
+	path := filepath.Join(t.TempDir(), "transform.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_newExecTransformer(t *testing.T) {
+	notExecutable := filepath.Join(t.TempDir(), "transform.sh")
+	if err := os.WriteFile(notExecutable, []byte("#!/bin/sh\ncat\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		path    string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "given no executable, should return no transformer", wantNil: true},
+		{name: "given an executable, should return its transformer", path: "testdata/transform-exec/storage-class.sh"},
+		{name: "given a missing file, should fail", path: "testdata/transform-exec/missing.sh", wantErr: true},
+		{name: "given a file that is not executable, should fail", path: notExecutable, wantErr: true},
+		{name: "given a directory, should fail", path: "testdata", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newExecTransformer(tt.path, time.Second, 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newExecTransformer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (got == nil) != tt.wantNil {
+				t.Errorf("newExecTransformer() = %v, want nil %v", got, tt.wantNil)
+			}
+		})
+	}
+}
+
+func Test_execTransformer_apply(t *testing.T) {
+	claim := func(name string, storageClass string, annotations map[string]string) unstructured.Unstructured {
+		obj := testOwnedObject("PersistentVolumeClaim", name)
+		obj.SetAnnotations(annotations)
+		if err := unstructured.SetNestedField(obj.Object, storageClass, "spec", "storageClassName"); err != nil {
+			t.Fatal(err)
+		}
+		return obj
+	}
+	resources := func() []*groupResource {
+		return []*groupResource{{
+			APIVersion:  "v1",
+			APIResource: metav1.APIResource{Name: "persistentvolumeclaims", Kind: "PersistentVolumeClaim"},
+			objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+				claim("data", "standard", nil),
+				claim("fast", "premium", nil),
+				claim("scratch", "standard", map[string]string{"example.com/scratch": "true"}),
+			}},
+		}}
+	}
+	storageClasses := func(resources []*groupResource) map[string]string {
+		classes := map[string]string{}
+		for _, obj := range resources[0].objects.Items {
+			classes[obj.GetName()], _, _ = unstructured.NestedString(obj.Object, "spec", "storageClassName")
+		}
+		return classes
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		timeout      time.Duration
+		want         map[string]string
+		wantDropped  []string
+		wantFailures map[string]string
+	}{
+		{
+			name:        "given the sample transformer, should swap the storage class and drop the scratch claim",
+			path:        "testdata/transform-exec/storage-class.sh",
+			want:        map[string]string{"data": "gp3", "fast": "premium"},
+			wantDropped: []string{"PersistentVolumeClaim/scratch"},
+		},
+		{
+			name: "given a transformer writing invalid JSON, should record every object as failed",
+			path: testTransformer(t, "cat >/dev/null; echo not-json"),
+			want: map[string]string{},
+			wantFailures: map[string]string{
+				"data": failureOther, "fast": failureOther, "scratch": failureOther,
+			},
+		},
+		{
+			name:    "given a transformer exceeding the timeout, should record the object as timed out",
+			path:    testTransformer(t, `obj=$(cat); case "$obj" in *'"name":"fast"'*) sleep 5 ;; esac; printf '%s' "$obj"`),
+			timeout: 200 * time.Millisecond,
+			want:    map[string]string{"data": "standard", "scratch": "standard"},
+			wantFailures: map[string]string{
+				"fast": failureTimedOut,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeout := tt.timeout
+			if timeout == 0 {
+				timeout = 10 * time.Second
+			}
+			transformer, err := newExecTransformer(tt.path, timeout, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resources := resources()
+			summary := newExportSummary("foo")
+
+			failures := transformer.apply(context.Background(), resources, summary, testLogger())
+
+			if got := storageClasses(resources); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("storage classes = %v, want %v", got, tt.want)
+			}
+			if got := summary.SkippedObjects[transformDropped]; !reflect.DeepEqual(got, tt.wantDropped) {
+				t.Errorf("dropped = %v, want %v", got, tt.wantDropped)
+			}
+			gotFailures := map[string]string{}
+			for _, f := range failures {
+				if f.Operation != "transform" || f.Resource != "persistentvolumeclaims" {
+					t.Errorf("failure = %+v, want a transform failure of persistentvolumeclaims", f)
+				}
+				gotFailures[f.Name] = f.Category
+			}
+			if len(tt.wantFailures) == 0 {
+				tt.wantFailures = map[string]string{}
+			}
+			if !reflect.DeepEqual(gotFailures, tt.wantFailures) {
+				t.Errorf("failures = %v, want %v", gotFailures, tt.wantFailures)
+			}
+		})
+	}
+}
+
+func Test_execTransformer_nil(t *testing.T) {
+	var transformer *execTransformer
+	resources := []*groupResource{{objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{testOwnedObject("ConfigMap", "web")}}}}
+	if failures := transformer.apply(context.Background(), resources, newExportSummary("foo"), testLogger()); failures != nil {
+		t.Errorf("apply() = %v, want nil", failures)
+	}
+	if len(resources[0].objects.Items) != 1 {
+		t.Errorf("apply() should leave the objects unchanged, got %v", resources[0].objects.Items)
+	}
+}
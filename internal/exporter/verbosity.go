@@ -0,0 +1,126 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// verbosityQuiet only logs the errors and the final summary line
+	verbosityQuiet = 0
+	// verbosityDefault logs the progress of the export per namespace
+	verbosityDefault = 1
+	// verbosityResources logs each resource as it is listed and written
+	verbosityResources = 2
+	// verbosityObjects logs every object written
+	verbosityObjects = 3
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// The fields of the structured logs, the errors are logged with logrus.ErrorKey
+const (
+	logFieldGVR       = "gvr"
+	logFieldNamespace = "namespace"
+	logFieldObject    = "object"
+	logFieldAction    = "action"
+)
+
+// The actions of the structured logs of a resource
+const (
+	actionListed  = "listed"
+	actionWriting = "writing"
+	actionWritten = "written"
+	actionRemoved = "removed"
+)
+
+// validateVerbosity checks --verbosity and --quiet, --quiet is an alias of --verbosity 0
+func (o *ExportOptions) validateVerbosity(verbosityChanged bool) error {
+	if o.verbosity < verbosityQuiet || o.verbosity > verbosityObjects {
+		return fmt.Errorf("invalid --verbosity %d, must be between %d and %d", o.verbosity, verbosityQuiet, verbosityObjects)
+	}
+	if o.quiet && verbosityChanged && o.verbosity != verbosityQuiet {
+		return fmt.Errorf("--quiet cannot be used with --verbosity %d", o.verbosity)
+	}
+	return nil
+}
+
+// logLevel returns the level of the export logs, --debug logs at least each resource
+func logLevel(verbosity int, debug bool) logrus.Level {
+	if debug && verbosity < verbosityResources {
+		verbosity = verbosityResources
+	}
+	switch verbosity {
+	case verbosityQuiet:
+		return logrus.ErrorLevel
+	case verbosityResources:
+		return logrus.DebugLevel
+	case verbosityObjects:
+		return logrus.TraceLevel
+	}
+	return logrus.InfoLevel
+}
+
+// newLogger returns the logger of the export, text or JSON lines with --log-format json. It writes
+// to stderr so that the output of the command on stdout, like the --dry-run listing, stays clean.
+func (o *ExportOptions) newLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(o.ErrOut)
+	verbosity := o.verbosity
+	if o.quiet {
+		verbosity = verbosityQuiet
+	}
+	log.SetLevel(logLevel(verbosity, o.globalFlags.Debug))
+	if o.logFormat == logFormatJSON {
+		log.SetFormatter(&logrus.JSONFormatter{
+			FieldMap: logrus.FieldMap{
+				logrus.FieldKeyTime: "timestamp",
+				logrus.FieldKeyMsg:  "message",
+			},
+		})
+	}
+	return log
+}
+
+// resourceFields are the fields of the structured logs of a resource, its gvr is the resource and
+// group of the resource, e.g. deployments.apps
+func resourceFields(r *groupResource) logrus.Fields {
+	return logrus.Fields{logFieldGVR: GroupResourceName(r.APIGroup, r.APIResource.Name)}
+}
+
+// quietRun reports whether only the errors and the final summary line are printed
+func (o *ExportOptions) quietRun() bool {
+	return (o.quiet || o.verbosity == verbosityQuiet) && !o.globalFlags.Debug
+}
+
+// printLine prints the final summary line of an export, printed whatever the verbosity
+func (s *RunSummary) printLine(out io.Writer) {
+	total := 0
+	for _, count := range s.Resources {
+		total += count
+	}
+	for _, ns := range s.Namespaces {
+		total -= ns.OverBudget
+	}
+	status := "Exported"
+	switch {
+	case s.Interrupted:
+		status = "Export interrupted:"
+	case s.BudgetExceeded != "":
+		status = "Export stopped, " + s.BudgetExceeded + " reached:"
+	}
+	fmt.Fprintf(out, "%s %d objects from %d namespaces to %s in %s, %d failures\n", status, total, len(s.Namespaces), s.ExportDir, s.Duration, s.Failures)
+}
+
+// tracef logs the objects written with --verbosity 3, the loggers passed around are FieldLoggers
+// which have no trace level
+func tracef(log logrus.FieldLogger, format string, args ...interface{}) {
+	if l, ok := log.(logrus.Ext1FieldLogger); ok {
+		l.Tracef(format, args...)
+	}
+}
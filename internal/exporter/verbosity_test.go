@@ -0,0 +1,195 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func Test_logLevel(t *testing.T) {
+	tests := []struct {
+		name      string
+		verbosity int
+		debug     bool
+		want      logrus.Level
+	}{
+		{name: "given verbosity 0, should only log errors", verbosity: 0, want: logrus.ErrorLevel},
+		{name: "given the default verbosity, should log infos", verbosity: 1, want: logrus.InfoLevel},
+		{name: "given verbosity 2, should log each resource", verbosity: 2, want: logrus.DebugLevel},
+		{name: "given verbosity 3, should log each object", verbosity: 3, want: logrus.TraceLevel},
+		{name: "given --debug, should log at least each resource", verbosity: 0, debug: true, want: logrus.DebugLevel},
+		{name: "given --debug and verbosity 3, should log each object", verbosity: 3, debug: true, want: logrus.TraceLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := logLevel(tt.verbosity, tt.debug); got != tt.want {
+				t.Errorf("logLevel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_validateVerbosity(t *testing.T) {
+	tests := []struct {
+		name        string
+		o           *ExportOptions
+		changed     bool
+		errContains string
+	}{
+		{name: "given --quiet, should pass", o: &ExportOptions{verbosity: verbosityDefault, quiet: true}},
+		{name: "given --quiet and -v=0, should pass", o: &ExportOptions{verbosity: 0, quiet: true}, changed: true},
+		{name: "given --quiet and -v=2, should fail", o: &ExportOptions{verbosity: 2, quiet: true}, changed: true, errContains: "--quiet cannot be used with --verbosity 2"},
+		{name: "given -v=4, should fail", o: &ExportOptions{verbosity: 4}, changed: true, errContains: "invalid --verbosity 4"},
+		{name: "given a negative verbosity, should fail", o: &ExportOptions{verbosity: -1}, changed: true, errContains: "invalid --verbosity -1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.o.validateVerbosity(tt.changed)
+			if tt.errContains == "" {
+				if err != nil {
+					t.Errorf("validateVerbosity() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("validateVerbosity() error = %v, want it to contain %q", err, tt.errContains)
+			}
+		})
+	}
+}
+
+func Test_newLogger_resourceLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		verbosity int
+		quiet     bool
+		want      []string
+		notWant   []string
+	}{
+		{
+			name:    "given -v=0, should log no per-resource lines",
+			notWant: []string{"deployments", "hello-world"},
+		},
+		{
+			name:      "given --quiet, should log no per-resource lines",
+			verbosity: verbosityDefault,
+			quiet:     true,
+			notWant:   []string{"deployments", "hello-world"},
+		},
+		{
+			name:      "given the default verbosity, should log no per-resource lines",
+			verbosity: verbosityDefault,
+			notWant:   []string{"deployments", "hello-world"},
+		},
+		{
+			name:      "given -v=2, should log each resource but not the objects",
+			verbosity: verbosityResources,
+			want:      []string{"Writing objects of resource: deployments", "Wrote 1 objects of resource deployments"},
+			notWant:   []string{"hello-world"},
+		},
+		{
+			name:      "given -v=3, should log every object written",
+			verbosity: verbosityObjects,
+			want:      []string{"Writing objects of resource: deployments", "deployments.apps_hello-world.yaml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errOut := &bytes.Buffer{}
+			o := &ExportOptions{
+				verbosity:   tt.verbosity,
+				quiet:       tt.quiet,
+				globalFlags: &flags.GlobalFlags{},
+				IOStreams:   genericclioptions.IOStreams{Out: &bytes.Buffer{}, ErrOut: errOut},
+			}
+			resources := []*groupResource{
+				{APIGroup: "apps", APIVersion: "v1", APIResource: metav1.APIResource{Name: "deployments", Kind: "Deployment"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{testObject()}}},
+			}
+			w := &resourceWriter{resourceDir: t.TempDir(), output: OutputYAML, layout: LayoutFlat, workers: 1, log: o.newLogger()}
+			if errs := w.writeResources(resources); len(errs) > 0 {
+				t.Fatalf("writeResources() errors = %v", errs)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(errOut.String(), want) {
+					t.Errorf("logs = %q, want %q", errOut.String(), want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(errOut.String(), notWant) {
+					t.Errorf("logs = %q, should not contain %q", errOut.String(), notWant)
+				}
+			}
+		})
+	}
+}
+
+func Test_runSummary_printLine(t *testing.T) {
+	s := newRunSummary(time.Now(), "", nil, []*exportSummary{
+		{Namespace: "foo", Resources: map[string]int{"configmaps": 2, "deployments.apps": 1}, Failures: 1},
+		{Namespace: "bar", Resources: map[string]int{"configmaps": 1}},
+	})
+	s.Duration = "1.5s"
+	s.ExportDir = "export"
+	out := &bytes.Buffer{}
+	s.printLine(out)
+	if want := "Exported 4 objects from 2 namespaces to export in 1.5s, 1 failures\n"; out.String() != want {
+		t.Errorf("printLine() = %q, want %q", out.String(), want)
+	}
+}
+
+// jsonLogLines parses the logs written with --log-format json, one object per line
+func jsonLogLines(t *testing.T, logs string) []map[string]interface{} {
+	t.Helper()
+	lines := []map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(logs), "\n") {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", line, err)
+		}
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+func Test_newLogger_json(t *testing.T) {
+	errOut := &bytes.Buffer{}
+	o := &ExportOptions{
+		verbosity:   verbosityObjects,
+		logFormat:   logFormatJSON,
+		globalFlags: &flags.GlobalFlags{},
+		IOStreams:   genericclioptions.IOStreams{Out: &bytes.Buffer{}, ErrOut: errOut},
+	}
+	resources := []*groupResource{
+		{APIGroup: "apps", APIVersion: "v1", APIResource: metav1.APIResource{Name: "deployments", Kind: "Deployment"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{testObject()}}},
+	}
+	w := &resourceWriter{resourceDir: t.TempDir(), output: OutputYAML, layout: LayoutFlat, workers: 1, log: o.newLogger().WithField(logFieldNamespace, "foo")}
+	if errs := w.writeResources(resources); len(errs) > 0 {
+		t.Fatalf("writeResources() errors = %v", errs)
+	}
+
+	var written map[string]interface{}
+	for _, entry := range jsonLogLines(t, errOut.String()) {
+		for _, key := range []string{"level", "timestamp", "message"} {
+			if _, found := entry[key]; !found {
+				t.Errorf("log line %v has no %s", entry, key)
+			}
+		}
+		if entry[logFieldObject] != nil {
+			written = entry
+		}
+	}
+	want := map[string]interface{}{logFieldGVR: "deployments.apps", logFieldNamespace: "foo", logFieldObject: "hello-world", logFieldAction: actionWritten, "level": "trace"}
+	for key, value := range want {
+		if written[key] != value {
+			t.Errorf("object written log = %v, want %s=%v", written, key, value)
+		}
+	}
+}
@@ -0,0 +1,432 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/s3"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+// watchRetryDelay is the delay before listing or watching again a resource whose watch failed
+const watchRetryDelay = 5 * time.Second
+
+// watchChanges counts the files --watch wrote and removed after the export until it stopped
+type watchChanges struct {
+	Until   time.Time `json:"until"`
+	Written int       `json:"written"`
+	Removed int       `json:"removed"`
+	// Relists counts the resources listed again after their watch expired
+	Relists int `json:"relists"`
+}
+
+// watchedResource is a namespaced resource of an exported namespace kept in sync by --watch
+type watchedResource struct {
+	namespace string
+	resource  *groupResource
+	client    dynamic.ResourceInterface
+	// objects are the objects by name as last listed or watched, versions their resourceVersion,
+	// those of the export until the resource is listed again when the watch starts
+	objects  map[string]unstructured.Unstructured
+	versions map[string]string
+	listed   bool
+	// pending are the objects changed by name, with the time their file is written
+	pending map[string]time.Time
+}
+
+// watchEvent is an object changed, or the list of the objects of a resource listed again
+type watchEvent struct {
+	resource *watchedResource
+	object   *unstructured.Unstructured
+	deleted  bool
+	list     *unstructured.UnstructuredList
+}
+
+// exportWatcher keeps the files of an export in sync with the objects of its namespaces. Each
+// resource is watched from the version it was listed at, and listed again when its watch expires.
+// The file of an object is written, or removed, once the object did not change for the debounce
+// delay, and the index is written after each batch so that the directory stays consistent.
+type exportWatcher struct {
+	o         *ExportOptions
+	exportRun *exportRun
+	resources []*watchedResource
+	debounce  time.Duration
+	// retryDelay is the delay before listing or watching again a resource whose watch failed
+	retryDelay time.Duration
+	events     chan watchEvent
+	changes    watchChanges
+	log        logrus.FieldLogger
+}
+
+// newExportWatcher returns the watcher of the namespaced resources listed by the export, except
+// those that cannot be watched like the ImageStreamTags
+func newExportWatcher(o *ExportOptions, dynamicClient dynamic.Interface, exportRun *exportRun, summaries []*exportSummary, log logrus.FieldLogger) *exportWatcher {
+	w := &exportWatcher{
+		o:          o,
+		exportRun:  exportRun,
+		debounce:   o.watchDebounce,
+		retryDelay: watchRetryDelay,
+		events:     make(chan watchEvent),
+		log:        log,
+	}
+	for _, s := range summaries {
+		for _, l := range s.Lists {
+			if !l.namespaced || l.resource == nil {
+				continue
+			}
+			if verbs := l.resource.APIResource.Verbs; len(verbs) > 0 && !containsString(verbs, "watch") {
+				log.Debugf("resource %s cannot be watched, its objects are not kept in sync", l.Resource)
+				continue
+			}
+			versions := map[string]string{}
+			for key, version := range l.objects {
+				_, name, _ := strings.Cut(key, "/")
+				versions[name] = version
+			}
+			w.resources = append(w.resources, &watchedResource{
+				namespace: s.Namespace,
+				resource:  l.resource,
+				client:    dynamicClient.Resource(l.gvr).Namespace(s.Namespace),
+				objects:   map[string]unstructured.Unstructured{},
+				versions:  versions,
+				pending:   map[string]time.Time{},
+			})
+		}
+	}
+	return w
+}
+
+// run keeps the files in sync until ctx is done, the pending changes are then written without
+// waiting for the debounce delay
+func (w *exportWatcher) run(ctx context.Context) error {
+	wg := sync.WaitGroup{}
+	for _, r := range w.resources {
+		wg.Add(1)
+		go func(r *watchedResource) {
+			defer wg.Done()
+			w.watch(ctx, r)
+		}(r)
+	}
+	tick := w.debounce / 2
+	if tick < 10*time.Millisecond {
+		tick = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			// the transformations of the last changes must not be cancelled with the watch
+			return w.flush(context.Background(), time.Time{})
+		case e := <-w.events:
+			w.apply(e)
+		case now := <-ticker.C:
+			if err := w.flush(ctx, now); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// watch lists the resource and watches it from the version listed, it is listed again whenever its
+// watch expires, until ctx is done
+func (w *exportWatcher) watch(ctx context.Context, r *watchedResource) {
+	log := w.log.WithField(logFieldNamespace, r.namespace).WithFields(resourceFields(r.resource))
+	resourceVersion := ""
+	for ctx.Err() == nil {
+		if resourceVersion == "" {
+			list, err := listPages(ctx, r.client, w.o.listOptions(), log)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Warnf("cannot list %s to watch it, retrying in %s: %v", r.resource.APIResource.Name, w.retryDelay, err)
+					sleep(ctx, w.retryDelay)
+				}
+				continue
+			}
+			resourceVersion = list.GetResourceVersion()
+			if !w.send(ctx, watchEvent{resource: r, list: list}) {
+				return
+			}
+		}
+		options := w.o.listOptions()
+		options.Limit = 0
+		options.ResourceVersion = resourceVersion
+		options.AllowWatchBookmarks = true
+		watcher, err := r.client.Watch(ctx, options)
+		switch {
+		case err != nil && isExpired(err):
+			log.Infof("the version of %s to watch from expired, listing it again", r.resource.APIResource.Name)
+			resourceVersion = ""
+		case err != nil:
+			if ctx.Err() == nil {
+				log.Warnf("cannot watch %s, retrying in %s: %v", r.resource.APIResource.Name, w.retryDelay, err)
+				sleep(ctx, w.retryDelay)
+			}
+		default:
+			resourceVersion = w.receive(ctx, r, watcher, resourceVersion, log)
+		}
+	}
+}
+
+// receive forwards the changes of a watch until it ends and returns the version to watch from
+// next, empty when the resource must be listed again
+func (w *exportWatcher) receive(ctx context.Context, r *watchedResource, watcher watch.Interface, resourceVersion string, log logrus.FieldLogger) string {
+	defer watcher.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return resourceVersion
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return resourceVersion
+			}
+			if event.Type == watch.Error {
+				err := apierrors.FromObject(event.Object)
+				if isExpired(err) {
+					log.Infof("the watch of %s expired, listing it again", r.resource.APIResource.Name)
+					return ""
+				}
+				log.Warnf("the watch of %s failed, watching it again: %v", r.resource.APIResource.Name, err)
+				sleep(ctx, w.retryDelay)
+				return resourceVersion
+			}
+			obj, ok := event.Object.(*unstructured.Unstructured)
+			if !ok {
+				continue
+			}
+			resourceVersion = obj.GetResourceVersion()
+			if event.Type == watch.Bookmark {
+				continue
+			}
+			if !w.send(ctx, watchEvent{resource: r, object: obj, deleted: event.Type == watch.Deleted}) {
+				return resourceVersion
+			}
+		}
+	}
+}
+
+// send hands an event to the loop of run, it reports false when ctx is done
+func (w *exportWatcher) send(ctx context.Context, e watchEvent) bool {
+	select {
+	case w.events <- e:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// apply records a change, the file of the object is written once the debounce delay passed
+// without other change
+func (w *exportWatcher) apply(e watchEvent) {
+	r := e.resource
+	due := time.Now().Add(w.debounce)
+	if e.list != nil {
+		if r.listed {
+			w.changes.Relists++
+		}
+		r.listed = true
+		objects := map[string]unstructured.Unstructured{}
+		for _, obj := range e.list.Items {
+			objects[obj.GetName()] = obj
+			if r.versions[obj.GetName()] != obj.GetResourceVersion() {
+				r.pending[obj.GetName()] = due
+			}
+		}
+		for name := range r.versions {
+			if _, found := objects[name]; !found {
+				r.pending[name] = due
+			}
+		}
+		r.objects = objects
+		r.versions = map[string]string{}
+		for name, obj := range objects {
+			r.versions[name] = obj.GetResourceVersion()
+		}
+		return
+	}
+	name := e.object.GetName()
+	if e.deleted {
+		delete(r.objects, name)
+		delete(r.versions, name)
+	} else {
+		r.objects[name] = *e.object
+		r.versions[name] = e.object.GetResourceVersion()
+	}
+	r.pending[name] = due
+}
+
+// flush writes the files of the objects whose debounce delay passed at now, all of them when now
+// is zero, then the index
+func (w *exportWatcher) flush(ctx context.Context, now time.Time) error {
+	namespaces := []string{}
+	due := map[*watchedResource][]string{}
+	for _, r := range w.resources {
+		for name, at := range r.pending {
+			if now.IsZero() || !at.After(now) {
+				due[r] = append(due[r], name)
+				delete(r.pending, name)
+			}
+		}
+		if len(due[r]) > 0 && !containsString(namespaces, r.namespace) {
+			namespaces = append(namespaces, r.namespace)
+		}
+	}
+	if len(due) == 0 {
+		return nil
+	}
+	for _, namespace := range namespaces {
+		w.flushNamespace(ctx, namespace, due)
+	}
+	if err := w.exportRun.manifests.write(); err != nil {
+		return fmt.Errorf("cannot write the index: %w", err)
+	}
+	return nil
+}
+
+// flushNamespace exports the changed objects of the namespace like the export does: they are
+// filtered and transformed, then written, or their file is removed when they were deleted or are
+// now filtered out. The reports of the export, like the images or the Helm releases, are not
+// updated.
+func (w *exportWatcher) flushNamespace(ctx context.Context, namespace string, due map[*watchedResource][]string) {
+	log := w.log.WithField(logFieldNamespace, namespace)
+	// the filters look at the other objects of the namespace, like the Services of the Endpoints
+	current := []*groupResource{}
+	changed := []*groupResource{}
+	names := map[*groupResource][]string{}
+	for _, r := range w.resources {
+		if r.namespace != namespace {
+			continue
+		}
+		all := *r.resource
+		all.objects = &unstructured.UnstructuredList{Items: []unstructured.Unstructured{}}
+		for _, obj := range r.objects {
+			all.objects.Items = append(all.objects.Items, obj)
+		}
+		current = append(current, &all)
+		if len(due[r]) == 0 {
+			continue
+		}
+		sort.Strings(due[r])
+		res := *r.resource
+		res.objects = &unstructured.UnstructuredList{Items: []unstructured.Unstructured{}}
+		for _, name := range due[r] {
+			if obj, found := r.objects[name]; found {
+				res.objects.Items = append(res.objects.Items, *obj.DeepCopy())
+			}
+		}
+		changed = append(changed, &res)
+		names[&res] = due[r]
+	}
+
+	summary := newExportSummary(namespace)
+	w.o.application.filter(namespace, current, changed, summary, log)
+	applyObjectFilters(changed, w.o.objectFilters(current), summary, log)
+	for _, f := range w.o.prepareObjects(ctx, changed, summary, log) {
+		log.Warnf("cannot transform %s %s, its file is not updated: %s", f.Resource, f.Name, f.Error)
+	}
+	if w.exportRun.streams != nil {
+		w.exportRun.streams.resolve(ctx, changed)
+	}
+	w.exportRun.imageRewrites.rewrite(changed)
+	w.o.storageClasses.rewrite(changed)
+	w.exportRun.cidrs.check(changed)
+	w.exportRun.renamer.rename(namespace, changed)
+
+	writer := w.o.newResourceWriter(namespace, w.exportRun, log)
+	failed := map[string]bool{}
+	for _, err := range writer.writeResources(changed) {
+		log.WithError(err).Warnf("cannot write a changed object: %v", err)
+		if e, ok := err.(*objectWriteError); ok {
+			failed[GroupResourceName(e.resource.APIGroup, e.resource.APIResource.Name)+"/"+e.name] = true
+		}
+	}
+	for _, res := range changed {
+		log := log.WithFields(resourceFields(res))
+		kept := map[string]bool{}
+		for _, obj := range res.objects.Items {
+			kept[obj.GetName()] = true
+			if failed[GroupResourceName(res.APIGroup, res.APIResource.Name)+"/"+obj.GetName()] {
+				continue
+			}
+			w.changes.Written++
+			log.WithFields(logrus.Fields{logFieldObject: obj.GetName(), logFieldAction: actionWritten}).Infof("%s %s/%s changed, its file was written", res.APIResource.Kind, namespace, obj.GetName())
+		}
+		for _, name := range names[res] {
+			if kept[name] {
+				continue
+			}
+			stub := unstructured.Unstructured{}
+			stub.SetName(name)
+			stub.SetNamespace(namespace)
+			removed, err := writer.removeObject(res, stub)
+			if err != nil {
+				log.WithError(err).Warnf("cannot remove the file of %s %s/%s: %v", res.APIResource.Kind, namespace, name, err)
+				continue
+			}
+			if removed {
+				w.changes.Removed++
+				log.WithFields(logrus.Fields{logFieldObject: name, logFieldAction: actionRemoved}).Infof("%s %s/%s was deleted or is no longer exported, its file was removed", res.APIResource.Kind, namespace, name)
+			}
+		}
+	}
+}
+
+// isExpired reports whether the version a resource is listed or watched from is too old
+func isExpired(err error) bool {
+	return apierrors.IsResourceExpired(err) || apierrors.IsGone(err)
+}
+
+// sleep waits for the delay or until ctx is done
+func sleep(ctx context.Context, delay time.Duration) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// validateWatch checks that --watch has a local export directory it can keep in sync
+func (o *ExportOptions) validateWatch() error {
+	if !o.watch {
+		return nil
+	}
+	switch {
+	case o.watchDebounce <= 0:
+		return fmt.Errorf("--watch-debounce must be positive")
+	case o.archive || s3.IsURL(o.exportDir):
+		return fmt.Errorf("--watch keeps the export directory in sync, it cannot be used with --archive or an %s --export-dir", s3.Scheme)
+	case o.dryRun || o.retryFailures:
+		return fmt.Errorf("--watch cannot be used with --dry-run or --retry-failures")
+	case o.layout == LayoutSingle:
+		return fmt.Errorf("--watch cannot be used with --layout %s, the objects are written to a single file", LayoutSingle)
+	case hasToken(o.exportDir, tokenNamespace):
+		return fmt.Errorf("--watch cannot be used with a {%s} token in --export-dir, the namespaces are exported one after the other", tokenNamespace)
+	}
+	return nil
+}
+
+// watchExport keeps the export directory in sync with the exported namespaces until ctx is done,
+// on Ctrl-C or after --timeout, then records the changes in the export summary
+func (o *ExportOptions) watchExport(ctx context.Context, dynamicClient dynamic.Interface, exportRun *exportRun, runSummary *RunSummary, log logrus.FieldLogger) error {
+	w := newExportWatcher(o, dynamicClient, exportRun, runSummary.Namespaces, log)
+	log.Infof("Watching %d resources for changes, press Ctrl-C to stop", len(w.resources))
+	err := w.run(ctx)
+	w.changes.Until = time.Now().UTC()
+	runSummary.Watch = &w.changes
+	if err := runSummary.write(o.exportDir); err != nil {
+		log.Errorf("error writing the export summary: %#v", err)
+		return err
+	}
+	log.Infof("Stopped watching: %d files written, %d removed, %d relists", w.changes.Written, w.changes.Removed, w.changes.Relists)
+	return err
+}
@@ -0,0 +1,145 @@
+package exporter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func testWatchedConfigMap(name string, resourceVersion string) unstructured.Unstructured {
+	obj := unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetName(name)
+	obj.SetNamespace("foo")
+	obj.SetResourceVersion(resourceVersion)
+	return obj
+}
+
+func Test_exportWatcher_flush(t *testing.T) {
+	exportDir := t.TempDir()
+	o := &ExportOptions{exportDir: exportDir, output: OutputYAML, layout: LayoutFlat, workers: 1, watchDebounce: time.Second}
+	exportRun := &exportRun{manifests: newExportIndex(exportDir)}
+	configMaps := &groupResource{APIVersion: "v1", APIResource: metav1.APIResource{Name: "configmaps", Kind: "ConfigMap", Namespaced: true}}
+	exported := *configMaps
+	exported.objects = &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+		testWatchedConfigMap("kept", "1"), testWatchedConfigMap("modified", "2"), testWatchedConfigMap("deleted", "3"),
+	}}
+	if errs := o.newResourceWriter("foo", exportRun, testLogger()).writeResources([]*groupResource{&exported}); len(errs) > 0 {
+		t.Fatalf("writeResources() errors = %v", errs)
+	}
+	path := func(name string) string {
+		return filepath.Join(exportDir, "resources", "foo", "configmaps_"+name+".yaml")
+	}
+	keptInfo, err := os.Stat(path("kept"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := &exportWatcher{o: o, exportRun: exportRun, debounce: time.Hour, log: testLogger()}
+	r := &watchedResource{
+		namespace: "foo",
+		resource:  configMaps,
+		objects:   map[string]unstructured.Unstructured{},
+		versions:  map[string]string{"kept": "1", "modified": "2", "deleted": "3"},
+		pending:   map[string]time.Time{},
+	}
+	w.resources = []*watchedResource{r}
+
+	// the list of the start of the watch is compared to the versions exported
+	w.apply(watchEvent{resource: r, list: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+		testWatchedConfigMap("kept", "1"), testWatchedConfigMap("modified", "4"), testWatchedConfigMap("added", "5"),
+	}}})
+	if err := w.flush(context.Background(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path("added")); !os.IsNotExist(err) {
+		t.Errorf("flush() wrote an object before its debounce delay: %v", err)
+	}
+	if err := w.flush(context.Background(), time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"kept", "modified", "added"} {
+		if _, err := os.Stat(path(name)); err != nil {
+			t.Errorf("flush() did not write %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(path("deleted")); !os.IsNotExist(err) {
+		t.Errorf("flush() did not remove the file of the deleted object: %v", err)
+	}
+	if info, _ := os.Stat(path("kept")); !info.ModTime().Equal(keptInfo.ModTime()) {
+		t.Errorf("flush() wrote the unchanged object again")
+	}
+	if w.changes.Written != 2 || w.changes.Removed != 1 || w.changes.Relists != 0 {
+		t.Errorf("changes = %+v, want 2 written, 1 removed and no relist", w.changes)
+	}
+
+	// a watched deletion removes the file, a second list counts as a relist
+	obj := testWatchedConfigMap("modified", "6")
+	w.apply(watchEvent{resource: r, object: &obj, deleted: true})
+	w.apply(watchEvent{resource: r, list: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+		testWatchedConfigMap("kept", "1"), testWatchedConfigMap("added", "5"),
+	}}})
+	if err := w.flush(context.Background(), time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path("modified")); !os.IsNotExist(err) {
+		t.Errorf("flush() did not remove the file of the watched deletion: %v", err)
+	}
+	if w.changes.Written != 2 || w.changes.Removed != 2 || w.changes.Relists != 1 {
+		t.Errorf("changes = %+v, want 2 written, 2 removed and 1 relist", w.changes)
+	}
+	for _, entry := range exportRun.manifests.entries {
+		if entry.Path == "resources/foo/configmaps_modified.yaml" || entry.Path == "resources/foo/configmaps_deleted.yaml" {
+			t.Errorf("the index still lists the removed file %s", entry.Path)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(exportDir, "index.json")); err != nil {
+		t.Errorf("flush() did not write the index: %v", err)
+	}
+}
+
+func TestExportOptions_validateWatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		o       ExportOptions
+		wantErr bool
+	}{
+		{
+			name: "given a local export directory, should succeed",
+			o:    ExportOptions{watch: true, watchDebounce: time.Second, exportDir: "export", layout: LayoutFlat},
+		},
+		{
+			name:    "given --archive, should fail",
+			o:       ExportOptions{watch: true, watchDebounce: time.Second, exportDir: "export", archive: true},
+			wantErr: true,
+		},
+		{
+			name:    "given an s3 export directory, should fail",
+			o:       ExportOptions{watch: true, watchDebounce: time.Second, exportDir: "s3://bucket/export"},
+			wantErr: true,
+		},
+		{
+			name:    "given a namespace token, should fail",
+			o:       ExportOptions{watch: true, watchDebounce: time.Second, exportDir: "export/{namespace}"},
+			wantErr: true,
+		},
+		{
+			name:    "given no debounce delay, should fail",
+			o:       ExportOptions{watch: true, exportDir: "export"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.o.validateWatch(); (err != nil) != tt.wantErr {
+				t.Errorf("validateWatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package exporter
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var WebhookConfigurationResources = []metav1.APIResource{
+	{Group: "admissionregistration.k8s.io", Version: "v1", Name: "validatingwebhookconfigurations", Kind: "ValidatingWebhookConfiguration"},
+	{Group: "admissionregistration.k8s.io", Version: "v1", Name: "mutatingwebhookconfigurations", Kind: "MutatingWebhookConfiguration"},
+}
+
+// webhookCollector selects the admission webhook configurations calling a service of the exported
+// namespaces, an operator would stop admitting anything on the target cluster without them. The
+// configurations are listed once per run.
+type webhookCollector struct {
+	client dynamic.Interface
+	log    logrus.FieldLogger
+
+	listed   bool
+	configs  []*groupResource
+	failures []FailureRecord
+}
+
+func newWebhookCollector(client dynamic.Interface, log logrus.FieldLogger) *webhookCollector {
+	return &webhookCollector{client: client, log: log}
+}
+
+func (c *webhookCollector) list(ctx context.Context) {
+	c.listed = true
+	for _, resource := range WebhookConfigurationResources {
+		g := &groupResource{
+			APIGroup:        resource.Group,
+			APIVersion:      resource.Version,
+			APIGroupVersion: schema.GroupVersion{Group: resource.Group, Version: resource.Version}.String(),
+			APIResource:     resource,
+		}
+		list, err := c.client.Resource(schema.GroupVersionResource{Group: resource.Group, Version: resource.Version, Resource: resource.Name}).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			c.log.Warnf("cannot list %s, the webhooks are not exported: %v", resource.Name, err)
+			c.failures = append(c.failures, listFailureRecord(&groupResourceError{APIResource: resource, Error: err}))
+			continue
+		}
+		g.objects = list
+		c.configs = append(c.configs, g)
+	}
+}
+
+// collect returns the webhook configurations with the webhooks calling a service of the namespace,
+// the other webhooks of a configuration are left out. The CA bundles are kept as they are. The
+// configurations that could not be listed are returned as failures.
+func (c *webhookCollector) collect(ctx context.Context, namespace string) ([]*groupResource, []FailureRecord) {
+	if !c.listed {
+		c.list(ctx)
+	}
+	collected := []*groupResource{}
+	for _, g := range c.configs {
+		selected := &groupResource{
+			APIGroup:        g.APIGroup,
+			APIVersion:      g.APIVersion,
+			APIGroupVersion: g.APIGroupVersion,
+			APIResource:     g.APIResource,
+			objects:         &unstructured.UnstructuredList{Items: []unstructured.Unstructured{}},
+		}
+		for _, config := range g.objects.Items {
+			webhooks, _, _ := unstructured.NestedSlice(config.Object, "webhooks")
+			matching := []interface{}{}
+			for _, webhook := range webhooks {
+				serviceNamespace := nestedStrings(webhook, []string{"clientConfig", "service", "namespace"})
+				if len(serviceNamespace) == 1 && serviceNamespace[0] == namespace {
+					matching = append(matching, webhook)
+				}
+			}
+			if len(matching) == 0 {
+				continue
+			}
+			obj := *config.DeepCopy()
+			if err := unstructured.SetNestedSlice(obj.Object, matching, "webhooks"); err != nil {
+				c.log.Warnf("cannot select the webhooks of %s %s: %v", obj.GetKind(), obj.GetName(), err)
+				continue
+			}
+			c.log.Infof("Adding %s %s with %d webhooks calling namespace %s", obj.GetKind(), obj.GetName(), len(matching), namespace)
+			selected.objects.Items = append(selected.objects.Items, obj)
+		}
+		if len(selected.objects.Items) > 0 {
+			collected = append(collected, selected)
+		}
+	}
+	return collected, c.failures
+}
@@ -0,0 +1,68 @@
+package exporter
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func Test_webhookCollector(t *testing.T) {
+	webhook := func(name, namespace string) interface{} {
+		return map[string]interface{}{
+			"name": name,
+			"clientConfig": map[string]interface{}{
+				"caBundle": "Y2EtYnVuZGxl",
+				"service":  map[string]interface{}{"name": "webhook", "namespace": namespace},
+			},
+		}
+	}
+	config := func(kind, name string, webhooks ...interface{}) runtime.Object {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "admissionregistration.k8s.io/v1",
+			"kind":       kind,
+			"metadata":   map[string]interface{}{"name": name},
+			"webhooks":   webhooks,
+		}}
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			{Group: "admissionregistration.k8s.io", Version: "v1", Resource: "validatingwebhookconfigurations"}: "ValidatingWebhookConfigurationList",
+			{Group: "admissionregistration.k8s.io", Version: "v1", Resource: "mutatingwebhookconfigurations"}:   "MutatingWebhookConfigurationList",
+		},
+		config("ValidatingWebhookConfiguration", "operator", webhook("validate.foo", "foo"), webhook("validate.bar", "bar")),
+		config("MutatingWebhookConfiguration", "operator", webhook("mutate.foo", "foo")),
+		config("ValidatingWebhookConfiguration", "external", map[string]interface{}{"name": "url", "clientConfig": map[string]interface{}{"url": "https://example.com"}}),
+	)
+
+	c := newWebhookCollector(client, testLogger())
+	configs, failures := c.collect(context.Background(), "foo")
+	if len(failures) != 0 {
+		t.Fatalf("collect() failures = %v", failures)
+	}
+	got := map[string][]interface{}{}
+	for _, r := range configs {
+		for _, obj := range r.objects.Items {
+			webhooks, _, _ := unstructured.NestedSlice(obj.Object, "webhooks")
+			got[obj.GetKind()+"/"+obj.GetName()] = webhooks
+		}
+	}
+	if len(got) != 2 {
+		t.Fatalf("collect() = %v, want the two operator configurations", got)
+	}
+	validating := got["ValidatingWebhookConfiguration/operator"]
+	if len(validating) != 1 || validating[0].(map[string]interface{})["name"] != "validate.foo" {
+		t.Errorf("collect() should keep only the webhooks calling namespace foo, got %v", validating)
+	}
+	if ca := nestedStrings(validating[0], []string{"clientConfig", "caBundle"}); len(ca) != 1 || ca[0] != "Y2EtYnVuZGxl" {
+		t.Errorf("collect() should keep the CA bundle, got %v", ca)
+	}
+
+	configs, _ = c.collect(context.Background(), "baz")
+	if len(configs) != 0 {
+		t.Errorf("collect() = %v, want no configuration for namespace baz", configs)
+	}
+}
@@ -0,0 +1,43 @@
+package exporter
+
+import (
+	"context"
+	"sync"
+)
+
+// RunWorkers calls fn for every index in [0, n) from at most workers goroutines. Once ctx is done
+// no new index is handed out; the indexes that were never processed are returned in order.
+func RunWorkers(ctx context.Context, workers int, n int, fn func(i int)) []int {
+	if workers < 1 {
+		workers = 1
+	}
+
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+
+	skipped := []int{}
+	for i := 0; i < n; i++ {
+		if ctx.Err() != nil {
+			skipped = append(skipped, i)
+			continue
+		}
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			skipped = append(skipped, i)
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	return skipped
+}
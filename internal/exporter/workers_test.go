@@ -0,0 +1,57 @@
+package exporter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_runWorkers(t *testing.T) {
+	var running, maxRunning int32
+	processed := make([]bool, 20)
+	mu := sync.Mutex{}
+
+	skipped := RunWorkers(context.Background(), 3, len(processed), func(i int) {
+		n := atomic.AddInt32(&running, 1)
+		mu.Lock()
+		if n > maxRunning {
+			maxRunning = n
+		}
+		processed[i] = true
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -1)
+	})
+
+	if len(skipped) != 0 {
+		t.Errorf("runWorkers() skipped %v, want none", skipped)
+	}
+	for i, p := range processed {
+		if !p {
+			t.Errorf("index %d was not processed", i)
+		}
+	}
+	if maxRunning > 3 {
+		t.Errorf("runWorkers() ran %d functions concurrently, want at most 3", maxRunning)
+	}
+}
+
+func Test_runWorkers_cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int32
+
+	skipped := RunWorkers(ctx, 1, 10, func(i int) {
+		if atomic.AddInt32(&calls, 1) == 2 {
+			cancel()
+		}
+	})
+
+	if int(calls)+len(skipped) != 10 {
+		t.Errorf("runWorkers() processed %d and skipped %d indexes, want 10 in total", calls, len(skipped))
+	}
+	if len(skipped) == 0 {
+		t.Errorf("runWorkers() should skip indexes once the context is cancelled")
+	}
+}
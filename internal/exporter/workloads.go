@@ -0,0 +1,190 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/file"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+)
+
+const workloadsFile = "workloads.json"
+
+// workload is an exported object that can be scaled, to scale down on the source cluster before
+// the final sync
+type workload struct {
+	Namespace  string `json:"namespace"`
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Replicas   int64  `json:"replicas"`
+	// Selector is the label selector of the pods of the workload, empty when it has none
+	Selector string `json:"selector,omitempty"`
+}
+
+// scaleSubresource is where the replicas and the selector of a scalable kind are read from, the
+// paths of the scale subresource of a CRD
+type scaleSubresource struct {
+	specReplicasPath  string
+	labelSelectorPath string
+}
+
+// BuiltinScaleSubresources are the scalable built-in kinds, the owned ReplicaSets are scaled by
+// their Deployment
+var BuiltinScaleSubresources = map[string]scaleSubresource{
+	"apps/Deployment":  {specReplicasPath: ".spec.replicas", labelSelectorPath: ".spec.selector"},
+	"apps/StatefulSet": {specReplicasPath: ".spec.replicas", labelSelectorPath: ".spec.selector"},
+	"apps/ReplicaSet":  {specReplicasPath: ".spec.replicas", labelSelectorPath: ".spec.selector"},
+}
+
+// crdScaleSubresource returns the scale subresource the CRD declares for the version, nil when
+// the custom resources of the version cannot be scaled
+func crdScaleSubresource(crd unstructured.Unstructured, version string) *scaleSubresource {
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, v := range versions {
+		m, ok := v.(map[string]interface{})
+		if !ok || m["name"] != version {
+			continue
+		}
+		scale, found, _ := unstructured.NestedMap(m, "subresources", "scale")
+		if !found {
+			return nil
+		}
+		specReplicasPath, _ := scale["specReplicasPath"].(string)
+		labelSelectorPath, _ := scale["labelSelectorPath"].(string)
+		if specReplicasPath == "" {
+			return nil
+		}
+		return &scaleSubresource{specReplicasPath: specReplicasPath, labelSelectorPath: labelSelectorPath}
+	}
+	return nil
+}
+
+// fieldPath splits a JSON path of a scale subresource, like .spec.replicas
+func fieldPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "."), ".")
+}
+
+// Workload returns the object as a workload, its replicas defaulting to 1 like the API server
+func (s scaleSubresource) Workload(obj unstructured.Unstructured) workload {
+	w := workload{
+		Namespace:  obj.GetNamespace(),
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Name:       obj.GetName(),
+		Replicas:   1,
+	}
+	if replicas, found, err := unstructured.NestedInt64(obj.Object, fieldPath(s.specReplicasPath)...); found && err == nil {
+		w.Replicas = replicas
+	}
+	if s.labelSelectorPath == "" {
+		return w
+	}
+	// the selector is a LabelSelector on the built-in kinds and usually serialized as a string
+	// by the custom resources, like the status.selector of the scale subresource
+	path := fieldPath(s.labelSelectorPath)
+	if selector, found, err := unstructured.NestedString(obj.Object, path...); found && err == nil {
+		w.Selector = selector
+	} else if m, found, err := unstructured.NestedMap(obj.Object, path...); found && err == nil {
+		labelSelector := &metav1.LabelSelector{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, labelSelector); err == nil {
+			w.Selector = metav1.FormatLabelSelector(labelSelector)
+		}
+	}
+	return w
+}
+
+// workloadReport lists the scalable exported objects in workloads.json. The scale subresources of
+// the custom resources are read from their CRD, looked up once per run.
+type workloadReport struct {
+	client dynamic.Interface
+	log    logrus.FieldLogger
+	// scales are the scale subresources of the custom resources by CRD name and version, nil
+	// when they cannot be scaled
+	scales    map[string]*scaleSubresource
+	workloads []workload
+}
+
+func newWorkloadReport(client dynamic.Interface, log logrus.FieldLogger) *workloadReport {
+	return &workloadReport{client: client, log: log, scales: map[string]*scaleSubresource{}, workloads: []workload{}}
+}
+
+// add records the scalable objects of the resources and returns how many there are. It must be
+// called before the status is stripped, the custom resources usually report their selector there.
+func (w *workloadReport) add(ctx context.Context, resources []*groupResource) int {
+	if w == nil {
+		return 0
+	}
+	added := 0
+	for _, r := range resources {
+		if r.objects == nil || len(r.objects.Items) == 0 {
+			continue
+		}
+		scale := w.scaleSubresource(ctx, r)
+		if scale == nil {
+			continue
+		}
+		for _, obj := range r.objects.Items {
+			if r.APIResource.Kind == "ReplicaSet" && IsControlled(obj) {
+				continue
+			}
+			w.workloads = append(w.workloads, scale.Workload(obj))
+			added++
+		}
+	}
+	return added
+}
+
+// scaleSubresource returns the scale subresource of the resource, nil when it cannot be scaled
+func (w *workloadReport) scaleSubresource(ctx context.Context, r *groupResource) *scaleSubresource {
+	if scale, ok := BuiltinScaleSubresources[r.APIGroup+"/"+r.APIResource.Kind]; ok {
+		return &scale
+	}
+	// built-in groups have no dots, CRD groups must have at least one
+	if !strings.Contains(r.APIGroup, ".") {
+		return nil
+	}
+	name := r.APIResource.Name + "." + r.APIGroup
+	key := name + "/" + r.APIVersion
+	if scale, ok := w.scales[key]; ok {
+		return scale
+	}
+	w.scales[key] = nil
+	crd, err := w.client.Resource(CRDGVR).Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		return nil
+	case err != nil:
+		w.log.Warnf("cannot read CRD %s, its custom resources are not listed in %s: %v", name, workloadsFile, err)
+		return nil
+	}
+	w.scales[key] = crdScaleSubresource(*crd, r.APIVersion)
+	return w.scales[key]
+}
+
+// write writes workloads.json atomically, flushed to disk with durable
+func (w *workloadReport) write(exportDir string, durable bool) error {
+	sort.SliceStable(w.workloads, func(i, j int) bool {
+		a, b := w.workloads[i], w.workloads[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	workloadBytes, err := json.MarshalIndent(w.workloads, "", "  ")
+	if err != nil {
+		return err
+	}
+	return file.WriteAtomic(file.OS, filepath.Join(exportDir, workloadsFile), append(workloadBytes, '\n'), durable)
+}
@@ -0,0 +1,143 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func testCRD(name string, versions ...interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       map[string]interface{}{"versions": versions},
+	}}
+}
+
+func Test_crdScaleSubresource(t *testing.T) {
+	scalable := map[string]interface{}{
+		"name": "v1",
+		"subresources": map[string]interface{}{
+			"status": map[string]interface{}{},
+			"scale": map[string]interface{}{
+				"specReplicasPath":   ".spec.size",
+				"statusReplicasPath": ".status.size",
+				"labelSelectorPath":  ".status.selector",
+			},
+		},
+	}
+	tests := []struct {
+		name    string
+		crd     *unstructured.Unstructured
+		version string
+		want    *scaleSubresource
+	}{
+		{
+			name:    "given a version with the scale subresource, should return its paths",
+			crd:     testCRD("databases.example.com", scalable),
+			version: "v1",
+			want:    &scaleSubresource{specReplicasPath: ".spec.size", labelSelectorPath: ".status.selector"},
+		},
+		{
+			name:    "given a version without the scale subresource, should return nil",
+			crd:     testCRD("databases.example.com", scalable, map[string]interface{}{"name": "v1beta1", "subresources": map[string]interface{}{"status": map[string]interface{}{}}}),
+			version: "v1beta1",
+		},
+		{
+			name:    "given a version not served by the CRD, should return nil",
+			crd:     testCRD("databases.example.com", scalable),
+			version: "v2",
+		},
+		{
+			name: "given a CRD without subresources, should return nil",
+			crd:  testCRD("widgets.example.com", map[string]interface{}{"name": "v1"}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := crdScaleSubresource(*tt.crd, tt.version); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("crdScaleSubresource() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_workloadReport(t *testing.T) {
+	crd := testCRD("databases.example.com", map[string]interface{}{
+		"name": "v1",
+		"subresources": map[string]interface{}{"scale": map[string]interface{}{
+			"specReplicasPath":  ".spec.size",
+			"labelSelectorPath": ".status.selector",
+		}},
+	})
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{CRDGVR: "CustomResourceDefinitionList"}, crd)
+
+	object := func(apiVersion string, kind string, name string, fields map[string]interface{}) unstructured.Unstructured {
+		obj := testOwnedObject(kind, name)
+		obj.SetAPIVersion(apiVersion)
+		for k, v := range fields {
+			obj.Object[k] = v
+		}
+		return obj
+	}
+	controller := true
+	owned := object("apps/v1", "ReplicaSet", "web-5d4f8", nil)
+	owned.SetOwnerReferences([]metav1.OwnerReference{{Kind: "Deployment", Name: "web", Controller: &controller}})
+	resources := []*groupResource{
+		{APIGroup: "apps", APIVersion: "v1", APIResource: metav1.APIResource{Name: "deployments", Kind: "Deployment"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+			object("apps/v1", "Deployment", "web", map[string]interface{}{"spec": map[string]interface{}{
+				"replicas": int64(3),
+				"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "web"}},
+			}}),
+		}}},
+		{APIGroup: "apps", APIVersion: "v1", APIResource: metav1.APIResource{Name: "replicasets", Kind: "ReplicaSet"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{owned}}},
+		{APIGroup: "example.com", APIVersion: "v1", APIResource: metav1.APIResource{Name: "databases", Kind: "Database"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+			object("example.com/v1", "Database", "orders", map[string]interface{}{
+				"spec":   map[string]interface{}{"size": int64(2)},
+				"status": map[string]interface{}{"selector": "app=orders"},
+			}),
+		}}},
+		{APIGroup: "other.example.com", APIVersion: "v1", APIResource: metav1.APIResource{Name: "gadgets", Kind: "Gadget"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+			object("other.example.com/v1", "Gadget", "g", nil),
+		}}},
+		{APIGroup: "", APIVersion: "v1", APIResource: metav1.APIResource{Name: "configmaps", Kind: "ConfigMap"}, objects: &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+			object("v1", "ConfigMap", "web-config", nil),
+		}}},
+	}
+
+	report := newWorkloadReport(client, testLogger())
+	if got := report.add(context.Background(), resources); got != 2 {
+		t.Errorf("add() = %d, want 2", got)
+	}
+
+	dir := t.TempDir()
+	if err := report.write(dir, false); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	workloadBytes, err := os.ReadFile(filepath.Join(dir, workloadsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []workload{}
+	if err := json.Unmarshal(workloadBytes, &got); err != nil {
+		t.Fatalf("%s does not parse: %v", workloadsFile, err)
+	}
+	want := []workload{
+		{Namespace: "foo", APIVersion: "example.com/v1", Kind: "Database", Name: "orders", Replicas: 2, Selector: "app=orders"},
+		{Namespace: "foo", APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Replicas: 3, Selector: "app=web"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("workloads = %+v, want %+v", got, want)
+	}
+}
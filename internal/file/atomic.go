@@ -0,0 +1,96 @@
+package file
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tempSuffix ends the name of the temporary files written next to their target, which start with
+// a dot
+const tempSuffix = ".tmp"
+
+// TempFile is a temporary file created by an FS
+type TempFile interface {
+	io.Writer
+	Name() string
+	Sync() error
+	Close() error
+}
+
+// FS is the filesystem WriteAtomic writes to, tests inject their own to simulate failures
+type FS interface {
+	CreateTemp(dir string, pattern string) (TempFile, error)
+	Rename(oldPath string, newPath string) error
+	Remove(path string) error
+	// SyncDir flushes the entries of a directory, like a file renamed into it
+	SyncDir(dir string) error
+}
+
+// OS is the filesystem of the operating system
+var OS FS = osFS{}
+
+type osFS struct{}
+
+func (osFS) CreateTemp(dir string, pattern string) (TempFile, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (osFS) Rename(oldPath string, newPath string) error {
+	return os.Rename(oldPath, newPath)
+}
+
+func (osFS) Remove(path string) error {
+	return os.Remove(path)
+}
+
+func (osFS) SyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// WriteAtomic writes data to path through a temporary file of the same directory renamed into
+// place, so that path holds either its previous content or data, never a part of it. With durable
+// the file and its directory are flushed to disk before returning. The file is created with mode
+// 0600.
+func WriteAtomic(fsys FS, path string, data []byte, durable bool) error {
+	dir := filepath.Dir(path)
+	f, err := fsys.CreateTemp(dir, "."+filepath.Base(path)+".*"+tempSuffix)
+	if err != nil {
+		return err
+	}
+	tempPath := f.Name()
+	_, err = f.Write(data)
+	if err == nil && durable {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = fsys.Rename(tempPath, path)
+	}
+	if err != nil {
+		fsys.Remove(tempPath)
+		return err
+	}
+	if durable {
+		return fsys.SyncDir(dir)
+	}
+	return nil
+}
+
+// IsTemp reports whether the file name is a temporary file of WriteAtomic, left behind when the
+// process died before renaming it
+func IsTemp(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, tempSuffix)
+}
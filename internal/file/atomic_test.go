@@ -0,0 +1,126 @@
+package file_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/file"
+)
+
+// faultyFS is the filesystem of the OS failing the rename, like a process dying between writing a
+// temporary file and renaming it, and counting the flushes
+type faultyFS struct {
+	failRename bool
+	syncs      int
+}
+
+type countedFile struct {
+	*os.File
+	fs *faultyFS
+}
+
+func (f countedFile) Sync() error {
+	f.fs.syncs++
+	return f.File.Sync()
+}
+
+func (fs *faultyFS) CreateTemp(dir string, pattern string) (file.TempFile, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return countedFile{File: f, fs: fs}, nil
+}
+
+func (fs *faultyFS) Rename(oldPath string, newPath string) error {
+	if fs.failRename {
+		return errors.New("killed")
+	}
+	return os.Rename(oldPath, newPath)
+}
+
+func (fs *faultyFS) Remove(path string) error {
+	return os.Remove(path)
+}
+
+func (fs *faultyFS) SyncDir(dir string) error {
+	fs.syncs++
+	return file.OS.SyncDir(dir)
+}
+
+func TestWriteAtomic(t *testing.T) {
+	tests := []struct {
+		name       string
+		failRename bool
+		durable    bool
+		want       string
+		wantSyncs  int
+		wantErr    bool
+	}{
+		{
+			name: "given a successful write, should replace the file",
+			want: "kind: ConfigMap\n",
+		},
+		{
+			name:      "given a durable write, should flush the file and its directory",
+			durable:   true,
+			want:      "kind: ConfigMap\n",
+			wantSyncs: 2,
+		},
+		{
+			name:       "given a failure before the rename, should keep the previous file",
+			failRename: true,
+			want:       "kind: Secret\n",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "configmaps_foo.yaml")
+			if err := os.WriteFile(path, []byte("kind: Secret\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+			fs := &faultyFS{failRename: tt.failRename}
+			err := file.WriteAtomic(fs, path, []byte("kind: ConfigMap\n"), tt.durable)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WriteAtomic() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil || string(got) != tt.want {
+				t.Errorf("file = %q, %v, want %q", got, err, tt.want)
+			}
+			if fs.syncs != tt.wantSyncs {
+				t.Errorf("WriteAtomic() flushed %d times, want %d", fs.syncs, tt.wantSyncs)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("WriteAtomic() left %d files, want only the target", len(entries))
+			}
+		})
+	}
+}
+
+func TestReadFiles_temp(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "configmaps_foo.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: foo\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// the temporary file of an export killed while writing it
+	if err := os.WriteFile(filepath.Join(dir, ".configmaps_bar.yaml.123.tmp"), []byte("apiVersion: v1\nkind: Conf"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	files, err := file.ReadFiles(context.Background(), dir, nil)
+	if err != nil {
+		t.Fatalf("ReadFiles() error = %v", err)
+	}
+	if len(files) != 1 || files[0].Unstructured.GetName() != "foo" {
+		t.Errorf("ReadFiles() = %v, want only foo", files)
+	}
+}
@@ -1,8 +1,11 @@
 package file
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -19,38 +22,56 @@ type File struct {
 	Path         string
 }
 
-func ReadFiles(ctx context.Context, dir string) ([]File, error) {
+// ReadFiles reads the manifests of an export directory. The reports written next to them, like
+// exporter.ReportFiles, are not resource manifests and are skipped.
+func ReadFiles(ctx context.Context, dir string, reports []string) ([]File, error) {
 	log := logrus.New()
 
 	files, err := ioutil.ReadDir(dir)
 	if err != nil {
 		return nil, err
 	}
-	return readFiles(ctx, dir, files, log)
+	skipped := map[string]bool{}
+	for _, report := range reports {
+		skipped[report] = true
+	}
+	return readFiles(ctx, dir, files, skipped, log)
 }
 
-func readFiles(ctx context.Context, path string, files []os.FileInfo, log *logrus.Logger) ([]File, error) {
+func readFiles(ctx context.Context, path string, files []os.FileInfo, reports map[string]bool, log *logrus.Logger) ([]File, error) {
 	jsonFiles := []File{}
 	for _, file := range files {
 		filePath := fmt.Sprintf("%v/%v", path, file.Name())
 		if file.IsDir() {
-			if file.Name() == "failures" {
+			// the Events exported with --include-events are not applied
+			if file.Name() == "failures" || file.Name() == "_events" {
 				continue
 			}
 			newFiles, err := ioutil.ReadDir(filePath)
 			if err != nil {
 				return nil, err
 			}
-			files, err := readFiles(ctx, filePath, newFiles, log)
+			files, err := readFiles(ctx, filePath, newFiles, reports, log)
 			if err != nil {
 				return nil, err
 			}
 			jsonFiles = append(jsonFiles, files...)
 		} else {
+			// a temporary file is left behind by an export that died while writing it
+			if reports[file.Name()] || IsTemp(file.Name()) {
+				continue
+			}
 			data, err := ioutil.ReadFile(filePath)
 			if err != nil {
 				return nil, err
 			}
+			// the files of an export written with --compress are gzipped
+			if strings.HasSuffix(file.Name(), ".gz") {
+				data, err = gunzip(data)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", filePath, err)
+				}
+			}
 			json, err := yaml.YAMLToJSON(data)
 			if err != nil {
 				return nil, err
@@ -72,6 +93,15 @@ func readFiles(ctx context.Context, path string, files []os.FileInfo, log *logru
 	return jsonFiles, nil
 }
 
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
 // TODO: @shawn-hurley Add errors for these methods to validate that the correct struct values are set.
 type PathOpts struct {
 	TransformDir      string
@@ -1,8 +1,16 @@
 package file_test
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
 	"github.com/konveyor-ecosystem/kubectl-migrate/internal/file"
 )
 
@@ -88,3 +96,50 @@ func TestGetOutputFilePath(t *testing.T) {
 		}
 	}
 }
+
+func TestReadFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"resources/ns/ConfigMap__v1_ns_app.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n",
+		"resources/ns/apps_deployments/web.yaml": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n",
+		"failures/ns/failures.json":              "[]\n",
+		"export-summary.json":                    "{\"resources\": {}}\n",
+		"export-summary.txt":                     "Export started\n",
+		"images.json":                            "[]\n",
+		"helm-releases.json":                     "[]\n",
+		"graph.json":                             "{\"nodes\": [], \"edges\": []}\n",
+		"workloads.json":                         "[]\n",
+	}
+	// the files of an export written with --compress
+	buf := &bytes.Buffer{}
+	zw := gzip.NewWriter(buf)
+	if _, err := zw.Write([]byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: db\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files["resources/ns/secrets_db.yaml.gz"] = buf.String()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	read, err := file.ReadFiles(context.TODO(), dir, exporter.ReportFiles)
+	if err != nil {
+		t.Fatalf("ReadFiles() error = %v", err)
+	}
+	names := []string{}
+	for _, f := range read {
+		names = append(names, f.Unstructured.GetName())
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "app,db,web" {
+		t.Errorf("ReadFiles() read %v, want the app, db and web manifests only", names)
+	}
+}
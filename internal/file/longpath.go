@@ -0,0 +1,20 @@
+package file
+
+import "strings"
+
+// longPathPrefix makes Windows accept paths longer than MAX_PATH, 260 characters, without long
+// paths enabled system-wide
+const longPathPrefix = `\\?\`
+
+// prefixLongPath returns the absolute Windows path abs with the long path prefix, \\server\share
+// network paths becoming \\?\UNC\server\share. The prefixed paths are used as is by Windows, they
+// must be clean and use backslashes.
+func prefixLongPath(abs string) string {
+	switch {
+	case strings.HasPrefix(abs, longPathPrefix):
+		return abs
+	case strings.HasPrefix(abs, `\\`):
+		return longPathPrefix + `UNC\` + abs[2:]
+	}
+	return longPathPrefix + abs
+}
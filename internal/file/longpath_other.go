@@ -0,0 +1,8 @@
+//go:build !windows
+
+package file
+
+// LongPath returns path as is, only Windows limits the length of paths
+func LongPath(path string) (string, error) {
+	return path, nil
+}
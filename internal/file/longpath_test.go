@@ -0,0 +1,22 @@
+package file
+
+import "testing"
+
+func Test_prefixLongPath(t *testing.T) {
+	tests := []struct {
+		name string
+		abs  string
+		want string
+	}{
+		{name: "given a drive path, should prefix it", abs: `C:\exports\prod`, want: `\\?\C:\exports\prod`},
+		{name: "given a network path, should prefix it as UNC", abs: `\\nas\backups\exports`, want: `\\?\UNC\nas\backups\exports`},
+		{name: "given a prefixed path, should keep it", abs: `\\?\C:\exports`, want: `\\?\C:\exports`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := prefixLongPath(tt.abs); got != tt.want {
+				t.Errorf("prefixLongPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
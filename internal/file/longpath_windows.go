@@ -0,0 +1,13 @@
+package file
+
+import "path/filepath"
+
+// LongPath returns path as an absolute path with the long path prefix, so that the files under it
+// can be written whatever the length of their path
+func LongPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return prefixLongPath(abs), nil
+}
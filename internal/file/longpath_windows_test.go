@@ -0,0 +1,32 @@
+package file_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/file"
+)
+
+func TestLongPath_windows(t *testing.T) {
+	dir, err := file.LongPath(t.TempDir())
+	if err != nil {
+		t.Fatalf("LongPath() error = %v", err)
+	}
+	if !strings.HasPrefix(dir, `\\?\`) {
+		t.Fatalf("LongPath() = %q, want a prefixed path", dir)
+	}
+	// a path well above MAX_PATH, like a CRD of a long group in the kind layout
+	deep := filepath.Join(dir, "resources", strings.Repeat("n", 63), "_cluster", strings.Repeat("g", 120))
+	if err := os.MkdirAll(deep, 0700); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	path := filepath.Join(deep, strings.Repeat("o", 150)+".yaml")
+	if err := file.WriteAtomic(file.OS, path, []byte("kind: ConfigMap\n"), false); err != nil {
+		t.Fatalf("WriteAtomic() of a %d characters path error = %v", len(path), err)
+	}
+	if got, err := os.ReadFile(path); err != nil || string(got) != "kind: ConfigMap\n" {
+		t.Errorf("file = %q, %v, want the written content", got, err)
+	}
+}
@@ -0,0 +1,136 @@
+package index
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/file"
+)
+
+// File is the name of the index written at the root of an export directory
+const File = "index.json"
+
+// Entry describes a manifest file written by export. The object fields are empty for the files
+// holding several objects.
+type Entry struct {
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	SHA256     string `json:"sha256"`
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name,omitempty"`
+}
+
+// Sum returns the SHA-256 of data, hex encoded
+func Sum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Write writes the entries sorted by path in the index file of dir, atomically
+func Write(dir string, entries []Entry) error {
+	return WriteDurable(dir, entries, false)
+}
+
+// WriteDurable writes the index like Write, flushed to disk when durable is set
+func WriteDurable(dir string, entries []Entry, durable bool) error {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	if entries == nil {
+		entries = []Entry{}
+	}
+	indexBytes, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return file.WriteAtomic(file.OS, filepath.Join(dir, File), append(indexBytes, '\n'), durable)
+}
+
+// Read reads the index file of dir
+func Read(dir string) ([]Entry, error) {
+	indexBytes, err := os.ReadFile(filepath.Join(dir, File))
+	if err != nil {
+		return nil, err
+	}
+	entries := []Entry{}
+	if err := json.Unmarshal(indexBytes, &entries); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", File, err)
+	}
+	return entries, nil
+}
+
+// Problem is a difference between the index and the files of the export directory
+type Problem struct {
+	Path   string
+	Reason string
+}
+
+func (p Problem) String() string {
+	return p.Path + ": " + p.Reason
+}
+
+// Verify re-hashes the indexed files of dir and reports the missing, truncated or modified ones,
+// and the files found under the resources directory that are not indexed
+func Verify(dir string, entries []Entry) ([]Problem, error) {
+	problems := []Problem{}
+	indexed := map[string]bool{}
+	for _, e := range entries {
+		indexed[filepath.ToSlash(e.Path)] = true
+		size, sum, err := hashFile(filepath.Join(dir, filepath.FromSlash(e.Path)))
+		switch {
+		case os.IsNotExist(err):
+			problems = append(problems, Problem{Path: e.Path, Reason: "missing"})
+		case err != nil:
+			return nil, err
+		case size != e.Size:
+			problems = append(problems, Problem{Path: e.Path, Reason: fmt.Sprintf("size %d, expected %d", size, e.Size)})
+		case sum != e.SHA256:
+			problems = append(problems, Problem{Path: e.Path, Reason: "checksum mismatch"})
+		}
+	}
+
+	resourcesDir := filepath.Join(dir, "resources")
+	err := filepath.Walk(resourcesDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == resourcesDir {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel = filepath.ToSlash(rel); !indexed[rel] {
+			problems = append(problems, Problem{Path: rel, Reason: "not in " + File})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return problems, nil
+}
+
+func hashFile(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
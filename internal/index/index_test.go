@@ -0,0 +1,88 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	files := map[string]string{
+		"resources/foo/deployments.apps_web.yaml": "kind: Deployment\n",
+		"resources/foo/configmaps_app.yaml":       "kind: ConfigMap\n",
+		"resources/foo/services_web.yaml":         "kind: Service\n",
+	}
+	write := func(t *testing.T, dir string) []Entry {
+		entries := []Entry{}
+		for name, content := range files {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			entries = append(entries, Entry{Path: name, Size: int64(len(content)), SHA256: Sum([]byte(content))})
+		}
+		if err := Write(dir, entries); err != nil {
+			t.Fatal(err)
+		}
+		read, err := Read(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return read
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, dir string)
+		want   []Problem
+	}{
+		{
+			name:   "untouched export has no problems",
+			tamper: func(t *testing.T, dir string) {},
+			want:   []Problem{},
+		},
+		{
+			name: "modified, truncated, missing and extra files are reported",
+			tamper: func(t *testing.T, dir string) {
+				resources := filepath.Join(dir, "resources", "foo")
+				if err := os.WriteFile(filepath.Join(resources, "deployments.apps_web.yaml"), []byte("kind: Deploymenz\n"), 0600); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(resources, "configmaps_app.yaml"), []byte("kind"), 0600); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Remove(filepath.Join(resources, "services_web.yaml")); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(resources, "secrets_injected.yaml"), []byte("kind: Secret\n"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []Problem{
+				{Path: "resources/foo/configmaps_app.yaml", Reason: "size 4, expected 16"},
+				{Path: "resources/foo/deployments.apps_web.yaml", Reason: "checksum mismatch"},
+				{Path: "resources/foo/services_web.yaml", Reason: "missing"},
+				{Path: "resources/foo/secrets_injected.yaml", Reason: "not in index.json"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			entries := write(t, dir)
+			tt.tamper(t, dir)
+
+			got, err := Verify(dir, entries)
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
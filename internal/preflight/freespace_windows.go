@@ -0,0 +1,16 @@
+package preflight
+
+import "golang.org/x/sys/windows"
+
+// freeSpace returns the bytes available to the user on the volume of dir
+func freeSpace(dir string) (uint64, error) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	var available, total, free uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &available, &total, &free); err != nil {
+		return 0, err
+	}
+	return available, nil
+}
@@ -0,0 +1,142 @@
+package preflight
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Permission is a verb on a resource, in a namespace or cluster-wide when Namespace is empty,
+// optionally restricted to a single object
+type Permission struct {
+	Resource  schema.GroupVersionResource `json:"resource"`
+	Namespace string                      `json:"namespace,omitempty"`
+	Name      string                      `json:"name,omitempty"`
+	Verb      string                      `json:"verb"`
+	Allowed   bool                        `json:"allowed"`
+}
+
+// Allowed reviews whether the current user is allowed the verb on the resource in the namespace,
+// cluster-wide when the namespace is empty
+func Allowed(ctx context.Context, client kubernetes.Interface, namespace string, verb string, gvr schema.GroupVersionResource, name string) (bool, error) {
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      verb,
+				Group:     gvr.Group,
+				Version:   gvr.Version,
+				Resource:  gvr.Resource,
+				Name:      name,
+			},
+		},
+	}
+	review, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	return review.Status.Allowed, nil
+}
+
+// ReviewPermissions sets Allowed on each permission, it stops at the first review that fails
+func ReviewPermissions(ctx context.Context, client kubernetes.Interface, permissions []Permission) error {
+	for i, p := range permissions {
+		allowed, err := Allowed(ctx, client, p.Namespace, p.Verb, p.Resource, p.Name)
+		if err != nil {
+			return err
+		}
+		permissions[i].Allowed = allowed
+	}
+	return nil
+}
+
+// PrintPermissions writes the permissions as a table
+func PrintPermissions(out io.Writer, permissions []Permission) {
+	table := tablewriter.NewWriter(out)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Resource", "Scope", "Verb", "Status"})
+	for _, p := range permissions {
+		scope := "cluster"
+		if p.Namespace != "" {
+			scope = p.Namespace
+		}
+		resource := p.Resource.GroupResource().String()
+		if p.Name != "" {
+			resource += "/" + p.Name
+		}
+		status := "ALLOWED"
+		if !p.Allowed {
+			status = "DENIED"
+		}
+		table.Append([]string{resource, scope, p.Verb, status})
+	}
+	table.Render()
+}
+
+// PolicyRules returns the rules granting the permissions, whether they are allowed or not: one
+// rule per API group and verbs, the permissions on a single object in rules of their own
+func PolicyRules(permissions []Permission) []rbacv1.PolicyRule {
+	type target struct {
+		resource schema.GroupResource
+		name     string
+	}
+	type ruleKey struct {
+		group string
+		verbs string
+		name  string
+	}
+	verbs := map[target]map[string]bool{}
+	targets := []target{}
+	for _, p := range permissions {
+		t := target{resource: p.Resource.GroupResource(), name: p.Name}
+		if verbs[t] == nil {
+			verbs[t] = map[string]bool{}
+			targets = append(targets, t)
+		}
+		verbs[t][p.Verb] = true
+	}
+	rules := map[ruleKey]*rbacv1.PolicyRule{}
+	keys := []ruleKey{}
+	for _, t := range targets {
+		list := make([]string, 0, len(verbs[t]))
+		for verb := range verbs[t] {
+			list = append(list, verb)
+		}
+		sort.Strings(list)
+		key := ruleKey{group: t.resource.Group, verbs: strings.Join(list, ","), name: t.name}
+		rule, ok := rules[key]
+		if !ok {
+			rule = &rbacv1.PolicyRule{APIGroups: []string{key.group}, Verbs: list}
+			if key.name != "" {
+				rule.ResourceNames = []string{key.name}
+			}
+			rules[key] = rule
+			keys = append(keys, key)
+		}
+		rule.Resources = append(rule.Resources, t.resource.Resource)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].group != keys[j].group {
+			return keys[i].group < keys[j].group
+		}
+		if keys[i].verbs != keys[j].verbs {
+			return keys[i].verbs < keys[j].verbs
+		}
+		return keys[i].name < keys[j].name
+	})
+	result := make([]rbacv1.PolicyRule, 0, len(keys))
+	for _, key := range keys {
+		rule := rules[key]
+		sort.Strings(rule.Resources)
+		result = append(result, *rule)
+	}
+	return result
+}
@@ -0,0 +1,51 @@
+package preflight
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func Test_ReviewPermissions(t *testing.T) {
+	pods := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	secrets := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	permissions := []Permission{
+		{Resource: pods, Namespace: "foo", Verb: "list"},
+		{Resource: secrets, Namespace: "foo", Verb: "list"},
+	}
+	if err := ReviewPermissions(context.Background(), allowList(map[string]bool{"pods": true}), permissions); err != nil {
+		t.Fatal(err)
+	}
+	if !permissions[0].Allowed || permissions[1].Allowed {
+		t.Errorf("ReviewPermissions() = %+v, want pods allowed and secrets denied", permissions)
+	}
+}
+
+func Test_PolicyRules(t *testing.T) {
+	permissions := []Permission{
+		{Resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, Verb: "list"},
+		{Resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, Verb: "get"},
+		{Resource: schema.GroupVersionResource{Version: "v1", Resource: "services"}, Verb: "get"},
+		{Resource: schema.GroupVersionResource{Version: "v1", Resource: "services"}, Verb: "list"},
+		{Resource: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, Verb: "list"},
+		{Resource: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, Verb: "get"},
+		{Resource: schema.GroupVersionResource{Version: "v1", Resource: "events"}, Verb: "list"},
+		// the permissions on a single object are granted apart
+		{Resource: schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, Name: "foo", Verb: "get"},
+		// whether a permission is allowed does not matter
+		{Resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}, Verb: "list", Allowed: true},
+	}
+	want := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"namespaces"}, ResourceNames: []string{"foo"}, Verbs: []string{"get"}},
+		{APIGroups: []string{""}, Resources: []string{"configmaps", "services"}, Verbs: []string{"get", "list"}},
+		{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"list"}},
+		{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get", "list"}},
+		{APIGroups: []string{"apps"}, Resources: []string{"statefulsets"}, Verbs: []string{"list"}},
+	}
+	if got := PolicyRules(permissions); !reflect.DeepEqual(got, want) {
+		t.Errorf("PolicyRules() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,190 @@
+// Package preflight checks, before an export, what would otherwise make it fail after listing a
+// large part of the cluster: an unreachable cluster, a missing namespace, missing permissions or
+// an export directory that cannot be written.
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Check statuses, only failures abort an export
+const (
+	StatusPass = "pass"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+)
+
+// MinFreeSpace is the free space below which the export directory check fails
+const MinFreeSpace = 100 * 1024 * 1024
+
+// Resources are the representative resources that must be listed in the exported namespaces
+var Resources = []schema.GroupVersionResource{
+	{Version: "v1", Resource: "pods"},
+	{Version: "v1", Resource: "services"},
+	{Version: "v1", Resource: "configmaps"},
+	{Version: "v1", Resource: "secrets"},
+	{Version: "v1", Resource: "serviceaccounts"},
+	{Version: "v1", Resource: "persistentvolumeclaims"},
+	{Group: "apps", Version: "v1", Resource: "deployments"},
+	{Group: "apps", Version: "v1", Resource: "statefulsets"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "rolebindings"},
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Check  string `json:"check"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// Run runs the checks of the cluster and of the export directory, the namespaces and the
+// permissions are not checked when the cluster cannot be reached
+func Run(ctx context.Context, client kubernetes.Interface, namespaces []string, exportDir string) []Result {
+	results := []Result{}
+	connectivity := checkConnectivity(client)
+	results = append(results, connectivity)
+	if connectivity.Status != StatusFail {
+		for _, namespace := range namespaces {
+			results = append(results, checkNamespace(ctx, client, namespace), checkAccess(ctx, client, namespace))
+		}
+	}
+	return append(results, checkExportDir(exportDir), checkFreeSpace(exportDir))
+}
+
+// Failed reports whether a check failed
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes the results as a table
+func Print(out io.Writer, results []Result) {
+	table := tablewriter.NewWriter(out)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Check", "Status", "Detail"})
+	for _, r := range results {
+		table.Append([]string{r.Check, strings.ToUpper(r.Status), r.Detail})
+	}
+	table.Render()
+}
+
+func checkConnectivity(client kubernetes.Interface) Result {
+	result := Result{Check: "cluster connectivity"}
+	info, err := client.Discovery().ServerVersion()
+	if err != nil {
+		result.Status, result.Detail = StatusFail, err.Error()
+		return result
+	}
+	result.Status, result.Detail = StatusPass, "server version "+info.GitVersion
+	return result
+}
+
+// checkNamespace fails when the namespace does not exist, not being allowed to get it only warns
+// as its resources may still be listed
+func checkNamespace(ctx context.Context, client kubernetes.Interface, namespace string) Result {
+	result := Result{Check: "namespace " + namespace}
+	_, err := client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		result.Status, result.Detail = StatusFail, "the namespace does not exist"
+	case err != nil:
+		result.Status, result.Detail = StatusWarn, err.Error()
+	default:
+		result.Status, result.Detail = StatusPass, "exists"
+	}
+	return result
+}
+
+// checkAccess reviews the permission to list the representative resources in the namespace. The
+// resources denied are exported as permission failures, the check fails when all of them are.
+func checkAccess(ctx context.Context, client kubernetes.Interface, namespace string) Result {
+	result := Result{Check: "list access in " + namespace}
+	denied := []string{}
+	for _, gvr := range Resources {
+		allowed, err := Allowed(ctx, client, namespace, "list", gvr, "")
+		if err != nil {
+			result.Status, result.Detail = StatusWarn, "cannot review the permissions: "+err.Error()
+			return result
+		}
+		if !allowed {
+			denied = append(denied, gvr.GroupResource().String())
+		}
+	}
+	switch {
+	case len(denied) == len(Resources):
+		result.Status, result.Detail = StatusFail, "cannot list any of "+resourceNames()
+	case len(denied) > 0:
+		result.Status, result.Detail = StatusWarn, "cannot list "+strings.Join(denied, ", ")
+	default:
+		result.Status, result.Detail = StatusPass, "can list "+resourceNames()
+	}
+	return result
+}
+
+func resourceNames() string {
+	names := make([]string, 0, len(Resources))
+	for _, gvr := range Resources {
+		names = append(names, gvr.GroupResource().String())
+	}
+	return strings.Join(names, ", ")
+}
+
+// checkExportDir writes a file in the export directory, or in its closest existing parent when it
+// does not exist yet so that the check leaves nothing behind
+func checkExportDir(exportDir string) Result {
+	result := Result{Check: "export directory " + exportDir}
+	dir := existingDir(exportDir)
+	f, err := os.CreateTemp(dir, ".preflight-")
+	if err != nil {
+		result.Status, result.Detail = StatusFail, "not writable: "+err.Error()
+		return result
+	}
+	f.Close()
+	os.Remove(f.Name())
+	result.Status, result.Detail = StatusPass, "writable"
+	return result
+}
+
+func checkFreeSpace(exportDir string) Result {
+	result := Result{Check: "free space in " + exportDir}
+	free, err := freeSpace(existingDir(exportDir))
+	switch {
+	case err != nil:
+		result.Status, result.Detail = StatusWarn, "cannot read the free space: "+err.Error()
+	case free < MinFreeSpace:
+		result.Status, result.Detail = StatusFail, fmt.Sprintf("%d MiB free, at least %d MiB needed", free/1024/1024, MinFreeSpace/1024/1024)
+	default:
+		result.Status, result.Detail = StatusPass, fmt.Sprintf("%d MiB free", free/1024/1024)
+	}
+	return result
+}
+
+// existingDir returns dir or its closest existing parent, which may be a file
+func existingDir(dir string) string {
+	dir = filepath.Clean(dir)
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
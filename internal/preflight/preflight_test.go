@@ -0,0 +1,134 @@
+package preflight
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// allowList returns a client allowing to list the given resources only
+func allowList(allowed map[string]bool, objects ...runtime.Object) *fake.Clientset {
+	client := fake.NewSimpleClientset(objects...)
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		review.Status.Allowed = allowed[attributes.Resource]
+		return true, review, nil
+	})
+	return client
+}
+
+func Test_Run(t *testing.T) {
+	foo := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}
+	all := map[string]bool{}
+	for _, gvr := range Resources {
+		all[gvr.Resource] = true
+	}
+
+	tests := []struct {
+		name       string
+		client     *fake.Clientset
+		namespaces []string
+		want       map[string]string
+		wantFailed bool
+	}{
+		{
+			name:       "all checks pass",
+			client:     allowList(all, foo),
+			namespaces: []string{"foo"},
+			want: map[string]string{
+				"cluster connectivity": StatusPass,
+				"namespace foo":        StatusPass,
+				"list access in foo":   StatusPass,
+			},
+		},
+		{
+			name:       "missing namespace",
+			client:     allowList(all, foo),
+			namespaces: []string{"foo", "bar"},
+			want: map[string]string{
+				"namespace foo": StatusPass,
+				"namespace bar": StatusFail,
+			},
+			wantFailed: true,
+		},
+		{
+			name:       "some resources cannot be listed",
+			client:     allowList(map[string]bool{"pods": true, "services": true}, foo),
+			namespaces: []string{"foo"},
+			want:       map[string]string{"list access in foo": StatusWarn},
+		},
+		{
+			name:       "no resource can be listed",
+			client:     allowList(map[string]bool{}, foo),
+			namespaces: []string{"foo"},
+			want:       map[string]string{"list access in foo": StatusFail},
+			wantFailed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exportDir := filepath.Join(t.TempDir(), "export")
+			results := Run(context.Background(), tt.client, tt.namespaces, exportDir)
+
+			got := map[string]string{}
+			for _, r := range results {
+				got[r.Check] = r.Status
+			}
+			for check, status := range tt.want {
+				if got[check] != status {
+					t.Errorf("%s = %q, want %q", check, got[check], status)
+				}
+			}
+			if got["export directory "+exportDir] != StatusPass {
+				t.Errorf("export directory = %q, want %q", got["export directory "+exportDir], StatusPass)
+			}
+			if _, err := os.Stat(exportDir); !os.IsNotExist(err) {
+				t.Errorf("the export directory should not be created, stat: %v", err)
+			}
+			if Failed(results) != tt.wantFailed {
+				t.Errorf("Failed() = %v, want %v", Failed(results), tt.wantFailed)
+			}
+		})
+	}
+}
+
+func Test_checkExportDir(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte{}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// a file stands where the export directory should be
+	if r := checkExportDir(filepath.Join(file, "export")); r.Status != StatusFail {
+		t.Errorf("checkExportDir() = %+v, want a failure", r)
+	}
+	if r := checkExportDir(dir); r.Status != StatusPass {
+		t.Errorf("checkExportDir() = %+v, want a pass", r)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("checkExportDir() left files behind: %v", entries)
+	}
+}
+
+func Test_Print(t *testing.T) {
+	out := &bytes.Buffer{}
+	Print(out, []Result{{Check: "namespace foo", Status: StatusFail, Detail: "the namespace does not exist"}})
+	if !strings.Contains(out.String(), "FAIL") || !strings.Contains(out.String(), "the namespace does not exist") {
+		t.Errorf("Print() = %s", out.String())
+	}
+}
@@ -0,0 +1,103 @@
+package s3
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Credentials sign the requests, SessionToken is set for temporary credentials
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// profile is the AWS profile of the environment: AWS_PROFILE or default, read from the shared
+// credentials and config files, the environment variables taking precedence
+type profile struct {
+	name   string
+	values map[string]string
+	region string
+}
+
+func loadProfile() profile {
+	p := profile{name: os.Getenv("AWS_PROFILE"), values: map[string]string{}}
+	if p.name == "" {
+		p.name = "default"
+	}
+	home, _ := os.UserHomeDir()
+	configFile := os.Getenv("AWS_CONFIG_FILE")
+	if configFile == "" && home != "" {
+		configFile = filepath.Join(home, ".aws", "config")
+	}
+	credentialsFile := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if credentialsFile == "" && home != "" {
+		credentialsFile = filepath.Join(home, ".aws", "credentials")
+	}
+
+	// the profiles of the config file are named [profile name], but the default one
+	configSection := "profile " + p.name
+	if p.name == "default" {
+		configSection = "default"
+	}
+	for key, value := range readSection(configFile, configSection) {
+		p.values[key] = value
+	}
+	for key, value := range readSection(credentialsFile, p.name) {
+		p.values[key] = value
+	}
+	// the credentials of the environment replace those of the files, session token included
+	if os.Getenv("AWS_ACCESS_KEY_ID") != "" {
+		p.values["aws_access_key_id"] = os.Getenv("AWS_ACCESS_KEY_ID")
+		p.values["aws_secret_access_key"] = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		p.values["aws_session_token"] = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	p.region = os.Getenv("AWS_REGION")
+	if p.region == "" {
+		p.region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if p.region == "" {
+		p.region = p.values["region"]
+	}
+	return p
+}
+
+func (p profile) credentials() (Credentials, error) {
+	c := Credentials{
+		AccessKeyID:     p.values["aws_access_key_id"],
+		SecretAccessKey: p.values["aws_secret_access_key"],
+		SessionToken:    p.values["aws_session_token"],
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("no S3 credentials found, set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY or the profile %q of the shared credentials file", p.name)
+	}
+	return c, nil
+}
+
+// readSection returns the keys of a section of an INI file, none when the file cannot be read
+func readSection(path, section string) map[string]string {
+	values := map[string]string{}
+	f, err := os.Open(path)
+	if err != nil {
+		return values
+	}
+	defer f.Close()
+	current := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			current = strings.TrimSpace(line[1 : len(line)-1])
+		case current == section:
+			if key, value, ok := strings.Cut(line, "="); ok {
+				values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,250 @@
+// Package s3 writes exports to an S3 bucket or to S3-compatible object storage, like MinIO or Ceph.
+// It implements the few calls an export needs, signed with AWS Signature Version 4, with the
+// credentials and the region read from the standard AWS environment variables and shared files.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Scheme prefixes the export directories written to object storage, e.g. s3://bucket/exports
+const Scheme = "s3://"
+
+// Server-side encryption modes of the uploaded objects
+const (
+	SSEAES256 = "AES256"
+	SSEKMS    = "aws:kms"
+)
+
+// DefaultRegion is used when no region is configured, S3-compatible storage usually accepts it
+const DefaultRegion = "us-east-1"
+
+// IsURL reports whether an export directory is an s3:// URL
+func IsURL(dir string) bool {
+	return strings.HasPrefix(dir, Scheme)
+}
+
+// Location is a bucket and the key prefix of an export in it
+type Location struct {
+	Bucket string
+	Prefix string
+}
+
+// ParseURL parses an s3://bucket/prefix URL, the prefix may be empty
+func ParseURL(u string) (Location, error) {
+	if !IsURL(u) {
+		return Location{}, fmt.Errorf("%q is not an %s URL", u, Scheme)
+	}
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(u, Scheme), "/")
+	if bucket == "" {
+		return Location{}, fmt.Errorf("%q has no bucket, expected %sbucket/prefix", u, Scheme)
+	}
+	return Location{Bucket: bucket, Prefix: strings.Trim(prefix, "/")}, nil
+}
+
+// Key returns the key of a file of the export, rel is its slash-separated path in the export
+func (l Location) Key(rel string) string {
+	if l.Prefix == "" {
+		return rel
+	}
+	return path.Join(l.Prefix, rel)
+}
+
+func (l Location) String() string {
+	if l.Prefix == "" {
+		return Scheme + l.Bucket
+	}
+	return Scheme + l.Bucket + "/" + l.Prefix
+}
+
+// Config configures a Client. An empty Endpoint is AWS S3 in the region, otherwise the buckets of
+// the endpoint are addressed by path, as MinIO and Ceph expect.
+type Config struct {
+	Endpoint string
+	Region   string
+	// SSE is the server-side encryption of the uploaded objects, SSEAES256 or SSEKMS, with the
+	// key SSEKMSKeyID for SSEKMS or the default KMS key of the bucket when empty
+	SSE         string
+	SSEKMSKeyID string
+	// Retries is the number of times a request failing with a transient error is retried, after
+	// Backoff doubled on each retry
+	Retries int
+	Backoff time.Duration
+}
+
+// Client calls the S3 API
+type Client struct {
+	config      Config
+	credentials Credentials
+	http        *http.Client
+	now         func() time.Time
+	log         logrus.FieldLogger
+}
+
+// NewClient returns a client with the credentials and, unless configured, the region of the
+// standard AWS environment variables and shared files
+func NewClient(config Config, log logrus.FieldLogger) (*Client, error) {
+	profile := loadProfile()
+	credentials, err := profile.credentials()
+	if err != nil {
+		return nil, err
+	}
+	if config.Region == "" {
+		config.Region = profile.region
+	}
+	if config.Region == "" {
+		config.Region = DefaultRegion
+	}
+	return newClient(config, credentials, log), nil
+}
+
+func newClient(config Config, credentials Credentials, log logrus.FieldLogger) *Client {
+	return &Client{config: config, credentials: credentials, http: http.DefaultClient, now: time.Now, log: log}
+}
+
+// ResponseError is an error returned by the S3 API
+type ResponseError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *ResponseError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("S3 returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("S3 returned %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// isTransient reports whether a failed request is worth retrying: the server errors, the
+// throttling and the network errors
+func isTransient(ctx context.Context, err error) bool {
+	var responseErr *ResponseError
+	if errors.As(err, &responseErr) {
+		return responseErr.StatusCode >= 500 || responseErr.StatusCode == http.StatusTooManyRequests ||
+			responseErr.Code == "SlowDown" || responseErr.Code == "RequestTimeout"
+	}
+	return ctx.Err() == nil
+}
+
+// PutObject uploads data as the object key of the bucket
+func (c *Client) PutObject(ctx context.Context, bucket, key string, data []byte) error {
+	header := http.Header{}
+	if c.config.SSE != "" {
+		header.Set("X-Amz-Server-Side-Encryption", c.config.SSE)
+	}
+	if c.config.SSE == SSEKMS && c.config.SSEKMSKeyID != "" {
+		header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", c.config.SSEKMSKeyID)
+	}
+	_, err := c.do(ctx, http.MethodPut, bucket, key, nil, header, data)
+	return err
+}
+
+type listBucketResult struct {
+	KeyCount int `xml:"KeyCount"`
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+}
+
+// HasObjects reports whether the bucket has objects under the prefix
+func (c *Client) HasObjects(ctx context.Context, bucket, prefix string) (bool, error) {
+	query := url.Values{"list-type": {"2"}, "max-keys": {"1"}}
+	if prefix != "" {
+		query.Set("prefix", strings.TrimSuffix(prefix, "/")+"/")
+	}
+	body, err := c.do(ctx, http.MethodGet, bucket, "", query, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	result := listBucketResult{}
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return false, fmt.Errorf("invalid list response: %w", err)
+	}
+	return result.KeyCount > 0 || len(result.Contents) > 0, nil
+}
+
+// do sends a request, retrying the transient errors, and returns the body of the response
+func (c *Client) do(ctx context.Context, method, bucket, key string, query url.Values, header http.Header, data []byte) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		body, err := c.send(ctx, method, bucket, key, query, header, data)
+		if err == nil || attempt >= c.config.Retries || !isTransient(ctx, err) {
+			return body, err
+		}
+		delay := wait.Jitter(c.config.Backoff<<attempt, 1.0)
+		if c.log != nil {
+			c.log.Warnf("transient error, retrying in %s (%d/%d): %v", delay, attempt+1, c.config.Retries, err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, bucket, key string, query url.Values, header http.Header, data []byte) ([]byte, error) {
+	u, err := c.objectURL(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.ContentLength = int64(len(data))
+	sign(req, data, c.credentials, c.config.Region, c.now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		responseErr := &ResponseError{StatusCode: resp.StatusCode}
+		xml.Unmarshal(body, &struct {
+			Code    *string `xml:"Code"`
+			Message *string `xml:"Message"`
+		}{&responseErr.Code, &responseErr.Message})
+		return nil, responseErr
+	}
+	return body, nil
+}
+
+// objectURL returns the URL of the object, or of the bucket when key is empty. The buckets of AWS
+// S3 are addressed by host, unless their name has dots that would not match its certificate.
+func (c *Client) objectURL(bucket, key string) (*url.URL, error) {
+	base := strings.TrimSuffix(c.config.Endpoint, "/")
+	bucketPath := "/" + uriEncode(bucket, false)
+	switch {
+	case base == "" && !strings.Contains(bucket, "."):
+		base = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, c.config.Region)
+		bucketPath = ""
+	case base == "":
+		base = fmt.Sprintf("https://s3.%s.amazonaws.com", c.config.Region)
+	case !strings.Contains(base, "://"):
+		base = "https://" + base
+	}
+	return url.Parse(base + bucketPath + "/" + uriEncode(key, true))
+}
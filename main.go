@@ -1,18 +1,37 @@
 package main
 
 import (
+	"errors"
 	"os"
 
 	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/apply"
+	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/archive"
+	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/cleanup"
+	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/completion"
 	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/convert"
-	export "github.com/konveyor-ecosystem/kubectl-migrate/cmd/export"
+	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/decrypt"
+	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/diff"
+	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/export"
+	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/images"
+	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/permissions"
+	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/plan"
 	plugin_manager "github.com/konveyor-ecosystem/kubectl-migrate/cmd/plugin-manager"
+	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/preflight"
+	pvc_migrate "github.com/konveyor-ecosystem/kubectl-migrate/cmd/pvc-migrate"
+	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/quiesce"
+	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/replay"
+	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/report"
+	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/rewrite"
 	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/runfn"
 	skopeo_sync_gen "github.com/konveyor-ecosystem/kubectl-migrate/cmd/skopeo-sync-gen"
+	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/status"
 	transfer_pvc "github.com/konveyor-ecosystem/kubectl-migrate/cmd/transfer-pvc"
 	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/transform"
 	tunnel_api "github.com/konveyor-ecosystem/kubectl-migrate/cmd/tunnel-api"
+	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/validate"
+	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/verify"
 	"github.com/konveyor-ecosystem/kubectl-migrate/cmd/version"
+	"github.com/konveyor-ecosystem/kubectl-migrate/internal/exporter"
 	"github.com/konveyor-ecosystem/kubectl-migrate/internal/flags"
 	"github.com/spf13/cobra"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
@@ -29,17 +48,94 @@ It provides commands for exporting, transforming, and applying resources across
 This tool integrates all features from the crane migration tool and can be used with the 'kubectl migrate' prefix.`,
 	}
 	f.ApplyFlags(&root)
+	// the completion command below documents the completion of the cluster objects
+	root.CompletionOptions.DisableDefaultCmd = true
 	root.AddCommand(export.NewExportCommand(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}, f))
+	root.AddCommand(diff.NewDiffCommand(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}, f))
+	root.AddCommand(replay.NewReplayCommand(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}, f))
+	root.AddCommand(validate.NewValidateCommand(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}, f))
+	root.AddCommand(rewrite.NewRewriteCommand(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}, f))
+	root.AddCommand(plan.NewPlanCommand(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}, f))
+	root.AddCommand(quiesce.NewQuiesceCommand(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}, f))
+	root.AddCommand(quiesce.NewUnquiesceCommand(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}, f))
+	root.AddCommand(pvc_migrate.NewPVCMigrateCommand(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}, f))
+	root.AddCommand(images.NewImagesCommand(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}, f))
+	root.AddCommand(cleanup.NewCleanupCommand(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}, f))
+	root.AddCommand(status.NewStatusCommand(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}, f))
+	root.AddCommand(report.NewReportCommand(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}, f))
+	root.AddCommand(permissions.NewPermissionsCommand(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}, f))
+	root.AddCommand(preflight.NewPreflightCommand(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}, f))
 	root.AddCommand(transfer_pvc.NewTransferPVCCommand(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}))
 	root.AddCommand(tunnel_api.NewTunnelAPIOptions(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}))
 	root.AddCommand(convert.NewConvertOptions(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}))
 	root.AddCommand(transform.NewTransformCommand(f))
 	root.AddCommand(skopeo_sync_gen.NewSkopeoSyncGenCommand(f))
 	root.AddCommand(apply.NewApplyCommand(f))
+	root.AddCommand(decrypt.NewDecryptCommand(f))
+	root.AddCommand(verify.NewVerifyCommand(f))
+	root.AddCommand(archive.NewArchiveCommand(f))
 	root.AddCommand(plugin_manager.NewPluginManagerCommand(f))
 	root.AddCommand(version.NewVersionCommand(f))
+	root.AddCommand(completion.NewCompletionCommand(f))
 	root.AddCommand(runfn.NewFnRunCommand(f))
 	if err := root.Execute(); err != nil {
+		// timeouts and partial export failures are told apart from fatal errors by the exit code
+		var interrupted *exporter.InterruptedError
+		if errors.As(err, &interrupted) {
+			os.Exit(exporter.ExitCodeInterrupted)
+		}
+		var budget *exporter.BudgetExceededError
+		if errors.As(err, &budget) {
+			os.Exit(exporter.ExitCodeBudgetExceeded)
+		}
+		var timeout *exporter.TimeoutError
+		if errors.As(err, &timeout) {
+			os.Exit(exporter.ExitCodeTimeout)
+		}
+		var drift *diff.DriftError
+		if errors.As(err, &drift) {
+			os.Exit(exporter.ExitCodeCheckFailed)
+		}
+		var invalid *validate.ValidationFailedError
+		if errors.As(err, &invalid) {
+			os.Exit(exporter.ExitCodeCheckFailed)
+		}
+		var notReady *plan.PlanFailedError
+		if errors.As(err, &notReady) {
+			os.Exit(exporter.ExitCodeCheckFailed)
+		}
+		var running *quiesce.QuiesceTimeoutError
+		if errors.As(err, &running) {
+			os.Exit(exporter.ExitCodeCheckFailed)
+		}
+		var unhealthy *status.UnhealthyError
+		if errors.As(err, &unhealthy) {
+			os.Exit(exporter.ExitCodeCheckFailed)
+		}
+		var denied *permissions.PermissionsDeniedError
+		if errors.As(err, &denied) {
+			os.Exit(exporter.ExitCodeCheckFailed)
+		}
+		var partial *exporter.PartialFailureError
+		if errors.As(err, &partial) {
+			os.Exit(exporter.ExitCodePartial)
+		}
+		var replayErr *replay.ReplayFailureError
+		if errors.As(err, &replayErr) {
+			os.Exit(exporter.ExitCodePartial)
+		}
+		var pvcs *pvc_migrate.PVCMigrateFailureError
+		if errors.As(err, &pvcs) {
+			os.Exit(exporter.ExitCodePartial)
+		}
+		var imagesErr *images.ImageCopyFailureError
+		if errors.As(err, &imagesErr) {
+			os.Exit(exporter.ExitCodePartial)
+		}
+		var remaining *cleanup.CleanupIncompleteError
+		if errors.As(err, &remaining) {
+			os.Exit(exporter.ExitCodePartial)
+		}
 		os.Exit(1)
 	}
 }